@@ -0,0 +1,542 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/api/middleware"
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/internal/policy"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// AdminDevicesPrefix is the path prefix for device administration endpoints
+const AdminDevicesPrefix = "/api/admin/devices/"
+
+// AdminDevicesPath lists registered devices and registers new ones
+const AdminDevicesPath = "/api/admin/devices"
+
+// AdminDeletedDevicesPath lists soft-deleted devices that can still be restored
+const AdminDeletedDevicesPath = "/api/admin/devices/deleted"
+
+// AdminPolicyPath exports the active policy
+const AdminPolicyPath = "/api/admin/policy"
+
+// AdminPolicyReviewPath lists policy rules approaching their review date
+const AdminPolicyReviewPath = "/api/admin/policy/review"
+
+// DefaultReviewWindow is how far ahead the policy review listing looks by default
+const DefaultReviewWindow = 30 * 24 * time.Hour
+
+// AdminLockoutsPath lists and lifts authentication lockouts
+const AdminLockoutsPath = "/api/admin/lockouts"
+
+// DevicePermissionsRoute is the normalized route name of the device permissions endpoint
+const DevicePermissionsRoute = "/api/admin/devices/{id}/permissions"
+
+// DeviceRoute is the normalized route name of the device deletion endpoint
+const DeviceRoute = "/api/admin/devices/{id}"
+
+// DeviceRestoreRoute is the normalized route name of the device restore endpoint
+const DeviceRestoreRoute = "/api/admin/devices/{id}/restore"
+
+// DeviceRouteName returns the normalized route name for a path under AdminDevicesPrefix
+func DeviceRouteName(path string) string {
+	rest := strings.TrimPrefix(path, AdminDevicesPrefix)
+	switch _, action, _ := strings.Cut(rest, "/"); action {
+	case "":
+		return DeviceRoute
+	case "restore":
+		return DeviceRestoreRoute
+	}
+	return DevicePermissionsRoute
+}
+
+// PermissionMethods are the HTTP methods evaluated for each route
+var PermissionMethods = []string{"GET", "POST", "PUT", "DELETE"}
+
+// RouteInfo describes a registered route
+type RouteInfo struct {
+	Pattern   string `json:"pattern"`
+	Anonymous bool   `json:"anonymous"`
+}
+
+// MethodPermission is the policy outcome for one route and method
+type MethodPermission struct {
+	Effect   policy.Effect `json:"effect"`
+	RuleID   string        `json:"rule_id,omitempty"`
+	RuleName string        `json:"rule_name,omitempty"`
+	Reason   string        `json:"reason"`
+	Code     string        `json:"code,omitempty"` // Deny code, see policy.Remediation
+}
+
+// RoutePermissions is the allow/deny matrix row for a single route
+type RoutePermissions struct {
+	Route     string                      `json:"route"`
+	Anonymous bool                        `json:"anonymous,omitempty"`
+	Methods   map[string]MethodPermission `json:"methods,omitempty"`
+}
+
+// permissionTokens maps the token names accepted by the permissions query to their offsets
+var permissionTokens = map[string]models.TokenOffset{
+	"status": models.TokenOffsetStatus,
+	"config": models.TokenOffsetConfig,
+	"data":   models.TokenOffsetData,
+}
+
+// DevicePermissionsHandler handles GET /api/admin/devices/{id}/permissions[?token=status|config|data].
+// It evaluates the active policy for the device across every registered route, with
+// the identity the clearance middleware establishes for the device: from X-Device-ID
+// alone, or from the named X-Token-ID when token is set.
+func DevicePermissionsHandler(logger *logging.Logger, engine *policy.Engine, registry *models.DeviceRegistry, routes func() []RouteInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		rest := strings.TrimPrefix(r.URL.Path, AdminDevicesPrefix)
+		idStr, action, ok := strings.Cut(rest, "/")
+		if !ok || action != "permissions" {
+			http.NotFound(w, r)
+			return
+		}
+
+		id, err := strconv.ParseUint(idStr, 10, 16)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid device ID")
+			return
+		}
+
+		if engine == nil || registry == nil {
+			respondError(w, http.StatusServiceUnavailable, "policy engine not configured")
+			return
+		}
+
+		device, err := registry.GetDevice(uint16(id))
+		if err != nil {
+			respondError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		identity := middleware.DeviceIdentity(device)
+		if name := r.URL.Query().Get("token"); name != "" {
+			offset, ok := permissionTokens[name]
+			if !ok {
+				respondError(w, http.StatusBadRequest, "token must be one of status, config, data")
+				return
+			}
+			identity.ResolveToken(device, offset)
+		}
+
+		permissions := make([]RoutePermissions, 0)
+		for _, route := range routes() {
+			row := RoutePermissions{
+				Route:     route.Pattern,
+				Anonymous: route.Anonymous,
+			}
+
+			if !route.Anonymous {
+				row.Methods = make(map[string]MethodPermission, len(PermissionMethods))
+				for _, method := range PermissionMethods {
+					decision := engine.Simulate(identity.PolicyContext(route.Pattern, method))
+					permission := MethodPermission{
+						Effect:   decision.Effect,
+						RuleID:   decision.RuleID,
+						RuleName: decision.RuleName,
+						Reason:   decision.Reason,
+					}
+					if decision.Remediation != nil {
+						permission.Code = decision.Remediation.Code
+					}
+					row.Methods[method] = permission
+				}
+			}
+
+			permissions = append(permissions, row)
+		}
+
+		logger.InfoContext(r.Context(), "device permissions inspected", map[string]interface{}{
+			"device_id": device.ID,
+			"routes":    len(permissions),
+		})
+
+		response := map[string]interface{}{
+			"device": map[string]interface{}{
+				"id":        device.ID,
+				"name":      device.Name,
+				"layer":     device.Layer,
+				"class":     device.Class,
+				"clearance": device.Clearance.String(),
+			},
+			"permissions": permissions,
+		}
+		if identity.TokenResolved {
+			response["token"] = fmt.Sprintf("0x%04X", identity.TokenID)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// DeviceAdminHandler handles requests under /api/admin/devices/: DELETE {id}
+// soft-deletes a device, POST {id}/restore restores it, and other paths are
+// served by permissions
+func DeviceAdminHandler(logger *logging.Logger, registry *models.DeviceRegistry, permissions http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, AdminDevicesPrefix)
+		idStr, action, _ := strings.Cut(rest, "/")
+
+		var method, event, result string
+		switch action {
+		case "":
+			method, event, result = http.MethodDelete, "device deleted", "deleted"
+		case "restore":
+			method, event, result = http.MethodPost, "device restored", "restored"
+		default:
+			permissions(w, r)
+			return
+		}
+
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		id, err := strconv.ParseUint(idStr, 10, 16)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid device ID")
+			return
+		}
+
+		if registry == nil {
+			respondError(w, http.StatusServiceUnavailable, "device registry not configured")
+			return
+		}
+
+		actor := "unknown"
+		if device, ok := middleware.GetDevice(r.Context()); ok {
+			actor = fmt.Sprintf("device-%d", device.ID)
+		}
+
+		apply := registry.DeleteBy
+		if action == "restore" {
+			apply = registry.RestoreBy
+		}
+		if err := apply(uint16(id), actor); err != nil {
+			respondError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		logger.InfoContext(r.Context(), event, map[string]interface{}{
+			"device_id": id,
+			"actor":     actor,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			result: id,
+		})
+	}
+}
+
+// DeletedDevicesHandler handles GET /api/admin/devices/deleted, listing soft-deleted
+// devices with the time after which they are purged
+func DeletedDevicesHandler(logger *logging.Logger, registry *models.DeviceRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowReadOnly(w, r) {
+			return
+		}
+		if registry == nil {
+			respondError(w, http.StatusServiceUnavailable, "device registry not configured")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"devices": registry.DeletedDevices(),
+		})
+	}
+}
+
+// RegisterDeviceRequest is the body of POST /api/admin/devices
+type RegisterDeviceRequest struct {
+	DeviceID  *uint16            `json:"device_id" validate:"min=1"` // Omit to assign the next free ID in the device's range
+	Name      string             `json:"name" validate:"required,max=64"`
+	Layer     models.Layer       `json:"layer" validate:"required,oneof=data transport control application"`
+	Class     models.DeviceClass `json:"class" validate:"required,oneof=sensor actuator gateway controller"`
+	Clearance string             `json:"clearance" validate:"required"` // Hex, as sent in X-Clearance
+	Labels    map[string]string  `json:"labels,omitempty" validate:"max=32"`
+}
+
+// Validate checks the clearance and labels
+func (req *RegisterDeviceRequest) Validate() []Violation {
+	var violations []Violation
+	if req.Clearance != "" {
+		c, err := strconv.ParseUint(req.Clearance, 16, 32)
+		if err != nil || !models.ValidateClearance(models.Clearance(c)) {
+			violations = append(violations, Violation{Field: "clearance", Message: "must be a hex clearance from 02020202 to 09090909"})
+		}
+	}
+	for key, value := range req.Labels {
+		if key == "" || len(key) > 63 {
+			violations = append(violations, Violation{Field: "labels", Message: fmt.Sprintf("label name %q must have 1 to 63 characters", key)})
+		}
+		if len(value) > 255 {
+			violations = append(violations, Violation{Field: "labels." + key, Message: "must have at most 255 characters"})
+		}
+	}
+	return violations
+}
+
+// DeviceRegisterHandler handles POST /api/admin/devices. Devices without a
+// device_id get the next free ID in the range reserved for their layer and class.
+func DeviceRegisterHandler(logger *logging.Logger, registry *models.DeviceRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if registry == nil {
+			respondError(w, http.StatusServiceUnavailable, "device registry not configured")
+			return
+		}
+
+		var req RegisterDeviceRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+
+		clearance, _ := strconv.ParseUint(req.Clearance, 16, 32) // Checked by Validate
+		device := &models.Device{
+			Layer:     req.Layer,
+			Class:     req.Class,
+			Clearance: models.Clearance(clearance),
+			Name:      req.Name,
+			Labels:    req.Labels,
+		}
+
+		actor := "unknown"
+		if admin, ok := middleware.GetDevice(r.Context()); ok {
+			actor = fmt.Sprintf("device-%d", admin.ID)
+		}
+
+		var err error
+		if req.DeviceID != nil {
+			device.ID = *req.DeviceID
+			err = registry.RegisterBy(device, actor)
+		} else {
+			_, err = registry.RegisterNextBy(device, actor)
+		}
+		if err != nil {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		logger.InfoContext(r.Context(), "device registered", map[string]interface{}{
+			"device_id": device.ID,
+			"actor":     actor,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", fmt.Sprintf("%s%d", AdminDevicesPrefix, device.ID))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(device)
+	}
+}
+
+// DeviceListHandler handles GET /api/admin/devices, and POST through register when
+// set. The encoded listing is cached per registry generation and served with
+// validators for conditional requests.
+func DeviceListHandler(logger *logging.Logger, registry *models.DeviceRegistry, register http.HandlerFunc) http.HandlerFunc {
+	var cache generationCache
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && register != nil {
+			register(w, r)
+			return
+		}
+		if !allowReadOnly(w, r) {
+			return
+		}
+		if registry == nil {
+			respondError(w, http.StatusServiceUnavailable, "device registry not configured")
+			return
+		}
+
+		devices, generation := registry.Snapshot()
+		entry, err := cache.get(generation, registry.LastModified(), func() (interface{}, error) {
+			return map[string]interface{}{"devices": devices}, nil
+		})
+		if err != nil {
+			logger.ErrorContext(r.Context(), "failed to encode device list", map[string]interface{}{
+				"error": err.Error(),
+			})
+			respondError(w, http.StatusInternalServerError, "failed to encode device list")
+			return
+		}
+
+		serveCached(w, r, entry)
+	}
+}
+
+// PolicyExportHandler handles GET /api/admin/policy, returning the active policy in
+// the form accepted by policy.Engine.LoadFromJSON
+func PolicyExportHandler(logger *logging.Logger, engine *policy.Engine) http.HandlerFunc {
+	var cache generationCache
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowReadOnly(w, r) {
+			return
+		}
+		if engine == nil {
+			respondError(w, http.StatusServiceUnavailable, "policy engine not configured")
+			return
+		}
+
+		// Read the generation before the policy so a concurrent change can only
+		// pair newer content with an older key, which the next request rebuilds
+		generation := engine.Generation()
+		entry, err := cache.get(generation, engine.LastModified(), func() (interface{}, error) {
+			return engine.GetPolicy(), nil
+		})
+		if err != nil {
+			logger.ErrorContext(r.Context(), "failed to encode policy", map[string]interface{}{
+				"error": err.Error(),
+			})
+			respondError(w, http.StatusInternalServerError, "failed to encode policy")
+			return
+		}
+
+		serveCached(w, r, entry)
+	}
+}
+
+// RuleReview is a policy rule awaiting recertification
+type RuleReview struct {
+	RuleID    string     `json:"rule_id"`
+	RuleName  string     `json:"rule_name,omitempty"`
+	ReviewBy  time.Time  `json:"review_by"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Overdue   bool       `json:"overdue"`
+	Expired   bool       `json:"expired"`
+}
+
+// PolicyReviewHandler handles GET /api/admin/policy/review?days=30, listing rules whose
+// review date falls within the window, overdue ones first
+func PolicyReviewHandler(logger *logging.Logger, engine *policy.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowReadOnly(w, r) {
+			return
+		}
+		if engine == nil {
+			respondError(w, http.StatusServiceUnavailable, "policy engine not configured")
+			return
+		}
+
+		window := DefaultReviewWindow
+		if days := r.URL.Query().Get("days"); days != "" {
+			n, err := strconv.Atoi(days)
+			if err != nil || n < 0 {
+				respondError(w, http.StatusBadRequest, "days must be a non-negative integer")
+				return
+			}
+			window = time.Duration(n) * 24 * time.Hour
+		}
+
+		now := time.Now().UTC()
+		reviews := make([]RuleReview, 0)
+		for _, rule := range engine.RulesDueForReview(window) {
+			reviews = append(reviews, RuleReview{
+				RuleID:    rule.ID,
+				RuleName:  rule.Name,
+				ReviewBy:  *rule.ReviewBy,
+				ExpiresAt: rule.ExpiresAt,
+				Overdue:   rule.ReviewDue(now),
+				Expired:   rule.Expired(now),
+			})
+		}
+
+		logger.InfoContext(r.Context(), "policy reviews listed", map[string]interface{}{
+			"window": window.String(),
+			"rules":  len(reviews),
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"window_days": int(window / (24 * time.Hour)),
+			"rules":       reviews,
+		})
+	}
+}
+
+// LockoutsHandler handles GET /api/admin/lockouts, listing tracked sources and devices,
+// and DELETE /api/admin/lockouts?key=ip:10.0.0.1 (or key=device:7), lifting a lockout
+func LockoutsHandler(logger *logging.Logger, lockout *middleware.Lockout, auditLogger *audit.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if lockout == nil {
+			respondError(w, http.StatusServiceUnavailable, "lockout not configured")
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"lockouts": lockout.Status(),
+			})
+
+		case http.MethodDelete:
+			key := r.URL.Query().Get("key")
+			if key == "" {
+				respondError(w, http.StatusBadRequest, "key is required")
+				return
+			}
+			if !lockout.Unblock(key) {
+				respondError(w, http.StatusNotFound, "no lockout for "+key)
+				return
+			}
+
+			actor := "unknown"
+			if device, ok := middleware.GetDevice(r.Context()); ok {
+				actor = fmt.Sprintf("device-%d", device.ID)
+			}
+			logger.InfoContext(r.Context(), "lockout lifted", map[string]interface{}{
+				"key":   key,
+				"actor": actor,
+			})
+			if auditLogger != nil {
+				event := audit.NewEvent(audit.DecisionAllow, middleware.UnblockAuditAction, key, "lockout lifted by "+actor)
+				event.Actor = actor
+				event.Method = r.Method
+				event.RequestID = logging.GetRequestID(r.Context())
+				event.SourceIP = r.RemoteAddr
+				auditLogger.Log(event)
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"unblocked": key,
+			})
+
+		default:
+			respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	}
+}
+
+// respondError writes a JSON error response
+func respondError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": message,
+	})
+}
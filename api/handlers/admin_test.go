@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/NSACodeGov/CodeGov/api/middleware"
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/internal/policy"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// eventRecorder keeps every audit event written
+type eventRecorder struct {
+	events []*audit.AuditEvent
+}
+
+func (w *eventRecorder) Write(event *audit.AuditEvent) error {
+	w.events = append(w.events, event)
+	return nil
+}
+
+func (w *eventRecorder) Close() error { return nil }
+
+// testLogger returns a logger that discards its output
+func testLogger() *logging.Logger {
+	logger := logging.New("test", "test", "error", "json")
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestLockoutsHandler(t *testing.T) {
+	lockout := middleware.NewLockout(middleware.LockoutPolicy{MaxFailures: 1})
+	lockout.Failure("ip:192.0.2.1")
+
+	recorder := &eventRecorder{}
+	auditLogger := audit.NewLogger()
+	auditLogger.AddWriter(recorder)
+	handler := LockoutsHandler(testLogger(), lockout, auditLogger)
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		admin := &models.Device{ID: 4, Clearance: models.ClearanceLevel9}
+		req = req.WithContext(context.WithValue(req.Context(), middleware.DeviceKey, admin))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "/api/admin/lockouts")
+	var listed struct {
+		Lockouts []middleware.LockoutStatus `json:"lockouts"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&listed); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || len(listed.Lockouts) != 1 || listed.Lockouts[0].Key != "ip:192.0.2.1" || !listed.Lockouts[0].Banned {
+		t.Fatalf("unexpected listing %d %+v", rec.Code, listed)
+	}
+
+	if rec := serve(http.MethodDelete, "/api/admin/lockouts"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a key, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodDelete, "/api/admin/lockouts?key=device:9"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an untracked key, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodDelete, "/api/admin/lockouts?key=ip:192.0.2.1"); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if _, blocked := lockout.Check("ip:192.0.2.1"); blocked {
+		t.Error("key still blocked after DELETE")
+	}
+	if len(recorder.events) != 1 || recorder.events[0].Action != middleware.UnblockAuditAction || recorder.events[0].Actor != "device-4" {
+		t.Errorf("expected one unblock event by device-4, got %+v", recorder.events)
+	}
+
+	if rec := serve(http.MethodPost, "/api/admin/lockouts"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	LockoutsHandler(testLogger(), nil, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/admin/lockouts", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without a lockout, got %d", rec.Code)
+	}
+}
+
+func TestDeviceAdminAttributesChanges(t *testing.T) {
+	registry := models.NewDeviceRegistry()
+	var changes []models.RegistryChange
+	registry.SetObserver(func(change models.RegistryChange) {
+		changes = append(changes, change)
+	})
+
+	serve := func(handler http.HandlerFunc, method, target, body string) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		admin := &models.Device{ID: 4, Clearance: models.ClearanceLevel9}
+		req = req.WithContext(context.WithValue(req.Context(), middleware.DeviceKey, admin))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	register := DeviceRegisterHandler(testLogger(), registry)
+	if code := serve(register, http.MethodPost, "/api/admin/devices",
+		`{"device_id": 12, "name": "probe", "layer": "data", "class": "sensor", "clearance": "02020202"}`); code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+	admin := DeviceAdminHandler(testLogger(), registry, nil)
+	if code := serve(admin, http.MethodDelete, "/api/admin/devices/12", ""); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if code := serve(admin, http.MethodPost, "/api/admin/devices/12/restore", ""); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	wantActions := []string{models.RegistryActionRegister, models.RegistryActionDelete, models.RegistryActionRestore}
+	if len(changes) != len(wantActions) {
+		t.Fatalf("expected %d changes, got %+v", len(wantActions), changes)
+	}
+	for i, action := range wantActions {
+		if changes[i].Action != action || changes[i].Actor != "device-4" {
+			t.Errorf("change %d: expected %s by device-4, got %s by %q", i, action, changes[i].Action, changes[i].Actor)
+		}
+	}
+}
+
+func TestDevicePermissionsMatchMiddleware(t *testing.T) {
+	registry := models.NewDeviceRegistry()
+	sensor := &models.Device{ID: 3, Layer: models.LayerData, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel3}
+	console := &models.Device{ID: 4, Layer: models.LayerApplication, Class: models.DeviceClassController, Clearance: models.ClearanceLevel7}
+	for _, device := range []*models.Device{sensor, console} {
+		if err := registry.Register(device); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	engine := policy.NewEngine(registry)
+	data, err := json.Marshal(&policy.Policy{Version: "1.0", Rules: []*policy.Rule{
+		{ID: "status", Name: "status", Effect: policy.EffectAllow, Routes: []string{"/api/device/status"}, Methods: []string{"POST"}, AllowedLayers: []models.Layer{models.LayerData}},
+		{ID: "data", Name: "data", Effect: policy.EffectAllow, Routes: []string{DeviceDataPath}, Methods: []string{"POST"}},
+		{ID: "no-data", Name: "no data", Effect: policy.EffectDeny, Routes: []string{DeviceDataPath}, Methods: []string{"POST"}, AllowedDevices: []uint16{4}, Priority: 10},
+		{ID: "records", Name: "records", Effect: policy.EffectAllow, Routes: []string{DataRecordsPath}, Methods: []string{"GET"}, RequiredClearance: models.ClearanceLevel5},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.LoadFromJSON(data); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := []RouteInfo{{Pattern: "/health", Anonymous: true}, {Pattern: "/api/device/status"}, {Pattern: DeviceDataPath}, {Pattern: DataRecordsPath}}
+	handler := DevicePermissionsHandler(testLogger(), engine, registry, func() []RouteInfo { return routes })
+	clearance := middleware.Clearance(&middleware.ClearanceConfig{
+		Logger:         testLogger(),
+		DeviceRegistry: registry,
+		PolicyEngine:   engine,
+		Enabled:        true,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	permissions := func(query string) (string, []RoutePermissions) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, AdminDevicesPrefix+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", query, rec.Code, rec.Body)
+		}
+		var body struct {
+			Token       string             `json:"token"`
+			Permissions []RoutePermissions `json:"permissions"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Token, body.Permissions
+	}
+
+	tests := []struct {
+		name      string
+		device    *models.Device
+		token     string
+		offset    models.TokenOffset
+		wantAllow map[string]bool // "METHOD route" pairs the policy allows
+	}{
+		{"sensor by device ID", sensor, "", 0, map[string]bool{"POST /api/device/status": true, "POST " + DeviceDataPath: true}},
+		{"sensor by data token", sensor, "data", models.TokenOffsetData, map[string]bool{"POST /api/device/status": true, "POST " + DeviceDataPath: true}},
+		{"console by device ID", console, "", 0, map[string]bool{"GET " + DataRecordsPath: true}},
+		{"console by status token", console, "status", models.TokenOffsetStatus, map[string]bool{"GET " + DataRecordsPath: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := strconv.Itoa(int(tt.device.ID)) + "/permissions"
+			if tt.token != "" {
+				query += "?token=" + tt.token
+			}
+			token, rows := permissions(query)
+			wantToken := ""
+			if tt.token != "" {
+				wantToken = fmt.Sprintf("0x%04X", tt.device.ComputeToken(tt.offset))
+			}
+			if token != wantToken {
+				t.Errorf("token = %q, want %q", token, wantToken)
+			}
+			if len(rows) != len(routes) {
+				t.Fatalf("expected %d routes, got %d", len(routes), len(rows))
+			}
+
+			for _, row := range rows {
+				if row.Anonymous {
+					if row.Methods != nil {
+						t.Errorf("%s: anonymous route has methods %v", row.Route, row.Methods)
+					}
+					continue
+				}
+				for _, method := range PermissionMethods {
+					permission := row.Methods[method]
+					allowed := permission.Effect == policy.EffectAllow
+					if allowed != tt.wantAllow[method+" "+row.Route] {
+						t.Errorf("%s %s: effect %s (%s)", method, row.Route, permission.Effect, permission.Reason)
+					}
+
+					// The middleware makes the same decision for the device's real request
+					req := httptest.NewRequest(method, row.Route, nil)
+					if tt.token != "" {
+						req.Header.Set("X-Token-ID", strconv.Itoa(int(tt.device.ComputeToken(tt.offset))))
+					} else {
+						req.Header.Set("X-Device-ID", strconv.Itoa(int(tt.device.ID)))
+					}
+					rec := httptest.NewRecorder()
+					clearance.ServeHTTP(rec, req)
+					if got := rec.Code == http.StatusOK; got != allowed {
+						t.Errorf("%s %s: simulated %s but the middleware answered %d", method, row.Route, permission.Effect, rec.Code)
+					}
+				}
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, AdminDevicesPrefix+"3/permissions?token=admin", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown token name, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, AdminDevicesPrefix+"99/permissions", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown device, got %d", rec.Code)
+	}
+}
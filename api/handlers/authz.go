@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/NSACodeGov/CodeGov/api/middleware"
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/internal/policy"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// DeviceAuthzPath answers whether one device may send DATA token traffic to another
+const DeviceAuthzPath = "/api/authz/device"
+
+// DeviceAuthzAuditAction is the audit action recorded for every flow decision
+const DeviceAuthzAuditAction = "device.authz"
+
+// Flow checks, in the order they are evaluated
+const (
+	FlowCheckLayer     = "layer"     // data only flows upward
+	FlowCheckClearance = "clearance" // the target's clearance dominates the source's
+	FlowCheckSend      = "send"      // policy lets the source submit DATA token traffic
+	FlowCheckReceive   = "receive"   // policy lets the target read telemetry
+)
+
+// FlowCheck is the outcome of one check of a device-to-device flow
+type FlowCheck struct {
+	Check  string `json:"check"`
+	Passed bool   `json:"passed"`
+	Reason string `json:"reason"`
+	RuleID string `json:"rule_id,omitempty"` // Policy checks only
+	Code   string `json:"code,omitempty"`    // Deny code of a failed policy check, see policy.Remediation
+}
+
+// FlowDecision says whether a source device may send DATA token traffic to a target
+type FlowDecision struct {
+	Allowed bool        `json:"allowed"`
+	Source  uint16      `json:"source"`
+	Target  uint16      `json:"target"`
+	Token   string      `json:"token"`            // The source's DATA token
+	Reason  string      `json:"reason,omitempty"` // First failed check; empty when allowed
+	Checks  []FlowCheck `json:"checks"`
+}
+
+// AuthorizeFlow decides whether source may send DATA token traffic to target. The
+// flow must go upward or stay on one layer, the target's clearance must be at or
+// above the source's, and the active policy must allow the source to submit
+// telemetry (POST DeviceDataPath) and the target to read it (GET DataRecordsPath).
+// Every check is evaluated so callers see all the reasons a flow is refused.
+func AuthorizeFlow(engine *policy.Engine, source, target *models.Device) FlowDecision {
+	decision := FlowDecision{
+		Source: source.ID,
+		Target: target.ID,
+		Token:  fmt.Sprintf("0x%04X", source.GetDataToken()),
+	}
+
+	layer := FlowCheck{Check: FlowCheckLayer, Passed: models.CanAccessLayer(source.Layer, target.Layer)}
+	if layer.Passed {
+		layer.Reason = fmt.Sprintf("data may flow from the %s layer to the %s layer", source.Layer, target.Layer)
+	} else {
+		layer.Reason = fmt.Sprintf("data cannot flow from the %s layer to the %s layer", source.Layer, target.Layer)
+	}
+
+	clearance := FlowCheck{Check: FlowCheckClearance, Passed: target.Clearance.IsHigherOrEqual(source.Clearance)}
+	if clearance.Passed {
+		clearance.Reason = fmt.Sprintf("target clearance %s dominates source clearance %s", target.Clearance, source.Clearance)
+	} else {
+		clearance.Reason = fmt.Sprintf("target clearance %s is below source clearance %s", target.Clearance, source.Clearance)
+	}
+
+	send := policyCheck(FlowCheckSend, engine.Simulate(&policy.Context{
+		Route:       DeviceDataPath,
+		Method:      http.MethodPost,
+		DeviceID:    source.ID,
+		Layer:       source.Layer,
+		Clearance:   source.Clearance,
+		TokenID:     source.GetDataToken(),
+		TokenOffset: models.TokenOffsetData,
+	}))
+	receive := policyCheck(FlowCheckReceive, engine.Simulate(&policy.Context{
+		Route:     DataRecordsPath,
+		Method:    http.MethodGet,
+		DeviceID:  target.ID,
+		Layer:     target.Layer,
+		Clearance: target.Clearance,
+	}))
+
+	decision.Checks = []FlowCheck{layer, clearance, send, receive}
+	decision.Allowed = true
+	for _, check := range decision.Checks {
+		if !check.Passed {
+			decision.Allowed = false
+			decision.Reason = check.Reason
+			break
+		}
+	}
+	return decision
+}
+
+// policyCheck converts a simulated policy decision into a flow check
+func policyCheck(name string, decision *policy.Decision) FlowCheck {
+	check := FlowCheck{
+		Check:  name,
+		Passed: decision.Effect == policy.EffectAllow,
+		Reason: decision.Reason,
+		RuleID: decision.RuleID,
+	}
+	if decision.Remediation != nil {
+		check.Code = decision.Remediation.Code
+	}
+	return check
+}
+
+// DeviceAuthzHandler handles GET /api/authz/device?source=<id>&target=<id> for
+// gateways that relay traffic between devices. It returns the FlowDecision with 200
+// whether or not the flow is allowed; unknown devices get 404. Every decision is
+// audited when auditLogger is set, allowed or denied by the flow's verdict.
+func DeviceAuthzHandler(logger *logging.Logger, engine *policy.Engine, registry *models.DeviceRegistry, auditLogger *audit.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		if engine == nil || registry == nil {
+			respondError(w, http.StatusServiceUnavailable, "policy engine not configured")
+			return
+		}
+
+		query := r.URL.Query()
+		devices := make([]*models.Device, 0, 2)
+		for _, param := range []string{"source", "target"} {
+			id, err := strconv.ParseUint(query.Get(param), 10, 16)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, param+" must be a decimal device ID")
+				return
+			}
+			device, err := registry.GetDevice(uint16(id))
+			if err != nil {
+				respondError(w, http.StatusNotFound, fmt.Sprintf("%s device %d not found", param, id))
+				return
+			}
+			devices = append(devices, device)
+		}
+
+		decision := AuthorizeFlow(engine, devices[0], devices[1])
+		if auditLogger != nil {
+			auditLogger.Log(flowAuditEvent(r, decision))
+		}
+
+		logger.DebugContext(r.Context(), "device flow checked", map[string]interface{}{
+			"source":  decision.Source,
+			"target":  decision.Target,
+			"allowed": decision.Allowed,
+			"reason":  decision.Reason,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(decision)
+	}
+}
+
+// flowAuditEvent records a flow decision, attributed to the device asking when known
+func flowAuditEvent(r *http.Request, decision FlowDecision) *audit.AuditEvent {
+	verdict, reason := audit.DecisionAllow, "flow allowed"
+	var failed []string
+	for _, check := range decision.Checks {
+		if !check.Passed {
+			failed = append(failed, check.Check)
+		}
+	}
+	if !decision.Allowed {
+		verdict, reason = audit.DecisionDeny, decision.Reason
+	}
+
+	event := audit.NewEvent(verdict, DeviceAuthzAuditAction, decision.Token, reason)
+	if caller, ok := middleware.GetDevice(r.Context()); ok {
+		event.Actor = fmt.Sprintf("device-%d", caller.ID)
+		event.DeviceID = caller.ID
+		event.Layer = caller.Layer
+	}
+	event.Method = r.Method
+	event.RequestID = logging.GetRequestID(r.Context())
+	event.SourceIP = r.RemoteAddr
+	event.StatusCode = http.StatusOK
+	event.AdditionalData = map[string]interface{}{
+		"source_device": decision.Source,
+		"target_device": decision.Target,
+		"failed_checks": failed,
+	}
+	return event
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/NSACodeGov/CodeGov/api/middleware"
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/policy"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+func TestDeviceAuthzHandler(t *testing.T) {
+	registry := models.NewDeviceRegistry()
+	devices := []*models.Device{
+		{ID: 1, Layer: models.LayerData, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel3},
+		{ID: 2, Layer: models.LayerTransport, Class: models.DeviceClassGateway, Clearance: models.ClearanceLevel5},
+		{ID: 3, Layer: models.LayerControl, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel5},
+		{ID: 4, Layer: models.LayerApplication, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel7},
+		{ID: 5, Layer: models.LayerApplication, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel2},
+		{ID: 6, Layer: models.LayerData, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel3},
+		{ID: 7, Layer: models.LayerApplication, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel7},
+	}
+	for _, device := range devices {
+		if err := registry.Register(device); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Any device may send telemetry except device 6, and any device may read it except device 7
+	engine := policy.NewEngine(registry)
+	data, err := json.Marshal(&policy.Policy{Version: "1.0", Rules: []*policy.Rule{
+		{ID: "send", Name: "send", Effect: policy.EffectAllow, Routes: []string{DeviceDataPath}, Methods: []string{http.MethodPost}},
+		{ID: "no-send", Name: "no send", Effect: policy.EffectDeny, Routes: []string{DeviceDataPath}, Methods: []string{http.MethodPost}, AllowedDevices: []uint16{6}, Priority: 10},
+		{ID: "receive", Name: "receive", Effect: policy.EffectAllow, Routes: []string{DataRecordsPath}, Methods: []string{http.MethodGet}},
+		{ID: "no-receive", Name: "no receive", Effect: policy.EffectDeny, Routes: []string{DataRecordsPath}, Methods: []string{http.MethodGet}, AllowedDevices: []uint16{7}, Priority: 10},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.LoadFromJSON(data); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := &eventRecorder{}
+	auditLogger := audit.NewLogger()
+	auditLogger.AddWriter(recorder)
+	handler := DeviceAuthzHandler(testLogger(), engine, registry, auditLogger)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantAllow  bool
+		wantFailed []string // Failed checks, in evaluation order
+		wantRules  [2]string
+	}{
+		{"upward flow", "source=1&target=4", http.StatusOK, true, nil, [2]string{"send", "receive"}},
+		{"same layer", "source=4&target=7", http.StatusOK, false, []string{FlowCheckReceive}, [2]string{"send", "no-receive"}},
+		{"downward flow", "source=3&target=2", http.StatusOK, false, []string{FlowCheckLayer}, [2]string{"send", "receive"}},
+		{"target clearance below source", "source=1&target=5", http.StatusOK, false, []string{FlowCheckClearance}, [2]string{"send", "receive"}},
+		{"equal clearance", "source=2&target=3", http.StatusOK, true, nil, [2]string{"send", "receive"}},
+		{"policy denies the send", "source=6&target=4", http.StatusOK, false, []string{FlowCheckSend}, [2]string{"no-send", "receive"}},
+		{"every check fails", "source=6&target=5", http.StatusOK, false, []string{FlowCheckClearance, FlowCheckSend}, [2]string{"no-send", "receive"}},
+		{"unknown device", "source=1&target=99", http.StatusNotFound, false, nil, [2]string{}},
+		{"malformed device", "source=one&target=4", http.StatusBadRequest, false, nil, [2]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder.events = nil
+			req := httptest.NewRequest(http.MethodGet, DeviceAuthzPath+"?"+tt.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.DeviceKey, devices[1]))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if len(recorder.events) != 0 {
+					t.Errorf("expected no audit event, got %+v", recorder.events)
+				}
+				return
+			}
+
+			var decision FlowDecision
+			if err := json.NewDecoder(rec.Body).Decode(&decision); err != nil {
+				t.Fatal(err)
+			}
+			var failed []string
+			for _, check := range decision.Checks {
+				if !check.Passed {
+					failed = append(failed, check.Check)
+				}
+			}
+			if decision.Allowed != tt.wantAllow || !reflect.DeepEqual(failed, tt.wantFailed) {
+				t.Errorf("allowed = %v with failed checks %v, want %v with %v", decision.Allowed, failed, tt.wantAllow, tt.wantFailed)
+			}
+			if rules := [2]string{decision.Checks[2].RuleID, decision.Checks[3].RuleID}; rules != tt.wantRules {
+				t.Errorf("policy rules = %v, want %v", rules, tt.wantRules)
+			}
+			if !tt.wantAllow && decision.Reason != decision.Checks[indexOfCheck(decision.Checks, tt.wantFailed[0])].Reason {
+				t.Errorf("reason %q is not the first failed check's", decision.Reason)
+			}
+
+			if len(recorder.events) != 1 {
+				t.Fatalf("expected one audit event, got %d", len(recorder.events))
+			}
+			event := recorder.events[0]
+			wantDecision, wantReason := audit.DecisionAllow, "flow allowed"
+			if !tt.wantAllow {
+				wantDecision, wantReason = audit.DecisionDeny, decision.Reason
+			}
+			if event.Decision != wantDecision || event.Reason != wantReason || event.Action != DeviceAuthzAuditAction ||
+				event.Resource != decision.Token || event.Actor != "device-2" {
+				t.Errorf("unexpected audit event %+v", event)
+			}
+			if got, _ := event.AdditionalData["failed_checks"].([]string); !reflect.DeepEqual(got, tt.wantFailed) {
+				t.Errorf("audited failed checks %v, want %v", got, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func indexOfCheck(checks []FlowCheck, name string) int {
+	for i, check := range checks {
+		if check.Check == name {
+			return i
+		}
+	}
+	return -1
+}
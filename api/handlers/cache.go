@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cachedBody is an encoded response and the validators that identify it
+type cachedBody struct {
+	body     []byte
+	etag     string
+	modified time.Time
+}
+
+// newCachedBody computes a strong ETag from the response bytes, so identical
+// content yields the same validator across restarts and replicas
+func newCachedBody(body []byte, modified time.Time) *cachedBody {
+	sum := sha256.Sum256(body)
+	return &cachedBody{
+		body:     body,
+		etag:     `"` + hex.EncodeToString(sum[:16]) + `"`,
+		modified: modified.UTC().Truncate(time.Second),
+	}
+}
+
+// generationCache holds the encoded response for one generation of the underlying
+// data. Bumping the generation invalidates the entry on the next request.
+type generationCache struct {
+	mu         sync.Mutex
+	generation uint64
+	entry      *cachedBody
+}
+
+// get returns the cached response for generation, rebuilding it when the data has changed
+func (c *generationCache) get(generation uint64, modified time.Time, build func() (interface{}, error)) (*cachedBody, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entry != nil && c.generation == generation {
+		return c.entry, nil
+	}
+
+	v, err := build()
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	c.generation = generation
+	c.entry = newCachedBody(append(body, '\n'), modified)
+	return c.entry, nil
+}
+
+// fileCache holds the contents of a file, re-read when its size or modification time changes
+type fileCache struct {
+	mu      sync.Mutex
+	path    string
+	size    int64
+	modTime time.Time // Full-precision mtime; Last-Modified only keeps seconds
+	entry   *cachedBody
+}
+
+// get returns the cached file contents, reloading them if the file has changed on disk
+func (c *fileCache) get() (*cachedBody, error) {
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entry != nil && c.size == info.Size() && c.modTime.Equal(info.ModTime()) {
+		return c.entry, nil
+	}
+
+	body, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.size = info.Size()
+	c.modTime = info.ModTime()
+	c.entry = newCachedBody(body, info.ModTime())
+	return c.entry, nil
+}
+
+// serveCached writes a JSON response with ETag and Last-Modified validators,
+// answering 304 Not Modified when the client's cached copy is still current
+func serveCached(w http.ResponseWriter, r *http.Request, entry *cachedBody) {
+	w.Header().Set("ETag", entry.etag)
+	if !entry.modified.IsZero() {
+		w.Header().Set("Last-Modified", entry.modified.Format(http.TimeFormat))
+	}
+	// Clients may store the response but must revalidate before each use
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if notModified(r, entry) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(entry.body)
+	}
+}
+
+// notModified evaluates If-None-Match, falling back to If-Modified-Since only when
+// the client sent no entity tags (RFC 9110 section 13.2.2)
+func notModified(r *http.Request, entry *cachedBody) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == entry.etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !entry.modified.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !entry.modified.After(t)
+	}
+
+	return false
+}
+
+// allowReadOnly rejects methods other than GET and HEAD, reporting whether the request may proceed
+func allowReadOnly(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	return true
+}
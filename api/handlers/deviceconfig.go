@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/NSACodeGov/CodeGov/api/middleware"
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/devconfig"
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// DeviceConfigPath serves signed configuration blobs to devices
+const DeviceConfigPath = "/api/device/config"
+
+// DeviceConfigAuditAction is the audit action recorded for every configuration request
+const DeviceConfigAuditAction = "device.config"
+
+// Headers describing a configuration blob. Requests send X-Config-Version with the
+// version the device runs; responses carry the served version, its digest and the
+// base64 ed25519 signature to check with devconfig.Verify.
+const (
+	HeaderConfigVersion   = "X-Config-Version"
+	HeaderConfigSHA256    = "X-Config-SHA256"
+	HeaderConfigSignature = "X-Config-Signature"
+)
+
+// DeviceConfigHandler serves the requesting device's configuration from store. The
+// device must authenticate with its CONFIG token (X-Token-ID), so configuration is
+// keyed by that token, and policy decides which devices and clearances may fetch it.
+//
+// Without query parameters the newest version is served; ?version=N serves exactly
+// version N and ?max_version=N the newest version not above N, for firmware that
+// cannot read newer formats. A device already running the selected version (by
+// X-Config-Version or If-None-Match) gets 304. Every request, served or refused,
+// is audited when auditLogger is set.
+func DeviceConfigHandler(logger *logging.Logger, store *devconfig.Store, auditLogger *audit.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device, hasDevice := middleware.GetDevice(r.Context())
+		clearance, _ := middleware.GetClearance(r.Context())
+		token, offset, hasToken := middleware.GetToken(r.Context())
+
+		// record audits the outcome; version and blob are set once known
+		var version uint64
+		var blob *devconfig.Blob
+		record := func(decision audit.Decision, status int, reason string) {
+			if auditLogger == nil {
+				return
+			}
+			resource := DeviceConfigPath
+			if hasToken {
+				resource = devconfig.TokenDir(token)
+			}
+			event := audit.NewEvent(decision, DeviceConfigAuditAction, resource, reason)
+			if hasDevice {
+				event.Actor = fmt.Sprintf("device-%d", device.ID)
+				event.DeviceID = device.ID
+				event.Layer = device.Layer
+			}
+			event.Clearance = clearance
+			event.Method = r.Method
+			event.RequestID = logging.GetRequestID(r.Context())
+			event.SourceIP = r.RemoteAddr
+			event.StatusCode = status
+			if version > 0 {
+				event.AdditionalData = map[string]interface{}{"version": version}
+			}
+			if blob != nil {
+				event.AdditionalData["sha256"] = blob.SHA256
+				event.AdditionalData["size"] = len(blob.Data)
+			}
+			auditLogger.Log(event)
+		}
+		deny := func(status int, reason string) {
+			logger.WarnContext(r.Context(), "device configuration refused", map[string]interface{}{
+				"reason": reason,
+				"status": status,
+			})
+			record(audit.DecisionDeny, status, reason)
+			respondError(w, status, reason)
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			deny(http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if !hasDevice {
+			deny(http.StatusForbidden, "device registration required")
+			return
+		}
+		if !hasToken || offset != models.TokenOffsetConfig || token != device.GetConfigToken() {
+			deny(http.StatusForbidden, "authenticate with the device's CONFIG token in X-Token-ID")
+			return
+		}
+		// Token authentication assigns the registered clearance; anything lower means the
+		// clearance was downgraded after registration and the configuration is withheld
+		if !models.ValidateClearance(clearance) || !clearance.IsHigherOrEqual(device.Clearance) {
+			deny(http.StatusForbidden, "insufficient clearance")
+			return
+		}
+
+		var req devconfig.Request
+		var current uint64
+		for _, param := range []struct {
+			value string
+			dest  *uint64
+			name  string
+		}{
+			{r.URL.Query().Get("version"), &req.Version, "version"},
+			{r.URL.Query().Get("max_version"), &req.MaxVersion, "max_version"},
+			{r.Header.Get(HeaderConfigVersion), &current, HeaderConfigVersion},
+		} {
+			if param.value == "" {
+				continue
+			}
+			n, err := strconv.ParseUint(param.value, 10, 64)
+			if err != nil {
+				deny(http.StatusBadRequest, fmt.Sprintf("invalid %s %q", param.name, param.value))
+				return
+			}
+			*param.dest = n
+		}
+
+		var err error
+		version, err = store.Resolve(token, req)
+		if err == nil {
+			blob, err = store.Get(token, version)
+		}
+		switch {
+		case errors.Is(err, devconfig.ErrNotFound):
+			deny(http.StatusNotFound, "no configuration for this device")
+			return
+		case errors.Is(err, devconfig.ErrNoVersion):
+			deny(http.StatusNotFound, "no configuration version matches the request")
+			return
+		case err != nil:
+			logger.ErrorContext(r.Context(), "failed to read device configuration", map[string]interface{}{
+				"token":   devconfig.TokenDir(token),
+				"version": version,
+				"error":   err.Error(),
+			})
+			record(audit.DecisionDeny, http.StatusInternalServerError, "configuration unavailable")
+			respondError(w, http.StatusInternalServerError, "configuration unavailable")
+			return
+		}
+
+		entry := newCachedBody(blob.Data, blob.ModTime)
+		w.Header().Set("ETag", entry.etag)
+		w.Header().Set("Last-Modified", entry.modified.Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set(HeaderConfigVersion, strconv.FormatUint(blob.Version, 10))
+
+		// An explicit version is always served, so a device can re-fetch to roll back
+		upToDate := req.Version == 0 && current >= blob.Version
+		if upToDate || notModified(r, entry) {
+			record(audit.DecisionAllow, http.StatusNotModified, "configuration up to date")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(blob.Data)))
+		w.Header().Set(HeaderConfigSHA256, blob.SHA256)
+		w.Header().Set(HeaderConfigSignature, base64.StdEncoding.EncodeToString(blob.Signature))
+		record(audit.DecisionAllow, http.StatusOK, "configuration served")
+
+		logger.InfoContext(r.Context(), "device configuration served", map[string]interface{}{
+			"token":   devconfig.TokenDir(token),
+			"version": blob.Version,
+			"sha256":  blob.SHA256,
+		})
+
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			w.Write(blob.Data)
+		}
+	}
+}
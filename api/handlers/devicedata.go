@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/api/middleware"
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/ingest"
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// DeviceDataPath accepts telemetry devices submit under their DATA token
+const DeviceDataPath = "/api/device/data"
+
+// DataRecordsPath serves stored telemetry to consumers
+const DataRecordsPath = "/api/data/records"
+
+// DataReadAuditAction is the audit action recorded for every telemetry query
+const DataReadAuditAction = "device.data.read"
+
+// DeviceDataHandler stores a payload POSTed by a device authenticated with its DATA
+// token (X-Token-ID) in the ingester's backends. Devices over their frequency quota
+// get 429 with Retry-After and payloads over the size quota 413; the body is stored
+// as sent, with its Content-Type, and the response gives the record ID.
+func DeviceDataHandler(logger *logging.Logger, ingester *ingest.Ingester) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		device, hasDevice := middleware.GetDevice(r.Context())
+		if !hasDevice {
+			respondError(w, http.StatusForbidden, "device registration required")
+			return
+		}
+		token, offset, hasToken := middleware.GetToken(r.Context())
+		if !hasToken || offset != models.TokenOffsetData || token != device.GetDataToken() {
+			respondError(w, http.StatusForbidden, "authenticate with the device's DATA token in X-Token-ID")
+			return
+		}
+
+		if wait, err := ingester.Allow(device.ID); err != nil {
+			logger.WarnContext(r.Context(), "device data quota exceeded", map[string]interface{}{
+				"device_id": device.ID,
+				"wait":      wait.String(),
+			})
+			w.Header().Set("Retry-After", strconv.Itoa(max(int(math.Ceil(wait.Seconds())), 1)))
+			respondError(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+
+		maxBytes := ingester.Quota().MaxBytes
+		if r.ContentLength > maxBytes {
+			respondError(w, http.StatusRequestEntityTooLarge, "payload exceeds "+strconv.FormatInt(maxBytes, 10)+" bytes")
+			return
+		}
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(w, http.StatusRequestEntityTooLarge, "payload exceeds "+strconv.FormatInt(maxBytes, 10)+" bytes")
+			return
+		}
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "failed to read payload")
+			return
+		}
+		if len(data) == 0 {
+			respondError(w, http.StatusBadRequest, "empty payload")
+			return
+		}
+
+		record := ingest.NewRecord(device, r.Header.Get("Content-Type"), data)
+		stored, err := ingester.Store(r.Context(), record)
+		if err != nil {
+			logger.ErrorContext(r.Context(), "failed to store device data", map[string]interface{}{
+				"device_id": device.ID,
+				"record_id": record.ID,
+				"stored":    stored,
+				"error":     err.Error(),
+			})
+			w.Header().Set("Retry-After", "5")
+			respondError(w, http.StatusServiceUnavailable, "storage unavailable")
+			return
+		}
+
+		logger.DebugContext(r.Context(), "device data stored", map[string]interface{}{
+			"device_id": device.ID,
+			"record_id": record.ID,
+			"bytes":     len(data),
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"record_id": record.ID,
+			"bytes":     len(data),
+			"stored":    stored,
+		})
+	}
+}
+
+// dataRecord is a stored record as returned to consumers; Data is base64 in JSON
+type dataRecord struct {
+	ID          string    `json:"record_id"`
+	DeviceID    uint16    `json:"device_id"`
+	Layer       string    `json:"layer"`
+	Class       string    `json:"class"`
+	Clearance   string    `json:"clearance"`
+	ContentType string    `json:"content_type,omitempty"`
+	ReceivedAt  time.Time `json:"received_at"`
+	Data        []byte    `json:"data"`
+}
+
+// DataRecordsHandler returns the telemetry a source device submitted, newest first, to
+// a consuming device: GET /api/data/records?device=<id>[&since=<RFC 3339>][&until=...][&limit=N].
+// Reads follow the labels: the consumer's clearance must dominate the record's, and
+// data only flows upward, so the source's layer must be at or below the consumer's
+// (a transport-layer consumer cannot read control-layer data). A source the consumer
+// may not read from is refused with 403; individual records whose labels do not
+// permit the read are withheld and counted. The consumer reads with the clearance it was
+// registered with; an asserted X-Clearance can only lower it. Every query is audited when
+// auditLogger is set.
+func DataRecordsHandler(logger *logging.Logger, ingester *ingest.Ingester, registry *models.DeviceRegistry, auditLogger *audit.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		consumer, hasConsumer := middleware.GetDevice(r.Context())
+		clearance, _ := middleware.GetClearance(r.Context())
+		if hasConsumer && (clearance == 0 || clearance.IsHigherThan(consumer.Clearance)) {
+			clearance = consumer.Clearance
+		}
+
+		// record audits the outcome; source and the counts are set once known
+		var source *models.Device
+		var returned, withheld int
+		record := func(decision audit.Decision, status int, reason string) {
+			if auditLogger == nil {
+				return
+			}
+			resource := DataRecordsPath
+			if source != nil {
+				resource = fmt.Sprintf("0x%04X", source.GetDataToken())
+			}
+			event := audit.NewEvent(decision, DataReadAuditAction, resource, reason)
+			if hasConsumer {
+				event.Actor = fmt.Sprintf("device-%d", consumer.ID)
+				event.DeviceID = consumer.ID
+				event.Layer = consumer.Layer
+			}
+			event.Clearance = clearance
+			event.Method = r.Method
+			event.RequestID = logging.GetRequestID(r.Context())
+			event.SourceIP = r.RemoteAddr
+			event.StatusCode = status
+			if source != nil {
+				event.AdditionalData = map[string]interface{}{
+					"source_device": source.ID,
+					"source_layer":  string(source.Layer),
+					"returned":      returned,
+					"withheld":      withheld,
+				}
+			}
+			auditLogger.Log(event)
+		}
+		deny := func(status int, reason string) {
+			logger.WarnContext(r.Context(), "telemetry query refused", map[string]interface{}{
+				"reason": reason,
+				"status": status,
+			})
+			record(audit.DecisionDeny, status, reason)
+			respondError(w, status, reason)
+		}
+
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			deny(http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if !hasConsumer {
+			deny(http.StatusForbidden, "device registration required")
+			return
+		}
+		if registry == nil {
+			deny(http.StatusServiceUnavailable, "device registry not configured")
+			return
+		}
+
+		query := r.URL.Query()
+		sourceID, err := strconv.ParseUint(query.Get("device"), 10, 16)
+		if err != nil {
+			deny(http.StatusBadRequest, "device must be the decimal ID of the source device")
+			return
+		}
+		q := ingest.Query{}
+		for _, param := range []struct {
+			name string
+			dest *time.Time
+		}{
+			{"since", &q.Since},
+			{"until", &q.Until},
+		} {
+			if value := query.Get(param.name); value != "" {
+				if *param.dest, err = time.Parse(time.RFC3339, value); err != nil {
+					deny(http.StatusBadRequest, fmt.Sprintf("invalid %s %q: expected RFC 3339", param.name, value))
+					return
+				}
+			}
+		}
+		if value := query.Get("limit"); value != "" {
+			if q.Limit, err = strconv.Atoi(value); err != nil || q.Limit <= 0 {
+				deny(http.StatusBadRequest, fmt.Sprintf("invalid limit %q", value))
+				return
+			}
+		}
+
+		source, err = registry.GetDevice(uint16(sourceID))
+		if err != nil {
+			deny(http.StatusNotFound, fmt.Sprintf("device %d not found", sourceID))
+			return
+		}
+		if !models.CanAccessLayer(source.Layer, consumer.Layer) {
+			deny(http.StatusForbidden, fmt.Sprintf("data cannot flow from the %s layer to the %s layer", source.Layer, consumer.Layer))
+			return
+		}
+		if !clearance.IsHigherOrEqual(source.Clearance) {
+			deny(http.StatusForbidden, "insufficient clearance")
+			return
+		}
+
+		q.Token = source.GetDataToken()
+		records, err := ingester.Read(r.Context(), q)
+		if errors.Is(err, ingest.ErrNoReader) {
+			deny(http.StatusNotImplemented, err.Error())
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(r.Context(), "failed to read device data", map[string]interface{}{
+				"source_device": source.ID,
+				"error":         err.Error(),
+			})
+			record(audit.DecisionDeny, http.StatusServiceUnavailable, "storage unavailable")
+			w.Header().Set("Retry-After", "5")
+			respondError(w, http.StatusServiceUnavailable, "storage unavailable")
+			return
+		}
+
+		// A record keeps the labels it was submitted with, which may be stricter than
+		// the source device's current ones
+		results := make([]dataRecord, 0, len(records))
+		for _, rec := range records {
+			if !rec.Readable(consumer.Layer, clearance) {
+				withheld++
+				continue
+			}
+			results = append(results, dataRecord{
+				ID:          rec.ID,
+				DeviceID:    rec.DeviceID,
+				Layer:       string(rec.Layer),
+				Class:       string(rec.Class),
+				Clearance:   fmt.Sprintf("%08X", uint32(rec.Clearance)),
+				ContentType: rec.ContentType,
+				ReceivedAt:  rec.ReceivedAt,
+				Data:        rec.Data,
+			})
+		}
+		returned = len(results)
+		record(audit.DecisionAllow, http.StatusOK, "telemetry served")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"device_id": source.ID,
+			"token":     fmt.Sprintf("0x%04X", q.Token),
+			"records":   results,
+			"withheld":  withheld,
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NSACodeGov/CodeGov/api/middleware"
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/ingest"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// memoryReader stores records in memory and returns those under the queried token
+type memoryReader struct {
+	records []*ingest.Record
+}
+
+func (m *memoryReader) Name() string { return "memory" }
+
+func (m *memoryReader) Store(ctx context.Context, record *ingest.Record) error {
+	m.records = append(m.records, record)
+	return nil
+}
+
+func (m *memoryReader) Read(ctx context.Context, q ingest.Query) ([]*ingest.Record, error) {
+	var records []*ingest.Record
+	for _, record := range m.records {
+		if record.Token == q.Token {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func TestDataRecordsClearance(t *testing.T) {
+	registry := models.NewDeviceRegistry()
+	source := &models.Device{ID: 5, Layer: models.LayerData, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel5}
+	low := &models.Device{ID: 6, Layer: models.LayerApplication, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel3}
+	high := &models.Device{ID: 7, Layer: models.LayerApplication, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel9}
+	for _, device := range []*models.Device{source, low, high} {
+		if err := registry.Register(device); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backend := &memoryReader{}
+	backend.Store(context.Background(), ingest.NewRecord(source, "application/json", []byte(`{"t": 1}`)))
+	// Submitted while the source held a higher clearance
+	labelled := ingest.NewRecord(source, "application/json", []byte(`{"t": 2}`))
+	labelled.Clearance = models.ClearanceLevel8
+	backend.Store(context.Background(), labelled)
+
+	recorder := &eventRecorder{}
+	auditLogger := audit.NewLogger()
+	auditLogger.AddWriter(recorder)
+	handler := DataRecordsHandler(testLogger(), ingest.New(ingest.Quota{}, backend), registry, auditLogger)
+
+	tests := []struct {
+		name         string
+		consumer     *models.Device
+		asserted     models.Clearance // X-Clearance the request carried; 0 for none
+		wantStatus   int
+		wantReturned int
+		wantWithheld int
+	}{
+		{"registered clearance", high, 0, http.StatusOK, 2, 0},
+		{"asserted clearance above the registered one", low, models.ClearanceLevel9, http.StatusForbidden, 0, 0},
+		{"asserted clearance lowers the read", high, models.ClearanceLevel5, http.StatusOK, 1, 1},
+		{"asserted clearance below the source", high, models.ClearanceLevel3, http.StatusForbidden, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder.events = nil
+			req := httptest.NewRequest(http.MethodGet, DataRecordsPath+"?device=5", nil)
+			ctx := context.WithValue(req.Context(), middleware.DeviceKey, tt.consumer)
+			if tt.asserted != 0 {
+				ctx = context.WithValue(ctx, middleware.ClearanceKey, tt.asserted)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req.WithContext(ctx))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body)
+			}
+			if len(recorder.events) != 1 {
+				t.Fatalf("expected one audit event, got %d", len(recorder.events))
+			}
+			event := recorder.events[0]
+			if tt.wantStatus != http.StatusOK {
+				if event.Decision != audit.DecisionDeny || event.Reason != "insufficient clearance" {
+					t.Errorf("expected a clearance denial, got %+v", event)
+				}
+				return
+			}
+
+			var body struct {
+				Records  []dataRecord `json:"records"`
+				Withheld int          `json:"withheld"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Records) != tt.wantReturned || body.Withheld != tt.wantWithheld {
+				t.Errorf("expected %d returned and %d withheld, got %d and %d", tt.wantReturned, tt.wantWithheld, len(body.Records), body.Withheld)
+			}
+			if event.Decision != audit.DecisionAllow || event.AdditionalData["withheld"] != tt.wantWithheld {
+				t.Errorf("unexpected audit event %+v", event)
+			}
+		})
+	}
+}
@@ -89,7 +89,8 @@ func DeviceOnlyHandler(logger *logging.Logger) http.HandlerFunc {
 	}
 }
 
-// DeviceStatusHandler handles device status requests
+// DeviceStatusHandler handles device status requests. GET returns the device's
+// status; POST accepts a status report from the device.
 func DeviceStatusHandler(logger *logging.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		device, hasDevice := middleware.GetDevice(r.Context())
@@ -103,6 +104,11 @@ func DeviceStatusHandler(logger *logging.Logger) http.HandlerFunc {
 			return
 		}
 
+		if r.Method == http.MethodPost {
+			reportDeviceStatus(logger, w, r, device)
+			return
+		}
+
 		clearance, _ := middleware.GetClearance(r.Context())
 
 		response := map[string]interface{}{
@@ -125,6 +131,48 @@ func DeviceStatusHandler(logger *logging.Logger) http.HandlerFunc {
 	}
 }
 
+// DeviceStatusReport is the telemetry a device sends to POST /api/device/status
+type DeviceStatusReport struct {
+	Status   string             `json:"status" validate:"required,oneof=operational degraded maintenance fault"`
+	Uptime   int64              `json:"uptime_seconds" validate:"min=0"`
+	Firmware string             `json:"firmware,omitempty" validate:"max=64"`
+	Metrics  map[string]float64 `json:"metrics,omitempty" validate:"max=64"`
+}
+
+// Validate checks the metric names
+func (report *DeviceStatusReport) Validate() []Violation {
+	var violations []Violation
+	for name := range report.Metrics {
+		if name == "" || len(name) > 64 {
+			violations = append(violations, Violation{Field: "metrics", Message: fmt.Sprintf("metric name %q must have 1 to 64 characters", name)})
+		}
+	}
+	return violations
+}
+
+// reportDeviceStatus validates and logs a status report from the requesting device
+func reportDeviceStatus(logger *logging.Logger, w http.ResponseWriter, r *http.Request, device *models.Device) {
+	var report DeviceStatusReport
+	if !decodeJSON(w, r, &report) {
+		return
+	}
+
+	logger.InfoContext(r.Context(), "device status report", map[string]interface{}{
+		"device_id":      device.ID,
+		"status":         report.Status,
+		"uptime_seconds": report.Uptime,
+		"firmware":       report.Firmware,
+		"metrics":        report.Metrics,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"device_id": device.ID,
+		"accepted":  report,
+	})
+}
+
 // HighSecurityHandler requires high clearance
 func HighSecurityHandler(logger *logging.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -162,3 +210,37 @@ func HighSecurityHandler(logger *logging.Logger) http.HandlerFunc {
 		json.NewEncoder(w).Encode(response)
 	}
 }
+
+// CodeJSONPath serves the generated code.gov inventory
+const CodeJSONPath = "/code.json"
+
+// InventoryPath serves the same inventory as /code.json through the clearance
+// middleware, so the policy decides who may read it
+const InventoryPath = "/api/inventory"
+
+// PrivateCodeJSONPath serves the full inventory, private repositories included, to
+// callers the policy grants it to
+const PrivateCodeJSONPath = "/api/inventory/private"
+
+// CodeJSONHandler serves a code.json file produced by the codegov generator.
+// The file is re-read only when its size or modification time changes.
+func CodeJSONHandler(logger *logging.Logger, path string) http.HandlerFunc {
+	cache := &fileCache{path: path}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowReadOnly(w, r) {
+			return
+		}
+
+		entry, err := cache.get()
+		if err != nil {
+			logger.ErrorContext(r.Context(), "failed to read code.json", map[string]interface{}{
+				"path":  path,
+				"error": err.Error(),
+			})
+			respondError(w, http.StatusServiceUnavailable, "code.json is not available")
+			return
+		}
+
+		serveCached(w, r, entry)
+	}
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/internal/sigv4"
+)
+
+// DefaultInventoryRefresh is how long an inventory read from MinIO is served before
+// it is revalidated
+const DefaultInventoryRefresh = time.Minute
+
+// maxInventorySize bounds inventories read from MinIO
+const maxInventorySize = 64 << 20
+
+// MinIOObject is an inventory stored as a MinIO (or other S3-compatible) object,
+// e.g. by inventory jobs that publish to minio://bucket/key
+type MinIOObject struct {
+	Endpoint  string // host:port
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	Region    string // Defaults to us-east-1
+	Bucket    string
+	Key       string
+	Client    *http.Client // Defaults to a client with a 30s timeout
+}
+
+// ParseMinIOPath splits a minio://bucket/key path, reporting false for other paths
+func ParseMinIOPath(path string) (bucket, key string, ok bool) {
+	rest, ok := strings.CutPrefix(path, "minio://")
+	if !ok {
+		return "", "", false
+	}
+	bucket, key, ok = strings.Cut(rest, "/")
+	return bucket, key, ok && bucket != "" && key != ""
+}
+
+func (o *MinIOObject) String() string {
+	return "minio://" + o.Bucket + "/" + o.Key
+}
+
+// do sends a SigV4-signed request for the object
+func (o *MinIOObject) do(ctx context.Context, method string, body []byte, header http.Header) (*http.Response, error) {
+	scheme := "http"
+	if o.UseSSL {
+		scheme = "https"
+	}
+	url := scheme + "://" + o.Endpoint + "/" + sigv4.URIEncode(o.Bucket, false) + "/" + sigv4.URIEncode(o.Key, false)
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if o.AccessKey != "" {
+		creds := sigv4.Credentials{AccessKey: o.AccessKey, SecretKey: o.SecretKey, Region: o.Region}
+		hash := sigv4.EmptyPayloadHash
+		if body != nil {
+			hash = sigv4.PayloadHash(body)
+		}
+		creds.Sign(req, hash, time.Now())
+	}
+
+	client := o.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return client.Do(req)
+}
+
+// Put uploads an inventory, replacing the object
+func (o *MinIOObject) Put(ctx context.Context, data []byte) error {
+	resp, err := o.do(ctx, http.MethodPut, data, http.Header{"Content-Type": {"application/json"}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("PUT %s: %s: %s", o, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// objectCache holds an inventory read from MinIO, revalidated with the object's ETag
+// once refresh has passed
+type objectCache struct {
+	object  *MinIOObject
+	refresh time.Duration
+
+	mu         sync.Mutex
+	checked    time.Time
+	objectETag string
+	entry      *cachedBody
+}
+
+// get returns the cached inventory, revalidating it when refresh has passed. When
+// revalidation fails, the last inventory is returned with the error.
+func (c *objectCache) get(ctx context.Context) (*cachedBody, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entry != nil && time.Since(c.checked) < c.refresh {
+		return c.entry, nil
+	}
+
+	header := http.Header{}
+	if c.entry != nil && c.objectETag != "" {
+		header.Set("If-None-Match", c.objectETag)
+	}
+	resp, err := c.object.do(ctx, http.MethodGet, nil, header)
+	if err != nil {
+		return c.entry, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		c.checked = time.Now()
+		return c.entry, nil
+	case http.StatusOK:
+	default:
+		return c.entry, fmt.Errorf("GET %s: unexpected status %d", c.object, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxInventorySize+1))
+	if err != nil {
+		return c.entry, err
+	}
+	if len(body) > maxInventorySize {
+		return c.entry, fmt.Errorf("GET %s: object exceeds %d bytes", c.object, maxInventorySize)
+	}
+
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	c.checked = time.Now()
+	c.objectETag = resp.Header.Get("ETag")
+	c.entry = newCachedBody(body, modified)
+	return c.entry, nil
+}
+
+// MinIOCodeJSONHandler serves a code.json stored in MinIO. The object is revalidated
+// at most every refresh (DefaultInventoryRefresh when zero); while MinIO is
+// unreachable, the last inventory read keeps being served.
+func MinIOCodeJSONHandler(logger *logging.Logger, object *MinIOObject, refresh time.Duration) http.HandlerFunc {
+	if refresh <= 0 {
+		refresh = DefaultInventoryRefresh
+	}
+	cache := &objectCache{object: object, refresh: refresh}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowReadOnly(w, r) {
+			return
+		}
+
+		entry, err := cache.get(r.Context())
+		if err != nil {
+			logger.ErrorContext(r.Context(), "failed to read code.json", map[string]interface{}{
+				"object": object.String(),
+				"stale":  entry != nil,
+				"error":  err.Error(),
+			})
+		}
+		if entry == nil {
+			respondError(w, http.StatusServiceUnavailable, "code.json is not available")
+			return
+		}
+
+		serveCached(w, r, entry)
+	}
+}
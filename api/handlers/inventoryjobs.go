@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/NSACodeGov/CodeGov/codegov"
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/jobs"
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+)
+
+// InventoryJobKind is the job kind that generates a code.gov inventory
+const InventoryJobKind = "inventory"
+
+// InventoryGeneratePath queues an inventory generation job
+const InventoryGeneratePath = "/api/inventory/generate"
+
+// InventoryJobsPrefix is the path prefix for a generation job's status and result
+const InventoryJobsPrefix = "/api/inventory/jobs/"
+
+// InventoryJobRoute is the normalized route name of a generation job's status endpoint
+const InventoryJobRoute = "/api/inventory/jobs/{id}"
+
+// InventoryJobResultRoute is the normalized route name of a generation job's result endpoint
+const InventoryJobResultRoute = "/api/inventory/jobs/{id}/result"
+
+// InventoryGenerateAuditAction records who requested an inventory generation
+const InventoryGenerateAuditAction = "inventory.generate"
+
+// InventoryJobRouteName returns the normalized route name for a path under InventoryJobsPrefix
+func InventoryJobRouteName(path string) string {
+	if strings.HasSuffix(path, "/result") {
+		return InventoryJobResultRoute
+	}
+	return InventoryJobRoute
+}
+
+// InventoryJobParams are the parameters of an "inventory" job, and the body of
+// POST /api/inventory/generate
+type InventoryJobParams struct {
+	Organizations []string `json:"organizations" validate:"required"` // GitHub organizations, "user:" accounts, "gitlab:" groups, "bitbucket:" workspaces and "azuredevops:" organizations
+	Agency        string   `json:"agency" validate:"required"`
+	Email         string   `json:"email" validate:"required"` // Contact email published with the inventory
+	Organization  string   `json:"organization,omitempty"` // Published instead of each owner name
+	Private       string   `json:"private,omitempty"`      // exclude, include or only
+	Forks         string   `json:"forks,omitempty"`
+	Archived      string   `json:"archived,omitempty"`
+	Include       []string `json:"include,omitempty"`
+	Exclude       []string `json:"exclude,omitempty"`
+	Publish       bool     `json:"publish,omitempty"` // Replace the inventory served at /code.json, or the private one when private repositories are included
+}
+
+// Validate checks the contact email and the repository filters
+func (p *InventoryJobParams) Validate() []Violation {
+	var violations []Violation
+	if p.Email != "" {
+		if _, err := mail.ParseAddress(p.Email); err != nil {
+			violations = append(violations, Violation{Field: "email", Message: "must be an email address"})
+		}
+	}
+	for _, filter := range []struct{ field, value string }{{"private", p.Private}, {"forks", p.Forks}, {"archived", p.Archived}} {
+		if filter.value == "" {
+			continue
+		}
+		if _, err := codegov.ParseRepoFilter(filter.value); err != nil {
+			violations = append(violations, Violation{Field: filter.field, Message: "must be one of exclude, include, only"})
+		}
+	}
+	return violations
+}
+
+// InventoryGenerateHandler handles POST /api/inventory/generate, which queues an
+// inventory job with the body's parameters and answers 202 with the job's ID and the
+// URLs of its status and result
+func InventoryGenerateHandler(logger *logging.Logger, manager *jobs.Manager, auditLogger *audit.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if manager == nil {
+			respondError(w, http.StatusServiceUnavailable, "jobs not configured")
+			return
+		}
+
+		var params InventoryJobParams
+		if !decodeJSON(w, r, &params) {
+			return
+		}
+		raw, _ := json.Marshal(params)
+
+		actor := jobActor(r)
+		job, err := manager.Submit(InventoryJobKind, raw, actor)
+		switch {
+		case errors.Is(err, jobs.ErrUnknownKind):
+			respondError(w, http.StatusServiceUnavailable, "inventory generation not configured")
+			return
+		case errors.Is(err, jobs.ErrQueueFull):
+			w.Header().Set("Retry-After", strconv.Itoa(60))
+			respondError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		case err != nil:
+			logger.ErrorContext(r.Context(), "failed to submit inventory job", map[string]interface{}{
+				"error": err.Error(),
+			})
+			respondError(w, http.StatusInternalServerError, "failed to submit job")
+			return
+		}
+
+		logger.InfoContext(r.Context(), "inventory generation requested", map[string]interface{}{
+			"job":           job.ID,
+			"actor":         actor,
+			"organizations": params.Organizations,
+			"publish":       params.Publish,
+		})
+		reason := "inventory generation requested by " + actor + " for " + strings.Join(params.Organizations, ", ")
+		if params.Publish {
+			reason += ", publishing"
+		}
+		auditJob(r, auditLogger, InventoryGenerateAuditAction, job, actor, reason)
+
+		status := InventoryJobsPrefix + job.ID
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", status)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":     job.ID,
+			"status":     job.Status,
+			"status_url": status,
+			"result_url": status + "/result",
+		})
+	}
+}
+
+// InventoryJobHandler handles GET /api/inventory/jobs/{id} (status) and
+// GET /api/inventory/jobs/{id}/result, which answers 409 until the job succeeds.
+// Jobs of other kinds are not found here.
+func InventoryJobHandler(logger *logging.Logger, manager *jobs.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowReadOnly(w, r) {
+			return
+		}
+		if manager == nil {
+			respondError(w, http.StatusServiceUnavailable, "jobs not configured")
+			return
+		}
+
+		id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, InventoryJobsPrefix), "/")
+		if id == "" || (action != "" && action != "result") {
+			http.NotFound(w, r)
+			return
+		}
+		job, err := manager.Get(id)
+		if err != nil || job.Kind != InventoryJobKind {
+			respondError(w, http.StatusNotFound, jobs.ErrNotFound.Error())
+			return
+		}
+
+		if action == "result" {
+			writeJobResult(w, r, logger, manager, id)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(job)
+	}
+}
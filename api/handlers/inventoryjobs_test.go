@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/api/middleware"
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/jobs"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+func TestInventoryJobParamsValidation(t *testing.T) {
+	manager, err := jobs.New(t.TempDir(), jobs.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runs int
+	manager.Register(InventoryJobKind, func(ctx context.Context, params json.RawMessage) (*jobs.Result, error) {
+		runs++
+		return &jobs.Result{}, nil
+	})
+	handler := InventoryGenerateHandler(testLogger(), manager, nil)
+
+	tests := []struct {
+		name string
+		body string
+		want []Violation
+	}{
+		{"missing everything", `{}`, []Violation{
+			{Field: "organizations", Message: "is required"},
+			{Field: "agency", Message: "is required"},
+			{Field: "email", Message: "is required"},
+		}},
+		{"missing agency", `{"organizations": ["my-agency"], "email": "code@agency.gov"}`, []Violation{
+			{Field: "agency", Message: "is required"},
+		}},
+		{"invalid email", `{"organizations": ["my-agency"], "agency": "NSA", "email": "code at agency"}`, []Violation{
+			{Field: "email", Message: "must be an email address"},
+		}},
+		{"invalid filter", `{"organizations": ["my-agency"], "agency": "NSA", "email": "code@agency.gov", "forks": "some"}`, []Violation{
+			{Field: "forks", Message: "must be one of exclude, include, only"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, InventoryGeneratePath, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("expected 422, got %d: %s", rec.Code, rec.Body)
+			}
+			var body struct {
+				Violations []Violation `json:"violations"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(body.Violations, tt.want) {
+				t.Errorf("violations = %+v, want %+v", body.Violations, tt.want)
+			}
+		})
+	}
+	if jobs := manager.List(); len(jobs) != 0 || runs != 0 {
+		t.Errorf("invalid requests queued %d jobs", len(jobs))
+	}
+}
+
+func TestInventoryJobLifecycle(t *testing.T) {
+	manager, err := jobs.New(t.TempDir(), jobs.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.Register(InventoryJobKind, func(ctx context.Context, raw json.RawMessage) (*jobs.Result, error) {
+		var params InventoryJobParams
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, err
+		}
+		if params.Organizations[0] == "broken" {
+			return nil, errors.New("organization broken not found")
+		}
+		return &jobs.Result{Data: []byte(`{"agency":"` + params.Agency + `"}`), ContentType: "application/json", Summary: "1 release"}, nil
+	})
+	// Another kind, which the inventory endpoints must not expose
+	manager.Register("other", func(ctx context.Context, raw json.RawMessage) (*jobs.Result, error) {
+		return &jobs.Result{}, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		manager.Run(ctx)
+		close(stopped)
+	}()
+	// Stop the workers before the job directory is removed
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	recorder := &eventRecorder{}
+	auditLogger := audit.NewLogger()
+	auditLogger.AddWriter(recorder)
+	generate := InventoryGenerateHandler(testLogger(), manager, auditLogger)
+	status := InventoryJobHandler(testLogger(), manager)
+	device := &models.Device{ID: 4, Layer: models.LayerApplication, Class: models.DeviceClassController, Clearance: models.ClearanceLevel9}
+
+	submit := func(organization string) string {
+		t.Helper()
+		body := `{"organizations": ["` + organization + `"], "agency": "NSA", "email": "code@agency.gov"}`
+		req := httptest.NewRequest(http.MethodPost, InventoryGeneratePath, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(context.WithValue(req.Context(), middleware.DeviceKey, device))
+		rec := httptest.NewRecorder()
+		generate.ServeHTTP(rec, req)
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body)
+		}
+		var accepted struct {
+			JobID     string `json:"job_id"`
+			StatusURL string `json:"status_url"`
+			ResultURL string `json:"result_url"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&accepted); err != nil {
+			t.Fatal(err)
+		}
+		if accepted.StatusURL != InventoryJobsPrefix+accepted.JobID || accepted.ResultURL != accepted.StatusURL+"/result" ||
+			rec.Header().Get("Location") != accepted.StatusURL {
+			t.Errorf("unexpected job URLs %+v", accepted)
+		}
+		return accepted.JobID
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		status.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	// poll waits for a job to finish and returns its record
+	poll := func(id string) jobs.Job {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			rec := get(InventoryJobsPrefix + id)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+			}
+			var job jobs.Job
+			if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+				t.Fatal(err)
+			}
+			if job.Done() {
+				return job
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("job %s still %s", id, job.Status)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	id := submit("my-agency")
+	if len(recorder.events) != 1 || recorder.events[0].Action != InventoryGenerateAuditAction || recorder.events[0].Actor != "device-4" ||
+		recorder.events[0].Resource != InventoryJobKind+"/"+id {
+		t.Errorf("unexpected audit events %+v", recorder.events)
+	}
+	if job := poll(id); job.Status != jobs.StatusSucceeded || job.Summary != "1 release" || job.Actor != "device-4" {
+		t.Errorf("unexpected finished job %+v", job)
+	}
+	rec := get(InventoryJobsPrefix + id + "/result")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" || rec.Body.String() != `{"agency":"NSA"}` {
+		t.Errorf("unexpected result %d %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+
+	// A job that fails reports its error, and has no result
+	id = submit("broken")
+	if job := poll(id); job.Status != jobs.StatusFailed || !strings.Contains(job.Error, "organization broken not found") {
+		t.Errorf("unexpected failed job %+v", job)
+	}
+	if rec := get(InventoryJobsPrefix + id + "/result"); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a failed job's result, got %d", rec.Code)
+	}
+
+	// Unknown jobs, jobs of other kinds and unknown actions are not found
+	other, err := manager.Submit("other", nil, "device-4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{InventoryJobsPrefix + "missing", InventoryJobsPrefix + other.ID, InventoryJobsPrefix + id + "/logs"} {
+		if rec := get(path); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, rec.Code)
+		}
+	}
+}
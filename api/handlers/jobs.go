@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/NSACodeGov/CodeGov/api/middleware"
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/jobs"
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+)
+
+// AdminJobsPath lists jobs and submits new ones
+const AdminJobsPath = "/api/admin/jobs"
+
+// AdminJobsPrefix is the path prefix for a single job's status, result and cancellation
+const AdminJobsPrefix = "/api/admin/jobs/"
+
+// JobRoute is the normalized route name of a job's status and cancellation endpoint
+const JobRoute = "/api/admin/jobs/{id}"
+
+// JobResultRoute is the normalized route name of a job's result endpoint
+const JobResultRoute = "/api/admin/jobs/{id}/result"
+
+// Job audit actions
+const (
+	JobSubmitAuditAction = "job.submit"
+	JobCancelAuditAction = "job.cancel"
+)
+
+// JobRouteName returns the normalized route name for a path under AdminJobsPrefix
+func JobRouteName(path string) string {
+	if strings.HasSuffix(path, "/result") {
+		return JobResultRoute
+	}
+	return JobRoute
+}
+
+// SubmitJobRequest is the body of POST /api/admin/jobs
+type SubmitJobRequest struct {
+	Kind   string          `json:"kind" validate:"required"`
+	Params json.RawMessage `json:"params"` // Passed to the job as is; each kind documents its own
+}
+
+// JobsHandler handles GET /api/admin/jobs, listing jobs newest first, and POST,
+// which queues a job and answers 202 with its record
+func JobsHandler(logger *logging.Logger, manager *jobs.Manager, auditLogger *audit.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if manager == nil {
+			respondError(w, http.StatusServiceUnavailable, "jobs not configured")
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			list := manager.List()
+			if status := r.URL.Query().Get("status"); status != "" {
+				filtered := list[:0]
+				for _, job := range list {
+					if string(job.Status) == status {
+						filtered = append(filtered, job)
+					}
+				}
+				list = filtered
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"jobs":  list,
+				"kinds": manager.Kinds(),
+			})
+
+		case http.MethodPost:
+			var req SubmitJobRequest
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+
+			actor := jobActor(r)
+			job, err := manager.Submit(req.Kind, req.Params, actor)
+			switch {
+			case errors.Is(err, jobs.ErrUnknownKind):
+				respondViolations(w, []Violation{{Field: "kind", Message: "must be one of " + strings.Join(manager.Kinds(), ", ")}})
+				return
+			case errors.Is(err, jobs.ErrQueueFull):
+				w.Header().Set("Retry-After", strconv.Itoa(60))
+				respondError(w, http.StatusServiceUnavailable, err.Error())
+				return
+			case err != nil:
+				logger.ErrorContext(r.Context(), "failed to submit job", map[string]interface{}{
+					"kind":  req.Kind,
+					"error": err.Error(),
+				})
+				respondError(w, http.StatusInternalServerError, "failed to submit job")
+				return
+			}
+
+			logger.InfoContext(r.Context(), "job submitted", map[string]interface{}{
+				"job":   job.ID,
+				"kind":  job.Kind,
+				"actor": actor,
+			})
+			auditJob(r, auditLogger, JobSubmitAuditAction, job, actor, "job submitted by "+actor)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Location", AdminJobsPrefix+job.ID)
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(job)
+
+		default:
+			respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	}
+}
+
+// JobHandler handles GET /api/admin/jobs/{id} (status), DELETE /api/admin/jobs/{id}
+// (cancel) and GET /api/admin/jobs/{id}/result, which answers 409 until the job succeeds
+func JobHandler(logger *logging.Logger, manager *jobs.Manager, auditLogger *audit.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if manager == nil {
+			respondError(w, http.StatusServiceUnavailable, "jobs not configured")
+			return
+		}
+
+		id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, AdminJobsPrefix), "/")
+		if id == "" || (action != "" && action != "result") {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && action == "result":
+			writeJobResult(w, r, logger, manager, id)
+
+		case r.Method == http.MethodGet && action == "":
+			job, err := manager.Get(id)
+			if err != nil {
+				respondError(w, http.StatusNotFound, err.Error())
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(job)
+
+		case r.Method == http.MethodDelete && action == "":
+			job, err := manager.Cancel(id)
+			switch {
+			case errors.Is(err, jobs.ErrNotFound):
+				respondError(w, http.StatusNotFound, err.Error())
+				return
+			case errors.Is(err, jobs.ErrFinished):
+				respondError(w, http.StatusConflict, err.Error())
+				return
+			}
+
+			actor := jobActor(r)
+			logger.InfoContext(r.Context(), "job canceled", map[string]interface{}{
+				"job":   job.ID,
+				"kind":  job.Kind,
+				"actor": actor,
+			})
+			auditJob(r, auditLogger, JobCancelAuditAction, job, actor, "job canceled by "+actor)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(job)
+
+		default:
+			respondError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	}
+}
+
+// writeJobResult serves a job's result, answering 409 until the job succeeds
+func writeJobResult(w http.ResponseWriter, r *http.Request, logger *logging.Logger, manager *jobs.Manager, id string) {
+	data, contentType, err := manager.Result(id)
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, jobs.ErrNoResult):
+		respondError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		logger.ErrorContext(r.Context(), "failed to read job result", map[string]interface{}{
+			"job":   id,
+			"error": err.Error(),
+		})
+		respondError(w, http.StatusInternalServerError, "failed to read job result")
+	default:
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	}
+}
+
+// jobActor names the device submitting or canceling a job
+func jobActor(r *http.Request) string {
+	if device, ok := middleware.GetDevice(r.Context()); ok {
+		return fmt.Sprintf("device-%d", device.ID)
+	}
+	return "unknown"
+}
+
+// auditJob records a job submission or cancellation
+func auditJob(r *http.Request, auditLogger *audit.Logger, action string, job *jobs.Job, actor, reason string) {
+	if auditLogger == nil {
+		return
+	}
+	event := audit.NewEvent(audit.DecisionAllow, action, job.Kind+"/"+job.ID, reason)
+	event.Actor = actor
+	event.Method = r.Method
+	event.RequestID = logging.GetRequestID(r.Context())
+	event.SourceIP = r.RemoteAddr
+	auditLogger.Log(event)
+}
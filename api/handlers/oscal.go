@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/internal/oscal"
+	"github.com/NSACodeGov/CodeGov/internal/policy"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// AdminOSCALComponentPath exports the policy configuration as an OSCAL component definition
+const AdminOSCALComponentPath = "/api/admin/oscal/component-definition"
+
+// AdminOSCALResultsPath exports the audit trail as OSCAL assessment results
+const AdminOSCALResultsPath = "/api/admin/oscal/assessment-results"
+
+// DefaultAssessmentWindow is how far back assessment results look by default
+const DefaultAssessmentWindow = 30 * 24 * time.Hour
+
+// OSCALComponentHandler handles GET /api/admin/oscal/component-definition
+func OSCALComponentHandler(logger *logging.Logger, engine *policy.Engine, system oscal.System) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowReadOnly(w, r) {
+			return
+		}
+		if engine == nil {
+			respondError(w, http.StatusServiceUnavailable, "policy engine not configured")
+			return
+		}
+
+		document := oscal.NewComponentDefinition(system, engine.GetPolicy(), time.Now())
+		logger.InfoContext(r.Context(), "OSCAL component definition exported", map[string]interface{}{
+			"uuid": document.ComponentDefinition.UUID,
+		})
+		respondOSCAL(w, document)
+	}
+}
+
+// OSCALResultsHandler handles GET /api/admin/oscal/assessment-results?days=30, assessing
+// the active policy and the audit events of the last days from the system's audit
+// file. import_ap names the assessment plan the results belong to.
+func OSCALResultsHandler(logger *logging.Logger, engine *policy.Engine, registry *models.DeviceRegistry, system oscal.System) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowReadOnly(w, r) {
+			return
+		}
+		if engine == nil {
+			respondError(w, http.StatusServiceUnavailable, "policy engine not configured")
+			return
+		}
+
+		window := DefaultAssessmentWindow
+		if days := r.URL.Query().Get("days"); days != "" {
+			n, err := strconv.Atoi(days)
+			if err != nil || n <= 0 {
+				respondError(w, http.StatusBadRequest, "days must be a positive integer")
+				return
+			}
+			window = time.Duration(n) * 24 * time.Hour
+		}
+
+		now := time.Now()
+		var trail *oscal.AuditTrail
+		if system.AuditFile != "" {
+			trail = oscal.NewAuditTrail(now.Add(-window), now)
+			skipped, err := audit.ScanFile(system.AuditFile, trail.Add)
+			if err != nil {
+				logger.ErrorContext(r.Context(), "failed to read audit trail", map[string]interface{}{
+					"path":  system.AuditFile,
+					"error": err.Error(),
+				})
+				respondError(w, http.StatusInternalServerError, "failed to read audit trail")
+				return
+			}
+			trail.Skipped = skipped
+		}
+
+		current := engine.GetPolicy()
+		document := oscal.NewAssessmentResults(system, current, policy.Lint(current, registry), trail, r.URL.Query().Get("import_ap"), now)
+		fields := map[string]interface{}{
+			"uuid":   document.AssessmentResults.UUID,
+			"window": window.String(),
+		}
+		if trail != nil {
+			fields["events"] = trail.Events
+			fields["skipped"] = trail.Skipped
+		}
+		logger.InfoContext(r.Context(), "OSCAL assessment results exported", fields)
+		respondOSCAL(w, document)
+	}
+}
+
+// respondOSCAL writes an OSCAL document as indented JSON
+func respondOSCAL(w http.ResponseWriter, document interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(document)
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/api/middleware"
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/breaker"
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/pkg/identity"
+)
+
+// ProxyAuditAction is the audit action recorded for every request forwarded to an upstream
+const ProxyAuditAction = "proxy.forward"
+
+// Upstream timeouts used when an Upstream leaves them zero
+const (
+	DefaultUpstreamTimeout        = 30 * time.Second // Wait for response headers
+	DefaultUpstreamConnectTimeout = 5 * time.Second  // Wait for the TCP connection
+)
+
+// MaxSignedBodyBytes bounds the bodies of requests forwarded with a signed identity,
+// which are buffered because the signature covers a digest of the body
+const MaxSignedBodyBytes = 32 << 20
+
+// upstreamRetryBackoff is the wait before the first retry, doubled for each further retry
+var upstreamRetryBackoff = 100 * time.Millisecond
+
+// credentialHeaders are the client's own identity claims. Upstreams receive the
+// verified identity as signed headers instead.
+var credentialHeaders = []string{"X-Device-ID", "X-Clearance", "X-Layer", "X-Token-ID"}
+
+// Upstream is an internal service proxied under a path prefix
+type Upstream struct {
+	Prefix          string        // Path prefix ending in "/", e.g. "/svc/inventory/"; "/" forwards every path not served locally
+	Target          *url.URL      // Base URL of the service
+	StripPrefix     bool          // Remove Prefix from the path before forwarding
+	ForwardIdentity bool          // Send the verified device identity as signed headers
+	Timeout         time.Duration // Wait for response headers; zero uses DefaultUpstreamTimeout
+	ConnectTimeout  time.Duration // Wait for the connection; zero uses DefaultUpstreamConnectTimeout
+	StripHeaders    []string      // Further credential headers to remove, e.g. the identity mapping's groups and claims headers
+
+	// Retries is the number of extra attempts for GET, HEAD and OPTIONS requests without
+	// a body that fail to connect or get a 502, 503 or 504; RetryBudget, when set, caps them
+	Retries     int
+	RetryBudget *breaker.RetryBudget
+
+	// Breaker, when set, fails requests fast with 503 while the upstream keeps failing
+	Breaker *breaker.Breaker
+}
+
+// ProxyHandler forwards requests under upstream.Prefix to the upstream service.
+// Requests reach it only after the clearance middleware and policy allowed them;
+// with ForwardIdentity the device ID, clearance and layer that were verified are
+// signed by signer (see package identity) so the service can trust them.
+// Identity and credential headers sent by the client are always removed. Each forwarded request
+// is audited with the upstream's status code when auditLogger is set.
+func ProxyHandler(logger *logging.Logger, upstream Upstream, signer *identity.Signer, auditLogger *audit.Logger) http.HandlerFunc {
+	timeout := upstream.Timeout
+	if timeout <= 0 {
+		timeout = DefaultUpstreamTimeout
+	}
+	connectTimeout := upstream.ConnectTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = DefaultUpstreamConnectTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = timeout
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+
+	proxy := &httputil.ReverseProxy{
+		Transport: &upstreamTransport{base: transport, upstream: upstream},
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			if upstream.StripPrefix {
+				pr.Out.URL.Path = "/" + strings.TrimPrefix(pr.In.URL.Path, upstream.Prefix)
+				pr.Out.URL.RawPath = ""
+			}
+			pr.SetURL(upstream.Target)
+			pr.SetXForwarded()
+
+			identity.Strip(pr.Out.Header)
+			for _, name := range credentialHeaders {
+				pr.Out.Header.Del(name)
+			}
+			for _, name := range upstream.StripHeaders {
+				pr.Out.Header.Del(name)
+			}
+			if upstream.ForwardIdentity && signer != nil {
+				if id, ok := requestIdentity(pr.In); ok {
+					if err := signer.Sign(pr.Out, id); err != nil {
+						// Forward unsigned; the upstream rejects it rather than trusting it
+						logger.WarnContext(pr.In.Context(), "failed to sign identity", map[string]interface{}{
+							"upstream": upstream.Target.Redacted(),
+							"error":    err.Error(),
+						})
+					}
+				}
+			}
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if errors.Is(err, breaker.ErrOpen) {
+				seconds := int(math.Ceil(upstream.Breaker.RetryAfter().Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+				respondError(w, http.StatusServiceUnavailable, "upstream circuit open")
+				return
+			}
+			logger.ErrorContext(r.Context(), "upstream request failed", map[string]interface{}{
+				"upstream": upstream.Target.Redacted(),
+				"error":    err.Error(),
+			})
+			respondError(w, http.StatusBadGateway, "upstream unavailable")
+		},
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if upstream.ForwardIdentity && signer != nil && r.Body != nil && r.Body != http.NoBody {
+			// Read the body up front, so an oversized one is refused instead of forwarded unsigned
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxSignedBodyBytes))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				respondError(w, http.StatusRequestEntityTooLarge, "body exceeds "+strconv.Itoa(MaxSignedBodyBytes)+" bytes")
+				return
+			}
+			if err != nil {
+				respondError(w, http.StatusBadRequest, "failed to read body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		proxy.ServeHTTP(rec, r)
+
+		if auditLogger != nil {
+			event := audit.NewEvent(audit.DecisionAllow, ProxyAuditAction, r.URL.Path, "forwarded to "+upstream.Target.Redacted())
+			if id, ok := requestIdentity(r); ok {
+				event.Actor = fmt.Sprintf("device-%d", id.DeviceID)
+				event.DeviceID = id.DeviceID
+				event.Clearance = id.Clearance
+				event.Layer = id.Layer
+			}
+			event.Method = r.Method
+			event.RequestID = logging.GetRequestID(r.Context())
+			event.SourceIP = r.RemoteAddr
+			event.StatusCode = rec.status
+			auditLogger.Log(event)
+		}
+	}
+}
+
+// upstreamTransport applies an upstream's circuit breaker and retries to each request
+type upstreamTransport struct {
+	base     http.RoundTripper
+	upstream Upstream
+}
+
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cb, budget := t.upstream.Breaker, t.upstream.RetryBudget
+	if budget != nil {
+		budget.Request()
+	}
+	retryable := (req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodOptions) &&
+		(req.Body == nil || req.Body == http.NoBody)
+
+	backoff := upstreamRetryBackoff
+	for attempt := 0; ; attempt++ {
+		if cb != nil && !cb.Allow() {
+			return nil, breaker.ErrOpen
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if req.Context().Err() != nil {
+			// The client went away; that says nothing about the upstream
+			if cb != nil {
+				cb.Ignore()
+			}
+			return resp, err
+		}
+
+		failure := err
+		if err == nil {
+			switch resp.StatusCode {
+			case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				failure = fmt.Errorf("upstream returned %d", resp.StatusCode)
+			}
+		}
+		if failure == nil {
+			if cb != nil {
+				cb.Success()
+			}
+			return resp, nil
+		}
+		if cb != nil {
+			cb.Failure(failure)
+		}
+
+		if !retryable || attempt >= t.upstream.Retries || (budget != nil && !budget.Withdraw()) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		if err := sleepContext(req.Context(), backoff); err != nil {
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// statusRecorder captures the status code the proxy sent
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, so streamed
+// responses are flushed and upgraded connections hijacked
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// requestIdentity collects the identity the clearance middleware verified.
+// Requests with neither a device nor a clearance have no identity to forward.
+func requestIdentity(r *http.Request) (identity.Identity, bool) {
+	var id identity.Identity
+	device, hasDevice := middleware.GetDevice(r.Context())
+	clearance, hasClearance := middleware.GetClearance(r.Context())
+	if !hasDevice && !hasClearance {
+		return id, false
+	}
+
+	if hasDevice {
+		id.DeviceID = device.ID
+		id.Layer = device.Layer
+	}
+	id.Clearance = clearance
+	if layer, ok := middleware.GetLayer(r.Context()); ok {
+		id.Layer = layer
+	}
+	return id, true
+}
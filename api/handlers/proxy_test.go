@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/api/middleware"
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/breaker"
+	"github.com/NSACodeGov/CodeGov/pkg/identity"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// newUpstream starts a service answering with handler and returns its URL
+func newUpstream(t *testing.T, handler http.HandlerFunc) *url.URL {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+// withIdentity attaches what the clearance middleware verified to a request
+func withIdentity(r *http.Request, device *models.Device) *http.Request {
+	ctx := context.WithValue(r.Context(), middleware.DeviceKey, device)
+	ctx = context.WithValue(ctx, middleware.ClearanceKey, device.Clearance)
+	return r.WithContext(ctx)
+}
+
+func TestProxyStripsCredentialsAndSignsIdentity(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := &identity.Verifier{Keys: []ed25519.PublicKey{pub}}
+
+	credentials := []string{"X-Device-ID", "X-Clearance", "X-Layer", "X-Token-ID", "X-Forwarded-Groups", "X-Forwarded-Claims"}
+	target := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range credentials {
+			if value := r.Header.Get(name); value != "" {
+				t.Errorf("%s forwarded to the upstream: %q", name, value)
+			}
+		}
+		if r.URL.Path != "/orders" {
+			t.Errorf("prefix not stripped: %s", r.URL.Path)
+		}
+		id, err := verifier.Verify(r)
+		if err != nil {
+			t.Errorf("forwarded identity did not verify: %v", err)
+		} else if id.DeviceID != 4 || id.Clearance != models.ClearanceLevel7 || id.Layer != models.LayerControl {
+			t.Errorf("unexpected forwarded identity %+v", id)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"item": 1}` {
+			t.Errorf("body not forwarded intact: %q", body)
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	recorder := &eventRecorder{}
+	auditLogger := audit.NewLogger()
+	auditLogger.AddWriter(recorder)
+	handler := ProxyHandler(testLogger(), Upstream{
+		Prefix:          "/svc/inventory/",
+		Target:          target,
+		StripPrefix:     true,
+		ForwardIdentity: true,
+		StripHeaders:    []string{"X-Forwarded-Groups", "X-Forwarded-Claims"},
+	}, identity.NewSigner(priv), auditLogger)
+
+	req := httptest.NewRequest(http.MethodPost, "/svc/inventory/orders", strings.NewReader(`{"item": 1}`))
+	for _, name := range credentials {
+		req.Header.Set(name, "forged")
+	}
+	req.Header.Set(identity.HeaderDeviceID, "9")
+	req.Header.Set(identity.HeaderClearance, "09090909")
+	req = withIdentity(req, &models.Device{ID: 4, Layer: models.LayerControl, Clearance: models.ClearanceLevel7})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	if len(recorder.events) != 1 || recorder.events[0].Action != ProxyAuditAction ||
+		recorder.events[0].Actor != "device-4" || recorder.events[0].StatusCode != http.StatusCreated {
+		t.Errorf("expected one proxy.forward event, got %+v", recorder.events)
+	}
+}
+
+func TestProxyRejectsOversizedSignedBody(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("oversized body reached the upstream")
+	})
+	handler := ProxyHandler(testLogger(), Upstream{Prefix: "/svc/", Target: target, ForwardIdentity: true}, identity.NewSigner(priv), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/svc/upload", strings.NewReader(strings.Repeat("x", MaxSignedBodyBytes+1)))
+	req = withIdentity(req, &models.Device{ID: 4, Clearance: models.ClearanceLevel7})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", rec.Code)
+	}
+}
+
+func TestProxyRetries(t *testing.T) {
+	defer func(backoff time.Duration) { upstreamRetryBackoff = backoff }(upstreamRetryBackoff)
+	upstreamRetryBackoff = time.Millisecond
+
+	var calls atomic.Int32
+	target := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := ProxyHandler(testLogger(), Upstream{Prefix: "/svc/", Target: target, Retries: 2}, nil, nil)
+
+	// Idempotent requests are retried until the upstream recovers
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/svc/status", nil))
+	if rec.Code != http.StatusOK || calls.Load() != 3 {
+		t.Fatalf("expected 200 after 3 attempts, got %d after %d", rec.Code, calls.Load())
+	}
+
+	// Requests with a body are sent once
+	calls.Store(0)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/svc/orders", strings.NewReader("{}")))
+	if rec.Code != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Errorf("expected the upstream's 503 after 1 attempt, got %d after %d", rec.Code, calls.Load())
+	}
+
+	// An exhausted retry budget stops retries
+	calls.Store(0)
+	budget := breaker.NewRetryBudget(0, 0)
+	handler = ProxyHandler(testLogger(), Upstream{Prefix: "/svc/", Target: target, Retries: 2, RetryBudget: budget}, nil, nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/svc/status", nil))
+	if rec.Code != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Errorf("expected no retries without budget, got %d after %d attempts", rec.Code, calls.Load())
+	}
+}
+
+func TestProxyBreaker(t *testing.T) {
+	var calls atomic.Int32
+	target := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	circuit := breaker.New("upstream /svc/", 2, time.Minute)
+	handler := ProxyHandler(testLogger(), Upstream{Prefix: "/svc/", Target: target, Breaker: circuit}, nil, nil)
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/svc/status", nil))
+		if rec.Code != http.StatusBadGateway {
+			t.Fatalf("request %d: expected the upstream's 502, got %d", i, rec.Code)
+		}
+	}
+
+	// The open circuit fails fast without reaching the upstream
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/svc/status", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After, got %d %v", rec.Code, rec.Header())
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected the open circuit to spare the upstream, got %d calls", calls.Load())
+	}
+}
@@ -0,0 +1,69 @@
+// Renders the releases in /code.json; kept in a separate file so the page works
+// under a Content-Security-Policy without 'unsafe-inline'
+(function () {
+  "use strict";
+
+  var list = document.getElementById("releases");
+  var status = document.getElementById("status");
+  var filter = document.getElementById("filter");
+  var releases = [];
+
+  function text(tag, className, value) {
+    var el = document.createElement(tag);
+    if (className) {
+      el.className = className;
+    }
+    el.textContent = value;
+    return el;
+  }
+
+  function render() {
+    var query = filter.value.toLowerCase();
+    list.textContent = "";
+    var shown = 0;
+    releases.forEach(function (release) {
+      var haystack = [release.name, release.description].concat(release.tags || [], release.languages || []).join(" ").toLowerCase();
+      if (query && haystack.indexOf(query) === -1) {
+        return;
+      }
+      var item = document.createElement("li");
+      var link = document.createElement("a");
+      link.href = release.repositoryURL;
+      link.textContent = release.name;
+      item.appendChild(link);
+      if (release.description) {
+        item.appendChild(text("p", "", release.description));
+      }
+      var meta = (release.languages || []).join(", ");
+      if (release.status) {
+        meta = meta ? release.status + " · " + meta : release.status;
+      }
+      item.appendChild(text("p", "meta", meta));
+      list.appendChild(item);
+      shown++;
+    });
+    status.textContent = shown + " of " + releases.length + " projects";
+  }
+
+  fetch("/code.json")
+    .then(function (resp) {
+      if (!resp.ok) {
+        throw new Error(resp.status + " " + resp.statusText);
+      }
+      return resp.json();
+    })
+    .then(function (inventory) {
+      if (inventory.agency) {
+        document.getElementById("agency").textContent = inventory.agency + " Open Source Software";
+      }
+      releases = (inventory.releases || []).slice().sort(function (a, b) {
+        return a.name.localeCompare(b.name);
+      });
+      render();
+    })
+    .catch(function (err) {
+      status.textContent = "The inventory could not be loaded (" + err.message + ").";
+    });
+
+  filter.addEventListener("input", render);
+})();
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+)
+
+// StaticRoute is the normalized route name of files served from the landing page site
+const StaticRoute = "/{file}"
+
+// DefaultStaticMaxAge is how long browsers may cache static assets other than HTML
+const DefaultStaticMaxAge = time.Hour
+
+// DefaultContentSecurityPolicy only lets pages load resources from the server itself,
+// which is enough for a landing page that renders /code.json
+const DefaultContentSecurityPolicy = "default-src 'self'; img-src 'self' data:; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'"
+
+//go:embed site
+var siteFiles embed.FS
+
+// EmbeddedSite returns the built-in landing page, which lists the releases in /code.json
+func EmbeddedSite() fs.FS {
+	site, err := fs.Sub(siteFiles, "site")
+	if err != nil {
+		panic(fmt.Sprintf("embedded site is missing: %v", err))
+	}
+	return site
+}
+
+// StaticOptions configures static file serving
+type StaticOptions struct {
+	MaxAge                time.Duration // Cache lifetime of non-HTML assets; zero uses DefaultStaticMaxAge
+	ContentSecurityPolicy string        // Empty uses DefaultContentSecurityPolicy
+}
+
+// StaticHandler serves files from fsys, e.g. an agency's open-source landing page.
+// HTML is revalidated on every use so edits show up immediately, other assets are
+// cached for opts.MaxAge, and every response carries the Content-Security-Policy.
+// Directory listings and dotfiles are never served.
+func StaticHandler(logger *logging.Logger, fsys fs.FS, opts StaticOptions) http.HandlerFunc {
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = DefaultStaticMaxAge
+	}
+	if opts.ContentSecurityPolicy == "" {
+		opts.ContentSecurityPolicy = DefaultContentSecurityPolicy
+	}
+	files := http.FileServer(http.FS(fsys))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowReadOnly(w, r) {
+			return
+		}
+
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+		if name == "" {
+			name = "."
+		}
+		if hiddenPath(name) {
+			http.NotFound(w, r)
+			return
+		}
+
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		html := strings.HasSuffix(name, ".html")
+		if info.IsDir() {
+			if _, err := fs.Stat(fsys, path.Join(name, "index.html")); err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			html = true
+		}
+
+		w.Header().Set("Content-Security-Policy", opts.ContentSecurityPolicy)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		if html {
+			w.Header().Set("Cache-Control", "no-cache")
+		} else {
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(opts.MaxAge.Seconds())))
+		}
+
+		logger.DebugContext(r.Context(), "static file", map[string]interface{}{
+			"path": r.URL.Path,
+		})
+		files.ServeHTTP(w, r)
+	}
+}
+
+// StaticPaths lists the request paths StaticHandler serves from fsys: every file,
+// and every directory holding an index.html (with and without the trailing slash)
+func StaticPaths(fsys fs.FS) ([]string, error) {
+	var paths []string
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." && hiddenPath(name) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		paths = append(paths, "/"+name)
+		if path.Base(name) == "index.html" {
+			dir := path.Dir(name)
+			if dir == "." {
+				paths = append(paths, "/")
+			} else {
+				paths = append(paths, "/"+dir, "/"+dir+"/")
+			}
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// hiddenPath reports whether any element of a slash-separated path starts with a dot
+func hiddenPath(name string) bool {
+	for _, elem := range strings.Split(name, "/") {
+		if strings.HasPrefix(elem, ".") && elem != "." {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxRequestBodyBytes bounds JSON request bodies
+const MaxRequestBodyBytes = 1 << 20
+
+// Violation is one request field that failed validation
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Validator is implemented by request types with checks that struct tags cannot
+// express, such as cross-field rules. It runs after the tag checks.
+type Validator interface {
+	Validate() []Violation
+}
+
+// decodeJSON reads a JSON request body into v, which must point to a struct, and
+// validates it against its `validate` tags and Validator. It writes the error
+// response and returns false when the body is unreadable (400, 413, 415) or
+// invalid (422, listing every violation, including members the struct does not
+// declare).
+//
+// Supported tags, comma-separated:
+//
+//	required      the field must be present and non-zero
+//	min=N, max=N  bounds for numbers, or for the length of strings, slices and maps
+//	oneof=a b c   non-empty strings must be one of the listed values
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		respondError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxRequestBodyBytes))
+	var sizeErr *http.MaxBytesError
+	if errors.As(err, &sizeErr) {
+		respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", MaxRequestBodyBytes))
+		return false
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "failed to read request body")
+		return false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if err := dec.Decode(v); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.Is(err, io.EOF):
+			respondError(w, http.StatusBadRequest, "request body is required")
+		case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+			respondError(w, http.StatusBadRequest, "malformed JSON: "+err.Error())
+		case errors.As(err, &typeErr):
+			respondViolations(w, []Violation{{Field: typeErr.Field, Message: typeMessage(typeErr)}})
+		default:
+			respondError(w, http.StatusBadRequest, "malformed JSON: "+err.Error())
+		}
+		return false
+	}
+	if dec.More() {
+		respondError(w, http.StatusBadRequest, "malformed JSON: unexpected data after the object")
+		return false
+	}
+
+	violations := unknownFields(body, reflect.TypeOf(v).Elem())
+	violations = append(violations, validateStruct(reflect.ValueOf(v).Elem(), "")...)
+	if validator, ok := v.(Validator); ok {
+		violations = append(violations, validator.Validate()...)
+	}
+	if len(violations) > 0 {
+		respondViolations(w, violations)
+		return false
+	}
+	return true
+}
+
+// respondViolations writes a 422 response listing every violation
+func respondViolations(w http.ResponseWriter, violations []Violation) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":      "validation failed",
+		"violations": violations,
+	})
+}
+
+// unknownFields reports top-level members of a JSON object that the struct type
+// does not declare, so typos are rejected instead of silently ignored
+func unknownFields(body []byte, t reflect.Type) []Violation {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil {
+		return nil
+	}
+
+	known := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			known[strings.ToLower(jsonName(t.Field(i)))] = true
+		}
+	}
+
+	var violations []Violation
+	for name := range members {
+		// encoding/json matches member names case-insensitively
+		if !known[strings.ToLower(name)] {
+			violations = append(violations, Violation{Field: name, Message: "unknown field"})
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool { return violations[i].Field < violations[j].Field })
+	return violations
+}
+
+// validateStruct checks the `validate` tags of a struct's fields, descending into
+// nested structs. Fields are reported by their JSON names.
+func validateStruct(v reflect.Value, prefix string) []Violation {
+	var violations []Violation
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := jsonName(field)
+		if name == "-" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		value := v.Field(i)
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			if message := checkRule(value, rule); message != "" {
+				violations = append(violations, Violation{Field: name, Message: message})
+				break
+			}
+		}
+
+		for value.Kind() == reflect.Ptr && !value.IsNil() {
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.Struct {
+			violations = append(violations, validateStruct(value, name)...)
+		}
+	}
+	return violations
+}
+
+// checkRule returns why value breaks a single tag rule, or "" if it doesn't
+func checkRule(value reflect.Value, rule string) string {
+	name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+	if name == "" {
+		return ""
+	}
+	if name == "required" {
+		if value.IsZero() {
+			return "is required"
+		}
+		return ""
+	}
+
+	// Optional fields that are absent pass every other rule
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	switch name {
+	case "min", "max":
+		bound, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			panic(fmt.Sprintf("invalid %s rule %q", name, rule))
+		}
+		n, unit := measure(value)
+		if name == "min" && n < bound {
+			if unit != "" {
+				return fmt.Sprintf("must have at least %s %s", arg, unit)
+			}
+			return "must be at least " + arg
+		}
+		if name == "max" && n > bound {
+			if unit != "" {
+				return fmt.Sprintf("must have at most %s %s", arg, unit)
+			}
+			return "must be at most " + arg
+		}
+	case "oneof":
+		// An empty string is an omitted value; "required" decides whether that is allowed
+		if value.String() == "" {
+			return ""
+		}
+		allowed := strings.Fields(arg)
+		for _, option := range allowed {
+			if value.String() == option {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(allowed, ", ")
+	default:
+		panic(fmt.Sprintf("unknown validation rule %q", rule))
+	}
+	return ""
+}
+
+// measure returns a number's value, or the length of a string, slice or map with
+// the unit it is counted in
+func measure(value reflect.Value) (float64, string) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), ""
+	case reflect.String:
+		return float64(len([]rune(value.String()))), "characters"
+	case reflect.Slice, reflect.Array:
+		return float64(value.Len()), "elements"
+	case reflect.Map:
+		return float64(value.Len()), "entries"
+	}
+	panic(fmt.Sprintf("min/max rules do not apply to %s", value.Kind()))
+}
+
+// jsonName returns the name a struct field is encoded under
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// typeMessage describes a value that could not be decoded into its field
+func typeMessage(err *json.UnmarshalTypeError) string {
+	want := jsonType(err.Type)
+	if want == "number" && strings.HasPrefix(err.Value, "number") {
+		return "is out of range"
+	}
+	return "must be a JSON " + want
+}
+
+// jsonType names the JSON type a Go type is decoded from
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Ptr:
+		return jsonType(t.Elem())
+	}
+	return "number"
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type validatedAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type validatedRequest struct {
+	Name    string            `json:"name" validate:"required,max=5"`
+	Mode    string            `json:"mode,omitempty" validate:"oneof=fast slow"`
+	Count   int               `json:"count" validate:"min=1,max=10"`
+	Ratio   *float64          `json:"ratio,omitempty" validate:"min=0.5"`
+	Tags    []string          `json:"tags,omitempty" validate:"max=2"`
+	Labels  map[string]string `json:"labels,omitempty" validate:"max=1"`
+	Home    *validatedAddress `json:"home,omitempty"`
+	Work    validatedAddress  `json:"work"`
+	Ignored string            `json:"-"`
+	Renamed string            // Encoded under its Go name
+	Pair    [2]int            `json:"pair"`
+	UID     uint8             `json:"uid"`
+}
+
+// Validate reports a cross-field rule
+func (r *validatedRequest) Validate() []Violation {
+	if r.Mode == "fast" && r.Count > 5 {
+		return []Violation{{Field: "count", Message: "must be at most 5 in fast mode"}}
+	}
+	return nil
+}
+
+func TestCheckRule(t *testing.T) {
+	ratio := 0.25
+	tests := []struct {
+		name  string
+		value interface{}
+		rule  string
+		want  string
+	}{
+		{"required string", "", "required", "is required"},
+		{"required present", "x", "required", ""},
+		{"required slice", []string(nil), "required", "is required"},
+		{"required empty slice", []string{}, "required", ""},
+		{"required zero number", 0, "required", "is required"},
+		{"required nil pointer", (*int)(nil), "required", "is required"},
+		{"empty rule", "", "", ""},
+		{"min number", 0, "min=1", "must be at least 1"},
+		{"min number met", 1, "min=1", ""},
+		{"max number", 11, "max=10", "must be at most 10"},
+		{"min unsigned", uint8(0), "min=1", "must be at least 1"},
+		{"min float", 0.25, "min=0.5", "must be at least 0.5"},
+		{"min string", "ab", "min=3", "must have at least 3 characters"},
+		{"max string counts runes", "héé", "max=3", ""},
+		{"max string", "abcdef", "max=5", "must have at most 5 characters"},
+		{"max slice", []int{1, 2, 3}, "max=2", "must have at most 2 elements"},
+		{"min array", [1]int{}, "min=2", "must have at least 2 elements"},
+		{"max map", map[string]int{"a": 1, "b": 2}, "max=1", "must have at most 1 entries"},
+		{"absent pointer skips bounds", (*float64)(nil), "min=0.5", ""},
+		{"present pointer", &ratio, "min=0.5", "must be at least 0.5"},
+		{"oneof", "medium", "oneof=fast slow", "must be one of fast, slow"},
+		{"oneof match", "slow", "oneof=fast slow", ""},
+		{"oneof omitted", "", "oneof=fast slow", ""},
+		{"oneof is case-sensitive", "Fast", "oneof=fast slow", "must be one of fast, slow"},
+		{"oneof with spaces around the rule", "fast", " oneof=fast slow ", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkRule(reflect.ValueOf(tt.value), tt.rule); got != tt.want {
+				t.Errorf("checkRule(%v, %q) = %q, want %q", tt.value, tt.rule, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckRulePanicsOnBadTags(t *testing.T) {
+	for _, tt := range []struct {
+		value interface{}
+		rule  string
+	}{
+		{"x", "email"},
+		{1, "min=one"},
+		{true, "max=1"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected checkRule(%v, %q) to panic", tt.value, tt.rule)
+				}
+			}()
+			checkRule(reflect.ValueOf(tt.value), tt.rule)
+		}()
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	valid := `{"name": "ok", "count": 3, "work": {"city": "Fort Meade"}}`
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantError   string      // Error message of a non-422 failure
+		want        []Violation // Violations of a 422
+	}{
+		{"valid", "application/json", valid, http.StatusOK, "", nil},
+		{"content type with parameters", "application/json; charset=utf-8", valid, http.StatusOK, "", nil},
+		{"Go field name matches case-insensitively", "application/json", `{"name": "ok", "count": 3, "work": {"city": "x"}, "renamed": "y"}`, http.StatusOK, "", nil},
+		{"wrong content type", "text/plain", valid, http.StatusUnsupportedMediaType, "Content-Type must be application/json", nil},
+		{"missing content type", "", valid, http.StatusUnsupportedMediaType, "Content-Type must be application/json", nil},
+		{"empty body", "application/json", "", http.StatusBadRequest, "request body is required", nil},
+		{"malformed", "application/json", `{"name": `, http.StatusBadRequest, "malformed JSON: unexpected EOF", nil},
+		{"syntax error", "application/json", `{"name" "ok"}`, http.StatusBadRequest, "malformed JSON: invalid character", nil},
+		{"trailing data", "application/json", valid + ` {}`, http.StatusBadRequest, "malformed JSON: unexpected data after the object", nil},
+		{"not an object", "application/json", `[1]`, http.StatusUnprocessableEntity, "", []Violation{{Field: "", Message: "must be a JSON object"}}},
+		{"wrong type", "application/json", `{"name": 5}`, http.StatusUnprocessableEntity, "", []Violation{{Field: "name", Message: "must be a JSON string"}}},
+		{"number out of range", "application/json", `{"uid": 300}`, http.StatusUnprocessableEntity, "", []Violation{{Field: "uid", Message: "is out of range"}}},
+		{"unknown fields", "application/json", `{"name": "ok", "count": 3, "work": {"city": "x"}, "zeta": 1, "alpha": 2, "Ignored": "x"}`, http.StatusUnprocessableEntity, "", []Violation{
+			{Field: "Ignored", Message: "unknown field"},
+			{Field: "alpha", Message: "unknown field"},
+			{Field: "zeta", Message: "unknown field"},
+		}},
+		{"every violation listed", "application/json", `{"name": "toolong", "mode": "medium", "count": 0, "ratio": 0.1, "tags": ["a", "b", "c"], "labels": {"a": "1", "b": "2"}, "home": {}}`, http.StatusUnprocessableEntity, "", []Violation{
+			{Field: "name", Message: "must have at most 5 characters"},
+			{Field: "mode", Message: "must be one of fast, slow"},
+			{Field: "count", Message: "must be at least 1"},
+			{Field: "ratio", Message: "must be at least 0.5"},
+			{Field: "tags", Message: "must have at most 2 elements"},
+			{Field: "labels", Message: "must have at most 1 entries"},
+			{Field: "home.city", Message: "is required"},
+			{Field: "work.city", Message: "is required"},
+		}},
+		{"first failing rule only", "application/json", `{"count": 3, "work": {"city": "x"}}`, http.StatusUnprocessableEntity, "", []Violation{{Field: "name", Message: "is required"}}},
+		{"Validator runs after the tags", "application/json", `{"name": "ok", "mode": "fast", "count": 8, "work": {"city": "x"}}`, http.StatusUnprocessableEntity, "", []Violation{{Field: "count", Message: "must be at most 5 in fast mode"}}},
+		{"oversized body", "application/json", `{"name": "` + strings.Repeat("a", MaxRequestBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, "request body exceeds 1048576 bytes", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			var v validatedRequest
+			ok := decodeJSON(rec, req, &v)
+
+			if tt.wantStatus == http.StatusOK {
+				if !ok {
+					t.Fatalf("expected the body to decode, got %d: %s", rec.Code, rec.Body)
+				}
+				return
+			}
+			if ok || rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %v with %d: %s", tt.wantStatus, ok, rec.Code, rec.Body)
+			}
+			if rec.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("unexpected Content-Type %q", rec.Header().Get("Content-Type"))
+			}
+
+			// The 422 payload is exactly {"error": ..., "violations": [{"field", "message"}]}
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantStatus != http.StatusUnprocessableEntity {
+				var message string
+				json.Unmarshal(body["error"], &message)
+				if !strings.HasPrefix(message, tt.wantError) {
+					t.Errorf("error = %q, want %q", message, tt.wantError)
+				}
+				return
+			}
+			if len(body) != 2 || string(body["error"]) != `"validation failed"` {
+				t.Errorf("unexpected 422 payload %s", rec.Body)
+			}
+			var violations []map[string]string
+			if err := json.Unmarshal(body["violations"], &violations); err != nil {
+				t.Fatal(err)
+			}
+			got := make([]Violation, len(violations))
+			for i, violation := range violations {
+				if len(violation) != 2 {
+					t.Errorf("violation %d has members %v, want field and message", i, violation)
+				}
+				got[i] = Violation{Field: violation["field"], Message: violation["message"]}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/NSACodeGov/CodeGov/internal/buildinfo"
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+)
+
+// VersionPath reports how the server was built and which subsystems are enabled
+const VersionPath = "/api/version"
+
+// VersionHandler serves build information. The subsystem map is fixed at startup,
+// so it is captured once rather than rebuilt per request.
+func VersionHandler(logger *logging.Logger, subsystems map[string]bool) http.HandlerFunc {
+	info := buildinfo.Get()
+	info.Subsystems = subsystems
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowReadOnly(w, r) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(info)
+	}
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/pkg/identity"
+)
+
+// auditSecretHeaders carry tokens or signatures that, unlike the standard credential
+// headers audit.RedactHeader covers, are specific to gogovcode
+var auditSecretHeaders = []string{"X-Token-ID", identity.HeaderSignature}
+
+// redactHeader reports whether a header must be omitted from audit events
+func (c *ClearanceConfig) redactHeader(name string) bool {
+	if audit.RedactHeader(name) {
+		return true
+	}
+	redacted := append(append([]string{}, auditSecretHeaders...), c.AuditRedactHeaders...)
+	if c.IdentityMapper != nil {
+		redacted = append(redacted, c.IdentityMapper.Headers()...)
+	}
+	for _, header := range redacted {
+		if strings.EqualFold(header, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditLevel resolves the audit detail level for a request. A policy
+// obligation wins over the route map, which wins over the default.
+func (c *ClearanceConfig) auditLevel(path string, obligation audit.Level) audit.Level {
+	if obligation != "" {
+		return obligation
+	}
+
+	level := c.DefaultAuditLevel
+	longest := -1
+	for pattern, l := range c.AuditLevels {
+		prefix := strings.TrimSuffix(pattern, "*")
+		matched := pattern == path || (strings.HasSuffix(pattern, "*") && strings.HasPrefix(path, prefix))
+		if matched && len(prefix) > longest {
+			level = l
+			longest = len(prefix)
+		}
+	}
+
+	if level == "" {
+		return audit.DefaultLevel
+	}
+	return level
+}
+
+// denialAuditLevel returns the level for a denied request's event. Denials are always
+// audited, so LevelNone records the decision only.
+func denialAuditLevel(level audit.Level) audit.Level {
+	if level == audit.LevelNone {
+		return audit.LevelDecision
+	}
+	return level
+}
+
+// applyAuditDetail fills the request-derived fields of an event according to level
+func (c *ClearanceConfig) applyAuditDetail(event *audit.AuditEvent, r *http.Request, level audit.Level) {
+	// Query strings may carry sensitive parameters, so only record them on request
+	event.Resource = r.URL.Path
+
+	if !level.Includes(audit.LevelHeaders) {
+		return
+	}
+
+	event.Resource = r.URL.String()
+	if event.AdditionalData == nil {
+		event.AdditionalData = make(map[string]interface{})
+	}
+
+	headers := make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		if c.redactHeader(name) {
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	event.AdditionalData["headers"] = headers
+
+	if !level.Includes(audit.LevelBody) || r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+
+	// Hash the body and restore it so the handler can still read it
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		event.AdditionalData["body_error"] = err.Error()
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	event.AdditionalData["body_sha256"] = hex.EncodeToString(sum[:])
+	event.AdditionalData["body_bytes"] = len(body)
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/idmap"
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/internal/policy"
+	"github.com/NSACodeGov/CodeGov/pkg/identity"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+func TestApplyAuditDetailRedactsCredentials(t *testing.T) {
+	mapper, err := idmap.New(idmap.Config{
+		ClaimsHeader: "X-Forwarded-Access-Token",
+		Rules:        []idmap.Rule{{Match: "claim:department=cyber", ClearanceLevel: 5}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ClearanceConfig{IdentityMapper: mapper, AuditRedactHeaders: []string{"x-api-key"}}
+
+	req := httptest.NewRequest("GET", "/api/data?q=1", nil)
+	secrets := map[string]string{
+		"Authorization":            "Bearer secret",
+		"Cookie":                   "session=secret",
+		"X-Token-ID":               "32771",
+		identity.HeaderSignature:   "c2lnbmF0dXJl",
+		"X-Forwarded-Access-Token": "Bearer eyJhbGciOiJub25lIn0.e30.",
+		"X-Api-Key":                "secret",
+	}
+	for name, value := range secrets {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("X-Request-ID", "req-1")
+
+	event := &audit.AuditEvent{}
+	config.applyAuditDetail(event, req, audit.LevelHeaders)
+
+	headers, ok := event.AdditionalData["headers"].(map[string]string)
+	if !ok {
+		t.Fatalf("expected headers in the event, got %v", event.AdditionalData)
+	}
+	for name := range secrets {
+		if value, found := headers[http.CanonicalHeaderKey(name)]; found {
+			t.Errorf("header %s leaked into the audit event: %q", name, value)
+		}
+	}
+	if headers["X-Request-Id"] != "req-1" {
+		t.Errorf("expected other headers to be kept, got %v", headers)
+	}
+	if event.Resource != "/api/data?q=1" {
+		t.Errorf("unexpected resource %q", event.Resource)
+	}
+
+	// Below the headers level nothing is copied
+	event = &audit.AuditEvent{}
+	config.applyAuditDetail(event, req, audit.LevelDecision)
+	if event.AdditionalData != nil || event.Resource != "/api/data" {
+		t.Errorf("unexpected decision-level event %+v", event)
+	}
+}
+
+func TestDenialsIgnoreAuditLevelNone(t *testing.T) {
+	registry := models.NewDeviceRegistry()
+	if err := registry.Register(&models.Device{ID: 3, Layer: models.LayerControl, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel7}); err != nil {
+		t.Fatal(err)
+	}
+	engine := policy.NewEngine(registry)
+	if err := engine.LoadFromJSON([]byte(`{"version": "1.0", "rules": [
+		{"id": "public", "effect": "allow", "routes": ["/api/public"], "methods": ["*"], "audit_level": "none", "priority": 20},
+		{"id": "secret", "effect": "deny", "routes": ["/api/secret"], "methods": ["*"], "audit_level": "none", "priority": 20}
+	]}`)); err != nil {
+		t.Fatal(err)
+	}
+	logger := logging.New("test", "test", "error", "json")
+	logger.SetOutput(io.Discard)
+
+	recorder := &eventRecorder{}
+	auditLogger := audit.NewLogger()
+	auditLogger.AddWriter(recorder)
+	handler := Clearance(&ClearanceConfig{
+		PolicyEngine:      engine,
+		AuditLogger:       auditLogger,
+		Logger:            logger,
+		DeviceRegistry:    registry,
+		Enabled:           true,
+		DefaultAuditLevel: audit.LevelNone,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(target, deviceID string) int {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("X-Device-ID", deviceID)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// An allowed request at level none is not audited
+	if code := serve("/api/public", "3"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if len(recorder.events) != 0 {
+		t.Fatalf("expected no audit event, got %+v", recorder.events)
+	}
+
+	// A policy denial and an authentication failure are, at the decision level
+	if code := serve("/api/secret?key=1", "3"); code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", code)
+	}
+	if code := serve("/api/public", "ZZ"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", code)
+	}
+	if len(recorder.events) != 2 {
+		t.Fatalf("expected 2 audit events, got %+v", recorder.events)
+	}
+	for i, want := range []struct {
+		resource string
+		status   int
+	}{{"/api/secret", http.StatusForbidden}, {"/api/public", http.StatusUnauthorized}} {
+		event := recorder.events[i]
+		if event.Decision != audit.DecisionDeny || event.Resource != want.resource || event.StatusCode != want.status || event.AdditionalData != nil {
+			t.Errorf("event %d: expected a decision-level denial of %s, got %+v", i, want.resource, event)
+		}
+	}
+}
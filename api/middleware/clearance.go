@@ -3,25 +3,107 @@ package middleware
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/idmap"
 	"github.com/NSACodeGov/CodeGov/internal/logging"
 	"github.com/NSACodeGov/CodeGov/internal/policy"
 	"github.com/NSACodeGov/CodeGov/pkg/models"
 )
 
+// Audit actions recorded by the lockout
+const (
+	LockoutAuditAction = "auth.lockout"
+	UnblockAuditAction = "auth.unblock"
+)
+
+// Authentication failure codes returned in 401 responses
+const (
+	DenyInvalidDeviceID     = "invalid_device_id"
+	DenyInvalidClearance    = "invalid_clearance"
+	DenyInvalidLayer        = "invalid_layer"
+	DenyInvalidToken        = "invalid_token"
+	DenyDeviceNotRegistered = "device_not_registered"
+	DenyInvalidIdentity     = "invalid_identity" // Rejected by an identity provider without a more specific code
+	DenyLayerMismatch       = "layer_mismatch"   // The claimed or registered layer is not the listener's
+)
+
+// authHints tells clients how to fix each authentication failure
+var authHints = map[string]string{
+	DenyInvalidDeviceID:     "X-Device-ID must be a decimal device ID",
+	DenyInvalidClearance:    "X-Clearance must be a hex clearance from 02020202 to 09090909",
+	DenyInvalidLayer:        "X-Layer must be one of data, transport, control or application",
+	DenyInvalidToken:        "X-Token-ID must be a decimal token ID",
+	DenyDeviceNotRegistered: "the device must be registered (or restored if it was deleted) before it can authenticate",
+	DenyInvalidIdentity:     "the request's credentials were not accepted",
+	DenyLayerMismatch:       "connect to the listener of the device's layer; X-Layer must match it or be omitted",
+}
+
+// DenyResponse is the JSON body of 401 and 403 responses. Code is one of the Deny*
+// constants here or in the policy package; the remaining fields say what the
+// request lacked.
+type DenyResponse struct {
+	Error                  string         `json:"error"`
+	Reason                 string         `json:"reason"`
+	Code                   string         `json:"code"`
+	Hint                   string         `json:"hint,omitempty"`
+	RuleID                 string         `json:"rule_id,omitempty"`
+	RequiredClearance      string         `json:"required_clearance,omitempty"` // Hex, as sent in X-Clearance
+	RequiredClearanceLevel int            `json:"required_clearance_level,omitempty"`
+	AllowedLayers          []models.Layer `json:"allowed_layers,omitempty"`
+	DeviceRequired         bool           `json:"device_required,omitempty"`
+	DeviceRegistered       bool           `json:"device_registered"`
+}
+
+// newPolicyDenyResponse builds the 403 body for a policy deny decision
+func newPolicyDenyResponse(decision *policy.Decision, registered bool) DenyResponse {
+	resp := DenyResponse{
+		Error:            "access denied",
+		Reason:           decision.Reason,
+		RuleID:           decision.RuleID,
+		DeviceRegistered: registered,
+	}
+	if rem := decision.Remediation; rem != nil {
+		resp.Code = rem.Code
+		resp.Hint = rem.Hint
+		resp.AllowedLayers = rem.AllowedLayers
+		resp.DeviceRequired = rem.DeviceRequired
+		if rem.RequiredClearance > 0 {
+			resp.RequiredClearance = fmt.Sprintf("%08X", uint32(rem.RequiredClearance))
+			resp.RequiredClearanceLevel = rem.RequiredClearance.Level()
+		}
+	}
+	return resp
+}
+
 // Context keys for clearance data
 type clearanceKey string
 
 const (
 	ClearanceKey clearanceKey = "clearance"
 	DeviceKey    clearanceKey = "device"
+	LayerKey     clearanceKey = "layer"
+	TokenKey     clearanceKey = "token"
+
+	// ListenerLayerKey holds the layer of the listener a request arrived on
+	ListenerLayerKey clearanceKey = "listener_layer"
+
 )
 
+// requestToken is the registered token a request authenticated with
+type requestToken struct {
+	id     uint16
+	offset models.TokenOffset
+}
+
 // ClearanceConfig holds configuration for clearance middleware
 type ClearanceConfig struct {
 	PolicyEngine   *policy.Engine
@@ -29,6 +111,81 @@ type ClearanceConfig struct {
 	Logger         *logging.Logger
 	DeviceRegistry *models.DeviceRegistry
 	Enabled        bool
+
+	// AnonymousRoutes are served without identity extraction or policy evaluation
+	AnonymousRoutes *AnonymousRoutes
+
+	// AuditLevels maps route patterns (trailing "*" for prefixes) to audit detail levels.
+	// Policy rule obligations take precedence; the longest matching pattern wins.
+	AuditLevels map[string]audit.Level
+
+	// DefaultAuditLevel applies when neither policy nor AuditLevels set a level
+	DefaultAuditLevel audit.Level
+
+	// AuditRedactHeaders are omitted from events at the headers level, in addition to
+	// credential headers, the token ID, identity signatures and IdentityMapper's headers
+	AuditRedactHeaders []string
+
+	// DeviceLimiter caps concurrent in-flight requests per device; nil disables the cap
+	DeviceLimiter *DeviceLimiter
+
+	// Lockout blocks source IPs and devices after repeated authentication failures; nil disables it
+	Lockout *Lockout
+
+	// IdentityMapper derives clearance and layer from external identity attributes for
+	// requests that do not identify a device; nil disables the mapping
+	IdentityMapper *idmap.Mapper
+
+	// IdentityProviders establish each request's identity in order; nil uses
+	// DefaultIdentityProviders. See NewIdentityProviders.
+	IdentityProviders []IdentityProvider
+}
+
+// AnonymousRoutes is the set of routes declared as anonymous at registration time
+type AnonymousRoutes struct {
+	mu       sync.RWMutex
+	exact    map[string]bool
+	prefixes []string
+}
+
+// NewAnonymousRoutes creates an empty anonymous route set
+func NewAnonymousRoutes() *AnonymousRoutes {
+	return &AnonymousRoutes{
+		exact: make(map[string]bool),
+	}
+}
+
+// Add declares a route as anonymous. A trailing "*" marks a prefix match;
+// all other patterns match the exact path only.
+func (a *AnonymousRoutes) Add(pattern string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if strings.HasSuffix(pattern, "*") {
+		a.prefixes = append(a.prefixes, strings.TrimSuffix(pattern, "*"))
+		return
+	}
+	a.exact[pattern] = true
+}
+
+// Matches reports whether a request path was declared anonymous
+func (a *AnonymousRoutes) Matches(path string) bool {
+	if a == nil {
+		return false
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.exact[path] {
+		return true
+	}
+	for _, prefix := range a.prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
 // Clearance middleware extracts and validates clearance information
@@ -40,89 +197,52 @@ func Clearance(config *ClearanceConfig) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Extract clearance data from headers
-			deviceIDStr := r.Header.Get("X-Device-ID")
-			layerStr := r.Header.Get("X-Layer")
-			clearanceStr := r.Header.Get("X-Clearance")
-			tokenIDStr := r.Header.Get("X-Token-ID")
-
-			// Parse device ID
-			var deviceID uint16
-			if deviceIDStr != "" {
-				id, err := strconv.ParseUint(deviceIDStr, 10, 16)
-				if err != nil {
-					config.Logger.WarnContext(r.Context(), "invalid device ID", map[string]interface{}{
-						"device_id": deviceIDStr,
-						"error":     err.Error(),
-					})
-					respondUnauthorized(w, r, config, "invalid device ID")
-					return
-				}
-				deviceID = uint16(id)
+			// Anonymous routes skip identity extraction entirely
+			if config.AnonymousRoutes.Matches(r.URL.Path) {
+				config.Logger.InfoContext(r.Context(), "anonymous access", map[string]interface{}{
+					"method": r.Method,
+					"path":   r.URL.Path,
+					"remote": r.RemoteAddr,
+				})
+				next.ServeHTTP(w, r)
+				return
 			}
 
-			// Parse clearance
-			var clearance models.Clearance
-			if clearanceStr != "" {
-				// Support both hex (0x03030303) and decimal formats
-				clearanceStr = strings.TrimPrefix(clearanceStr, "0x")
-				clearanceStr = strings.TrimPrefix(clearanceStr, "0X")
-
-				c, err := strconv.ParseUint(clearanceStr, 16, 32)
-				if err != nil {
-					config.Logger.WarnContext(r.Context(), "invalid clearance", map[string]interface{}{
-						"clearance": clearanceStr,
-						"error":      err.Error(),
-					})
-					respondUnauthorized(w, r, config, "invalid clearance format")
-					return
-				}
-				clearance = models.Clearance(c)
-
-				if !models.ValidateClearance(clearance) {
-					respondUnauthorized(w, r, config, "invalid clearance level")
-					return
-				}
+			// Reject clients locked out after repeated authentication failures
+			if wait, blocked := config.lockedOut(r, 0); blocked {
+				respondLockedOut(w, r, config, wait)
+				return
 			}
 
-			// Parse layer
-			layer := models.Layer(layerStr)
-			if layerStr != "" {
-				// Validate layer
-				validLayers := map[models.Layer]bool{
-					models.LayerData:        true,
-					models.LayerTransport:   true,
-					models.LayerControl:     true,
-					models.LayerApplication: true,
-				}
-				if !validLayers[layer] {
-					respondUnauthorized(w, r, config, "invalid layer")
-					return
+			// Establish the caller's identity through the provider chain
+			id, provider, err := config.identify(r)
+			if err != nil {
+				config.Logger.WarnContext(r.Context(), "identity rejected", map[string]interface{}{
+					"provider": provider,
+					"error":    err.Error(),
+				})
+				code, reason := DenyInvalidIdentity, "invalid credentials"
+				var idErr *IdentityError
+				if errors.As(err, &idErr) {
+					code, reason = idErr.Code, idErr.Reason
 				}
+				respondUnauthorized(w, r, config, 0, code, reason)
+				return
+			}
+			provenDevice := id.provenDevice()
+			for _, failure := range id.Failures {
+				config.recordAuthFailure(r, provenDevice, failure)
 			}
 
-			// Parse token ID (optional)
-			var tokenID uint16
-			var tokenOffset models.TokenOffset
-			if tokenIDStr != "" {
-				id, err := strconv.ParseUint(tokenIDStr, 10, 16)
-				if err != nil {
-					respondUnauthorized(w, r, config, "invalid token ID")
+			// A device is only locked out once a credential proves the request is its own
+			if provenDevice > 0 {
+				if wait, blocked := config.lockedOut(r, provenDevice); blocked {
+					respondLockedOut(w, r, config, wait)
 					return
 				}
-				tokenID = uint16(id)
-
-				// Look up device by token
-				if config.DeviceRegistry != nil {
-					device, offset, err := config.DeviceRegistry.GetDeviceByToken(tokenID)
-					if err == nil {
-						deviceID = device.ID
-						layer = device.Layer
-						clearance = device.Clearance
-						tokenOffset = offset
-					}
-				}
 			}
+			deviceID, clearance, layer := id.DeviceID, id.Clearance, id.Layer
+			tokenID, tokenOffset, tokenResolved := id.TokenID, id.TokenOffset, id.TokenResolved
 
 			// Get device info if registry is available
 			var device *models.Device
@@ -133,7 +253,7 @@ func Clearance(config *ClearanceConfig) func(http.Handler) http.Handler {
 					config.Logger.WarnContext(r.Context(), "device not found", map[string]interface{}{
 						"device_id": deviceID,
 					})
-					respondUnauthorized(w, r, config, "device not registered")
+					respondUnauthorized(w, r, config, provenDevice, DenyDeviceNotRegistered, "device not registered")
 					return
 				}
 
@@ -146,6 +266,25 @@ func Clearance(config *ClearanceConfig) func(http.Handler) http.Handler {
 				}
 			}
 
+			// Layer listeners decide the layer; a different claimed or registered one is rejected
+			if listenerLayer, ok := GetListenerLayer(r.Context()); ok {
+				if layer != "" && layer != listenerLayer {
+					config.Logger.WarnContext(r.Context(), "layer does not match listener", map[string]interface{}{
+						"device_id": deviceID,
+						"layer":     layer,
+						"listener":  listenerLayer,
+					})
+					respondUnauthorized(w, r, config, provenDevice, DenyLayerMismatch, "layer does not match listener")
+					return
+				}
+				layer = listenerLayer
+			}
+
+			// The proven device authenticated; forget its earlier failures
+			if config.Lockout != nil && provenDevice > 0 {
+				config.Lockout.Success(LockoutDeviceKey(provenDevice))
+			}
+
 			// Add clearance info to context
 			ctx := r.Context()
 			if clearance > 0 {
@@ -154,43 +293,44 @@ func Clearance(config *ClearanceConfig) func(http.Handler) http.Handler {
 			if device != nil {
 				ctx = context.WithValue(ctx, DeviceKey, device)
 			}
+			if tokenResolved {
+				ctx = context.WithValue(ctx, TokenKey, requestToken{id: tokenID, offset: tokenOffset})
+			}
 			if deviceID > 0 {
 				ctx = logging.WithDeviceID(ctx, fmt.Sprintf("%d", deviceID))
 			}
 			if layer != "" {
+				ctx = context.WithValue(ctx, LayerKey, layer)
 				ctx = logging.WithLayer(ctx, string(layer))
 			}
 
 			// Evaluate policy
 			if config.PolicyEngine != nil {
-				policyCtx := &policy.Context{
-					Route:       r.URL.Path,
-					Method:      r.Method,
-					DeviceID:    deviceID,
-					Layer:       layer,
-					Clearance:   clearance,
-					RequestID:   logging.GetRequestID(ctx),
-					SourceIP:    r.RemoteAddr,
-					TokenID:     tokenID,
-					TokenOffset: tokenOffset,
-				}
+				id.Clearance, id.Layer = clearance, layer
+				policyCtx := id.PolicyContext(r.URL.Path, r.Method)
+				policyCtx.RequestID = logging.GetRequestID(ctx)
+				policyCtx.SourceIP = r.RemoteAddr
 
 				decision := config.PolicyEngine.Evaluate(policyCtx)
 
 				// Log audit event
-				if config.AuditLogger != nil {
+				level := config.auditLevel(r.URL.Path, decision.AuditLevel)
+				if decision.Effect != policy.EffectAllow {
+					level = denialAuditLevel(level)
+				}
+				if config.AuditLogger != nil && level != audit.LevelNone {
 					auditEvent := &audit.AuditEvent{
 						Actor:      fmt.Sprintf("device-%d", deviceID),
 						Clearance:  clearance,
 						DeviceID:   deviceID,
 						Layer:      layer,
-						Action:     r.URL.Path,
+						Action:     auditAction(r),
 						Method:     r.Method,
-						Resource:   r.URL.String(),
 						RequestID:  logging.GetRequestID(ctx),
 						SourceIP:   r.RemoteAddr,
 						StatusCode: 0, // Will be set later
 					}
+					config.applyAuditDetail(auditEvent, r, level)
 
 					if decision.Effect == policy.EffectAllow {
 						auditEvent.Decision = audit.DecisionAllow
@@ -211,17 +351,45 @@ func Clearance(config *ClearanceConfig) func(http.Handler) http.Handler {
 						"reason":    decision.Reason,
 						"device_id": deviceID,
 						"clearance": clearance,
-						"route":     r.URL.Path,
+						"path":      r.URL.Path,
 					})
 
 					w.Header().Set("Content-Type", "application/json")
 					w.WriteHeader(http.StatusForbidden)
+					json.NewEncoder(w).Encode(newPolicyDenyResponse(decision, device != nil))
+					return
+				}
+			}
+
+			// Enforce the per-device in-flight cap
+			if config.DeviceLimiter != nil && deviceID > 0 {
+				if !config.DeviceLimiter.Acquire(deviceID, layer) {
+					config.Logger.WarnContext(ctx, "device concurrency limit exceeded", map[string]interface{}{
+						"device_id": deviceID,
+						"layer":     layer,
+						"path":      r.URL.Path,
+					})
+
+					w.Header().Set("Content-Type", "application/json")
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(http.StatusTooManyRequests)
 					json.NewEncoder(w).Encode(map[string]interface{}{
-						"error":  "access denied",
-						"reason": decision.Reason,
+						"error":  "too many requests",
+						"reason": "device concurrency limit exceeded",
 					})
 					return
 				}
+				defer config.DeviceLimiter.Release(deviceID)
+			}
+
+			// Recovery runs outside this middleware and never sees its context, so a
+			// panic is passed on with the device that sent the request
+			if device != nil {
+				defer func() {
+					if err := recover(); err != nil {
+						panic(&devicePanic{device: device, value: err})
+					}
+				}()
 			}
 
 			// Continue with updated context
@@ -230,31 +398,150 @@ func Clearance(config *ClearanceConfig) func(http.Handler) http.Handler {
 	}
 }
 
-// respondUnauthorized sends an unauthorized response
-func respondUnauthorized(w http.ResponseWriter, r *http.Request, config *ClearanceConfig, reason string) {
+// respondUnauthorized sends an unauthorized response with a machine-readable code.
+// provenDevice is the device a credential established, or 0 if none did.
+func respondUnauthorized(w http.ResponseWriter, r *http.Request, config *ClearanceConfig, provenDevice uint16, code, reason string) {
+	config.recordAuthFailure(r, provenDevice, reason)
+
 	if config.AuditLogger != nil {
+		level := denialAuditLevel(config.auditLevel(r.URL.Path, ""))
 		event := &audit.AuditEvent{
 			Actor:      "unknown",
-			Action:     r.URL.Path,
+			Action:     auditAction(r),
 			Method:     r.Method,
-			Resource:   r.URL.String(),
 			Decision:   audit.DecisionDeny,
 			Reason:     reason,
 			RequestID:  logging.GetRequestID(r.Context()),
 			SourceIP:   r.RemoteAddr,
 			StatusCode: http.StatusUnauthorized,
 		}
+		config.applyAuditDetail(event, r, level)
 		config.AuditLogger.Log(event)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(DenyResponse{
+		Error:  "unauthorized",
+		Reason: reason,
+		Code:   code,
+		Hint:   authHints[code],
+	})
+}
+
+// lockoutKeys returns the lockout keys for a request: its client address and, if
+// non-zero, the proven device ID. A device ID the client merely claims is never
+// used, or anyone could lock a device out by sending bad credentials in its name.
+func (c *ClearanceConfig) lockoutKeys(r *http.Request, provenDevice uint16) []string {
+	keys := []string{LockoutIPKey(c.clientAddr(r))}
+	if provenDevice > 0 {
+		keys = append(keys, LockoutDeviceKey(provenDevice))
+	}
+	return keys
+}
+
+// clientAddr returns the request's client address as the lockout sees it
+func (c *ClearanceConfig) clientAddr(r *http.Request) string {
+	if c.Lockout == nil {
+		return r.RemoteAddr
+	}
+	return c.Lockout.ClientAddr(r)
+}
+
+// lockedOut reports whether any of the request's lockout keys is blocked, and for how long
+func (c *ClearanceConfig) lockedOut(r *http.Request, provenDevice uint16) (time.Duration, bool) {
+	if c.Lockout == nil {
+		return 0, false
+	}
+
+	var longest time.Duration
+	blocked := false
+	for _, key := range c.lockoutKeys(r, provenDevice) {
+		if wait, ok := c.Lockout.Check(key); ok {
+			blocked = true
+			if wait > longest {
+				longest = wait
+			}
+		}
+	}
+	return longest, blocked
+}
+
+// recordAuthFailure counts an authentication failure against the request's
+// client address and proven device, auditing any ban it triggers
+func (c *ClearanceConfig) recordAuthFailure(r *http.Request, provenDevice uint16, reason string) {
+	if c.Lockout == nil {
+		return
+	}
+
+	for _, key := range c.lockoutKeys(r, provenDevice) {
+		wait, banned := c.Lockout.Failure(key)
+		if !banned {
+			continue
+		}
+
+		c.Logger.WarnContext(r.Context(), "authentication lockout", map[string]interface{}{
+			"key":      key,
+			"reason":   reason,
+			"duration": wait.String(),
+		})
+		if c.AuditLogger != nil {
+			event := audit.NewEvent(audit.DecisionDeny, LockoutAuditAction, key,
+				fmt.Sprintf("banned for %s after repeated authentication failures (last: %s)", wait, reason))
+			event.Actor = "system"
+			event.Method = r.Method
+			event.RequestID = logging.GetRequestID(r.Context())
+			event.SourceIP = c.clientAddr(r)
+			event.StatusCode = http.StatusTooManyRequests
+			c.AuditLogger.Log(event)
+		}
+	}
+}
+
+// respondLockedOut rejects a request from a locked-out source or device
+func respondLockedOut(w http.ResponseWriter, r *http.Request, config *ClearanceConfig, wait time.Duration) {
+	const reason = "too many failed authentication attempts"
+
+	config.Logger.WarnContext(r.Context(), "request from locked-out source", map[string]interface{}{
+		"remote": config.clientAddr(r),
+		"path":   r.URL.Path,
+		"wait":   wait.String(),
+	})
+
+	if config.AuditLogger != nil {
+		level := denialAuditLevel(config.auditLevel(r.URL.Path, ""))
+		event := &audit.AuditEvent{
+			Actor:      "unknown",
+			Action:     auditAction(r),
+			Method:     r.Method,
+			Decision:   audit.DecisionDeny,
+			Reason:     reason,
+			RequestID:  logging.GetRequestID(r.Context()),
+			SourceIP:   config.clientAddr(r),
+			StatusCode: http.StatusTooManyRequests,
+		}
+		config.applyAuditDetail(event, r, level)
+		config.AuditLogger.Log(event)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	w.WriteHeader(http.StatusTooManyRequests)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error":  "unauthorized",
+		"error":  "too many requests",
 		"reason": reason,
 	})
 }
 
+// auditAction returns the normalized route for audit events, falling back to the raw path.
+// The raw path is still recorded in the event's resource.
+func auditAction(r *http.Request) string {
+	if route := logging.GetRoute(r.Context()); route != "" {
+		return route
+	}
+	return r.URL.Path
+}
+
 // GetClearance retrieves clearance from context
 func GetClearance(ctx context.Context) (models.Clearance, bool) {
 	clearance, ok := ctx.Value(ClearanceKey).(models.Clearance)
@@ -266,3 +553,27 @@ func GetDevice(ctx context.Context) (*models.Device, bool) {
 	device, ok := ctx.Value(DeviceKey).(*models.Device)
 	return device, ok
 }
+
+// GetToken retrieves the registered token (X-Token-ID) a request authenticated with and its offset
+func GetToken(ctx context.Context) (uint16, models.TokenOffset, bool) {
+	token, ok := ctx.Value(TokenKey).(requestToken)
+	return token.id, token.offset, ok
+}
+
+// WithListenerLayer marks a connection's context with the layer of the listener it was
+// accepted on, e.g. from http.Server.ConnContext
+func WithListenerLayer(ctx context.Context, layer models.Layer) context.Context {
+	return context.WithValue(ctx, ListenerLayerKey, layer)
+}
+
+// GetListenerLayer retrieves the layer of the listener a request arrived on
+func GetListenerLayer(ctx context.Context) (models.Layer, bool) {
+	layer, ok := ctx.Value(ListenerLayerKey).(models.Layer)
+	return layer, ok
+}
+
+// GetLayer retrieves the request's layer (its listener's, X-Layer or the device's layer) from context
+func GetLayer(ctx context.Context) (models.Layer, bool) {
+	layer, ok := ctx.Value(LayerKey).(models.Layer)
+	return layer, ok
+}
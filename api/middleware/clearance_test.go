@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/idmap"
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/internal/policy"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// marshalWriter checks that every audit event can be serialized
+type marshalWriter struct {
+	mu     sync.Mutex
+	events int
+	err    error
+}
+
+func (w *marshalWriter) Write(event *audit.AuditEvent) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.events++
+	if _, err := json.Marshal(event); err != nil && w.err == nil {
+		w.err = err
+	}
+	return nil
+}
+
+func (w *marshalWriter) Close() error { return nil }
+
+func TestAnonymousRoutesMatch(t *testing.T) {
+	routes := NewAnonymousRoutes()
+	routes.Add("/health")
+	routes.Add("/public/*")
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/health", true},
+		{"/health/detail", false},
+		{"/public/", true},
+		{"/public/docs/index.html", true},
+		{"/publicity", false},
+		{"/api/data", false},
+	}
+	for _, tt := range tests {
+		if got := routes.Matches(tt.path); got != tt.want {
+			t.Errorf("Matches(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	var unset *AnonymousRoutes
+	if unset.Matches("/health") {
+		t.Error("a nil route set matched")
+	}
+}
+
+func TestClearanceAnonymousRoutes(t *testing.T) {
+	var logs bytes.Buffer
+	logger := logging.New("test", "test", "info", "json")
+	logger.SetOutput(&logs)
+
+	routes := NewAnonymousRoutes()
+	routes.Add("/health")
+	lockout := NewLockout(LockoutPolicy{MaxFailures: 1})
+	config := &ClearanceConfig{
+		Logger:          logger,
+		DeviceRegistry:  models.NewDeviceRegistry(),
+		Enabled:         true,
+		AnonymousRoutes: routes,
+		Lockout:         lockout,
+	}
+	handler := Clearance(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := GetDevice(r.Context()); ok {
+			t.Error("an anonymous request carried a device")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Device-ID", "ZZ")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Malformed credentials are never looked at on an anonymous route
+	if code := serve("/health"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if status := lockout.Status(); len(status) != 0 {
+		t.Errorf("anonymous access counted as an authentication failure: %+v", status)
+	}
+
+	// The access is still logged at info level, which production loggers keep
+	var entry struct {
+		Level   string                 `json:"level"`
+		Message string                 `json:"msg"`
+		Fields  map[string]interface{} `json:"fields"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(logs.Bytes()), &entry); err != nil {
+		t.Fatalf("expected one log entry, got %q: %v", logs.String(), err)
+	}
+	if entry.Level != "info" || entry.Message != "anonymous access" || entry.Fields["path"] != "/health" || entry.Fields["method"] != "GET" {
+		t.Errorf("unexpected log entry %+v", entry)
+	}
+
+	if code := serve("/api/data"); code != http.StatusUnauthorized {
+		t.Errorf("expected other routes to require identity, got %d", code)
+	}
+}
+
+func FuzzClearance(f *testing.F) {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"groups": ["ops"], "department": "cyber"}`))
+	f.Add("1", "", "", "", "", "", "")
+	f.Add("", "0x05050505", "transport", "", "", "", `{"k": 1}`)
+	f.Add("", "", "", "", "ops, field", "Bearer x."+claims+".sig", "")
+	f.Add("65536", "ZZ", "kernel", "-1", "", "", "")
+	f.Add("", "0X", "", "99999999999", ",,", "....", "")
+
+	registry := models.NewDeviceRegistry()
+	device := &models.Device{ID: 1, Layer: models.LayerData, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel3}
+	if err := registry.Register(device); err != nil {
+		f.Fatal(err)
+	}
+
+	engine := policy.NewEngine(registry)
+	if err := engine.LoadFromJSON([]byte(`{"version": "1.0", "rules": [
+		{"id": "ops", "effect": "allow", "routes": ["/api/*"], "methods": ["*"], "required_clearance": 117901063, "allowed_layers": ["control", "application"], "audit_level": "body", "priority": 20},
+		{"id": "sensors", "effect": "allow", "routes": ["/api/data"], "methods": ["POST"], "required_clearance": 50529027, "device_selector": "class=sensor", "priority": 10},
+		{"id": "default", "effect": "deny", "routes": ["*"], "methods": ["*"]}
+	]}`)); err != nil {
+		f.Fatal(err)
+	}
+
+	mapper, err := idmap.New(idmap.Config{
+		GroupsHeader: "X-Forwarded-Groups",
+		ClaimsHeader: "X-Forwarded-Claims",
+		Rules: []idmap.Rule{
+			{Match: "group:ops", ClearanceLevel: 7, Layer: "control"},
+			{Match: "claim:department=cyber", ClearanceLevel: 5},
+		},
+	})
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	logger := logging.New("fuzz", "test", "error", "json")
+	logger.SetOutput(io.Discard)
+	writer := &marshalWriter{}
+	auditLogger := audit.NewLogger()
+	auditLogger.AddWriter(writer)
+
+	handler := Clearance(&ClearanceConfig{
+		PolicyEngine:   engine,
+		AuditLogger:    auditLogger,
+		Logger:         logger,
+		DeviceRegistry: registry,
+		Enabled:        true,
+		IdentityMapper: mapper,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	f.Fuzz(func(t *testing.T, deviceID, clearance, layer, tokenID, groups, claims, body string) {
+		req := httptest.NewRequest("POST", "/api/data", strings.NewReader(body))
+		for name, value := range map[string]string{
+			"X-Device-ID":        deviceID,
+			"X-Clearance":        clearance,
+			"X-Layer":            layer,
+			"X-Token-ID":         tokenID,
+			"X-Forwarded-Groups": groups,
+			"X-Forwarded-Claims": claims,
+		} {
+			if value != "" {
+				req.Header.Set(name, value)
+			}
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		switch rec.Code {
+		case http.StatusOK:
+		case http.StatusUnauthorized, http.StatusForbidden:
+			var resp DenyResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("status %d with malformed body %q: %v", rec.Code, rec.Body.String(), err)
+			}
+			if rec.Code == http.StatusUnauthorized && authHints[resp.Code] == "" {
+				t.Fatalf("401 with unknown code %q", resp.Code)
+			}
+		default:
+			t.Fatalf("unexpected status %d", rec.Code)
+		}
+
+		writer.mu.Lock()
+		defer writer.mu.Unlock()
+		if writer.err != nil {
+			t.Fatalf("audit event cannot be serialized: %v", writer.err)
+		}
+	})
+}
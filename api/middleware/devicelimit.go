@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"sync"
+
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// DeviceLimiter caps the number of in-flight requests per device ID so a single
+// device cannot saturate the service. A limit of 1 serialises each device's requests.
+type DeviceLimiter struct {
+	mu          sync.Mutex
+	limit       int                  // Default cap per device; 0 means unlimited
+	layerLimits map[models.Layer]int // Per-layer overrides of the default cap
+	inFlight    map[uint16]int
+}
+
+// NewDeviceLimiter creates a limiter allowing at most limit concurrent requests per device
+func NewDeviceLimiter(limit int) *DeviceLimiter {
+	return &DeviceLimiter{
+		limit:       limit,
+		layerLimits: make(map[models.Layer]int),
+		inFlight:    make(map[uint16]int),
+	}
+}
+
+// SetLayerLimit overrides the cap for devices on a layer, e.g. a tighter cap for the control layer
+func (l *DeviceLimiter) SetLayerLimit(layer models.Layer, limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.layerLimits[layer] = limit
+}
+
+// Acquire reserves an in-flight slot for the device, reporting false if it is at its cap.
+// Every successful Acquire must be paired with a Release.
+func (l *DeviceLimiter) Acquire(deviceID uint16, layer models.Layer) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit := l.limit
+	if layerLimit, ok := l.layerLimits[layer]; ok {
+		limit = layerLimit
+	}
+
+	if limit > 0 && l.inFlight[deviceID] >= limit {
+		return false
+	}
+	l.inFlight[deviceID]++
+	return true
+}
+
+// Release frees a slot reserved by Acquire
+func (l *DeviceLimiter) Release(deviceID uint16) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight[deviceID] <= 1 {
+		delete(l.inFlight, deviceID)
+		return
+	}
+	l.inFlight[deviceID]--
+}
+
+// InFlight returns the number of in-flight requests for a device
+func (l *DeviceLimiter) InFlight(deviceID uint16) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight[deviceID]
+}
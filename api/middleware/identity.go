@@ -0,0 +1,298 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/NSACodeGov/CodeGov/internal/idmap"
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/internal/policy"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// Identity provider names accepted by NewIdentityProviders
+const (
+	ProviderHeader = "header" // X-Device-ID, X-Clearance and X-Layer as sent by the client
+	ProviderToken  = "token"  // X-Token-ID, resolved through the device registry
+	ProviderMTLS   = "mtls"   // Identity mapping rules applied to the verified client certificate
+	ProviderJWT    = "jwt"    // Identity mapping rules applied to the claims header
+	ProviderMapped = "mapped" // Identity mapping rules applied to every configured attribute source
+)
+
+// DefaultIdentityProviders is the chain used when none is configured. Later providers
+// override earlier ones, so a resolved token wins over asserted headers.
+var DefaultIdentityProviders = []string{ProviderHeader, ProviderMapped, ProviderToken}
+
+// Identity is what the identity providers established about a request's caller
+type Identity struct {
+	DeviceID      uint16
+	Clearance     models.Clearance
+	Layer         models.Layer
+	TokenID       uint16
+	TokenOffset   models.TokenOffset
+	TokenResolved bool // TokenID is a registered token and TokenOffset is set
+
+	// Failures are credentials presented but not recognized, e.g. an unknown token.
+	// They do not reject the request, but each counts towards lockout.
+	Failures []string
+
+	asserted bool // Clearance or Layer came from client-asserted headers
+}
+
+// DeviceIdentity is the identity the clearance middleware settles on for a registered
+// device that sends X-Device-ID alone: the registered clearance and layer apply
+func DeviceIdentity(device *models.Device) Identity {
+	return Identity{DeviceID: device.ID, Clearance: device.Clearance, Layer: device.Layer}
+}
+
+// ResolveToken makes id the identity of a device authenticated with its token at
+// offset, as TokenIdentity does for a registered X-Token-ID
+func (id *Identity) ResolveToken(device *models.Device, offset models.TokenOffset) {
+	id.DeviceID = device.ID
+	id.Layer = device.Layer
+	id.Clearance = device.Clearance
+	id.TokenID = device.ComputeToken(offset)
+	id.TokenOffset = offset
+	id.TokenResolved = true
+}
+
+// PolicyContext returns the policy context the clearance middleware evaluates for a
+// request from id; the caller adds the request ID and source IP
+func (id *Identity) PolicyContext(route, method string) *policy.Context {
+	return &policy.Context{
+		Route:       route,
+		Method:      method,
+		DeviceID:    id.DeviceID,
+		Layer:       id.Layer,
+		Clearance:   id.Clearance,
+		TokenID:     id.TokenID,
+		TokenOffset: id.TokenOffset,
+	}
+}
+
+// provenDevice returns the device ID a resolved token established, or 0 when the
+// device ID is only claimed
+func (id *Identity) provenDevice() uint16 {
+	if !id.TokenResolved {
+		return 0
+	}
+	return id.DeviceID
+}
+
+// IdentityError rejects a request with 401 and one of the Deny* codes
+type IdentityError struct {
+	Code   string
+	Reason string
+	Err    error // The parse error behind the rejection, if any
+}
+
+func (e *IdentityError) Error() string {
+	if e.Err != nil {
+		return e.Reason + ": " + e.Err.Error()
+	}
+	return e.Reason
+}
+
+func (e *IdentityError) Unwrap() error {
+	return e.Err
+}
+
+// IdentityProvider establishes part of a request's identity. Providers run in the
+// order of ClearanceConfig.IdentityProviders, each seeing and possibly overriding
+// what earlier ones set; a provider that finds none of its credentials leaves id
+// alone. Returning an *IdentityError rejects the request with 401.
+type IdentityProvider interface {
+	Name() string
+	Identify(r *http.Request, id *Identity) error
+}
+
+// HeaderIdentity reads X-Device-ID, X-Clearance and X-Layer
+type HeaderIdentity struct{}
+
+func (HeaderIdentity) Name() string { return ProviderHeader }
+
+func (HeaderIdentity) Identify(r *http.Request, id *Identity) error {
+	if s := r.Header.Get("X-Device-ID"); s != "" {
+		deviceID, err := strconv.ParseUint(s, 10, 16)
+		if err != nil {
+			return &IdentityError{Code: DenyInvalidDeviceID, Reason: "invalid device ID", Err: err}
+		}
+		id.DeviceID = uint16(deviceID)
+	}
+
+	if s := r.Header.Get("X-Clearance"); s != "" {
+		// Support both hex (0x03030303) and decimal formats
+		s = strings.TrimPrefix(s, "0x")
+		s = strings.TrimPrefix(s, "0X")
+
+		c, err := strconv.ParseUint(s, 16, 32)
+		if err != nil {
+			return &IdentityError{Code: DenyInvalidClearance, Reason: "invalid clearance format", Err: err}
+		}
+		clearance := models.Clearance(c)
+		if !models.ValidateClearance(clearance) {
+			return &IdentityError{Code: DenyInvalidClearance, Reason: "invalid clearance level"}
+		}
+		id.Clearance = clearance
+		id.asserted = true
+	}
+
+	if s := r.Header.Get("X-Layer"); s != "" {
+		layer := models.Layer(s)
+		switch layer {
+		case models.LayerData, models.LayerTransport, models.LayerControl, models.LayerApplication:
+		default:
+			return &IdentityError{Code: DenyInvalidLayer, Reason: "invalid layer"}
+		}
+		id.Layer = layer
+		id.asserted = true
+	}
+	return nil
+}
+
+// TokenIdentity resolves X-Token-ID to its registered device, whose ID, clearance
+// and layer replace anything set before. Unknown tokens are not rejected, but are
+// recorded as failures.
+type TokenIdentity struct {
+	Registry *models.DeviceRegistry
+}
+
+func (TokenIdentity) Name() string { return ProviderToken }
+
+func (p TokenIdentity) Identify(r *http.Request, id *Identity) error {
+	s := r.Header.Get("X-Token-ID")
+	if s == "" {
+		return nil
+	}
+	tokenID, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return &IdentityError{Code: DenyInvalidToken, Reason: "invalid token ID", Err: err}
+	}
+	id.TokenID = uint16(tokenID)
+
+	if p.Registry == nil {
+		return nil
+	}
+	device, offset, err := p.Registry.GetDeviceByToken(id.TokenID)
+	if err != nil {
+		id.Failures = append(id.Failures, "unknown token ID")
+		return nil
+	}
+	id.ResolveToken(device, offset)
+	return nil
+}
+
+// MappedIdentity derives clearance and layer from identity mapping rules, reading
+// only the given idmap sources (all of them when empty). Requests that identify a
+// device with X-Device-ID or X-Token-ID are left alone. Mapped values replace
+// asserted X-Clearance and X-Layer headers; an authoritative mapper discards those
+// headers even when no rule matches.
+type MappedIdentity struct {
+	Mapper  *idmap.Mapper
+	Sources []string
+	Logger  *logging.Logger // Logs each mapping at debug level when set
+	name    string
+}
+
+func (p MappedIdentity) Name() string {
+	if p.name != "" {
+		return p.name
+	}
+	return ProviderMapped
+}
+
+func (p MappedIdentity) Identify(r *http.Request, id *Identity) error {
+	if p.Mapper == nil || r.Header.Get("X-Device-ID") != "" || r.Header.Get("X-Token-ID") != "" {
+		return nil
+	}
+
+	if p.Mapper.Authoritative() && id.asserted {
+		id.Clearance, id.Layer, id.asserted = 0, "", false
+	}
+	mapped, ok := p.Mapper.ResolveSources(r, p.Sources...)
+	if !ok {
+		return nil
+	}
+	if mapped.Clearance > 0 {
+		id.Clearance = mapped.Clearance
+	}
+	if mapped.Layer != "" {
+		id.Layer = mapped.Layer
+	}
+	if p.Logger != nil {
+		p.Logger.DebugContext(r.Context(), "identity mapped", map[string]interface{}{
+			"provider":  p.Name(),
+			"clearance": id.Clearance,
+			"layer":     id.Layer,
+			"rules":     mapped.Rules,
+		})
+	}
+	return nil
+}
+
+// NewIdentityProviders builds a provider chain from provider names, using the
+// config's device registry, identity mapper and logger. The mapping providers need
+// an identity mapper reading their source.
+func NewIdentityProviders(names []string, config *ClearanceConfig) ([]IdentityProvider, error) {
+	providers := make([]IdentityProvider, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			return nil, fmt.Errorf("identity provider %q listed twice", name)
+		}
+		seen[name] = true
+
+		switch name {
+		case ProviderHeader:
+			providers = append(providers, HeaderIdentity{})
+		case ProviderToken:
+			providers = append(providers, TokenIdentity{Registry: config.DeviceRegistry})
+		case ProviderMTLS, ProviderJWT, ProviderMapped:
+			var sources []string
+			switch name {
+			case ProviderMTLS:
+				sources = []string{idmap.SourceClientCert}
+			case ProviderJWT:
+				sources = []string{idmap.SourceClaims}
+			}
+			if config.IdentityMapper == nil {
+				return nil, fmt.Errorf("identity provider %q requires identity mapping rules", name)
+			}
+			for _, source := range sources {
+				if !config.IdentityMapper.HasSource(source) {
+					return nil, fmt.Errorf("identity provider %q requires identity mapping to read %s", name, source)
+				}
+			}
+			providers = append(providers, MappedIdentity{Mapper: config.IdentityMapper, Sources: sources, Logger: config.Logger, name: name})
+		default:
+			return nil, fmt.Errorf("unknown identity provider %q", name)
+		}
+	}
+	return providers, nil
+}
+
+// identityProviders returns the configured chain, or the default chain built from
+// the config's registry and mapper
+func (c *ClearanceConfig) identityProviders() []IdentityProvider {
+	if c.IdentityProviders != nil {
+		return c.IdentityProviders
+	}
+	providers := []IdentityProvider{HeaderIdentity{}}
+	if c.IdentityMapper != nil {
+		providers = append(providers, MappedIdentity{Mapper: c.IdentityMapper, Logger: c.Logger})
+	}
+	return append(providers, TokenIdentity{Registry: c.DeviceRegistry})
+}
+
+// identify runs the provider chain over a request
+func (c *ClearanceConfig) identify(r *http.Request) (*Identity, string, error) {
+	id := &Identity{}
+	for _, provider := range c.identityProviders() {
+		if err := provider.Identify(r, id); err != nil {
+			return id, provider.Name(), err
+		}
+	}
+	return id, "", nil
+}
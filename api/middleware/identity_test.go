@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/NSACodeGov/CodeGov/internal/idmap"
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// rejectAll is a custom provider that rejects every request
+type rejectAll struct{}
+
+func (rejectAll) Name() string { return "reject" }
+
+func (rejectAll) Identify(r *http.Request, id *Identity) error {
+	return errors.New("no credentials")
+}
+
+func TestIdentityProviders(t *testing.T) {
+	registry := models.NewDeviceRegistry()
+	device := &models.Device{ID: 3, Layer: models.LayerControl, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel7}
+	if err := registry.Register(device); err != nil {
+		t.Fatal(err)
+	}
+	mapper, err := idmap.New(idmap.Config{
+		ClaimsHeader: "X-Forwarded-Claims",
+		Rules:        []idmap.Rule{{Match: "claim:department=cyber", ClearanceLevel: 5, Layer: "application"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := logging.New("test", "test", "error", "json")
+	logger.SetOutput(io.Discard)
+
+	config := &ClearanceConfig{Logger: logger, DeviceRegistry: registry, IdentityMapper: mapper, Enabled: true}
+	var got *Identity
+	handler := Clearance(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = &Identity{}
+		got.Clearance, _ = GetClearance(r.Context())
+		got.Layer, _ = GetLayer(r.Context())
+		if device, ok := GetDevice(r.Context()); ok {
+			got.DeviceID = device.ID
+		}
+	}))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"department":"cyber"}`))
+
+	serve := func(headers map[string]string) *httptest.ResponseRecorder {
+		got = nil
+		req := httptest.NewRequest("GET", "/api/data", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The default chain lets a resolved token override asserted headers
+	serve(map[string]string{"X-Clearance": "02020202", "X-Layer": "data", "X-Token-ID": strconv.Itoa(int(device.GetStatusToken()))})
+	if got == nil || got.DeviceID != 3 || got.Clearance != models.ClearanceLevel7 || got.Layer != models.LayerControl {
+		t.Fatalf("token did not override headers: %+v", got)
+	}
+
+	// A jwt-only chain ignores asserted clearance and maps the claims
+	config.IdentityProviders, err = NewIdentityProviders([]string{ProviderJWT}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve(map[string]string{"X-Clearance": "09090909"})
+	if got == nil || got.Clearance != 0 {
+		t.Errorf("jwt chain accepted X-Clearance: %+v", got)
+	}
+	serve(map[string]string{"X-Clearance": "09090909", "X-Forwarded-Claims": claims})
+	if got == nil || got.Clearance != models.ClearanceLevel5 || got.Layer != models.LayerApplication {
+		t.Errorf("claims not mapped: %+v", got)
+	}
+
+	// Custom providers reject with a generic code
+	config.IdentityProviders = []IdentityProvider{HeaderIdentity{}, rejectAll{}}
+	rec := serve(nil)
+	var resp DenyResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusUnauthorized || resp.Code != DenyInvalidIdentity || got != nil {
+		t.Errorf("expected 401 %s, got %d %+v", DenyInvalidIdentity, rec.Code, resp)
+	}
+
+	for _, names := range [][]string{{"kerberos"}, {ProviderMTLS}, {ProviderHeader, ProviderHeader}} {
+		if _, err := NewIdentityProviders(names, config); err == nil {
+			t.Errorf("expected %v to be rejected", names)
+		}
+	}
+}
+
+func TestListenerLayer(t *testing.T) {
+	registry := models.NewDeviceRegistry()
+	device := &models.Device{ID: 5, Layer: models.LayerData, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel3}
+	if err := registry.Register(device); err != nil {
+		t.Fatal(err)
+	}
+	logger := logging.New("test", "test", "error", "json")
+	logger.SetOutput(io.Discard)
+
+	var layer models.Layer
+	handler := Clearance(&ClearanceConfig{Logger: logger, DeviceRegistry: registry, Enabled: true})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		layer, _ = GetLayer(r.Context())
+	}))
+
+	for _, c := range []struct {
+		listener models.Layer
+		headers  map[string]string
+		status   int
+		layer    models.Layer
+	}{
+		{models.LayerControl, nil, http.StatusOK, models.LayerControl},
+		{models.LayerControl, map[string]string{"X-Layer": "application"}, http.StatusUnauthorized, ""},
+		{models.LayerData, map[string]string{"X-Device-ID": "5"}, http.StatusOK, models.LayerData},
+		{models.LayerControl, map[string]string{"X-Device-ID": "5"}, http.StatusUnauthorized, ""},
+		{"", map[string]string{"X-Layer": "application"}, http.StatusOK, models.LayerApplication},
+	} {
+		layer = ""
+		req := httptest.NewRequest("GET", "/api/data", nil)
+		if c.listener != "" {
+			req = req.WithContext(WithListenerLayer(req.Context(), c.listener))
+		}
+		for name, value := range c.headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != c.status || layer != c.layer {
+			t.Errorf("listener %q with %v: got %d on layer %q, want %d on %q", c.listener, c.headers, rec.Code, layer, c.status, c.layer)
+		}
+		if rec.Code == http.StatusUnauthorized && !strings.Contains(rec.Body.String(), DenyLayerMismatch) {
+			t.Errorf("expected %s, got %s", DenyLayerMismatch, rec.Body.String())
+		}
+	}
+}
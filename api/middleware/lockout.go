@@ -0,0 +1,248 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults applied to zero fields of a LockoutPolicy
+const (
+	DefaultLockoutMaxFailures = 5
+	DefaultLockoutWindow      = 15 * time.Minute
+	DefaultLockoutBaseDelay   = time.Second
+	DefaultLockoutMaxDelay    = 30 * time.Second
+	DefaultLockoutBanDuration = 15 * time.Minute
+)
+
+// maxLockoutEntries bounds tracked keys before idle entries are swept
+const maxLockoutEntries = 4096
+
+// LockoutPolicy tunes brute-force protection for authentication failures
+type LockoutPolicy struct {
+	MaxFailures int           // Failures within Window that trigger a ban
+	Window      time.Duration // Failures older than this are forgotten
+	BaseDelay   time.Duration // Wait imposed after the first failure, doubled for each further failure
+	MaxDelay    time.Duration // Upper bound on the escalating wait
+	BanDuration time.Duration // How long a key stays banned once MaxFailures is reached
+
+	// TrustedProxies are peers whose X-Forwarded-For is believed; the client is the
+	// right-most forwarded address outside them
+	TrustedProxies []*net.IPNet
+}
+
+// LockoutStatus describes a tracked source or device
+type LockoutStatus struct {
+	Key          string    `json:"key"`
+	Failures     int       `json:"failures"`
+	BlockedUntil time.Time `json:"blocked_until"`
+	Banned       bool      `json:"banned"`
+}
+
+type lockoutEntry struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+	banned       bool
+}
+
+// Lockout tracks authentication failures per client address and proven device. Each failure
+// blocks the key for an escalating delay; reaching MaxFailures bans it temporarily.
+type Lockout struct {
+	mu      sync.Mutex
+	policy  LockoutPolicy
+	entries map[string]*lockoutEntry
+	now     func() time.Time
+}
+
+// NewLockout creates a lockout tracker, filling zero policy fields with defaults
+func NewLockout(policy LockoutPolicy) *Lockout {
+	if policy.MaxFailures <= 0 {
+		policy.MaxFailures = DefaultLockoutMaxFailures
+	}
+	if policy.Window <= 0 {
+		policy.Window = DefaultLockoutWindow
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = DefaultLockoutBaseDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = DefaultLockoutMaxDelay
+	}
+	if policy.BanDuration <= 0 {
+		policy.BanDuration = DefaultLockoutBanDuration
+	}
+
+	return &Lockout{
+		policy:  policy,
+		entries: make(map[string]*lockoutEntry),
+		now:     time.Now,
+	}
+}
+
+// LockoutIPKey returns the lockout key for a request's remote address
+func LockoutIPKey(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return "ip:" + host
+}
+
+// ClientAddr returns the address failures are counted against: the peer address,
+// or for requests relayed by a trusted proxy the right-most untrusted
+// X-Forwarded-For entry
+func (l *Lockout) ClientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !l.trusted(host) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(addr) == nil {
+			// Anything left of a malformed entry may have been written by the client
+			break
+		}
+		host = addr
+		if !l.trusted(addr) {
+			break
+		}
+	}
+	return host
+}
+
+// trusted reports whether an address belongs to a trusted proxy
+func (l *Lockout) trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range l.policy.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// LockoutDeviceKey returns the lockout key for a device ID
+func LockoutDeviceKey(deviceID uint16) string {
+	return fmt.Sprintf("device:%d", deviceID)
+}
+
+// Check reports whether a key is currently blocked and for how long
+func (l *Lockout) Check(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[key]
+	if !ok {
+		return 0, false
+	}
+
+	if wait := entry.blockedUntil.Sub(l.now()); wait > 0 {
+		return wait, true
+	}
+	return 0, false
+}
+
+// Failure records an authentication failure and returns how long the key is now
+// blocked. banned is true only for the failure that starts a ban.
+func (l *Lockout) Failure(key string) (wait time.Duration, banned bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if len(l.entries) >= maxLockoutEntries {
+		l.sweep(now)
+	}
+
+	entry, ok := l.entries[key]
+	if !ok || (!entry.banned && now.Sub(entry.lastFailure) > l.policy.Window) {
+		entry = &lockoutEntry{}
+		l.entries[key] = entry
+	}
+	if entry.banned && !now.Before(entry.blockedUntil) {
+		// The previous ban has expired; start counting afresh
+		*entry = lockoutEntry{}
+	}
+
+	entry.failures++
+	entry.lastFailure = now
+
+	if entry.failures >= l.policy.MaxFailures {
+		banned = !entry.banned
+		entry.banned = true
+		entry.blockedUntil = now.Add(l.policy.BanDuration)
+		return l.policy.BanDuration, banned
+	}
+
+	delay := l.policy.BaseDelay << (entry.failures - 1)
+	if delay <= 0 || delay > l.policy.MaxDelay {
+		delay = l.policy.MaxDelay
+	}
+	entry.blockedUntil = now.Add(delay)
+	return delay, false
+}
+
+// Success clears the failure history of a key that is not banned
+func (l *Lockout) Success(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry, ok := l.entries[key]; ok && !entry.banned {
+		delete(l.entries, key)
+	}
+}
+
+// Unblock lifts a delay or ban and clears the key's failure history,
+// reporting whether the key was tracked
+func (l *Lockout) Unblock(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, ok := l.entries[key]
+	delete(l.entries, key)
+	return ok
+}
+
+// Status lists tracked keys that have recent failures or are blocked, ordered by key
+func (l *Lockout) Status() []LockoutStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(l.now())
+
+	statuses := make([]LockoutStatus, 0, len(l.entries))
+	for key, entry := range l.entries {
+		statuses = append(statuses, LockoutStatus{
+			Key:          key,
+			Failures:     entry.failures,
+			BlockedUntil: entry.blockedUntil,
+			Banned:       entry.banned,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Key < statuses[j].Key })
+	return statuses
+}
+
+// sweep drops entries that are no longer blocked and whose failures are outside the window
+func (l *Lockout) sweep(now time.Time) {
+	for key, entry := range l.entries {
+		if now.Before(entry.blockedUntil) {
+			continue
+		}
+		if entry.banned || now.Sub(entry.lastFailure) > l.policy.Window {
+			delete(l.entries, key)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// newTestLockout returns a lockout whose clock only moves when advance is called
+func newTestLockout(policy LockoutPolicy) (*Lockout, func(time.Duration)) {
+	lockout := NewLockout(policy)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lockout.now = func() time.Time { return now }
+	return lockout, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestLockoutThresholdAndExpiry(t *testing.T) {
+	lockout, advance := newTestLockout(LockoutPolicy{
+		MaxFailures: 3,
+		Window:      time.Minute,
+		BaseDelay:   time.Second,
+		MaxDelay:    4 * time.Second,
+		BanDuration: 10 * time.Minute,
+	})
+	const key = "ip:192.0.2.1"
+
+	// Each failure blocks for twice as long as the last
+	if wait, banned := lockout.Failure(key); wait != time.Second || banned {
+		t.Fatalf("first failure: wait %s, banned %v", wait, banned)
+	}
+	if wait, blocked := lockout.Check(key); !blocked || wait != time.Second {
+		t.Fatalf("expected a 1s block, got %s, %v", wait, blocked)
+	}
+	advance(time.Second)
+	if _, blocked := lockout.Check(key); blocked {
+		t.Fatal("block did not expire")
+	}
+	if wait, _ := lockout.Failure(key); wait != 2*time.Second {
+		t.Fatalf("second failure: wait %s", wait)
+	}
+
+	// Reaching MaxFailures bans the key, and only that failure reports the ban
+	if wait, banned := lockout.Failure(key); wait != 10*time.Minute || !banned {
+		t.Fatalf("third failure: wait %s, banned %v", wait, banned)
+	}
+	if _, banned := lockout.Failure(key); banned {
+		t.Error("a failure during a ban reported a new ban")
+	}
+	lockout.Success(key)
+	if _, blocked := lockout.Check(key); !blocked {
+		t.Fatal("success lifted a ban")
+	}
+
+	// Once the ban expires, counting starts afresh
+	advance(10 * time.Minute)
+	if _, blocked := lockout.Check(key); blocked {
+		t.Fatal("ban did not expire")
+	}
+	if wait, banned := lockout.Failure(key); wait != time.Second || banned {
+		t.Fatalf("failure after ban: wait %s, banned %v", wait, banned)
+	}
+
+	// Failures older than the window are forgotten
+	lockout.Failure(key)
+	advance(2 * time.Minute)
+	if wait, _ := lockout.Failure(key); wait != time.Second {
+		t.Errorf("failures outside the window were counted: wait %s", wait)
+	}
+}
+
+func TestLockoutUnblock(t *testing.T) {
+	lockout, _ := newTestLockout(LockoutPolicy{MaxFailures: 1})
+	const key = "device:3"
+
+	if _, banned := lockout.Failure(key); !banned {
+		t.Fatal("expected a ban")
+	}
+	if status := lockout.Status(); len(status) != 1 || status[0].Key != key || !status[0].Banned {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if !lockout.Unblock(key) {
+		t.Fatal("Unblock did not find the key")
+	}
+	if _, blocked := lockout.Check(key); blocked {
+		t.Error("key still blocked after Unblock")
+	}
+	if lockout.Unblock(key) {
+		t.Error("Unblock found an untracked key")
+	}
+	if status := lockout.Status(); len(status) != 0 {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestLockoutClientAddr(t *testing.T) {
+	_, proxies, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		trusted    bool
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"direct", true, "192.0.2.1:5000", "", "192.0.2.1"},
+		{"forwarded header from untrusted peer", true, "192.0.2.1:5000", "203.0.113.9", "192.0.2.1"},
+		{"no trusted proxies", false, "10.0.0.1:5000", "203.0.113.9", "10.0.0.1"},
+		{"trusted proxy", true, "10.0.0.1:5000", "203.0.113.9", "203.0.113.9"},
+		{"proxy chain", true, "10.0.0.1:5000", "203.0.113.9, 10.0.0.2", "203.0.113.9"},
+		{"spoofed left-most entry", true, "10.0.0.1:5000", "198.51.100.7, 203.0.113.9", "203.0.113.9"},
+		{"malformed entry", true, "10.0.0.1:5000", "203.0.113.9, unknown", "10.0.0.1"},
+		{"trusted proxy without header", true, "10.0.0.1:5000", "", "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := LockoutPolicy{}
+			if tt.trusted {
+				policy.TrustedProxies = []*net.IPNet{proxies}
+			}
+			req := httptest.NewRequest("GET", "/api/data", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := NewLockout(policy).ClientAddr(req); got != tt.want {
+				t.Errorf("ClientAddr() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClearanceLockout(t *testing.T) {
+	registry := models.NewDeviceRegistry()
+	device := &models.Device{ID: 3, Layer: models.LayerControl, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel7}
+	if err := registry.Register(device); err != nil {
+		t.Fatal(err)
+	}
+	logger := logging.New("test", "test", "error", "json")
+	logger.SetOutput(io.Discard)
+
+	lockout := NewLockout(LockoutPolicy{MaxFailures: 2, BaseDelay: time.Nanosecond, MaxDelay: time.Nanosecond})
+	config := &ClearanceConfig{Logger: logger, DeviceRegistry: registry, Lockout: lockout, Enabled: true}
+	handler := Clearance(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(remoteAddr string, listener models.Layer, headers map[string]string) int {
+		req := httptest.NewRequest("GET", "/api/data", nil)
+		req.RemoteAddr = remoteAddr
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		if listener != "" {
+			req = req.WithContext(WithListenerLayer(req.Context(), listener))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Bad credentials sent in device 3's name lock out only the sender
+	spoofed := map[string]string{"X-Device-ID": "3", "X-Clearance": "ZZ"}
+	if code := serve("198.51.100.1:4000", "", spoofed); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", code)
+	}
+	if code := serve("198.51.100.1:4000", "", spoofed); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", code)
+	}
+	if code := serve("198.51.100.1:4000", "", map[string]string{"X-Device-ID": "3"}); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the banned client to get 429, got %d", code)
+	}
+	if code := serve("192.0.2.10:4000", "", map[string]string{"X-Device-ID": "3"}); code != http.StatusOK {
+		t.Fatalf("spoofed failures locked out device 3: got %d", code)
+	}
+	if _, blocked := lockout.Check(LockoutDeviceKey(3)); blocked {
+		t.Error("a claimed device ID was counted")
+	}
+
+	// Failures by a device proven with its token count against the device wherever they come from
+	token := map[string]string{"X-Token-ID": strconv.Itoa(int(device.GetStatusToken()))}
+	for _, addr := range []string{"192.0.2.20:4000", "192.0.2.21:4000"} {
+		if code := serve(addr, models.LayerData, token); code != http.StatusUnauthorized {
+			t.Fatalf("expected a layer mismatch, got %d", code)
+		}
+	}
+	if code := serve("192.0.2.22:4000", "", token); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the banned device to get 429, got %d", code)
+	}
+	if code := serve("192.0.2.22:4000", "", map[string]string{"X-Device-ID": "3"}); code != http.StatusOK {
+		t.Errorf("a clean client was refused: %d", code)
+	}
+
+	lockout.Unblock(LockoutDeviceKey(3))
+	if code := serve("192.0.2.22:4000", "", token); code != http.StatusOK {
+		t.Errorf("expected the unblocked device to be served, got %d", code)
+	}
+}
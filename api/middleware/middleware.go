@@ -2,15 +2,22 @@ package middleware
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"net/http"
 	"runtime/debug"
+	"strings"
 	"time"
 
+	"github.com/NSACodeGov/CodeGov/internal/audit"
 	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
 )
 
+// PanicAuditReason is the reason of audit events for requests that panicked
+const PanicAuditReason = "panic"
+
 // RequestID adds a unique request ID to each request
 func RequestID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -32,6 +39,17 @@ func RequestID(next http.Handler) http.Handler {
 	})
 }
 
+// RouteName stores the normalized route pattern for each request in the context, so logs
+// and audit events are keyed by pattern rather than by raw paths containing IDs
+func RouteName(resolve func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := logging.WithRoute(r.Context(), resolve(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 // Logging logs HTTP requests
 func Logging(logger *logging.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -66,18 +84,59 @@ func Logging(logger *logging.Logger) func(http.Handler) http.Handler {
 	}
 }
 
-// Recovery recovers from panics and returns a 500 error
-func Recovery(logger *logging.Logger) func(http.Handler) http.Handler {
+// devicePanic is a panic the clearance middleware passes on with the authenticated device
+type devicePanic struct {
+	device *models.Device
+	value  interface{} // The original panic value
+}
+
+// Recovery recovers from panics and returns a 500 error. When auditLogger is set each
+// panic is also audited as a denial, whatever the route's audit level, by the device that
+// sent the request and with a hash of the stack instead of the stack itself; the request
+// ID ties the event to the log entry holding the stack.
+func Recovery(logger *logging.Logger, auditLogger *audit.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if err := recover(); err != nil {
+					device, ok := GetDevice(r.Context())
+					if p, isDevicePanic := err.(*devicePanic); isDevicePanic {
+						err, device, ok = p.value, p.device, true
+					}
+					stack := debug.Stack()
+					stackHash := hashStack(stack)
+
 					// Log the panic with stack trace
 					logger.ErrorContext(r.Context(), "panic recovered", map[string]interface{}{
-						"error": fmt.Sprintf("%v", err),
-						"stack": string(debug.Stack()),
+						"error":      fmt.Sprintf("%v", err),
+						"stack":      string(stack),
+						"stack_hash": stackHash,
 					})
 
+					if auditLogger != nil {
+						actor := "unknown"
+						if ok {
+							actor = fmt.Sprintf("device-%d", device.ID)
+						}
+
+						event := &audit.AuditEvent{
+							Actor:      actor,
+							Action:     auditAction(r),
+							Method:     r.Method,
+							Resource:   r.URL.Path,
+							Decision:   audit.DecisionDeny,
+							Reason:     PanicAuditReason,
+							RequestID:  logging.GetRequestID(r.Context()),
+							SourceIP:   r.RemoteAddr,
+							StatusCode: http.StatusInternalServerError,
+							AdditionalData: map[string]interface{}{
+								"panic_type": fmt.Sprintf("%T", err),
+								"stack_hash": stackHash,
+							},
+						}
+						auditLogger.Log(event)
+					}
+
 					// Return 500 error
 					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				}
@@ -88,6 +147,20 @@ func Recovery(logger *logging.Logger) func(http.Handler) http.Handler {
 	}
 }
 
+// hashStack returns a short hash of a stack trace's file:line frames. Goroutine IDs and
+// argument values are left out, so the same crash site always yields the same hash.
+func hashStack(stack []byte) string {
+	h := sha256.New()
+	for _, line := range strings.Split(string(stack), "\n") {
+		if !strings.HasPrefix(line, "\t") {
+			continue
+		}
+		frame, _, _ := strings.Cut(strings.TrimSpace(line), " +0x")
+		h.Write([]byte(frame + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
 // Chain chains multiple middleware functions
 func Chain(middlewares ...func(http.Handler) http.Handler) func(http.Handler) http.Handler {
 	return func(final http.Handler) http.Handler {
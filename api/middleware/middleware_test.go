@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// eventRecorder keeps every audit event written
+type eventRecorder struct {
+	events []*audit.AuditEvent
+}
+
+func (w *eventRecorder) Write(event *audit.AuditEvent) error {
+	w.events = append(w.events, event)
+	return nil
+}
+
+func (w *eventRecorder) Close() error { return nil }
+
+// crash panics with the request's path, so each call has different arguments but the same stack
+func crash(w http.ResponseWriter, r *http.Request) {
+	panic("crashed on " + r.URL.Path)
+}
+
+func TestRecoveryAuditsPanic(t *testing.T) {
+	registry := models.NewDeviceRegistry()
+	if err := registry.Register(&models.Device{ID: 3, Layer: models.LayerControl, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel7}); err != nil {
+		t.Fatal(err)
+	}
+	logger := logging.New("test", "test", "error", "json")
+	logger.SetOutput(io.Discard)
+
+	recorder := &eventRecorder{}
+	auditLogger := audit.NewLogger()
+	auditLogger.AddWriter(recorder)
+
+	recovery := Recovery(logger, auditLogger)
+	clearance := Clearance(&ClearanceConfig{Logger: logger, DeviceRegistry: registry, Enabled: true})
+	// Ordered as in the routes, with the logging middleware wrapping the response writer in between
+	authenticated := Chain(RequestID, recovery, Logging(logger), clearance)(http.HandlerFunc(crash))
+
+	serve := func(handler http.Handler, path string, deviceID string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if deviceID != "" {
+			req.Header.Set("X-Device-ID", deviceID)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected 500, got %d", rec.Code)
+		}
+	}
+
+	// Called from one line, so the test's own frame is the same for both requests
+	for _, path := range []string{"/api/data/1", "/api/data/22"} {
+		serve(authenticated, path, "3")
+	}
+	// Without the clearance middleware no device is known
+	serve(Chain(RequestID, recovery)(http.HandlerFunc(crash)), "/api/data/1", "")
+
+	if len(recorder.events) != 3 {
+		t.Fatalf("expected 3 audit events, got %d", len(recorder.events))
+	}
+	for i, want := range []string{"device-3", "device-3", "unknown"} {
+		event := recorder.events[i]
+		if event.Actor != want || event.Decision != audit.DecisionDeny || event.Reason != PanicAuditReason ||
+			event.StatusCode != http.StatusInternalServerError || event.RequestID == "" {
+			t.Errorf("event %d: unexpected %+v", i, event)
+		}
+		if event.AdditionalData["panic_type"] != "string" {
+			t.Errorf("event %d: unexpected panic type %v", i, event.AdditionalData["panic_type"])
+		}
+	}
+
+	// The same crash site hashes the same whatever the request, and the stack itself is not audited
+	first, _ := recorder.events[0].AdditionalData["stack_hash"].(string)
+	second, _ := recorder.events[1].AdditionalData["stack_hash"].(string)
+	if len(first) != 16 || first != second {
+		t.Errorf("expected a stable 16-character stack hash, got %q and %q", first, second)
+	}
+	if _, ok := recorder.events[0].AdditionalData["stack"]; ok {
+		t.Error("the stack trace was written to the audit log")
+	}
+}
+
+func TestHashStack(t *testing.T) {
+	stack := func(goroutine, arg string) []byte {
+		return []byte("goroutine " + goroutine + " [running]:\n" +
+			"runtime/debug.Stack()\n\t/usr/local/go/src/runtime/debug/stack.go:24 +0x5e\n" +
+			"main.handler(" + arg + ")\n\t/src/app/handler.go:42 +0x1a\n")
+	}
+
+	base := hashStack(stack("7", "0xc000010000"))
+	if got := hashStack(stack("19", "0xc000abcdef")); got != base {
+		t.Errorf("goroutine IDs and arguments changed the hash: %s != %s", got, base)
+	}
+	moved := []byte(string(stack("7", "0xc000010000")) + "main.serve()\n\t/src/app/server.go:10 +0x20\n")
+	if hashStack(moved) == base {
+		t.Error("a different call path produced the same hash")
+	}
+}
@@ -1,45 +1,207 @@
 package routes
 
 import (
+	"io/fs"
 	"net/http"
+	"time"
 
 	"github.com/NSACodeGov/CodeGov/api/handlers"
 	"github.com/NSACodeGov/CodeGov/api/middleware"
+	"github.com/NSACodeGov/CodeGov/codegov"
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/devconfig"
 	"github.com/NSACodeGov/CodeGov/internal/health"
+	"github.com/NSACodeGov/CodeGov/internal/ingest"
+	"github.com/NSACodeGov/CodeGov/internal/jobs"
 	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/internal/oscal"
+	"github.com/NSACodeGov/CodeGov/internal/policy"
+	"github.com/NSACodeGov/CodeGov/internal/watchdog"
+	"github.com/NSACodeGov/CodeGov/pkg/identity"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
 )
 
+// AdminMetricsPath serves code.gov generation metrics in Prometheus text format
+const AdminMetricsPath = "/api/admin/metrics/codegov"
+
+// AdminRuntimeMetricsPath serves the runtime watchdog's latest sample in Prometheus text format
+const AdminRuntimeMetricsPath = "/api/admin/metrics/runtime"
+
 // Config holds route configuration
 type Config struct {
 	Logger             *logging.Logger
 	HealthChecker      *health.Checker
 	ClearanceConfig    *middleware.ClearanceConfig
+	CodeJSONPath       string // Serves /code.json and /api/inventory from this file when set
+	PrivateJSONPath    string // Serves the private-inclusive inventory behind the clearance middleware when set
+	CodeJSONObject     *handlers.MinIOObject // Serves the inventory from this object instead of CodeJSONPath when set
+	PrivateJSONObject  *handlers.MinIOObject // Serves the private inventory from this object instead of PrivateJSONPath when set
+	InventoryRefresh   time.Duration         // How often inventories in MinIO are revalidated
+	InternalInventory  bool                  // Puts /code.json behind the clearance middleware too
+	Site               fs.FS  // Serves this landing page at / when set
+	SiteOptions        handlers.StaticOptions
+	Subsystems         map[string]bool // Reported by /api/version
+	Upstreams          []handlers.Upstream // An upstream with prefix "/" replaces the root endpoint
+	IdentitySigner     *identity.Signer // Signs identity headers forwarded to upstreams
+	Watchdog           *watchdog.Watchdog // Serves runtime metrics when set
+	DeviceConfigs      *devconfig.Store   // Serves signed device configuration when set
+	DataIngester       *ingest.Ingester   // Accepts device telemetry when set
+	Jobs               *jobs.Manager      // Serves the job admin API when set
+	OSCAL              *oscal.System      // Serves OSCAL exports of the policy and audit trail when set
 }
 
 // Setup configures all HTTP routes
 func Setup(config *Config) http.Handler {
 	mux := http.NewServeMux()
+	var registered []handlers.RouteInfo
+
+	// templates names prefix-registered routes whose paths embed IDs
+	templates := map[string]func(path string) string{
+		handlers.AdminDevicesPrefix:  handlers.DeviceRouteName,
+		handlers.AdminJobsPrefix:     handlers.JobRouteName,
+		handlers.InventoryJobsPrefix: handlers.InventoryJobRouteName,
+	}
+
+	// handle registers a route protected by the clearance middleware
+	handle := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, handler)
+		registered = append(registered, handlers.RouteInfo{Pattern: pattern})
+	}
+
+	// allowAnonymous lets a path bypass the clearance middleware
+	allowAnonymous := func(pattern string) {
+		if config.ClearanceConfig != nil {
+			if config.ClearanceConfig.AnonymousRoutes == nil {
+				config.ClearanceConfig.AnonymousRoutes = middleware.NewAnonymousRoutes()
+			}
+			config.ClearanceConfig.AnonymousRoutes.Add(pattern)
+		}
+	}
+
+	// anonymous registers a route that bypasses the clearance middleware
+	anonymous := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, handler)
+		registered = append(registered, handlers.RouteInfo{Pattern: pattern, Anonymous: true})
+		allowAnonymous(pattern)
+	}
 
 	// Health endpoints (no auth required)
-	mux.HandleFunc("/healthz", config.HealthChecker.LivenessHandler())
-	mux.HandleFunc("/readyz", config.HealthChecker.ReadinessHandler())
+	anonymous("/healthz", config.HealthChecker.LivenessHandler())
+	anonymous("/readyz", config.HealthChecker.ReadinessHandler())
+
+	proxyRoot := false
+	for _, upstream := range config.Upstreams {
+		proxyRoot = proxyRoot || upstream.Prefix == "/"
+	}
 
 	// Root endpoint (no auth required)
-	mux.HandleFunc("/", rootHandler(config.Logger))
+	switch {
+	case proxyRoot:
+		// Proxy mode: a catch-all upstream owns "/" and is registered, protected, with
+		// the other upstreams, so every path not served here is forwarded
+		templates["/"] = func(string) string { return "/" }
+	case config.Site != nil:
+		// The landing page owns "/", but only its own files are public; any other
+		// path still goes through clearance so unknown API paths are not exposed
+		anonymous("/", handlers.StaticHandler(config.Logger, config.Site, config.SiteOptions))
+		templates["/"] = func(string) string { return handlers.StaticRoute }
+		paths, err := handlers.StaticPaths(config.Site)
+		if err != nil {
+			config.Logger.Error("failed to list site files", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		for _, path := range paths {
+			allowAnonymous(path)
+		}
+	default:
+		anonymous("/", rootHandler(config.Logger))
+	}
 
 	// Public API endpoints
-	mux.HandleFunc("/api/public", handlers.PublicHandler(config.Logger))
+	anonymous("/api/public", handlers.PublicHandler(config.Logger))
+	anonymous(handlers.VersionPath, handlers.VersionHandler(config.Logger, config.Subsystems))
+	codeJSON := inventoryHandler(config, config.CodeJSONPath, config.CodeJSONObject)
+	if codeJSON != nil && !config.InternalInventory {
+		anonymous(handlers.CodeJSONPath, codeJSON)
+	}
 
 	// Protected API endpoints (require clearance)
-	mux.HandleFunc("/api/restricted", handlers.RestrictedHandler(config.Logger))
-	mux.HandleFunc("/api/device-only", handlers.DeviceOnlyHandler(config.Logger))
-	mux.HandleFunc("/api/device/status", handlers.DeviceStatusHandler(config.Logger))
-	mux.HandleFunc("/api/high-security", handlers.HighSecurityHandler(config.Logger))
+	if codeJSON != nil {
+		if config.InternalInventory {
+			handle(handlers.CodeJSONPath, codeJSON)
+		}
+		handle(handlers.InventoryPath, codeJSON)
+	}
+	if private := inventoryHandler(config, config.PrivateJSONPath, config.PrivateJSONObject); private != nil {
+		handle(handlers.PrivateCodeJSONPath, private)
+	}
+	handle("/api/restricted", handlers.RestrictedHandler(config.Logger))
+	handle("/api/device-only", handlers.DeviceOnlyHandler(config.Logger))
+	handle("/api/device/status", handlers.DeviceStatusHandler(config.Logger))
+	handle("/api/high-security", handlers.HighSecurityHandler(config.Logger))
+
+	// Admin endpoints
+	var policyEngine *policy.Engine
+	var deviceRegistry *models.DeviceRegistry
+	var lockout *middleware.Lockout
+	var auditLogger *audit.Logger
+	if config.ClearanceConfig != nil {
+		policyEngine = config.ClearanceConfig.PolicyEngine
+		deviceRegistry = config.ClearanceConfig.DeviceRegistry
+		lockout = config.ClearanceConfig.Lockout
+		auditLogger = config.ClearanceConfig.AuditLogger
+	}
+	if config.DeviceConfigs != nil {
+		handle(handlers.DeviceConfigPath, handlers.DeviceConfigHandler(config.Logger, config.DeviceConfigs, auditLogger))
+	}
+	if config.DataIngester != nil {
+		handle(handlers.DeviceDataPath, handlers.DeviceDataHandler(config.Logger, config.DataIngester))
+		handle(handlers.DataRecordsPath, handlers.DataRecordsHandler(config.Logger, config.DataIngester, deviceRegistry, auditLogger))
+	}
+	handle(handlers.DeviceAuthzPath, handlers.DeviceAuthzHandler(config.Logger, policyEngine, deviceRegistry, auditLogger))
+	handle(handlers.AdminDevicesPrefix, handlers.DeviceAdminHandler(config.Logger, deviceRegistry,
+		handlers.DevicePermissionsHandler(config.Logger, policyEngine, deviceRegistry, func() []handlers.RouteInfo {
+			return registered
+		})))
+	handle(handlers.AdminDevicesPath, handlers.DeviceListHandler(config.Logger, deviceRegistry,
+		handlers.DeviceRegisterHandler(config.Logger, deviceRegistry)))
+	handle(handlers.AdminDeletedDevicesPath, handlers.DeletedDevicesHandler(config.Logger, deviceRegistry))
+	handle(handlers.AdminPolicyPath, handlers.PolicyExportHandler(config.Logger, policyEngine))
+	handle(handlers.AdminPolicyReviewPath, handlers.PolicyReviewHandler(config.Logger, policyEngine))
+	handle(handlers.AdminLockoutsPath, handlers.LockoutsHandler(config.Logger, lockout, auditLogger))
+	if config.OSCAL != nil {
+		handle(handlers.AdminOSCALComponentPath, handlers.OSCALComponentHandler(config.Logger, policyEngine, *config.OSCAL))
+		handle(handlers.AdminOSCALResultsPath, handlers.OSCALResultsHandler(config.Logger, policyEngine, deviceRegistry, *config.OSCAL))
+	}
+	if config.Jobs != nil {
+		handle(handlers.AdminJobsPath, handlers.JobsHandler(config.Logger, config.Jobs, auditLogger))
+		handle(handlers.AdminJobsPrefix, handlers.JobHandler(config.Logger, config.Jobs, auditLogger))
+		handle(handlers.InventoryGeneratePath, handlers.InventoryGenerateHandler(config.Logger, config.Jobs, auditLogger))
+		handle(handlers.InventoryJobsPrefix, handlers.InventoryJobHandler(config.Logger, config.Jobs))
+	}
+	handle(AdminMetricsPath, codegov.MetricsHandler())
+	if config.Watchdog != nil {
+		handle(AdminRuntimeMetricsPath, config.Watchdog.Handler())
+	}
+
+	// Internal services, reachable only through the clearance middleware and policy
+	for _, upstream := range config.Upstreams {
+		if config.ClearanceConfig != nil && config.ClearanceConfig.IdentityMapper != nil {
+			// Groups and claims the identity provider asserted are credentials too
+			strip := append([]string{}, upstream.StripHeaders...)
+			upstream.StripHeaders = append(strip, config.ClearanceConfig.IdentityMapper.Headers()...)
+		}
+		handle(upstream.Prefix, handlers.ProxyHandler(config.Logger, upstream, config.IdentitySigner, auditLogger))
+	}
 
 	// Apply middleware chain
 	middlewares := []func(http.Handler) http.Handler{
 		middleware.RequestID,
-		middleware.Recovery(config.Logger),
+		middleware.RouteName(func(r *http.Request) string {
+			return routeName(mux, templates, r)
+		}),
+		middleware.Recovery(config.Logger, auditLogger),
 		middleware.Logging(config.Logger),
 	}
 
@@ -53,6 +215,31 @@ func Setup(config *Config) http.Handler {
 	return handler
 }
 
+// inventoryHandler serves an inventory from its MinIO object or file, or returns nil
+// when neither is configured
+func inventoryHandler(config *Config, path string, object *handlers.MinIOObject) http.HandlerFunc {
+	if object != nil {
+		return handlers.MinIOCodeJSONHandler(config.Logger, object, config.InventoryRefresh)
+	}
+	if path != "" {
+		return handlers.CodeJSONHandler(config.Logger, path)
+	}
+	return nil
+}
+
+// routeName resolves the normalized route for a request from the mux pattern it matches
+func routeName(mux *http.ServeMux, templates map[string]func(path string) string, r *http.Request) string {
+	_, pattern := mux.Handler(r)
+	if template, ok := templates[pattern]; ok {
+		return template(r.URL.Path)
+	}
+	// The root pattern catches every unregistered path
+	if pattern == "" || (pattern == "/" && r.URL.Path != "/") {
+		return "unmatched"
+	}
+	return pattern
+}
+
 // rootHandler returns a simple root handler
 func rootHandler(logger *logging.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/NSACodeGov/CodeGov/codegov"
+	"github.com/NSACodeGov/CodeGov/internal/keys"
 )
 
 func main() {
@@ -19,24 +29,100 @@ func main() {
 		testTokenCmd    = flag.NewFlagSet("test-token", flag.ExitOnError)
 		testURLCmd      = flag.NewFlagSet("test-url", flag.ExitOnError)
 		overrideCmd     = flag.NewFlagSet("override", flag.ExitOnError)
+		diffCmd         = flag.NewFlagSet("diff", flag.ExitOnError)
+		mergeCmd        = flag.NewFlagSet("merge", flag.ExitOnError)
+		convertCmd      = flag.NewFlagSet("convert", flag.ExitOnError)
+		exportCmd       = flag.NewFlagSet("export", flag.ExitOnError)
+		statsCmd        = flag.NewFlagSet("stats", flag.ExitOnError)
+		reconcileCmd    = flag.NewFlagSet("reconcile", flag.ExitOnError)
+		publishCmd      = flag.NewFlagSet("publish", flag.ExitOnError)
+		signCmd         = flag.NewFlagSet("sign", flag.ExitOnError)
+		verifyCmd       = flag.NewFlagSet("verify", flag.ExitOnError)
 	)
 
 	// generate command flags
-	generateOrgs := generateCmd.String("orgs", "", "Comma-separated list of GitHub organizations")
+	generateOrgs := generateCmd.String("orgs", "", "Comma-separated list of GitHub organizations (prefix GitHub users with user:, using user:@me for the token's own account, GitLab groups with gitlab:, Bitbucket workspaces or projects with bitbucket: and Azure DevOps organizations or org/project pairs with azuredevops:)")
 	generateAgency := generateCmd.String("agency", "", "Agency name")
 	generateEmail := generateCmd.String("email", "", "Contact email")
 	generateName := generateCmd.String("name", "", "Contact name (optional)")
 	generateURL := generateCmd.String("url", "", "Contact URL (optional)")
 	generatePhone := generateCmd.String("phone", "", "Contact phone (optional)")
-	generateOutput := generateCmd.String("output", "code.json", "Output file path")
-	generatePrivate := generateCmd.Bool("include-private", false, "Include private repositories")
-	generateForks := generateCmd.Bool("include-forks", false, "Include fork repositories")
+	generateOrganization := generateCmd.String("organization", "", "Organization published on every release (default: the GitHub organization, GitLab group or Bitbucket workspace)")
+	generateDisclaimer := generateCmd.String("disclaimer-text", "", "Disclaimer text published on every release (optional)")
+	generatePrivateUsage := generateCmd.String("private-usage-type", "", "usageType of private repositories: governmentWideReuse or an exempt* type (default: governmentWideReuse)")
+	generateExemption := generateCmd.String("exemption-text", "", "Justification published with an exempt usage type (required with one)")
+	generateOutput := generateCmd.String("output", "code.json", "Output file path; a .yaml or .yml extension writes YAML")
+	generateCompact := generateCmd.Bool("compact", false, "Write JSON without indentation (smaller and faster for large inventories)")
+	generateSignKey := generateCmd.String("sign-key", "", "ed25519 private key PEM file to sign the inventory with (default: $"+signingKeyEnv+")")
+	generateSignFormat := generateCmd.String("sign-format", codegov.SignatureEd25519, "Signature format: ed25519 (<output>.sig) or jws (detached JWS, <output>.jws)")
+	generatePrivate := generateCmd.String("private", "", "Private repositories: exclude, include or only (default: exclude)")
+	generateForks := generateCmd.String("forks", "", "Fork repositories: exclude, include or only (default: exclude)")
+	generateArchived := generateCmd.String("archived", "", "Archived repositories: exclude, include or only (default: include)")
+	generateIncludePrivate := generateCmd.Bool("include-private", false, "Deprecated: same as --private=include")
+	generateIncludeForks := generateCmd.Bool("include-forks", false, "Deprecated: same as --forks=include")
+	generateInclude := generateCmd.String("include", "", "Comma-separated org/repo glob patterns to include, e.g. 'myorg/api-*' (default: all)")
+	generateExclude := generateCmd.String("exclude", "", "Comma-separated org/repo glob patterns to exclude")
+	generateIncludeTopics := generateCmd.String("include-topics", "", "Comma-separated topics; only repositories tagged with at least one are included")
+	generateExcludeTopics := generateCmd.String("exclude-topics", "", "Comma-separated topics; repositories tagged with any are excluded")
+	generateNameRegex := generateCmd.String("name-regex", "", "Regular expression the org/repo name must match")
+	generateSkipMetadata := generateCmd.Bool("skip-repo-metadata", false, "Ignore .codegov.yml and codeinventory.json files committed to repositories")
+	generateSkipLabor := generateCmd.Bool("skip-labor-estimate", false, "Publish laborHours 1 instead of estimating it from GitHub's language statistics")
+	generateDeep := generateCmd.Bool("deep-analysis", false, "Clone repositories and count SLOC per language locally")
+	generateDeepConcurrency := generateCmd.Int("analysis-concurrency", 4, "Maximum concurrent clones for deep analysis")
+	generateDeepCache := generateCmd.String("analysis-cache", "", "Directory for cached deep-analysis results (optional)")
+	generateCacheDir := generateCmd.String("cache-dir", "", "Directory for cached API responses, revalidated with ETags on later runs (optional)")
+	generateGitHubURL := generateCmd.String("github-url", "", "GitHub API base URL for GitHub Enterprise Server, e.g. https://github.example.gov/api/v3 (default: $GITHUB_BASE_URI or https://api.github.com)")
+	generateAppID := generateCmd.String("github-app-id", "", "Authenticate as this GitHub App instead of a personal token (default: $GITHUB_APP_ID)")
+	generateAppKey := generateCmd.String("github-app-key", "", "GitHub App private key PEM file (default: $GITHUB_APP_PRIVATE_KEY)")
+	generateAppInstallation := generateCmd.Int64("github-app-installation", 0, "GitHub App installation ID (default: looked up per organization)")
+	generateGitHubAPI := generateCmd.String("github-api", codegov.GitHubAPIAuto, "GitHub API to use: auto (GraphQL when a token is set), rest or graphql")
+	generateConcurrency := generateCmd.Int("concurrency", codegov.DefaultEnrichmentConcurrency, "Number of repositories enriched in parallel")
+	generateMaxWait := generateCmd.Duration("max-rate-limit-wait", codegov.DefaultMaxRateLimitWait, "Longest time to wait for an API rate limit to reset before giving up")
+	generateMaxRPS := generateCmd.Float64("max-rps", codegov.DefaultMaxRequestsPerSecond, "Maximum GitHub API requests per second (0 disables throttling)")
+	generateGitLabMaxRPS := generateCmd.Float64("gitlab-max-rps", codegov.DefaultGitLabMaxRequestsPerSecond, "Maximum GitLab API requests per second (0 disables throttling)")
+	generateRetryAttempts := generateCmd.Int("retry-attempts", codegov.DefaultRetryPolicy.MaxAttempts, "Attempts per API request on network errors and 5xx responses (1 disables retries)")
+	generateRetryDelay := generateCmd.Duration("retry-delay", codegov.DefaultRetryPolicy.BaseDelay, "Wait before the first retry, doubled for each further retry")
+	generateRetryMaxDelay := generateCmd.Duration("retry-max-delay", codegov.DefaultRetryPolicy.MaxDelay, "Longest wait between retries")
+	generateRetryJitter := generateCmd.Float64("retry-jitter", codegov.DefaultRetryPolicy.Jitter, "Fraction of each retry wait that is randomized (0-1)")
+	generateCheckURLs := generateCmd.Bool("check-urls", false, "Check every repository, download, homepage and license URL and report dead links as warnings")
+	generateDigests := generateCmd.String("download-digests", "", "Record the SHA-256 of each downloadURL artifact: sidecar (<output>.digests.json) or inline (additionalInformation.downloadSHA256)")
+	generateStrict := generateCmd.Bool("strict", false, "Exit non-zero when any organization or repository failed, or a release is missing data")
+	generateProgress := generateCmd.String("progress", progressAuto, "Progress output on stderr: auto (a bar on a terminal, plain otherwise), bar, plain or none")
+	generateUserAgent := generateCmd.String("user-agent", codegov.DefaultUserAgent, "User-Agent sent with API requests")
+	generateHeaders := headerFlags{}
+	generateCmd.Var(generateHeaders, "header", "Extra request header as 'Name: value', e.g. for proxy authentication (repeatable)")
+	generateProxy := generateCmd.String("proxy", "", "HTTP(S) proxy URL for API requests (default: $HTTPS_PROXY / $HTTP_PROXY)")
+	generateCAFile := generateCmd.String("ca-file", "", "Comma-separated PEM files of CAs to trust in addition to the system roots")
+	generatePrevious := generateCmd.String("previous", "", "Previously published code.json; notifications list the releases added, removed and changed since")
+	generateTombstoneDays := generateCmd.Int("tombstone-days", 0, "Keep releases of --previous that are no longer found as Archival tombstones for this many days (serve uses its newest version)")
+	generateWebhook := generateCmd.String("notify-webhook", "", "URL to POST a run summary to (Slack and Teams compatible)")
+	generateSMTPHost := generateCmd.String("smtp-host", "", "SMTP server for emailing a run summary")
+	generateSMTPPort := generateCmd.Int("smtp-port", 587, "SMTP server port")
+	generateSMTPUser := generateCmd.String("smtp-user", "", "SMTP user name (password from $SMTP_PASSWORD)")
+	generateSMTPFrom := generateCmd.String("smtp-from", "", "Sender address for summary emails")
+	generateSMTPTo := generateCmd.String("smtp-to", "", "Comma-separated recipients for summary emails")
+
+	// serve command flags, in addition to the generate flags
+	serveInterval, serveDir, serveKeep, serveListen := new(time.Duration), new(string), new(int), new(string)
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		serveInterval = generateCmd.Duration("interval", 24*time.Hour, "Time between generation runs")
+		serveDir = generateCmd.String("dir", "codegov-versions", "Directory holding the generated versions")
+		serveKeep = generateCmd.Int("keep", codegov.DefaultScheduleKeep, "Number of versions kept")
+		serveListen = generateCmd.String("listen", ":8080", "Address to serve /code.json and /versions on")
+	}
 
 	// validate command flags
 	validateInput := validateCmd.String("input", "", "Input JSON file to validate")
+	validateSchema := validateCmd.String("schema", "", "JSON Schema file to validate against (default: embedded code.gov 2.0.0 schema)")
+	validateCheckURLs := validateCmd.Bool("check-urls", false, "Also check every repository, download, homepage and license URL and fail on dead links")
+	validateLinkConcurrency := validateCmd.Int("link-concurrency", codegov.DefaultLinkCheckConcurrency, "Number of URLs checked in parallel")
+	validateFormat := validateCmd.String("format", "text", "Output format: text or json (results with path, severity, message and release index)")
 
 	// set-token command flags
-	setToken := setTokenCmd.String("token", "", "GitHub OAuth token")
+	setToken := setTokenCmd.String("token", "", "GitHub token (classic, ghp_ or fine-grained github_pat_)")
+	setTokenStore := setTokenCmd.String("store", "", "Where to keep the token: env (this process only), git-credential or keychain (default: $CODEGOV_TOKEN_STORE or env)")
+	setTokenSkipVerify := setTokenCmd.Bool("skip-verify", false, "Store the token without checking it against the GitHub API")
+	setTokenDelete := setTokenCmd.Bool("delete", false, "Remove the stored token instead of setting one")
 
 	// test-token command flags
 	testToken := testTokenCmd.String("token", "", "GitHub OAuth token to test (uses env var if not provided)")
@@ -45,9 +131,58 @@ func main() {
 	testURL := testURLCmd.String("url", "", "URL to test")
 
 	// override command flags
-	overrideOriginal := overrideCmd.String("original", "", "Original code.gov JSON file")
-	overrideNew := overrideCmd.String("new", "", "New code.gov JSON file")
-	overrideFile := overrideCmd.String("overrides", "", "Overrides JSON file")
+	overrideOriginal := overrideCmd.String("original", "", "Original code.gov JSON or YAML file")
+	overrideNew := overrideCmd.String("new", "", "New code.gov JSON file; a .yaml or .yml extension writes YAML")
+	overrideFile := overrideCmd.String("overrides", "", "Overrides JSON or YAML file, or RFC 6902 JSON Patch")
+
+	// diff command flags
+	diffOld := diffCmd.String("old", "", "Previously published code.gov JSON file")
+	diffNew := diffCmd.String("new", "", "Candidate code.gov JSON file")
+	diffFormat := diffCmd.String("format", "text", "Output format: text or json")
+
+	// merge command flags
+	mergeOutput := mergeCmd.String("output", "code.json", "Merged code.gov JSON file")
+
+	// convert command flags
+	convertInput := convertCmd.String("input", "", "Inventory to convert (.json, .yaml or .yml)")
+	convertOutput := convertCmd.String("output", "", "Converted inventory; the extension selects JSON or YAML")
+
+	// export command flags
+	exportInput := exportCmd.String("input", "", "Inventory to export (.json, .yaml or .yml)")
+	exportFormat := exportCmd.String("format", "csv", "Output format: csv or markdown")
+	exportOutput := exportCmd.String("output", "", "Output file (default: stdout)")
+
+	// stats command flags
+	statsInput := statsCmd.String("input", "", "Inventory to summarize (.json, .yaml or .yml)")
+	statsFormat := statsCmd.String("format", "text", "Output format: text or json")
+
+	// reconcile command flags
+	reconcileInput := reconcileCmd.String("input", "", "Generated inventory to compare (.json, .yaml or .yml)")
+	reconcileAgency := reconcileCmd.String("agency", "", "Agency acronym on code.gov (default: the inventory's agency)")
+	reconcileAPIKey := reconcileCmd.String("api-key", "", "api.data.gov key for the code.gov API (default: $CODEGOV_API_KEY)")
+	reconcileAPIURL := reconcileCmd.String("api-url", codegov.DefaultCodeGovAPIURL, "code.gov API base URL")
+	reconcileFormat := reconcileCmd.String("format", "text", "Output format: text or json")
+
+	// publish command flags
+	publishDest := publishCmd.String("dest", "", "Destination directory: s3://bucket/prefix, minio://bucket/prefix, https://host/path/, sftp://user@host/path or gh-pages://owner/repo/path")
+	publishCacheControl := publishCmd.String("cache-control", "", "Cache-Control header for S3 and HTTPS uploads, e.g. max-age=300")
+	publishBranch := publishCmd.String("branch", "gh-pages", "Branch gh-pages:// destinations commit to")
+	publishMessage := publishCmd.String("message", "", "Commit message for gh-pages:// destinations (default: Publish <file>)")
+	publishS3Endpoint := publishCmd.String("s3-endpoint", "", "S3-compatible endpoint host[:port], e.g. minio.example.gov:9000 (default: AWS S3)")
+	publishS3Region := publishCmd.String("s3-region", "", "S3 region (default: $AWS_REGION or us-east-1)")
+	publishS3Insecure := publishCmd.Bool("s3-insecure", false, "Use plain HTTP for the S3 endpoint")
+	publishHeaders := headerFlags{}
+	publishCmd.Var(publishHeaders, "header", "Extra header for HTTPS uploads as 'Name: value' (repeatable)")
+
+	// sign command flags
+	signInput := signCmd.String("input", "", "Inventory to sign (.json, .yaml or .yml)")
+	signKey := signCmd.String("key", "", "ed25519 private key PEM file (default: $"+signingKeyEnv+")")
+	signFormat := signCmd.String("format", codegov.SignatureEd25519, "Signature format: ed25519 (<input>.sig) or jws (detached JWS, <input>.jws)")
+
+	// verify command flags
+	verifyInput := verifyCmd.String("input", "", "Inventory to verify (.json, .yaml or .yml)")
+	verifySignature := verifyCmd.String("signature", "", "Signature file (default: <input>.sig, or <input>.jws when only that exists)")
+	verifyKey := verifyCmd.String("key", "", "ed25519 public key: PEM file or base64 encoded raw key")
 
 	if len(os.Args) < 2 {
 		printUsage()
@@ -55,7 +190,7 @@ func main() {
 	}
 
 	switch os.Args[1] {
-	case "generate":
+	case "generate", "serve":
 		generateCmd.Parse(os.Args[2:])
 		if *generateOrgs == "" || *generateAgency == "" || *generateEmail == "" {
 			fmt.Println("Error: --orgs, --agency, and --email are required")
@@ -63,30 +198,172 @@ func main() {
 			os.Exit(1)
 		}
 
-		agencyOptions := make(map[string]string)
-		if *generateName != "" {
-			agencyOptions["name"] = *generateName
-		}
-		if *generateURL != "" {
-			agencyOptions["url"] = *generateURL
-		}
-		if *generatePhone != "" {
-			agencyOptions["phone"] = *generatePhone
+		orgs := splitList(*generateOrgs)
+
+		transport, err := codegov.NewTransport(codegov.TransportConfig{
+			ProxyURL: *generateProxy,
+			CAFiles:  splitList(*generateCAFile),
+		})
+		if err != nil {
+			log.Fatalf("Error configuring HTTP transport: %v\n", err)
 		}
+		codegov.SetClientOptions(codegov.ClientOptions{Transport: transport})
+		codegov.SetMaxRequestsPerSecond(*generateMaxRPS)
+		codegov.SetGitLabMaxRequestsPerSecond(*generateGitLabMaxRPS)
+		codegov.SetMaxRateLimitWait(*generateMaxWait)
+		codegov.SetAnalysisConfig(codegov.AnalysisConfig{
+			Enabled:     *generateDeep,
+			Concurrency: *generateDeepConcurrency,
+			CacheDir:    *generateDeepCache,
+		})
 
-		orgs := strings.Split(*generateOrgs, ",")
-		for i := range orgs {
-			orgs[i] = strings.TrimSpace(orgs[i])
+		app, err := gitHubApp(*generateAppID, *generateAppKey, *generateAppInstallation)
+		if err != nil {
+			log.Fatalf("Error configuring GitHub App authentication: %v\n", err)
 		}
 
 		fmt.Printf("Generating code.gov JSON for organizations: %v\n", orgs)
 		fmt.Printf("Agency: %s\n", *generateAgency)
 
-		if err := codegov.NewCodeGovJSONFile(orgs, *generateAgency, *generateEmail, agencyOptions, *generatePrivate, *generateForks, *generateOutput); err != nil {
+		opts := codegov.GenerateOptions{
+			Organizations: orgs,
+			Agency:        *generateAgency,
+			Contact: codegov.Contact{
+				Email: *generateEmail,
+				Name:  *generateName,
+				URL:   *generateURL,
+				Phone: *generatePhone,
+			},
+			Organization:      *generateOrganization,
+			DisclaimerText:    *generateDisclaimer,
+			PrivateUsageType:  *generatePrivateUsage,
+			ExemptionText:     *generateExemption,
+			Private:           codegov.RepoFilter(*generatePrivate),
+			Forks:             codegov.RepoFilter(*generateForks),
+			Archived:          codegov.RepoFilter(*generateArchived),
+			IncludePrivate:    *generateIncludePrivate,
+			IncludeForks:      *generateIncludeForks,
+			Include:           splitList(*generateInclude),
+			Exclude:           splitList(*generateExclude),
+			IncludeTopics:     splitList(*generateIncludeTopics),
+			ExcludeTopics:     splitList(*generateExcludeTopics),
+			NameRegex:         *generateNameRegex,
+			SkipRepoMetadata:  *generateSkipMetadata,
+			SkipLaborEstimate: *generateSkipLabor,
+			CheckURLs:         *generateCheckURLs,
+			DownloadDigests:   *generateDigests,
+			TombstoneDays:     *generateTombstoneDays,
+			Credentials:       codegov.Credentials{GitHubApp: app},
+			Concurrency:       *generateConcurrency,
+			Compact:           *generateCompact,
+			UserAgent:         *generateUserAgent,
+			Headers:           generateHeaders,
+			GitHub:            codegov.GitHubConfig{BaseURI: *generateGitHubURL, API: *generateGitHubAPI},
+			Retry: codegov.RetryPolicy{
+				MaxAttempts: *generateRetryAttempts,
+				BaseDelay:   *generateRetryDelay,
+				MaxDelay:    *generateRetryMaxDelay,
+				Jitter:      *generateRetryJitter,
+			},
+		}
+
+		progress, err := newProgressPrinter(*generateProgress, os.Stderr)
+		if err != nil {
+			log.Fatalf("Error: %v\n", err)
+		}
+		if progress != nil {
+			opts.Progress = progress.report
+		}
+
+		var cache *codegov.ResponseCache
+		if *generateCacheDir != "" {
+			cache, err = codegov.NewResponseCache(*generateCacheDir, transport)
+			if err != nil {
+				log.Fatalf("Error opening response cache: %v\n", err)
+			}
+			opts.Transport = cache
+		}
+
+		if os.Args[1] == "serve" {
+			// Runs repeat unattended, so there is no progress bar to finish
+			opts.Progress = nil
+			if err := serveInventory(opts, *serveInterval, *serveDir, *serveKeep, *serveListen); err != nil {
+				log.Fatalf("Error: %v\n", err)
+			}
+			return
+		}
+
+		// Read the previous inventory first: it may be the file about to be overwritten
+		var previous *codegov.CodeGovJSON
+		if *generatePrevious != "" {
+			inventory, err := codegov.ReadCodeGovJSONFile(*generatePrevious)
+			if err != nil {
+				log.Fatalf("Error reading previous inventory: %v\n", err)
+			}
+			previous = inventory
+		}
+		if *generateTombstoneDays > 0 && previous == nil {
+			log.Fatalf("Error: --tombstone-days requires --previous\n")
+		}
+		opts.Previous = previous
+
+		report, err := codegov.GenerateFileWithReport(opts, *generateOutput)
+		if progress != nil {
+			progress.finish()
+		}
+		if report != nil {
+			printReport(report)
+		}
+		if err != nil {
+			if hint := apiErrorHint(err); hint != "" {
+				log.Fatalf("Error generating code.gov JSON: %v\n%s\n", err, hint)
+			}
 			log.Fatalf("Error generating code.gov JSON: %v\n", err)
 		}
 
 		fmt.Printf("Successfully generated code.gov JSON: %s\n", *generateOutput)
+		if *generateDigests == codegov.DownloadDigestsSidecar {
+			fmt.Printf("Download digests: %s\n", codegov.DigestsFile(*generateOutput))
+		}
+		signKeyPath := *generateSignKey
+		if signKeyPath == "" {
+			signKeyPath = os.Getenv(signingKeyEnv)
+		}
+		if signKeyPath != "" {
+			sigPath, err := signInventory(*generateOutput, signKeyPath, *generateSignFormat)
+			if err != nil {
+				log.Fatalf("Error signing code.gov JSON: %v\n", err)
+			}
+			fmt.Printf("Signature: %s\n", sigPath)
+		}
+		if cache != nil {
+			fmt.Printf("Response cache: %d unchanged, %d downloaded\n", cache.Hits(), cache.Misses())
+		}
+
+		notify := codegov.NotifyOptions{}
+		if *generateWebhook != "" {
+			notify.Webhook = &codegov.WebhookConfig{URL: *generateWebhook}
+		}
+		if *generateSMTPHost != "" {
+			notify.SMTP = &codegov.SMTPConfig{
+				Host:     *generateSMTPHost,
+				Port:     *generateSMTPPort,
+				Username: *generateSMTPUser,
+				From:     *generateSMTPFrom,
+				To:       splitList(*generateSMTPTo),
+			}
+		}
+		if notify.Webhook != nil || notify.SMTP != nil {
+			// A failed notification does not fail the run; the inventory was written
+			if err := sendRunSummary(notify, previous, *generateOutput); err != nil {
+				log.Printf("Warning: failed to send notifications: %v\n", err)
+			}
+		}
+
+		if *generateStrict && !report.Clean() {
+			fmt.Fprintf(os.Stderr, "Error: --strict: %d errors and %d warnings\n", len(report.Errors()), len(report.Warnings()))
+			os.Exit(1)
+		}
 
 	case "validate":
 		validateCmd.Parse(os.Args[2:])
@@ -96,36 +373,134 @@ func main() {
 			os.Exit(1)
 		}
 
-		fmt.Printf("Validating code.gov JSON: %s\n", *validateInput)
+		if *validateFormat != "text" && *validateFormat != "json" {
+			log.Fatalf("Unknown format %q (expected text or json)\n", *validateFormat)
+		}
+		if *validateFormat == "text" {
+			fmt.Printf("Validating code.gov JSON: %s\n", *validateInput)
+		}
+
+		schema := codegov.DefaultSchema()
+		if *validateSchema != "" {
+			custom, err := codegov.LoadSchema(*validateSchema)
+			if err != nil {
+				log.Fatalf("Error loading schema: %v\n", err)
+			}
+			schema = custom
+		}
 
-		isValid, errors, err := codegov.TestCodeGovJSONFile(*validateInput)
+		results, err := codegov.ValidateCodeGovJSONFile(*validateInput, schema)
 		if err != nil {
 			log.Fatalf("Error validating JSON: %v\n", err)
 		}
+		errors := codegov.ValidationErrors(results)
+		isValid := len(errors) == 0
+
+		var dead []codegov.DeadLink
+		if *validateCheckURLs {
+			inventory, err := codegov.ReadCodeGovJSONFile(*validateInput)
+			if err != nil {
+				log.Fatalf("Error reading inventory: %v\n", err)
+			}
+			dead = codegov.CheckURLs(inventory, *validateLinkConcurrency)
+		}
+		linksOK := len(dead) == 0
 
-		if isValid {
-			fmt.Println("✓ JSON is valid")
+		if *validateFormat == "json" {
+			output := struct {
+				Input     string                     `json:"input"`
+				Valid     bool                       `json:"valid"`
+				Results   []codegov.ValidationResult `json:"results"`
+				DeadLinks []codegov.DeadLink         `json:"dead_links,omitempty"`
+			}{*validateInput, isValid && linksOK, results, dead}
+			if output.Results == nil {
+				output.Results = []codegov.ValidationResult{}
+			}
+			data, err := json.MarshalIndent(output, "", "  ")
+			if err != nil {
+				log.Fatalf("Error encoding results: %v\n", err)
+			}
+			fmt.Println(string(data))
 		} else {
-			fmt.Println("✗ JSON is invalid:")
-			for _, e := range errors {
-				fmt.Printf("  - %s\n", e)
+			if isValid {
+				fmt.Println("✓ JSON is valid")
+			} else {
+				fmt.Println("✗ JSON is invalid:")
+				for _, e := range errors {
+					fmt.Printf("  - %s\n", e)
+				}
+			}
+			if warnings := len(results) - len(errors); warnings > 0 {
+				fmt.Printf("%d warnings:\n", warnings)
+				for _, result := range results {
+					if result.Severity == codegov.SeverityWarning {
+						fmt.Printf("  - %s: %s\n", result.Path, result.Message)
+					}
+				}
+			}
+			if *validateCheckURLs {
+				if linksOK {
+					fmt.Println("✓ All URLs are reachable")
+				} else {
+					fmt.Printf("✗ %d dead links:\n", len(dead))
+					for _, d := range dead {
+						fmt.Printf("  - %s\n", d)
+					}
+				}
 			}
+		}
+
+		if !isValid || !linksOK {
 			os.Exit(1)
 		}
 
 	case "set-token":
 		setTokenCmd.Parse(os.Args[2:])
+		kind := *setTokenStore
+		if kind == "" {
+			kind = os.Getenv(codegov.TokenStoreEnv)
+		}
+		if kind == "" {
+			kind = codegov.TokenStoreEnvVar
+		}
+		store, err := codegov.NewTokenStore(kind)
+		if err != nil {
+			log.Fatalf("Error: %v\n", err)
+		}
+
+		if *setTokenDelete {
+			if err := store.Delete(); err != nil {
+				log.Fatalf("Error deleting OAuth token: %v\n", err)
+			}
+			fmt.Printf("OAuth token removed from %s\n", kind)
+			break
+		}
+
 		if *setToken == "" {
 			fmt.Println("Error: --token is required")
 			setTokenCmd.PrintDefaults()
 			os.Exit(1)
 		}
+		if *setTokenSkipVerify && !codegov.ValidGitHubTokenFormat(*setToken) {
+			fmt.Fprintln(os.Stderr, "Warning: the token does not look like a GitHub token and was not verified")
+		}
+		if !*setTokenSkipVerify {
+			info, err := codegov.VerifyGitHubToken(&http.Client{Timeout: 10 * time.Second}, *setToken)
+			if err != nil {
+				log.Fatalf("Error verifying OAuth token: %v\n", err)
+			}
+			fmt.Printf("Token authenticates as %s\n", info.Login)
+		}
 
-		if err := codegov.SetOAuthToken(*setToken); err != nil {
+		if err := store.Set(*setToken); err != nil {
 			log.Fatalf("Error setting OAuth token: %v\n", err)
 		}
 
-		fmt.Println("OAuth token set successfully")
+		if kind == codegov.TokenStoreEnvVar {
+			fmt.Println("OAuth token set for this process only; use --store git-credential or --store keychain to keep it")
+		} else {
+			fmt.Printf("OAuth token stored in %s; set %s=%s so later runs read it\n", kind, codegov.TokenStoreEnv, kind)
+		}
 
 	case "get-token":
 		getTokenCmd.Parse(os.Args[2:])
@@ -144,12 +519,26 @@ func main() {
 			tokenToTest = *testToken
 		}
 
-		if codegov.TestOAuthToken(tokenToTest) {
-			fmt.Println("✓ Token is valid")
-		} else {
-			fmt.Println("✗ Token is invalid or not set")
+		if tokenToTest == "" {
+			tokenToTest = codegov.GetOAuthToken()
+		}
+		if tokenToTest == "" {
+			fmt.Println("✗ Token is not set")
+			os.Exit(1)
+		}
+
+		info, err := codegov.VerifyGitHubToken(&http.Client{Timeout: 10 * time.Second}, tokenToTest)
+		if err != nil {
+			fmt.Printf("✗ Token is invalid: %v\n", err)
 			os.Exit(1)
 		}
+		fmt.Printf("✓ Token is valid for %s\n", info.Login)
+		if len(info.Scopes) > 0 {
+			fmt.Printf("  Scopes: %s\n", strings.Join(info.Scopes, ", "))
+		}
+		if !info.ExpiresAt.IsZero() {
+			fmt.Printf("  Expires: %s\n", info.ExpiresAt.Format(time.RFC3339))
+		}
 
 	case "test-url":
 		testURLCmd.Parse(os.Args[2:])
@@ -182,6 +571,251 @@ func main() {
 
 		fmt.Printf("Successfully applied overrides: %s\n", *overrideNew)
 
+	case "diff":
+		diffCmd.Parse(os.Args[2:])
+		if *diffOld == "" || *diffNew == "" {
+			fmt.Println("Error: --old and --new are required")
+			diffCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		diff, err := codegov.DiffFiles(*diffOld, *diffNew)
+		if err != nil {
+			log.Fatalf("Error comparing code.gov JSON: %v\n", err)
+		}
+
+		switch *diffFormat {
+		case "json":
+			data, err := json.MarshalIndent(diff, "", "  ")
+			if err != nil {
+				log.Fatalf("Error encoding diff: %v\n", err)
+			}
+			fmt.Println(string(data))
+		case "text":
+			if err := diff.WriteText(os.Stdout); err != nil {
+				log.Fatalf("Error writing diff: %v\n", err)
+			}
+		default:
+			log.Fatalf("Unknown format %q (expected text or json)\n", *diffFormat)
+		}
+
+	case "merge":
+		mergeCmd.Parse(os.Args[2:])
+		inputs := mergeCmd.Args()
+		if len(inputs) < 2 {
+			fmt.Println("Error: at least two input files are required")
+			fmt.Println("Usage: codegov-cli merge [--output code.json] file1.json file2.json ...")
+			mergeCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		fmt.Printf("Merging %d code.gov JSON files\n", len(inputs))
+
+		if err := codegov.MergeFiles(*mergeOutput, inputs...); err != nil {
+			log.Fatalf("Error merging code.gov JSON: %v\n", err)
+		}
+
+		fmt.Printf("Successfully merged code.gov JSON: %s\n", *mergeOutput)
+
+	case "convert":
+		convertCmd.Parse(os.Args[2:])
+		if *convertInput == "" || *convertOutput == "" {
+			fmt.Println("Error: --input and --output are required")
+			convertCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		if err := codegov.ConvertCodeGovJSONFile(*convertInput, *convertOutput); err != nil {
+			log.Fatalf("Error converting inventory: %v\n", err)
+		}
+
+		fmt.Printf("Successfully converted %s to %s\n", *convertInput, *convertOutput)
+
+	case "export":
+		exportCmd.Parse(os.Args[2:])
+		if *exportInput == "" {
+			fmt.Println("Error: --input is required")
+			exportCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		var export func(w io.Writer, codeGov *codegov.CodeGovJSON) error
+		switch *exportFormat {
+		case "csv":
+			export = codegov.ExportCSV
+		case "markdown", "md":
+			export = codegov.ExportMarkdown
+		default:
+			log.Fatalf("Unknown format %q (expected csv or markdown)\n", *exportFormat)
+		}
+
+		codeGov, err := codegov.ReadCodeGovJSONFile(*exportInput)
+		if err != nil {
+			log.Fatalf("Error reading inventory: %v\n", err)
+		}
+
+		out := os.Stdout
+		if *exportOutput != "" {
+			if out, err = os.Create(*exportOutput); err != nil {
+				log.Fatalf("Error creating output file: %v\n", err)
+			}
+		}
+		if err := export(out, codeGov); err != nil {
+			log.Fatalf("Error exporting inventory: %v\n", err)
+		}
+		if *exportOutput != "" {
+			if err := out.Close(); err != nil {
+				log.Fatalf("Error writing output file: %v\n", err)
+			}
+			fmt.Printf("Successfully exported %d releases to %s\n", len(codeGov.Releases), *exportOutput)
+		}
+
+	case "stats":
+		statsCmd.Parse(os.Args[2:])
+		if *statsInput == "" {
+			fmt.Println("Error: --input is required")
+			statsCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		codeGov, err := codegov.ReadCodeGovJSONFile(*statsInput)
+		if err != nil {
+			log.Fatalf("Error reading inventory: %v\n", err)
+		}
+		summary := codegov.Summarize(codeGov)
+
+		switch *statsFormat {
+		case "json":
+			data, err := json.MarshalIndent(summary, "", "  ")
+			if err != nil {
+				log.Fatalf("Error encoding summary: %v\n", err)
+			}
+			fmt.Println(string(data))
+		case "text":
+			if err := summary.WriteText(os.Stdout); err != nil {
+				log.Fatalf("Error writing summary: %v\n", err)
+			}
+		default:
+			log.Fatalf("Unknown format %q (expected text or json)\n", *statsFormat)
+		}
+
+	case "reconcile":
+		reconcileCmd.Parse(os.Args[2:])
+		if *reconcileInput == "" {
+			fmt.Println("Error: --input is required")
+			reconcileCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		codeGov, err := codegov.ReadCodeGovJSONFile(*reconcileInput)
+		if err != nil {
+			log.Fatalf("Error reading inventory: %v\n", err)
+		}
+		agency := *reconcileAgency
+		if agency == "" {
+			agency = codeGov.Agency
+		}
+		apiKey := *reconcileAPIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("CODEGOV_API_KEY")
+		}
+
+		harvested, err := codegov.FetchHarvest(context.Background(), codegov.HarvestOptions{
+			Agency:  agency,
+			APIKey:  apiKey,
+			BaseURL: *reconcileAPIURL,
+		})
+		if err != nil {
+			log.Fatalf("Error fetching code.gov harvest: %v\n", err)
+		}
+		reconciliation := codegov.Reconcile(codeGov, agency, harvested)
+
+		switch *reconcileFormat {
+		case "json":
+			data, err := json.MarshalIndent(reconciliation, "", "  ")
+			if err != nil {
+				log.Fatalf("Error encoding reconciliation: %v\n", err)
+			}
+			fmt.Println(string(data))
+		case "text":
+			if err := reconciliation.WriteText(os.Stdout); err != nil {
+				log.Fatalf("Error writing reconciliation: %v\n", err)
+			}
+		default:
+			log.Fatalf("Unknown format %q (expected text or json)\n", *reconcileFormat)
+		}
+		if !reconciliation.InSync() {
+			os.Exit(1)
+		}
+
+	case "publish":
+		publishCmd.Parse(os.Args[2:])
+		files := publishCmd.Args()
+		if *publishDest == "" || len(files) == 0 {
+			fmt.Println("Error: --dest and at least one file are required")
+			fmt.Println("Usage: codegov-cli publish --dest s3://bucket/prefix code.json [report files...]")
+			publishCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		published, err := codegov.Publish(codegov.PublishOptions{
+			Destination:  *publishDest,
+			CacheControl: *publishCacheControl,
+			S3: codegov.S3Config{
+				Endpoint: *publishS3Endpoint,
+				Region:   *publishS3Region,
+				Insecure: *publishS3Insecure,
+			},
+			GitHub: codegov.GitHubPagesConfig{
+				Branch:  *publishBranch,
+				Message: *publishMessage,
+			},
+			Headers: publishHeaders,
+		}, files...)
+		if err != nil {
+			log.Fatalf("Error publishing: %v\n", err)
+		}
+
+		for _, file := range published {
+			fmt.Printf("✓ %s  %s\n", file.SHA256, file.URL)
+		}
+
+	case "sign":
+		signCmd.Parse(os.Args[2:])
+		keyPath := *signKey
+		if keyPath == "" {
+			keyPath = os.Getenv(signingKeyEnv)
+		}
+		if *signInput == "" || keyPath == "" {
+			fmt.Println("Error: --input and --key are required")
+			signCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		sigPath, err := signInventory(*signInput, keyPath, *signFormat)
+		if err != nil {
+			log.Fatalf("Error signing inventory: %v\n", err)
+		}
+		fmt.Printf("Signature: %s\n", sigPath)
+
+	case "verify":
+		verifyCmd.Parse(os.Args[2:])
+		if *verifyInput == "" || *verifyKey == "" {
+			fmt.Println("Error: --input and --key are required")
+			verifyCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		key, err := keys.LoadPublicKey(*verifyKey)
+		if err != nil {
+			log.Fatalf("Error: %v\n", err)
+		}
+		if err := codegov.VerifyCodeGovJSONFile(*verifyInput, *verifySignature, key); err != nil {
+			fmt.Printf("✗ %s: %v\n", *verifyInput, err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ %s: signature is valid\n", *verifyInput)
+
 	case "-h", "--help", "help":
 		printUsage()
 
@@ -200,12 +834,22 @@ Usage:
 
 Commands:
   generate      Generate code.gov JSON from GitHub organizations
+  serve         Regenerate code.gov JSON on a schedule and serve the latest over HTTP
   validate      Validate a code.gov JSON file
   set-token     Set GitHub OAuth token
   get-token     Get GitHub OAuth token
   test-token    Test GitHub OAuth token validity
   test-url      Test if a URL is accessible
   override      Apply overrides to code.gov JSON
+  diff          Show release changes between two code.gov JSON files
+  merge         Combine code.gov JSON files into one agency inventory
+  convert       Convert an inventory between JSON and YAML
+  export        Export the releases as a CSV spreadsheet or Markdown table
+  stats         Count releases by language, license, status, usage type and tag
+  reconcile     Compare an inventory with the releases code.gov has harvested
+  publish       Upload code.json and reports to S3/MinIO, HTTPS, SFTP or GitHub Pages
+  sign          Write a detached ed25519 or JWS signature for an inventory
+  verify        Check an inventory against its detached signature
   help          Show this help message
 
 Examples:
@@ -223,11 +867,197 @@ Examples:
   # Validate generated JSON
   codegov-cli validate --input code.json
 
+  # Also check that every URL in it is reachable
+  codegov-cli validate --input code.json --check-urls
+
   # Apply overrides
   codegov-cli override \
     --original code.json \
     --new code-final.json \
     --overrides overrides.json
 
+  # Combine sub-component inventories
+  codegov-cli merge --output code.json division-a.json division-b.json
+
+  # Review changes before publishing
+  codegov-cli diff --old published/code.json --new code-final.json
+
+  # Spreadsheet of the inventory for leadership reporting
+  codegov-cli export --input code.json --output inventory.csv
+
+  # Release counts for quarterly OMB reporting
+  codegov-cli stats --input code.json
+
+  # Check which releases code.gov has not harvested yet
+  CODEGOV_API_KEY=... codegov-cli reconcile --input code.json
+
+  # Sign the final inventory and check it before publishing
+  codegov-cli sign --input code-final.json --key signing-key.pem
+  codegov-cli verify --input code-final.json --key signing-key.pub
+
+  # Publish where code.gov harvests it
+  codegov-cli publish --dest s3://agency-www/ code.json
+
 Documentation: https://github.com/NSACodeGov/CodeGov`)
 }
+
+// gitHubApp builds the GitHub App authenticator from flags, falling back to the
+// GITHUB_APP_* environment variables; it returns nil when no app is configured
+func gitHubApp(appID, keyPath string, installationID int64) (*codegov.GitHubApp, error) {
+	if appID == "" {
+		app, err := codegov.GitHubAppFromEnv()
+		if app != nil && installationID != 0 {
+			app.InstallationID = installationID
+		}
+		return app, err
+	}
+
+	if keyPath == "" {
+		keyPath = os.Getenv(codegov.GitHubAppPrivateKeyEnv)
+	}
+	if keyPath == "" {
+		return nil, fmt.Errorf("--github-app-key is required with --github-app-id")
+	}
+	key := []byte(keyPath)
+	if !strings.Contains(keyPath, "-----BEGIN") {
+		var err error
+		if key, err = os.ReadFile(keyPath); err != nil {
+			return nil, err
+		}
+	}
+
+	app, err := codegov.NewGitHubApp(appID, key)
+	if err != nil {
+		return nil, err
+	}
+	app.InstallationID = installationID
+	return app, nil
+}
+
+// serveInventory regenerates the inventory every interval, keeping the last versions
+// in dir, and serves the newest until interrupted
+func serveInventory(opts codegov.GenerateOptions, interval time.Duration, dir string, keep int, listen string) error {
+	scheduler, err := codegov.NewScheduler(codegov.ScheduleOptions{
+		Generate: opts,
+		Interval: interval,
+		Dir:      dir,
+		Keep:     keep,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Addr: listen, Handler: scheduler.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go scheduler.Run(ctx)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Serving the inventory on %s (/code.json, /versions), regenerated every %s\n", listen, interval)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// signingKeyEnv holds the path or PEM contents of the key generate and sign use
+const signingKeyEnv = "CODEGOV_SIGNING_KEY"
+
+// signInventory signs an inventory with the ed25519 key in a PEM file, or PEM
+// contents passed directly, and returns the signature file written
+func signInventory(path, keyPath, format string) (string, error) {
+	var key ed25519.PrivateKey
+	var err error
+	if strings.Contains(keyPath, "-----BEGIN") {
+		key, err = keys.ParsePrivateKey([]byte(keyPath))
+	} else {
+		key, err = keys.LoadPrivateKey(keyPath)
+	}
+	if err != nil {
+		return "", err
+	}
+	return codegov.SignCodeGovJSONFile(path, key, format)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// headerFlags collects repeated --header 'Name: value' flags
+type headerFlags map[string]string
+
+func (h headerFlags) String() string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (h headerFlags) Set(value string) error {
+	name, v, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("header must be 'Name: value'")
+	}
+	h[strings.TrimSpace(name)] = strings.TrimSpace(v)
+	return nil
+}
+
+// apiErrorHint suggests a fix for a typed API error, or returns "" for other errors
+func apiErrorHint(err error) string {
+	switch {
+	case errors.Is(err, codegov.ErrUnauthorized):
+		return "Hint: the API token was rejected. Check it with 'codegov-cli test-token' and set " +
+			codegov.OAuthTokenEnv + " (GitHub) or " + codegov.GitLabTokenEnv + " (GitLab)."
+	case errors.Is(err, codegov.ErrForbidden):
+		return "Hint: the token lacks access. GitHub tokens need the read:org scope and, for SSO " +
+			"organizations, must be authorized for SSO; GitLab tokens need read_api."
+	case errors.Is(err, codegov.ErrOrgNotFound):
+		return "Hint: check the organization name. Private organizations are only visible with a " +
+			"token that can access them, and GitHub Enterprise Server needs --github-url."
+	case errors.Is(err, codegov.ErrRateLimited):
+		return "Hint: the API rate limit was reached. Set an API token for a higher limit, or raise " +
+			"--max-rate-limit-wait to wait for the reset."
+	}
+	return ""
+}
+
+// printReport prints a generation run's summary followed by its errors and warnings
+func printReport(report *codegov.GenerationReport) {
+	fmt.Printf("Summary: %s\n", report.Summary())
+	for _, issue := range report.Issues {
+		subject := issue.Repo
+		if subject == "" {
+			subject = issue.Org
+		}
+		fmt.Printf("  %s: %s (%s): %s\n", issue.Severity, subject, issue.Stage, issue.Message)
+	}
+}
+
+// sendRunSummary summarizes a generated inventory against the previous one and sends it
+func sendRunSummary(opts codegov.NotifyOptions, previous *codegov.CodeGovJSON, outputPath string) error {
+	current, err := codegov.ReadCodeGovJSONFile(outputPath)
+	if err != nil {
+		return err
+	}
+
+	summary, err := codegov.NewRunSummary(previous, current)
+	if err != nil {
+		return err
+	}
+
+	return codegov.Notify(opts, summary)
+}
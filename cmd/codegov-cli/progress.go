@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/codegov"
+)
+
+// Progress output modes for the generate command
+const (
+	progressAuto  = "auto"  // bar on a terminal, plain otherwise
+	progressBar   = "bar"   // A single redrawn line with a bar and percentage
+	progressPlain = "plain" // Log lines at every 10% and at least every 30 seconds, for CI logs
+	progressNone  = "none"
+)
+
+// progressHeartbeat is the longest plain mode stays silent while repositories are processed
+const progressHeartbeat = 30 * time.Second
+
+// progressPrinter writes generation progress to a terminal or log
+type progressPrinter struct {
+	w    io.Writer
+	bar  bool
+	now  func() time.Time
+	last time.Time // When plain mode last wrote a line
+	step int       // Last 10% step plain mode reported
+	open bool      // A bar line is drawn and needs a newline before other output
+}
+
+// newProgressPrinter returns a printer for the mode, or nil for none
+func newProgressPrinter(mode string, w *os.File) (*progressPrinter, error) {
+	switch mode {
+	case progressAuto:
+		info, err := w.Stat()
+		bar := err == nil && info.Mode()&os.ModeCharDevice != 0
+		return &progressPrinter{w: w, bar: bar, now: time.Now}, nil
+	case progressBar:
+		return &progressPrinter{w: w, bar: true, now: time.Now}, nil
+	case progressPlain:
+		return &progressPrinter{w: w, now: time.Now}, nil
+	case progressNone:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("invalid progress mode %q (want auto, bar, plain or none)", mode)
+}
+
+// report handles one progress event; it is used as the run's codegov.ProgressFunc
+func (p *progressPrinter) report(ev codegov.Progress) {
+	switch ev.Kind {
+	case codegov.ProgressOrgStarted:
+		p.line("Listing %s...", ev.Org)
+	case codegov.ProgressOrgListed:
+		p.line("Listed %s: %d repositories selected so far", ev.Org, ev.Total)
+	case codegov.ProgressOrgFailed:
+		p.line("Failed to list %s: %v", ev.Org, ev.Err)
+	case codegov.ProgressRepoFailed:
+		p.line("Failed %s: %v", ev.Repo, ev.Err)
+		p.repo(ev)
+	case codegov.ProgressRepoDone:
+		p.repo(ev)
+	}
+}
+
+// repo reports a processed repository
+func (p *progressPrinter) repo(ev codegov.Progress) {
+	percent := 100
+	if ev.Total > 0 {
+		percent = ev.Done * 100 / ev.Total
+	}
+
+	if p.bar {
+		const width = 30
+		filled := width * percent / 100
+		fmt.Fprintf(p.w, "\r\033[K[%s%s] %3d%% %d/%d %s",
+			strings.Repeat("=", filled), strings.Repeat(" ", width-filled), percent, ev.Done, ev.Total, ev.Repo)
+		p.open = true
+		if ev.Done == ev.Total {
+			p.finish()
+		}
+		return
+	}
+
+	if step := percent / 10; step > p.step || ev.Done == ev.Total || p.now().Sub(p.last) >= progressHeartbeat {
+		p.step = step
+		p.line("Processed %d/%d repositories (%d%%)", ev.Done, ev.Total, percent)
+	}
+}
+
+// line writes a full line, ending a drawn bar first
+func (p *progressPrinter) line(format string, args ...interface{}) {
+	p.finish()
+	fmt.Fprintf(p.w, format+"\n", args...)
+	p.last = p.now()
+}
+
+// finish ends a drawn bar so later output starts on a new line
+func (p *progressPrinter) finish() {
+	if p.open {
+		fmt.Fprintln(p.w)
+		p.open = false
+	}
+}
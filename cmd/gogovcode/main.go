@@ -1,19 +1,41 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/NSACodeGov/CodeGov/api/handlers"
 	"github.com/NSACodeGov/CodeGov/api/middleware"
 	"github.com/NSACodeGov/CodeGov/api/routes"
+	"github.com/NSACodeGov/CodeGov/codegov"
 	"github.com/NSACodeGov/CodeGov/config"
 	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/breaker"
+	"github.com/NSACodeGov/CodeGov/internal/buildinfo"
+	"github.com/NSACodeGov/CodeGov/internal/bundle"
+	"github.com/NSACodeGov/CodeGov/internal/devconfig"
+	"github.com/NSACodeGov/CodeGov/internal/egress"
+	"github.com/NSACodeGov/CodeGov/internal/geoip"
 	"github.com/NSACodeGov/CodeGov/internal/health"
+	"github.com/NSACodeGov/CodeGov/internal/ingest"
+	"github.com/NSACodeGov/CodeGov/internal/jobs"
+	"github.com/NSACodeGov/CodeGov/internal/keys"
 	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/internal/oscal"
 	"github.com/NSACodeGov/CodeGov/internal/policy"
+	"github.com/NSACodeGov/CodeGov/internal/redis"
 	"github.com/NSACodeGov/CodeGov/internal/server"
+	"github.com/NSACodeGov/CodeGov/internal/watchdog"
+	"github.com/NSACodeGov/CodeGov/pkg/identity"
 	"github.com/NSACodeGov/CodeGov/pkg/models"
 )
 
@@ -49,28 +71,235 @@ func run() error {
 		"profile": cfg.Profile,
 	})
 
-	// Initialize device registry
-	deviceRegistry := models.NewDeviceRegistry()
-
-	// Register example devices for testing
-	registerExampleDevices(deviceRegistry, logger)
-
 	// Initialize audit logger
 	auditLogger := audit.NewLogger()
-	auditLogger.AddWriter(audit.NewStdoutWriter())
+	if len(cfg.Audit.Sinks) == 0 {
+		auditLogger.AddWriter(audit.NewStdoutWriter())
+	}
+	for _, sink := range cfg.Audit.Sinks {
+		writer, err := sink.NewWriter() // Transforms checked by cfg.Validate
+		if err != nil {
+			return fmt.Errorf("failed to open audit sink: %w", err)
+		}
+		auditLogger.AddWriter(writer)
+	}
+	if cfg.Audit.InstanceID != "" {
+		auditLogger.SetInstanceID(cfg.Audit.InstanceID)
+	}
+
+	// Annotate audit events with source country/ASN if configured
+	if cfg.Audit.GeoIP.Enabled {
+		resolver, err := geoip.NewResolver(cfg.Audit.GeoIP.CountryDB, cfg.Audit.GeoIP.ASNDB)
+		if err != nil {
+			return fmt.Errorf("failed to load GeoIP databases: %w", err)
+		}
+		auditLogger.AddEnricher(audit.NewGeoIPEnricher(resolver))
+	}
+
+	// Outbound requests the server makes itself go through the egress allowlist if configured
+	outbound := &http.Client{Timeout: 30 * time.Second}
+	if cfg.Egress.Enabled {
+		allowlist, err := egress.New(cfg.Egress.Allow, cfg.Egress.Proxy) // Checked by cfg.Validate
+		if err != nil {
+			return err
+		}
+		allowlist.OnDeny = func(host, reason string) {
+			logger.Warn("outbound request denied", map[string]interface{}{
+				"host":   host,
+				"reason": reason,
+			})
+			event := audit.NewEvent(audit.DecisionDeny, egress.AuditAction, host, reason)
+			event.Actor = "system"
+			auditLogger.Log(event)
+		}
+		outbound.Transport = allowlist.Transport()
+	}
+
+	// Initialize device registry
+	deviceRegistry := models.NewDeviceRegistry()
+	deviceRegistry.SetObserver(func(change models.RegistryChange) {
+		// Changes made through the admin API carry the authenticated device
+		actor := change.Actor
+		if actor == "" {
+			actor = "system"
+		}
+		auditLogger.Log(audit.NewChangeEvent(actor, "device."+change.Action,
+			fmt.Sprintf("device/%d", change.DeviceID), change.Before, change.After))
+	})
+	if cfg.DeviceRetention != "" {
+		retention, _ := time.ParseDuration(cfg.DeviceRetention) // Checked by cfg.Validate
+		deviceRegistry.SetRetention(retention)
+	}
+	if len(cfg.DeviceIDs.Ranges) > 0 {
+		ranges := make([]models.IDRange, 0, len(cfg.DeviceIDs.Ranges))
+		for _, r := range cfg.DeviceIDs.Ranges {
+			ranges = append(ranges, models.IDRange{
+				Layer: models.Layer(r.Layer),
+				Class: models.DeviceClass(r.Class),
+				Min:   uint16(r.Min),
+				Max:   uint16(r.Max),
+			})
+		}
+		if err := deviceRegistry.SetIDRanges(ranges); err != nil {
+			return fmt.Errorf("invalid device ID ranges: %w", err)
+		}
+	}
 
 	// Initialize policy engine
 	policyEngine := policy.NewEngine(deviceRegistry)
+	policyEngine.SetObserver(func(change policy.Change) {
+		resource := "policy"
+		if change.RuleID != "" {
+			resource = "policy/rules/" + change.RuleID
+		}
+		auditLogger.Log(audit.NewChangeEvent("system", "policy."+change.Action, resource, change.Before, change.After))
+	})
 
-	// Load default policy (or from file if specified)
-	loadDefaultPolicy(policyEngine, logger)
+	// The inventories are granted by policy rules like any other route, so the
+	// middleware enforces them and denials carry the usual remediation. The rules are
+	// reinstalled whenever a policy is loaded.
+	installPolicyRules := func() error {
+		if cfg.CodeGov.PrivateJSONPath != "" {
+			if err := policyEngine.UpsertRule(privateInventoryRule(cfg.CodeGov.PrivateClearance)); err != nil {
+				return fmt.Errorf("failed to install private inventory rule: %w", err)
+			}
+		}
+		if cfg.CodeGov.InventoryClearance > 0 {
+			if err := policyEngine.UpsertRule(inventoryRule(cfg.CodeGov.InventoryClearance, cfg.CodeGov.Internal)); err != nil {
+				return fmt.Errorf("failed to install inventory rule: %w", err)
+			}
+		}
+		return nil
+	}
+
+	// Load devices and policy from a signed bundle or from MinIO objects kept in
+	// sync, or fall back to the built-in defaults
+	var policySyncer *bundle.Syncer
+	if cfg.Policy.Bundle.URL != "" {
+		if err := bootstrapFromBundle(cfg, outbound, deviceRegistry, policyEngine, logger); err != nil {
+			return fmt.Errorf("failed to bootstrap from policy bundle: %w", err)
+		}
+		if err := installPolicyRules(); err != nil {
+			return err
+		}
+	} else if cfg.Policy.Objects.PolicyKey != "" {
+		syncer, err := bootstrapFromObjects(cfg, outbound, deviceRegistry, policyEngine, installPolicyRules, logger)
+		if err != nil {
+			return fmt.Errorf("failed to bootstrap from policy objects: %w", err)
+		}
+		policySyncer = syncer
+	} else {
+		// Register example devices for testing
+		registerExampleDevices(deviceRegistry, logger)
+
+		// Load default policy (or from file if specified)
+		loadDefaultPolicy(policyEngine, logger)
+
+		if err := installPolicyRules(); err != nil {
+			return err
+		}
+	}
+
+	// Record decisions for offline replay if configured
+	if cfg.Policy.ReplayLog != "" {
+		recorder, err := policy.NewFileRecorder(cfg.Policy.ReplayLog)
+		if err != nil {
+			return fmt.Errorf("failed to open policy replay log: %w", err)
+		}
+		defer recorder.Close()
+		policyEngine.SetRecorder(recorder)
+
+		logger.Info("recording policy decisions", map[string]interface{}{
+			"path": cfg.Policy.ReplayLog,
+		})
+	}
+
+	// Background monitors stop when the server shuts down
+	monitorCtx, stopMonitors := context.WithCancel(context.Background())
+	defer stopMonitors()
+
+	// Pick up policy and device changes published to MinIO
+	if policySyncer != nil && cfg.Policy.Objects.Refresh != "" {
+		go policySyncer.Run(monitorCtx, parseDuration(cfg.Policy.Objects.Refresh), func(result bundle.SyncResult, err error) {
+			logSyncResult(logger, result, err)
+		})
+	}
 
 	// Initialize health checker
 	healthChecker := health.New(cfg.Service.Name, cfg.Service.Version)
 
+	// Register health check groups: enforcement must be healthy, storage may degrade
+	healthChecker.RegisterGroup("policy", true, 2)
+	healthChecker.RegisterGroup("storage", false, 1)
+
 	// Register health checks
-	healthChecker.RegisterCheck("redis", health.RedisCheck(cfg.Redis.Endpoint, cfg.Redis.Enabled), false)
-	healthChecker.RegisterCheck("minio", health.MinIOCheck(cfg.MinIO.Endpoint, cfg.MinIO.Enabled), false)
+	healthChecker.RegisterGroupCheck("policy", "policy-engine", func(ctx context.Context) error {
+		if len(policyEngine.GetPolicy().Rules) == 0 {
+			return fmt.Errorf("no policy rules loaded")
+		}
+		return nil
+	}, true, 1)
+	// Expired rules no longer match; report them as a warning until the policy is recertified
+	healthChecker.RegisterGroupCheck("policy", "policy-expiry", func(ctx context.Context) error {
+		expired := policyEngine.ExpiredRules()
+		if len(expired) == 0 {
+			return nil
+		}
+		ids := make([]string, len(expired))
+		for i, rule := range expired {
+			ids[i] = rule.ID
+		}
+		return fmt.Errorf("%d expired policy rule(s): %s", len(ids), strings.Join(ids, ", "))
+	}, false, 1)
+	var redisMonitor *redis.Monitor
+	if cfg.Redis.Enabled {
+		// Probe Redis so the data stream backend fails fast while it is down and
+		// readiness reports degraded rather than each request timing out
+		redisMonitor = redis.NewMonitor(cfg.Redis.Endpoint, cfg.Redis.Password, redis.DefaultProbeInterval)
+		redisMonitor.OnChange(func(available bool, err error) {
+			if available {
+				logger.Info("redis available, leaving degraded mode", map[string]interface{}{
+					"endpoint": cfg.Redis.Endpoint,
+				})
+				return
+			}
+			logger.Warn("redis unavailable, degraded to local-only state", map[string]interface{}{
+				"endpoint": cfg.Redis.Endpoint,
+				"error":    err.Error(),
+			})
+		})
+		go redisMonitor.Run(monitorCtx)
+		healthChecker.RegisterGroupCheck("storage", "redis", redisMonitor.HealthCheck(), false, 1)
+	} else {
+		healthChecker.RegisterGroupCheck("storage", "redis", health.RedisCheck(cfg.Redis.Endpoint, cfg.Redis.Enabled), false, 1)
+	}
+	healthChecker.RegisterGroupCheck("storage", "minio", health.MinIOCheck(cfg.MinIO.Endpoint, cfg.MinIO.Enabled), false, 1)
+
+	// Slow leaks degrade readiness and are logged long before they exhaust the host
+	var runtimeWatchdog *watchdog.Watchdog
+	if cfg.Watchdog.Enabled {
+		runtimeWatchdog = watchdog.New(watchdog.Thresholds{
+			HeapBytes:  uint64(cfg.Watchdog.MaxHeapMB) << 20,
+			Goroutines: cfg.Watchdog.MaxGoroutines,
+			AuditQueue: int64(cfg.Watchdog.MaxAuditQueue),
+		}, parseDuration(cfg.Watchdog.Interval), auditLogger.Pending)
+		runtimeWatchdog.OnChange(func(sample watchdog.Sample, exceeded []string) {
+			fields := map[string]interface{}{
+				"heap_bytes":  sample.HeapBytes,
+				"goroutines":  sample.Goroutines,
+				"audit_queue": sample.AuditQueue,
+			}
+			if len(exceeded) == 0 {
+				logger.Info("runtime back under watchdog thresholds", fields)
+				return
+			}
+			fields["exceeded"] = exceeded
+			logger.Warn("runtime past watchdog thresholds", fields)
+		})
+		go runtimeWatchdog.Run(monitorCtx)
+		healthChecker.RegisterGroup("runtime", false, 1)
+		healthChecker.RegisterGroupCheck("runtime", "watchdog", runtimeWatchdog.HealthCheck(), false, 1)
+	}
 
 	// Configure clearance middleware
 	clearanceConfig := &middleware.ClearanceConfig{
@@ -81,11 +310,187 @@ func run() error {
 		Enabled:        true, // Enable clearance enforcement
 	}
 
+	// Per-route audit detail levels
+	clearanceConfig.DefaultAuditLevel = audit.Level(cfg.Audit.DefaultLevel)
+	clearanceConfig.AuditLevels = make(map[string]audit.Level, len(cfg.Audit.RouteLevels))
+	for route, level := range cfg.Audit.RouteLevels {
+		clearanceConfig.AuditLevels[route] = audit.Level(level)
+	}
+	for _, header := range cfg.Audit.RedactHeaders {
+		if header = strings.TrimSpace(header); header != "" {
+			clearanceConfig.AuditRedactHeaders = append(clearanceConfig.AuditRedactHeaders, header)
+		}
+	}
+
+	// Cap concurrent requests per device
+	if cfg.DeviceLimits.MaxInFlight > 0 || len(cfg.DeviceLimits.LayerMaxInFlight) > 0 {
+		clearanceConfig.DeviceLimiter = middleware.NewDeviceLimiter(cfg.DeviceLimits.MaxInFlight)
+		for layer, limit := range cfg.DeviceLimits.LayerMaxInFlight {
+			clearanceConfig.DeviceLimiter.SetLayerLimit(models.Layer(layer), limit)
+		}
+	}
+
+	// Lock out sources and devices after repeated authentication failures
+	if cfg.Lockout.Enabled {
+		trustedProxies, err := cfg.Lockout.ProxyNetworks() // Checked by cfg.Validate
+		if err != nil {
+			return err
+		}
+		clearanceConfig.Lockout = middleware.NewLockout(middleware.LockoutPolicy{
+			MaxFailures:    cfg.Lockout.MaxFailures,
+			Window:         parseDuration(cfg.Lockout.Window),
+			BaseDelay:      parseDuration(cfg.Lockout.BaseDelay),
+			MaxDelay:       parseDuration(cfg.Lockout.MaxDelay),
+			BanDuration:    parseDuration(cfg.Lockout.BanDuration),
+			TrustedProxies: trustedProxies,
+		})
+	}
+
+	// Derive clearance and layer from identity provider attributes
+	identityMapper, err := cfg.IdentityMapping.NewMapper() // Checked by cfg.Validate
+	if err != nil {
+		return err
+	}
+	if identityMapper != nil {
+		clearanceConfig.IdentityMapper = identityMapper
+		logger.Info("identity mapping enabled", map[string]interface{}{
+			"rules":         len(cfg.IdentityMapping.Rules),
+			"authoritative": cfg.IdentityMapping.Authoritative,
+			"client_cert":   cfg.IdentityMapping.ClientCert,
+		})
+	}
+
+	// Order the identity providers configured for this profile
+	if names := cfg.IdentityProviderChain(); names != nil {
+		providers, err := middleware.NewIdentityProviders(names, clearanceConfig)
+		if err != nil {
+			return err
+		}
+		clearanceConfig.IdentityProviders = providers
+		logger.Info("identity providers configured", map[string]interface{}{
+			"profile":   cfg.Profile,
+			"providers": names,
+		})
+	}
+
 	// Setup routes
 	routeConfig := &routes.Config{
-		Logger:          logger,
-		HealthChecker:   healthChecker,
-		ClearanceConfig: clearanceConfig,
+		Logger:            logger,
+		HealthChecker:     healthChecker,
+		ClearanceConfig:   clearanceConfig,
+		CodeJSONPath:      cfg.CodeGov.JSONPath,
+		PrivateJSONPath:   cfg.CodeGov.PrivateJSONPath,
+		CodeJSONObject:    inventoryObject(cfg, cfg.CodeGov.JSONPath),
+		PrivateJSONObject: inventoryObject(cfg, cfg.CodeGov.PrivateJSONPath),
+		InventoryRefresh:  parseDuration(cfg.CodeGov.Refresh),
+		InternalInventory: cfg.CodeGov.Internal,
+		Watchdog:          runtimeWatchdog,
+		Subsystems: map[string]bool{
+			"clearance":     clearanceConfig.Enabled,
+			"lockout":       cfg.Lockout.Enabled,
+			"tls":           cfg.TLS.Enabled,
+			"redis":         cfg.Redis.Enabled,
+			"minio":         cfg.MinIO.Enabled,
+			"geoip":         cfg.Audit.GeoIP.Enabled,
+			"policy_bundle": cfg.Policy.Bundle.URL != "",
+			"policy_sync":   cfg.Policy.Objects.PolicyKey != "",
+			"policy_replay": cfg.Policy.ReplayLog != "",
+			"code_json":     cfg.CodeGov.JSONPath != "",
+			"private_json":  cfg.CodeGov.PrivateJSONPath != "",
+			"internal_json": cfg.CodeGov.Internal,
+			"site":          cfg.Site.Enabled,
+			"proxy":         len(cfg.Proxy.Upstreams) > 0,
+			"egress":        cfg.Egress.Enabled,
+			"watchdog":      cfg.Watchdog.Enabled,
+			"device_config": cfg.DeviceConfig.Dir != "",
+			"data_ingest":   len(cfg.DataIngest.Backends) > 0,
+			"identity_map":  identityMapper != nil,
+			"jobs":          cfg.Jobs.Dir != "",
+		},
+	}
+	routeConfig.OSCAL = newOSCALSystem(cfg, clearanceConfig.Enabled, auditLogger.InstanceID())
+	if cfg.Site.Enabled {
+		routeConfig.Site = handlers.EmbeddedSite()
+		if cfg.Site.Dir != "" {
+			routeConfig.Site = os.DirFS(cfg.Site.Dir)
+		}
+		routeConfig.SiteOptions = handlers.StaticOptions{
+			MaxAge:                parseDuration(cfg.Site.CacheMaxAge),
+			ContentSecurityPolicy: cfg.Site.ContentSecurityPolicy,
+		}
+	}
+	if cfg.Proxy.SigningKey != "" {
+		key, err := keys.LoadPrivateKey(cfg.Proxy.SigningKey)
+		if err != nil {
+			return fmt.Errorf("failed to load proxy signing key: %w", err)
+		}
+		routeConfig.IdentitySigner = identity.NewSigner(key)
+	}
+	if cfg.DeviceConfig.Dir != "" {
+		key, err := keys.LoadPrivateKey(cfg.DeviceConfig.SigningKey)
+		if err != nil {
+			return fmt.Errorf("failed to load device config signing key: %w", err)
+		}
+		routeConfig.DeviceConfigs = devconfig.NewStore(cfg.DeviceConfig.Dir, key)
+		logger.Info("serving device configuration", map[string]interface{}{
+			"dir": cfg.DeviceConfig.Dir,
+		})
+	}
+	if len(cfg.DataIngest.Backends) > 0 {
+		routeConfig.DataIngester = newDataIngester(cfg, redisMonitor)
+		logger.Info("accepting device data", map[string]interface{}{
+			"backends": routeConfig.DataIngester.Backends(),
+			"reader":   routeConfig.DataIngester.Reader(),
+		})
+	}
+	if cfg.Jobs.Dir != "" {
+		manager, err := newJobManager(cfg, outbound, deviceRegistry, policyEngine)
+		if err != nil {
+			return err
+		}
+		go manager.Run(monitorCtx)
+		routeConfig.Jobs = manager
+		logger.Info("running background jobs", map[string]interface{}{
+			"dir":   cfg.Jobs.Dir,
+			"kinds": manager.Kinds(),
+		})
+	}
+	if len(cfg.Proxy.Upstreams) > 0 {
+		// A failing upstream degrades readiness instead of taking the service down
+		healthChecker.RegisterGroup("upstreams", false, 1)
+	}
+	for _, upstream := range cfg.Proxy.Upstreams {
+		target, _ := url.Parse(upstream.URL) // Checked by cfg.Validate
+		circuit := breaker.New("upstream "+upstream.Prefix, upstream.FailureThreshold, parseDuration(upstream.Cooldown))
+		circuit.OnChange(func(name string, from, to breaker.State) {
+			logger.Warn("circuit state changed", map[string]interface{}{
+				"circuit": name,
+				"from":    from,
+				"to":      to,
+			})
+		})
+		healthChecker.RegisterGroupCheck("upstreams", "upstream:"+upstream.Prefix, circuit.HealthCheck(), false, 1)
+
+		ratio := upstream.RetryBudget
+		if ratio == 0 {
+			ratio = breaker.DefaultRetryRatio
+		}
+		routeConfig.Upstreams = append(routeConfig.Upstreams, handlers.Upstream{
+			Prefix:          upstream.Prefix,
+			Target:          target,
+			StripPrefix:     upstream.StripPrefix,
+			ForwardIdentity: upstream.ForwardIdentity,
+			Timeout:         parseDuration(upstream.Timeout),
+			ConnectTimeout:  parseDuration(upstream.ConnectTimeout),
+			Retries:         upstream.Retries,
+			RetryBudget:     breaker.NewRetryBudget(ratio, 1),
+			Breaker:         circuit,
+		})
+		logger.Info("proxying upstream", map[string]interface{}{
+			"prefix":           upstream.Prefix,
+			"upstream":         target.Redacted(),
+			"forward_identity": upstream.ForwardIdentity,
+		})
 	}
 	handler := routes.Setup(routeConfig)
 
@@ -100,16 +505,396 @@ func run() error {
 	})
 
 	// Start server (blocks until shutdown)
-	if err := srv.Start(context.Background()); err != nil {
-		return fmt.Errorf("server error: %w", err)
-	}
+	serveErr := srv.Start(context.Background())
 
 	// Cleanup
 	auditLogger.Close()
+	reportShutdown(cfg, logger, srv, policyEngine, auditLogger)
+
+	if serveErr != nil {
+		return fmt.Errorf("server error: %w", serveErr)
+	}
+	return nil
+}
+
+// shutdownReport is the final summary operations runbooks use for incident timelines
+type shutdownReport struct {
+	StoppedAt time.Time   `json:"stopped_at"`
+	Uptime    string      `json:"uptime"`
+	Requests  uint64      `json:"requests"`
+	Allowed   uint64      `json:"allowed"` // Policy decisions
+	Denied    uint64      `json:"denied"`
+	Audit     audit.Stats `json:"audit"`
+}
+
+// reportShutdown logs the shutdown report and writes it to the configured file
+func reportShutdown(cfg *config.Config, logger *logging.Logger, srv *server.Server, engine *policy.Engine, auditLogger *audit.Logger) {
+	report := shutdownReport{
+		StoppedAt: time.Now().UTC(),
+		Uptime:    srv.Uptime().Round(time.Second).String(),
+		Requests:  srv.Requests(),
+		Audit:     auditLogger.Stats(),
+	}
+	report.Allowed, report.Denied = engine.Decisions()
+
+	logger.Info("shutdown report", map[string]interface{}{
+		"uptime":        report.Uptime,
+		"requests":      report.Requests,
+		"allowed":       report.Allowed,
+		"denied":        report.Denied,
+		"audit_writers": report.Audit.Writers,
+		"audit_flushed": report.Audit.Flushed,
+		"audit_dropped": report.Audit.Dropped,
+	})
+
+	if cfg.Server.ShutdownReport == "" {
+		return
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err == nil {
+		err = os.WriteFile(cfg.Server.ShutdownReport, append(data, '\n'), 0644)
+	}
+	if err != nil {
+		logger.Error("failed to write shutdown report", map[string]interface{}{
+			"path":  cfg.Server.ShutdownReport,
+			"error": err.Error(),
+		})
+	}
+}
+
+// policyReplayJobParams are the parameters of a "policy-replay" job
+type policyReplayJobParams struct {
+	Policy json.RawMessage `json:"policy"` // Candidate policy; empty replays against the active policy
+}
+
+// newJobManager creates the job manager with the job kinds this configuration supports:
+// "inventory" generates a code.gov inventory, and "policy-replay" replays the recorded
+// policy decisions against a candidate policy
+func newJobManager(cfg *config.Config, outbound *http.Client, registry *models.DeviceRegistry, engine *policy.Engine) (*jobs.Manager, error) {
+	manager, err := jobs.New(cfg.Jobs.Dir, jobs.Options{
+		Workers:   cfg.Jobs.Workers,
+		Retention: parseDuration(cfg.Jobs.Retention),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	manager.Register(handlers.InventoryJobKind, func(ctx context.Context, raw json.RawMessage) (*jobs.Result, error) {
+		var params handlers.InventoryJobParams
+		if err := decodeJobParams(raw, &params); err != nil {
+			return nil, err
+		}
+		opts := codegov.GenerateOptions{
+			Organizations: params.Organizations,
+			Agency:        params.Agency,
+			Contact:       codegov.Contact{Email: params.Email},
+			Organization:  params.Organization,
+			Include:       params.Include,
+			Exclude:       params.Exclude,
+			Transport:     outbound.Transport, // Nil without an egress allowlist
+			Context:       ctx,
+		}
+		for _, filter := range []struct {
+			value  string
+			target *codegov.RepoFilter
+		}{{params.Private, &opts.Private}, {params.Forks, &opts.Forks}, {params.Archived, &opts.Archived}} {
+			if filter.value == "" {
+				continue
+			}
+			if *filter.target, err = codegov.ParseRepoFilter(filter.value); err != nil {
+				return nil, err
+			}
+		}
+
+		// An inventory with private repositories is never published at /code.json
+		publishPath, publishSetting := cfg.CodeGov.JSONPath, "codegov.json_path"
+		if opts.Private == codegov.RepoFilterInclude || opts.Private == codegov.RepoFilterOnly {
+			publishPath, publishSetting = cfg.CodeGov.PrivateJSONPath, "codegov.private_json_path"
+		}
+		if params.Publish && publishPath == "" {
+			return nil, fmt.Errorf("publish requested but %s is not configured", publishSetting)
+		}
 
+		inventory, report, err := codegov.GenerateWithReport(opts)
+		if err != nil {
+			return nil, err
+		}
+		data, err := codegov.MarshalCodeGovJSON(inventory, codegov.EncodeOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if params.Publish {
+			if object := inventoryObject(cfg, publishPath); object != nil {
+				err = object.Put(ctx, data)
+			} else {
+				err = writeFileAtomic(publishPath, data)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to publish inventory: %w", err)
+			}
+		}
+		return &jobs.Result{Data: data, ContentType: "application/json", Summary: report.Summary()}, nil
+	})
+
+	if cfg.Policy.ReplayLog != "" {
+		manager.Register("policy-replay", func(ctx context.Context, raw json.RawMessage) (*jobs.Result, error) {
+			var params policyReplayJobParams
+			if err := decodeJobParams(raw, &params); err != nil {
+				return nil, err
+			}
+			document := []byte(params.Policy)
+			if len(document) == 0 {
+				document, _ = json.Marshal(engine.GetPolicy())
+			}
+			candidate := policy.NewEngine(registry)
+			if err := candidate.LoadFromJSON(document); err != nil {
+				return nil, fmt.Errorf("invalid candidate policy: %w", err)
+			}
+
+			report, err := policy.ReplayFile(cfg.Policy.ReplayLog, candidate)
+			if err != nil {
+				return nil, err
+			}
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			summary := fmt.Sprintf("%d of %d decisions changed (%d newly allowed, %d newly denied)",
+				report.Changed, report.Total, report.NewlyAllowed, report.NewlyDenied)
+			return &jobs.Result{Data: data, ContentType: "application/json", Summary: summary}, nil
+		})
+	}
+
+	return manager, nil
+}
+
+// decodeJobParams decodes a job's parameters strictly, so misspelled fields fail the job
+func decodeJobParams(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid job parameters: %w", err)
+	}
 	return nil
 }
 
+// writeFileAtomic replaces path so readers never see a partially written file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// newOSCALSystem describes the deployment for OSCAL exports. Assessment results read
+// the first file sink without transforms, the only one holding events as written.
+func newOSCALSystem(cfg *config.Config, clearance bool, instanceID string) *oscal.System {
+	system := &oscal.System{
+		Build:             buildinfo.Get(),
+		InstanceID:        instanceID,
+		ClearanceEnforced: clearance,
+		AuditLevel:        cfg.Audit.DefaultLevel,
+		Lockout:           cfg.Lockout.Enabled,
+		TLS:               cfg.TLS.Enabled,
+	}
+	for _, sink := range cfg.Audit.Sinks {
+		system.AuditSinks = append(system.AuditSinks, sink.Type)
+		if sink.Type == "file" && len(sink.Transforms) == 0 && system.AuditFile == "" {
+			system.AuditFile = sink.Path
+		}
+	}
+	return system
+}
+
+// inventoryObject returns the MinIO object an inventory path names, or nil for a file
+func inventoryObject(cfg *config.Config, path string) *handlers.MinIOObject {
+	bucket, key, ok := handlers.ParseMinIOPath(path)
+	if !ok {
+		return nil
+	}
+	return &handlers.MinIOObject{
+		Endpoint:  cfg.MinIO.Endpoint,
+		AccessKey: cfg.MinIO.AccessKey,
+		SecretKey: cfg.MinIO.SecretKey,
+		UseSSL:    cfg.MinIO.UseSSL,
+		Bucket:    bucket,
+		Key:       key,
+	}
+}
+
+// newDataIngester builds the device telemetry ingester from the configured backends
+func newDataIngester(cfg *config.Config, redisMonitor *redis.Monitor) *ingest.Ingester {
+	var backends []ingest.Backend
+	for _, name := range cfg.DataIngest.Backends { // Checked by cfg.Validate
+		switch name {
+		case "minio":
+			bucket := cfg.DataIngest.Bucket
+			if bucket == "" {
+				bucket = "telemetry"
+			}
+			backends = append(backends, &ingest.MinIOBackend{
+				Endpoint:  cfg.MinIO.Endpoint,
+				AccessKey: cfg.MinIO.AccessKey,
+				SecretKey: cfg.MinIO.SecretKey,
+				Bucket:    bucket,
+				Prefix:    cfg.DataIngest.Prefix,
+				UseSSL:    cfg.MinIO.UseSSL,
+			})
+		case "redis":
+			backends = append(backends, &ingest.RedisBackend{
+				Endpoint: cfg.Redis.Endpoint,
+				Password: cfg.Redis.Password,
+				Prefix:   cfg.DataIngest.StreamPrefix,
+				MaxLen:   cfg.DataIngest.StreamMaxLen,
+				Monitor:  redisMonitor,
+			})
+		}
+	}
+
+	return ingest.New(ingest.Quota{
+		MaxBytes:    int64(cfg.DataIngest.MaxPayloadKB) << 10,
+		MaxRequests: cfg.DataIngest.MaxRequests,
+		Window:      parseDuration(cfg.DataIngest.Window),
+	}, backends...)
+}
+
+// bootstrapFromBundle registers devices and applies the policy from a signed bundle.
+// The bundle is rejected as a whole if its signature does not verify.
+func bootstrapFromBundle(cfg *config.Config, client *http.Client, registry *models.DeviceRegistry, engine *policy.Engine, logger *logging.Logger) error {
+	publicKey, err := keys.LoadPublicKey(cfg.Policy.Bundle.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	src := bundle.Source{
+		URL:          cfg.Policy.Bundle.URL,
+		SignatureURL: cfg.Policy.Bundle.SignatureURL,
+		PublicKey:    publicKey,
+		Client:       client,
+	}
+	if cfg.MinIO.Enabled {
+		src.MinIO = &bundle.MinIOCredentials{
+			Endpoint:  cfg.MinIO.Endpoint,
+			AccessKey: cfg.MinIO.AccessKey,
+			SecretKey: cfg.MinIO.SecretKey,
+			UseSSL:    cfg.MinIO.UseSSL,
+		}
+	}
+
+	b, err := bundle.Load(context.Background(), src)
+	if err != nil {
+		return err
+	}
+
+	for _, device := range b.Devices {
+		if err := registry.Register(device); err != nil {
+			return fmt.Errorf("failed to register device %d: %w", device.ID, err)
+		}
+	}
+
+	if err := engine.LoadFromJSON(b.Policy); err != nil {
+		return err
+	}
+
+	logger.Info("loaded signed policy bundle", map[string]interface{}{
+		"url":     cfg.Policy.Bundle.URL,
+		"digest":  b.Digest,
+		"devices": len(b.Devices),
+		"rules":   len(engine.GetPolicy().Rules),
+	})
+
+	return nil
+}
+
+// bootstrapFromObjects loads devices and policy from MinIO objects and returns the
+// syncer that keeps them current. Startup fails unless both load.
+func bootstrapFromObjects(cfg *config.Config, client *http.Client, registry *models.DeviceRegistry, engine *policy.Engine, policyLoaded func() error, logger *logging.Logger) (*bundle.Syncer, error) {
+	var publicKey ed25519.PublicKey
+	if cfg.Policy.Objects.PublicKey != "" {
+		key, err := keys.LoadPublicKey(cfg.Policy.Objects.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		publicKey = key
+	} else {
+		logger.Warn("INSECURE: policy objects are not signed; anyone who can write to the bucket controls policy and devices", map[string]interface{}{
+			"policy_key":  cfg.Policy.Objects.PolicyKey,
+			"devices_key": cfg.Policy.Objects.DevicesKey,
+		})
+	}
+
+	syncer := bundle.NewSyncer(bundle.Objects{
+		Policy:  cfg.Policy.Objects.PolicyKey,
+		Devices: cfg.Policy.Objects.DevicesKey,
+		MinIO: &bundle.MinIOCredentials{
+			Endpoint:  cfg.MinIO.Endpoint,
+			AccessKey: cfg.MinIO.AccessKey,
+			SecretKey: cfg.MinIO.SecretKey,
+			UseSSL:    cfg.MinIO.UseSSL,
+		},
+		PublicKey: publicKey,
+		Insecure:  cfg.Policy.Objects.Insecure,
+		Client:    client,
+	}, engine, registry)
+	syncer.PolicyLoaded = policyLoaded
+
+	result, err := syncer.Sync(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	logSyncResult(logger, result, nil)
+	return syncer, nil
+}
+
+// logSyncResult logs a policy object sync that changed something or failed
+func logSyncResult(logger *logging.Logger, result bundle.SyncResult, err error) {
+	fields := map[string]interface{}{
+		"policy_digest":  result.PolicyDigest,
+		"devices_digest": result.DevicesDigest,
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+		logger.Warn("policy object sync failed", fields)
+		return
+	}
+	if !result.PolicyChanged && !result.DevicesChanged {
+		return
+	}
+	if result.PolicyChanged {
+		fields["policy_version"] = result.PolicyVersion
+	}
+	if result.DevicesChanged {
+		fields["devices_version"] = result.DevicesVersion
+		fields["registered"] = result.Registered
+		fields["updated"] = result.Updated
+		fields["deleted"] = result.Deleted
+	}
+	logger.Info("synced policy objects", fields)
+}
+
+// parseDuration parses a validated config duration; empty values yield zero
+func parseDuration(value string) time.Duration {
+	d, _ := time.ParseDuration(value)
+	return d
+}
+
 // registerExampleDevices registers example devices for testing
 func registerExampleDevices(registry *models.DeviceRegistry, logger *logging.Logger) {
 	devices := []*models.Device{
@@ -160,6 +945,37 @@ func registerExampleDevices(registry *models.DeviceRegistry, logger *logging.Log
 	}
 }
 
+// privateInventoryRule allows reading the private inventory at the given clearance level
+func privateInventoryRule(level int) *policy.Rule {
+	return &policy.Rule{
+		ID:                "allow-private-inventory",
+		Name:              "Allow the private inventory for cleared callers",
+		Effect:            policy.EffectAllow,
+		Routes:            []string{handlers.PrivateCodeJSONPath},
+		Methods:           []string{"GET", "HEAD"},
+		RequiredClearance: models.Clearance(uint32(level) * 0x01010101),
+		Priority:          90,
+	}
+}
+
+// inventoryRule allows reading /api/inventory, and /code.json when it is internal, at
+// the given clearance level
+func inventoryRule(level int, internal bool) *policy.Rule {
+	routes := []string{handlers.InventoryPath}
+	if internal {
+		routes = append(routes, handlers.CodeJSONPath)
+	}
+	return &policy.Rule{
+		ID:                "allow-inventory",
+		Name:              "Allow the inventory for cleared callers",
+		Effect:            policy.EffectAllow,
+		Routes:            routes,
+		Methods:           []string{"GET", "HEAD"},
+		RequiredClearance: models.Clearance(uint32(level) * 0x01010101),
+		Priority:          90,
+	}
+}
+
 // loadDefaultPolicy loads a default policy for testing
 func loadDefaultPolicy(engine *policy.Engine, logger *logging.Logger) {
 	defaultPolicy := &policy.Policy{
@@ -192,6 +1008,56 @@ func loadDefaultPolicy(engine *policy.Engine, logger *logging.Logger) {
 				AllowedDevices:    []uint16{1, 2, 3, 4},
 				Priority:          60,
 			},
+			{
+				ID:                "allow-device-status-report",
+				Name:              "Allow registered devices to report their status",
+				Effect:            policy.EffectAllow,
+				Routes:            []string{"/api/device/status"},
+				Methods:           []string{"POST"},
+				RequiredClearance: models.ClearanceLevel3,
+				AllowedDevices:    []uint16{1, 2, 3, 4},
+				Priority:          60,
+			},
+			{
+				ID:                "allow-device-config",
+				Name:              "Allow registered devices to fetch their configuration",
+				Effect:            policy.EffectAllow,
+				Routes:            []string{"/api/device/config"},
+				Methods:           []string{"GET", "HEAD"},
+				RequiredClearance: models.ClearanceLevel3,
+				AllowedDevices:    []uint16{1, 2, 3, 4},
+				Priority:          60,
+			},
+			{
+				ID:                "allow-device-data",
+				Name:              "Allow registered devices to submit telemetry",
+				Effect:            policy.EffectAllow,
+				Routes:            []string{"/api/device/data"},
+				Methods:           []string{"POST"},
+				RequiredClearance: models.ClearanceLevel3,
+				AllowedDevices:    []uint16{1, 2, 3, 4},
+				Priority:          60,
+			},
+			{
+				ID:                "allow-data-read",
+				Name:              "Allow registered devices to read telemetry their layer and clearance permit",
+				Effect:            policy.EffectAllow,
+				Routes:            []string{"/api/data/records"},
+				Methods:           []string{"GET"},
+				RequiredClearance: models.ClearanceLevel3,
+				AllowedDevices:    []uint16{1, 2, 3, 4},
+				Priority:          60,
+			},
+			{
+				ID:                "allow-device-authz",
+				Name:              "Allow gateways to check device-to-device flows",
+				Effect:            policy.EffectAllow,
+				Routes:            []string{"/api/authz/device"},
+				Methods:           []string{"GET"},
+				RequiredClearance: models.ClearanceLevel5,
+				DeviceSelector:    "class=gateway",
+				Priority:          60,
+			},
 			{
 				ID:                "allow-high-security",
 				Name:              "Allow high security endpoints for level 7+",
@@ -201,6 +1067,42 @@ func loadDefaultPolicy(engine *policy.Engine, logger *logging.Logger) {
 				RequiredClearance: models.ClearanceLevel7,
 				Priority:          70,
 			},
+			{
+				ID:                "allow-admin",
+				Name:              "Allow admin endpoints for level 9",
+				Effect:            policy.EffectAllow,
+				Routes:            []string{"/api/admin/*"},
+				Methods:           []string{"GET"},
+				RequiredClearance: models.ClearanceLevel9,
+				Priority:          80,
+			},
+			{
+				ID:                "allow-admin-devices",
+				Name:              "Allow registering, deleting and restoring devices for level 9",
+				Effect:            policy.EffectAllow,
+				Routes:            []string{"/api/admin/devices", "/api/admin/devices/*"},
+				Methods:           []string{"POST", "DELETE"},
+				RequiredClearance: models.ClearanceLevel9,
+				Priority:          80,
+			},
+			{
+				ID:                "allow-admin-jobs",
+				Name:              "Allow submitting and canceling jobs for level 9",
+				Effect:            policy.EffectAllow,
+				Routes:            []string{"/api/admin/jobs", "/api/admin/jobs/*"},
+				Methods:           []string{"POST", "DELETE"},
+				RequiredClearance: models.ClearanceLevel9,
+				Priority:          80,
+			},
+			{
+				ID:                "allow-admin-unblock",
+				Name:              "Allow lifting lockouts for level 9",
+				Effect:            policy.EffectAllow,
+				Routes:            []string{"/api/admin/lockouts"},
+				Methods:           []string{"DELETE"},
+				RequiredClearance: models.ClearanceLevel9,
+				Priority:          80,
+			},
 			{
 				ID:       "deny-default",
 				Name:     "Deny all other requests",
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/NSACodeGov/CodeGov/internal/policy"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+func main() {
+	lintCmd := flag.NewFlagSet("lint", flag.ExitOnError)
+
+	// lint command flags
+	lintPolicy := lintCmd.String("policy", "", "Policy JSON file to lint")
+	lintDevices := lintCmd.String("devices", "", "JSON array of registered devices (optional, enables device and clearance checks)")
+	lintJSON := lintCmd.Bool("json", false, "Print findings as JSON")
+
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "lint":
+		lintCmd.Parse(os.Args[2:])
+		if *lintPolicy == "" {
+			fmt.Println("Error: --policy is required")
+			lintCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		var registry *models.DeviceRegistry
+		if *lintDevices != "" {
+			var err error
+			if registry, err = loadDevices(*lintDevices); err != nil {
+				log.Fatalf("Error loading devices: %v\n", err)
+			}
+		}
+
+		// Load runs Validate, so hard errors are reported before lint findings
+		engine := policy.NewEngine(registry)
+		if err := engine.LoadFromFile(*lintPolicy); err != nil {
+			fmt.Printf("✗ %v\n", err)
+			os.Exit(1)
+		}
+
+		findings := policy.Lint(engine.GetPolicy(), registry)
+
+		if *lintJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if findings == nil {
+				findings = []policy.LintFinding{}
+			}
+			enc.Encode(findings)
+		} else if len(findings) == 0 {
+			fmt.Println("✓ No lint findings")
+		} else {
+			fmt.Printf("✗ %d lint finding(s):\n", len(findings))
+			for _, f := range findings {
+				fmt.Printf("  - %s\n", f)
+			}
+		}
+
+		if len(findings) > 0 {
+			os.Exit(1)
+		}
+
+	case "-h", "--help", "help":
+		printUsage()
+
+	default:
+		fmt.Printf("Unknown command: %s\n", os.Args[1])
+		printUsage()
+		os.Exit(1)
+	}
+}
+
+// loadDevices builds a device registry from a JSON array of devices
+func loadDevices(path string) (*models.DeviceRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read devices file: %w", err)
+	}
+
+	var devices []*models.Device
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return nil, fmt.Errorf("failed to parse devices file: %w", err)
+	}
+
+	registry := models.NewDeviceRegistry()
+	for _, device := range devices {
+		if err := registry.Register(device); err != nil {
+			return nil, err
+		}
+	}
+
+	return registry, nil
+}
+
+func printUsage() {
+	fmt.Println(`policy - Inspect GoGovCode policy files
+
+Usage:
+  policy [command] [flags]
+
+Commands:
+  lint          Report unreachable, unconstrained and overly broad rules
+  help          Show this help message
+
+Examples:
+  # Lint a policy file
+  policy lint --policy policy.json
+
+  # Also check clearance levels against the registered devices
+  policy lint --policy policy.json --devices devices.json`)
+}
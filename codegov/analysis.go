@@ -0,0 +1,443 @@
+package codegov
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AnalysisConfig configures clone-based repository analysis
+type AnalysisConfig struct {
+	Enabled     bool   // Shallow-clone repositories and count SLOC locally
+	Concurrency int    // Maximum number of concurrent clones
+	CacheDir    string // Directory for cached analysis results (empty disables caching)
+	GitPath     string // Path to the git binary (defaults to "git")
+}
+
+// LanguageStats holds line counts for a single language
+type LanguageStats struct {
+	Language string `json:"language"`
+	Files    int    `json:"files"`
+	Code     int    `json:"code"`
+	Comments int    `json:"comments"`
+	Blanks   int    `json:"blanks"`
+}
+
+// RepositoryAnalysis is the result of analyzing a cloned repository
+type RepositoryAnalysis struct {
+	Languages []LanguageStats `json:"languages"`
+	TotalCode int             `json:"totalCode"`
+}
+
+// languageDef describes how to recognise and count a language
+type languageDef struct {
+	name          string
+	lineComments  []string
+	blockComments [][2]string
+}
+
+var (
+	cStyle    = languageDef{lineComments: []string{"//"}, blockComments: [][2]string{{"/*", "*/"}}}
+	hashStyle = languageDef{lineComments: []string{"#"}}
+)
+
+// languageByExtension maps file extensions to language definitions
+var languageByExtension = map[string]languageDef{
+	".go":    withName(cStyle, "Go"),
+	".c":     withName(cStyle, "C"),
+	".h":     withName(cStyle, "C"),
+	".cc":    withName(cStyle, "C++"),
+	".cpp":   withName(cStyle, "C++"),
+	".hpp":   withName(cStyle, "C++"),
+	".cs":    withName(cStyle, "C#"),
+	".java":  withName(cStyle, "Java"),
+	".kt":    withName(cStyle, "Kotlin"),
+	".scala": withName(cStyle, "Scala"),
+	".swift": withName(cStyle, "Swift"),
+	".rs":    withName(cStyle, "Rust"),
+	".js":    withName(cStyle, "JavaScript"),
+	".jsx":   withName(cStyle, "JavaScript"),
+	".ts":    withName(cStyle, "TypeScript"),
+	".tsx":   withName(cStyle, "TypeScript"),
+	".php":   withName(cStyle, "PHP"),
+	".css":   {name: "CSS", blockComments: [][2]string{{"/*", "*/"}}},
+	".py":    withName(hashStyle, "Python"),
+	".rb":    withName(hashStyle, "Ruby"),
+	".pl":    withName(hashStyle, "Perl"),
+	".r":     withName(hashStyle, "R"),
+	".sh":    withName(hashStyle, "Shell"),
+	".bash":  withName(hashStyle, "Shell"),
+	".ps1":   {name: "PowerShell", lineComments: []string{"#"}, blockComments: [][2]string{{"<#", "#>"}}},
+	".psm1":  {name: "PowerShell", lineComments: []string{"#"}, blockComments: [][2]string{{"<#", "#>"}}},
+	".yml":   withName(hashStyle, "YAML"),
+	".yaml":  withName(hashStyle, "YAML"),
+	".sql":   {name: "SQL", lineComments: []string{"--"}, blockComments: [][2]string{{"/*", "*/"}}},
+	".lua":   {name: "Lua", lineComments: []string{"--"}},
+	".hs":    {name: "Haskell", lineComments: []string{"--"}},
+	".m":     {name: "MATLAB", lineComments: []string{"%"}},
+	".html":  {name: "HTML", blockComments: [][2]string{{"<!--", "-->"}}},
+	".xml":   {name: "XML", blockComments: [][2]string{{"<!--", "-->"}}},
+}
+
+func withName(def languageDef, name string) languageDef {
+	def.name = name
+	return def
+}
+
+// Analyzer performs clone-based SLOC analysis with a concurrency limit
+type Analyzer struct {
+	config AnalysisConfig
+	sem    chan struct{}
+}
+
+// NewAnalyzer creates a new repository analyzer
+func NewAnalyzer(config AnalysisConfig) *Analyzer {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 4
+	}
+	if config.GitPath == "" {
+		config.GitPath = "git"
+	}
+
+	return &Analyzer{
+		config: config,
+		sem:    make(chan struct{}, config.Concurrency),
+	}
+}
+
+var (
+	analyzerMu     sync.RWMutex
+	activeAnalyzer *Analyzer
+)
+
+// SetAnalysisConfig enables or disables deep analysis for subsequent generation runs
+func SetAnalysisConfig(config AnalysisConfig) {
+	analyzerMu.Lock()
+	defer analyzerMu.Unlock()
+
+	if !config.Enabled {
+		activeAnalyzer = nil
+		return
+	}
+	activeAnalyzer = NewAnalyzer(config)
+}
+
+// getAnalyzer returns the configured analyzer, or nil if deep analysis is disabled
+func getAnalyzer() *Analyzer {
+	analyzerMu.RLock()
+	defer analyzerMu.RUnlock()
+	return activeAnalyzer
+}
+
+// AnalyzeRepository shallow-clones a repository and counts SLOC per language.
+// The revision is used as the cache key together with the clone URL; pass the
+// repository's push timestamp or head SHA so stale results are not reused.
+func (a *Analyzer) AnalyzeRepository(cloneURL, branch, revision string) (*RepositoryAnalysis, error) {
+	return a.AnalyzeRepositoryContext(context.Background(), cloneURL, branch, revision)
+}
+
+// AnalyzeRepositoryContext is AnalyzeRepository stopping the clone when ctx is done
+func (a *Analyzer) AnalyzeRepositoryContext(ctx context.Context, cloneURL, branch, revision string) (*RepositoryAnalysis, error) {
+	return a.analyze(ctx, cloneURL, branch, revision, Credentials{}.resolve())
+}
+
+// analyze is AnalyzeRepository cloning with the given credentials
+func (a *Analyzer) analyze(ctx context.Context, cloneURL, branch, revision string, creds Credentials) (*RepositoryAnalysis, error) {
+	cachePath := a.cachePath(cloneURL, revision)
+	if cachePath != "" {
+		if analysis, err := readAnalysisCache(cachePath); err == nil {
+			return analysis, nil
+		}
+	}
+
+	select {
+	case a.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-a.sem }()
+
+	dir, err := os.MkdirTemp("", "codegov-clone-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clone directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	args := []string{"clone", "--depth", "1", "--quiet"}
+	if branch != "" {
+		args = append(args, "--branch", branch)
+	}
+	// "--" keeps a URL starting with "-" from being read as an option
+	args = append(args, "--", cloneURL, dir)
+
+	cmd := exec.CommandContext(ctx, a.config.GitPath, args...)
+	// git's helpers can outlive a killed git and hold its output open
+	cmd.WaitDelay = time.Second
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), creds.cloneEnv(cloneURL)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("git clone failed: %v: %s", err, creds.redact(strings.TrimSpace(string(out))))
+	}
+
+	analysis, err := CountSLOC(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if cachePath != "" {
+		writeAnalysisCache(cachePath, analysis)
+	}
+
+	return analysis, nil
+}
+
+// CountSLOC walks a directory tree and counts code, comment and blank lines per language
+func CountSLOC(root string) (*RepositoryAnalysis, error) {
+	stats := make(map[string]*LanguageStats)
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			name := info.Name()
+			if path != root && (strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		def, ok := languageByExtension[strings.ToLower(filepath.Ext(path))]
+		if !ok {
+			return nil
+		}
+
+		code, comments, blanks, err := countFile(path, def)
+		if err != nil {
+			return nil
+		}
+
+		s, ok := stats[def.name]
+		if !ok {
+			s = &LanguageStats{Language: def.name}
+			stats[def.name] = s
+		}
+		s.Files++
+		s.Code += code
+		s.Comments += comments
+		s.Blanks += blanks
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk repository: %w", err)
+	}
+
+	analysis := &RepositoryAnalysis{
+		Languages: make([]LanguageStats, 0, len(stats)),
+	}
+	for _, s := range stats {
+		analysis.Languages = append(analysis.Languages, *s)
+		analysis.TotalCode += s.Code
+	}
+
+	// Largest languages first, ties broken by name for stable output
+	sort.Slice(analysis.Languages, func(i, j int) bool {
+		if analysis.Languages[i].Code != analysis.Languages[j].Code {
+			return analysis.Languages[i].Code > analysis.Languages[j].Code
+		}
+		return analysis.Languages[i].Language < analysis.Languages[j].Language
+	})
+
+	return analysis, nil
+}
+
+// countFile counts lines in a single source file. A line with any code outside
+// comments counts as code, so "/* note */ x := 1" is code while a line holding only
+// comments, even several, is a comment line.
+func countFile(path string, def languageDef) (code, comments, blanks int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var blockEnd string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" && blockEnd == "" {
+			blanks++
+			continue
+		}
+
+		var hasCode bool
+		hasCode, blockEnd = classifyLine(line, def, blockEnd)
+		if hasCode {
+			code++
+		} else {
+			comments++
+		}
+	}
+
+	return code, comments, blanks, scanner.Err()
+}
+
+// classifyLine scans a line that starts inside the block comment closed by blockEnd,
+// or outside comments when blockEnd is empty. It reports whether the line has code
+// and returns the block comment end still open at the end of the line. Comment
+// markers inside double-quoted strings are ignored.
+func classifyLine(line string, def languageDef, blockEnd string) (hasCode bool, openBlockEnd string) {
+	inString := false
+	for i := 0; i < len(line); {
+		rest := line[i:]
+		switch {
+		case blockEnd != "":
+			end := strings.Index(rest, blockEnd)
+			if end < 0 {
+				return hasCode, blockEnd
+			}
+			i += end + len(blockEnd)
+			blockEnd = ""
+		case inString:
+			if rest[0] == '\\' {
+				i++
+			} else if rest[0] == '"' {
+				inString = false
+			}
+			i++
+		default:
+			if start, end, ok := blockCommentAt(rest, def); ok {
+				blockEnd = end
+				i += len(start)
+				continue
+			}
+			if hasAnyPrefix(rest, def.lineComments) {
+				return hasCode, ""
+			}
+			if rest[0] == '"' {
+				inString = true
+			}
+			if rest[0] != ' ' && rest[0] != '\t' {
+				hasCode = true
+			}
+			i++
+		}
+	}
+	return hasCode, blockEnd
+}
+
+// blockCommentAt reports whether s starts with one of the language's block comments
+func blockCommentAt(s string, def languageDef) (start, end string, ok bool) {
+	for _, block := range def.blockComments {
+		if strings.HasPrefix(s, block[0]) {
+			return block[0], block[1], true
+		}
+	}
+	return "", "", false
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// LanguageNames returns the analysed languages ordered by code size
+func (ra *RepositoryAnalysis) LanguageNames() []string {
+	names := make([]string, 0, len(ra.Languages))
+	for _, l := range ra.Languages {
+		if l.Code > 0 {
+			names = append(names, l.Language)
+		}
+	}
+	return names
+}
+
+// EstimateLaborHours estimates labor hours from SLOC using the basic COCOMO
+// organic model (2.4 * KSLOC^1.05 person-months at 152 hours per month)
+func EstimateLaborHours(sloc int) float64 {
+	if sloc <= 0 {
+		return 0
+	}
+	personMonths := 2.4 * math.Pow(float64(sloc)/1000, 1.05)
+	return math.Round(personMonths * 152)
+}
+
+// sourceBytesPerLine approximates the bytes per counted source line, comments and
+// blank lines included, across common languages
+const sourceBytesPerLine = 40
+
+// EstimateLaborHoursFromBytes estimates labor hours from the bytes of code a host
+// reports per language, such as GitHub's language statistics, by converting them to
+// SLOC at sourceBytesPerLine. It avoids cloning at the cost of precision; vendored
+// or generated code the host does not exclude inflates the estimate.
+func EstimateLaborHoursFromBytes(codeBytes int64) float64 {
+	return EstimateLaborHours(int(codeBytes / sourceBytesPerLine))
+}
+
+// cachePath returns the on-disk cache location for a repository revision
+func (a *Analyzer) cachePath(cloneURL, revision string) string {
+	if a.config.CacheDir == "" || revision == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(cloneURL + "@" + revision))
+	return filepath.Join(a.config.CacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+func readAnalysisCache(path string) (*RepositoryAnalysis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var analysis RepositoryAnalysis
+	if err := json.Unmarshal(data, &analysis); err != nil {
+		return nil, err
+	}
+	return &analysis, nil
+}
+
+func writeAnalysisCache(path string, analysis *RepositoryAnalysis) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	data, err := json.Marshal(analysis)
+	if err != nil {
+		return
+	}
+	os.WriteFile(path, data, 0644)
+}
+
+// gitLabHost returns the host name of the configured GitLab instance
+func gitLabHost() string {
+	u, err := url.Parse(GetGitLabBaseURI())
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// redactToken removes the environment's access tokens from output before it is surfaced
+func redactToken(s string) string {
+	return Credentials{GitHubToken: GetOAuthToken()}.resolve().redact(s)
+}
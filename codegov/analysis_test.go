@@ -0,0 +1,173 @@
+package codegov
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountFile(t *testing.T) {
+	goDef := languageByExtension[".go"]
+	tests := []struct {
+		name     string
+		def      languageDef
+		source   string
+		code     int
+		comments int
+		blanks   int
+	}{
+		{"code", goDef, "x := 1\ny := 2\n", 2, 0, 0},
+		{"blank lines", goDef, "x := 1\n\n   \ny := 2\n", 2, 0, 2},
+		{"line comment", goDef, "// note\nx := 1\n", 1, 1, 0},
+		{"trailing line comment", goDef, "x := 1 // note\n", 1, 0, 0},
+		{"block comment on one line", goDef, "/* note */\n", 0, 1, 0},
+		{"code after block comment", goDef, "/* note */ x := 1\n", 1, 0, 0},
+		{"code before block comment", goDef, "x := 1 /* note */\n", 1, 0, 0},
+		{"two block comments", goDef, "/* a */ /* b */\n", 0, 1, 0},
+		{"block then line comment", goDef, "/* a */ // b\n", 0, 1, 0},
+		{"multi-line block", goDef, "/*\n note\n\n*/\nx := 1\n", 1, 4, 0},
+		{"block starting mid-line", goDef, "x := 1 /* note\n still note\n*/\ny := 2\n", 2, 2, 0},
+		{"code after block end", goDef, "/* note\n*/ x := 1\n", 1, 1, 0},
+		{"comment markers in a string", goDef, "url := \"http://example.gov/*\"\ny := 2\n", 2, 0, 0},
+		{"escaped quote in a string", goDef, "s := \"a\\\" /* b\"\ny := 2\n", 2, 0, 0},
+		{"hash comments", languageByExtension[".py"], "# note\nx = 1  # note\n", 1, 1, 0},
+		{"PowerShell block", languageByExtension[".ps1"], "<# note\n#>\n$x = 1\n", 1, 2, 0},
+		{"HTML comment", languageByExtension[".html"], "<!-- note --><p>x</p>\n<!-- a -->\n", 1, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "source")
+			if err := os.WriteFile(path, []byte(tt.source), 0644); err != nil {
+				t.Fatal(err)
+			}
+			code, comments, blanks, err := countFile(path, tt.def)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.code || comments != tt.comments || blanks != tt.blanks {
+				t.Errorf("got %d code, %d comments, %d blanks, want %d, %d, %d", code, comments, blanks, tt.code, tt.comments, tt.blanks)
+			}
+		})
+	}
+}
+
+func TestCountSLOC(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"main.go":              "package main\n\n// main runs\nfunc main() {}\n",
+		"lib/util.py":          "x = 1\ny = 2\nz = 3\n",
+		"README.txt":           "not counted\n",
+		"vendor/dep/dep.go":    "package dep\n",
+		"node_modules/m/m.js":  "module.exports = 1\n",
+		".github/ci/script.sh": "echo hidden\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	analysis, err := CountSLOC(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LanguageStats{
+		{Language: "Python", Files: 1, Code: 3},
+		{Language: "Go", Files: 1, Code: 2, Comments: 1, Blanks: 1},
+	}
+	if len(analysis.Languages) != len(want) || analysis.TotalCode != 5 {
+		t.Fatalf("unexpected analysis %+v", analysis)
+	}
+	for i := range want {
+		if analysis.Languages[i] != want[i] {
+			t.Errorf("language %d = %+v, want %+v", i, analysis.Languages[i], want[i])
+		}
+	}
+}
+
+// fakeGit writes a git script that counts its runs in a file and "clones" one Go file
+func fakeGit(t *testing.T, extra string) (git, runs string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake git is a shell script")
+	}
+
+	dir := t.TempDir()
+	runs = filepath.Join(dir, "runs")
+	script := "#!/bin/sh\necho run >> " + runs + "\n" + extra +
+		"for last; do :; done\nmkdir -p \"$last\"\nprintf 'package main\\n' > \"$last/main.go\"\n"
+	git = filepath.Join(dir, "git")
+	if err := os.WriteFile(git, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return git, runs
+}
+
+func TestAnalyzeCache(t *testing.T) {
+	git, runs := fakeGit(t, "")
+	analyzer := NewAnalyzer(AnalysisConfig{GitPath: git, CacheDir: filepath.Join(t.TempDir(), "cache")})
+	creds := Credentials{NoEnvFallback: true}
+	countRuns := func() int {
+		data, _ := os.ReadFile(runs)
+		return strings.Count(string(data), "run")
+	}
+
+	for i, tt := range []struct {
+		url, revision string
+		wantRuns      int
+	}{
+		{"https://example.gov/a.git", "r1", 1},
+		{"https://example.gov/a.git", "r1", 1}, // Cached
+		{"https://example.gov/a.git", "r2", 2}, // New revision
+		{"https://example.gov/b.git", "r1", 3}, // Other repository
+		{"https://example.gov/b.git", "", 4},   // No revision, never cached
+		{"https://example.gov/b.git", "", 5},
+	} {
+		analysis, err := analyzer.analyze(context.Background(), tt.url, "", tt.revision, creds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if analysis.TotalCode != 1 {
+			t.Errorf("analysis %d: unexpected %+v", i, analysis)
+		}
+		if got := countRuns(); got != tt.wantRuns {
+			t.Errorf("analysis %d: git ran %d times, want %d", i, got, tt.wantRuns)
+		}
+	}
+
+	// A corrupt cache entry is replaced by a fresh clone
+	path := analyzer.cachePath("https://example.gov/a.git", "r1")
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := analyzer.analyze(context.Background(), "https://example.gov/a.git", "", "r1", creds); err != nil {
+		t.Fatal(err)
+	}
+	if got := countRuns(); got != 6 {
+		t.Errorf("git ran %d times after a corrupt cache entry, want 6", got)
+	}
+}
+
+func TestAnalyzeCancel(t *testing.T) {
+	git, _ := fakeGit(t, "sleep 10\n")
+	analyzer := NewAnalyzer(AnalysisConfig{GitPath: git})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := analyzer.analyze(ctx, "https://example.gov/a.git", "", "", Credentials{NoEnvFallback: true}); err == nil {
+		t.Fatal("expected a cancelled clone to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancelled clone took %s", elapsed)
+	}
+}
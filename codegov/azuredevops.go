@@ -0,0 +1,451 @@
+package codegov
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// AzureDevOpsOrgPrefix selects the Azure DevOps backend for an organization: every
+	// project of an organization ("azuredevops:myorg") or a single project
+	// ("azuredevops:myorg/MyProject"). On Azure DevOps Server the organization is a collection.
+	AzureDevOpsOrgPrefix = "azuredevops:"
+
+	// AzureDevOpsBaseURIEnv selects an Azure DevOps Server instance, e.g. "https://ado.example.gov/tfs"
+	AzureDevOpsBaseURIEnv = "AZURE_DEVOPS_BASE_URI"
+	// AzureDevOpsTokenEnv is a personal access token with Code (Read) and Project and Team (Read) scopes
+	AzureDevOpsTokenEnv = "AZURE_DEVOPS_TOKEN"
+
+	defaultAzureDevOpsBaseURI = "https://dev.azure.com"
+	azureDevOpsAPIVersion     = "7.1"
+)
+
+// GetAzureDevOpsBaseURI returns the Azure DevOps base URI, honouring AZURE_DEVOPS_BASE_URI for Azure DevOps Server
+func GetAzureDevOpsBaseURI() string {
+	if uri := os.Getenv(AzureDevOpsBaseURIEnv); uri != "" {
+		return strings.TrimRight(uri, "/")
+	}
+	return defaultAzureDevOpsBaseURI
+}
+
+// azureDevOpsHost returns the host name of the configured Azure DevOps instance, which
+// serves the API, web pages and git clones alike
+func azureDevOpsHost() string {
+	u, err := url.Parse(GetAzureDevOpsBaseURI())
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// azureDevOpsURI appends the API version to an Azure DevOps API resource and query
+func azureDevOpsURI(resource string, query url.Values) string {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("api-version", azureDevOpsAPIVersion)
+	return resource + "?" + query.Encode()
+}
+
+func newAzureDevOpsRequest(method, uri string) (*http.Request, error) {
+	req, err := http.NewRequest(method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	setClientHeaders(req)
+
+	return req, nil
+}
+
+// getAzureDevOpsJSON fetches an Azure DevOps API resource, decodes it into v and returns
+// the response headers, which carry the continuation token of paged lists
+func getAzureDevOpsJSON(client *http.Client, uri string, v interface{}) (http.Header, error) {
+	req, err := newAzureDevOpsRequest("GET", uri)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := doAPIRequest(client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// An invalid token is answered 203 with the sign-in page, refused here like any other status
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, nil)
+	}
+
+	return resp.Header, json.NewDecoder(resp.Body).Decode(v)
+}
+
+// azureDevOpsProvider implements Provider for Azure DevOps Services and, when
+// AZURE_DEVOPS_BASE_URI is set, Azure DevOps Server
+type azureDevOpsProvider struct{}
+
+func (azureDevOpsProvider) ListRepos(client *http.Client, owner string) ([]ProviderRepository, error) {
+	org, project, _ := strings.Cut(owner, "/")
+
+	repos, err := listAzureDevOpsOwner(client, org, project)
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		apiErr.kind = ErrOrgNotFound
+	}
+	return repos, err
+}
+
+// listAzureDevOpsOwner returns the repositories of one project, or of every project in
+// the organization when project is empty
+func listAzureDevOpsOwner(client *http.Client, org, project string) ([]ProviderRepository, error) {
+	projects := []string{project}
+	if project == "" {
+		var err error
+		if projects, err = listAzureDevOpsProjects(client, org); err != nil {
+			return nil, err
+		}
+	}
+
+	var repos []ProviderRepository
+	for _, project := range projects {
+		found, err := listAzureDevOpsRepos(client, org, project)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, found...)
+	}
+	return repos, nil
+}
+
+// listAzureDevOpsProjects returns the names of an organization's projects
+func listAzureDevOpsProjects(client *http.Client, org string) ([]string, error) {
+	resource := fmt.Sprintf("%s/%s/_apis/projects", GetAzureDevOpsBaseURI(), url.PathEscape(org))
+
+	var projects []string
+	continuation := ""
+	for {
+		query := url.Values{"$top": {"100"}}
+		if continuation != "" {
+			query.Set("continuationToken", continuation)
+		}
+
+		var page struct {
+			Value []struct {
+				Name string `json:"name"`
+			} `json:"value"`
+		}
+		header, err := getAzureDevOpsJSON(client, azureDevOpsURI(resource, query), &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page.Value {
+			projects = append(projects, p.Name)
+		}
+
+		next := header.Get("X-MS-ContinuationToken")
+		if next == "" || next == continuation || len(page.Value) == 0 {
+			break
+		}
+		continuation = next
+	}
+
+	return projects, nil
+}
+
+// listAzureDevOpsRepos returns the Git repositories of a project
+func listAzureDevOpsRepos(client *http.Client, org, project string) ([]ProviderRepository, error) {
+	resource := fmt.Sprintf("%s/%s/%s/_apis/git/repositories", GetAzureDevOpsBaseURI(), url.PathEscape(org), url.PathEscape(project))
+
+	var page struct {
+		Value []AzureDevOpsRepository `json:"value"`
+	}
+	if _, err := getAzureDevOpsJSON(client, azureDevOpsURI(resource, nil), &page); err != nil {
+		return nil, err
+	}
+
+	var repos []ProviderRepository
+	for _, r := range page.Value {
+		// A disabled repository refuses every read, so it cannot be inventoried
+		if r.IsDisabled {
+			continue
+		}
+
+		repo := ProviderRepository{
+			ID:            org + "/" + r.Project.Name + "/" + r.Name,
+			Name:          r.Name,
+			WebURL:        r.WebURL,
+			DefaultBranch: strings.TrimPrefix(r.DefaultBranch, "refs/heads/"),
+			Private:       r.Project.Visibility != "public",
+			Fork:          r.IsFork,
+			Updated:       r.Project.LastUpdateTime,
+		}
+		// Drop the organization name Azure DevOps embeds as the user so stored URLs carry no identity
+		if u, err := url.Parse(r.RemoteURL); err == nil {
+			u.User = nil
+			repo.CloneURL = u.String()
+		}
+
+		// Azure DevOps reports no repository dates; use the latest commit on the default branch
+		if repo.DefaultBranch != "" {
+			var commits struct {
+				Value []struct {
+					Committer struct {
+						Date time.Time `json:"date"`
+					} `json:"committer"`
+				} `json:"value"`
+			}
+			query := url.Values{"searchCriteria.$top": {"1"}, "searchCriteria.itemVersion.version": {repo.DefaultBranch}}
+			if _, err := getAzureDevOpsJSON(client, azureDevOpsURI(azureDevOpsRepoURI(repo)+"/commits", query), &commits); err == nil && len(commits.Value) > 0 {
+				repo.Updated = commits.Value[0].Committer.Date.UTC()
+			}
+		}
+
+		repos = append(repos, repo)
+	}
+	defaultMetrics.addReposFetched("azuredevops", len(repos))
+
+	return repos, nil
+}
+
+// Languages detects languages from the extensions of the files on the default branch,
+// listed through the items API. Enable deep analysis to count lines instead.
+func (azureDevOpsProvider) Languages(client *http.Client, repo ProviderRepository) ([]string, error) {
+	if repo.DefaultBranch == "" {
+		return []string{}, nil
+	}
+
+	var items struct {
+		Value []struct {
+			Path     string `json:"path"`
+			IsFolder bool   `json:"isFolder"`
+		} `json:"value"`
+	}
+	query := azureDevOpsVersion(url.Values{"scopePath": {"/"}, "recursionLevel": {"Full"}}, "branch", repo.DefaultBranch)
+	if _, err := getAzureDevOpsJSON(client, azureDevOpsURI(azureDevOpsRepoURI(repo)+"/items", query), &items); err != nil {
+		return []string{}, err
+	}
+
+	seen := make(map[string]bool)
+	languages := []string{}
+	for _, item := range items.Value {
+		if item.IsFolder || isVendoredPath(item.Path) {
+			continue
+		}
+		def, ok := languageByExtension[strings.ToLower(path.Ext(item.Path))]
+		if !ok || seen[def.name] {
+			continue
+		}
+		seen[def.name] = true
+		languages = append(languages, def.name)
+	}
+	sort.Strings(languages)
+	return languages, nil
+}
+
+// isVendoredPath reports whether a repository path lies in a directory deep analysis skips
+func isVendoredPath(p string) bool {
+	for _, dir := range strings.Split(strings.Trim(path.Dir(p), "/"), "/") {
+		if strings.HasPrefix(dir, ".") || dir == "vendor" || dir == "node_modules" {
+			return true
+		}
+	}
+	return false
+}
+
+// License locates a LICENSE file; Azure DevOps has no license detection, so the name is
+// taken from the SPDX-License-Identifier line or well-known text when present
+func (azureDevOpsProvider) License(client *http.Client, repo ProviderRepository) (*License, error) {
+	license := &License{}
+
+	file := findAzureDevOpsFile(client, repo, "LICENSE")
+	if file == "" {
+		return license, nil
+	}
+	license.URL = azureDevOpsBrowseURL(repo, file)
+
+	req, err := newAzureDevOpsRequest("GET", azureDevOpsRawURL(repo, file))
+	if err != nil {
+		return license, err
+	}
+	resp, err := doAPIRequest(client, req)
+	if err != nil {
+		return license, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<10))
+		license.Name = detectLicenseName(string(text))
+	}
+	return license, nil
+}
+
+// Releases returns the zip archive of the highest version tag, falling back to the default branch
+func (p azureDevOpsProvider) Releases(client *http.Client, repo ProviderRepository) (string, error) {
+	versionType, version := "branch", repo.DefaultBranch
+	tags, err := p.Tags(client, repo)
+	if tag := latestTag(tags); tag != "" {
+		versionType, version = "tag", tag
+	}
+	if version == "" {
+		return "", err
+	}
+
+	query := azureDevOpsVersion(url.Values{"path": {"/"}, "$format": {"zip"}, "download": {"true"}}, versionType, version)
+	return azureDevOpsURI(azureDevOpsRepoURI(repo)+"/items", query), err
+}
+
+// FileURL probes for name, name.md and name.txt on the default branch
+func (azureDevOpsProvider) FileURL(client *http.Client, repo ProviderRepository, name string) string {
+	if file := findAzureDevOpsFile(client, repo, name); file != "" {
+		return azureDevOpsBrowseURL(repo, file)
+	}
+	return ""
+}
+
+// findAzureDevOpsFile returns the first of name, name.md and name.txt present on the default branch
+func findAzureDevOpsFile(client *http.Client, repo ProviderRepository, name string) string {
+	if repo.DefaultBranch == "" {
+		return ""
+	}
+	for _, candidate := range []string{name, name + ".md", name + ".txt"} {
+		var item struct {
+			Path string `json:"path"`
+		}
+		query := azureDevOpsVersion(url.Values{"path": {"/" + candidate}}, "branch", repo.DefaultBranch)
+		if _, err := getAzureDevOpsJSON(client, azureDevOpsURI(azureDevOpsRepoURI(repo)+"/items", query), &item); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// azureDevOpsBrowseURL is the web page showing a file on the default branch
+func azureDevOpsBrowseURL(repo ProviderRepository, file string) string {
+	return repo.WebURL + "?" + url.Values{"path": {"/" + file}, "version": {"GB" + repo.DefaultBranch}}.Encode()
+}
+
+// azureDevOpsRawURL is the API URL returning a file's raw content on the default branch
+func azureDevOpsRawURL(repo ProviderRepository, file string) string {
+	query := azureDevOpsVersion(url.Values{"path": {"/" + file}, "$format": {"octetStream"}}, "branch", repo.DefaultBranch)
+	return azureDevOpsURI(azureDevOpsRepoURI(repo)+"/items", query)
+}
+
+// azureDevOpsVersion adds the version descriptor of a branch or tag to an items query
+func azureDevOpsVersion(query url.Values, versionType, version string) url.Values {
+	query.Set("versionDescriptor.versionType", versionType)
+	query.Set("versionDescriptor.version", version)
+	return query
+}
+
+// azureDevOpsRepoURI is the Azure DevOps REST resource of a repository; the API accepts
+// the repository name in place of its ID
+func azureDevOpsRepoURI(repo ProviderRepository) string {
+	parts := strings.SplitN(repo.ID, "/", 3)
+	for i := range parts {
+		parts[i] = url.PathEscape(parts[i])
+	}
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	return fmt.Sprintf("%s/%s/%s/_apis/git/repositories/%s", GetAzureDevOpsBaseURI(), parts[0], parts[1], parts[2])
+}
+
+// azureDevOpsRefs returns the refs under a prefix such as "tags/", with the prefix removed
+func azureDevOpsRefs(client *http.Client, repo ProviderRepository, filter string) ([]azureDevOpsRef, error) {
+	var refs struct {
+		Value []azureDevOpsRef `json:"value"`
+	}
+	if _, err := getAzureDevOpsJSON(client, azureDevOpsURI(azureDevOpsRepoURI(repo)+"/refs", url.Values{"filter": {filter}}), &refs); err != nil {
+		return nil, err
+	}
+	for i := range refs.Value {
+		refs.Value[i].Name = strings.TrimPrefix(refs.Value[i].Name, "refs/"+filter)
+	}
+	return refs.Value, nil
+}
+
+type azureDevOpsRef struct {
+	Name     string `json:"name"`
+	ObjectID string `json:"objectId"`
+}
+
+// Tags implements ProviderVersioner. Azure DevOps lists refs by name rather than date,
+// so the release version is the highest numbered tag.
+func (azureDevOpsProvider) Tags(client *http.Client, repo ProviderRepository) ([]string, error) {
+	refs, err := azureDevOpsRefs(client, repo, "tags/")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, ref := range refs {
+		names = append(names, ref.Name)
+	}
+	return names, nil
+}
+
+// BranchSHA implements ProviderVersioner
+func (azureDevOpsProvider) BranchSHA(client *http.Client, repo ProviderRepository) (string, error) {
+	if repo.DefaultBranch == "" {
+		return "", nil // An empty repository has no default branch
+	}
+
+	refs, err := azureDevOpsRefs(client, repo, "heads/"+repo.DefaultBranch)
+	if err != nil {
+		return "", err
+	}
+	// The filter matches by prefix, so "main" also returns "main-old"
+	for _, ref := range refs {
+		if ref.Name == "" {
+			return ref.ObjectID, nil
+		}
+	}
+	return "", nil
+}
+
+// ListRoot implements ProviderRootLister
+func (azureDevOpsProvider) ListRoot(client *http.Client, repo ProviderRepository) ([]string, error) {
+	if repo.DefaultBranch == "" {
+		return nil, nil
+	}
+
+	var items struct {
+		Value []struct {
+			Path     string `json:"path"`
+			IsFolder bool   `json:"isFolder"`
+		} `json:"value"`
+	}
+	query := azureDevOpsVersion(url.Values{"scopePath": {"/"}, "recursionLevel": {"OneLevel"}}, "branch", repo.DefaultBranch)
+	if _, err := getAzureDevOpsJSON(client, azureDevOpsURI(azureDevOpsRepoURI(repo)+"/items", query), &items); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, item := range items.Value {
+		name := strings.TrimPrefix(item.Path, "/")
+		if !item.IsFolder && name != "" && !strings.Contains(name, "/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ReadFile implements ProviderFileReader
+func (azureDevOpsProvider) ReadFile(client *http.Client, repo ProviderRepository, name string) ([]byte, error) {
+	req, err := newAzureDevOpsRequest("GET", azureDevOpsRawURL(repo, name))
+	if err != nil {
+		return nil, err
+	}
+	return readRepoFile(client, req)
+}
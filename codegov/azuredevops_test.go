@@ -0,0 +1,119 @@
+package codegov
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAzureDevOpsProvider(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, password, ok := r.BasicAuth(); !ok || password != "ado-pat" {
+			t.Errorf("missing credentials on %s", r.URL)
+		}
+		if r.URL.Query().Get("api-version") != azureDevOpsAPIVersion {
+			t.Errorf("missing API version on %s", r.URL)
+		}
+		query := r.URL.Query()
+		switch r.URL.Path {
+		case "/org/_apis/projects":
+			if query.Get("continuationToken") == "" {
+				w.Header().Set("X-MS-ContinuationToken", "page2")
+				fmt.Fprint(w, `{"value": [{"name": "Alpha"}]}`)
+			} else {
+				fmt.Fprint(w, `{"value": [{"name": "Beta"}]}`)
+			}
+		case "/org/Alpha/_apis/git/repositories":
+			fmt.Fprintf(w, `{"value": [
+				{"name": "widget", "webUrl": "%[1]s/org/Alpha/_git/widget", "remoteUrl": "https://org@%[2]s/org/Alpha/_git/widget",
+				 "defaultBranch": "refs/heads/main", "project": {"name": "Alpha", "visibility": "public"}},
+				{"name": "old", "isDisabled": true, "project": {"name": "Alpha", "visibility": "public"}}
+			]}`, srv.URL, strings.TrimPrefix(srv.URL, "http://"))
+		case "/org/Beta/_apis/git/repositories":
+			fmt.Fprint(w, `{"value": [{"name": "secret", "defaultBranch": "refs/heads/main", "project": {"name": "Beta", "visibility": "private"}}]}`)
+		case "/org/Alpha/_apis/git/repositories/widget/commits":
+			if query.Get("searchCriteria.itemVersion.version") != "main" {
+				t.Errorf("unexpected commit query %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `{"value": [{"committer": {"date": "2024-05-06T10:00:00Z"}}]}`)
+		case "/org/Alpha/_apis/git/repositories/widget/items":
+			switch query.Get("path") {
+			case "":
+				fmt.Fprint(w, `{"value": [{"path": "/", "isFolder": true}, {"path": "/main.go"}, {"path": "/web/app.ts"},
+					{"path": "/vendor/lib.c"}, {"path": "/README.md"}]}`)
+			case "/LICENSE":
+				if query.Get("$format") == "octetStream" {
+					fmt.Fprint(w, "SPDX-License-Identifier: Apache-2.0\n")
+				} else {
+					fmt.Fprint(w, `{"path": "/LICENSE"}`)
+				}
+			default:
+				http.NotFound(w, r)
+			}
+		case "/org/Alpha/_apis/git/repositories/widget/refs":
+			switch query.Get("filter") {
+			case "tags/":
+				fmt.Fprint(w, `{"value": [{"name": "refs/tags/v1.10"}, {"name": "refs/tags/v1.9"}, {"name": "refs/tags/v2.0-rc1"}]}`)
+			case "heads/main":
+				fmt.Fprint(w, `{"value": [{"name": "refs/heads/main", "objectId": "abc123"}, {"name": "refs/heads/main-old", "objectId": "def456"}]}`)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv(AzureDevOpsBaseURIEnv, srv.URL)
+	t.Setenv(AzureDevOpsTokenEnv, "ado-pat")
+
+	codeGov, err := Generate(GenerateOptions{
+		Organizations: []string{"azuredevops:org"},
+		Agency:        "TEST",
+		Contact:       Contact{Email: "code@test.gov"},
+		HTTPClient:    srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(codeGov.Releases) != 1 {
+		t.Fatalf("expected disabled and private repositories to be skipped, got %+v", codeGov.Releases)
+	}
+
+	release := codeGov.Releases[0]
+	if release.RepositoryURL != srv.URL+"/org/Alpha/_git/widget" || release.Date.LastModified != "2024-05-06" {
+		t.Errorf("unexpected release %+v", release)
+	}
+	if lic := release.Permissions.Licenses[0]; lic.Name != "Apache-2.0" || lic.URL != srv.URL+"/org/Alpha/_git/widget?path=%2FLICENSE&version=GBmain" {
+		t.Errorf("unexpected license %+v", lic)
+	}
+	if release.Version != "v1.10" || !strings.Contains(release.DownloadURL, "versionDescriptor.version=v1.10") || !strings.Contains(release.DownloadURL, "%24format=zip") {
+		t.Errorf("unexpected download URL or version %s %s", release.DownloadURL, release.Version)
+	}
+	if strings.Join(release.Languages, ",") != "Go,TypeScript" {
+		t.Errorf("unexpected languages %v", release.Languages)
+	}
+	if release.DisclaimerURL != "" {
+		t.Errorf("unexpected disclaimer URL %s", release.DisclaimerURL)
+	}
+
+	sha, err := azureDevOpsProvider{}.BranchSHA(WithCredentials(srv.Client(), Credentials{}), ProviderRepository{ID: "org/Alpha/widget", DefaultBranch: "main"})
+	if err != nil || sha != "abc123" {
+		t.Errorf("expected the exact branch's commit, got %q, %v", sha, err)
+	}
+}
+
+func TestAzureDevOpsProviderProjectNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	t.Setenv(AzureDevOpsBaseURIEnv, srv.URL)
+
+	_, err := azureDevOpsProvider{}.ListRepos(srv.Client(), "org/Missing")
+	if !errors.Is(err, ErrOrgNotFound) {
+		t.Errorf("expected an organization not found error, got %v", err)
+	}
+}
@@ -0,0 +1,485 @@
+package codegov
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// BitbucketOrgPrefix selects the Bitbucket backend for an organization: a workspace
+	// on Bitbucket Cloud ("bitbucket:myworkspace") or a project key on Bitbucket
+	// Server / Data Center ("bitbucket:PROJ")
+	BitbucketOrgPrefix = "bitbucket:"
+
+	// BitbucketBaseURIEnv selects a Bitbucket Server instance, e.g. "https://bitbucket.example.gov/rest/api/1.0"
+	BitbucketBaseURIEnv = "BITBUCKET_BASE_URI"
+	// BitbucketTokenEnv is a Cloud workspace access token or Server HTTP access token, sent as a bearer token
+	BitbucketTokenEnv = "BITBUCKET_TOKEN"
+	// BitbucketUsernameEnv and BitbucketAppPasswordEnv authenticate to Bitbucket Cloud with an app password
+	BitbucketUsernameEnv    = "BITBUCKET_USERNAME"
+	BitbucketAppPasswordEnv = "BITBUCKET_APP_PASSWORD"
+
+	defaultBitbucketBaseURI = "https://api.bitbucket.org/2.0"
+)
+
+// GetBitbucketBaseURI returns the Bitbucket API base URI, honouring BITBUCKET_BASE_URI for Bitbucket Server
+func GetBitbucketBaseURI() string {
+	if uri := os.Getenv(BitbucketBaseURIEnv); uri != "" {
+		return strings.TrimRight(uri, "/")
+	}
+	return defaultBitbucketBaseURI
+}
+
+// isBitbucketServer reports whether the base URI points at the Bitbucket Server REST API
+func isBitbucketServer() bool {
+	return strings.Contains(GetBitbucketBaseURI(), "/rest/api/")
+}
+
+// bitbucketHost returns the host name of the configured Bitbucket API
+func bitbucketHost() string {
+	u, err := url.Parse(GetBitbucketBaseURI())
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// bitbucketCloneHost returns the host Bitbucket serves web pages and git clones from
+func bitbucketCloneHost() string {
+	u, err := url.Parse(bitbucketWebURL())
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// bitbucketWebURL returns the web root of the configured Bitbucket instance
+func bitbucketWebURL() string {
+	base := GetBitbucketBaseURI()
+	if isBitbucketServer() {
+		return base[:strings.Index(base, "/rest/api/")]
+	}
+	if base == defaultBitbucketBaseURI {
+		return "https://bitbucket.org"
+	}
+	return strings.TrimSuffix(base, "/2.0")
+}
+
+func newBitbucketRequest(method, uri string) (*http.Request, error) {
+	req, err := http.NewRequest(method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	setClientHeaders(req)
+
+	return req, nil
+}
+
+// getBitbucketJSON fetches a Bitbucket API resource and decodes it into v
+func getBitbucketJSON(client *http.Client, uri string, v interface{}) error {
+	req, err := newBitbucketRequest("GET", uri)
+	if err != nil {
+		return err
+	}
+
+	resp, err := doAPIRequest(client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp, nil)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// probeBitbucketFile reports whether a file exists at a Bitbucket URL, with credentials for private repositories
+func probeBitbucketFile(client *http.Client, uri string) bool {
+	req, err := newBitbucketRequest("GET", uri)
+	if err != nil {
+		return false
+	}
+
+	resp, err := doAPIRequest(client, req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK
+}
+
+// bitbucketProvider implements Provider for Bitbucket Cloud and, when
+// BITBUCKET_BASE_URI points at a /rest/api/ root, Bitbucket Server / Data Center
+type bitbucketProvider struct{}
+
+func (bitbucketProvider) ListRepos(client *http.Client, owner string) ([]ProviderRepository, error) {
+	var repos []ProviderRepository
+	var err error
+	if isBitbucketServer() {
+		repos, err = listBitbucketServerRepos(client, owner)
+	} else {
+		repos, err = listBitbucketCloudRepos(client, owner)
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		apiErr.kind = ErrOrgNotFound
+	}
+	return repos, err
+}
+
+func listBitbucketCloudRepos(client *http.Client, workspace string) ([]ProviderRepository, error) {
+	next := fmt.Sprintf("%s/repositories/%s?pagelen=100", GetBitbucketBaseURI(), url.PathEscape(workspace))
+
+	var repos []ProviderRepository
+	for next != "" {
+		var page BitbucketRepositoryPage
+		if err := getBitbucketJSON(client, next, &page); err != nil {
+			return nil, err
+		}
+
+		for _, r := range page.Values {
+			repo := ProviderRepository{
+				ID:            r.FullName,
+				Name:          r.Name,
+				Description:   r.Description,
+				WebURL:        r.Links.HTML.Href,
+				DefaultBranch: r.MainBranch.Name,
+				Private:       r.IsPrivate,
+				Fork:          r.Parent != nil,
+				Created:       r.CreatedOn,
+				Updated:       r.UpdatedOn,
+			}
+			for _, clone := range r.Links.Clone {
+				if clone.Name == "https" {
+					// Drop the user name Bitbucket embeds so stored URLs carry no identity
+					if u, err := url.Parse(clone.Href); err == nil {
+						u.User = nil
+						repo.CloneURL = u.String()
+					}
+				}
+			}
+			repos = append(repos, repo)
+		}
+		defaultMetrics.addReposFetched("bitbucket", len(page.Values))
+
+		next = page.Next
+	}
+
+	return repos, nil
+}
+
+func listBitbucketServerRepos(client *http.Client, projectKey string) ([]ProviderRepository, error) {
+	uri := fmt.Sprintf("%s/projects/%s/repos?limit=100", GetBitbucketBaseURI(), url.PathEscape(projectKey))
+
+	var repos []ProviderRepository
+	start := 0
+	for {
+		var page BitbucketServerRepositoryPage
+		if err := getBitbucketJSON(client, fmt.Sprintf("%s&start=%d", uri, start), &page); err != nil {
+			return nil, err
+		}
+
+		for _, r := range page.Values {
+			repo := ProviderRepository{
+				ID:          r.Project.Key + "/" + r.Slug,
+				Name:        r.Name,
+				Description: r.Description,
+				Private:     !r.Public,
+				Fork:        r.Origin != nil,
+				Archived:    r.Archived,
+			}
+			if len(r.Links.Self) > 0 {
+				repo.WebURL = strings.TrimSuffix(r.Links.Self[0].Href, "/browse")
+			}
+			for _, clone := range r.Links.Clone {
+				if clone.Name == "http" {
+					if u, err := url.Parse(clone.Href); err == nil {
+						u.User = nil
+						repo.CloneURL = u.String()
+					}
+				}
+			}
+
+			// Bitbucket Server reports neither the default branch nor any dates when listing
+			repoURI := fmt.Sprintf("%s/projects/%s/repos/%s", GetBitbucketBaseURI(), url.PathEscape(r.Project.Key), url.PathEscape(r.Slug))
+			var branch struct {
+				DisplayID string `json:"displayId"`
+			}
+			if err := getBitbucketJSON(client, repoURI+"/default-branch", &branch); err == nil {
+				repo.DefaultBranch = branch.DisplayID
+			}
+			var commits struct {
+				Values []struct {
+					AuthorTimestamp int64 `json:"authorTimestamp"`
+				} `json:"values"`
+			}
+			if err := getBitbucketJSON(client, repoURI+"/commits?limit=1", &commits); err == nil && len(commits.Values) > 0 {
+				repo.Updated = time.UnixMilli(commits.Values[0].AuthorTimestamp).UTC()
+			}
+
+			repos = append(repos, repo)
+		}
+		defaultMetrics.addReposFetched("bitbucket", len(page.Values))
+
+		if page.IsLastPage || page.NextPageStart <= start {
+			break
+		}
+		start = page.NextPageStart
+	}
+
+	return repos, nil
+}
+
+// Languages returns the primary language Bitbucket Cloud records for a repository.
+// Bitbucket Server does not track languages; enable deep analysis to detect them.
+func (bitbucketProvider) Languages(client *http.Client, repo ProviderRepository) ([]string, error) {
+	if isBitbucketServer() {
+		return []string{}, nil
+	}
+
+	var r BitbucketRepository
+	uri := fmt.Sprintf("%s/repositories/%s", GetBitbucketBaseURI(), repo.ID)
+	if err := getBitbucketJSON(client, uri, &r); err != nil {
+		return []string{}, err
+	}
+	if r.Language == "" {
+		return []string{}, nil
+	}
+	return []string{r.Language}, nil
+}
+
+// License locates a LICENSE file; Bitbucket has no license detection, so the name is
+// taken from the SPDX-License-Identifier line or well-known text when present
+func (bitbucketProvider) License(client *http.Client, repo ProviderRepository) (*License, error) {
+	license := &License{}
+
+	file := findBitbucketFile(client, repo, "LICENSE")
+	if file == "" {
+		return license, nil
+	}
+	license.URL = bitbucketBrowseURL(repo, file)
+
+	req, err := newBitbucketRequest("GET", bitbucketRawURL(repo, file))
+	if err != nil {
+		return license, err
+	}
+	resp, err := doAPIRequest(client, req)
+	if err != nil {
+		return license, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<10))
+		license.Name = detectLicenseName(string(text))
+	}
+	return license, nil
+}
+
+// Releases returns the zip archive of the most recent tag, falling back to the default branch
+func (bitbucketProvider) Releases(client *http.Client, repo ProviderRepository) (string, error) {
+	base := GetBitbucketBaseURI()
+
+	if isBitbucketServer() {
+		repoURI := bitbucketServerRepoURI(repo)
+		archive := strings.Replace(repoURI, "/rest/api/1.0/", "/rest/api/latest/", 1) + "/archive?format=zip"
+
+		var tags struct {
+			Values []struct {
+				ID string `json:"id"`
+			} `json:"values"`
+		}
+		if err := getBitbucketJSON(client, repoURI+"/tags?orderBy=MODIFICATION&limit=1", &tags); err != nil {
+			return archive, err
+		}
+		if len(tags.Values) > 0 {
+			return archive + "&at=" + url.QueryEscape(tags.Values[0].ID), nil
+		}
+		return archive, nil
+	}
+
+	var tags struct {
+		Values []struct {
+			Name string `json:"name"`
+		} `json:"values"`
+	}
+	uri := fmt.Sprintf("%s/repositories/%s/refs/tags?sort=-target.date&pagelen=1", base, repo.ID)
+	ref := repo.DefaultBranch
+	err := getBitbucketJSON(client, uri, &tags)
+	if err == nil && len(tags.Values) > 0 {
+		ref = tags.Values[0].Name
+	}
+	if ref == "" {
+		return "", err
+	}
+	return fmt.Sprintf("%s/get/%s.zip", repo.WebURL, url.PathEscape(ref)), err
+}
+
+// FileURL probes for name, name.md and name.txt on the default branch
+func (bitbucketProvider) FileURL(client *http.Client, repo ProviderRepository, name string) string {
+	if file := findBitbucketFile(client, repo, name); file != "" {
+		return bitbucketBrowseURL(repo, file)
+	}
+	return ""
+}
+
+// findBitbucketFile returns the first of name, name.md and name.txt present on the default branch
+func findBitbucketFile(client *http.Client, repo ProviderRepository, name string) string {
+	for _, candidate := range []string{name, name + ".md", name + ".txt"} {
+		if probeBitbucketFile(client, bitbucketRawURL(repo, candidate)) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// bitbucketBrowseURL is the web page showing a file on the default branch
+func bitbucketBrowseURL(repo ProviderRepository, file string) string {
+	if isBitbucketServer() {
+		return fmt.Sprintf("%s/browse/%s?at=%s", repo.WebURL, file, url.QueryEscape("refs/heads/"+repo.DefaultBranch))
+	}
+	return fmt.Sprintf("%s/src/%s/%s", repo.WebURL, url.PathEscape(repo.DefaultBranch), file)
+}
+
+// bitbucketRawURL is the API URL returning a file's raw content on the default branch
+func bitbucketRawURL(repo ProviderRepository, file string) string {
+	if isBitbucketServer() {
+		return fmt.Sprintf("%s/raw/%s?at=%s", repo.WebURL, file, url.QueryEscape("refs/heads/"+repo.DefaultBranch))
+	}
+	return fmt.Sprintf("%s/repositories/%s/src/%s/%s", GetBitbucketBaseURI(), repo.ID, url.PathEscape(repo.DefaultBranch), file)
+}
+
+// Tags implements ProviderVersioner
+func (bitbucketProvider) Tags(client *http.Client, repo ProviderRepository) ([]string, error) {
+	var names []string
+	if isBitbucketServer() {
+		var tags struct {
+			Values []struct {
+				DisplayID string `json:"displayId"`
+			} `json:"values"`
+		}
+		if err := getBitbucketJSON(client, bitbucketServerRepoURI(repo)+"/tags?orderBy=MODIFICATION&limit=100", &tags); err != nil {
+			return nil, err
+		}
+		for _, tag := range tags.Values {
+			names = append(names, tag.DisplayID)
+		}
+		return names, nil
+	}
+
+	var tags struct {
+		Values []struct {
+			Name string `json:"name"`
+		} `json:"values"`
+	}
+	uri := fmt.Sprintf("%s/repositories/%s/refs/tags?sort=-target.date&pagelen=100", GetBitbucketBaseURI(), repo.ID)
+	if err := getBitbucketJSON(client, uri, &tags); err != nil {
+		return nil, err
+	}
+	for _, tag := range tags.Values {
+		names = append(names, tag.Name)
+	}
+	return names, nil
+}
+
+// BranchSHA implements ProviderVersioner
+func (bitbucketProvider) BranchSHA(client *http.Client, repo ProviderRepository) (string, error) {
+	if repo.DefaultBranch == "" {
+		return "", nil // An empty repository has no default branch
+	}
+
+	if isBitbucketServer() {
+		var branch struct {
+			LatestCommit string `json:"latestCommit"`
+		}
+		if err := getBitbucketJSON(client, bitbucketServerRepoURI(repo)+"/branches/default", &branch); err != nil {
+			return "", err
+		}
+		return branch.LatestCommit, nil
+	}
+
+	var branch struct {
+		Target struct {
+			Hash string `json:"hash"`
+		} `json:"target"`
+	}
+	uri := fmt.Sprintf("%s/repositories/%s/refs/branches/%s", GetBitbucketBaseURI(), repo.ID, url.PathEscape(repo.DefaultBranch))
+	if err := getBitbucketJSON(client, uri, &branch); err != nil {
+		return "", err
+	}
+	return branch.Target.Hash, nil
+}
+
+// bitbucketServerRepoURI is the Bitbucket Server REST resource of a repository
+func bitbucketServerRepoURI(repo ProviderRepository) string {
+	key, slug, _ := strings.Cut(repo.ID, "/")
+	return fmt.Sprintf("%s/projects/%s/repos/%s", GetBitbucketBaseURI(), url.PathEscape(key), url.PathEscape(slug))
+}
+
+// ListRoot implements ProviderRootLister
+func (bitbucketProvider) ListRoot(client *http.Client, repo ProviderRepository) ([]string, error) {
+	if repo.DefaultBranch == "" {
+		return nil, nil
+	}
+
+	var names []string
+	if isBitbucketServer() {
+		// Bitbucket Server lists every file path in the repository
+		var files struct {
+			Values []string `json:"values"`
+		}
+		uri := bitbucketServerRepoURI(repo) + "/files?limit=1000&at=" + url.QueryEscape("refs/heads/"+repo.DefaultBranch)
+		if err := getBitbucketJSON(client, uri, &files); err != nil {
+			return nil, err
+		}
+		for _, name := range files.Values {
+			if !strings.Contains(name, "/") {
+				names = append(names, name)
+			}
+		}
+	} else {
+		uri := fmt.Sprintf("%s/repositories/%s/src/%s/?pagelen=100", GetBitbucketBaseURI(), repo.ID, url.PathEscape(repo.DefaultBranch))
+		for uri != "" {
+			var page struct {
+				Values []struct {
+					Path string `json:"path"`
+					Type string `json:"type"`
+				} `json:"values"`
+				Next string `json:"next"`
+			}
+			if err := getBitbucketJSON(client, uri, &page); err != nil {
+				return nil, err
+			}
+			for _, entry := range page.Values {
+				if entry.Type == "commit_file" {
+					names = append(names, entry.Path)
+				}
+			}
+			uri = page.Next
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ReadFile implements ProviderFileReader
+func (bitbucketProvider) ReadFile(client *http.Client, repo ProviderRepository, name string) ([]byte, error) {
+	req, err := newBitbucketRequest("GET", bitbucketRawURL(repo, name))
+	if err != nil {
+		return nil, err
+	}
+	return readRepoFile(client, req)
+}
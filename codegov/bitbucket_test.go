@@ -0,0 +1,154 @@
+package codegov
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBitbucketCloudProvider(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer bb-token" {
+			t.Errorf("missing credentials on %s", r.URL)
+		}
+		switch r.URL.Path {
+		case "/2.0/repositories/ws":
+			if r.URL.Query().Get("page") == "" {
+				fmt.Fprintf(w, `{"values": [
+					{"name": "widget", "full_name": "ws/widget", "description": "Widgets", "language": "go",
+					 "created_on": "2020-01-02T00:00:00Z", "updated_on": "2024-05-06T00:00:00Z",
+					 "mainbranch": {"name": "main"},
+					 "links": {"html": {"href": "%[1]s/ws/widget"}, "clone": [{"name": "https", "href": "https://someone@bitbucket.example/ws/widget.git"}]}},
+					{"name": "copy", "full_name": "ws/copy", "parent": {"full_name": "other/copy"},
+					 "updated_on": "2024-05-06T00:00:00Z", "links": {"html": {"href": "%[1]s/ws/copy"}}}
+				], "next": "%[1]s/2.0/repositories/ws?pagelen=100&page=2"}`, srv.URL)
+			} else {
+				fmt.Fprint(w, `{"values": []}`)
+			}
+		case "/2.0/repositories/ws/widget":
+			fmt.Fprint(w, `{"language": "go"}`)
+		case "/2.0/repositories/ws/widget/src/main/":
+			fmt.Fprint(w, `{"values": [{"path": "docs", "type": "commit_directory"}, {"path": "DISCLAIMER.md", "type": "commit_file"},
+				{"path": "LICENSE", "type": "commit_file"}, {"path": "LICENSE-APACHE", "type": "commit_file"}]}`)
+		case "/2.0/repositories/ws/widget/src/main/LICENSE":
+			fmt.Fprint(w, "MIT License\n\nCopyright (c) 2024")
+		case "/2.0/repositories/ws/widget/src/main/LICENSE-APACHE":
+			fmt.Fprint(w, "SPDX-License-Identifier: Apache-2.0\n")
+		case "/2.0/repositories/ws/widget/src/main/DISCLAIMER.md":
+			fmt.Fprint(w, "Provided as is.")
+		case "/2.0/repositories/ws/widget/refs/tags":
+			fmt.Fprint(w, `{"values": [{"name": "v2.0"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv(BitbucketBaseURIEnv, srv.URL+"/2.0")
+	t.Setenv(BitbucketTokenEnv, "bb-token")
+
+	codeGov, err := Generate(GenerateOptions{
+		Organizations: []string{"bitbucket:ws"},
+		Agency:        "TEST",
+		Contact:       Contact{Email: "code@test.gov"},
+		HTTPClient:    srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(codeGov.Releases) != 1 {
+		t.Fatalf("expected forks to be skipped, got %+v", codeGov.Releases)
+	}
+
+	release := codeGov.Releases[0]
+	if release.RepositoryURL != srv.URL+"/ws/widget" || release.Date.Created != "2020-01-02" || release.Date.LastModified != "2024-05-06" {
+		t.Errorf("unexpected release %+v", release)
+	}
+	licenses := release.Permissions.Licenses
+	if len(licenses) != 2 || licenses[0].Name != "MIT" || licenses[0].URL != srv.URL+"/ws/widget/src/main/LICENSE" {
+		t.Fatalf("unexpected licenses %+v", licenses)
+	}
+	if licenses[1].Name != "Apache-2.0" || licenses[1].URL != srv.URL+"/ws/widget/src/main/LICENSE-APACHE" {
+		t.Errorf("expected the second license file to be reported, got %+v", licenses[1])
+	}
+	if release.DisclaimerURL != srv.URL+"/ws/widget/src/main/DISCLAIMER.md" {
+		t.Errorf("unexpected disclaimer URL %s", release.DisclaimerURL)
+	}
+	if release.DownloadURL != srv.URL+"/ws/widget/get/v2.0.zip" || release.Version != "v2.0" {
+		t.Errorf("unexpected download URL or version %s %s", release.DownloadURL, release.Version)
+	}
+	if strings.Join(release.Languages, ",") != "go" {
+		t.Errorf("unexpected languages %v", release.Languages)
+	}
+}
+
+func TestBitbucketServerProvider(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/1.0/projects/PROJ/repos":
+			fmt.Fprintf(w, `{"isLastPage": true, "values": [
+				{"slug": "svc", "name": "svc", "public": true, "project": {"key": "PROJ"},
+				 "links": {"self": [{"href": "%s/projects/PROJ/repos/svc/browse"}]}}
+			]}`, srv.URL)
+		case "/rest/api/1.0/projects/PROJ/repos/svc/default-branch":
+			fmt.Fprint(w, `{"displayId": "main"}`)
+		case "/rest/api/1.0/projects/PROJ/repos/svc/commits":
+			fmt.Fprint(w, `{"values": [{"authorTimestamp": 1717200000000}]}`)
+		case "/rest/api/1.0/projects/PROJ/repos/svc/tags":
+			fmt.Fprint(w, `{"values": [{"id": "refs/tags/v1.0", "displayId": "v1.0"}]}`)
+		case "/projects/PROJ/repos/svc/raw/LICENSE":
+			if r.URL.Query().Get("at") != "refs/heads/main" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, "SPDX-License-Identifier: Apache-2.0\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv(BitbucketBaseURIEnv, srv.URL+"/rest/api/1.0")
+
+	codeGov, err := Generate(GenerateOptions{
+		Organizations: []string{"bitbucket:PROJ"},
+		Agency:        "TEST",
+		Contact:       Contact{Email: "code@test.gov"},
+		HTTPClient:    srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(codeGov.Releases) != 1 {
+		t.Fatalf("unexpected releases %+v", codeGov.Releases)
+	}
+
+	release := codeGov.Releases[0]
+	if release.RepositoryURL != srv.URL+"/projects/PROJ/repos/svc" || release.Date.LastModified != "2024-06-01" {
+		t.Errorf("unexpected release %+v", release)
+	}
+	if release.Permissions.Licenses[0].Name != "Apache-2.0" {
+		t.Errorf("unexpected license %+v", release.Permissions.Licenses[0])
+	}
+	if release.DownloadURL != srv.URL+"/rest/api/latest/projects/PROJ/repos/svc/archive?format=zip&at=refs%2Ftags%2Fv1.0" || release.Version != "v1.0" {
+		t.Errorf("unexpected download URL or version %s %s", release.DownloadURL, release.Version)
+	}
+}
+
+func TestDetectLicenseName(t *testing.T) {
+	for text, want := range map[string]string{
+		"// SPDX-License-Identifier: BSD-2-Clause\n":                       "BSD-2-Clause",
+		"GNU GENERAL PUBLIC LICENSE\n   Version 2, June 1991":              "GPL-2.0",
+		"GNU GENERAL PUBLIC LICENSE\n   Version 3, 29 June 2007":           "GPL-3.0",
+		"Permission is hereby granted, free of charge, to any person":      "MIT",
+		"All rights reserved. Contact the agency for licensing questions.": "",
+	} {
+		if got := detectLicenseName(text); got != want {
+			t.Errorf("detectLicenseName(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package codegov
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// CacheHeader is set to "1" on responses served from a ResponseCache after the
+// server answered 304 Not Modified
+const CacheHeader = "X-From-Cache"
+
+// cachedResponse is the on-disk format of one cached GET response
+type cachedResponse struct {
+	URL        string      `json:"url"` // Sanitized; for inspection only, lookups use the file name
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	StoredAt   time.Time   `json:"stored_at"`
+}
+
+// ResponseCache is an http.RoundTripper that keeps successful GET responses that
+// carry an ETag or Last-Modified in a directory, and revalidates them with
+// If-None-Match / If-Modified-Since on later runs. GitHub does not charge 304
+// responses against the rate limit, so nightly runs over unchanged repositories
+// cost almost nothing. Use it as GenerateOptions.Transport.
+//
+// Entries are keyed by URL and the request's credentials, so tokens with
+// different access never share responses. Cached bodies can include private
+// repository metadata; the directory is created readable by the owner only.
+type ResponseCache struct {
+	dir       string
+	transport http.RoundTripper
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// NewResponseCache creates a cache in dir, sending requests through transport
+// (http.DefaultTransport if nil)
+func NewResponseCache(dir string, transport http.RoundTripper) (*ResponseCache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("cache directory is required")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &ResponseCache{dir: dir, transport: transport}, nil
+}
+
+// Hits returns how many responses were served from the cache after a 304
+func (c *ResponseCache) Hits() int64 {
+	return c.hits.Load()
+}
+
+// Misses returns how many cacheable requests had to download a full response
+func (c *ResponseCache) Misses() int64 {
+	return c.misses.Load()
+}
+
+// RoundTrip revalidates a cached response if there is one, and stores new responses
+func (c *ResponseCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Only plain GETs are cached; callers that send their own validators get the
+	// server's answer unchanged
+	if req.Method != http.MethodGet || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return c.transport.RoundTrip(req)
+	}
+
+	path := filepath.Join(c.dir, cacheKey(req)+".json")
+	cached := readCachedResponse(path)
+
+	outgoing := req
+	if cached != nil {
+		outgoing = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			outgoing.Header.Set("If-None-Match", etag)
+		}
+		if modified := cached.Header.Get("Last-Modified"); modified != "" {
+			outgoing.Header.Set("If-Modified-Since", modified)
+		}
+	}
+
+	resp, err := c.transport.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		resp.Body.Close()
+		c.hits.Add(1)
+		return cached.response(req, resp.Header), nil
+	}
+
+	if resp.StatusCode != http.StatusOK || (resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "") {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	c.misses.Add(1)
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	entry := &cachedResponse{
+		URL:        sanitizeURL(req.URL),
+		StatusCode: resp.StatusCode,
+		Header:     header,
+		Body:       body,
+		StoredAt:   time.Now().UTC(),
+	}
+	// A failed write only costs a full download next time
+	writeCachedResponse(path, entry)
+
+	return resp, nil
+}
+
+// cacheKey identifies a response by URL, credentials and content negotiation.
+// Credentials are hashed, never stored.
+func cacheKey(req *http.Request) string {
+	h := sha256.New()
+	for _, part := range []string{
+		req.URL.String(),
+		req.Header.Get("Authorization"),
+		req.Header.Get("PRIVATE-TOKEN"),
+		req.Header.Get("Accept"),
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// response rebuilds the cached response for req. Headers of the 304, such as the
+// current rate limit, replace the stored ones.
+func (e *cachedResponse) response(req *http.Request, fresh http.Header) *http.Response {
+	header := e.Header.Clone()
+	for name, values := range fresh {
+		if name == "Content-Length" {
+			continue
+		}
+		header[name] = values
+	}
+	header.Set(CacheHeader, "1")
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// readCachedResponse loads an entry; unreadable or corrupt entries count as missing
+func readCachedResponse(path string) *cachedResponse {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entry cachedResponse
+	if err := json.Unmarshal(data, &entry); err != nil || entry.StatusCode != http.StatusOK {
+		return nil
+	}
+	return &entry
+}
+
+// writeCachedResponse stores an entry atomically so concurrent runs never read a partial file
+func writeCachedResponse(path string, entry *cachedResponse) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
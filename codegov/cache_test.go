@@ -0,0 +1,184 @@
+package codegov
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResponseCacheRevalidates(t *testing.T) {
+	token := "0123456789abcdef0123456789abcdef01234567"
+	t.Setenv(OAuthTokenEnv, token)
+
+	downloads, revalidations := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidations++
+			w.Header().Set("X-RateLimit-Remaining", "4998")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Link", `<https://api.github.com/orgs/testorg/repos?page=2>; rel="last"`)
+		w.Write([]byte(`[{"name":"api"}]`))
+	}))
+	defer srv.Close()
+	SetGitHubConfig(GitHubConfig{BaseURI: srv.URL})
+	defer SetGitHubConfig(GitHubConfig{})
+
+	dir := t.TempDir()
+	cache, err := NewResponseCache(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := WithCredentials(&http.Client{Transport: cache}, Credentials{})
+
+	for i := 0; i < 2; i++ {
+		repos, err := getGitHubRepositories(client, "testorg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(repos) != 1 || repos[0].Name != "api" {
+			t.Fatalf("run %d: got %+v", i, repos)
+		}
+	}
+	if downloads != 1 || revalidations != 1 {
+		t.Errorf("downloads = %d, revalidations = %d, want 1 and 1", downloads, revalidations)
+	}
+	if cache.Hits() != 1 || cache.Misses() != 1 {
+		t.Errorf("Hits() = %d, Misses() = %d, want 1 and 1", cache.Hits(), cache.Misses())
+	}
+
+	entries, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(entries) != 1 {
+		t.Fatalf("expected one cache entry, got %v", entries)
+	}
+	data, _ := os.ReadFile(entries[0])
+	if strings.Contains(string(data), token) {
+		t.Error("cache entry contains the API token")
+	}
+}
+
+func TestResponseCacheResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			w.Header().Set("X-RateLimit-Remaining", "10")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("X-RateLimit-Remaining", "11")
+		w.Header().Set("Link", `<next>; rel="next"`)
+		w.Write([]byte(`{"Go":100}`))
+	}))
+	defer srv.Close()
+
+	cache, err := NewResponseCache(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: cache}
+
+	get := func() *http.Response {
+		resp, err := client.Get(srv.URL + "/repos/org/api/languages")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	get().Body.Close()
+
+	resp := get()
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != `{"Go":100}` {
+		t.Fatalf("cached response = %d %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get(CacheHeader) != "1" {
+		t.Errorf("expected %s on a revalidated response", CacheHeader)
+	}
+	if resp.Header.Get("Link") != `<next>; rel="next"` {
+		t.Errorf("stored Link header lost: %q", resp.Header.Get("Link"))
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "10" {
+		t.Errorf("rate limit header should come from the 304, got %q", resp.Header.Get("X-RateLimit-Remaining"))
+	}
+}
+
+func TestResponseCacheKeyedByCredentials(t *testing.T) {
+	downloads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	cache, err := NewResponseCache(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{"token-a", "token-b", "token-a"} {
+		req, _ := http.NewRequest("GET", srv.URL+"/orgs/testorg/repos", nil)
+		req.Header.Set("Authorization", "token "+token)
+		resp, err := cache.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if downloads != 2 {
+		t.Errorf("downloads = %d, want one per token", downloads)
+	}
+}
+
+func TestResponseCacheSkipsUncacheable(t *testing.T) {
+	conditional := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			conditional++
+		}
+		if r.URL.Path == "/no-etag" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	cache, err := NewResponseCache(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: cache}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Post(srv.URL+"/graphql", "application/json", strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		resp, err = client.Get(srv.URL + "/no-etag")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if conditional != 0 {
+		t.Errorf("sent %d conditional requests for uncacheable responses", conditional)
+	}
+	if entries, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(entries) != 0 {
+		t.Errorf("expected no cache entries, got %v", entries)
+	}
+}
@@ -0,0 +1,60 @@
+package codegov
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Version is the library version reported in the default User-Agent
+const Version = "1.0.0"
+
+// DefaultUserAgent identifies API requests made by this library
+const DefaultUserAgent = "GoGovCode/" + Version
+
+// ClientOptions configures the process-wide transport of API requests. The User-Agent
+// and extra headers are set per run with GenerateOptions.UserAgent and Headers.
+type ClientOptions struct {
+	// Transport carries API requests for runs without GenerateOptions.HTTPClient or
+	// GenerateOptions.Transport, and those made outside a run such as URL probes and
+	// GitHub App token requests. Build it with NewTransport to use a proxy or private
+	// CA; nil uses a pooled transport that honors the proxy environment variables.
+	Transport http.RoundTripper
+}
+
+var (
+	clientOptionsMu sync.RWMutex
+	clientOptions   ClientOptions
+)
+
+// SetClientOptions sets the transport for subsequent requests
+func SetClientOptions(options ClientOptions) {
+	clientOptionsMu.Lock()
+	defer clientOptionsMu.Unlock()
+	clientOptions = options
+}
+
+// setClientHeaders identifies the library on a request with DefaultUserAgent. A run's
+// headerTransport replaces it with GenerateOptions.UserAgent.
+func setClientHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", DefaultUserAgent)
+}
+
+// headerTransport sets a run's User-Agent and extra headers on each request. It wraps
+// the credentials, which are added later and so always win over the extra headers.
+type headerTransport struct {
+	base      http.RoundTripper
+	userAgent string
+	headers   map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	if t.userAgent != "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
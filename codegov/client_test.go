@@ -0,0 +1,57 @@
+package codegov
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientHeaders(t *testing.T) {
+	t.Setenv(OAuthTokenEnv, "0123456789abcdef0123456789abcdef01234567")
+
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	SetGitHubConfig(GitHubConfig{BaseURI: srv.URL})
+	defer SetGitHubConfig(GitHubConfig{})
+
+	g := &generator{opts: GenerateOptions{HTTPClient: srv.Client()}, creds: Credentials{}.resolve()}
+	if _, _, err := getGitHubRepositoryLanguages(g.client(time.Second), srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if ua := got.Get("User-Agent"); ua != DefaultUserAgent {
+		t.Errorf("expected default User-Agent, got %q", ua)
+	}
+
+	g.opts.UserAgent = "AgencyInventory/2.1"
+	g.opts.Headers = map[string]string{
+		"Proxy-Authorization": "Basic cHJveHk6cGFzcw==",
+		"Authorization":       "overridden",
+	}
+	if _, _, err := getGitHubRepositoryLanguages(g.client(time.Second), srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if ua := got.Get("User-Agent"); ua != "AgencyInventory/2.1" {
+		t.Errorf("expected configured User-Agent, got %q", ua)
+	}
+	if got.Get("Proxy-Authorization") != "Basic cHJveHk6cGFzcw==" {
+		t.Errorf("extra header not sent: %v", got)
+	}
+	if got.Get("Authorization") != "token 0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("extra headers must not replace the API token, got %q", got.Get("Authorization"))
+	}
+
+	// Another run's options are its own
+	other := &generator{opts: GenerateOptions{HTTPClient: srv.Client()}, creds: Credentials{}.resolve()}
+	if _, _, err := getGitHubRepositoryLanguages(other.client(time.Second), srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if ua := got.Get("User-Agent"); ua != DefaultUserAgent || got.Get("Proxy-Authorization") != "" {
+		t.Errorf("headers leaked into another run: %v", got)
+	}
+}
@@ -1,14 +1,16 @@
 package codegov
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -18,25 +20,27 @@ const (
 	OAuthTokenEnv = "OAUTH_TOKEN"
 )
 
-// SetOAuthToken sets the OAuth token in environment variable
+// SetOAuthToken sets the OAuth token in environment variable; an empty token clears
+// it. The token's format is not checked, so any token GitHub issues is accepted; use
+// TestOAuthToken or VerifyGitHubToken to check it against the API.
 func SetOAuthToken(token string) error {
-	if !regexp.MustCompile(`^([0-9a-f]{40}){0,1}$`).MatchString(token) {
-		return fmt.Errorf("invalid token format")
-	}
 	return os.Setenv(OAuthTokenEnv, token)
 }
 
-// GetOAuthToken retrieves the OAuth token from environment variable
+// GetOAuthToken retrieves the OAuth token from environment variable, falling back
+// to the token store selected with SetTokenStore or $CODEGOV_TOKEN_STORE
 func GetOAuthToken() string {
-	token := os.Getenv(OAuthTokenEnv)
-	return token
+	if token := os.Getenv(OAuthTokenEnv); token != "" {
+		return token
+	}
+	return storedToken()
 }
 
-// TestOAuthToken validates the OAuth token
+// TestOAuthToken validates the OAuth token by calling the GitHub API's /user endpoint
 func TestOAuthToken(token ...string) bool {
 	var tokenToTest string
 
-	if len(token) > 0 {
+	if len(token) > 0 && token[0] != "" {
 		tokenToTest = token[0]
 	} else {
 		tokenToTest = GetOAuthToken()
@@ -46,23 +50,24 @@ func TestOAuthToken(token ...string) bool {
 		return false
 	}
 
-	return regexp.MustCompile(`^([0-9a-f]{40}){1}$`).MatchString(tokenToTest)
+	_, err := VerifyGitHubToken(newHTTPClient(10*time.Second), tokenToTest)
+	return err == nil
 }
 
 // TestURL verifies a URL is accessible
 func TestURL(urlStr string) bool {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+	return probeURL(newHTTPClient(10*time.Second), urlStr)
+}
 
+func probeURL(client *http.Client, urlStr string) bool {
 	req, err := http.NewRequest("HEAD", urlStr, nil)
 	if err != nil {
 		return false
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	setClientHeaders(req)
 
-	resp, err := client.Do(req)
+	resp, err := doWithRetry(client, req)
 	if err != nil {
 		return false
 	}
@@ -71,13 +76,14 @@ func TestURL(urlStr string) bool {
 	return resp.StatusCode == http.StatusOK
 }
 
-// GetGitHubRepositories fetches all repositories for an organization
+// GetGitHubRepositories fetches all repositories for an organization, or for a user
+// account prefixed with GitHubUserPrefix
 func GetGitHubRepositories(organization string) ([]GitHubRepository, error) {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
+	return getGitHubRepositories(newEnvClient(30 * time.Second), organization)
+}
 
-	uri := fmt.Sprintf("%s/orgs/%s/repos?per_page=100", GitHubBaseURI, strings.ToLower(organization))
+func getGitHubRepositories(client *http.Client, organization string) ([]GitHubRepository, error) {
+	uri := parseGitHubOwner(organization).reposURI(gitHubAPIOf(client))
 
 	var allRepos []GitHubRepository
 	page := 1
@@ -90,6 +96,7 @@ func GetGitHubRepositories(organization string) ([]GitHubRepository, error) {
 		}
 
 		allRepos = append(allRepos, repos...)
+		defaultMetrics.addReposFetched("github", len(repos))
 
 		if !hasNext {
 			break
@@ -106,22 +113,17 @@ func fetchRepositoriesPage(client *http.Client, uri string) ([]GitHubRepository,
 		return nil, false, err
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	setClientHeaders(req)
 	req.Header.Set("Accept", "application/vnd.github.mercy-preview+json")
 
-	if TestOAuthToken() {
-		req.Header.Set("Authorization", fmt.Sprintf("token %s", GetOAuthToken()))
-	}
-
-	resp, err := client.Do(req)
+	resp, err := doAPIRequest(client, req)
 	if err != nil {
 		return nil, false, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, false, fmt.Errorf("request failed with status code %d: %s", resp.StatusCode, string(body))
+		return nil, false, newAPIError(resp, ErrOrgNotFound)
 	}
 
 	var repos []GitHubRepository
@@ -136,47 +138,51 @@ func fetchRepositoriesPage(client *http.Client, uri string) ([]GitHubRepository,
 
 // GetGitHubRepositoryLanguages extracts programming languages from a repository
 func GetGitHubRepositoryLanguages(languagesURL string) ([]string, error) {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+	languages, _, err := getGitHubRepositoryLanguages(newEnvClient(10 * time.Second), languagesURL)
+	return languages, err
+}
 
+// getGitHubRepositoryLanguages returns a repository's languages and the total bytes of code in them
+func getGitHubRepositoryLanguages(client *http.Client, languagesURL string) ([]string, int64, error) {
 	req, err := http.NewRequest("GET", languagesURL, nil)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-
-	if TestOAuthToken() {
-		req.Header.Set("Authorization", fmt.Sprintf("token %s", GetOAuthToken()))
-	}
+	setClientHeaders(req)
 
-	resp, err := client.Do(req)
+	resp, err := doAPIRequest(client, req)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return []string{}, nil
+		return []string{}, 0, nil
 	}
 
-	var languageStats map[string]interface{}
+	var languageStats map[string]int64
 	if err := json.NewDecoder(resp.Body).Decode(&languageStats); err != nil {
-		return []string{}, nil
+		return []string{}, 0, nil
 	}
 
 	languages := make([]string, 0, len(languageStats))
-	for lang := range languageStats {
+	var codeBytes int64
+	for lang, size := range languageStats {
 		languages = append(languages, lang)
+		codeBytes += size
 	}
 	sort.Strings(languages)
 
-	return languages, nil
+	return languages, codeBytes, nil
 }
 
 // GetGitHubRepositoryLicenseURL finds the license file URL
 func GetGitHubRepositoryLicenseURL(repositoryURL, branch string) string {
+	return getGitHubRepositoryLicenseURL(newEnvClient(10 * time.Second), repositoryURL, branch)
+}
+
+func getGitHubRepositoryLicenseURL(client *http.Client, repositoryURL, branch string) string {
 	urls := []string{
 		fmt.Sprintf("%s/blob/%s/LICENSE", repositoryURL, branch),
 		fmt.Sprintf("%s/blob/%s/LICENSE.md", repositoryURL, branch),
@@ -184,7 +190,7 @@ func GetGitHubRepositoryLicenseURL(repositoryURL, branch string) string {
 	}
 
 	for _, urlStr := range urls {
-		if TestURL(urlStr) {
+		if probeURL(client, urlStr) {
 			return urlStr
 		}
 	}
@@ -194,24 +200,20 @@ func GetGitHubRepositoryLicenseURL(repositoryURL, branch string) string {
 
 // GetGitHubRepositoryLicense retrieves license information from GitHub
 func GetGitHubRepositoryLicense(organization, repositoryURL, project, branch string) (*License, error) {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+	return getGitHubRepositoryLicense(newEnvClient(10 * time.Second), organization, repositoryURL, project, branch)
+}
 
-	uri := fmt.Sprintf("%s/repos/%s/%s/license", GitHubBaseURI, strings.ToLower(organization), project)
+func getGitHubRepositoryLicense(client *http.Client, organization, repositoryURL, project, branch string) (*License, error) {
+	uri := fmt.Sprintf("%s/repos/%s/%s/license", gitHubAPIOf(client).baseURI(), strings.ToLower(organization), project)
 
 	req, err := http.NewRequest("GET", uri, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	setClientHeaders(req)
 
-	if TestOAuthToken() {
-		req.Header.Set("Authorization", fmt.Sprintf("token %s", GetOAuthToken()))
-	}
-
-	resp, err := client.Do(req)
+	resp, err := doAPIRequest(client, req)
 	if err != nil {
 		return nil, err
 	}
@@ -225,7 +227,7 @@ func GetGitHubRepositoryLicense(organization, repositoryURL, project, branch str
 	license := &License{}
 
 	if lic.Message != "" || resp.StatusCode != http.StatusOK {
-		license.URL = GetGitHubRepositoryLicenseURL(repositoryURL, branch)
+		license.URL = getGitHubRepositoryLicenseURL(client, repositoryURL, branch)
 		license.Name = ""
 	} else {
 		license.URL = lic.HTMLURL
@@ -237,14 +239,23 @@ func GetGitHubRepositoryLicense(organization, repositoryURL, project, branch str
 
 // GetGitHubRepositoryDisclaimerURL finds the disclaimer file URL
 func GetGitHubRepositoryDisclaimerURL(repositoryURL, branch string) string {
+	return getGitHubRepositoryDisclaimerURL(newEnvClient(10 * time.Second), repositoryURL, branch)
+}
+
+func getGitHubRepositoryDisclaimerURL(client *http.Client, repositoryURL, branch string) string {
+	return getGitHubFileURL(client, repositoryURL, branch, "DISCLAIMER")
+}
+
+// getGitHubFileURL probes for name, name.md and name.txt on a branch
+func getGitHubFileURL(client *http.Client, repositoryURL, branch, name string) string {
 	urls := []string{
-		fmt.Sprintf("%s/blob/%s/DISCLAIMER", repositoryURL, branch),
-		fmt.Sprintf("%s/blob/%s/DISCLAIMER.md", repositoryURL, branch),
-		fmt.Sprintf("%s/blob/%s/DISCLAIMER.txt", repositoryURL, branch),
+		fmt.Sprintf("%s/blob/%s/%s", repositoryURL, branch, name),
+		fmt.Sprintf("%s/blob/%s/%s.md", repositoryURL, branch, name),
+		fmt.Sprintf("%s/blob/%s/%s.txt", repositoryURL, branch, name),
 	}
 
 	for _, urlStr := range urls {
-		if TestURL(urlStr) {
+		if probeURL(client, urlStr) {
 			return urlStr
 		}
 	}
@@ -254,91 +265,130 @@ func GetGitHubRepositoryDisclaimerURL(repositoryURL, branch string) string {
 
 // GetGitHubRepositoryReleaseURL finds the release/download URL
 func GetGitHubRepositoryReleaseURL(releasesURL string) (string, error) {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+	downloadURL, _, err := getGitHubRepositoryRelease(newEnvClient(10 * time.Second), releasesURL)
+	return downloadURL, err
+}
 
+// getGitHubRepositoryRelease returns the download URL and tag of the latest published release
+func getGitHubRepositoryRelease(client *http.Client, releasesURL string) (string, string, error) {
 	uri := strings.Replace(releasesURL, "{/id}", "", -1)
 
 	req, err := http.NewRequest("GET", uri, nil)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	setClientHeaders(req)
 
-	if TestOAuthToken() {
-		req.Header.Set("Authorization", fmt.Sprintf("token %s", GetOAuthToken()))
-	}
-
-	resp, err := client.Do(req)
+	resp, err := doAPIRequest(client, req)
 	if err != nil {
-		return "", nil
+		return "", "", nil
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", nil
+		return "", "", nil
 	}
 
 	var releases []GitHubRelease
 	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
-		return "", nil
+		return "", "", nil
 	}
 
 	for _, release := range releases {
 		if !release.Prerelease {
-			url := strings.Replace(release.ZipballURL, "api.", "", 1)
-			return url, nil
+			return gitHubAPIOf(client).webLink(release.ZipballURL), release.TagName, nil
 		}
 	}
 
-	return "", nil
+	return "", "", nil
 }
 
-// NewCodeGovJSON generates a code.gov JSON object from GitHub data
-func NewCodeGovJSON(organizations []string, agencyName, agencyEmail string, agencyOptions map[string]string, includePrivate, includeForks bool) (*CodeGovJSON, error) {
-	var releases []Release
+// getGitHubRepositoryTags lists the names of a repository's most recent tags
+func getGitHubRepositoryTags(client *http.Client, fullName string) ([]string, error) {
+	uri := fmt.Sprintf("%s/repos/%s/tags?per_page=100", gitHubAPIOf(client).baseURI(), fullName)
 
-	for _, org := range organizations {
-		repos, err := GetGitHubRepositories(org)
-		if err != nil {
-			log.Printf("Error fetching repositories for %s: %v\n", org, err)
-			continue
-		}
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
 
-		for _, repo := range repos {
-			if repo.Private != includePrivate || repo.Fork != includeForks {
-				continue
-			}
+	setClientHeaders(req)
 
-			release, err := buildRelease(org, repo, agencyName, agencyEmail, agencyOptions)
-			if err != nil {
-				log.Printf("Error building release for %s/%s: %v\n", org, repo.Name, err)
-				continue
-			}
+	resp, err := doAPIRequest(client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
-			releases = append(releases, release)
-		}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, nil)
 	}
 
-	sort.Slice(releases, func(i, j int) bool {
-		return releases[i].Name < releases[j].Name
-	})
+	var tags []struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names, nil
+}
+
+// getGitHubBranchSHA returns the commit SHA at the head of a branch
+func getGitHubBranchSHA(client *http.Client, fullName, branch string) (string, error) {
+	uri := fmt.Sprintf("%s/repos/%s/commits/%s", gitHubAPIOf(client).baseURI(), fullName, url.PathEscape(branch))
+
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return "", err
+	}
+
+	setClientHeaders(req)
+	req.Header.Set("Accept", "application/vnd.github.sha")
+
+	resp, err := doAPIRequest(client, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
 
-	codeGov := &CodeGovJSON{
-		Version: "2.0",
-		Agency:  agencyName,
-		MeasurementType: MeasurementType{
-			Method: "projects",
-		},
-		Releases: releases,
+	// An empty repository has no commits
+	if resp.StatusCode == http.StatusConflict {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError(resp, nil)
 	}
 
-	return codeGov, nil
+	sha, err := io.ReadAll(io.LimitReader(resp.Body, 128))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(sha)), nil
+}
+
+// NewCodeGovJSON generates a code.gov JSON object from GitHub data.
+// It is equivalent to Generate with the corresponding GenerateOptions; use
+// GenerateWithReport to learn which repositories failed.
+//
+// Deprecated: use Generate in github.com/NSACodeGov/CodeGov/v2/codegov.
+func NewCodeGovJSON(organizations []string, agencyName, agencyEmail string, agencyOptions map[string]string, includePrivate, includeForks bool) (*CodeGovJSON, error) {
+	return Generate(GenerateOptions{
+		Organizations:  organizations,
+		Agency:         agencyName,
+		Contact:        newContact(agencyEmail, agencyOptions),
+		IncludePrivate: includePrivate,
+		IncludeForks:   includeForks,
+	})
 }
 
-func buildRelease(org string, repo GitHubRepository, agencyName, agencyEmail string, agencyOptions map[string]string) (Release, error) {
+// newContact builds the release contact from the agency email and options
+func newContact(agencyEmail string, agencyOptions map[string]string) Contact {
 	contact := Contact{
 		Email: agencyEmail,
 	}
@@ -353,165 +403,249 @@ func buildRelease(org string, repo GitHubRepository, agencyName, agencyEmail str
 		contact.Phone = phone
 	}
 
-	languages, _ := GetGitHubRepositoryLanguages(repo.LanguagesURL)
+	return contact
+}
 
-	lic, err := GetGitHubRepositoryLicense(org, repo.HTMLURL, repo.Name, repo.DefaultBranch)
-	if err != nil {
-		lic = &License{}
-	}
+// gitHubProvider inventories GitHub organizations and user accounts. With graphQL set,
+// listing fetches every repository's enrichment data in bulk, so its other methods
+// answer without per-repository REST calls.
+type gitHubProvider struct {
+	graphQL bool
+}
 
-	disclaimerURL := GetGitHubRepositoryDisclaimerURL(repo.HTMLURL, repo.DefaultBranch)
+// gitHubListing is what the GitHub provider keeps of a listed repository
+type gitHubListing struct {
+	repo     GitHubRepository
+	fullName string
+	root     *gitHubRoot    // Root of the default branch, for license and metadata files
+	bulk     *gitHubDetails // Enrichment data fetched through GraphQL; nil over REST
+}
 
-	downloadURL, _ := GetGitHubRepositoryReleaseURL(repo.ReleasesURL)
-	if downloadURL == "" {
-		downloadURL = fmt.Sprintf("%s/archive/%s.zip", repo.HTMLURL, repo.DefaultBranch)
+// listing returns the listing data of a repository, setting the root's client on first use
+func (gitHubProvider) listing(client *http.Client, repo ProviderRepository) *gitHubListing {
+	l := repo.details.(*gitHubListing)
+	if l.root.client == nil {
+		l.root.client = client
 	}
+	return l
+}
 
-	description := repo.Description
-	if description == "" {
-		description = "No description provided"
+// ListRepos lists an organization's repositories, or a user's when owner is prefixed
+// with GitHubUserPrefix
+func (p gitHubProvider) ListRepos(client *http.Client, owner string) ([]ProviderRepository, error) {
+	var repos []GitHubRepository
+	var details map[string]*gitHubDetails
+	var err error
+	if p.graphQL {
+		repos, details, err = getGitHubRepositoriesGraphQL(client, owner)
+	} else {
+		repos, err = getGitHubRepositories(client, owner)
 	}
-
-	tags := repo.Topics
-	if len(tags) == 0 {
-		tags = []string{"none"}
+	if err != nil {
+		return nil, err
 	}
 
-	homepageURL := repo.Homepage
-	if homepageURL == "" {
-		homepageURL = repo.HTMLURL
-	}
+	user := parseGitHubOwner(owner).user
+	result := make([]ProviderRepository, 0, len(repos))
+	for _, repo := range repos {
+		// A user's repositories are published under the login, without the prefix
+		login := owner
+		if user {
+			login = repo.Owner.Login
+		}
+		fullName := login + "/" + repo.Name
 
-	status := "Production"
-	if repo.Archived {
-		status = "Archival"
-	}
+		listing := &gitHubListing{repo: repo, fullName: fullName, bulk: details[repo.Name]}
+		if listing.bulk != nil {
+			listing.root = listing.bulk.root
+		} else {
+			listing.root = &gitHubRoot{fullName: fullName, branch: repo.DefaultBranch}
+		}
 
-	release := Release{
-		Name:           repo.Name,
-		RepositoryURL:  repo.HTMLURL,
-		Description:    description,
-		Permissions: Permissions{
-			Licenses: []License{
-				{
-					URL:  lic.URL,
-					Name: lic.Name,
-				},
-			},
-			UsageType: "openSource",
-		},
-		LaborHours:   1,
-		Tags:         tags,
-		Contact:      contact,
-		Status:       status,
-		VCS:          "git",
-		HomepageURL:  homepageURL,
-		DownloadURL:  downloadURL,
-		Languages:    languages,
-		DisclaimerURL: disclaimerURL,
-		Date: DateInfo{
-			Created:             repo.CreatedAt.Format("2006-01-02"),
-			LastModified:        repo.PushedAt.Format("2006-01-02"),
-			MetadataLastUpdated: repo.UpdatedAt.Format("2006-01-02"),
-		},
-	}
+		// Pushes date the code; updated_at also moves with stars and settings
+		updated := repo.PushedAt
+		if updated.IsZero() {
+			updated = repo.UpdatedAt
+		}
+		result = append(result, ProviderRepository{
+			ID:              fullName,
+			Name:            repo.Name,
+			Description:     repo.Description,
+			WebURL:          repo.HTMLURL,
+			CloneURL:        repo.CloneURL,
+			DefaultBranch:   repo.DefaultBranch,
+			Private:         repo.Private,
+			Fork:            repo.Fork,
+			Archived:        repo.Archived,
+			Topics:          repo.Topics,
+			Created:         repo.CreatedAt,
+			Updated:         updated,
+			Owner:           login,
+			Homepage:        repo.Homepage,
+			MetadataUpdated: repo.UpdatedAt,
+			details:         listing,
+		})
+	}
+	return result, nil
+}
 
-	return release, nil
+// Languages returns the languages GitHub detected, sorted
+func (p gitHubProvider) Languages(client *http.Client, repo ProviderRepository) ([]string, error) {
+	languages, _, err := p.LanguageSizes(client, repo)
+	return languages, err
 }
 
-// NewCodeGovJSONFile generates and saves code.gov JSON to a file
-func NewCodeGovJSONFile(organizations []string, agencyName, agencyEmail string, agencyOptions map[string]string, includePrivate, includeForks bool, outputPath string) error {
-	codeGov, err := NewCodeGovJSON(organizations, agencyName, agencyEmail, agencyOptions, includePrivate, includeForks)
-	if err != nil {
-		return err
+// LanguageSizes implements ProviderCodeSizer
+func (p gitHubProvider) LanguageSizes(client *http.Client, repo ProviderRepository) ([]string, int64, error) {
+	l := p.listing(client, repo)
+	if l.bulk != nil {
+		return l.bulk.languages, l.bulk.codeBytes, nil
 	}
+	return getGitHubRepositoryLanguages(client, l.repo.LanguagesURL)
+}
 
-	data, err := json.MarshalIndent(codeGov, "", "  ")
-	if err != nil {
-		return err
+// License returns the license GitHub detected, or the URL of a LICENSE file it could not identify
+func (p gitHubProvider) License(client *http.Client, repo ProviderRepository) (*License, error) {
+	l := p.listing(client, repo)
+	if l.bulk != nil {
+		license := l.bulk.license
+		return &license, nil
 	}
+	login, _, _ := strings.Cut(l.fullName, "/")
+	return getGitHubRepositoryLicense(client, login, l.repo.HTMLURL, l.repo.Name, l.repo.DefaultBranch)
+}
 
-	return os.WriteFile(outputPath, data, 0644)
+// Releases returns the zipball of the latest published release, falling back to the default branch
+func (p gitHubProvider) Releases(client *http.Client, repo ProviderRepository) (string, error) {
+	downloadURL, _, err := p.LatestRelease(client, repo)
+	return downloadURL, err
 }
 
-// TestCodeGovJSONFile validates a code.gov JSON file against the schema
-func TestCodeGovJSONFile(filePath string) (bool, []string, error) {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return false, nil, err
+// LatestRelease implements ProviderReleaser
+func (p gitHubProvider) LatestRelease(client *http.Client, repo ProviderRepository) (string, string, error) {
+	l := p.listing(client, repo)
+	var downloadURL, tag string
+	var err error
+	if l.bulk != nil {
+		downloadURL, tag = l.bulk.downloadURL, l.bulk.releaseTag
+	} else {
+		downloadURL, tag, err = getGitHubRepositoryRelease(client, l.repo.ReleasesURL)
 	}
+	if downloadURL == "" {
+		downloadURL = fmt.Sprintf("%s/archive/%s.zip", l.repo.HTMLURL, l.repo.DefaultBranch)
+	}
+	return downloadURL, tag, err
+}
 
-	var codeGov CodeGovJSON
-	if err := json.Unmarshal(data, &codeGov); err != nil {
-		return false, nil, err
+// FileURL looks name, name.md and name.txt up in the listed root, or probes for them
+// when the root has not been listed
+func (p gitHubProvider) FileURL(client *http.Client, repo ProviderRepository, name string) string {
+	l := p.listing(client, repo)
+	if files := l.root.files; files != nil {
+		for _, candidate := range []string{name, name + ".md", name + ".txt"} {
+			if files[candidate] {
+				return fmt.Sprintf("%s/blob/%s/%s", l.repo.HTMLURL, l.repo.DefaultBranch, candidate)
+			}
+		}
+		return ""
 	}
+	return getGitHubFileURL(client, l.repo.HTMLURL, l.repo.DefaultBranch, name)
+}
 
-	var errors []string
+// ListRoot implements ProviderRootLister
+func (p gitHubProvider) ListRoot(client *http.Client, repo ProviderRepository) ([]string, error) {
+	return p.listing(client, repo).root.list()
+}
 
-	// Basic validation
-	if codeGov.Version == "" {
-		errors = append(errors, "version is required")
-	}
-	if codeGov.Agency == "" {
-		errors = append(errors, "agency is required")
+// ReadFile implements ProviderFileReader. Metadata files fetched in bulk are served
+// without a request.
+func (p gitHubProvider) ReadFile(client *http.Client, repo ProviderRepository, name string) ([]byte, error) {
+	l := p.listing(client, repo)
+	if l.bulk != nil {
+		if blob, ok := l.bulk.metadata[name]; ok {
+			if blob != nil && blob.Text != nil {
+				return []byte(*blob.Text), nil
+			}
+			return nil, nil
+		}
 	}
-	if codeGov.MeasurementType.Method == "" {
-		errors = append(errors, "measurementType.method is required")
+	return l.root.read(name)
+}
+
+// Tags implements ProviderVersioner
+func (p gitHubProvider) Tags(client *http.Client, repo ProviderRepository) ([]string, error) {
+	l := p.listing(client, repo)
+	if l.bulk != nil {
+		return l.bulk.tags, nil
 	}
-	if len(codeGov.Releases) == 0 {
-		errors = append(errors, "releases is required and must not be empty")
+	return getGitHubRepositoryTags(client, l.fullName)
+}
+
+// BranchSHA implements ProviderVersioner
+func (p gitHubProvider) BranchSHA(client *http.Client, repo ProviderRepository) (string, error) {
+	l := p.listing(client, repo)
+	if l.bulk != nil {
+		return l.bulk.sha, nil
 	}
+	return getGitHubBranchSHA(client, l.fullName, l.repo.DefaultBranch)
+}
 
-	for i, release := range codeGov.Releases {
-		releaseErrors := validateRelease(release)
-		for _, e := range releaseErrors {
-			errors = append(errors, fmt.Sprintf("releases[%d]: %s", i, e))
-		}
+// organization returns the organization published on releases of an owner's repositories
+func (g *generator) organization(owner string) string {
+	if g.opts.Organization != "" {
+		return g.opts.Organization
 	}
+	return owner
+}
 
-	return len(errors) == 0, errors, nil
+// NewCodeGovJSONFile generates and saves code.gov JSON to a file
+//
+// Deprecated: use GenerateFile in github.com/NSACodeGov/CodeGov/v2/codegov.
+func NewCodeGovJSONFile(organizations []string, agencyName, agencyEmail string, agencyOptions map[string]string, includePrivate, includeForks bool, outputPath string) error {
+	return GenerateFile(GenerateOptions{
+		Organizations:  organizations,
+		Agency:         agencyName,
+		Contact:        newContact(agencyEmail, agencyOptions),
+		IncludePrivate: includePrivate,
+		IncludeForks:   includeForks,
+	}, outputPath)
 }
 
-func validateRelease(release Release) []string {
-	var errors []string
+// TestCodeGovJSONFile validates a code.gov JSON file against the official 2.0.0 schema
+// and the semantic checks of ValidateSemantics
+//
+// Deprecated: use ValidateFile in github.com/NSACodeGov/CodeGov/v2/codegov, which returns each result
+// with its severity.
+func TestCodeGovJSONFile(filePath string) (bool, []string, error) {
+	return TestCodeGovJSONFileWithSchema(filePath, DefaultSchema())
+}
 
-	if release.Name == "" {
-		errors = append(errors, "name is required")
-	}
-	if release.RepositoryURL == "" {
-		errors = append(errors, "repositoryURL is required")
-	}
-	if release.Description == "" {
-		errors = append(errors, "description is required")
-	}
-	if len(release.Tags) == 0 {
-		errors = append(errors, "tags is required")
-	}
-	if release.Contact.Email == "" {
-		errors = append(errors, "contact.email is required")
-	}
-	if release.LaborHours == 0 {
-		errors = append(errors, "laborHours is required and must not be 0")
-	}
-	if len(release.Permissions.Licenses) == 0 {
-		errors = append(errors, "permissions.licenses is required")
-	} else {
-		for i, lic := range release.Permissions.Licenses {
-			if lic.URL == "" {
-				errors = append(errors, fmt.Sprintf("permissions.licenses[%d].URL is required", i))
-			}
-			if lic.Name == "" {
-				errors = append(errors, fmt.Sprintf("permissions.licenses[%d].name is required", i))
-			}
-		}
+// TestCodeGovJSONFileWithSchema validates a code.gov JSON file against a custom or newer
+// schema; a .yaml or .yml file is validated as the JSON it converts to. The file is
+// valid when there are no errors; warnings are returned too, prefixed "warning: ".
+// ValidateCodeGovJSONFile returns the issues with their severity.
+//
+// Deprecated: use ValidateFile in github.com/NSACodeGov/CodeGov/v2/codegov with ValidateOptions.Schema.
+func TestCodeGovJSONFileWithSchema(filePath string, schema *Schema) (bool, []string, error) {
+	issues, err := ValidateCodeGovJSONFile(filePath, schema)
+	if err != nil {
+		return false, nil, err
 	}
 
-	return errors
+	messages := make([]string, len(issues))
+	for i, issue := range issues {
+		messages[i] = issue.String()
+	}
+	return len(ValidationErrors(issues)) == 0, messages, nil
 }
 
-// InvokeCodeGovJsonOverride applies overrides to a code.gov JSON file
+// InvokeCodeGovJsonOverride applies overrides to a code.gov JSON file. The override
+// file is either an {"overrides": [...]} document or an RFC 6902 JSON Patch array.
+// Each file may be YAML instead of JSON when its name ends in .yaml or .yml.
+//
+// Deprecated: use Override in github.com/NSACodeGov/CodeGov/v2/codegov.
 func InvokeCodeGovJsonOverride(originalPath, newPath, overridePath string) error {
-	originalData, err := os.ReadFile(originalPath)
+	originalData, err := readDocument(originalPath)
 	if err != nil {
 		return err
 	}
@@ -521,11 +655,24 @@ func InvokeCodeGovJsonOverride(originalPath, newPath, overridePath string) error
 		return err
 	}
 
-	overrideData, err := os.ReadFile(overridePath)
+	overrideData, err := readDocument(overridePath)
 	if err != nil {
 		return err
 	}
 
+	if err := applyOverrides(&codeGov, overrideData); err != nil {
+		return err
+	}
+	return writeCodeGovJSON(&codeGov, newPath)
+}
+
+// applyOverrides applies an override document, either a JSON Patch or the
+// {"overrides": [...]} format, to an inventory
+func applyOverrides(codeGov *CodeGovJSON, overrideData []byte) error {
+	if isJSONPatch(overrideData) {
+		return ApplyJSONPatch(codeGov, overrideData)
+	}
+
 	var overrides OverrideJSON
 	if err := json.Unmarshal(overrideData, &overrides); err != nil {
 		return err
@@ -547,7 +694,9 @@ func InvokeCodeGovJsonOverride(originalPath, newPath, overridePath string) error
 
 		switch override.Action {
 		case "replaceproperty":
-			applyReplaceProperty(release, override.Property, override.Value)
+			if err := applyReplaceProperty(release, override.Property, override.Value); err != nil {
+				log.Printf("Cannot replace property on %s: %v\n", override.Project, err)
+			}
 		case "addproperty":
 			log.Printf("Add property not yet implemented\n")
 		case "removeproperty":
@@ -568,25 +717,114 @@ func InvokeCodeGovJsonOverride(originalPath, newPath, overridePath string) error
 		return releases[i].Name < releases[j].Name
 	})
 	codeGov.Releases = releases
+	return nil
+}
 
-	// Write output
-	data, err := json.MarshalIndent(codeGov, "", "  ")
+// ReadCodeGovJSONFile reads a code.gov inventory from a JSON file, or a YAML file
+// ending in .yaml or .yml
+func ReadCodeGovJSONFile(path string) (*CodeGovJSON, error) {
+	data, err := readDocument(path)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	var codeGov CodeGovJSON
+	if err := json.Unmarshal(data, &codeGov); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
 	}
+	return &codeGov, nil
+}
 
-	return os.WriteFile(newPath, data, 0644)
+// ConvertCodeGovJSONFile rewrites an inventory between JSON and YAML, choosing each
+// format by file extension. The output is the canonical encoding of the inventory, so
+// converting YAML to JSON and back yields the same data.
+func ConvertCodeGovJSONFile(inputPath, outputPath string) error {
+	codeGov, err := ReadCodeGovJSONFile(inputPath)
+	if err != nil {
+		return err
+	}
+	return writeCodeGovJSON(codeGov, outputPath)
 }
 
-func applyReplaceProperty(release *Release, property string, value interface{}) {
+// applyReplaceProperty sets a release property addressed by a dotted path such as
+// "laborHours", "contact.email" or "permissions.licenses.0.name". Numeric segments
+// index into arrays. The release is left unchanged if the path or value is invalid.
+func applyReplaceProperty(release *Release, property string, value interface{}) error {
+	if property == "" {
+		return fmt.Errorf("property is required")
+	}
+
+	data, err := json.Marshal(release)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
 	parts := strings.Split(property, ".")
+	if err := setPath(doc, parts, value); err != nil {
+		return fmt.Errorf("%s: %w", property, err)
+	}
+
+	data, err = json.Marshal(doc)
+	if err != nil {
+		return err
+	}
 
-	if len(parts) == 1 {
-		switch property {
-		case "laborHours":
-			if v, ok := value.(float64); ok {
-				release.LaborHours = v
+	var updated Release
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&updated); err != nil {
+		return fmt.Errorf("%s: %w", property, err)
+	}
+
+	*release = updated
+	return nil
+}
+
+// setPath replaces the value at path within a decoded JSON document
+func setPath(node interface{}, path []string, value interface{}) error {
+	key := path[0]
+	last := len(path) == 1
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		// Accept keys regardless of case, e.g. "repositoryurl" for "repositoryURL"
+		if _, ok := n[key]; !ok {
+			for k := range n {
+				if strings.EqualFold(k, key) {
+					key = k
+					break
+				}
 			}
 		}
+		if last {
+			n[key] = value
+			return nil
+		}
+		child, ok := n[key]
+		if !ok || child == nil {
+			return fmt.Errorf("%s not found", key)
+		}
+		return setPath(child, path[1:], value)
+
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil {
+			return fmt.Errorf("%q is not an array index", key)
+		}
+		if i < 0 || i >= len(n) {
+			return fmt.Errorf("index %d out of range (length %d)", i, len(n))
+		}
+		if last {
+			n[i] = value
+			return nil
+		}
+		return setPath(n[i], path[1:], value)
+
+	default:
+		return fmt.Errorf("cannot descend into %s", key)
 	}
 }
@@ -0,0 +1,78 @@
+package codegov
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyReplaceProperty(t *testing.T) {
+	fixture := func() Release {
+		return Release{
+			Name:          "widget",
+			RepositoryURL: "https://example.gov/widget",
+			Permissions: Permissions{
+				Licenses:  []License{{URL: "https://example.gov/LICENSE", Name: "MIT"}},
+				UsageType: "openSource",
+			},
+			Tags:    []string{"go", "tools"},
+			Contact: Contact{Email: "code@example.gov"},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		property string
+		value    interface{}
+		want     func(*Release) // Applied to the fixture to give the expected release
+		wantErr  string         // Expected error text when the release must be left unchanged
+	}{
+		{"top-level field", "description", "A widget", func(r *Release) { r.Description = "A widget" }, ""},
+		{"number", "laborHours", 40.0, func(r *Release) { r.LaborHours = 40 }, ""},
+		{"whole array", "tags", []interface{}{"a", "b"}, func(r *Release) { r.Tags = []string{"a", "b"} }, ""},
+		{"nested field", "contact.email", "team@example.gov", func(r *Release) { r.Contact.Email = "team@example.gov" }, ""},
+		{"omitted nested field", "contact.name", "Team", func(r *Release) { r.Contact.Name = "Team" }, ""},
+		{"array index", "tags.1", "cli", func(r *Release) { r.Tags[1] = "cli" }, ""},
+		{"field inside array element", "permissions.licenses.0.name", "Apache-2.0", func(r *Release) { r.Permissions.Licenses[0].Name = "Apache-2.0" }, ""},
+		{"array element object", "permissions.licenses.0", map[string]interface{}{"URL": "https://example.gov/COPYING", "name": "GPL-3.0"},
+			func(r *Release) {
+				r.Permissions.Licenses[0] = License{URL: "https://example.gov/COPYING", Name: "GPL-3.0"}
+			}, ""},
+		{"case-insensitive key", "repositoryurl", "https://example.gov/w", func(r *Release) { r.RepositoryURL = "https://example.gov/w" }, ""},
+		{"case-insensitive nested keys", "Permissions.Licenses.0.url", "https://example.gov/L", func(r *Release) { r.Permissions.Licenses[0].URL = "https://example.gov/L" }, ""},
+		{"case-insensitive omitted field", "disclaimerurl", "https://example.gov/D", func(r *Release) { r.DisclaimerURL = "https://example.gov/D" }, ""},
+
+		{"empty property", "", "x", nil, "property is required"},
+		{"index out of range", "tags.2", "x", nil, "tags.2: index 2 out of range (length 2)"},
+		{"negative index", "permissions.licenses.-1.name", "x", nil, "index -1 out of range (length 1)"},
+		{"non-numeric index", "tags.first", "x", nil, `"first" is not an array index`},
+		{"unknown field", "owner", "me", nil, `owner: json: unknown field "owner"`},
+		{"unknown nested field", "contact.fax", "555-0100", nil, `contact.fax: json: unknown field "fax"`},
+		{"unknown field in array element", "permissions.licenses.0.spdx", "MIT", nil, `unknown field "spdx"`},
+		{"wrong type", "laborHours", "many", nil, "laborHours: json: cannot unmarshal string"},
+		{"missing parent", "partners.0.name", "x", nil, "partners.0.name: partners not found"},
+		{"descend into scalar", "name.first", "x", nil, "name.first: cannot descend into first"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := fixture()
+			err := applyReplaceProperty(&release, tt.property, tt.value)
+
+			want := fixture()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("applyReplaceProperty failed: %v", err)
+				}
+				tt.want(&want)
+			}
+			if !reflect.DeepEqual(release, want) {
+				t.Errorf("release = %+v, want %+v", release, want)
+			}
+		})
+	}
+}
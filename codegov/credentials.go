@@ -0,0 +1,183 @@
+package codegov
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Credentials holds the API credentials for one client or generation run, so concurrent runs
+// can authenticate as different users or GitHub App installations without touching the
+// process environment. Empty fields fall back to OAUTH_TOKEN, GITLAB_TOKEN, the
+// BITBUCKET_* variables and AZURE_DEVOPS_TOKEN unless NoEnvFallback is set.
+type Credentials struct {
+	GitHubToken          string     // Personal access, OAuth or GitHub App installation token
+	GitHubApp            *GitHubApp // Authenticates as a GitHub App installation when GitHubToken is empty
+	GitLabToken          string
+	BitbucketToken       string // Sent as a bearer token; takes precedence over the app password
+	BitbucketUsername    string
+	BitbucketAppPassword string
+	AzureDevOpsToken     string // Personal access token, sent as the password of basic authentication
+
+	NoEnvFallback bool
+
+	gitHub gitHubAPI // GitHub the run's GitHub credentials belong to; set by the generator
+}
+
+// resolve fills empty fields from the environment
+func (c Credentials) resolve() Credentials {
+	if c.NoEnvFallback {
+		return c
+	}
+	if c.GitHubToken == "" && c.GitHubApp == nil {
+		c.GitHubToken = GetOAuthToken()
+	}
+	if c.GitLabToken == "" {
+		c.GitLabToken = GetGitLabToken()
+	}
+	if c.BitbucketToken == "" && c.BitbucketUsername == "" {
+		c.BitbucketToken = os.Getenv(BitbucketTokenEnv)
+		c.BitbucketUsername = os.Getenv(BitbucketUsernameEnv)
+		c.BitbucketAppPassword = os.Getenv(BitbucketAppPasswordEnv)
+	}
+	if c.AzureDevOpsToken == "" {
+		c.AzureDevOpsToken = os.Getenv(AzureDevOpsTokenEnv)
+	}
+	return c
+}
+
+// authorize adds the credentials matching the request's host. Tokens are only sent to the
+// API host they belong to and replace any Authorization header set from ClientOptions.
+func (c Credentials) authorize(req *http.Request) {
+	switch host := req.URL.Host; {
+	case host == c.gitHub.apiHost() && c.GitHubToken != "":
+		// The GraphQL endpoint documents the bearer scheme; REST accepts the classic token scheme
+		if strings.HasSuffix(req.URL.Path, "/graphql") {
+			req.Header.Set("Authorization", "bearer "+c.GitHubToken)
+		} else {
+			req.Header.Set("Authorization", "token "+c.GitHubToken)
+		}
+	case host == gitLabHost() && c.GitLabToken != "":
+		req.Header.Set("PRIVATE-TOKEN", c.GitLabToken)
+	case host == bitbucketHost() && c.BitbucketToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.BitbucketToken)
+	case host == bitbucketHost() && c.BitbucketUsername != "":
+		req.SetBasicAuth(c.BitbucketUsername, c.BitbucketAppPassword)
+	case host == azureDevOpsHost() && c.AzureDevOpsToken != "":
+		req.SetBasicAuth("", c.AzureDevOpsToken)
+	}
+}
+
+// hasGitHubAuth reports whether GitHub API requests will be authenticated
+func (c Credentials) hasGitHubAuth() bool {
+	return c.GitHubToken != "" || c.GitHubApp != nil
+}
+
+// cloneEnv returns the environment that authenticates git with the matching host's token,
+// so private repositories can be cloned. The token is sent as an http.extraHeader scoped to
+// the host through GIT_CONFIG_* variables (git 2.31+) rather than embedded in the clone
+// URL, where other local users could read it from the process list.
+func (c Credentials) cloneEnv(cloneURL string) []string {
+	u, err := url.Parse(cloneURL)
+	if err != nil || u.Scheme != "https" {
+		return nil
+	}
+
+	if c.GitHubToken == "" && c.GitHubApp != nil && u.Host == c.gitHub.host() {
+		// Clone with the token of the installation on the repository's owner
+		owner, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+		if token, err := c.GitHubApp.token(c.gitHub, owner); err == nil {
+			c.GitHubToken = token
+		}
+	}
+
+	var username, password string
+	switch {
+	case u.Host == c.gitHub.host() && c.GitHubToken != "":
+		username, password = "x-access-token", c.GitHubToken
+	case u.Host == gitLabHost() && c.GitLabToken != "":
+		username, password = "oauth2", c.GitLabToken
+	case u.Host == bitbucketCloneHost() && c.BitbucketToken != "":
+		username, password = "x-token-auth", c.BitbucketToken
+	case u.Host == bitbucketCloneHost() && c.BitbucketUsername != "":
+		username, password = c.BitbucketUsername, c.BitbucketAppPassword
+	case u.Host == azureDevOpsHost() && c.AzureDevOpsToken != "":
+		username, password = "pat", c.AzureDevOpsToken
+	default:
+		return nil
+	}
+
+	basic := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http.https://" + u.Host + "/.extraHeader",
+		"GIT_CONFIG_VALUE_0=Authorization: Basic " + basic,
+	}
+}
+
+// redact removes the credentials' secrets from s
+func (c Credentials) redact(s string) string {
+	secrets := []string{c.GitHubToken, c.GitLabToken, c.BitbucketToken, c.BitbucketAppPassword, c.AzureDevOpsToken}
+	if c.GitHubApp != nil {
+		secrets = append(secrets, c.GitHubApp.issuedTokens()...)
+	}
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, "***")
+		}
+	}
+	return s
+}
+
+// credentialsTransport authenticates each request with a fixed set of credentials
+type credentialsTransport struct {
+	base  http.RoundTripper
+	creds Credentials
+}
+
+func (t *credentialsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+
+	creds := t.creds
+	if creds.GitHubToken == "" && creds.GitHubApp != nil && req.URL.Host == creds.gitHub.apiHost() {
+		token, err := creds.GitHubApp.token(creds.gitHub, requestOwner(req))
+		if err != nil {
+			return nil, err
+		}
+		creds.GitHubToken = token
+	}
+	creds.authorize(req)
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// WithCredentials returns a copy of client that authenticates its GitHub, GitLab,
+// Bitbucket and Azure DevOps API requests with creds. The copy is safe for concurrent use and does not
+// affect other clients.
+func WithCredentials(client *http.Client, creds Credentials) *http.Client {
+	return withCredentials(client, creds.resolve())
+}
+
+// withCredentials wraps client with already resolved credentials
+func withCredentials(client *http.Client, creds Credentials) *http.Client {
+	if client == nil {
+		client = &http.Client{}
+	}
+	wrapped := *client
+	wrapped.Transport = &credentialsTransport{base: client.Transport, creds: creds}
+	return &wrapped
+}
+
+// newEnvClient returns a client authenticated from the environment, for the
+// package-level helpers that predate Credentials
+func newEnvClient(timeout time.Duration) *http.Client {
+	return WithCredentials(newHTTPClient(timeout), Credentials{})
+}
@@ -0,0 +1,265 @@
+package codegov
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// FieldChange is a changed value at a dotted path, e.g. "permissions.licenses.0.name".
+// Old is absent for added fields and New is absent for removed fields.
+type FieldChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// ReleaseChange lists the field-level changes to a release present in both inventories
+type ReleaseChange struct {
+	Name    string        `json:"name"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// InventoryDiff describes the differences between two code.gov inventories.
+// Releases are matched by name.
+type InventoryDiff struct {
+	Inventory  []FieldChange   `json:"inventory,omitempty"` // Changes outside the releases array
+	Added      []string        `json:"added,omitempty"`
+	Removed    []string        `json:"removed,omitempty"`
+	Tombstoned []string        `json:"tombstoned,omitempty"` // Became tombstones (see IsTombstone) rather than being removed
+	Changed    []ReleaseChange `json:"changed,omitempty"`
+}
+
+// Empty reports whether the inventories are identical
+func (d *InventoryDiff) Empty() bool {
+	return len(d.Inventory) == 0 && len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Tombstoned) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares two code.gov inventories
+func Diff(oldInventory, newInventory *CodeGovJSON) (*InventoryDiff, error) {
+	oldDoc, err := toGeneric(oldInventory)
+	if err != nil {
+		return nil, err
+	}
+	newDoc, err := toGeneric(newInventory)
+	if err != nil {
+		return nil, err
+	}
+
+	oldReleases := releasesByName(oldDoc)
+	newReleases := releasesByName(newDoc)
+	delete(oldDoc, "releases")
+	delete(newDoc, "releases")
+
+	d := &InventoryDiff{}
+	diffValues("", oldDoc, newDoc, &d.Inventory)
+
+	for name, oldRelease := range oldReleases {
+		newRelease, ok := newReleases[name]
+		if !ok {
+			d.Removed = append(d.Removed, name)
+			continue
+		}
+		if !isGenericTombstone(oldRelease) && isGenericTombstone(newRelease) {
+			d.Tombstoned = append(d.Tombstoned, name)
+			continue
+		}
+		var changes []FieldChange
+		diffValues("", oldRelease, newRelease, &changes)
+		if len(changes) > 0 {
+			d.Changed = append(d.Changed, ReleaseChange{Name: name, Changes: changes})
+		}
+	}
+	for name := range newReleases {
+		if _, ok := oldReleases[name]; !ok {
+			d.Added = append(d.Added, name)
+		}
+	}
+
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Tombstoned)
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].Name < d.Changed[j].Name })
+	return d, nil
+}
+
+// DiffFiles compares two code.gov JSON files
+func DiffFiles(oldPath, newPath string) (*InventoryDiff, error) {
+	oldInventory, err := ReadCodeGovJSONFile(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newInventory, err := ReadCodeGovJSONFile(newPath)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(oldInventory, newInventory)
+}
+
+// WriteText writes a human-readable summary of the diff
+func (d *InventoryDiff) WriteText(w io.Writer) error {
+	if d.Empty() {
+		_, err := fmt.Fprintln(w, "No changes")
+		return err
+	}
+
+	ew := &errWriter{w: w}
+	for _, c := range d.Inventory {
+		writeFieldChange(ew, "", c)
+	}
+	for _, name := range d.Added {
+		ew.printf("+ release %s\n", name)
+	}
+	for _, name := range d.Removed {
+		ew.printf("- release %s\n", name)
+	}
+	for _, name := range d.Tombstoned {
+		ew.printf("- release %s (kept as Archival tombstone)\n", name)
+	}
+	for _, r := range d.Changed {
+		ew.printf("~ release %s\n", r.Name)
+		for _, c := range r.Changes {
+			writeFieldChange(ew, "    ", c)
+		}
+	}
+	if len(d.Tombstoned) > 0 {
+		ew.printf("\n%d added, %d removed, %d tombstoned, %d changed\n", len(d.Added), len(d.Removed), len(d.Tombstoned), len(d.Changed))
+	} else {
+		ew.printf("\n%d added, %d removed, %d changed\n", len(d.Added), len(d.Removed), len(d.Changed))
+	}
+	return ew.err
+}
+
+func writeFieldChange(ew *errWriter, indent string, c FieldChange) {
+	switch {
+	case c.Old == nil:
+		ew.printf("%s+ %s: %s\n", indent, c.Path, formatDiffValue(c.New))
+	case c.New == nil:
+		ew.printf("%s- %s: %s\n", indent, c.Path, formatDiffValue(c.Old))
+	default:
+		ew.printf("%s~ %s: %s -> %s\n", indent, c.Path, formatDiffValue(c.Old), formatDiffValue(c.New))
+	}
+}
+
+// errWriter remembers the first write error so text output can be written without checks on every line
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, args...)
+	}
+}
+
+func formatDiffValue(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// diffValues appends the changes between two decoded JSON values. Objects are compared
+// key by key and arrays of objects element by element; other arrays and scalars are
+// compared as a whole.
+func diffValues(path string, oldValue, newValue interface{}, changes *[]FieldChange) {
+	switch o := oldValue.(type) {
+	case map[string]interface{}:
+		if n, ok := newValue.(map[string]interface{}); ok {
+			keys := make(map[string]bool, len(o)+len(n))
+			for k := range o {
+				keys[k] = true
+			}
+			for k := range n {
+				keys[k] = true
+			}
+			sorted := make([]string, 0, len(keys))
+			for k := range keys {
+				sorted = append(sorted, k)
+			}
+			sort.Strings(sorted)
+
+			for _, k := range sorted {
+				diffValues(diffPath(path, k), o[k], n[k], changes)
+			}
+			return
+		}
+
+	case []interface{}:
+		if n, ok := newValue.([]interface{}); ok && containsObjects(o) && containsObjects(n) {
+			for i := 0; i < len(o) || i < len(n); i++ {
+				var ov, nv interface{}
+				if i < len(o) {
+					ov = o[i]
+				}
+				if i < len(n) {
+					nv = n[i]
+				}
+				diffValues(diffPath(path, strconv.Itoa(i)), ov, nv, changes)
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(oldValue, newValue) {
+		*changes = append(*changes, FieldChange{Path: path, Old: oldValue, New: newValue})
+	}
+}
+
+func containsObjects(values []interface{}) bool {
+	for _, v := range values {
+		if _, ok := v.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return len(values) > 0
+}
+
+func diffPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// toGeneric round-trips an inventory through JSON so it can be compared field by field
+func toGeneric(codeGov *CodeGovJSON) (map[string]interface{}, error) {
+	data, err := json.Marshal(codeGov)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// isGenericTombstone is IsTombstone for a release decoded by toGeneric
+func isGenericTombstone(release interface{}) bool {
+	r, _ := release.(map[string]interface{})
+	if r["status"] != "Archival" {
+		return false
+	}
+	tags, _ := r["tags"].([]interface{})
+	for _, tag := range tags {
+		if tag == TombstoneTag {
+			return true
+		}
+	}
+	return false
+}
+
+func releasesByName(doc map[string]interface{}) map[string]interface{} {
+	releases, _ := doc["releases"].([]interface{})
+	byName := make(map[string]interface{}, len(releases))
+	for _, r := range releases {
+		if release, ok := r.(map[string]interface{}); ok {
+			name, _ := release["name"].(string)
+			byName[name] = release
+		}
+	}
+	return byName
+}
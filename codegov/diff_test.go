@@ -0,0 +1,61 @@
+package codegov
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	oldInventory := patchFixture()
+	newInventory := patchFixture()
+
+	newInventory.Agency = "OTHER"
+	newInventory.Releases = newInventory.Releases[1:] // remove alpha
+	newInventory.Releases[0].Permissions.Licenses[0].Name = "Apache-2.0"
+	newInventory.Releases[0].Tags = []string{"go", "cli"}
+	newInventory.Releases = append(newInventory.Releases, Release{Name: "delta"})
+
+	d, err := Diff(oldInventory, newInventory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.Inventory) != 1 || d.Inventory[0].Path != "agency" || d.Inventory[0].New != "OTHER" {
+		t.Errorf("unexpected inventory changes %+v", d.Inventory)
+	}
+	if strings.Join(d.Added, ",") != "delta" || strings.Join(d.Removed, ",") != "alpha" {
+		t.Errorf("unexpected added %v / removed %v", d.Added, d.Removed)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].Name != "beta" {
+		t.Fatalf("unexpected changed releases %+v", d.Changed)
+	}
+
+	paths := make([]string, 0, len(d.Changed[0].Changes))
+	for _, c := range d.Changed[0].Changes {
+		paths = append(paths, c.Path)
+	}
+	if strings.Join(paths, ",") != "permissions.licenses.0.name,tags" {
+		t.Errorf("unexpected field changes %v", paths)
+	}
+
+	var buf bytes.Buffer
+	if err := d.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"+ release delta", "- release alpha", `~ permissions.licenses.0.name: "MIT" -> "Apache-2.0"`, "1 added, 1 removed, 1 changed"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("text output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestDiffIdentical(t *testing.T) {
+	d, err := Diff(patchFixture(), patchFixture())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Empty() {
+		t.Errorf("expected no changes, got %+v", d)
+	}
+}
@@ -0,0 +1,260 @@
+package codegov
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StageDigests is the report stage of downloadURL artifacts whose digest could not be
+// taken when GenerateOptions.DownloadDigests is set
+const StageDigests = "digests"
+
+// Where GenerateOptions.DownloadDigests records digests
+const (
+	// DownloadDigestsSidecar writes them to DigestsFile(output) next to the inventory,
+	// leaving the inventory itself schema-valid
+	DownloadDigestsSidecar = "sidecar"
+	// DownloadDigestsInline sets each release's additionalInformation.downloadSHA256,
+	// which the 2.0.0 schema does not define
+	DownloadDigestsInline = "inline"
+)
+
+// Where a DownloadDigest came from
+const (
+	DigestSourceGitHubAsset = "github-asset" // The digest GitHub publishes for a release asset
+	DigestSourceDownload    = "download"     // Computed by downloading the artifact
+)
+
+// DownloadDigest is the SHA-256 of a release's downloadURL artifact
+type DownloadDigest struct {
+	Release     string `json:"release"`
+	DownloadURL string `json:"downloadURL"`
+	SHA256      string `json:"sha256,omitempty"` // Hex encoded; empty when Error is set
+	Source      string `json:"source,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// DigestsFile returns where GenerateFile writes the digests of an inventory at path
+func DigestsFile(path string) string {
+	return path + ".digests.json"
+}
+
+// ComputeDownloadDigests takes the SHA-256 of every release's downloadURL artifact,
+// ordered by release. Assets of GitHub releases use the digest GitHub publishes for
+// them when it has one; everything else is downloaded and hashed. URLs shared by
+// several releases are fetched once, and concurrency <= 0 uses
+// DefaultLinkCheckConcurrency. Failures are returned with Error set.
+func ComputeDownloadDigests(codeGov *CodeGovJSON, concurrency int) []DownloadDigest {
+	return computeDownloadDigests(newEnvClient(5*time.Minute), codeGov, concurrency)
+}
+
+func computeDownloadDigests(client *http.Client, codeGov *CodeGovJSON, concurrency int) []DownloadDigest {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, r := range codeGov.Releases {
+		if r.DownloadURL != "" && !seen[r.DownloadURL] {
+			seen[r.DownloadURL] = true
+			urls = append(urls, r.DownloadURL)
+		}
+	}
+
+	if concurrency <= 0 {
+		concurrency = DefaultLinkCheckConcurrency
+	}
+	if concurrency > len(urls) {
+		concurrency = len(urls)
+	}
+
+	var mu sync.Mutex
+	results := make(map[string]DownloadDigest, len(urls))
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range queue {
+				d := DownloadDigest{DownloadURL: u}
+				sum, source, err := downloadDigest(client, u)
+				if err != nil {
+					d.Error = err.Error()
+				} else {
+					d.SHA256, d.Source = sum, source
+				}
+				mu.Lock()
+				results[u] = d
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, u := range urls {
+		queue <- u
+	}
+	close(queue)
+	wg.Wait()
+
+	var digests []DownloadDigest
+	for _, r := range codeGov.Releases {
+		if r.DownloadURL == "" {
+			continue
+		}
+		d := results[r.DownloadURL]
+		d.Release = r.Name
+		digests = append(digests, d)
+	}
+	sort.SliceStable(digests, func(i, j int) bool {
+		return digests[i].Release < digests[j].Release
+	})
+	return digests
+}
+
+// downloadDigest returns the hex SHA-256 of the artifact at u and where it came from
+func downloadDigest(client *http.Client, u string) (string, string, error) {
+	if sum, ok := gitHubAssetDigest(client, u); ok {
+		return sum, DigestSourceGitHubAsset, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return "", "", err
+	}
+	setClientHeaders(req)
+
+	resp, err := doWithRetry(client, req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("%s returned %d", sanitizeURL(req.URL), resp.StatusCode)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), DigestSourceDownload, nil
+}
+
+// gitHubAssetDigest looks up the digest GitHub publishes for a release asset URL of
+// the form <web>/<owner>/<repo>/releases/download/<tag>/<name>. It reports false for
+// other URLs and for assets uploaded before GitHub recorded digests.
+func gitHubAssetDigest(client *http.Client, u string) (string, bool) {
+	api := gitHubAPIOf(client)
+	rest, ok := strings.CutPrefix(u, api.webURL()+"/")
+	if !ok {
+		return "", false
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) != 6 || parts[2] != "releases" || parts[3] != "download" {
+		return "", false
+	}
+	owner, repo, tag, name := parts[0], parts[1], parts[4], parts[5]
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s",
+		api.baseURI(), owner, repo, tag), nil)
+	if err != nil {
+		return "", false
+	}
+	setClientHeaders(req)
+
+	resp, err := doWithRetry(client, req)
+	if err != nil {
+		return "", false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", false
+	}
+
+	var release struct {
+		Assets []struct {
+			Name   string `json:"name"`
+			Digest string `json:"digest"` // "sha256:<hex>"
+		} `json:"assets"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&release) != nil {
+		return "", false
+	}
+	unescaped, err := url.PathUnescape(name)
+	if err != nil {
+		unescaped = name
+	}
+	for _, asset := range release.Assets {
+		if asset.Name == unescaped {
+			sum, ok := strings.CutPrefix(asset.Digest, "sha256:")
+			return sum, ok && sum != ""
+		}
+	}
+	return "", false
+}
+
+// applyDigests sets additionalInformation.downloadSHA256 on each release with a digest
+func applyDigests(codeGov *CodeGovJSON, digests []DownloadDigest) {
+	sums := make(map[string]string, len(digests))
+	for _, d := range digests {
+		if d.SHA256 != "" {
+			sums[d.Release] = d.SHA256
+		}
+	}
+	for i := range codeGov.Releases {
+		r := &codeGov.Releases[i]
+		if sum, ok := sums[r.Name]; ok {
+			if r.AdditionalInformation == nil {
+				r.AdditionalInformation = map[string]interface{}{}
+			}
+			r.AdditionalInformation["downloadSHA256"] = sum
+		}
+	}
+}
+
+// WriteDigestsFile writes digests as the JSON document GenerateFile puts in
+// DigestsFile, leaving out failed ones
+func WriteDigestsFile(path string, digests []DownloadDigest) error {
+	doc := struct {
+		Algorithm string           `json:"algorithm"`
+		Releases  []DownloadDigest `json:"releases"`
+	}{Algorithm: "sha256", Releases: []DownloadDigest{}}
+	for _, d := range digests {
+		if d.Error == "" {
+			doc.Releases = append(doc.Releases, d)
+		}
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// takeDigests records the digests of the inventory's downloads on the report, with
+// failures as warnings, and applies them inline when asked to
+func (g *generator) takeDigests(codeGov *CodeGovJSON) {
+	digests := computeDownloadDigests(g.client(5*time.Minute), codeGov, g.opts.LinkCheckConcurrency)
+	failed := 0
+	for _, d := range digests {
+		if d.Error != "" {
+			failed++
+			g.report.add(ReportIssue{Severity: SeverityWarning, Repo: d.Release, Stage: StageDigests, Message: d.Error})
+		}
+	}
+	g.report.Digests = digests
+	if failed > 0 {
+		g.logger.Printf("%d downloads could not be digested\n", failed)
+	}
+	if g.opts.DownloadDigests == DownloadDigestsInline {
+		applyDigests(codeGov, digests)
+	}
+}
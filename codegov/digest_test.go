@@ -0,0 +1,81 @@
+package codegov
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestComputeDownloadDigests(t *testing.T) {
+	archive := []byte("zip archive bytes")
+	sum := sha256.Sum256(archive)
+	var downloads atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/repos/nsa/tool/releases/tags/v1.0", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"assets":[{"name":"tool.tar.gz","digest":"sha256:abc123"},{"name":"old.tar.gz","digest":null}]}`))
+	})
+	mux.HandleFunc("/nsa/api/archive/main.zip", func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		w.Write(archive)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	SetGitHubConfig(GitHubConfig{BaseURI: srv.URL + "/api/v3"})
+	defer SetGitHubConfig(GitHubConfig{})
+
+	codeGov := &CodeGovJSON{Releases: []Release{
+		{Name: "tool", DownloadURL: srv.URL + "/nsa/tool/releases/download/v1.0/tool.tar.gz"},
+		{Name: "api", DownloadURL: srv.URL + "/nsa/api/archive/main.zip"},
+		{Name: "api-docs", DownloadURL: srv.URL + "/nsa/api/archive/main.zip"},
+		{Name: "gone", DownloadURL: srv.URL + "/nsa/gone/archive/main.zip"},
+		{Name: "no-download"},
+	}}
+	digests := computeDownloadDigests(srv.Client(), codeGov, 2)
+
+	if len(digests) != 4 {
+		t.Fatalf("got %d digests: %+v", len(digests), digests)
+	}
+	byRelease := map[string]DownloadDigest{}
+	for _, d := range digests {
+		byRelease[d.Release] = d
+	}
+	if d := byRelease["tool"]; d.SHA256 != "abc123" || d.Source != DigestSourceGitHubAsset {
+		t.Errorf("asset digest %+v", d)
+	}
+	for _, name := range []string{"api", "api-docs"} {
+		if d := byRelease[name]; d.SHA256 != hex.EncodeToString(sum[:]) || d.Source != DigestSourceDownload {
+			t.Errorf("%s digest %+v", name, d)
+		}
+	}
+	if downloads.Load() != 1 {
+		t.Errorf("shared download fetched %d times", downloads.Load())
+	}
+	if d := byRelease["gone"]; d.Error == "" || !strings.Contains(d.Error, "404") {
+		t.Errorf("expected a 404 error, got %+v", d)
+	}
+
+	path := filepath.Join(t.TempDir(), "code.json.digests.json")
+	if err := WriteDigestsFile(path, digests); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	var doc struct {
+		Algorithm string           `json:"algorithm"`
+		Releases  []DownloadDigest `json:"releases"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil || doc.Algorithm != "sha256" || len(doc.Releases) != 3 {
+		t.Errorf("unexpected digests file %s", data)
+	}
+
+	applyDigests(codeGov, digests)
+	if codeGov.Releases[1].AdditionalInformation["downloadSHA256"] != hex.EncodeToString(sum[:]) || codeGov.Releases[3].AdditionalInformation != nil {
+		t.Errorf("unexpected inline digests %+v", codeGov.Releases)
+	}
+}
@@ -0,0 +1,99 @@
+package codegov
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// EncodeOptions controls how inventories are encoded as JSON
+type EncodeOptions struct {
+	// Compact omits indentation, which makes large inventories about a third smaller
+	// and faster to encode; code.gov harvests either form
+	Compact bool
+}
+
+// bufferPool holds buffers for encoding inventories, which reach megabytes for
+// agencies with thousands of releases
+var bufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// EncodeCodeGovJSON writes an inventory to w as JSON followed by a newline. The
+// output matches json.MarshalIndent's, but is built in pooled buffers, so encoding
+// the same large inventory repeatedly allocates a fraction of the memory.
+func EncodeCodeGovJSON(w io.Writer, codeGov *CodeGovJSON, opts EncodeOptions) error {
+	compact := bufferPool.Get().(*bytes.Buffer)
+	compact.Reset()
+	defer bufferPool.Put(compact)
+
+	if err := json.NewEncoder(compact).Encode(codeGov); err != nil {
+		return err
+	}
+	if opts.Compact {
+		_, err := w.Write(compact.Bytes())
+		return err
+	}
+
+	indented := bufferPool.Get().(*bytes.Buffer)
+	indented.Reset()
+	defer bufferPool.Put(indented)
+
+	if err := json.Indent(indented, compact.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	_, err := w.Write(indented.Bytes())
+	return err
+}
+
+// MarshalCodeGovJSON encodes an inventory as EncodeCodeGovJSON does, returning the bytes
+func MarshalCodeGovJSON(codeGov *CodeGovJSON, opts EncodeOptions) ([]byte, error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufferPool.Put(buf)
+
+	if err := EncodeCodeGovJSON(buf, codeGov, opts); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// writeCodeGovJSON writes an indented code.gov inventory to path, as YAML when the
+// path ends in .yaml or .yml
+func writeCodeGovJSON(codeGov *CodeGovJSON, path string) error {
+	return writeCodeGovJSONWithOptions(codeGov, path, EncodeOptions{})
+}
+
+// writeCodeGovJSONWithOptions writes an inventory to path with a single write of the
+// encoded JSON; YAML ignores opts
+func writeCodeGovJSONWithOptions(codeGov *CodeGovJSON, path string, opts EncodeOptions) error {
+	if isYAMLPath(path) {
+		data, err := marshalYAMLInventory(codeGov)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, data, 0644)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := EncodeCodeGovJSON(file, codeGov, opts); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// marshalYAMLInventory encodes an inventory as YAML through its JSON encoding
+func marshalYAMLInventory(codeGov *CodeGovJSON) ([]byte, error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufferPool.Put(buf)
+
+	if err := EncodeCodeGovJSON(buf, codeGov, EncodeOptions{Compact: true}); err != nil {
+		return nil, err
+	}
+	return jsonToYAML(buf.Bytes())
+}
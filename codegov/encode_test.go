@@ -0,0 +1,109 @@
+package codegov
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// largeInventory builds an inventory the size of a large agency's
+func largeInventory(releases int) *CodeGovJSON {
+	codeGov := &CodeGovJSON{Version: "2.0.0", Agency: "TEST", MeasurementType: MeasurementType{Method: "projects"}}
+	for i := 0; i < releases; i++ {
+		name := fmt.Sprintf("release-%d", i)
+		codeGov.Releases = append(codeGov.Releases, Release{
+			Name:          name,
+			RepositoryURL: "https://github.com/test/" + name,
+			Description:   "A release of a large inventory, used to measure encoding",
+			Permissions:   Permissions{Licenses: []License{{Name: "MIT", URL: "https://github.com/test/" + name + "/blob/main/LICENSE"}}, UsageType: UsageTypeOpenSource},
+			LaborHours:    float64(i),
+			Tags:          []string{"go", "inventory", "test"},
+			Languages:     []string{"Go", "Shell"},
+			Contact:       Contact{Email: "code@agency.gov"},
+			Status:        "Production",
+			VCS:           "git",
+			Date:          DateInfo{Created: "2020-01-02", LastModified: "2024-05-06", MetadataLastUpdated: "2024-05-06"},
+		})
+	}
+	return codeGov
+}
+
+func TestEncodeCodeGovJSON(t *testing.T) {
+	codeGov := largeInventory(3)
+
+	want, _ := json.MarshalIndent(codeGov, "", "  ")
+	indented, err := MarshalCodeGovJSON(codeGov, EncodeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(indented, append(want, '\n')) {
+		t.Errorf("indented output differs from MarshalIndent:\n%s", indented)
+	}
+
+	compact, err := MarshalCodeGovJSON(codeGov, EncodeOptions{Compact: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(compact[:len(compact)-1], []byte("\n")) || len(compact) >= len(indented) {
+		t.Errorf("compact output is not compact:\n%s", compact)
+	}
+
+	// Generated files use the same encoding
+	path := filepath.Join(t.TempDir(), "code.json")
+	if err := writeCodeGovJSONWithOptions(codeGov, path, EncodeOptions{Compact: true}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, compact) {
+		t.Errorf("file differs from MarshalCodeGovJSON:\n%s", data)
+	}
+}
+
+// The encoding benchmarks use a 5,000-release inventory. Compare allocations with
+// go test -run '^$' -bench Encode -benchmem ./codegov
+const benchmarkReleases = 5000
+
+func BenchmarkEncodeMarshalIndent(b *testing.B) {
+	codeGov := largeInventory(benchmarkReleases)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, err := json.MarshalIndent(codeGov, "", "  ")
+		if err != nil {
+			b.Fatal(err)
+		}
+		io.Discard.Write(data)
+	}
+}
+
+func BenchmarkEncodeStream(b *testing.B) {
+	codeGov := largeInventory(benchmarkReleases)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := EncodeCodeGovJSON(io.Discard, codeGov, EncodeOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeStreamCompact(b *testing.B) {
+	codeGov := largeInventory(benchmarkReleases)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := EncodeCodeGovJSON(io.Discard, codeGov, EncodeOptions{Compact: true}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeMarshalPooled(b *testing.B) {
+	codeGov := largeInventory(benchmarkReleases)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := MarshalCodeGovJSON(codeGov, EncodeOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
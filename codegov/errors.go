@@ -0,0 +1,93 @@
+package codegov
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Sentinel errors for API failures, matched with errors.Is
+var (
+	ErrOrgNotFound  = errors.New("organization not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrRateLimited  = errors.New("rate limited")
+)
+
+// APIError is a non-success response from the GitHub or GitLab API, carrying the
+// message and documentation link from the error body
+type APIError struct {
+	StatusCode       int
+	URL              string
+	Message          string
+	DocumentationURL string
+	kind             error
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("API request failed with status %d", e.StatusCode)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.DocumentationURL != "" {
+		msg += " (see " + e.DocumentationURL + ")"
+	}
+	return msg
+}
+
+// Unwrap returns the matching sentinel error, if any
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+// Unwrap lets errors.Is(err, ErrRateLimited) match rate limit failures
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// newAPIError builds an APIError from a failed response, classifying it by status.
+// notFound is the sentinel for a 404, e.g. ErrOrgNotFound when listing an organization.
+func newAPIError(resp *http.Response, notFound error) *APIError {
+	// GitHub sends {"message", "documentation_url"}; GitLab sends {"message"} or {"error"}
+	var body struct {
+		Message          interface{} `json:"message"`
+		Error            string      `json:"error"`
+		DocumentationURL string      `json:"documentation_url"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	json.Unmarshal(data, &body)
+
+	e := &APIError{
+		StatusCode:       resp.StatusCode,
+		DocumentationURL: body.DocumentationURL,
+	}
+	if resp.Request != nil {
+		e.URL = sanitizeURL(resp.Request.URL)
+	}
+
+	switch m := body.Message.(type) {
+	case string:
+		e.Message = m
+	case nil:
+		e.Message = body.Error
+	default:
+		// GitLab validation errors are objects
+		encoded, _ := json.Marshal(m)
+		e.Message = string(encoded)
+	}
+	e.Message = redactToken(e.Message)
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		e.kind = ErrUnauthorized
+	case http.StatusForbidden:
+		e.kind = ErrForbidden
+	case http.StatusTooManyRequests:
+		e.kind = ErrRateLimited
+	case http.StatusNotFound:
+		e.kind = notFound
+	}
+	return e
+}
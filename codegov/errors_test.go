@@ -0,0 +1,70 @@
+package codegov
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGitHubAPIErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusNotFound, `{"message":"Not Found","documentation_url":"https://docs.github.com/rest/repos/repos#list-organization-repositories"}`, ErrOrgNotFound},
+		{http.StatusUnauthorized, `{"message":"Bad credentials","documentation_url":"https://docs.github.com/rest"}`, ErrUnauthorized},
+		{http.StatusForbidden, `{"message":"Resource protected by organization SAML enforcement."}`, ErrForbidden},
+		{http.StatusInternalServerError, `oops`, nil},
+	}
+
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+		SetGitHubConfig(GitHubConfig{BaseURI: srv.URL})
+
+		_, err := getGitHubRepositories(srv.Client(), "testorg")
+		srv.Close()
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+			t.Errorf("status %d: expected APIError, got %v", tt.status, err)
+			continue
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("status %d: expected %v, got %v", tt.status, tt.want, err)
+		}
+		if tt.want != nil && !strings.Contains(err.Error(), apiErr.Message) {
+			t.Errorf("status %d: message missing from %q", tt.status, err)
+		}
+	}
+	SetGitHubConfig(GitHubConfig{})
+
+	if !errors.Is(&RateLimitError{StatusCode: 403}, ErrRateLimited) {
+		t.Error("RateLimitError should match ErrRateLimited")
+	}
+}
+
+func TestGenerateFailsWhenNoOrganizationIsReachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"Not Found"}`))
+	}))
+	defer srv.Close()
+	SetGitHubConfig(GitHubConfig{BaseURI: srv.URL})
+	defer SetGitHubConfig(GitHubConfig{})
+
+	_, err := Generate(GenerateOptions{
+		Organizations: []string{"missing"},
+		Agency:        "TEST",
+		Contact:       Contact{Email: "code@test.gov"},
+		HTTPClient:    srv.Client(),
+	})
+	if !errors.Is(err, ErrOrgNotFound) || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected organization not found error, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package codegov
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// exportColumns are the release fields written by ExportCSV and ExportMarkdown
+var exportColumns = []string{"name", "description", "status", "usageType", "licenses", "languages", "repositoryURL", "homepageURL", "downloadURL"}
+
+// exportRow flattens a release into the export columns; list fields are joined with "; "
+func exportRow(release Release) []string {
+	var licenses []string
+	for _, license := range release.Permissions.Licenses {
+		if license.Name != "" {
+			licenses = append(licenses, license.Name)
+		} else if license.URL != "" {
+			licenses = append(licenses, license.URL)
+		}
+	}
+	return []string{
+		release.Name,
+		release.Description,
+		release.Status,
+		release.Permissions.UsageType,
+		strings.Join(licenses, "; "),
+		strings.Join(release.Languages, "; "),
+		release.RepositoryURL,
+		release.HomepageURL,
+		release.DownloadURL,
+	}
+}
+
+// ExportCSV writes one row per release, after a header row, for opening the inventory in a spreadsheet
+func ExportCSV(w io.Writer, codeGov *CodeGovJSON) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportColumns); err != nil {
+		return err
+	}
+	for _, release := range codeGov.Releases {
+		if err := cw.Write(exportRow(release)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportMarkdown writes the releases as a Markdown table for reports and wiki pages
+func ExportMarkdown(w io.Writer, codeGov *CodeGovJSON) error {
+	ew := &errWriter{w: w}
+	ew.printf("%s\n", markdownRow(exportColumns))
+	ew.printf("|%s\n", strings.Repeat(" --- |", len(exportColumns)))
+	for _, release := range codeGov.Releases {
+		ew.printf("%s\n", markdownRow(exportRow(release)))
+	}
+	return ew.err
+}
+
+// markdownCell escapes a value for a table cell, which cannot span lines or contain bare pipes
+var markdownCell = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+func markdownRow(cells []string) string {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = markdownCell.Replace(strings.TrimSpace(cell))
+	}
+	return fmt.Sprintf("| %s |", strings.Join(escaped, " | "))
+}
@@ -0,0 +1,48 @@
+package codegov
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestExportCSV(t *testing.T) {
+	codeGov := patchFixture()
+	codeGov.Releases[0].Description = "Multi-line,\n\"quoted\" description"
+	codeGov.Releases[0].Languages = []string{"Go", "Shell"}
+	codeGov.Releases[0].Permissions.Licenses = append(codeGov.Releases[0].Permissions.Licenses, License{URL: "https://example.gov/LICENSE"})
+
+	var out bytes.Buffer
+	if err := ExportCSV(&out, codeGov); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	if len(records) != 4 || records[0][0] != "name" || records[3][0] != "gamma" {
+		t.Fatalf("unexpected records %q", records)
+	}
+	alpha := records[1]
+	if alpha[1] != codeGov.Releases[0].Description || alpha[4] != "MIT; https://example.gov/LICENSE" || alpha[5] != "Go; Shell" {
+		t.Errorf("unexpected row %q", alpha)
+	}
+}
+
+func TestExportMarkdown(t *testing.T) {
+	codeGov := patchFixture()
+	codeGov.Releases[1].Description = "Pipes | and\nnewlines"
+
+	var out bytes.Buffer
+	if err := ExportMarkdown(&out, codeGov); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "| name | description |") || !strings.HasPrefix(lines[1], "| --- |") {
+		t.Fatalf("unexpected table:\n%s", out.String())
+	}
+	if !strings.Contains(lines[3], `| beta | Pipes \| and<br>newlines | `) {
+		t.Errorf("cell not escaped: %s", lines[3])
+	}
+}
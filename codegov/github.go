@@ -0,0 +1,158 @@
+package codegov
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// GitHubBaseURIEnv overrides the GitHub API base URI, e.g. for GitHub Enterprise Server
+const GitHubBaseURIEnv = "GITHUB_BASE_URI"
+
+const (
+	// GitHubUserPrefix selects a GitHub user account instead of an organization, e.g.
+	// "user:svc-builds". Only the account's public repositories are listed.
+	GitHubUserPrefix = "user:"
+	// GitHubAuthenticatedUser names the account the token belongs to, as "user:@me",
+	// listing the repositories it owns including private ones
+	GitHubAuthenticatedUser = "@me"
+)
+
+// GitHubConfig configures the GitHub backend
+type GitHubConfig struct {
+	// BaseURI is the REST API root, e.g. "https://github.example.gov/api/v3".
+	// Empty uses GITHUB_BASE_URI, falling back to GitHubBaseURI.
+	BaseURI string
+	// API selects GitHubAPIAuto, GitHubAPIREST or GitHubAPIGraphQL. Empty means GitHubAPIAuto.
+	API string
+}
+
+var (
+	githubConfigMu sync.RWMutex
+	githubConfig   GitHubConfig
+)
+
+// SetGitHubConfig sets the GitHub backend configuration of the package-level helpers,
+// and the defaults of runs whose GenerateOptions.GitHub leaves fields empty
+func SetGitHubConfig(config GitHubConfig) {
+	githubConfigMu.Lock()
+	defer githubConfigMu.Unlock()
+	githubConfig = config
+}
+
+// GetGitHubBaseURI returns the GitHub API base URI in effect
+func GetGitHubBaseURI() string {
+	githubConfigMu.RLock()
+	uri := githubConfig.BaseURI
+	githubConfigMu.RUnlock()
+
+	if uri == "" {
+		uri = os.Getenv(GitHubBaseURIEnv)
+	}
+	if uri == "" {
+		return GitHubBaseURI
+	}
+	return strings.TrimRight(uri, "/")
+}
+
+// GetGitHubWebURL returns the web root matching the API base URI.
+// github.com serves its API from api.github.com while GitHub Enterprise
+// Server serves it under /api/v3 on the web host.
+func GetGitHubWebURL() string {
+	return gitHubAPI("").webURL()
+}
+
+// gitHubAPI is the REST API root of a run's GitHub backend; empty uses GetGitHubBaseURI
+type gitHubAPI string
+
+// gitHubAPIOf returns the GitHub API of the run a client belongs to
+func gitHubAPIOf(client *http.Client) gitHubAPI {
+	if run := runOf(client); run != nil {
+		return run.gitHub
+	}
+	return ""
+}
+
+// baseURI returns the REST API root without a trailing slash
+func (a gitHubAPI) baseURI() string {
+	if a == "" {
+		return GetGitHubBaseURI()
+	}
+	return strings.TrimRight(string(a), "/")
+}
+
+// webURL returns the web root matching the API root
+func (a gitHubAPI) webURL() string {
+	base := a.baseURI()
+	if base == GitHubBaseURI {
+		return "https://github.com"
+	}
+	return strings.TrimSuffix(base, "/api/v3")
+}
+
+// graphQLURI returns the GraphQL endpoint matching the API root
+func (a gitHubAPI) graphQLURI() string {
+	base := a.baseURI()
+	if base == GitHubBaseURI {
+		return GitHubBaseURI + "/graphql"
+	}
+	return strings.TrimSuffix(base, "/v3") + "/graphql"
+}
+
+// webLink rewrites an API URL such as a release zipball to the equivalent web URL
+func (a gitHubAPI) webLink(apiURL string) string {
+	base := a.baseURI()
+	if !strings.HasPrefix(apiURL, base) {
+		return apiURL
+	}
+	return a.webURL() + strings.TrimPrefix(apiURL, base)
+}
+
+// host returns the host name of the web root, which git clones from
+func (a gitHubAPI) host() string {
+	u, err := url.Parse(a.webURL())
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// apiHost returns the host name of the API root
+func (a gitHubAPI) apiHost() string {
+	u, err := url.Parse(a.baseURI())
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// gitHubOwner is the GitHub account an organization entry names
+type gitHubOwner struct {
+	login string // Empty for the authenticated user
+	user  bool
+}
+
+// parseGitHubOwner splits an organization entry into the account it names:
+// "agency" is an organization, "user:alice" a user and "user:@me" the authenticated user
+func parseGitHubOwner(org string) gitHubOwner {
+	login, user := strings.CutPrefix(org, GitHubUserPrefix)
+	if user && login == GitHubAuthenticatedUser {
+		login = ""
+	}
+	return gitHubOwner{login: login, user: user}
+}
+
+// reposURI is the REST endpoint listing the repositories the account owns
+func (o gitHubOwner) reposURI(api gitHubAPI) string {
+	switch {
+	case !o.user:
+		return fmt.Sprintf("%s/orgs/%s/repos?per_page=100", api.baseURI(), strings.ToLower(o.login))
+	case o.login == "":
+		return api.baseURI() + "/user/repos?affiliation=owner&per_page=100"
+	default:
+		return fmt.Sprintf("%s/users/%s/repos?type=owner&per_page=100", api.baseURI(), url.PathEscape(o.login))
+	}
+}
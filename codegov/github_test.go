@@ -0,0 +1,98 @@
+package codegov
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestGitHubOwnerReposURI(t *testing.T) {
+	api := gitHubAPI("https://github.example.gov/api/v3/")
+	for org, want := range map[string]string{
+		"NSACodeGov":      "https://github.example.gov/api/v3/orgs/nsacodegov/repos?per_page=100",
+		"user:svc-builds": "https://github.example.gov/api/v3/users/svc-builds/repos?type=owner&per_page=100",
+		"user:@me":        "https://github.example.gov/api/v3/user/repos?affiliation=owner&per_page=100",
+	} {
+		if got := parseGitHubOwner(org).reposURI(api); got != want {
+			t.Errorf("reposURI(%q) = %q, want %q", org, got, want)
+		}
+	}
+}
+
+func TestGenerateGitHubPerRun(t *testing.T) {
+	newGitHub := func(repo string, auth *string) *httptest.Server {
+		var srv *httptest.Server
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*auth = r.Header.Get("Authorization")
+			switch r.URL.Path {
+			case "/api/v3/orgs/agency/repos":
+				api := srv.URL + "/api/v3/repos/agency/" + repo
+				fmt.Fprintf(w, `[{"name":%q,"html_url":"%s/agency/%s","languages_url":"%s/languages","releases_url":"%s/releases{/id}","default_branch":"main","created_at":"2024-01-02T00:00:00Z","pushed_at":"2024-03-04T00:00:00Z"}]`,
+					repo, srv.URL, repo, api, api)
+			case "/api/v3/repos/agency/" + repo + "/languages":
+				w.Write([]byte(`{"Go":1000}`))
+			case "/api/v3/repos/agency/" + repo + "/releases":
+				fmt.Fprintf(w, `[{"tag_name":"v1","zipball_url":"%s/api/v3/repos/agency/%s/zipball/v1"}]`, srv.URL, repo)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		return srv
+	}
+	var alphaAuth, betaAuth string
+	alpha, beta := newGitHub("alpha", &alphaAuth), newGitHub("beta", &betaAuth)
+	defer alpha.Close()
+	defer beta.Close()
+
+	run := func(srv *httptest.Server, token string) (*CodeGovJSON, error) {
+		codeGov, _, err := GenerateWithReport(GenerateOptions{
+			Organizations:    []string{"agency"},
+			Agency:           "Agency",
+			Contact:          Contact{Email: "code@agency.gov"},
+			Credentials:      Credentials{GitHubToken: token, NoEnvFallback: true},
+			GitHub:           GitHubConfig{BaseURI: srv.URL + "/api/v3", API: GitHubAPIREST},
+			SkipRepoMetadata: true,
+			HTTPClient:       srv.Client(),
+			Logger:           log.New(io.Discard, "", 0),
+		})
+		return codeGov, err
+	}
+
+	// Concurrent runs each reach their own GitHub, without SetGitHubConfig
+	var wg sync.WaitGroup
+	var alphaJSON, betaJSON *CodeGovJSON
+	var alphaErr, betaErr error
+	wg.Add(2)
+	go func() { defer wg.Done(); alphaJSON, alphaErr = run(alpha, "alpha-token") }()
+	go func() { defer wg.Done(); betaJSON, betaErr = run(beta, "beta-token") }()
+	wg.Wait()
+
+	for _, tt := range []struct {
+		codeGov *CodeGovJSON
+		err     error
+		name    string
+		srv     *httptest.Server
+		auth    string
+	}{
+		{alphaJSON, alphaErr, "alpha", alpha, alphaAuth},
+		{betaJSON, betaErr, "beta", beta, betaAuth},
+	} {
+		if tt.err != nil {
+			t.Fatalf("%s: %v", tt.name, tt.err)
+		}
+		if len(tt.codeGov.Releases) != 1 || tt.codeGov.Releases[0].Name != tt.name {
+			t.Fatalf("%s: releases = %+v", tt.name, tt.codeGov.Releases)
+		}
+		// The zipball is published as a web link of the run's GitHub
+		if want := tt.srv.URL + "/repos/agency/" + tt.name + "/zipball/v1"; tt.codeGov.Releases[0].DownloadURL != want {
+			t.Errorf("%s: downloadURL = %q, want %q", tt.name, tt.codeGov.Releases[0].DownloadURL, want)
+		}
+		if tt.auth != "token "+tt.name+"-token" {
+			t.Errorf("%s: Authorization = %q, want the run's own token", tt.name, tt.auth)
+		}
+	}
+}
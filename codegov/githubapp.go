@@ -0,0 +1,279 @@
+package codegov
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variables read by GitHubAppFromEnv
+const (
+	GitHubAppIDEnv             = "GITHUB_APP_ID"
+	GitHubAppPrivateKeyEnv     = "GITHUB_APP_PRIVATE_KEY" // PEM contents or a path to the PEM file
+	GitHubAppInstallationIDEnv = "GITHUB_APP_INSTALLATION_ID"
+)
+
+// gitHubAppTokenRefresh is how long before expiry an installation token is replaced
+const gitHubAppTokenRefresh = 5 * time.Minute
+
+// GitHubApp authenticates as a GitHub App installation. Installation tokens are
+// exchanged on first use and refreshed shortly before they expire (after an hour).
+// Each organization's installation is looked up on demand unless InstallationID
+// pins one. A GitHubApp is safe for concurrent use.
+type GitHubApp struct {
+	AppID          string // App ID or client ID, used as the JWT issuer
+	InstallationID int64  // Optional; found per organization when zero
+	HTTPClient     *http.Client
+
+	key *rsa.PrivateKey
+	now func() time.Time
+
+	mu            sync.Mutex
+	installations map[string]int64                   // Organization (lower case) to installation ID
+	tokens        map[int64]*gitHubInstallationToken // Installation ID to current token
+	issued        []string                           // Every token handed out, for redaction
+}
+
+// gitHubInstallationToken is a short-lived installation access token
+type gitHubInstallationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewGitHubApp creates an app authenticator from the app ID and its PEM-encoded
+// private key (PKCS#1 as downloaded from GitHub, or PKCS#8)
+func NewGitHubApp(appID string, privateKeyPEM []byte) (*GitHubApp, error) {
+	if appID == "" {
+		return nil, fmt.Errorf("GitHub App ID is required")
+	}
+
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("GitHub App private key is not PEM encoded")
+	}
+	var key *rsa.PrivateKey
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		key = k
+	} else if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := k.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("GitHub App private key is not an RSA key")
+		}
+		key = rsaKey
+	} else {
+		return nil, fmt.Errorf("failed to parse GitHub App private key: %w", err)
+	}
+
+	return &GitHubApp{
+		AppID:         appID,
+		key:           key,
+		now:           time.Now,
+		installations: make(map[string]int64),
+		tokens:        make(map[int64]*gitHubInstallationToken),
+	}, nil
+}
+
+// GitHubAppFromEnv creates an app authenticator from GITHUB_APP_ID,
+// GITHUB_APP_PRIVATE_KEY and the optional GITHUB_APP_INSTALLATION_ID. It returns
+// nil without error when GITHUB_APP_ID is unset.
+func GitHubAppFromEnv() (*GitHubApp, error) {
+	appID := os.Getenv(GitHubAppIDEnv)
+	if appID == "" {
+		return nil, nil
+	}
+
+	key := os.Getenv(GitHubAppPrivateKeyEnv)
+	if key == "" {
+		return nil, fmt.Errorf("%s is set but %s is not", GitHubAppIDEnv, GitHubAppPrivateKeyEnv)
+	}
+	data := []byte(key)
+	if !strings.Contains(key, "-----BEGIN") {
+		var err error
+		if data, err = os.ReadFile(key); err != nil {
+			return nil, fmt.Errorf("failed to read GitHub App private key: %w", err)
+		}
+	}
+
+	app, err := NewGitHubApp(appID, data)
+	if err != nil {
+		return nil, err
+	}
+	if v := os.Getenv(GitHubAppInstallationIDEnv); v != "" {
+		if app.InstallationID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid %s: %q", GitHubAppInstallationIDEnv, v)
+		}
+	}
+	return app, nil
+}
+
+// JWT returns a short-lived JSON Web Token authenticating as the app itself
+func (a *GitHubApp) JWT() (string, error) {
+	now := a.now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(), // Allow for clock drift
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.AppID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Token returns an installation token for the organization's installation, or for
+// the pinned or only installation when org is empty
+func (a *GitHubApp) Token(org string) (string, error) {
+	return a.token("", org)
+}
+
+// token is Token for the app registered on api's GitHub
+func (a *GitHubApp) token(api gitHubAPI, org string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	id, err := a.installation(api, org)
+	if err != nil {
+		return "", err
+	}
+	if t := a.tokens[id]; t != nil && a.now().Add(gitHubAppTokenRefresh).Before(t.ExpiresAt) {
+		return t.Token, nil
+	}
+
+	var t gitHubInstallationToken
+	if err := a.call(api, http.MethodPost, fmt.Sprintf("/app/installations/%d/access_tokens", id), &t); err != nil {
+		return "", fmt.Errorf("failed to create installation token: %w", err)
+	}
+	a.tokens[id] = &t
+	a.issued = append(a.issued, t.Token)
+	return t.Token, nil
+}
+
+// installation resolves the installation ID for an organization; callers must hold a.mu
+func (a *GitHubApp) installation(api gitHubAPI, org string) (int64, error) {
+	if a.InstallationID != 0 {
+		return a.InstallationID, nil
+	}
+
+	key := strings.ToLower(org)
+	if id, ok := a.installations[key]; ok {
+		return id, nil
+	}
+
+	var id int64
+	if org != "" {
+		var inst struct {
+			ID int64 `json:"id"`
+		}
+		err := a.call(api, http.MethodGet, "/orgs/"+url.PathEscape(org)+"/installation", &inst)
+		if apiErr, ok := err.(*APIError); ok && apiErr.StatusCode == http.StatusNotFound {
+			// Apps can also be installed on user accounts
+			err = a.call(api, http.MethodGet, "/users/"+url.PathEscape(org)+"/installation", &inst)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("GitHub App is not installed on %s: %w", org, err)
+		}
+		id = inst.ID
+	} else {
+		var insts []struct {
+			ID int64 `json:"id"`
+		}
+		if err := a.call(api, http.MethodGet, "/app/installations", &insts); err != nil {
+			return 0, fmt.Errorf("failed to list GitHub App installations: %w", err)
+		}
+		if len(insts) != 1 {
+			return 0, fmt.Errorf("GitHub App has %d installations; set the installation ID", len(insts))
+		}
+		id = insts[0].ID
+	}
+
+	a.installations[key] = id
+	return id, nil
+}
+
+// call makes an API request to api's GitHub authenticated with the app's JWT
+func (a *GitHubApp) call(api gitHubAPI, method, path string, v interface{}) error {
+	jwt, err := a.JWT()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, api.baseURI()+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	setClientHeaders(req)
+
+	client := a.HTTPClient
+	if client == nil {
+		client = newHTTPClient(30 * time.Second)
+	}
+	resp, err := doAPIRequest(client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp, nil)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// issuedTokens returns every installation token handed out so far
+func (a *GitHubApp) issuedTokens() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.issued...)
+}
+
+// requestOwner returns the organization a GitHub API request concerns: the owner in
+// REST paths such as /orgs/{org}/repos and /repos/{owner}/{repo}, or the "org"
+// variable of a GraphQL query. It returns "" when the request names none.
+func requestOwner(req *http.Request) string {
+	path := strings.TrimPrefix(req.URL.Path, "/api/v3")
+	for _, prefix := range []string{"/orgs/", "/repos/", "/users/"} {
+		if rest, ok := strings.CutPrefix(path, prefix); ok {
+			owner, _, _ := strings.Cut(rest, "/")
+			return owner
+		}
+	}
+
+	if strings.HasSuffix(path, "/graphql") && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return ""
+		}
+		defer body.Close()
+		var query struct {
+			Variables struct {
+				Org string `json:"org"`
+			} `json:"variables"`
+		}
+		json.NewDecoder(body).Decode(&query)
+		return query.Variables.Org
+	}
+	return ""
+}
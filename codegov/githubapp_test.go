@@ -0,0 +1,155 @@
+package codegov
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// verifyJWT checks an RS256 token signed by key and returns its claims
+func verifyJWT(t *testing.T, key *rsa.PublicKey, token string) map[string]interface{} {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed JWT %q", token)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("JWT signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		t.Fatalf("JWT signature does not verify: %v", err)
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims map[string]interface{}
+	json.Unmarshal(payload, &claims)
+	return claims
+}
+
+func TestGitHubAppInstallationTokens(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	var exchanges int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/orgs/testorg/installation" || strings.HasPrefix(r.URL.Path, "/app/"):
+			claims := verifyJWT(t, &key.PublicKey, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+			if claims["iss"] != "12345" {
+				t.Errorf("JWT issuer = %v, want 12345", claims["iss"])
+			}
+			if r.URL.Path == "/orgs/testorg/installation" {
+				w.Write([]byte(`{"id":42}`))
+				return
+			}
+			if r.Method != http.MethodPost || r.URL.Path != "/app/installations/42/access_tokens" {
+				t.Errorf("unexpected app request %s %s", r.Method, r.URL.Path)
+				http.NotFound(w, r)
+				return
+			}
+			exchanges++
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"token":      fmt.Sprintf("ghs_installation%d", exchanges),
+				"expires_at": now.Add(time.Hour),
+			})
+		case r.URL.Path == "/orgs/testorg/repos":
+			if want := fmt.Sprintf("token ghs_installation%d", exchanges); r.Header.Get("Authorization") != want {
+				t.Errorf("Authorization = %q, want %q", r.Header.Get("Authorization"), want)
+			}
+			w.Write([]byte(`[]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	SetGitHubConfig(GitHubConfig{BaseURI: srv.URL})
+	defer SetGitHubConfig(GitHubConfig{})
+
+	app, err := NewGitHubApp("12345", keyPEM)
+	if err != nil {
+		t.Fatalf("NewGitHubApp: %v", err)
+	}
+	app.now = func() time.Time { return now }
+	client := WithCredentials(srv.Client(), Credentials{GitHubApp: app, NoEnvFallback: true})
+
+	get := func() {
+		t.Helper()
+		resp, err := client.Get(srv.URL + "/orgs/testorg/repos")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	get()
+	get()
+	if exchanges != 1 {
+		t.Errorf("exchanged %d tokens, want 1 reused token", exchanges)
+	}
+
+	// Within the refresh margin of expiry the token is replaced
+	now = now.Add(56 * time.Minute)
+	get()
+	if exchanges != 2 {
+		t.Errorf("exchanged %d tokens, want a refresh near expiry", exchanges)
+	}
+
+	creds := Credentials{GitHubApp: app}
+	if got := creds.redact("clone failed for ghs_installation1 and ghs_installation2"); strings.Contains(got, "ghs_") {
+		t.Errorf("issued tokens not redacted: %q", got)
+	}
+}
+
+func TestNewGitHubAppRejectsBadKeys(t *testing.T) {
+	if _, err := NewGitHubApp("1", []byte("not a key")); err == nil {
+		t.Error("expected error for a non-PEM key")
+	}
+	if _, err := NewGitHubApp("", nil); err == nil {
+		t.Error("expected error without an app ID")
+	}
+}
+
+func TestRequestOwner(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://api.github.com/orgs/NSACodeGov/repos", "NSACodeGov"},
+		{"https://api.github.com/repos/NSACodeGov/ghidra/languages", "NSACodeGov"},
+		{"https://github.example.gov/api/v3/repos/team/tool/license", "team"},
+		{"https://api.github.com/rate_limit", ""},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+		if got := requestOwner(req); got != tt.want {
+			t.Errorf("requestOwner(%s) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "https://api.github.com/graphql",
+		strings.NewReader(`{"query":"...","variables":{"org":"NSACodeGov","first":50}}`))
+	if got := requestOwner(req); got != "NSACodeGov" {
+		t.Errorf("requestOwner(graphql) = %q, want NSACodeGov", got)
+	}
+}
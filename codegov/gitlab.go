@@ -0,0 +1,379 @@
+package codegov
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// GitLabOrgPrefix selects the GitLab backend for an organization, e.g. "gitlab:mygroup"
+	GitLabOrgPrefix = "gitlab:"
+
+	GitLabBaseURIEnv = "GITLAB_BASE_URI"
+	GitLabTokenEnv   = "GITLAB_TOKEN"
+
+	defaultGitLabBaseURI = "https://gitlab.com/api/v4"
+)
+
+// gitLabSPDX maps GitLab license keys to SPDX identifiers where they differ by more than case
+var gitLabSPDX = map[string]string{
+	"mit":          "MIT",
+	"apache-2.0":   "Apache-2.0",
+	"bsd-2-clause": "BSD-2-Clause",
+	"bsd-3-clause": "BSD-3-Clause",
+	"gpl-2.0":      "GPL-2.0",
+	"gpl-3.0":      "GPL-3.0",
+	"lgpl-2.1":     "LGPL-2.1",
+	"lgpl-3.0":     "LGPL-3.0",
+	"agpl-3.0":     "AGPL-3.0",
+	"mpl-2.0":      "MPL-2.0",
+	"epl-2.0":      "EPL-2.0",
+	"unlicense":    "Unlicense",
+	"cc0-1.0":      "CC0-1.0",
+}
+
+// GetGitLabBaseURI returns the GitLab API base URI, honouring GITLAB_BASE_URI for self-hosted instances
+func GetGitLabBaseURI() string {
+	if uri := os.Getenv(GitLabBaseURIEnv); uri != "" {
+		return strings.TrimRight(uri, "/")
+	}
+	return defaultGitLabBaseURI
+}
+
+// GetGitLabToken retrieves the GitLab access token from environment variable
+func GetGitLabToken() string {
+	return os.Getenv(GitLabTokenEnv)
+}
+
+// newGitLabRequest creates a GitLab API request; the client's Credentials authenticate it
+func newGitLabRequest(uri string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	setClientHeaders(req)
+
+	return req, nil
+}
+
+// getGitLabJSON fetches a GitLab API resource and decodes it into v.
+// It returns the response headers so callers can follow pagination.
+func getGitLabJSON(client *http.Client, uri string, v interface{}) (http.Header, error) {
+	req, err := newGitLabRequest(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := doAPIRequest(client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, nil)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return nil, err
+	}
+
+	return resp.Header, nil
+}
+
+// GetGitLabProjects fetches all projects in a GitLab group, including subgroups
+func GetGitLabProjects(group string) ([]GitLabProject, error) {
+	return getGitLabProjects(newEnvClient(30 * time.Second), group)
+}
+
+func getGitLabProjects(client *http.Client, group string) ([]GitLabProject, error) {
+	uri := fmt.Sprintf("%s/groups/%s/projects?include_subgroups=true&license=true&per_page=100",
+		GetGitLabBaseURI(), url.PathEscape(group))
+
+	var allProjects []GitLabProject
+	page := "1"
+
+	for page != "" {
+		var projects []GitLabProject
+		header, err := getGitLabJSON(client, fmt.Sprintf("%s&page=%s", uri, page), &projects)
+		if err != nil {
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+				apiErr.kind = ErrOrgNotFound
+			}
+			return nil, err
+		}
+
+		allProjects = append(allProjects, projects...)
+		defaultMetrics.addReposFetched("gitlab", len(projects))
+		page = header.Get("X-Next-Page")
+	}
+
+	return allProjects, nil
+}
+
+// GetGitLabProjectLanguages returns the languages detected for a GitLab project, largest share first
+func GetGitLabProjectLanguages(projectID int) ([]string, error) {
+	return getGitLabProjectLanguages(newEnvClient(10 * time.Second), strconv.Itoa(projectID))
+}
+
+// getGitLabProjectLanguages takes the project's ID or URL-encoded path, as every
+// project resource of the API does
+func getGitLabProjectLanguages(client *http.Client, projectID string) ([]string, error) {
+	var languageStats map[string]float64
+	uri := fmt.Sprintf("%s/projects/%s/languages", GetGitLabBaseURI(), projectID)
+	if _, err := getGitLabJSON(client, uri, &languageStats); err != nil {
+		return []string{}, nil
+	}
+
+	languages := make([]string, 0, len(languageStats))
+	for lang := range languageStats {
+		languages = append(languages, lang)
+	}
+	// GitLab reports each language's percentage of the repository's bytes
+	sort.Slice(languages, func(i, j int) bool {
+		if languageStats[languages[i]] != languageStats[languages[j]] {
+			return languageStats[languages[i]] > languageStats[languages[j]]
+		}
+		return languages[i] < languages[j]
+	})
+
+	return languages, nil
+}
+
+// GetGitLabProjectLicense returns license information for a GitLab project
+func GetGitLabProjectLicense(project GitLabProject) *License {
+	return getGitLabProjectLicense(newEnvClient(10 * time.Second), project)
+}
+
+func getGitLabProjectLicense(client *http.Client, project GitLabProject) *License {
+	license := &License{}
+
+	if project.License != nil && project.License.Key != "" {
+		license.Name = gitLabLicenseSPDX(project.License.Key)
+		license.URL = project.LicenseURL
+	}
+
+	if license.URL == "" {
+		license.URL = getGitLabProjectFileURL(client, project.WebURL, project.DefaultBranch, "LICENSE")
+	}
+
+	return license
+}
+
+// gitLabLicenseSPDX converts a GitLab license key to its SPDX identifier
+func gitLabLicenseSPDX(key string) string {
+	if spdx, ok := gitLabSPDX[strings.ToLower(key)]; ok {
+		return spdx
+	}
+	return strings.ToUpper(key)
+}
+
+// GetGitLabProjectFileURL finds the URL of a well-known file such as LICENSE or DISCLAIMER
+func GetGitLabProjectFileURL(webURL, branch, name string) string {
+	return getGitLabProjectFileURL(newEnvClient(10 * time.Second), webURL, branch, name)
+}
+
+func getGitLabProjectFileURL(client *http.Client, webURL, branch, name string) string {
+	urls := []string{
+		fmt.Sprintf("%s/-/blob/%s/%s", webURL, branch, name),
+		fmt.Sprintf("%s/-/blob/%s/%s.md", webURL, branch, name),
+		fmt.Sprintf("%s/-/blob/%s/%s.txt", webURL, branch, name),
+	}
+
+	for _, urlStr := range urls {
+		if probeURL(client, urlStr) {
+			return urlStr
+		}
+	}
+
+	return ""
+}
+
+// GetGitLabProjectReleaseURL finds the zip download of the latest published release
+func GetGitLabProjectReleaseURL(projectID int) (string, error) {
+	downloadURL, _, err := getGitLabProjectRelease(newEnvClient(10*time.Second), strconv.Itoa(projectID))
+	return downloadURL, err
+}
+
+// getGitLabProjectRelease returns the zip download and tag of the latest published release
+func getGitLabProjectRelease(client *http.Client, projectID string) (string, string, error) {
+	var releases []GitLabRelease
+	uri := fmt.Sprintf("%s/projects/%s/releases", GetGitLabBaseURI(), projectID)
+	if _, err := getGitLabJSON(client, uri, &releases); err != nil {
+		return "", "", nil
+	}
+
+	for _, release := range releases {
+		if release.UpcomingRelease {
+			continue
+		}
+		for _, source := range release.Assets.Sources {
+			if source.Format == "zip" {
+				return source.URL, release.TagName, nil
+			}
+		}
+	}
+
+	return "", "", nil
+}
+
+// getGitLabProjectTags lists the names of a project's tags, most recently updated first
+func getGitLabProjectTags(client *http.Client, projectID string) ([]string, error) {
+	var tags []struct {
+		Name string `json:"name"`
+	}
+	uri := fmt.Sprintf("%s/projects/%s/repository/tags?order_by=updated&per_page=100", GetGitLabBaseURI(), projectID)
+	if _, err := getGitLabJSON(client, uri, &tags); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names, nil
+}
+
+// getGitLabBranchSHA returns the commit SHA at the head of a project's branch
+func getGitLabBranchSHA(client *http.Client, projectID, branch string) (string, error) {
+	var b struct {
+		Commit struct {
+			ID string `json:"id"`
+		} `json:"commit"`
+	}
+	uri := fmt.Sprintf("%s/projects/%s/repository/branches/%s", GetGitLabBaseURI(), projectID, url.PathEscape(branch))
+	if _, err := getGitLabJSON(client, uri, &b); err != nil {
+		return "", err
+	}
+	return b.Commit.ID, nil
+}
+
+// gitLabProvider inventories GitLab groups and their subgroups
+type gitLabProvider struct{}
+
+// gitLabListing is what the GitLab provider keeps of a listed project
+type gitLabListing struct {
+	project GitLabProject
+	tree    *gitLabTree
+}
+
+// listing returns the listing data of a project, setting the tree's client on first use
+func (gitLabProvider) listing(client *http.Client, repo ProviderRepository) *gitLabListing {
+	l := repo.details.(*gitLabListing)
+	if l.tree.client == nil {
+		l.tree.client = client
+	}
+	return l
+}
+
+// ListRepos lists the projects of a group, including its subgroups
+func (gitLabProvider) ListRepos(client *http.Client, owner string) ([]ProviderRepository, error) {
+	projects, err := getGitLabProjects(client, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make([]ProviderRepository, 0, len(projects))
+	for _, project := range projects {
+		// Older GitLab versions only report tag_list
+		topics := project.Topics
+		if len(topics) == 0 {
+			topics = project.TagList
+		}
+		repos = append(repos, ProviderRepository{
+			ID:            project.PathWithNamespace,
+			Name:          project.Name,
+			Description:   project.Description,
+			WebURL:        project.WebURL,
+			CloneURL:      project.HTTPURLToRepo,
+			DefaultBranch: project.DefaultBranch,
+			Private:       project.Visibility != "public",
+			Fork:          project.ForkedFromProject != nil,
+			Archived:      project.Archived,
+			Topics:        topics,
+			Created:       project.CreatedAt,
+			Updated:       project.LastActivityAt,
+			// Projects in subgroups are published under their subgroup
+			Owner: strings.TrimSuffix(project.PathWithNamespace, "/"+project.Path),
+			details: &gitLabListing{
+				project: project,
+				tree:    &gitLabTree{projectID: strconv.Itoa(project.ID), branch: project.DefaultBranch},
+			},
+		})
+	}
+	return repos, nil
+}
+
+// Languages returns the languages GitLab detected, largest share first
+func (p gitLabProvider) Languages(client *http.Client, repo ProviderRepository) ([]string, error) {
+	return getGitLabProjectLanguages(client, p.listing(client, repo).tree.projectID)
+}
+
+// License returns the license GitLab detected, or the URL of a LICENSE file it could not identify
+func (p gitLabProvider) License(client *http.Client, repo ProviderRepository) (*License, error) {
+	return getGitLabProjectLicense(client, p.listing(client, repo).project), nil
+}
+
+// Releases returns the zip source archive of the latest published release, falling back to the default branch
+func (p gitLabProvider) Releases(client *http.Client, repo ProviderRepository) (string, error) {
+	downloadURL, _, err := p.LatestRelease(client, repo)
+	return downloadURL, err
+}
+
+// LatestRelease implements ProviderReleaser
+func (p gitLabProvider) LatestRelease(client *http.Client, repo ProviderRepository) (string, string, error) {
+	project := p.listing(client, repo).project
+	downloadURL, tag, err := getGitLabProjectRelease(client, strconv.Itoa(project.ID))
+	if downloadURL == "" {
+		downloadURL = fmt.Sprintf("%s/-/archive/%s/%s-%s.zip", project.WebURL, project.DefaultBranch, project.Path, project.DefaultBranch)
+	}
+	return downloadURL, tag, err
+}
+
+// FileURL looks name, name.md and name.txt up in the listed tree, or probes for them
+// when the tree has not been listed
+func (p gitLabProvider) FileURL(client *http.Client, repo ProviderRepository, name string) string {
+	l := p.listing(client, repo)
+	if files := l.tree.files; files != nil {
+		for _, candidate := range []string{name, name + ".md", name + ".txt"} {
+			if files[candidate] {
+				return fmt.Sprintf("%s/-/blob/%s/%s", l.project.WebURL, l.project.DefaultBranch, candidate)
+			}
+		}
+		return ""
+	}
+	return getGitLabProjectFileURL(client, l.project.WebURL, l.project.DefaultBranch, name)
+}
+
+// ListRoot implements ProviderRootLister
+func (p gitLabProvider) ListRoot(client *http.Client, repo ProviderRepository) ([]string, error) {
+	return p.listing(client, repo).tree.list()
+}
+
+// ReadFile implements ProviderFileReader
+func (p gitLabProvider) ReadFile(client *http.Client, repo ProviderRepository, name string) ([]byte, error) {
+	return p.listing(client, repo).tree.read(name)
+}
+
+// Tags implements ProviderVersioner
+func (p gitLabProvider) Tags(client *http.Client, repo ProviderRepository) ([]string, error) {
+	return getGitLabProjectTags(client, p.listing(client, repo).tree.projectID)
+}
+
+// BranchSHA implements ProviderVersioner
+func (p gitLabProvider) BranchSHA(client *http.Client, repo ProviderRepository) (string, error) {
+	if repo.DefaultBranch == "" {
+		return "", nil // An empty project has no default branch
+	}
+	return getGitLabBranchSHA(client, p.listing(client, repo).tree.projectID, repo.DefaultBranch)
+}
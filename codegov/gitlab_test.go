@@ -0,0 +1,142 @@
+package codegov
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGitLabGenerate(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "glpat-test" {
+			t.Errorf("missing token on %s", r.URL)
+		}
+		project := func(id int, path, visibility string, extra string) string {
+			return fmt.Sprintf(`{"id": %d, "name": %q, "path": %q, "path_with_namespace": "group/%s", "description": "The %s project",
+				"web_url": "%s/group/%s", "visibility": %q, "default_branch": "main",
+				"created_at": "2023-01-02T00:00:00Z", "last_activity_at": "2024-05-06T00:00:00Z"%s}`,
+				id, path, path, path, path, srv.URL, path, visibility, extra)
+		}
+		switch r.URL.Path {
+		case "/api/v4/groups/group/projects":
+			if r.URL.Query().Get("include_subgroups") != "true" {
+				t.Errorf("subgroups not requested: %s", r.URL.RawQuery)
+			}
+			if r.URL.Query().Get("page") == "1" {
+				w.Header().Set("X-Next-Page", "2")
+				fmt.Fprintf(w, "[%s, %s]",
+					project(1, "widget", "public", `, "topics": ["tools"], "license": {"key": "apache-2.0"}, "license_url": "https://example.gov/LICENSE"`),
+					project(2, "secret", "private", ""))
+				return
+			}
+			fmt.Fprintf(w, "[%s, %s, %s]",
+				project(3, "forked", "public", `, "forked_from_project": {"id": 9}`),
+				project(4, "old", "public", `, "archived": true, "tag_list": ["legacy"]`),
+				project(5, "skip-me", "public", ""))
+		case "/api/v4/projects/1/languages":
+			fmt.Fprint(w, `{"Go": 20.5, "TypeScript": 70.1, "Shell": 9.4}`)
+		case "/api/v4/projects/1/releases":
+			fmt.Fprint(w, `[{"tag_name": "v2.0", "upcoming_release": true, "assets": {"sources": [{"format": "zip", "url": "https://example.gov/v2.0.zip"}]}},
+				{"tag_name": "v1.4", "assets": {"sources": [{"format": "tar.gz", "url": "https://example.gov/v1.4.tar.gz"}, {"format": "zip", "url": "https://example.gov/v1.4.zip"}]}}]`)
+		case "/api/v4/projects/4/repository/tags":
+			fmt.Fprint(w, `[]`)
+		case "/api/v4/projects/4/repository/branches/main":
+			fmt.Fprint(w, `{"commit": {"id": "0123456789abcdef0123456789abcdef01234567"}}`)
+		case "/api/v4/projects/1/repository/tree":
+			if r.URL.Query().Get("ref") != "main" {
+				t.Errorf("unexpected tree query %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `[{"name": "docs", "type": "tree"}, {"name": "DISCLAIMER.md", "type": "blob"}, {"name": "LICENSE", "type": "blob"},
+				{"name": "LICENSE-MIT", "type": "blob"}, {"name": "main.go", "type": "blob"}]`)
+		case "/api/v4/projects/1/repository/files/LICENSE-MIT/raw":
+			fmt.Fprint(w, "SPDX-License-Identifier: MIT\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv(GitLabBaseURIEnv, srv.URL+"/api/v4")
+	t.Setenv(GitLabTokenEnv, "glpat-test")
+	SetGitLabMaxRequestsPerSecond(0)
+	defer SetGitLabMaxRequestsPerSecond(DefaultGitLabMaxRequestsPerSecond)
+
+	codeGov, err := Generate(GenerateOptions{
+		Organizations: []string{"gitlab:group"},
+		Agency:        "TEST",
+		Contact:       Contact{Email: "code@test.gov"},
+		Exclude:       []string{"group/skip-*"},
+		HTTPClient:    srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	releases := make(map[string]Release)
+	for _, release := range codeGov.Releases {
+		releases[release.Name] = release
+	}
+	if len(releases) != 2 || releases["widget"].Name == "" || releases["old"].Name == "" {
+		t.Fatalf("expected private, forked and excluded projects to be skipped, got %+v", codeGov.Releases)
+	}
+
+	widget := releases["widget"]
+	if strings.Join(widget.Languages, ",") != "TypeScript,Go,Shell" {
+		t.Errorf("languages not ordered by share: %v", widget.Languages)
+	}
+	licenses := widget.Permissions.Licenses
+	if len(licenses) != 2 || licenses[0].Name != "Apache-2.0" || licenses[0].URL != "https://example.gov/LICENSE" {
+		t.Fatalf("unexpected licenses %+v", licenses)
+	}
+	if licenses[1].Name != "MIT" || licenses[1].URL != srv.URL+"/group/widget/-/blob/main/LICENSE-MIT" {
+		t.Errorf("expected the second license file to be reported, got %+v", licenses[1])
+	}
+	if widget.Version != "v1.4" || widget.DownloadURL != "https://example.gov/v1.4.zip" {
+		t.Errorf("expected the latest published release, got %s %s", widget.Version, widget.DownloadURL)
+	}
+	if widget.DisclaimerURL != srv.URL+"/group/widget/-/blob/main/DISCLAIMER.md" {
+		t.Errorf("unexpected disclaimer URL %s", widget.DisclaimerURL)
+	}
+	if widget.Status != "Production" || strings.Join(widget.Tags, ",") != "tools" || widget.Organization != "group" {
+		t.Errorf("unexpected release %+v", widget)
+	}
+	if widget.Date.Created != "2023-01-02" || widget.Date.LastModified != "2024-05-06" {
+		t.Errorf("unexpected dates %+v", widget.Date)
+	}
+
+	old := releases["old"]
+	if old.Status != "Archival" || strings.Join(old.Tags, ",") != "legacy" {
+		t.Errorf("unexpected archived release %+v", old)
+	}
+	if !strings.HasPrefix(old.Version, "0123456") || old.DownloadURL != srv.URL+"/group/old/-/archive/main/old-main.zip" {
+		t.Errorf("expected the branch commit and archive, got %s %s", old.Version, old.DownloadURL)
+	}
+}
+
+func TestGitLabGroupNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	t.Setenv(GitLabBaseURIEnv, srv.URL+"/api/v4")
+
+	_, err := getGitLabProjects(srv.Client(), "missing")
+	if !errors.Is(err, ErrOrgNotFound) {
+		t.Errorf("expected an organization not found error, got %v", err)
+	}
+}
+
+func TestGitLabThrottle(t *testing.T) {
+	t.Setenv(GitLabBaseURIEnv, "https://gitlab.example.gov/api/v4")
+
+	req, _ := http.NewRequest("GET", "https://gitlab.example.gov/api/v4/projects", nil)
+	if throttleFor(req) != gitLabThrottle {
+		t.Error("GitLab requests should use the GitLab throttle")
+	}
+	req, _ = http.NewRequest("GET", "https://api.github.com/orgs/x/repos", nil)
+	if throttleFor(req) != githubThrottle {
+		t.Error("GitHub requests should use the GitHub throttle")
+	}
+}
@@ -0,0 +1,371 @@
+package codegov
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GitHub API modes for GitHubConfig.API
+const (
+	GitHubAPIAuto    = "auto"    // GraphQL when a token is configured, otherwise REST
+	GitHubAPIREST    = "rest"    // One or more REST requests per repository attribute
+	GitHubAPIGraphQL = "graphql" // A few paginated GraphQL queries per organization; requires a token
+)
+
+// gitHubGraphQLPageSize is the number of repositories fetched per GraphQL query
+const gitHubGraphQLPageSize = 50
+
+// gitHubRepositoryFragment selects everything a release needs from a repository. The root
+// tree listing replaces the REST probes for LICENSE and DISCLAIMER files.
+const gitHubRepositoryFragment = `
+fragment releaseFields on Repository {
+  name
+  nameWithOwner
+  description
+  url
+  homepageUrl
+  isPrivate
+  isFork
+  isArchived
+  createdAt
+  updatedAt
+  pushedAt
+  defaultBranchRef { name target { oid } }
+  repositoryTopics(first: 100) { nodes { topic { name } } }
+  languages(first: 100) { totalSize nodes { name } }
+  licenseInfo { spdxId }
+  latestRelease { tagName }
+  refs(refPrefix: "refs/tags/", first: 20, orderBy: {field: TAG_COMMIT_DATE, direction: DESC}) { nodes { name } }
+  object(expression: "HEAD:") { ... on Tree { entries { name } } }
+  codegovYml: object(expression: "HEAD:.codegov.yml") { ... on Blob { text } }
+  codegovYaml: object(expression: "HEAD:.codegov.yaml") { ... on Blob { text } }
+  codeInventory: object(expression: "HEAD:codeinventory.json") { ... on Blob { text } }
+}`
+
+// gitHubOrgQuery fetches a page of an organization's repositories
+const gitHubOrgQuery = `query($org: String!, $first: Int!, $cursor: String) {
+  organization(login: $org) {
+    repositories(first: $first, after: $cursor, orderBy: {field: NAME, direction: ASC}) {
+      pageInfo { hasNextPage endCursor }
+      nodes { ...releaseFields }
+    }
+  }
+}` + gitHubRepositoryFragment
+
+// gitHubUserQuery fetches a page of the repositories a user owns
+const gitHubUserQuery = `query($org: String!, $first: Int!, $cursor: String) {
+  user(login: $org) {
+    repositories(first: $first, after: $cursor, ownerAffiliations: [OWNER], orderBy: {field: NAME, direction: ASC}) {
+      pageInfo { hasNextPage endCursor }
+      nodes { ...releaseFields }
+    }
+  }
+}` + gitHubRepositoryFragment
+
+// gitHubViewerQuery fetches a page of the repositories the authenticated user owns
+const gitHubViewerQuery = `query($first: Int!, $cursor: String) {
+  viewer {
+    repositories(first: $first, after: $cursor, ownerAffiliations: [OWNER], orderBy: {field: NAME, direction: ASC}) {
+      pageInfo { hasNextPage endCursor }
+      nodes { ...releaseFields }
+    }
+  }
+}` + gitHubRepositoryFragment
+
+// gitHubGraphQLRepositories is a page of repositories from one of the listing queries
+type gitHubGraphQLRepositories struct {
+	PageInfo struct {
+		HasNextPage bool   `json:"hasNextPage"`
+		EndCursor   string `json:"endCursor"`
+	} `json:"pageInfo"`
+	Nodes []gitHubGraphQLRepository `json:"nodes"`
+}
+
+// gitHubGraphQLRepository is a repository node selected by gitHubRepositoryFragment
+type gitHubGraphQLRepository struct {
+	Name             string    `json:"name"`
+	NameWithOwner    string    `json:"nameWithOwner"`
+	Description      string    `json:"description"`
+	URL              string    `json:"url"`
+	HomepageURL      string    `json:"homepageUrl"`
+	IsPrivate        bool      `json:"isPrivate"`
+	IsFork           bool      `json:"isFork"`
+	IsArchived       bool      `json:"isArchived"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+	PushedAt         time.Time `json:"pushedAt"`
+	DefaultBranchRef *struct {
+		Name   string `json:"name"`
+		Target *struct {
+			OID string `json:"oid"`
+		} `json:"target"`
+	} `json:"defaultBranchRef"`
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct {
+				Name string `json:"name"`
+			} `json:"topic"`
+		} `json:"nodes"`
+	} `json:"repositoryTopics"`
+	Languages struct {
+		TotalSize int64 `json:"totalSize"`
+		Nodes     []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+	} `json:"languages"`
+	LicenseInfo *struct {
+		SPDXID string `json:"spdxId"`
+	} `json:"licenseInfo"`
+	LatestRelease *struct {
+		TagName string `json:"tagName"`
+	} `json:"latestRelease"`
+	Refs struct {
+		Nodes []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+	} `json:"refs"` // Tags, most recently committed first
+	Object *struct {
+		Entries []struct {
+			Name string `json:"name"`
+		} `json:"entries"`
+	} `json:"object"`
+	CodegovYml    *gitHubGraphQLBlob `json:"codegovYml"`
+	CodegovYaml   *gitHubGraphQLBlob `json:"codegovYaml"`
+	CodeInventory *gitHubGraphQLBlob `json:"codeInventory"`
+}
+
+// gitHubGraphQLBlob is a file's content; Text is null for binary files
+type gitHubGraphQLBlob struct {
+	Text *string `json:"text"`
+}
+
+// gitHubDetails carries enrichment data fetched in bulk, so the GitHub provider can skip per-repository REST calls
+type gitHubDetails struct {
+	languages   []string
+	codeBytes   int64 // Size of the code GitHub detected, across all languages
+	license     License
+	downloadURL string                        // Zipball of the latest release; empty when there is none
+	releaseTag  string                        // Tag of the latest release
+	tags        []string                      // Most recent tags, newest first
+	sha         string                        // Commit at the head of the default branch
+	metadata    map[string]*gitHubGraphQLBlob // Per-repository metadata files; nil when absent
+	root        *gitHubRoot                   // Root of the default branch, already listed; the provider sets its client
+}
+
+// useGitHubGraphQL reports whether a run's API mode, or SetGitHubConfig's when it is
+// empty, selects GraphQL for the run, which must be authenticated to use it
+func useGitHubGraphQL(mode string, authenticated bool) (bool, error) {
+	if mode == "" {
+		githubConfigMu.RLock()
+		mode = githubConfig.API
+		githubConfigMu.RUnlock()
+	}
+
+	switch mode {
+	case "", GitHubAPIAuto:
+		return authenticated, nil
+	case GitHubAPIREST:
+		return false, nil
+	case GitHubAPIGraphQL:
+		if !authenticated {
+			return false, fmt.Errorf("the GitHub GraphQL API requires a token; set %s, Credentials.GitHubToken or Credentials.GitHubApp", OAuthTokenEnv)
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("unknown GitHub API mode %q (expected auto, rest or graphql)", mode)
+}
+
+// GetGitHubGraphQLURI returns the GraphQL endpoint matching the REST base URI.
+// GitHub Enterprise Server serves it at /api/graphql next to /api/v3.
+func GetGitHubGraphQLURI() string {
+	return gitHubAPI("").graphQLURI()
+}
+
+// getGitHubRepositoriesGraphQL lists an organization's or user's repositories with their enrichment data
+func getGitHubRepositoriesGraphQL(client *http.Client, organization string) ([]GitHubRepository, map[string]*gitHubDetails, error) {
+	api := gitHubAPIOf(client)
+	owner := parseGitHubOwner(organization)
+	query, kind := gitHubOrgQuery, "an Organization"
+	switch {
+	case owner.user && owner.login == "":
+		query = gitHubViewerQuery
+	case owner.user:
+		query, kind = gitHubUserQuery, "a User"
+	}
+
+	var repos []GitHubRepository
+	details := make(map[string]*gitHubDetails)
+
+	var cursor *string
+	for {
+		var data struct {
+			Organization *struct {
+				Repositories gitHubGraphQLRepositories `json:"repositories"`
+			} `json:"organization"`
+			User *struct {
+				Repositories gitHubGraphQLRepositories `json:"repositories"`
+			} `json:"user"`
+			Viewer *struct {
+				Repositories gitHubGraphQLRepositories `json:"repositories"`
+			} `json:"viewer"`
+		}
+		variables := map[string]interface{}{"first": gitHubGraphQLPageSize, "cursor": cursor}
+		if owner.login != "" {
+			variables["org"] = owner.login
+		}
+		if err := gitHubGraphQL(client, query, variables, &data); err != nil {
+			return nil, nil, err
+		}
+
+		var page gitHubGraphQLRepositories
+		switch {
+		case data.Organization != nil:
+			page = data.Organization.Repositories
+		case data.User != nil:
+			page = data.User.Repositories
+		case data.Viewer != nil:
+			page = data.Viewer.Repositories
+		default:
+			return nil, nil, &APIError{StatusCode: http.StatusNotFound, URL: api.graphQLURI(), Message: "Could not resolve to " + kind + " with the login of '" + owner.login + "'", kind: ErrOrgNotFound}
+		}
+
+		for _, node := range page.Nodes {
+			repo, d := node.convert(api)
+			repos = append(repos, repo)
+			details[repo.Name] = d
+		}
+		defaultMetrics.addReposFetched("github", len(page.Nodes))
+
+		if !page.PageInfo.HasNextPage {
+			break
+		}
+		endCursor := page.PageInfo.EndCursor
+		cursor = &endCursor
+	}
+
+	return repos, details, nil
+}
+
+// convert maps a GraphQL node onto the REST repository type plus its enrichment data
+func (n gitHubGraphQLRepository) convert(api gitHubAPI) (GitHubRepository, *gitHubDetails) {
+	repo := GitHubRepository{
+		Name:        n.Name,
+		Description: n.Description,
+		HTMLURL:     n.URL,
+		CloneURL:    n.URL + ".git",
+		Private:     n.IsPrivate,
+		Fork:        n.IsFork,
+		Archived:    n.IsArchived,
+		Homepage:    n.HomepageURL,
+		CreatedAt:   n.CreatedAt,
+		UpdatedAt:   n.UpdatedAt,
+		PushedAt:    n.PushedAt,
+	}
+	repo.Owner.Login, _, _ = strings.Cut(n.NameWithOwner, "/")
+	if n.DefaultBranchRef != nil {
+		repo.DefaultBranch = n.DefaultBranchRef.Name
+	}
+	for _, t := range n.RepositoryTopics.Nodes {
+		repo.Topics = append(repo.Topics, t.Topic.Name)
+	}
+
+	d := &gitHubDetails{languages: []string{}}
+	for _, l := range n.Languages.Nodes {
+		d.languages = append(d.languages, l.Name)
+	}
+	sort.Strings(d.languages)
+	d.codeBytes = n.Languages.TotalSize
+
+	files := make(map[string]bool)
+	if n.Object != nil {
+		for _, e := range n.Object.Entries {
+			files[e.Name] = true
+		}
+	}
+	d.root = &gitHubRoot{fullName: n.NameWithOwner, branch: repo.DefaultBranch, files: files}
+	for _, candidate := range []string{"LICENSE", "LICENSE.md", "LICENSE.txt"} {
+		if files[candidate] {
+			d.license.URL = fmt.Sprintf("%s/blob/%s/%s", repo.HTMLURL, repo.DefaultBranch, candidate)
+			break
+		}
+	}
+	if n.LicenseInfo != nil {
+		d.license.Name = n.LicenseInfo.SPDXID
+	}
+	d.metadata = map[string]*gitHubGraphQLBlob{
+		".codegov.yml":       n.CodegovYml,
+		".codegov.yaml":      n.CodegovYaml,
+		"codeinventory.json": n.CodeInventory,
+	}
+
+	if n.LatestRelease != nil {
+		d.downloadURL = api.webLink(fmt.Sprintf("%s/repos/%s/zipball/%s", api.baseURI(), n.NameWithOwner, n.LatestRelease.TagName))
+		d.releaseTag = n.LatestRelease.TagName
+	}
+	for _, ref := range n.Refs.Nodes {
+		d.tags = append(d.tags, ref.Name)
+	}
+	if n.DefaultBranchRef != nil && n.DefaultBranchRef.Target != nil {
+		d.sha = n.DefaultBranchRef.Target.OID
+	}
+
+	return repo, d
+}
+
+// gitHubGraphQL runs a GraphQL query and decodes its data into v
+func gitHubGraphQL(client *http.Client, query string, variables map[string]interface{}, v interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", gitHubAPIOf(client).graphQLURI(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	setClientHeaders(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := doAPIRequest(client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp, nil)
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+
+	if len(result.Errors) > 0 {
+		// GraphQL reports failures in a 200 response; map them onto the REST status codes
+		first := result.Errors[0]
+		apiErr := &APIError{StatusCode: resp.StatusCode, URL: sanitizeURL(req.URL), Message: first.Message}
+		switch first.Type {
+		case "NOT_FOUND":
+			apiErr.StatusCode, apiErr.kind = http.StatusNotFound, ErrOrgNotFound
+		case "FORBIDDEN":
+			apiErr.StatusCode, apiErr.kind = http.StatusForbidden, ErrForbidden
+		case "RATE_LIMITED":
+			apiErr.StatusCode, apiErr.kind = http.StatusForbidden, ErrRateLimited
+		}
+		return apiErr
+	}
+
+	return json.Unmarshal(result.Data, v)
+}
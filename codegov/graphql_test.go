@@ -0,0 +1,188 @@
+package codegov
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGenerateGraphQL(t *testing.T) {
+	t.Setenv(OAuthTokenEnv, "0123456789abcdef0123456789abcdef01234567")
+
+	pages := []string{
+		`{"data":{"organization":{"repositories":{"pageInfo":{"hasNextPage":true,"endCursor":"c1"},"nodes":[
+			{"name":"widget","nameWithOwner":"testorg/widget","description":"A widget service","url":"https://github.example.gov/testorg/widget",
+			 "createdAt":"2019-03-01T00:00:00Z","updatedAt":"2024-01-02T00:00:00Z","pushedAt":"2024-01-01T00:00:00Z",
+			 "defaultBranchRef":{"name":"main"},"repositoryTopics":{"nodes":[{"topic":{"name":"api"}}]},
+			 "languages":{"nodes":[{"name":"Shell"},{"name":"Go"}]},"licenseInfo":{"spdxId":"MIT"},
+			 "latestRelease":{"tagName":"v1.4.0"},"object":{"entries":[{"name":"LICENSE"},{"name":"DISCLAIMER.md"},{"name":"main.go"}]},
+			 "codegovYml":{"text":"laborHours: 40\n"}}]}}}}`,
+		`{"data":{"organization":{"repositories":{"pageInfo":{"hasNextPage":false,"endCursor":"c2"},"nodes":[
+			{"name":"fork","nameWithOwner":"testorg/fork","url":"https://github.example.gov/testorg/fork","isFork":true,"languages":{"nodes":[]}},
+			{"name":"legacy","nameWithOwner":"testorg/legacy","url":"https://github.example.gov/testorg/legacy","isArchived":true,
+			 "createdAt":"2015-06-01T00:00:00Z","updatedAt":"2020-02-03T00:00:00Z","pushedAt":"2020-02-01T00:00:00Z",
+			 "defaultBranchRef":{"name":"master","target":{"oid":"9fceb02d0ae598e95dc970b74767f19372d61af8"}},"languages":{"totalSize":400000,"nodes":[]},
+			 "refs":{"nodes":[{"name":"v3.0.0-rc.1"},{"name":"v2.10.1"},{"name":"v2.9.4"}]},"object":{"entries":[{"name":"COPYING"},{"name":"README.md"}]}}]}}}}`,
+	}
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// legacy has no license GitHub recognizes, so its license file is read over REST
+		if r.URL.Path == "/api/v3/repos/testorg/legacy/contents/COPYING" {
+			if r.Header.Get("Accept") != "application/vnd.github.raw" || r.URL.Query().Get("ref") != "master" {
+				t.Errorf("unexpected license request %s %v", r.URL, r.Header)
+			}
+			w.Write([]byte("Copyright 2015 Agency\n\nRedistribution and use in source and binary forms, with or without\nmodification, are permitted ...\n3. Neither the name of the copyright holder ..."))
+			return
+		}
+		// legacy has no description either, so its README is read over REST
+		if r.URL.Path == "/api/v3/repos/testorg/legacy/contents/README.md" {
+			w.Write([]byte("# Legacy\n\n[![Build](https://ci.example.gov/badge.svg)](https://ci.example.gov)\n\nThe **legacy** records\nsystem, kept for [audits](docs/audits.md).\n\n## Install\n"))
+			return
+		}
+		if r.Method != "POST" || r.URL.Path != "/api/graphql" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "bearer 0123456789abcdef0123456789abcdef01234567" {
+			t.Errorf("missing token, got %q", r.Header.Get("Authorization"))
+		}
+		var body struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Variables["org"] != "testorg" {
+			t.Errorf("unexpected variables %v", body.Variables)
+		}
+		if requests == 1 && body.Variables["cursor"] != "c1" {
+			t.Errorf("expected cursor c1, got %v", body.Variables["cursor"])
+		}
+		w.Write([]byte(pages[requests]))
+		requests++
+	}))
+	defer srv.Close()
+
+	SetGitHubConfig(GitHubConfig{BaseURI: srv.URL + "/api/v3"})
+	defer SetGitHubConfig(GitHubConfig{})
+
+	codeGov, err := Generate(GenerateOptions{
+		Organizations: []string{"testorg"},
+		Agency:        "TEST",
+		Contact:       Contact{Email: "code@test.gov"},
+		HTTPClient:    srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected one query per page, got %d requests", requests)
+	}
+	if len(codeGov.Releases) != 2 {
+		t.Fatalf("expected forks to be skipped, got %d releases", len(codeGov.Releases))
+	}
+
+	legacy, widget := codeGov.Releases[0], codeGov.Releases[1]
+	if strings.Join(widget.Languages, ",") != "Go,Shell" || strings.Join(widget.Tags, ",") != "api" {
+		t.Errorf("unexpected languages or tags %v %v", widget.Languages, widget.Tags)
+	}
+	if lic := widget.Permissions.Licenses[0]; lic.Name != "MIT" || lic.URL != "https://github.example.gov/testorg/widget/blob/main/LICENSE" {
+		t.Errorf("unexpected license %+v", lic)
+	}
+	if widget.LaborHours != 40 {
+		t.Errorf("expected laborHours from .codegov.yml, got %v", widget.LaborHours)
+	}
+	if widget.DisclaimerURL != "https://github.example.gov/testorg/widget/blob/main/DISCLAIMER.md" {
+		t.Errorf("unexpected disclaimer URL %s", widget.DisclaimerURL)
+	}
+	if widget.DownloadURL != srv.URL+"/repos/testorg/widget/zipball/v1.4.0" || widget.Version != "v1.4.0" {
+		t.Errorf("unexpected download URL or version %s %s", widget.DownloadURL, widget.Version)
+	}
+	if legacy.Status != "Archival" || legacy.DownloadURL != "https://github.example.gov/testorg/legacy/archive/master.zip" {
+		t.Errorf("unexpected archived release %+v", legacy)
+	}
+	// 400 kB of code is about 10 KSLOC
+	if legacy.LaborHours != EstimateLaborHoursFromBytes(400000) || legacy.LaborHours < 4000 || legacy.LaborHours > 4200 {
+		t.Errorf("expected laborHours estimated from the code size, got %v", legacy.LaborHours)
+	}
+	if legacy.Version != "v2.10.1" {
+		t.Errorf("expected the version from the latest stable tag, got %q", legacy.Version)
+	}
+	if legacy.Description != "The legacy records system, kept for audits." {
+		t.Errorf("expected the description from the README, got %q", legacy.Description)
+	}
+	if lic := legacy.Permissions.Licenses[0]; lic.Name != "BSD-3-Clause" || lic.URL != "https://github.example.gov/testorg/legacy/blob/master/COPYING" {
+		t.Errorf("expected the license detected from COPYING, got %+v", lic)
+	}
+}
+
+func TestGraphQLAuthenticatedUser(t *testing.T) {
+	t.Setenv(OAuthTokenEnv, "0123456789abcdef0123456789abcdef01234567")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/graphql" {
+			http.NotFound(w, r)
+			return
+		}
+		var body struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if !strings.Contains(body.Query, "viewer {") || !strings.Contains(body.Query, "ownerAffiliations: [OWNER]") {
+			t.Errorf("expected the viewer query, got %s", body.Query)
+		}
+		if _, ok := body.Variables["org"]; ok {
+			t.Errorf("the viewer query takes no login, got %v", body.Variables)
+		}
+		w.Write([]byte(`{"data":{"viewer":{"repositories":{"pageInfo":{"hasNextPage":false},"nodes":[
+			{"name":"deploy-tools","nameWithOwner":"svc-builds/deploy-tools","description":"Deployment scripts","url":"https://github.example.gov/svc-builds/deploy-tools",
+			 "isPrivate":true,"pushedAt":"2024-01-01T00:00:00Z","defaultBranchRef":{"name":"main"},"languages":{"nodes":[]},"latestRelease":{"tagName":"v2.0.0"}}]}}}}`))
+	}))
+	defer srv.Close()
+
+	SetGitHubConfig(GitHubConfig{BaseURI: srv.URL + "/api/v3"})
+	defer SetGitHubConfig(GitHubConfig{})
+
+	codeGov, err := Generate(GenerateOptions{
+		Organizations:  []string{"user:@me"},
+		Agency:         "TEST",
+		Contact:        Contact{Email: "code@test.gov"},
+		IncludePrivate: true,
+		HTTPClient:     srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(codeGov.Releases) != 1 {
+		t.Fatalf("unexpected releases %+v", codeGov.Releases)
+	}
+	if release := codeGov.Releases[0]; release.Organization != "svc-builds" || release.Version != "v2.0.0" {
+		t.Errorf("expected the release under the login, got %+v", release)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	t.Setenv(OAuthTokenEnv, "0123456789abcdef0123456789abcdef01234567")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"organization":null},"errors":[{"type":"NOT_FOUND","message":"Could not resolve to an Organization with the login of 'nope'."}]}`))
+	}))
+	defer srv.Close()
+
+	SetGitHubConfig(GitHubConfig{BaseURI: srv.URL + "/api/v3", API: GitHubAPIGraphQL})
+	defer SetGitHubConfig(GitHubConfig{})
+
+	_, err := Generate(GenerateOptions{Organizations: []string{"nope"}, Agency: "TEST", Contact: Contact{Email: "code@test.gov"}, HTTPClient: srv.Client()})
+	if !errors.Is(err, ErrOrgNotFound) {
+		t.Errorf("expected ErrOrgNotFound, got %v", err)
+	}
+
+	t.Setenv(OAuthTokenEnv, "")
+	if _, err := Generate(GenerateOptions{Organizations: []string{"nope"}, Agency: "TEST", Contact: Contact{Email: "code@test.gov"}, HTTPClient: srv.Client()}); err == nil {
+		t.Error("expected graphql mode without a token to fail")
+	}
+}
@@ -0,0 +1,299 @@
+package codegov
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is a single RFC 6902 JSON Patch operation
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// ApplyJSONPatch applies an RFC 6902 JSON Patch document to a code.gov inventory.
+// In addition to numeric indexes, a release may be addressed by name, e.g.
+// "/releases/my-repo/permissions/licenses/-". The patch is applied atomically:
+// if any operation fails, or the result is not a valid inventory, codeGov is unchanged.
+func ApplyJSONPatch(codeGov *CodeGovJSON, patch []byte) error {
+	var ops []PatchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return fmt.Errorf("failed to parse JSON Patch: %w", err)
+	}
+
+	data, err := json.Marshal(codeGov)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	for i, op := range ops {
+		doc, err = applyPatchOperation(doc, op)
+		if err != nil {
+			return fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	data, err = json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	var updated CodeGovJSON
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&updated); err != nil {
+		return fmt.Errorf("patched inventory is invalid: %w", err)
+	}
+
+	*codeGov = updated
+	return nil
+}
+
+// isJSONPatch reports whether an override file holds a JSON Patch array rather
+// than the {"overrides": [...]} format
+func isJSONPatch(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("["))
+}
+
+func applyPatchOperation(doc interface{}, op PatchOperation) (interface{}, error) {
+	path, err := parsePointer(doc, op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("value is required")
+		}
+		var value interface{}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, err
+		}
+		switch op.Op {
+		case "add":
+			return patchAdd(doc, path, value)
+		case "replace":
+			if _, err := patchGet(doc, path); err != nil {
+				return nil, err
+			}
+			doc, _, err = patchRemove(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			return patchAdd(doc, path, value)
+		default:
+			current, err := patchGet(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !equalValues(current, value) {
+				return nil, fmt.Errorf("test failed")
+			}
+			return doc, nil
+		}
+
+	case "remove":
+		doc, _, err = patchRemove(doc, path)
+		return doc, err
+
+	case "move", "copy":
+		from, err := parsePointer(doc, op.From)
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		var value interface{}
+		if op.Op == "move" {
+			if len(path) > len(from) && strings.Join(path[:len(from)], "/") == strings.Join(from, "/") {
+				return nil, fmt.Errorf("cannot move a value into one of its children")
+			}
+			doc, value, err = patchRemove(doc, from)
+			if err != nil {
+				return nil, fmt.Errorf("from: %w", err)
+			}
+			// Release names must be resolved again now that indexes may have shifted
+			if path, err = parsePointer(doc, op.Path); err != nil {
+				return nil, err
+			}
+		} else {
+			value, err = patchGet(doc, from)
+			if err != nil {
+				return nil, fmt.Errorf("from: %w", err)
+			}
+			value = deepCopy(value)
+		}
+		return patchAdd(doc, path, value)
+	}
+
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+// parsePointer splits a JSON Pointer into unescaped tokens, replacing a release
+// name in "/releases/<name>" with that release's current index
+func parsePointer(doc interface{}, pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON Pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+
+	if len(tokens) >= 2 && tokens[0] == "releases" {
+		root, _ := doc.(map[string]interface{})
+		releases, _ := root["releases"].([]interface{})
+		for i, r := range releases {
+			if release, ok := r.(map[string]interface{}); ok && release["name"] == tokens[1] {
+				tokens[1] = strconv.Itoa(i)
+				break
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// patchGet returns the value at path
+func patchGet(doc interface{}, path []string) (interface{}, error) {
+	node := doc
+	for _, token := range path {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			child, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("%s not found", token)
+			}
+			node = child
+		case []interface{}:
+			i, err := arrayIndex(token, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("cannot descend into %s", token)
+		}
+	}
+	return node, nil
+}
+
+// patchAdd inserts value at path and returns the (possibly new) document
+func patchAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := patchGet(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[token] = value
+		return doc, nil
+	case []interface{}:
+		i := len(p)
+		if token != "-" {
+			if i, err = arrayIndex(token, len(p)); err != nil {
+				return nil, err
+			}
+		}
+		updated := append(p[:i:i], append([]interface{}{value}, p[i:]...)...)
+		return patchSet(doc, path[:len(path)-1], updated)
+	}
+	return nil, fmt.Errorf("cannot add to %s", token)
+}
+
+// patchRemove deletes the value at path, returning the document and the removed value
+func patchRemove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the document root")
+	}
+
+	parent, err := patchGet(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	token := path[len(path)-1]
+
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		value, ok := p[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s not found", token)
+		}
+		delete(p, token)
+		return doc, value, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(p)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := p[i]
+		updated := append(p[:i:i], p[i+1:]...)
+		doc, err = patchSet(doc, path[:len(path)-1], updated)
+		return doc, value, err
+	}
+	return nil, nil, fmt.Errorf("cannot remove %s", token)
+}
+
+// patchSet replaces the value at an existing path, used to store resized arrays
+func patchSet(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := patchGet(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[token] = value
+	case []interface{}:
+		i, err := arrayIndex(token, len(p)-1)
+		if err != nil {
+			return nil, err
+		}
+		p[i] = value
+	}
+	return doc, nil
+}
+
+// arrayIndex parses an RFC 6901 array index no greater than max
+func arrayIndex(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+	if i > max {
+		return 0, fmt.Errorf("index %d out of range", i)
+	}
+	return i, nil
+}
+
+func deepCopy(v interface{}) interface{} {
+	data, _ := json.Marshal(v)
+	var out interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
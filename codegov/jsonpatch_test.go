@@ -0,0 +1,117 @@
+package codegov
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func patchFixture() *CodeGovJSON {
+	release := func(name string) Release {
+		return Release{
+			Name:        name,
+			Permissions: Permissions{Licenses: []License{{Name: "MIT"}}, UsageType: "openSource"},
+			Tags:        []string{"go"},
+		}
+	}
+	return &CodeGovJSON{Version: "2.0", Agency: "TEST", Releases: []Release{release("alpha"), release("beta"), release("gamma")}}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	codeGov := patchFixture()
+
+	patch := `[
+		{"op": "test", "path": "/releases/beta/permissions/usageType", "value": "openSource"},
+		{"op": "replace", "path": "/releases/beta/description", "value": "Beta service"},
+		{"op": "add", "path": "/releases/beta/tags/0", "value": "first"},
+		{"op": "add", "path": "/releases/beta/permissions/licenses/-", "value": {"name": "Apache-2.0"}},
+		{"op": "copy", "from": "/releases/beta/tags", "path": "/releases/gamma/tags"},
+		{"op": "remove", "path": "/releases/alpha"},
+		{"op": "move", "from": "/releases/gamma", "path": "/releases/0"}
+	]`
+	if err := ApplyJSONPatch(codeGov, []byte(patch)); err != nil {
+		t.Fatalf("ApplyJSONPatch failed: %v", err)
+	}
+
+	if len(codeGov.Releases) != 2 || codeGov.Releases[0].Name != "gamma" || codeGov.Releases[1].Name != "beta" {
+		t.Fatalf("unexpected releases %+v", codeGov.Releases)
+	}
+	beta := codeGov.Releases[1]
+	if beta.Description != "Beta service" {
+		t.Errorf("description not replaced: %q", beta.Description)
+	}
+	if strings.Join(beta.Tags, ",") != "first,go" || strings.Join(codeGov.Releases[0].Tags, ",") != "first,go" {
+		t.Errorf("unexpected tags %v / %v", beta.Tags, codeGov.Releases[0].Tags)
+	}
+	if len(beta.Permissions.Licenses) != 2 || beta.Permissions.Licenses[1].Name != "Apache-2.0" {
+		t.Errorf("unexpected licenses %+v", beta.Permissions.Licenses)
+	}
+}
+
+func TestApplyJSONPatchIsAtomic(t *testing.T) {
+	for name, patch := range map[string]string{
+		"failed test":     `[{"op": "replace", "path": "/agency", "value": "X"}, {"op": "test", "path": "/version", "value": "1.0"}]`,
+		"missing path":    `[{"op": "replace", "path": "/agency", "value": "X"}, {"op": "remove", "path": "/releases/nope"}]`,
+		"unknown field":   `[{"op": "add", "path": "/releases/alpha/bogus", "value": 1}]`,
+		"wrong type":      `[{"op": "replace", "path": "/releases/alpha/tags", "value": "go"}]`,
+		"unknown op":      `[{"op": "merge", "path": "/agency", "value": "X"}]`,
+		"move into child": `[{"op": "move", "from": "/releases/alpha", "path": "/releases/alpha/tags/0"}]`,
+	} {
+		codeGov := patchFixture()
+		if err := ApplyJSONPatch(codeGov, []byte(patch)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+		if codeGov.Agency != "TEST" || len(codeGov.Releases) != 3 {
+			t.Errorf("%s: inventory modified by failed patch", name)
+		}
+	}
+}
+
+func TestInvokeCodeGovJsonOverrideWithPatch(t *testing.T) {
+	dir := t.TempDir()
+	original := filepath.Join(dir, "code.json")
+	patched := filepath.Join(dir, "patched.json")
+	overrides := filepath.Join(dir, "patch.json")
+
+	if err := writeCodeGovJSON(patchFixture(), original); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(overrides, []byte(`[{"op": "remove", "path": "/releases/beta"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InvokeCodeGovJsonOverride(original, patched, overrides); err != nil {
+		t.Fatalf("override failed: %v", err)
+	}
+	data, err := os.ReadFile(patched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"beta"`) || !strings.Contains(string(data), `"gamma"`) {
+		t.Errorf("unexpected output:\n%s", data)
+	}
+}
+
+func FuzzApplyOverrides(f *testing.F) {
+	f.Add([]byte(`[{"op": "replace", "path": "/releases/beta/description", "value": "patched"}, {"op": "move", "from": "/releases/0/tags/0", "path": "/releases/1/tags/-"}]`))
+	f.Add([]byte(`[{"op": "test", "path": "/releases/~1x/name", "value": null}, {"op": "remove", "path": "/releases/9"}]`))
+	f.Add([]byte(`{"overrides": [{"project": "alpha", "action": "replaceproperty", "property": "permissions.licenses", "value": [{"name": "Apache-2.0"}]}, {"project": "gamma", "action": "removeproject"}]}`))
+	f.Add([]byte(`{"overrides": [{"project": "beta", "action": "replaceproperty", "property": "tags.0.x", "value": 1}]}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		codeGov := patchFixture()
+		before, _ := json.Marshal(codeGov)
+		err := applyOverrides(codeGov, data)
+		if err != nil && isJSONPatch(data) {
+			// Patches are atomic, so a rejected patch leaves the inventory unchanged
+			if after, _ := json.Marshal(codeGov); string(after) != string(before) {
+				t.Fatalf("failed patch changed the inventory: %v", err)
+			}
+		}
+		if _, err := json.Marshal(codeGov); err != nil {
+			t.Fatalf("overrides produced an inventory that cannot be written: %v", err)
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package codegov
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// licenseFileNames are the usual names of a repository's main license file, in order of precedence
+var licenseFileNames = []string{"LICENSE", "LICENSE.md", "LICENSE.txt", "LICENCE", "LICENCE.md", "COPYING", "COPYING.md", "COPYING.txt"}
+
+// licenseHeaderSize is how much of the normalized text counts as the license title
+const licenseHeaderSize = 300
+
+// licenseSignatures identifies common licenses by distinctive phrases in normalized
+// text (lower case, punctuation dropped, whitespace collapsed); every phrase must
+// appear. Header signatures match titles near the top of the file, and the title
+// found first wins, because license texts mention other licenses (GPL-3.0 refers
+// to the Affero GPL, the LGPL to the GPL). Other signatures are tried in order,
+// most specific first.
+var licenseSignatures = []struct {
+	spdx    string
+	header  bool
+	phrases []string
+}{
+	{"AGPL-3.0", true, []string{"gnu affero general public license"}},
+	{"LGPL-2.1", true, []string{"gnu lesser general public license", "version 2.1"}},
+	{"LGPL-2.0", true, []string{"gnu library general public license"}},
+	{"LGPL-3.0", true, []string{"gnu lesser general public license"}},
+	{"GPL-2.0", true, []string{"gnu general public license", "version 2 june 1991"}},
+	{"GPL-3.0", true, []string{"gnu general public license"}},
+	{"MPL-2.0", true, []string{"mozilla public license version 2.0"}},
+	{"EPL-2.0", true, []string{"eclipse public license v 2.0"}},
+	{"Apache-2.0", false, []string{"apache license version 2.0"}},
+	{"Apache-2.0", true, []string{"apache license"}},
+	{"BSL-1.0", false, []string{"boost software license version 1.0"}},
+	{"CC0-1.0", false, []string{"cc0 1.0 universal"}},
+	{"Unlicense", false, []string{"this is free and unencumbered software released into the public domain"}},
+	{"MIT", true, []string{"mit license"}},
+	{"MIT", false, []string{"permission is hereby granted free of charge"}},
+	{"ISC", false, []string{"permission to use copy modify and or distribute this software for any purpose with or without fee is hereby granted provided that"}},
+	{"Zlib", false, []string{"this software is provided as is without any express or implied warranty", "altered source versions must be plainly marked as such"}},
+	{"BSD-3-Clause", false, []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", false, []string{"redistribution and use in source and binary forms"}},
+}
+
+// detectLicenseName returns the SPDX identifier for license text, or "" if unknown.
+// An SPDX-License-Identifier line wins over text matching.
+func detectLicenseName(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.Index(line, "SPDX-License-Identifier:"); i >= 0 {
+			return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line[i+len("SPDX-License-Identifier:"):]), "*/"))
+		}
+	}
+
+	normalized := normalizeLicenseText(text)
+	header := normalized
+	if len(header) > licenseHeaderSize {
+		header = header[:licenseHeaderSize]
+	}
+	best, bestAt := "", len(header)
+	for _, sig := range licenseSignatures {
+		if !sig.header || !containsAll(header, sig.phrases) {
+			continue
+		}
+		if at := strings.Index(header, sig.phrases[0]); at < bestAt {
+			best, bestAt = sig.spdx, at
+		}
+	}
+	if best != "" {
+		return best
+	}
+	for _, sig := range licenseSignatures {
+		if !sig.header && containsAll(normalized, sig.phrases) {
+			return sig.spdx
+		}
+	}
+	return ""
+}
+
+// containsAll reports whether text contains every phrase
+func containsAll(text string, phrases []string) bool {
+	for _, phrase := range phrases {
+		if !strings.Contains(text, phrase) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeLicenseText lower-cases text and reduces it to words separated by single
+// spaces, keeping dots inside version numbers, so phrases match regardless of line
+// wrapping, Markdown and comment markup
+func normalizeLicenseText(text string) string {
+	var b strings.Builder
+	space := true
+	runes := []rune(text)
+	for i, r := range runes {
+		keep := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if r == '.' && i > 0 && i+1 < len(runes) && unicode.IsDigit(runes[i-1]) && unicode.IsDigit(runes[i+1]) {
+			keep = true
+		}
+		if !keep {
+			if !space {
+				b.WriteByte(' ')
+				space = true
+			}
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+		space = false
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// needsLicenseDetection reports whether a license reported by a provider lacks a
+// usable SPDX identifier
+func needsLicenseDetection(name string) bool {
+	return name == "" || name == "NOASSERTION" || name == "OTHER"
+}
+
+// isLicenseFile reports whether a root file name holds license text: LICENSE, LICENCE
+// or COPYING, alone or followed by an extension or a qualifier such as LICENSE-MIT,
+// LICENSE.APACHE or COPYING.LESSER
+func isLicenseFile(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, base := range []string{"LICENSE", "LICENCE", "COPYING"} {
+		if rest, ok := strings.CutPrefix(upper, base); ok && (rest == "" || strings.ContainsRune(".-_", rune(rest[0]))) {
+			return true
+		}
+	}
+	return false
+}
+
+// licenseFiles returns the license files among a repository's root names, the usual
+// main license names first in licenseFileNames order and the rest sorted
+func licenseFiles(names []string) []string {
+	rank := func(name string) int {
+		for i, n := range licenseFileNames {
+			if n == name {
+				return i
+			}
+		}
+		return len(licenseFileNames)
+	}
+	var files []string
+	for _, name := range names {
+		if isLicenseFile(name) {
+			files = append(files, name)
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if ri, rj := rank(files[i]), rank(files[j]); ri != rj {
+			return ri < rj
+		}
+		return files[i] < files[j]
+	})
+	return files
+}
+
+// collectLicenses returns every license of a repository: primary, the license the
+// provider reported, followed by the other licenses identified from license files in
+// the root (names), so dual-licensed projects with LICENSE-MIT and LICENSE-APACHE, or
+// COPYING and COPYING.LESSER, list both. When primary lacks an SPDX identifier it is
+// completed from its file (or the first license file) and keeps the reported name if
+// the text is not recognized. Other files whose license cannot be identified, or
+// repeats one already listed, are skipped.
+func (g *generator) collectLicenses(repoName string, primary License, names []string, read func(name string) ([]byte, error), fileURL func(name string) string) []License {
+	files := licenseFiles(names)
+	licenses := []License{primary}
+	if len(files) == 0 {
+		return licenses
+	}
+
+	// The provider's license URL names the file it identified
+	primaryFile := ""
+	for _, name := range files {
+		if primary.URL != "" && strings.HasSuffix(primary.URL, "/"+name) {
+			primaryFile = name
+		}
+	}
+	if primary.URL == "" {
+		primaryFile = files[0]
+		licenses[0].URL = fileURL(primaryFile)
+	}
+
+	// identify returns the SPDX identifier of a license file's text
+	identify := func(name string) (string, bool) {
+		data, err := read(name)
+		if err != nil {
+			g.enrichmentError(repoName, "license", fmt.Errorf("reading %s: %w", name, err))
+			return "", false
+		}
+		return detectLicenseName(string(data)), true
+	}
+
+	if primaryFile != "" && needsLicenseDetection(primary.Name) {
+		spdx, ok := identify(primaryFile)
+		if !ok {
+			return licenses
+		}
+		if spdx != "" {
+			licenses[0].Name = spdx
+		}
+	}
+
+	seen := map[string]bool{licenses[0].Name: true}
+	for _, name := range files {
+		if name == primaryFile {
+			continue
+		}
+		spdx, ok := identify(name)
+		if !ok {
+			break
+		}
+		if spdx != "" && !seen[spdx] {
+			seen[spdx] = true
+			licenses = append(licenses, License{URL: fileURL(name), Name: spdx})
+		}
+	}
+	return licenses
+}
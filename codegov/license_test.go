@@ -0,0 +1,70 @@
+package codegov
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectLicenseNameText(t *testing.T) {
+	for text, want := range map[string]string{
+		"                    GNU LESSER GENERAL PUBLIC LICENSE\n                       Version 2.1, February 1999":                               "LGPL-2.1",
+		"GNU GENERAL PUBLIC LICENSE\nVersion 3, 29 June 2007\n\n13. Use with the GNU Affero General Public License.":                             "GPL-3.0",
+		"# Apache License\n_Version 2.0, January 2004_":                                                                                          "Apache-2.0",
+		"Mozilla Public License, version 2.0":                                                                                                    "MPL-2.0",
+		"Redistribution and use in source and binary forms, with or\nwithout modification, are permitted provided that:":                         "BSD-2-Clause",
+		"Redistribution and use in source and binary forms ... Neither the name of the\ncopyright holder":                                        "BSD-3-Clause",
+		"Permission to use, copy, modify, and/or distribute this software for any\npurpose with or without fee is hereby granted, provided that": "ISC",
+		"Boost Software License - Version 1.0 - August 17th, 2003":                                                                               "BSL-1.0",
+		"This software is a work of the United States Government and is in the public domain.":                                                   "",
+	} {
+		if got := detectLicenseName(text); got != want {
+			t.Errorf("detectLicenseName(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestLicenseFiles(t *testing.T) {
+	names := []string{"COPYING.LESSER", "LICENSE-MIT", "README.md", "LICENSE-APACHE", "LICENSES.md", "COPYING", "licensing.go", "LICENSE"}
+	got := strings.Join(licenseFiles(names), ",")
+	if got != "LICENSE,COPYING,COPYING.LESSER,LICENSE-APACHE,LICENSE-MIT" {
+		t.Errorf("unexpected license files %s", got)
+	}
+}
+
+func TestCollectLicenses(t *testing.T) {
+	texts := map[string]string{
+		"LICENSE-APACHE": "Apache License\nVersion 2.0, January 2004",
+		"LICENSE-MIT":    "MIT License\n\nCopyright (c) 2024 Agency",
+		"LICENSE.md":     "# MIT License",
+		"COPYING":        "Contact the agency for licensing questions.",
+	}
+	var reads []string
+	read := func(name string) ([]byte, error) {
+		reads = append(reads, name)
+		return []byte(texts[name]), nil
+	}
+	fileURL := func(name string) string { return "https://github.example.gov/org/repo/blob/main/" + name }
+	g := &generator{report: &GenerationReport{}}
+
+	// GitHub identified LICENSE-MIT; the Apache license is found alongside it
+	licenses := g.collectLicenses("org/repo", License{Name: "MIT", URL: fileURL("LICENSE-MIT")}, []string{"LICENSE-APACHE", "LICENSE-MIT", "main.go"}, read, fileURL)
+	if len(licenses) != 2 || licenses[0].Name != "MIT" || licenses[1] != (License{Name: "Apache-2.0", URL: fileURL("LICENSE-APACHE")}) {
+		t.Errorf("expected MIT and Apache-2.0, got %+v", licenses)
+	}
+	if strings.Join(reads, ",") != "LICENSE-APACHE" {
+		t.Errorf("expected only the unidentified file to be read, got %v", reads)
+	}
+
+	// Nothing reported: the main file is identified, duplicates and unknown text are skipped
+	licenses = g.collectLicenses("org/repo", License{}, []string{"COPYING", "LICENSE-MIT", "LICENSE.md"}, read, fileURL)
+	if len(licenses) != 1 || licenses[0] != (License{Name: "MIT", URL: fileURL("LICENSE.md")}) {
+		t.Errorf("unexpected licenses %+v", licenses)
+	}
+
+	// A single license file GitHub already identified is not read
+	reads = nil
+	licenses = g.collectLicenses("org/repo", License{Name: "MIT", URL: fileURL("LICENSE")}, []string{"LICENSE"}, read, fileURL)
+	if len(licenses) != 1 || len(reads) != 0 {
+		t.Errorf("unexpected licenses %+v after reading %v", licenses, reads)
+	}
+}
@@ -0,0 +1,174 @@
+package codegov
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// StageLinks is the report stage of dead links found by GenerateOptions.CheckURLs
+const StageLinks = "links"
+
+// DefaultLinkCheckConcurrency is the default number of URLs checked in parallel
+const DefaultLinkCheckConcurrency = 16
+
+// DeadLink is an inventory URL that did not respond with a success or redirect status
+type DeadLink struct {
+	Release string `json:"release"`
+	Field   string `json:"field"` // e.g. repositoryURL or permissions.licenses[0].URL
+	URL     string `json:"url"`
+	Status  int    `json:"status,omitempty"` // 0 when no response was received
+	Error   string `json:"error,omitempty"`
+}
+
+func (d DeadLink) String() string {
+	if d.Status != 0 {
+		return fmt.Sprintf("%s %s: %s returned %d", d.Release, d.Field, d.URL, d.Status)
+	}
+	return fmt.Sprintf("%s %s: %s: %s", d.Release, d.Field, d.URL, d.Error)
+}
+
+// inventoryLink is a URL field of a release
+type inventoryLink struct {
+	release string
+	field   string
+	url     string
+}
+
+// inventoryLinks returns the repository, download, homepage and license URLs of an
+// inventory's releases, skipping empty ones
+func inventoryLinks(codeGov *CodeGovJSON) []inventoryLink {
+	var links []inventoryLink
+	add := func(release, field, url string) {
+		if url != "" {
+			links = append(links, inventoryLink{release: release, field: field, url: url})
+		}
+	}
+	for _, r := range codeGov.Releases {
+		add(r.Name, "repositoryURL", r.RepositoryURL)
+		add(r.Name, "downloadURL", r.DownloadURL)
+		add(r.Name, "homepageURL", r.HomepageURL)
+		for i, license := range r.Permissions.Licenses {
+			add(r.Name, fmt.Sprintf("permissions.licenses[%d].URL", i), license.URL)
+		}
+	}
+	return links
+}
+
+// CheckURLs checks every repositoryURL, downloadURL, homepageURL and license URL of an
+// inventory and returns the dead ones, ordered by release and field. Requests are
+// unauthenticated, so links are judged as code.gov and the public see them; URLs shared
+// by several releases are requested once. concurrency <= 0 uses
+// DefaultLinkCheckConcurrency.
+func CheckURLs(codeGov *CodeGovJSON, concurrency int) []DeadLink {
+	return checkURLs(newHTTPClient(10*time.Second), codeGov, concurrency)
+}
+
+func checkURLs(client *http.Client, codeGov *CodeGovJSON, concurrency int) []DeadLink {
+	links := inventoryLinks(codeGov)
+
+	var urls []string
+	seen := make(map[string]bool)
+	for _, link := range links {
+		if !seen[link.url] {
+			seen[link.url] = true
+			urls = append(urls, link.url)
+		}
+	}
+
+	if concurrency <= 0 {
+		concurrency = DefaultLinkCheckConcurrency
+	}
+	if concurrency > len(urls) {
+		concurrency = len(urls)
+	}
+
+	type result struct {
+		status int
+		err    error
+	}
+	var mu sync.Mutex
+	results := make(map[string]result, len(urls))
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range queue {
+				status, err := linkStatus(client, u)
+				mu.Lock()
+				results[u] = result{status: status, err: err}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, u := range urls {
+		queue <- u
+	}
+	close(queue)
+	wg.Wait()
+
+	var dead []DeadLink
+	for _, link := range links {
+		r := results[link.url]
+		if r.err == nil && r.status < http.StatusBadRequest {
+			continue
+		}
+		d := DeadLink{Release: link.release, Field: link.field, URL: link.url, Status: r.status}
+		if r.err != nil {
+			d.Error = r.err.Error()
+		}
+		dead = append(dead, d)
+	}
+	sort.SliceStable(dead, func(i, j int) bool {
+		return dead[i].Release < dead[j].Release
+	})
+	return dead
+}
+
+// linkStatus returns a URL's final status after redirects. Servers that do not
+// support HEAD are asked again with GET.
+func linkStatus(client *http.Client, u string) (int, error) {
+	status, err := requestStatus(client, "HEAD", u)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		return requestStatus(client, "GET", u)
+	}
+	return status, err
+}
+
+func requestStatus(client *http.Client, method, u string) (int, error) {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	setClientHeaders(req)
+
+	resp, err := doWithRetry(client, req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// checkLinks records the inventory's dead links as warnings
+func (g *generator) checkLinks(codeGov *CodeGovJSON) {
+	client := &http.Client{Timeout: 10 * time.Second, Transport: g.transport}
+	if g.opts.Context != nil {
+		client.Transport = &contextTransport{base: client.Transport, ctx: g.opts.Context}
+	}
+	client = g.runClient(client)
+
+	dead := checkURLs(client, codeGov, g.opts.LinkCheckConcurrency)
+	for _, d := range dead {
+		g.report.add(ReportIssue{Severity: SeverityWarning, Repo: d.Release, Stage: StageLinks, Message: d.String()})
+	}
+	g.report.DeadLinks = len(dead)
+	if len(dead) > 0 {
+		g.logger.Printf("%d dead links in the inventory\n", len(dead))
+	}
+}
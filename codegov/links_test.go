@@ -0,0 +1,83 @@
+package codegov
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCheckURLs(t *testing.T) {
+	stubRetrySleep(t)
+
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+		case "/no-head":
+			if r.Method == "HEAD" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/gone":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	license := []License{{Name: "MIT", URL: srv.URL + "/ok"}}
+	codeGov := &CodeGovJSON{Releases: []Release{
+		{Name: "alpha", RepositoryURL: srv.URL + "/moved", DownloadURL: srv.URL + "/no-head", Permissions: Permissions{Licenses: license}},
+		{Name: "beta", RepositoryURL: srv.URL + "/ok", HomepageURL: srv.URL + "/gone", DownloadURL: "http://127.0.0.1:1/archive.zip",
+			Permissions: Permissions{Licenses: []License{{Name: "MIT"}, {Name: "CC0-1.0", URL: srv.URL + "/gone"}}}},
+	}}
+
+	dead := checkURLs(srv.Client(), codeGov, 4)
+	if len(dead) != 3 {
+		t.Fatalf("expected 3 dead links, got %v", dead)
+	}
+	want := []struct {
+		field  string
+		status int
+	}{
+		{"downloadURL", 0},
+		{"homepageURL", http.StatusNotFound},
+		{"permissions.licenses[1].URL", http.StatusNotFound},
+	}
+	for i, w := range want {
+		if dead[i].Release != "beta" || dead[i].Field != w.field || dead[i].Status != w.status {
+			t.Errorf("dead link %d = %+v, want beta %s %d", i, dead[i], w.field, w.status)
+		}
+	}
+	if dead[0].Error == "" || !strings.Contains(dead[0].String(), "archive.zip") {
+		t.Errorf("expected a connection error for the download URL, got %+v", dead[0])
+	}
+
+	// URLs shared by several fields are requested once; GET only follows a refused HEAD
+	if n := requests["HEAD /ok"]; n != 2 { // Once directly, once after the redirect
+		t.Errorf("expected 2 HEAD requests for /ok, got %d", n)
+	}
+	if requests["GET /no-head"] != 1 || requests["GET /gone"] != 0 {
+		t.Errorf("unexpected GET fallbacks: %v", requests)
+	}
+}
+
+func TestGeneratorCheckLinksReportsWarnings(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	g := &generator{opts: GenerateOptions{CheckURLs: true}, report: &GenerationReport{}, transport: srv.Client().Transport, logger: log.New(io.Discard, "", 0)}
+	g.checkLinks(&CodeGovJSON{Releases: []Release{{Name: "alpha", RepositoryURL: srv.URL + "/alpha"}}})
+
+	warnings := g.report.Warnings()
+	if g.report.DeadLinks != 1 || len(warnings) != 1 || warnings[0].Stage != StageLinks || warnings[0].Repo != "alpha" {
+		t.Fatalf("unexpected report %+v", g.report)
+	}
+}
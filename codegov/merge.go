@@ -0,0 +1,89 @@
+package codegov
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Merge combines several code.gov JSON files, e.g. one per agency sub-component,
+// into a single agency inventory. See MergeInventories for the rules applied.
+func Merge(paths ...string) (*CodeGovJSON, error) {
+	inventories := make([]*CodeGovJSON, 0, len(paths))
+	for _, path := range paths {
+		codeGov, err := ReadCodeGovJSONFile(path)
+		if err != nil {
+			return nil, err
+		}
+		inventories = append(inventories, codeGov)
+	}
+	return mergeInventories(paths, inventories)
+}
+
+// MergeInventories combines inventories into one. Every inventory must carry the
+// same version, agency and measurement type, and a release name may appear in
+// only one of them. Releases are sorted by name.
+func MergeInventories(inventories ...*CodeGovJSON) (*CodeGovJSON, error) {
+	sources := make([]string, len(inventories))
+	for i := range inventories {
+		sources[i] = fmt.Sprintf("inventory %d", i)
+	}
+	return mergeInventories(sources, inventories)
+}
+
+// mergeInventories merges inventories, naming them by source in error messages
+func mergeInventories(sources []string, inventories []*CodeGovJSON) (*CodeGovJSON, error) {
+	if len(inventories) == 0 {
+		return nil, fmt.Errorf("at least one inventory is required")
+	}
+
+	first := inventories[0]
+	if first.Agency == "" {
+		return nil, fmt.Errorf("%s: agency is required", sources[0])
+	}
+
+	merged := &CodeGovJSON{
+		Version:         first.Version,
+		Agency:          first.Agency,
+		MeasurementType: first.MeasurementType,
+	}
+
+	var problems []string
+	owner := make(map[string]string)
+	for i, inventory := range inventories {
+		switch {
+		case inventory.Agency != first.Agency:
+			problems = append(problems, fmt.Sprintf("%s: agency %q does not match %q from %s", sources[i], inventory.Agency, first.Agency, sources[0]))
+		case inventory.Version != first.Version:
+			problems = append(problems, fmt.Sprintf("%s: version %q does not match %q from %s", sources[i], inventory.Version, first.Version, sources[0]))
+		case inventory.MeasurementType != first.MeasurementType:
+			problems = append(problems, fmt.Sprintf("%s: measurementType does not match %s", sources[i], sources[0]))
+		}
+
+		for _, release := range inventory.Releases {
+			if previous, ok := owner[release.Name]; ok {
+				problems = append(problems, fmt.Sprintf("release %q appears in both %s and %s", release.Name, previous, sources[i]))
+				continue
+			}
+			owner[release.Name] = sources[i]
+			merged.Releases = append(merged.Releases, release)
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("cannot merge inventories:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	sort.Slice(merged.Releases, func(i, j int) bool {
+		return merged.Releases[i].Name < merged.Releases[j].Name
+	})
+	return merged, nil
+}
+
+// MergeFiles merges code.gov JSON files and writes the combined inventory to outputPath
+func MergeFiles(outputPath string, paths ...string) error {
+	merged, err := Merge(paths...)
+	if err != nil {
+		return err
+	}
+	return writeCodeGovJSON(merged, outputPath)
+}
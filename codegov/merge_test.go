@@ -0,0 +1,48 @@
+package codegov
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+
+	a := patchFixture()
+	a.Releases = a.Releases[2:] // gamma
+	b := patchFixture()
+	b.Releases = b.Releases[:2] // alpha, beta
+
+	pathA := filepath.Join(dir, "a.json")
+	pathB := filepath.Join(dir, "b.json")
+	for path, inventory := range map[string]*CodeGovJSON{pathA: a, pathB: b} {
+		if err := writeCodeGovJSON(inventory, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	merged, err := Merge(pathA, pathB)
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if merged.Agency != "TEST" || len(merged.Releases) != 3 || merged.Releases[0].Name != "alpha" || merged.Releases[2].Name != "gamma" {
+		t.Errorf("unexpected merged inventory %+v", merged)
+	}
+}
+
+func TestMergeRejectsConflicts(t *testing.T) {
+	other := patchFixture()
+	other.Agency = "OTHER"
+	other.Releases = nil
+	if _, err := MergeInventories(patchFixture(), other); err == nil || !strings.Contains(err.Error(), `agency "OTHER"`) {
+		t.Errorf("expected agency mismatch error, got %v", err)
+	}
+
+	duplicate := patchFixture()
+	duplicate.Releases = duplicate.Releases[1:2]
+	_, err := MergeInventories(patchFixture(), duplicate)
+	if err == nil || !strings.Contains(err.Error(), `release "beta" appears in both inventory 0 and inventory 1`) {
+		t.Errorf("expected duplicate release error, got %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package codegov
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Metrics collects counters for code.gov generation runs so inventory health
+// can be observed when the generator runs inside a long-lived process
+type Metrics struct {
+	mu sync.Mutex
+
+	reposFetched      map[string]int64
+	apiCalls          map[string]int64
+	rateLimitRemain   map[string]int64
+	enrichmentErrors  map[string]int64
+	releasesEmitted   int64
+	runs              int64
+	runFailures       int64
+	lastRunDuration   time.Duration
+	lastRunCompletion time.Time
+}
+
+// MetricsSnapshot is a point-in-time copy of the generation metrics
+type MetricsSnapshot struct {
+	ReposFetched       map[string]int64 `json:"repos_fetched"`
+	APICalls           map[string]int64 `json:"api_calls"`
+	RateLimitRemaining map[string]int64 `json:"rate_limit_remaining"`
+	EnrichmentErrors   map[string]int64 `json:"enrichment_errors"`
+	ReleasesEmitted    int64            `json:"releases_emitted"`
+	Runs               int64            `json:"runs"`
+	RunFailures        int64            `json:"run_failures"`
+	LastRunDuration    float64          `json:"last_run_duration_seconds"`
+	LastRunCompletion  time.Time        `json:"last_run_completion,omitempty"`
+}
+
+// NewMetrics creates an empty metrics collector
+func NewMetrics() *Metrics {
+	return &Metrics{
+		reposFetched:     make(map[string]int64),
+		apiCalls:         make(map[string]int64),
+		rateLimitRemain:  make(map[string]int64),
+		enrichmentErrors: make(map[string]int64),
+	}
+}
+
+// defaultMetrics is the collector used by the package-level generation functions
+var defaultMetrics = NewMetrics()
+
+// DefaultMetrics returns the collector updated by all generation runs in this process
+func DefaultMetrics() *Metrics {
+	return defaultMetrics
+}
+
+// addReposFetched records repositories or projects listed from a provider
+func (m *Metrics) addReposFetched(provider string, n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reposFetched[provider] += int64(n)
+}
+
+// observeAPIResponse counts an API call and records the provider's remaining rate limit
+func (m *Metrics) observeAPIResponse(provider string, resp *http.Response) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.apiCalls[provider]++
+
+	if resp == nil {
+		return
+	}
+	// GitHub sends X-RateLimit-Remaining, GitLab sends RateLimit-Remaining
+	for _, header := range []string{"X-RateLimit-Remaining", "RateLimit-Remaining"} {
+		if v := resp.Header.Get(header); v != "" {
+			if remaining, err := strconv.ParseInt(v, 10, 64); err == nil {
+				m.rateLimitRemain[provider] = remaining
+			}
+			return
+		}
+	}
+}
+
+// addEnrichmentError records a failed enrichment step such as languages or license lookup
+func (m *Metrics) addEnrichmentError(stage string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enrichmentErrors[stage]++
+}
+
+// observeRun records a completed generation run
+func (m *Metrics) observeRun(duration time.Duration, releases int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.runs++
+	if err != nil {
+		m.runFailures++
+	}
+	m.releasesEmitted += int64(releases)
+	m.lastRunDuration = duration
+	m.lastRunCompletion = time.Now().UTC()
+}
+
+// Snapshot returns a copy of the current metrics
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return MetricsSnapshot{
+		ReposFetched:       copyCounts(m.reposFetched),
+		APICalls:           copyCounts(m.apiCalls),
+		RateLimitRemaining: copyCounts(m.rateLimitRemain),
+		EnrichmentErrors:   copyCounts(m.enrichmentErrors),
+		ReleasesEmitted:    m.releasesEmitted,
+		Runs:               m.runs,
+		RunFailures:        m.runFailures,
+		LastRunDuration:    m.lastRunDuration.Seconds(),
+		LastRunCompletion:  m.lastRunCompletion,
+	}
+}
+
+func copyCounts(src map[string]int64) map[string]int64 {
+	dst := make(map[string]int64, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	s := m.Snapshot()
+
+	writeLabeled := func(name, help, kind, label string, values map[string]int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s{%s=%q} %d\n", name, label, k, values[k])
+		}
+	}
+	writeValue := func(name, help, kind string, value interface{}) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+	}
+
+	writeLabeled("codegov_repos_fetched_total", "Repositories listed from source providers.", "counter", "provider", s.ReposFetched)
+	writeLabeled("codegov_api_calls_total", "API requests sent to source providers.", "counter", "provider", s.APICalls)
+	writeLabeled("codegov_rate_limit_remaining", "Remaining API requests reported by the provider.", "gauge", "provider", s.RateLimitRemaining)
+	writeLabeled("codegov_enrichment_errors_total", "Failed repository enrichment steps.", "counter", "stage", s.EnrichmentErrors)
+	writeValue("codegov_releases_emitted_total", "Releases written to generated code.gov JSON.", "counter", s.ReleasesEmitted)
+	writeValue("codegov_runs_total", "Completed generation runs.", "counter", s.Runs)
+	writeValue("codegov_run_failures_total", "Generation runs that returned an error.", "counter", s.RunFailures)
+	writeValue("codegov_last_run_duration_seconds", "Duration of the most recent generation run.", "gauge", s.LastRunDuration)
+
+	var completion int64
+	if !s.LastRunCompletion.IsZero() {
+		completion = s.LastRunCompletion.Unix()
+	}
+	writeValue("codegov_last_run_completion_timestamp_seconds", "Unix time the most recent generation run completed.", "gauge", completion)
+
+	return nil
+}
+
+// MetricsHandler serves the default generation metrics in Prometheus text format
+func MetricsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		defaultMetrics.WritePrometheus(w)
+	}
+}
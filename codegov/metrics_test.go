@@ -0,0 +1,128 @@
+package codegov
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// scrapeMetrics fetches MetricsHandler's exposition and returns its samples by name and labels
+func scrapeMetrics(t *testing.T) (map[string]string, http.Header) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	MetricsHandler()(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	samples := make(map[string]string)
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, " ")
+		samples[line[:i]] = line[i+1:]
+	}
+	return samples, rec.Header()
+}
+
+func TestMetricsHandlerScrape(t *testing.T) {
+	previous := defaultMetrics
+	defaultMetrics = NewMetrics()
+	defer func() { defaultMetrics = previous }()
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4990")
+		switch r.URL.Path {
+		case "/api/v3/orgs/agency/repos":
+			var repos []string
+			for _, name := range []string{"api", "web"} {
+				repos = append(repos, fmt.Sprintf(`{"name":%q,"html_url":"%s/agency/%s","languages_url":"%s/api/v3/repos/agency/%s/languages","releases_url":"%s/api/v3/repos/agency/%s/releases{/id}","default_branch":"main","created_at":"2024-01-02T00:00:00Z","pushed_at":"2024-03-04T00:00:00Z"}`,
+					name, srv.URL, name, srv.URL, name, srv.URL, name))
+			}
+			w.Write([]byte("[" + strings.Join(repos, ",") + "]"))
+		case "/api/v3/repos/agency/api/languages":
+			w.Write([]byte(`{"Go":1000}`))
+		case "/api/v3/repos/agency/web/languages":
+			// Drop the connection, failing the languages step without a response
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		case "/api/v3/repos/agency/api/releases", "/api/v3/repos/agency/web/releases":
+			w.Write([]byte(`[]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	generate := func(org string) error {
+		_, _, err := GenerateWithReport(GenerateOptions{
+			Organizations:    []string{org},
+			Agency:           "Agency",
+			Contact:          Contact{Email: "code@agency.gov"},
+			Credentials:      Credentials{NoEnvFallback: true},
+			GitHub:           GitHubConfig{BaseURI: srv.URL + "/api/v3", API: GitHubAPIREST},
+			Retry:            RetryPolicy{MaxAttempts: 1},
+			SkipRepoMetadata: true,
+			HTTPClient:       srv.Client(),
+			Logger:           log.New(io.Discard, "", 0),
+		})
+		return err
+	}
+	if err := generate("agency"); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate("missing"); err == nil {
+		t.Fatal("expected the run for an unknown organization to fail")
+	}
+
+	samples, header := scrapeMetrics(t)
+	if ct := header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", ct)
+	}
+	for name, want := range map[string]string{
+		`codegov_repos_fetched_total{provider="github"}`:     "2",
+		`codegov_rate_limit_remaining{provider="github"}`:    "4990",
+		`codegov_enrichment_errors_total{stage="languages"}`: "1",
+		`codegov_releases_emitted_total`:                     "2",
+		`codegov_runs_total`:                                 "2",
+		`codegov_run_failures_total`:                         "1",
+	} {
+		if got := samples[name]; got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	// Listing both organizations plus each repository's languages and releases
+	if calls, _ := strconv.Atoi(samples[`codegov_api_calls_total{provider="github"}`]); calls < 6 {
+		t.Errorf("codegov_api_calls_total = %d, want at least 6", calls)
+	}
+	if ts, _ := strconv.ParseInt(samples["codegov_last_run_completion_timestamp_seconds"], 10, 64); ts == 0 {
+		t.Error("codegov_last_run_completion_timestamp_seconds was not set")
+	}
+	if _, ok := samples["codegov_last_run_duration_seconds"]; !ok {
+		t.Error("codegov_last_run_duration_seconds is missing")
+	}
+}
+
+func TestMetricsHandlerEmpty(t *testing.T) {
+	previous := defaultMetrics
+	defaultMetrics = NewMetrics()
+	defer func() { defaultMetrics = previous }()
+
+	rec := httptest.NewRecorder()
+	MetricsHandler()(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, name := range []string{"codegov_repos_fetched_total", "codegov_api_calls_total", "codegov_rate_limit_remaining", "codegov_enrichment_errors_total"} {
+		if !strings.Contains(body, "# TYPE "+name+" ") {
+			t.Errorf("missing TYPE line for %s", name)
+		}
+	}
+	samples, _ := scrapeMetrics(t)
+	if samples["codegov_runs_total"] != "0" || samples["codegov_last_run_completion_timestamp_seconds"] != "0" {
+		t.Errorf("samples of an empty collector = %v", samples)
+	}
+}
@@ -0,0 +1,215 @@
+package codegov
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPPasswordEnv supplies the SMTP password when SMTPConfig.Password is empty
+const SMTPPasswordEnv = "SMTP_PASSWORD"
+
+// RunSummary summarizes a generation run for notifications
+type RunSummary struct {
+	Agency           string         `json:"agency"`
+	GeneratedAt      time.Time      `json:"generated_at"`
+	Releases         int            `json:"releases"`
+	Changes          *InventoryDiff `json:"changes,omitempty"` // Nil when there was no previous inventory
+	Valid            bool           `json:"valid"`
+	ValidationErrors []string       `json:"validation_errors,omitempty"`
+}
+
+// NewRunSummary compares a new inventory with the previously published one (which
+// may be nil) and validates it against the code.gov schema
+func NewRunSummary(previous, current *CodeGovJSON) (*RunSummary, error) {
+	summary := &RunSummary{
+		Agency:      current.Agency,
+		GeneratedAt: time.Now().UTC(),
+		Releases:    len(current.Releases),
+	}
+
+	if previous != nil {
+		diff, err := Diff(previous, current)
+		if err != nil {
+			return nil, err
+		}
+		summary.Changes = diff
+	}
+
+	data, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	errs, err := DefaultSchema().Validate(data)
+	if err != nil {
+		return nil, err
+	}
+	summary.Valid = len(errs) == 0
+	summary.ValidationErrors = errs
+
+	return summary, nil
+}
+
+// Text renders the summary as a short plain-text report
+func (s *RunSummary) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "code.gov inventory for %s: %d releases", s.Agency, s.Releases)
+	if s.Valid {
+		b.WriteString(", valid\n")
+	} else {
+		fmt.Fprintf(&b, ", INVALID (%d schema errors)\n", len(s.ValidationErrors))
+	}
+
+	if s.Changes != nil {
+		b.WriteString("\n")
+		s.Changes.WriteText(&b)
+	}
+
+	if len(s.ValidationErrors) > 0 {
+		b.WriteString("\nValidation errors:\n")
+		for i, e := range s.ValidationErrors {
+			if i == 20 {
+				fmt.Fprintf(&b, "  ... and %d more\n", len(s.ValidationErrors)-i)
+				break
+			}
+			fmt.Fprintf(&b, "  - %s\n", e)
+		}
+	}
+	return b.String()
+}
+
+// subject is the one-line headline used for email subjects
+func (s *RunSummary) subject() string {
+	status := "valid"
+	if !s.Valid {
+		status = "INVALID"
+	}
+	if s.Changes == nil {
+		return fmt.Sprintf("[code.gov] %s inventory: %d releases, %s", s.Agency, s.Releases, status)
+	}
+	if len(s.Changes.Tombstoned) > 0 {
+		return fmt.Sprintf("[code.gov] %s inventory: %d added, %d removed, %d tombstoned, %d changed, %s",
+			s.Agency, len(s.Changes.Added), len(s.Changes.Removed), len(s.Changes.Tombstoned), len(s.Changes.Changed), status)
+	}
+	return fmt.Sprintf("[code.gov] %s inventory: %d added, %d removed, %d changed, %s",
+		s.Agency, len(s.Changes.Added), len(s.Changes.Removed), len(s.Changes.Changed), status)
+}
+
+// WebhookConfig posts the summary as JSON. The body carries a "text" field so
+// Slack and Teams incoming webhooks display it directly.
+type WebhookConfig struct {
+	URL     string
+	Headers map[string]string
+}
+
+// SMTPConfig sends the summary by email. STARTTLS is used whenever the server offers it.
+type SMTPConfig struct {
+	Host     string
+	Port     int // Defaults to 587
+	Username string
+	Password string // Defaults to $SMTP_PASSWORD
+	From     string
+	To       []string
+}
+
+// NotifyOptions selects where run summaries are sent; nil targets are skipped
+type NotifyOptions struct {
+	Webhook    *WebhookConfig
+	SMTP       *SMTPConfig
+	HTTPClient *http.Client // Defaults to a client with a 30 second timeout
+}
+
+// Notify sends the summary to every configured target, returning the failures joined
+func Notify(opts NotifyOptions, summary *RunSummary) error {
+	var errs []error
+	if opts.Webhook != nil {
+		if err := notifyWebhook(opts, summary); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		}
+	}
+	if opts.SMTP != nil {
+		if err := notifySMTP(opts.SMTP, summary); err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func notifyWebhook(opts NotifyOptions, summary *RunSummary) error {
+	payload := struct {
+		Text string `json:"text"`
+		*RunSummary
+	}{summary.Text(), summary}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, opts.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	setClientHeaders(req)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range opts.Webhook.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := opts.HTTPClient
+	if client == nil {
+		client = newHTTPClient(30 * time.Second)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned status %d", sanitizeURL(req.URL), resp.StatusCode)
+	}
+	return nil
+}
+
+func notifySMTP(config *SMTPConfig, summary *RunSummary) error {
+	if config.Host == "" || config.From == "" || len(config.To) == 0 {
+		return fmt.Errorf("host, from and at least one recipient are required")
+	}
+
+	port := config.Port
+	if port == 0 {
+		port = 587
+	}
+	password := config.Password
+	if password == "" {
+		password = os.Getenv(SMTPPasswordEnv)
+	}
+
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, password, config.Host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(config.To, ", "))
+	// The agency name comes from input data; keep it from injecting headers
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(summary.subject())
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", summary.GeneratedAt.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(summary.Text(), "\n", "\r\n"))
+
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(port))
+	return smtp.SendMail(addr, auth, config.From, config.To, msg.Bytes())
+}
@@ -0,0 +1,121 @@
+package codegov
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNotifyWebhook(t *testing.T) {
+	previous := patchFixture()
+	current := patchFixture()
+	current.Releases = current.Releases[1:]
+
+	summary, err := NewRunSummary(previous, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var payload map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer srv.Close()
+
+	if err := Notify(NotifyOptions{Webhook: &WebhookConfig{URL: srv.URL}}, summary); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	text, _ := payload["text"].(string)
+	if !strings.Contains(text, "- release alpha") || !strings.Contains(text, "0 added, 1 removed, 0 changed") {
+		t.Errorf("unexpected webhook text:\n%s", text)
+	}
+	if payload["releases"] != float64(2) {
+		t.Errorf("unexpected payload %v", payload)
+	}
+	// The fixture has no dates or URLs, so validation must report it
+	if payload["valid"] != false || summary.Valid {
+		t.Error("expected schema validation failures in the summary")
+	}
+}
+
+func TestNotifySMTP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	message := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 test ESMTP")
+
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					message <- data.String()
+					reply("250 queued")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO", "HELO", "MAIL", "RCPT":
+				reply("250 ok")
+			case "DATA":
+				inData = true
+				reply("354 go ahead")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 unsupported")
+			}
+		}
+	}()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	summary, err := NewRunSummary(nil, patchFixture())
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary.Agency = "TEST\r\nBcc: attacker@example.com"
+
+	err = Notify(NotifyOptions{SMTP: &SMTPConfig{
+		Host: "127.0.0.1",
+		Port: port,
+		From: "inventory@test.gov",
+		To:   []string{"owners@test.gov"},
+	}}, summary)
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	msg := <-message
+	if !strings.Contains(msg, "Subject: [code.gov] TEST  Bcc: attacker@example.com inventory: 3 releases, INVALID") {
+		t.Errorf("unexpected or injectable subject:\n%s", msg)
+	}
+	if headers, _, _ := strings.Cut(msg, "\r\n\r\n"); strings.Contains(headers, "\r\nBcc:") {
+		t.Errorf("header injection via agency name:\n%s", msg)
+	}
+}
@@ -0,0 +1,508 @@
+package codegov
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GenerateOptions configures a code.gov generation run
+type GenerateOptions struct {
+	// Organizations lists GitHub organizations; prefix GitLab groups with "gitlab:",
+	// Bitbucket workspaces or projects with "bitbucket:" and Azure DevOps organizations
+	// or organization/project pairs with "azuredevops:"
+	Organizations []string
+	Agency        string
+	Contact       Contact // Contact published on every release; Email is required
+
+	// Organization is published as every release's organization instead of the owner
+	// name, i.e. the GitHub organization, GitLab group or Bitbucket workspace
+	Organization string
+
+	// DisclaimerText is published on every release, e.g. the agency's standard disclaimer
+	DisclaimerText string
+
+	// PrivateUsageType is the usageType of private repositories' releases; public ones are
+	// openSource. Empty uses governmentWideReuse. ExemptionText justifies an exempt* type
+	// and is required with one.
+	PrivateUsageType string
+	ExemptionText    string
+
+	// Private, Forks and Archived filter repositories on each property. Empty values
+	// exclude private repositories and forks and include archived repositories.
+	Private  RepoFilter
+	Forks    RepoFilter
+	Archived RepoFilter
+
+	// IncludePrivate and IncludeForks are shorthand for Private and Forks set to
+	// RepoFilterInclude when those are empty.
+	//
+	// Deprecated: use Private and Forks.
+	IncludePrivate bool
+	IncludeForks   bool
+
+	// Include and Exclude are path.Match patterns tested against "org/repo"
+	// (or the GitLab project path). A repository must match at least one Include
+	// pattern when any are given, and no Exclude pattern.
+	Include []string
+	Exclude []string
+
+	// IncludeTopics keeps only repositories tagged with at least one of the topics and
+	// ExcludeTopics drops repositories tagged with any of them; topics match case-insensitively
+	IncludeTopics []string
+	ExcludeTopics []string
+
+	// NameRegex, when set, must match the repository's "org/repo" name (unanchored)
+	NameRegex string
+
+	// SkipRepoMetadata ignores .codegov.yml and codeinventory.json files committed to
+	// repositories, saving a request or two per repository
+	SkipRepoMetadata bool
+
+	// SkipLaborEstimate publishes laborHours 1 for GitHub repositories, and those of
+	// other providers implementing ProviderCodeSizer, instead of estimating it from the
+	// size of their code (EstimateLaborHoursFromBytes). Deep
+	// analysis and repository metadata still set laborHours.
+	SkipLaborEstimate bool
+
+	// CheckURLs requests every repository, download, homepage and license URL of the
+	// generated inventory and reports dead ones as warnings in the links stage, since
+	// code.gov scores inventories on link health. LinkCheckConcurrency URLs are checked
+	// in parallel; 0 uses DefaultLinkCheckConcurrency.
+	CheckURLs            bool
+	LinkCheckConcurrency int
+
+	// DownloadDigests, DownloadDigestsSidecar or DownloadDigestsInline, records the
+	// SHA-256 of each release's downloadURL artifact in GenerationReport.Digests, so
+	// consumers can check the archives they fetch. GenerateFile writes the sidecar;
+	// the inline form goes in additionalInformation. Downloads share
+	// LinkCheckConcurrency, and failures are warnings in the digests stage.
+	DownloadDigests string
+
+	// Previous is the inventory published before this run. With TombstoneDays > 0, its
+	// releases the run no longer finds, because their repositories were deleted or made
+	// private, are kept for that many days with status Archival and TombstoneTag instead
+	// of being dropped, and are listed in GenerationReport.Removed and as warnings in the
+	// removed stage. Releases are matched by name.
+	Previous      *CodeGovJSON
+	TombstoneDays int
+
+	// Credentials authenticate this run's API requests and clones; empty fields fall back
+	// to the environment. Runs with different credentials may execute concurrently.
+	Credentials Credentials
+
+	// GitHub selects the run's GitHub backend, e.g. a GitHub Enterprise Server API root;
+	// empty fields take SetGitHubConfig's values
+	GitHub GitHubConfig
+
+	// Retry is the retry policy of the run's API requests and URL probes; zero fields take
+	// their values from DefaultRetryPolicy. The zero policy uses SetRetryPolicy's.
+	Retry RetryPolicy
+
+	// Concurrency is the number of repositories enriched in parallel; 0 uses
+	// DefaultEnrichmentConcurrency. API calls remain subject to the request rate set
+	// by SetMaxRequestsPerSecond.
+	Concurrency int
+
+	HTTPClient *http.Client // Client for API requests; defaults to a client with a per-request timeout
+	Logger     *log.Logger  // Receives progress and error messages; defaults to log.Default()
+
+	// Progress, when set, is called as organizations are listed and repositories processed
+	Progress ProgressFunc
+
+	// Transport is shared by all API requests of the run when HTTPClient is nil, keeping
+	// the per-request timeouts; defaults to ClientOptions.Transport
+	Transport http.RoundTripper
+
+	// UserAgent replaces DefaultUserAgent on the run's API requests. GitHub requires a
+	// User-Agent that identifies the application, so browser strings should not be used.
+	UserAgent string
+
+	// Headers are added to every API request of the run, e.g. a corporate proxy's
+	// Proxy-Authorization. They cannot override the provider auth headers.
+	Headers map[string]string
+
+	// Compact writes GenerateFile's JSON without indentation
+	Compact bool
+
+	// Context, when set, cancels the run: once it is done, in-flight and later API
+	// requests fail without retries and the run returns the context's error
+	Context context.Context
+}
+
+// RepoFilter selects repositories by a yes/no property such as private or fork
+type RepoFilter string
+
+const (
+	RepoFilterExclude RepoFilter = "exclude" // Drop repositories with the property
+	RepoFilterInclude RepoFilter = "include" // Keep repositories with or without the property
+	RepoFilterOnly    RepoFilter = "only"    // Keep only repositories with the property
+)
+
+// ParseRepoFilter parses "exclude", "include" or "only"
+func ParseRepoFilter(s string) (RepoFilter, error) {
+	switch f := RepoFilter(strings.ToLower(strings.TrimSpace(s))); f {
+	case RepoFilterExclude, RepoFilterInclude, RepoFilterOnly:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid repository filter %q (want exclude, include or only)", s)
+}
+
+// or returns the filter, or def when it is empty
+func (f RepoFilter) or(def RepoFilter) RepoFilter {
+	if f == "" {
+		return def
+	}
+	return f
+}
+
+// allows reports whether a repository whose property is set to value passes the filter
+func (f RepoFilter) allows(value bool) bool {
+	switch f {
+	case RepoFilterExclude:
+		return !value
+	case RepoFilterOnly:
+		return value
+	}
+	return true
+}
+
+// filters returns the private, fork and archived filters with defaults and the
+// deprecated booleans applied
+func (o GenerateOptions) filters() (private, forks, archived RepoFilter) {
+	private, forks = RepoFilterExclude, RepoFilterExclude
+	if o.IncludePrivate {
+		private = RepoFilterInclude
+	}
+	if o.IncludeForks {
+		forks = RepoFilterInclude
+	}
+	return o.Private.or(private), o.Forks.or(forks), o.Archived.or(RepoFilterInclude)
+}
+
+// validate checks the options before any API request is made
+func (o GenerateOptions) validate() error {
+	if len(o.Organizations) == 0 {
+		return fmt.Errorf("at least one organization is required")
+	}
+	if o.Agency == "" {
+		return fmt.Errorf("agency is required")
+	}
+	if o.Contact.Email == "" {
+		return fmt.Errorf("contact email is required")
+	}
+	for _, pattern := range append(append([]string{}, o.Include...), o.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid repository pattern %q: %w", pattern, err)
+		}
+	}
+	for name, f := range map[string]RepoFilter{"private": o.Private, "forks": o.Forks, "archived": o.Archived} {
+		if f == "" {
+			continue
+		}
+		if _, err := ParseRepoFilter(string(f)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if _, err := regexp.Compile(o.NameRegex); err != nil {
+		return fmt.Errorf("invalid repository name regex %q: %w", o.NameRegex, err)
+	}
+	if o.PrivateUsageType != "" && !ValidUsageType(o.PrivateUsageType) {
+		return fmt.Errorf("invalid usage type %q for private repositories", o.PrivateUsageType)
+	}
+	if ExemptUsageType(o.PrivateUsageType) && o.ExemptionText == "" {
+		return fmt.Errorf("exemption text is required with usage type %s", o.PrivateUsageType)
+	}
+	switch o.DownloadDigests {
+	case "", DownloadDigestsSidecar, DownloadDigestsInline:
+	default:
+		return fmt.Errorf("invalid download digests %q (expected %s or %s)", o.DownloadDigests, DownloadDigestsSidecar, DownloadDigestsInline)
+	}
+	if o.TombstoneDays < 0 {
+		return fmt.Errorf("tombstone days must not be negative")
+	}
+	return nil
+}
+
+// permissions returns the permissions published for a repository with the given licenses
+func (g *generator) permissions(licenses []License, private bool) Permissions {
+	if !private {
+		return Permissions{Licenses: licenses, UsageType: UsageTypeOpenSource}
+	}
+
+	perms := Permissions{Licenses: licenses, UsageType: g.opts.PrivateUsageType}
+	if perms.UsageType == "" {
+		perms.UsageType = UsageTypeGovernmentWideReuse
+	}
+	if ExemptUsageType(perms.UsageType) {
+		perms.ExemptionText = g.opts.ExemptionText
+	}
+	return perms
+}
+
+// generator carries the options of a single generation run
+type generator struct {
+	opts   GenerateOptions
+	logger *log.Logger
+	creds  Credentials // opts.Credentials resolved against the environment once per run
+
+	transport http.RoundTripper // Pools connections across the run; nil uses the shared transport
+
+	nameRegex *regexp.Regexp // Compiled opts.NameRegex; nil when unset
+	retry     RetryPolicy    // opts.Retry with defaults applied
+
+	progress progressReporter
+	report   *GenerationReport
+}
+
+// client returns the configured HTTP client, or a new one with the given timeout,
+// authenticated with the run's credentials
+func (g *generator) client(timeout time.Duration) *http.Client {
+	client := g.opts.HTTPClient
+	if client == nil {
+		transport := g.transport
+		if transport == nil {
+			transport = sharedTransport()
+		}
+		client = &http.Client{Timeout: timeout, Transport: transport}
+	}
+	client = withCredentials(client, g.creds)
+	if g.opts.UserAgent != "" || len(g.opts.Headers) > 0 {
+		client.Transport = &headerTransport{base: client.Transport, userAgent: g.opts.UserAgent, headers: g.opts.Headers}
+	}
+	if g.opts.Context != nil {
+		client.Transport = &contextTransport{base: client.Transport, ctx: g.opts.Context}
+	}
+	return g.runClient(client)
+}
+
+// runClient marks client as one of the run's, so requests made with it follow the
+// run's retry policy and reach the run's GitHub
+func (g *generator) runClient(client *http.Client) *http.Client {
+	client.Transport = &runTransport{base: client.Transport, ctx: g.context(), retry: g.retry, gitHub: g.creds.gitHub}
+	return client
+}
+
+// context returns the run's context, or a background context when none was set
+func (g *generator) context() context.Context {
+	if g.opts.Context != nil {
+		return g.opts.Context
+	}
+	return context.Background()
+}
+
+// include reports whether a repository passes the visibility, fork, archive, topic and name filters
+func (g *generator) include(name string, private, fork, archived bool, topics []string) bool {
+	privateFilter, forkFilter, archivedFilter := g.opts.filters()
+	if !privateFilter.allows(private) || !forkFilter.allows(fork) || !archivedFilter.allows(archived) {
+		return false
+	}
+	if hasTopic(topics, g.opts.ExcludeTopics) {
+		return false
+	}
+	if len(g.opts.IncludeTopics) > 0 && !hasTopic(topics, g.opts.IncludeTopics) {
+		return false
+	}
+	if g.nameRegex != nil && !g.nameRegex.MatchString(name) {
+		return false
+	}
+
+	name = strings.ToLower(name)
+	for _, pattern := range g.opts.Exclude {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return false
+		}
+	}
+	if len(g.opts.Include) == 0 {
+		return true
+	}
+	for _, pattern := range g.opts.Include {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTopic reports whether any of the repository's topics is in wanted
+func hasTopic(topics, wanted []string) bool {
+	for _, topic := range topics {
+		for _, w := range wanted {
+			if strings.EqualFold(topic, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Generate builds a code.gov JSON object from the configured GitHub organizations, GitLab groups
+// and registered providers such as Bitbucket.
+// Organizations that cannot be listed are logged and skipped; if none can be listed the
+// errors are returned, and can be matched with errors.Is against ErrOrgNotFound,
+// ErrUnauthorized, ErrForbidden and ErrRateLimited.
+//
+// Deprecated: use Generate in github.com/NSACodeGov/CodeGov/v2/codegov, which takes a context and
+// returns the report.
+func Generate(opts GenerateOptions) (*CodeGovJSON, error) {
+	codeGov, _, err := GenerateWithReport(opts)
+	return codeGov, err
+}
+
+// GenerateWithReport is Generate, also returning a report of the organizations and
+// repositories that were skipped or published with incomplete data. The report is
+// returned with the error when no organization can be listed.
+//
+// Deprecated: use Generate in github.com/NSACodeGov/CodeGov/v2/codegov, which takes a context.
+func GenerateWithReport(opts GenerateOptions) (*CodeGovJSON, *GenerationReport, error) {
+	if err := opts.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	report := &GenerationReport{Organizations: len(opts.Organizations)}
+	g := &generator{opts: opts, logger: opts.Logger, creds: opts.Credentials.resolve(), transport: opts.Transport, report: report}
+	g.creds.gitHub = gitHubAPI(opts.GitHub.BaseURI)
+	if g.transport == nil {
+		g.transport = sharedTransport()
+	}
+	if g.logger == nil {
+		g.logger = log.Default()
+	}
+	if opts.NameRegex != "" {
+		g.nameRegex = regexp.MustCompile(opts.NameRegex)
+	}
+	g.retry = getRetryPolicy()
+	if opts.Retry != (RetryPolicy{}) {
+		g.retry = opts.Retry.withDefaults()
+	}
+	g.progress.fn = opts.Progress
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultEnrichmentConcurrency
+	}
+
+	graphQL, err := useGitHubGraphQL(opts.GitHub.API, g.creds.hasGitHubAuth())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start := time.Now()
+	var jobs []enrichJob
+	var fetchErrs []error
+
+	// Listing is sequential; per-repository enrichment runs on the worker pool
+	for _, org := range opts.Organizations {
+		g.progress.report(Progress{Kind: ProgressOrgStarted, Org: org, Total: len(jobs)})
+		failed := func(err error) {
+			fetchErrs = append(fetchErrs, fmt.Errorf("%s: %w", org, err))
+			report.FailedOrganizations++
+			report.add(ReportIssue{Severity: SeverityError, Org: org, Stage: StageList, Err: err})
+			g.progress.report(Progress{Kind: ProgressOrgFailed, Org: org, Total: len(jobs), Err: err})
+		}
+
+		p, owner, ok := providerFor(org)
+		if !ok {
+			p, owner = gitHubProvider{graphQL: graphQL}, org
+		}
+		providerJobs, err := g.providerJobs(p, owner)
+		if err != nil {
+			g.logger.Printf("Error fetching repositories for %s: %v\n", org, err)
+			failed(err)
+			continue
+		}
+		jobs = append(jobs, providerJobs...)
+		g.progress.report(Progress{Kind: ProgressOrgListed, Org: org, Total: len(jobs)})
+	}
+
+	// An inventory with no reachable organization is never worth publishing
+	if len(fetchErrs) == len(opts.Organizations) {
+		err := errors.Join(fetchErrs...)
+		defaultMetrics.observeRun(time.Since(start), 0, err)
+		report.sort()
+		return nil, report, err
+	}
+
+	releases, buildErr := runEnrichment(jobs, concurrency, func(job string, done int, err error) {
+		kind := ProgressRepoDone
+		if err != nil {
+			kind = ProgressRepoFailed
+			report.add(ReportIssue{Severity: SeverityError, Repo: job, Stage: StageBuild, Err: err})
+		}
+		g.progress.report(Progress{Kind: kind, Repo: job, Done: done, Total: len(jobs), Err: err})
+	})
+	if buildErr != nil {
+		g.logger.Printf("Error building releases:\n%v\n", buildErr)
+	}
+
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Name < releases[j].Name
+	})
+
+	codeGov := &CodeGovJSON{
+		Version: "2.0",
+		Agency:  opts.Agency,
+		MeasurementType: MeasurementType{
+			Method: "projects",
+		},
+		Releases: releases,
+	}
+
+	defaultMetrics.observeRun(time.Since(start), len(releases), errors.Join(append(fetchErrs, buildErr)...))
+
+	report.Repositories = len(jobs)
+	report.Releases = len(releases)
+	if opts.CheckURLs {
+		g.checkLinks(codeGov)
+	}
+	if opts.DownloadDigests != "" {
+		g.takeDigests(codeGov)
+	}
+	// Tombstones come last: their links and downloads are expected to be gone
+	if opts.Previous != nil && opts.TombstoneDays > 0 {
+		g.keepTombstones(codeGov)
+	}
+	report.sort()
+
+	// A canceled run is incomplete, so it is never worth publishing either
+	if opts.Context != nil && opts.Context.Err() != nil {
+		return nil, report, opts.Context.Err()
+	}
+
+	return codeGov, report, nil
+}
+
+// GenerateFile generates code.gov JSON and writes it to outputPath, as YAML when the
+// path ends in .yaml or .yml
+//
+// Deprecated: use GenerateFile in github.com/NSACodeGov/CodeGov/v2/codegov, which takes a context and
+// returns the report.
+func GenerateFile(opts GenerateOptions, outputPath string) error {
+	_, err := GenerateFileWithReport(opts, outputPath)
+	return err
+}
+
+// GenerateFileWithReport is GenerateFile, also returning the run's GenerationReport
+//
+// Deprecated: use GenerateFile in github.com/NSACodeGov/CodeGov/v2/codegov, which takes a context.
+func GenerateFileWithReport(opts GenerateOptions, outputPath string) (*GenerationReport, error) {
+	codeGov, report, err := GenerateWithReport(opts)
+	if err != nil {
+		return report, err
+	}
+
+	if err := writeCodeGovJSONWithOptions(codeGov, outputPath, EncodeOptions{Compact: opts.Compact}); err != nil {
+		return report, err
+	}
+	if opts.DownloadDigests == DownloadDigestsSidecar {
+		return report, WriteDigestsFile(DigestsFile(outputPath), report.Digests)
+	}
+	return report, nil
+}
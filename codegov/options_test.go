@@ -0,0 +1,181 @@
+package codegov
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestGenerateOptionsValidate(t *testing.T) {
+	valid := GenerateOptions{
+		Organizations: []string{"org"},
+		Agency:        "NSA",
+		Contact:       Contact{Email: "oss@example.gov"},
+		Include:       []string{"org/*"},
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(o *GenerateOptions)
+		wantErr bool
+	}{
+		{"valid", func(o *GenerateOptions) {}, false},
+		{"no organizations", func(o *GenerateOptions) { o.Organizations = nil }, true},
+		{"no agency", func(o *GenerateOptions) { o.Agency = "" }, true},
+		{"no contact email", func(o *GenerateOptions) { o.Contact = Contact{Name: "OSS Team"} }, true},
+		{"bad include pattern", func(o *GenerateOptions) { o.Include = []string{"org/["} }, true},
+		{"bad exclude pattern", func(o *GenerateOptions) { o.Exclude = []string{"["} }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := valid
+			tt.modify(&opts)
+			if err := opts.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewCodeGovJSONRequiresEmail(t *testing.T) {
+	// The options are validated before any API request, so no server is needed
+	if _, err := NewCodeGovJSON([]string{"org"}, "NSA", "", nil, false, false); err == nil {
+		t.Error("expected an error for an empty agency email")
+	}
+}
+
+func TestGeneratorInclude(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     GenerateOptions
+		repo     string
+		archived bool
+		topics   []string
+		want     bool
+	}{
+		{"no filters", GenerateOptions{}, "org/api", false, nil, true},
+		{"exclude archived", GenerateOptions{Archived: RepoFilterExclude}, "org/api", true, nil, false},
+		{"archived kept by default", GenerateOptions{}, "org/api", true, nil, true},
+		{"include topic match", GenerateOptions{IncludeTopics: []string{"Cyber"}}, "org/api", false, []string{"cyber", "go"}, true},
+		{"include topic missing", GenerateOptions{IncludeTopics: []string{"cyber"}}, "org/api", false, []string{"go"}, false},
+		{"include topic without topics", GenerateOptions{IncludeTopics: []string{"cyber"}}, "org/api", false, nil, false},
+		{"exclude topic", GenerateOptions{ExcludeTopics: []string{"internal"}}, "org/api", false, []string{"go", "internal"}, false},
+		{"exclude wins over include", GenerateOptions{IncludeTopics: []string{"go"}, ExcludeTopics: []string{"internal"}}, "org/api", false, []string{"go", "internal"}, false},
+		{"name regex match", GenerateOptions{NameRegex: "^org/(api|web)$"}, "org/web", false, nil, true},
+		{"name regex miss", GenerateOptions{NameRegex: "^org/(api|web)$"}, "org/web-old", false, nil, false},
+		{"name regex and glob", GenerateOptions{NameRegex: "api", Exclude: []string{"org/*-old"}}, "org/api-old", false, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &generator{opts: tt.opts}
+			if tt.opts.NameRegex != "" {
+				g.nameRegex = regexp.MustCompile(tt.opts.NameRegex)
+			}
+			if got := g.include(tt.repo, false, false, tt.archived, tt.topics); got != tt.want {
+				t.Errorf("include(%q) = %v, want %v", tt.repo, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepoFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    GenerateOptions
+		private bool
+		fork    bool
+		want    bool
+	}{
+		{"public source by default", GenerateOptions{}, false, false, true},
+		{"private excluded by default", GenerateOptions{}, true, false, false},
+		{"fork excluded by default", GenerateOptions{}, false, true, false},
+		{"include private keeps public", GenerateOptions{Private: RepoFilterInclude}, false, false, true},
+		{"include private keeps private", GenerateOptions{Private: RepoFilterInclude}, true, false, true},
+		{"only private drops public", GenerateOptions{Private: RepoFilterOnly}, false, false, false},
+		{"only private keeps private", GenerateOptions{Private: RepoFilterOnly}, true, false, true},
+		{"only forks drops sources", GenerateOptions{Forks: RepoFilterOnly}, false, false, false},
+		{"private forks", GenerateOptions{Private: RepoFilterOnly, Forks: RepoFilterOnly}, true, true, true},
+		{"deprecated include private keeps public", GenerateOptions{IncludePrivate: true}, false, false, true},
+		{"deprecated include forks keeps forks", GenerateOptions{IncludeForks: true}, false, true, true},
+		{"filter overrides deprecated boolean", GenerateOptions{IncludePrivate: true, Private: RepoFilterExclude}, true, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &generator{opts: tt.opts}
+			if got := g.include("org/repo", tt.private, tt.fork, false, nil); got != tt.want {
+				t.Errorf("include(private=%v, fork=%v) = %v, want %v", tt.private, tt.fork, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRepoFilter(t *testing.T) {
+	for _, s := range []string{"exclude", "Include", " only "} {
+		if _, err := ParseRepoFilter(s); err != nil {
+			t.Errorf("ParseRepoFilter(%q): %v", s, err)
+		}
+	}
+	if _, err := ParseRepoFilter("maybe"); err == nil {
+		t.Error("expected error for an unknown filter")
+	}
+}
+
+func TestValidateNameRegex(t *testing.T) {
+	opts := GenerateOptions{
+		Organizations: []string{"org"},
+		Agency:        "NSA",
+		Contact:       Contact{Email: "oss@example.gov"},
+		NameRegex:     "org/(api",
+	}
+	if err := opts.validate(); err == nil {
+		t.Error("expected error for an invalid name regex")
+	}
+}
+
+func TestPermissionsUsageType(t *testing.T) {
+	lic := License{URL: "https://example.gov/LICENSE", Name: "MIT"}
+
+	g := &generator{opts: GenerateOptions{ExemptionText: "Unused without an exempt type"}}
+	if p := g.permissions([]License{lic}, false); p.UsageType != UsageTypeOpenSource || p.ExemptionText != "" {
+		t.Errorf("unexpected public permissions %+v", p)
+	}
+	if p := g.permissions([]License{lic}, true); p.UsageType != UsageTypeGovernmentWideReuse || p.ExemptionText != "" {
+		t.Errorf("unexpected private permissions %+v", p)
+	}
+
+	g.opts.PrivateUsageType = UsageTypeExemptByNationalSecurity
+	g.opts.ExemptionText = "Release would disclose classified capabilities."
+	if p := g.permissions([]License{lic}, true); p.UsageType != UsageTypeExemptByNationalSecurity || p.ExemptionText != g.opts.ExemptionText {
+		t.Errorf("unexpected exempt permissions %+v", p)
+	}
+
+	// Repository metadata can lift the exemption, dropping the justification
+	release := Release{Permissions: g.permissions([]License{lic}, true)}
+	(&RepoMetadata{UsageType: UsageTypeGovernmentWideReuse}).Apply(&release)
+	if release.Permissions.ExemptionText != "" {
+		t.Errorf("exemption text kept for %s", release.Permissions.UsageType)
+	}
+}
+
+func TestValidateUsageType(t *testing.T) {
+	opts := GenerateOptions{
+		Organizations:    []string{"org"},
+		Agency:           "NSA",
+		Contact:          Contact{Email: "oss@example.gov"},
+		PrivateUsageType: "closedSource",
+	}
+	if err := opts.validate(); err == nil {
+		t.Error("expected error for an unknown usage type")
+	}
+
+	opts.PrivateUsageType = UsageTypeExemptByCIO
+	if err := opts.validate(); err == nil {
+		t.Error("expected error for an exempt usage type without exemption text")
+	}
+	opts.ExemptionText = "Approved by the CIO."
+	if err := opts.validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package codegov
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DefaultEnrichmentConcurrency is the number of repositories a run enriches in parallel
+// when GenerateOptions.Concurrency is unset
+const DefaultEnrichmentConcurrency = 8
+
+// enrichJob builds the release for a single repository
+type enrichJob struct {
+	name  string // org/repo, used in error messages
+	build func() (Release, error)
+}
+
+// runEnrichment runs jobs on a bounded worker pool. Releases are returned in job
+// order; failed jobs are skipped and their errors joined into the returned error.
+// When set, finished is called after each job with the number of jobs finished so
+// far; calls are not concurrent.
+func runEnrichment(jobs []enrichJob, concurrency int, finished func(job string, done int, err error)) ([]Release, error) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if concurrency > len(jobs) {
+		concurrency = len(jobs)
+	}
+
+	results := make([]Release, len(jobs))
+	errs := make([]error, len(jobs))
+
+	var mu sync.Mutex
+	done := 0
+	report := func(i int, err error) {
+		if finished == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		finished(jobs[i].name, done, err)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				release, err := jobs[i].build()
+				if err != nil {
+					errs[i] = fmt.Errorf("%s: %w", jobs[i].name, err)
+					report(i, err)
+					continue
+				}
+				results[i] = release
+				report(i, nil)
+			}
+		}()
+	}
+
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	releases := make([]Release, 0, len(jobs))
+	for i := range jobs {
+		if errs[i] == nil {
+			releases = append(releases, results[i])
+		}
+	}
+
+	return releases, errors.Join(errs...)
+}
@@ -0,0 +1,122 @@
+package codegov
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunEnrichmentConcurrencyLimit(t *testing.T) {
+	const concurrency = 3
+
+	var running, peak int32
+	release := make(chan struct{})
+	jobs := make([]enrichJob, 10)
+	for i := range jobs {
+		jobs[i] = enrichJob{name: fmt.Sprintf("org/repo%d", i), build: func() (Release, error) {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			<-release
+			return Release{}, nil
+		}}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runEnrichment(jobs, concurrency, nil)
+	}()
+
+	// Wait for the pool to fill, then give extra workers a chance to show up
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&running) < concurrency && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	<-done
+
+	if got := atomic.LoadInt32(&peak); got != concurrency {
+		t.Errorf("peak concurrency = %d, want %d", got, concurrency)
+	}
+}
+
+func TestRunEnrichmentOrderAndErrors(t *testing.T) {
+	// Later jobs finish first, and every third one fails
+	jobs := make([]enrichJob, 9)
+	for i := range jobs {
+		i := i
+		jobs[i] = enrichJob{name: fmt.Sprintf("org/repo%d", i), build: func() (Release, error) {
+			time.Sleep(time.Duration(len(jobs)-i) * time.Millisecond)
+			if i%3 == 0 {
+				return Release{}, fmt.Errorf("build failed")
+			}
+			return Release{Name: fmt.Sprintf("repo%d", i)}, nil
+		}}
+	}
+
+	var mu sync.Mutex
+	var calls []int
+	failed := map[string]error{}
+	releases, err := runEnrichment(jobs, 4, func(job string, done int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, done)
+		if err != nil {
+			failed[job] = err
+		}
+	})
+
+	var names []string
+	for _, r := range releases {
+		names = append(names, r.Name)
+	}
+	if got, want := strings.Join(names, ","), "repo1,repo2,repo4,repo5,repo7,repo8"; got != want {
+		t.Errorf("releases = %s, want %s in job order", got, want)
+	}
+
+	if err == nil {
+		t.Fatal("expected the failed jobs' errors")
+	}
+	for _, name := range []string{"org/repo0", "org/repo3", "org/repo6"} {
+		if !strings.Contains(err.Error(), name+": build failed") {
+			t.Errorf("error %q does not name %s", err, name)
+		}
+		if failed[name] == nil {
+			t.Errorf("finished was not called with %s's error", name)
+		}
+	}
+	if len(failed) != 3 {
+		t.Errorf("finished reported %d failures, want 3", len(failed))
+	}
+	for i, done := range calls {
+		if done != i+1 {
+			t.Fatalf("finished done counts = %v, want 1 to %d in order", calls, len(jobs))
+		}
+	}
+	if len(calls) != len(jobs) {
+		t.Errorf("finished called %d times, want %d", len(calls), len(jobs))
+	}
+}
+
+func TestRunEnrichmentWithoutJobs(t *testing.T) {
+	releases, err := runEnrichment(nil, DefaultEnrichmentConcurrency, nil)
+	if len(releases) != 0 || err != nil {
+		t.Errorf("runEnrichment(nil) = %v, %v", releases, err)
+	}
+	if _, err := runEnrichment([]enrichJob{{name: "org/a", build: func() (Release, error) {
+		return Release{}, errors.New("boom")
+	}}}, 0, nil); err == nil || !strings.Contains(err.Error(), "org/a: boom") {
+		t.Errorf("runEnrichment with concurrency 0 = %v, want the job's error", err)
+	}
+}
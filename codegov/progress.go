@@ -0,0 +1,44 @@
+package codegov
+
+import "sync"
+
+// ProgressKind identifies a step of a generation run
+type ProgressKind string
+
+const (
+	ProgressOrgStarted ProgressKind = "org_started" // Listing an organization began
+	ProgressOrgListed  ProgressKind = "org_listed"  // An organization was listed; Total includes its repositories
+	ProgressOrgFailed  ProgressKind = "org_failed"  // An organization could not be listed; Err says why
+	ProgressRepoDone   ProgressKind = "repo_done"   // A repository's release was built
+	ProgressRepoFailed ProgressKind = "repo_failed" // A repository's release could not be built; Err says why
+)
+
+// Progress reports a step of a generation run. Organizations are listed first, so
+// Total only grows during listing and is final once repositories are processed.
+type Progress struct {
+	Kind  ProgressKind
+	Org   string // Organization as given in GenerateOptions.Organizations
+	Repo  string // "org/repo" for repository events
+	Done  int    // Repositories processed so far, including failed ones
+	Total int    // Repositories selected so far
+	Err   error
+}
+
+// ProgressFunc receives progress during a generation run. Calls are serialized but
+// may come from different goroutines; the function should return quickly.
+type ProgressFunc func(Progress)
+
+// progressReporter serializes calls to an optional ProgressFunc
+type progressReporter struct {
+	mu sync.Mutex
+	fn ProgressFunc
+}
+
+func (r *progressReporter) report(p Progress) {
+	if r.fn == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fn(p)
+}
@@ -0,0 +1,294 @@
+package codegov
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProviderRepository is a repository as reported by a hosting provider
+type ProviderRepository struct {
+	ID            string // Provider path, e.g. "workspace/repo" or "PROJECT/repo"
+	Name          string
+	Description   string
+	WebURL        string
+	CloneURL      string // HTTPS clone URL used for deep analysis
+	DefaultBranch string
+	Private       bool
+	Fork          bool
+	Archived      bool
+	Topics        []string
+	Created       time.Time
+	Updated       time.Time
+
+	Owner           string    // Organization published on the release; the listed owner when empty
+	Homepage        string    // Project homepage; WebURL when empty
+	MetadataUpdated time.Time // Last change to the repository's settings; Updated when zero
+
+	details interface{} // Data the provider fetched while listing, for its other methods
+}
+
+// Provider is a source-code hosting service that repositories can be inventoried from.
+// Organizations are routed to a provider by prefix, e.g. "bitbucket:workspace"; those
+// without a registered prefix are GitHub organizations.
+type Provider interface {
+	// ListRepos returns every repository owned by an organization, workspace or project
+	ListRepos(client *http.Client, owner string) ([]ProviderRepository, error)
+	// Languages returns the repository's languages, sorted
+	Languages(client *http.Client, repo ProviderRepository) ([]string, error)
+	// License returns the repository's license; Name is empty when it cannot be identified
+	License(client *http.Client, repo ProviderRepository) (*License, error)
+	// Releases returns the download URL of the latest release, or of the default branch when there is none
+	Releases(client *http.Client, repo ProviderRepository) (string, error)
+	// FileURL returns the web URL of a well-known file such as DISCLAIMER, or "" if it does not exist
+	FileURL(client *http.Client, repo ProviderRepository, name string) string
+}
+
+// ProviderVersioner is implemented by providers that can list a repository's tags and
+// default branch commit, used to version repositories without a release
+type ProviderVersioner interface {
+	// Tags returns the names of the repository's tags, most recent first
+	Tags(client *http.Client, repo ProviderRepository) ([]string, error)
+	// BranchSHA returns the commit SHA at the head of the default branch
+	BranchSHA(client *http.Client, repo ProviderRepository) (string, error)
+}
+
+// ProviderReleaser is implemented by providers whose releases are tagged. It is used
+// instead of Releases, so the latest release's tag versions the inventory entry.
+type ProviderReleaser interface {
+	// LatestRelease returns the download URL of the latest published release and its tag,
+	// or the default branch's download URL and an empty tag when there is none
+	LatestRelease(client *http.Client, repo ProviderRepository) (downloadURL, tag string, err error)
+}
+
+// ProviderCodeSizer is implemented by providers that report how much code a repository
+// holds, used to estimate labor hours without deep analysis. It is used instead of Languages.
+type ProviderCodeSizer interface {
+	// LanguageSizes returns the repository's languages, as Languages does, and the bytes of code across them
+	LanguageSizes(client *http.Client, repo ProviderRepository) ([]string, int64, error)
+}
+
+// ProviderRootLister is implemented by providers that can list the root of a repository's
+// default branch. Every license file found there is reported, so dual-licensed projects
+// with LICENSE-MIT and LICENSE-APACHE, or COPYING and COPYING.LESSER, list both; FileURL
+// must accept the exact names listed.
+type ProviderRootLister interface {
+	// ListRoot returns the names of the files in the repository root, sorted
+	ListRoot(client *http.Client, repo ProviderRepository) ([]string, error)
+}
+
+// registeredProvider is a provider and the organization prefix routed to it
+type registeredProvider struct {
+	prefix   string
+	provider Provider
+}
+
+var (
+	providersMu sync.RWMutex
+	// providers is ordered by descending prefix length, so the most specific prefix wins
+	providers = []registeredProvider{
+		{AzureDevOpsOrgPrefix, azureDevOpsProvider{}},
+		{BitbucketOrgPrefix, bitbucketProvider{}},
+		{GitLabOrgPrefix, gitLabProvider{}},
+	}
+)
+
+// RegisterProvider routes organizations starting with prefix (e.g. "gitea:") to p,
+// replacing any provider registered for the same prefix. When prefixes overlap, the
+// longest matching prefix selects the provider.
+func RegisterProvider(prefix string, p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	for i := range providers {
+		if providers[i].prefix == prefix {
+			providers[i].provider = p
+			return
+		}
+	}
+	providers = append(providers, registeredProvider{prefix, p})
+	sort.SliceStable(providers, func(i, j int) bool {
+		return len(providers[i].prefix) > len(providers[j].prefix)
+	})
+}
+
+// providerFor returns the registered provider for an organization and the owner name
+// without its prefix
+func providerFor(org string) (Provider, string, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	for _, registered := range providers {
+		if owner, ok := strings.CutPrefix(org, registered.prefix); ok {
+			return registered.provider, owner, true
+		}
+	}
+	return nil, "", false
+}
+
+// providerJobs lists an owner on a provider and returns an enrichment job for every matching repository
+func (g *generator) providerJobs(p Provider, owner string) ([]enrichJob, error) {
+	repos, err := p.ListRepos(g.client(30*time.Second), owner)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []enrichJob
+	for _, repo := range repos {
+		if !g.include(repo.ID, repo.Private, repo.Fork, repo.Archived, repo.Topics) {
+			continue
+		}
+
+		repo := repo
+		jobs = append(jobs, enrichJob{
+			name: repo.ID,
+			build: func() (Release, error) {
+				return g.buildProviderRelease(p, owner, repo)
+			},
+		})
+	}
+
+	return jobs, nil
+}
+
+// buildProviderRelease builds the release of a repository listed by a provider
+func (g *generator) buildProviderRelease(p Provider, owner string, repo ProviderRepository) (Release, error) {
+	var languages []string
+	var codeBytes int64
+	var err error
+	if sizer, ok := p.(ProviderCodeSizer); ok {
+		languages, codeBytes, err = sizer.LanguageSizes(g.client(10*time.Second), repo)
+	} else {
+		languages, err = p.Languages(g.client(10*time.Second), repo)
+	}
+	if err != nil {
+		g.enrichmentError(repo.ID, "languages", err)
+	}
+
+	laborHours := 1.0
+	if !g.opts.SkipLaborEstimate {
+		if hours := EstimateLaborHoursFromBytes(codeBytes); hours > 0 {
+			laborHours = hours
+		}
+	}
+	if analyzer := getAnalyzer(); analyzer != nil && repo.CloneURL != "" {
+		analysis, err := analyzer.analyze(g.context(), repo.CloneURL, repo.DefaultBranch, repo.Updated.Format(time.RFC3339), g.creds)
+		if err != nil {
+			g.logger.Printf("Error analyzing %s: %v\n", repo.ID, err)
+			g.enrichmentError(repo.ID, "analysis", err)
+		} else {
+			if names := analysis.LanguageNames(); len(names) > 0 {
+				languages = names
+			}
+			if hours := EstimateLaborHours(analysis.TotalCode); hours > 0 {
+				laborHours = hours
+			}
+		}
+	}
+
+	lic, err := p.License(g.client(10*time.Second), repo)
+	if err != nil || lic == nil {
+		if err == nil {
+			err = errors.New("no license found")
+		}
+		g.enrichmentError(repo.ID, "license", err)
+		lic = &License{}
+	}
+
+	readFile := g.providerFileReader(p, repo)
+	licenses := []License{*lic}
+	if lister, ok := p.(ProviderRootLister); ok {
+		names, err := lister.ListRoot(g.client(10*time.Second), repo)
+		if err != nil {
+			g.enrichmentError(repo.ID, "license", err)
+		}
+		fileURL := func(name string) string {
+			return p.FileURL(g.client(10*time.Second), repo, name)
+		}
+		licenses = g.collectLicenses(repo.ID, *lic, names, readFile, fileURL)
+	}
+
+	var downloadURL, releaseTag string
+	if releaser, ok := p.(ProviderReleaser); ok {
+		downloadURL, releaseTag, err = releaser.LatestRelease(g.client(10*time.Second), repo)
+	} else {
+		downloadURL, err = p.Releases(g.client(10*time.Second), repo)
+	}
+	if err != nil {
+		g.enrichmentError(repo.ID, "release", err)
+	}
+	if downloadURL == "" {
+		downloadURL = repo.WebURL
+	}
+
+	if repo.Updated.IsZero() {
+		return Release{}, fmt.Errorf("%s: provider reported no modification date", repo.ID)
+	}
+	created := repo.Created
+	if created.IsZero() {
+		created = repo.Updated
+	}
+	metadataUpdated := repo.MetadataUpdated
+	if metadataUpdated.IsZero() {
+		metadataUpdated = repo.Updated
+	}
+
+	version := releaseTag
+	if v, ok := p.(ProviderVersioner); ok {
+		tagNames := func() ([]string, error) { return v.Tags(g.client(10*time.Second), repo) }
+		sha := func() (string, error) { return v.BranchSHA(g.client(10*time.Second), repo) }
+		version = g.releaseVersion(repo.ID, releaseTag, tagNames, sha)
+	}
+
+	description := g.describe(repo.ID, repo.Description, readFile)
+
+	tags := repo.Topics
+	if len(tags) == 0 {
+		tags = []string{"none"}
+	}
+
+	status := "Production"
+	if repo.Archived {
+		status = "Archival"
+	}
+
+	if repo.Owner != "" {
+		owner = repo.Owner
+	}
+	homepageURL := repo.Homepage
+	if homepageURL == "" {
+		homepageURL = repo.WebURL
+	}
+
+	release := Release{
+		Name:           repo.Name,
+		Version:        version,
+		Organization:   g.organization(owner),
+		RepositoryURL:  repo.WebURL,
+		Description:    description,
+		Permissions:    g.permissions(licenses, repo.Private),
+		LaborHours:     laborHours,
+		Tags:           tags,
+		Contact:        g.opts.Contact,
+		Status:         status,
+		VCS:            "git",
+		HomepageURL:    homepageURL,
+		DownloadURL:    downloadURL,
+		Languages:      languages,
+		DisclaimerURL:  p.FileURL(g.client(10*time.Second), repo, "DISCLAIMER"),
+		DisclaimerText: g.opts.DisclaimerText,
+		Date: DateInfo{
+			Created:             created.Format("2006-01-02"),
+			LastModified:        repo.Updated.Format("2006-01-02"),
+			MetadataLastUpdated: metadataUpdated.Format("2006-01-02"),
+		},
+	}
+
+	g.applyRepoMetadata(&release, repo.ID, readFile)
+
+	return release, nil
+}
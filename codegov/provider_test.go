@@ -0,0 +1,37 @@
+package codegov
+
+import "testing"
+
+func TestProviderForLongestPrefix(t *testing.T) {
+	providersMu.Lock()
+	saved := append([]registeredProvider(nil), providers...)
+	providersMu.Unlock()
+	defer func() {
+		providersMu.Lock()
+		providers = saved
+		providersMu.Unlock()
+	}()
+
+	// Registered after the shorter "gitlab:" prefix, yet still preferred for its organizations
+	RegisterProvider("gitlab:mirror:", bitbucketProvider{})
+
+	for i := 0; i < 20; i++ {
+		p, owner, ok := providerFor("gitlab:mirror:group")
+		if _, mirror := p.(bitbucketProvider); !ok || !mirror || owner != "group" {
+			t.Fatalf("expected the longest prefix to win, got %T %q", p, owner)
+		}
+		p, owner, ok = providerFor("gitlab:group")
+		if _, gitLab := p.(gitLabProvider); !ok || !gitLab || owner != "group" {
+			t.Fatalf("expected the GitLab provider, got %T %q", p, owner)
+		}
+	}
+
+	RegisterProvider("gitlab:mirror:", azureDevOpsProvider{})
+	if p, _, _ := providerFor("gitlab:mirror:group"); p != (azureDevOpsProvider{}) {
+		t.Errorf("expected re-registration to replace the provider, got %T", p)
+	}
+
+	if _, _, ok := providerFor("agency"); ok {
+		t.Error("an organization without a prefix should be left to GitHub")
+	}
+}
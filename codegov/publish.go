@@ -0,0 +1,518 @@
+package codegov
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/internal/sigv4"
+)
+
+// Environment variables read when S3Config leaves credentials empty
+const (
+	AWSAccessKeyEnv    = "AWS_ACCESS_KEY_ID"
+	AWSSecretKeyEnv    = "AWS_SECRET_ACCESS_KEY"
+	AWSSessionTokenEnv = "AWS_SESSION_TOKEN"
+	AWSRegionEnv       = "AWS_REGION"
+)
+
+// S3Config locates an S3-compatible object store such as AWS S3 or MinIO
+type S3Config struct {
+	Endpoint     string // host[:port]; defaults to s3.<region>.amazonaws.com
+	AccessKey    string // Defaults to $AWS_ACCESS_KEY_ID
+	SecretKey    string // Defaults to $AWS_SECRET_ACCESS_KEY
+	SessionToken string // Defaults to $AWS_SESSION_TOKEN
+	Region       string // Defaults to $AWS_REGION, then us-east-1
+	Insecure     bool   // Use plain HTTP, e.g. for a local MinIO
+}
+
+// GitHubPagesConfig configures commits to a GitHub Pages branch. Files are committed
+// through the GitHub API configured with SetGitHubConfig, so GitHub Enterprise Server
+// works the same way.
+type GitHubPagesConfig struct {
+	Branch      string      // Defaults to gh-pages
+	Message     string      // Commit message; defaults to "Publish <name>"
+	Credentials Credentials // Needs contents write access; empty fields fall back to OAUTH_TOKEN
+}
+
+// PublishOptions configures where generated files are published
+type PublishOptions struct {
+	// Destination is a directory URL: s3://bucket/prefix (minio:// is an alias),
+	// https://host/path/ for HTTP PUT, sftp://user@host[:port]/path, or
+	// gh-pages://owner/repo/path for a commit to a GitHub Pages branch
+	Destination string
+
+	// CacheControl is sent with S3 and HTTPS uploads, e.g. "max-age=300" so code.gov
+	// and CDNs pick up a new inventory within minutes; GitHub Pages sets its own
+	CacheControl string
+
+	S3         S3Config
+	GitHub     GitHubPagesConfig
+	Headers    map[string]string // Extra headers for HTTPS uploads, e.g. Authorization
+	SFTPPath   string            // sftp binary; defaults to "sftp" on PATH
+	HTTPClient *http.Client      // Defaults to a client with a one minute timeout
+	Logger     *log.Logger       // Defaults to log.Default()
+}
+
+// PublishedFile describes an uploaded and verified file
+type PublishedFile struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// publisher uploads a file to a destination and reads it back for verification
+type publisher interface {
+	put(name string, data []byte, contentType string) (string, error)
+	get(name string) ([]byte, error)
+}
+
+// Publish uploads files, e.g. code.json and its reports, to the destination. Each
+// file gets a "<name>.sha256" sidecar, and is downloaded again after upload so a
+// truncated or altered copy is reported as an error rather than harvested.
+func Publish(opts PublishOptions, files ...string) ([]PublishedFile, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files to publish")
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	p, err := newPublisher(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var published []PublishedFile
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return published, err
+		}
+
+		name := filepath.Base(file)
+		sum := sha256.Sum256(data)
+		checksum := hex.EncodeToString(sum[:])
+
+		location, err := p.put(name, data, publishContentType(name))
+		if err != nil {
+			return published, fmt.Errorf("failed to upload %s: %w", name, err)
+		}
+		if _, err := p.put(name+".sha256", []byte(checksum+"  "+name+"\n"), "text/plain"); err != nil {
+			return published, fmt.Errorf("failed to upload checksum for %s: %w", name, err)
+		}
+
+		remote, err := p.get(name)
+		if err != nil {
+			return published, fmt.Errorf("failed to verify %s: %w", name, err)
+		}
+		if remoteSum := sha256.Sum256(remote); remoteSum != sum {
+			return published, fmt.Errorf("checksum mismatch for %s: uploaded %s, published copy is %s",
+				name, checksum, hex.EncodeToString(remoteSum[:]))
+		}
+
+		logger.Printf("Published %s (sha256 %s)\n", location, checksum)
+		published = append(published, PublishedFile{Name: name, URL: location, SHA256: checksum})
+	}
+
+	return published, nil
+}
+
+func newPublisher(opts PublishOptions) (publisher, error) {
+	u, err := url.Parse(opts.Destination)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination: %w", err)
+	}
+
+	client := opts.HTTPClient
+	if client == nil {
+		client = newHTTPClient(time.Minute)
+	}
+
+	switch u.Scheme {
+	case "s3", "minio":
+		if u.Host == "" {
+			return nil, fmt.Errorf("destination must be %s://bucket/prefix", u.Scheme)
+		}
+		p := newS3Publisher(opts.S3, u.Host, strings.Trim(u.Path, "/"), client)
+		p.cacheControl = opts.CacheControl
+		return p, nil
+	case "https", "http":
+		return &httpPublisher{base: strings.TrimRight(opts.Destination, "/"), headers: opts.Headers, cacheControl: opts.CacheControl, client: client}, nil
+	case "gh-pages":
+		repo, dir, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+		if u.Host == "" || repo == "" {
+			return nil, fmt.Errorf("destination must be gh-pages://owner/repo[/path]")
+		}
+		branch := opts.GitHub.Branch
+		if branch == "" {
+			branch = "gh-pages"
+		}
+		return &gitHubPagesPublisher{
+			repo:    GetGitHubBaseURI() + "/repos/" + url.PathEscape(u.Host) + "/" + url.PathEscape(repo),
+			dir:     dir,
+			branch:  branch,
+			message: opts.GitHub.Message,
+			client:  WithCredentials(client, opts.GitHub.Credentials),
+		}, nil
+	case "sftp":
+		binary := opts.SFTPPath
+		if binary == "" {
+			binary = "sftp"
+		}
+		return &sftpPublisher{binary: binary, target: u}, nil
+	}
+	return nil, fmt.Errorf("unsupported destination scheme %q (expected s3, minio, https, sftp or gh-pages)", u.Scheme)
+}
+
+func publishContentType(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		return "application/json"
+	case ".html":
+		return "text/html; charset=utf-8"
+	case ".csv":
+		return "text/csv"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// s3Publisher uploads with SigV4-signed PUTs. The signed payload hash and
+// Content-MD5 let the store reject a corrupted upload outright.
+type s3Publisher struct {
+	endpoint     string
+	scheme       string
+	bucket       string
+	prefix       string
+	creds        sigv4.Credentials
+	token        string
+	cacheControl string
+	client       *http.Client
+}
+
+func newS3Publisher(config S3Config, bucket, prefix string, client *http.Client) *s3Publisher {
+	envOr := func(value, env string) string {
+		if value != "" {
+			return value
+		}
+		return os.Getenv(env)
+	}
+
+	region := envOr(config.Region, AWSRegionEnv)
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = "s3." + region + ".amazonaws.com"
+	}
+	scheme := "https"
+	if config.Insecure {
+		scheme = "http"
+	}
+
+	return &s3Publisher{
+		endpoint: endpoint,
+		scheme:   scheme,
+		bucket:   bucket,
+		prefix:   prefix,
+		creds: sigv4.Credentials{
+			AccessKey: envOr(config.AccessKey, AWSAccessKeyEnv),
+			SecretKey: envOr(config.SecretKey, AWSSecretKeyEnv),
+			Region:    region,
+		},
+		token:  envOr(config.SessionToken, AWSSessionTokenEnv),
+		client: client,
+	}
+}
+
+func (p *s3Publisher) objectURL(name string) string {
+	key := path.Join(p.prefix, name)
+	return p.scheme + "://" + p.endpoint + "/" + sigv4.URIEncode(p.bucket, false) + "/" + sigv4.URIEncode(key, false)
+}
+
+func (p *s3Publisher) do(method, name string, body []byte, header http.Header) ([]byte, error) {
+	req, err := http.NewRequest(method, p.objectURL(name), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if p.token != "" {
+		req.Header.Set("X-Amz-Security-Token", p.token)
+	}
+	if p.creds.AccessKey != "" {
+		p.creds.Sign(req, sigv4.PayloadHash(body), time.Now())
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s returned status %d: %s", method, sanitizeURL(req.URL), resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+func (p *s3Publisher) put(name string, data []byte, contentType string) (string, error) {
+	sum := md5.Sum(data)
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	if p.cacheControl != "" {
+		header.Set("Cache-Control", p.cacheControl)
+	}
+
+	if _, err := p.do(http.MethodPut, name, data, header); err != nil {
+		return "", err
+	}
+	return "s3://" + p.bucket + "/" + path.Join(p.prefix, name), nil
+}
+
+func (p *s3Publisher) get(name string) ([]byte, error) {
+	return p.do(http.MethodGet, name, nil, nil)
+}
+
+// httpPublisher uploads with HTTP PUT, e.g. to a WebDAV share or the web server code.gov harvests from
+type httpPublisher struct {
+	base         string
+	headers      map[string]string
+	cacheControl string
+	client       *http.Client
+}
+
+func (p *httpPublisher) do(method, name string, body []byte, contentType string) ([]byte, error) {
+	uri := p.base + "/" + url.PathEscape(name)
+	req, err := http.NewRequest(method, uri, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	setClientHeaders(req)
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+	if method == http.MethodPut {
+		sum := sha256.Sum256(body)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Digest", "sha-256="+base64.StdEncoding.EncodeToString(sum[:]))
+		if p.cacheControl != "" {
+			req.Header.Set("Cache-Control", p.cacheControl)
+		}
+	} else {
+		req.Header.Set("Cache-Control", "no-cache")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s returned status %d", method, sanitizeURL(req.URL), resp.StatusCode)
+	}
+	return data, nil
+}
+
+func (p *httpPublisher) put(name string, data []byte, contentType string) (string, error) {
+	if _, err := p.do(http.MethodPut, name, data, contentType); err != nil {
+		return "", err
+	}
+	return p.base + "/" + url.PathEscape(name), nil
+}
+
+func (p *httpPublisher) get(name string) ([]byte, error) {
+	return p.do(http.MethodGet, name, nil, "")
+}
+
+// sftpPublisher drives the system sftp client in batch mode, so SSH keys, agents
+// and known_hosts are handled exactly as for interactive use
+type sftpPublisher struct {
+	binary string
+	target *url.URL
+}
+
+func (p *sftpPublisher) run(commands string) error {
+	args := []string{"-b", "-", "-o", "BatchMode=yes"}
+	if port := p.target.Port(); port != "" {
+		args = append(args, "-P", port)
+	}
+	host := p.target.Hostname()
+	if p.target.User != nil {
+		host = p.target.User.Username() + "@" + host
+	}
+	args = append(args, host)
+
+	cmd := exec.Command(p.binary, args...)
+	cmd.Stdin = strings.NewReader(commands)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("sftp failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (p *sftpPublisher) remotePath(name string) string {
+	return path.Join(p.target.Path, name)
+}
+
+func (p *sftpPublisher) put(name string, data []byte, contentType string) (string, error) {
+	local, err := os.CreateTemp("", "codegov-publish-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(local.Name())
+
+	_, err = local.Write(data)
+	if closeErr := local.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Upload under a temporary name and rename, so harvesters never see a partial file
+	remote := p.remotePath(name)
+	commands := fmt.Sprintf("put %s %s\n-rm %s\nrename %s %s\n",
+		sftpQuote(local.Name()), sftpQuote(remote+".part"), sftpQuote(remote), sftpQuote(remote+".part"), sftpQuote(remote))
+	if err := p.run(commands); err != nil {
+		return "", err
+	}
+
+	location := *p.target
+	location.Path = remote
+	return location.String(), nil
+}
+
+func (p *sftpPublisher) get(name string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "codegov-verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	local := filepath.Join(dir, name)
+	if err := p.run(fmt.Sprintf("get %s %s\n", sftpQuote(p.remotePath(name)), sftpQuote(local))); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(local)
+}
+
+// sftpQuote quotes a path for an sftp batch file
+func sftpQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// gitHubPagesPublisher commits files to a GitHub Pages branch through the contents
+// API. Each file is one commit that replaces the previous version, so the branch,
+// and the site built from it, never holds a partial file.
+type gitHubPagesPublisher struct {
+	repo    string // API URL of the repository
+	dir     string
+	branch  string
+	message string
+	client  *http.Client
+}
+
+func (p *gitHubPagesPublisher) contentsURL(name string) string {
+	var segments []string
+	for _, segment := range strings.Split(path.Join(p.dir, name), "/") {
+		segments = append(segments, url.PathEscape(segment))
+	}
+	return p.repo + "/contents/" + strings.Join(segments, "/") + "?ref=" + url.QueryEscape(p.branch)
+}
+
+func (p *gitHubPagesPublisher) do(method, uri string, body interface{}, accept string) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, uri, reader)
+	if err != nil {
+		return nil, err
+	}
+	setClientHeaders(req)
+	req.Header.Set("Accept", accept)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newAPIError(resp, nil)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (p *gitHubPagesPublisher) put(name string, data []byte, contentType string) (string, error) {
+	// Replacing a file needs the blob SHA of the current version
+	var current struct {
+		SHA string `json:"sha"`
+	}
+	existing, err := p.do(http.MethodGet, p.contentsURL(name), nil, "application/vnd.github+json")
+	var apiErr *APIError
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(existing, &current); err != nil {
+			return "", err
+		}
+	case !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound:
+		return "", err
+	}
+
+	message := p.message
+	if message == "" {
+		message = "Publish " + name
+	}
+	commit := map[string]string{
+		"message": message,
+		"content": base64.StdEncoding.EncodeToString(data),
+		"branch":  p.branch,
+	}
+	if current.SHA != "" {
+		commit["sha"] = current.SHA
+	}
+	uri, _, _ := strings.Cut(p.contentsURL(name), "?")
+	if _, err := p.do(http.MethodPut, uri, commit, "application/vnd.github+json"); err != nil {
+		return "", err
+	}
+	return uri + "?ref=" + url.QueryEscape(p.branch), nil
+}
+
+func (p *gitHubPagesPublisher) get(name string) ([]byte, error) {
+	return p.do(http.MethodGet, p.contentsURL(name), nil, "application/vnd.github.raw")
+}
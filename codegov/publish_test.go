@@ -0,0 +1,222 @@
+package codegov
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// objectStore is a minimal in-memory PUT/GET server
+type objectStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	headers map[string]http.Header
+	corrupt bool
+}
+
+func (s *objectStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		if md5Header := r.Header.Get("Content-MD5"); md5Header != "" {
+			sum := md5.Sum(data)
+			if md5Header != base64.StdEncoding.EncodeToString(sum[:]) {
+				http.Error(w, "BadDigest", http.StatusBadRequest)
+				return
+			}
+		}
+		s.objects[r.URL.Path] = data
+		s.headers[r.URL.Path] = r.Header.Clone()
+	case http.MethodGet:
+		data, ok := s.objects[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if s.corrupt {
+			data = data[:len(data)/2]
+		}
+		w.Write(data)
+	}
+}
+
+func writePublishFixture(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "code.json")
+	if err := writeCodeGovJSON(patchFixture(), path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPublishS3(t *testing.T) {
+	store := &objectStore{objects: map[string][]byte{}, headers: map[string]http.Header{}}
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+
+	file := writePublishFixture(t)
+	published, err := Publish(PublishOptions{
+		Destination: "minio://inventory/agency/2026",
+		S3: S3Config{
+			Endpoint:  strings.TrimPrefix(srv.URL, "http://"),
+			AccessKey: "minio",
+			SecretKey: "minio123",
+			Insecure:  true,
+		},
+	}, file)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	if len(published) != 1 || published[0].URL != "s3://inventory/agency/2026/code.json" {
+		t.Fatalf("unexpected result %+v", published)
+	}
+	if !strings.HasPrefix(store.headers["/inventory/agency/2026/code.json"].Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") {
+		t.Error("upload was not signed")
+	}
+	sidecar := string(store.objects["/inventory/agency/2026/code.json.sha256"])
+	if sidecar != published[0].SHA256+"  code.json\n" {
+		t.Errorf("unexpected checksum sidecar %q", sidecar)
+	}
+}
+
+func TestPublishHTTPSDetectsCorruption(t *testing.T) {
+	store := &objectStore{objects: map[string][]byte{}, headers: map[string]http.Header{}}
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+
+	file := writePublishFixture(t)
+	opts := PublishOptions{
+		Destination: srv.URL + "/data/",
+		Headers:     map[string]string{"Authorization": "Bearer upload-token"},
+	}
+
+	if _, err := Publish(opts, file); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	header := store.headers["/data/code.json"]
+	if header.Get("Authorization") != "Bearer upload-token" || !strings.HasPrefix(header.Get("Digest"), "sha-256=") {
+		t.Errorf("unexpected upload headers %v", header)
+	}
+
+	store.corrupt = true
+	if _, err := Publish(opts, file); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected checksum mismatch, got %v", err)
+	}
+}
+
+// contentsAPI is a minimal GitHub contents API for one branch
+type contentsAPI struct {
+	mu      sync.Mutex
+	files   map[string][]byte
+	shas    map[string]string
+	commits []map[string]string
+}
+
+func (c *contentsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "token pages-token" {
+		http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/repos/agency/agency.github.io/contents/")
+	switch r.Method {
+	case http.MethodGet:
+		data, ok := c.files[name]
+		if !ok || r.URL.Query().Get("ref") != "gh-pages" {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Accept") == "application/vnd.github.raw" {
+			w.Write(data)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"sha": c.shas[name]})
+	case http.MethodPut:
+		var commit map[string]string
+		json.NewDecoder(r.Body).Decode(&commit)
+		if commit["sha"] != c.shas[name] {
+			http.Error(w, `{"message":"sha does not match"}`, http.StatusConflict)
+			return
+		}
+		data, _ := base64.StdEncoding.DecodeString(commit["content"])
+		c.files[name] = data
+		c.shas[name] = fmt.Sprintf("sha-%d", len(c.commits))
+		c.commits = append(c.commits, commit)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func TestPublishGitHubPages(t *testing.T) {
+	api := &contentsAPI{files: map[string][]byte{}, shas: map[string]string{}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	SetGitHubConfig(GitHubConfig{BaseURI: srv.URL})
+	defer SetGitHubConfig(GitHubConfig{})
+
+	file := writePublishFixture(t)
+	opts := PublishOptions{
+		Destination: "gh-pages://agency/agency.github.io/data",
+		GitHub:      GitHubPagesConfig{Credentials: Credentials{GitHubToken: "pages-token", NoEnvFallback: true}},
+	}
+	for i := 0; i < 2; i++ {
+		published, err := Publish(opts, file)
+		if err != nil {
+			t.Fatalf("Publish %d failed: %v", i, err)
+		}
+		if !strings.HasSuffix(published[0].URL, "/contents/data/code.json?ref=gh-pages") {
+			t.Errorf("unexpected URL %s", published[0].URL)
+		}
+	}
+
+	// The second run replaces both files in place
+	if len(api.commits) != 4 || api.commits[2]["sha"] != "sha-0" || api.commits[0]["branch"] != "gh-pages" || api.commits[0]["message"] != "Publish code.json" {
+		t.Errorf("unexpected commits %v", api.commits)
+	}
+	if _, ok := api.files["data/code.json.sha256"]; !ok {
+		t.Error("checksum sidecar not committed")
+	}
+
+	opts.GitHub.Credentials.GitHubToken = "wrong"
+	if _, err := Publish(opts, file); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got %v", err)
+	}
+}
+
+func TestPublishCacheControl(t *testing.T) {
+	store := &objectStore{objects: map[string][]byte{}, headers: map[string]http.Header{}}
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+
+	file := writePublishFixture(t)
+	if _, err := Publish(PublishOptions{Destination: srv.URL + "/data/", CacheControl: "max-age=300"}, file); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.headers["/data/code.json"].Get("Cache-Control"); got != "max-age=300" {
+		t.Errorf("Cache-Control = %q", got)
+	}
+}
+
+func TestPublishRejectsUnknownDestination(t *testing.T) {
+	file := writePublishFixture(t)
+	if _, err := Publish(PublishOptions{Destination: "ftp://host/dir"}, file); err == nil {
+		t.Error("expected error for ftp destination")
+	}
+	if _, err := Publish(PublishOptions{Destination: "s3://bucket"}, filepath.Join(os.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
@@ -0,0 +1,258 @@
+package codegov
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxRequestsPerSecond is the default global GitHub request rate
+	DefaultMaxRequestsPerSecond = 10
+
+	// DefaultGitLabMaxRequestsPerSecond is the default global GitLab request rate
+	DefaultGitLabMaxRequestsPerSecond = 10
+
+	// MaxSecondaryRateLimitRetries bounds how often a request is retried after a rate limit
+	MaxSecondaryRateLimitRetries = 3
+
+	// defaultSecondaryRateLimitWait is used when GitHub does not send Retry-After
+	defaultSecondaryRateLimitWait = 60 * time.Second
+
+	// DefaultMaxRateLimitWait is the longest a request waits for a rate limit to clear
+	DefaultMaxRateLimitWait = 15 * time.Minute
+)
+
+// throttle spaces out requests so that all goroutines together stay under a maximum rate
+type throttle struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// Each provider has its own throttle, so a rate limit on one never holds back the others
+var (
+	githubThrottle      = &throttle{interval: time.Second / DefaultMaxRequestsPerSecond}
+	gitLabThrottle      = &throttle{interval: time.Second / DefaultGitLabMaxRequestsPerSecond}
+	bitbucketThrottle   = &throttle{interval: time.Second / DefaultMaxRequestsPerSecond}
+	azureDevOpsThrottle = &throttle{interval: time.Second / DefaultMaxRequestsPerSecond}
+)
+
+// throttleFor returns the throttle of the provider a request is sent to
+func throttleFor(req *http.Request) *throttle {
+	switch requestProvider(req) {
+	case "gitlab":
+		return gitLabThrottle
+	case "bitbucket":
+		return bitbucketThrottle
+	case "azuredevops":
+		return azureDevOpsThrottle
+	}
+	return githubThrottle
+}
+
+var (
+	rateLimitWaitMu  sync.RWMutex
+	maxRateLimitWait = DefaultMaxRateLimitWait
+)
+
+// SetMaxRateLimitWait sets how long a request may wait for a rate limit to reset before
+// it fails with a RateLimitError. Zero or less fails immediately on any rate limit.
+func SetMaxRateLimitWait(d time.Duration) {
+	rateLimitWaitMu.Lock()
+	defer rateLimitWaitMu.Unlock()
+	maxRateLimitWait = d
+}
+
+func getMaxRateLimitWait() time.Duration {
+	rateLimitWaitMu.RLock()
+	defer rateLimitWaitMu.RUnlock()
+	return maxRateLimitWait
+}
+
+// SetMaxRequestsPerSecond sets the global GitHub request rate shared by all goroutines.
+// A value of zero or less disables throttling.
+func SetMaxRequestsPerSecond(rps float64) {
+	githubThrottle.setRate(rps)
+}
+
+// SetGitLabMaxRequestsPerSecond sets the global GitLab request rate shared by all goroutines.
+// A value of zero or less disables throttling.
+func SetGitLabMaxRequestsPerSecond(rps float64) {
+	gitLabThrottle.setRate(rps)
+}
+
+// setRate changes the throttle's rate; zero or less disables it
+func (t *throttle) setRate(rps float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if rps <= 0 {
+		t.interval = 0
+		return
+	}
+	t.interval = time.Duration(float64(time.Second) / rps)
+}
+
+// wait blocks until the caller may issue the next request
+func (t *throttle) wait() {
+	t.mu.Lock()
+	if t.interval == 0 {
+		t.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	delay := t.next.Sub(now)
+	t.next = t.next.Add(t.interval)
+	t.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// pause pushes the next allowed request out by d, so every goroutine backs off together
+func (t *throttle) pause(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(t.next) {
+		t.next = until
+	}
+}
+
+// doAPIRequest sends an API request through its provider's throttle, waiting out
+// primary and secondary rate limits before retrying
+func doAPIRequest(client *http.Client, req *http.Request) (*http.Response, error) {
+	throttle := throttleFor(req)
+	for attempt := 0; ; attempt++ {
+		throttle.wait()
+
+		// Requests with a body, such as GraphQL queries, need it rewound before a retry
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := doWithRetry(client, req)
+		defaultMetrics.observeAPIResponse(requestProvider(req), resp)
+		if err != nil {
+			return nil, err
+		}
+
+		wait, limited := rateLimitWait(resp, attempt)
+		if !limited {
+			return resp, nil
+		}
+
+		resp.Body.Close()
+		if attempt >= MaxSecondaryRateLimitRetries || wait > getMaxRateLimitWait() {
+			return nil, &RateLimitError{StatusCode: resp.StatusCode, RetryAfter: wait}
+		}
+
+		log.Printf("Rate limited by %s (status %d), waiting %s before retrying\n", req.URL.Host, resp.StatusCode, wait.Round(time.Second))
+		throttle.pause(wait)
+	}
+}
+
+// requestProvider names the source provider a request is sent to, for metrics labels
+func requestProvider(req *http.Request) string {
+	if req.URL.Host == gitLabHost() {
+		return "gitlab"
+	}
+	if req.URL.Host == bitbucketHost() || req.URL.Host == bitbucketCloneHost() {
+		return "bitbucket"
+	}
+	if req.URL.Host == azureDevOpsHost() {
+		return "azuredevops"
+	}
+	return "github"
+}
+
+// rateLimitWait reports whether resp is a rate limit response and how long to wait before retrying
+func rateLimitWait(resp *http.Response, attempt int) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	// Primary limit: the quota is exhausted until the reset time.
+	// GitHub uses the X- prefixed headers, GitLab the unprefixed ones.
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		if resp.Header.Get(prefix+"Remaining") != "0" {
+			continue
+		}
+		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+			return parseRetryAfter(retryAfter, defaultSecondaryRateLimitWait), true
+		}
+		if reset, err := strconv.ParseInt(resp.Header.Get(prefix+"Reset"), 10, 64); err == nil {
+			// Allow a second of clock skew so the retry lands after the reset
+			if d := time.Until(time.Unix(reset, 0)) + time.Second; d > 0 {
+				return d, true
+			}
+			return time.Second, true
+		}
+		return defaultSecondaryRateLimitWait, true
+	}
+
+	return secondaryRateLimitWait(resp, attempt)
+}
+
+// secondaryRateLimitWait reports whether resp is a secondary rate limit response and how long to wait.
+// Without Retry-After the wait doubles with every attempt, as GitHub recommends.
+// The response body is buffered and restored so callers can still read it.
+func secondaryRateLimitWait(resp *http.Response, attempt int) (time.Duration, bool) {
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	retryAfter := resp.Header.Get("Retry-After")
+	if retryAfter != "" {
+		return parseRetryAfter(retryAfter, defaultSecondaryRateLimitWait), true
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || strings.Contains(strings.ToLower(string(body)), "secondary rate limit") {
+		return defaultSecondaryRateLimitWait << attempt, true
+	}
+
+	return 0, false
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, fallback time.Duration) time.Duration {
+	if value == "" {
+		return fallback
+	}
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return fallback
+}
+
+// RateLimitError is returned when a request is still rate limited after all retries,
+// or when the required wait exceeds the configured maximum
+type RateLimitError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("API rate limit exceeded (status %d, retry after %s)", e.StatusCode, e.RetryAfter)
+}
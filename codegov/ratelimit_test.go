@@ -0,0 +1,213 @@
+package codegov
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	const fallback = time.Minute
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"empty", "", fallback},
+		{"seconds", "30", 30 * time.Second},
+		{"padded seconds", " 5 ", 5 * time.Second},
+		{"zero", "0", 0},
+		{"negative", "-1", fallback},
+		{"garbage", "soon", fallback},
+		{"past date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value, fallback); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+
+	// HTTP dates have a one-second resolution
+	date := time.Now().Add(2 * time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date, fallback); got < 118*time.Second || got > 2*time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %s, want about 2m", date, got)
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	inSeconds := func(d time.Duration) string {
+		return strconv.FormatInt(time.Now().Add(d).Unix(), 10)
+	}
+	tests := []struct {
+		name     string
+		status   int
+		headers  map[string]string
+		body     string
+		attempt  int
+		want     time.Duration
+		slack    time.Duration // Tolerance for waits computed from the clock
+		wantWait bool
+	}{
+		{"success", http.StatusOK, map[string]string{"X-RateLimit-Remaining": "0"}, "", 0, 0, 0, false},
+		{"plain forbidden", http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "42"}, "Resource not accessible by integration", 0, 0, 0, false},
+		{"primary limit until reset", http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": inSeconds(30 * time.Second)}, "", 0, 31 * time.Second, 2 * time.Second, true},
+		{"primary limit already reset", http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": inSeconds(-time.Minute)}, "", 0, time.Second, 0, true},
+		{"primary limit prefers Retry-After", http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": inSeconds(time.Hour), "Retry-After": "7"}, "", 0, 7 * time.Second, 0, true},
+		{"primary limit without reset", http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "0"}, "", 0, defaultSecondaryRateLimitWait, 0, true},
+		{"GitLab headers", http.StatusTooManyRequests, map[string]string{"RateLimit-Remaining": "0", "Retry-After": "12"}, "", 0, 12 * time.Second, 0, true},
+		{"secondary limit with Retry-After", http.StatusForbidden, map[string]string{"Retry-After": "45"}, `{"message": "You have exceeded a secondary rate limit"}`, 0, 45 * time.Second, 0, true},
+		{"secondary limit backs off", http.StatusForbidden, nil, `{"message": "You have exceeded a secondary rate limit"}`, 2, 4 * defaultSecondaryRateLimitWait, 0, true},
+		{"too many requests", http.StatusTooManyRequests, nil, "", 1, 2 * defaultSecondaryRateLimitWait, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			for name, value := range tt.headers {
+				rec.Header().Set(name, value)
+			}
+			rec.WriteHeader(tt.status)
+			rec.WriteString(tt.body)
+			resp := rec.Result()
+
+			wait, limited := rateLimitWait(resp, tt.attempt)
+			if limited != tt.wantWait {
+				t.Fatalf("limited = %v, want %v", limited, tt.wantWait)
+			}
+			if wait < tt.want-tt.slack || wait > tt.want {
+				t.Errorf("wait = %s, want %s (within %s)", wait, tt.want, tt.slack)
+			}
+			if body, _ := io.ReadAll(resp.Body); string(body) != tt.body {
+				t.Errorf("body not restored: %q", body)
+			}
+		})
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	throttle := &throttle{}
+	throttle.setRate(50)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		throttle.wait()
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("5 requests at 50/s took %s, want at least 80ms", elapsed)
+	}
+
+	// A pause holds back the next request even when the rate would allow it
+	throttle.pause(100 * time.Millisecond)
+	start = time.Now()
+	throttle.wait()
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("wait after a 100ms pause took %s", elapsed)
+	}
+
+	throttle.setRate(0)
+	throttle.pause(time.Hour)
+	start = time.Now()
+	throttle.wait()
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("a disabled throttle waited %s", elapsed)
+	}
+}
+
+func TestDoAPIRequestRateLimits(t *testing.T) {
+	SetMaxRequestsPerSecond(0)
+	defer SetMaxRequestsPerSecond(DefaultMaxRequestsPerSecond)
+	defer SetMaxRateLimitWait(DefaultMaxRateLimitWait)
+
+	var calls atomic.Int32
+	var respond func(w http.ResponseWriter, call int32)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond(w, calls.Add(1))
+	}))
+	defer srv.Close()
+
+	get := func() (*http.Response, error) {
+		req, err := http.NewRequest("GET", srv.URL+"/orgs/agency/repos", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return doAPIRequest(srv.Client(), req)
+	}
+
+	// An exhausted quota, then a secondary limit, are waited out before the request succeeds
+	respond = func(w http.ResponseWriter, call int32) {
+		switch call {
+		case 1:
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"message": "You have exceeded a secondary rate limit."}`)
+		default:
+			io.WriteString(w, `[]`)
+		}
+	}
+	resp, err := get()
+	if err != nil {
+		t.Fatalf("expected the request to succeed after the limits cleared, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Errorf("expected 200 after 3 attempts, got %d after %d", resp.StatusCode, calls.Load())
+	}
+
+	// A limit that would take longer than the maximum wait fails at once
+	calls.Store(0)
+	SetMaxRateLimitWait(time.Minute)
+	respond = func(w http.ResponseWriter, call int32) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusForbidden)
+	}
+	_, err = get()
+	var rateErr *RateLimitError
+	if !errors.As(err, &rateErr) || rateErr.StatusCode != http.StatusForbidden || rateErr.RetryAfter < 59*time.Minute {
+		t.Fatalf("expected a RateLimitError for the hour-long wait, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected no retries, got %d attempts", calls.Load())
+	}
+
+	// A limit that never clears gives up after MaxSecondaryRateLimitRetries retries
+	calls.Store(0)
+	respond = func(w http.ResponseWriter, call int32) {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+	if _, err := get(); !errors.As(err, &rateErr) || rateErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected a RateLimitError, got %v", err)
+	}
+	if calls.Load() != MaxSecondaryRateLimitRetries+1 {
+		t.Errorf("expected %d attempts, got %d", MaxSecondaryRateLimitRetries+1, calls.Load())
+	}
+
+	// A 403 that is not a rate limit is returned to the caller with its body
+	calls.Store(0)
+	respond = func(w http.ResponseWriter, call int32) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `{"message": "Resource not accessible by integration"}`)
+	}
+	resp, err = get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusForbidden || !strings.Contains(string(body), "not accessible") || calls.Load() != 1 {
+		t.Errorf("expected the 403 after 1 attempt, got %d %q after %d", resp.StatusCode, body, calls.Load())
+	}
+}
@@ -0,0 +1,156 @@
+package codegov
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// readmeFileNames are the README names tried, in order, when a repository has no description
+var readmeFileNames = []string{"README.md", "README", "README.markdown", "README.rst", "README.txt", "readme.md", "Readme.md"}
+
+// maxReadmeDescriptionLength caps descriptions taken from a README, in characters
+const maxReadmeDescriptionLength = 300
+
+// noDescription is published when neither the provider nor the README describes a repository
+const noDescription = "No description provided"
+
+var (
+	markdownImage     = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	markdownLink      = regexp.MustCompile(`\[([^\]]*)\](\([^)]*\)|\[[^\]]*\])`)
+	markdownAutolink  = regexp.MustCompile(`<(https?://[^>\s]+)>`)
+	htmlTag           = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	rstLink           = regexp.MustCompile("`([^`<]+?)\\s*<[^>]*>`_+")
+	markdownEmphasis  = regexp.MustCompile("\\*\\*|__|[*`]")
+	htmlHeading       = regexp.MustCompile(`^<h[1-6][\s>]`)
+	markdownListStart = regexp.MustCompile(`^([-*+]|\d+[.)])\s+`)
+)
+
+// readmeDescription returns the first prose paragraph of a Markdown, reStructuredText
+// or plain-text README, stripped of markup and truncated, or "" if it has none.
+// Headings, badges, HTML blocks, code blocks, tables and directives are skipped.
+func readmeDescription(text string) string {
+	text = strings.TrimPrefix(strings.ReplaceAll(text, "\r\n", "\n"), "\ufeff")
+	lines := strings.Split(text, "\n")
+
+	var paragraph []string
+	fence, comment := "", false
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+
+		switch {
+		case fence != "":
+			if strings.HasPrefix(line, fence) {
+				fence = ""
+			}
+			continue
+		case comment:
+			comment = !strings.Contains(line, "-->")
+			continue
+		case strings.HasPrefix(line, "```"), strings.HasPrefix(line, "~~~"):
+			fence = line[:3]
+		case strings.HasPrefix(line, "<!--"):
+			comment = !strings.Contains(line, "-->")
+		case line == "":
+			if len(paragraph) > 0 {
+				return truncateDescription(strings.Join(paragraph, " "))
+			}
+			continue
+		case i+1 < len(lines) && isHeadingRule(strings.TrimSpace(lines[i+1])) && len(paragraph) == 0:
+			// A Setext or reStructuredText heading
+			i++
+		case isHeadingRule(line), strings.HasPrefix(line, "#"), htmlHeading.MatchString(line),
+			strings.HasPrefix(line, "|"), strings.HasPrefix(line, ".. "):
+			// Headings, rules, tables and directives
+		case len(paragraph) == 0 && (strings.HasPrefix(lines[i], "    ") || strings.HasPrefix(lines[i], "\t")):
+			// Indented code; indented lines inside a paragraph continue it
+		default:
+			if prose := cleanReadmeLine(line); prose != "" {
+				paragraph = append(paragraph, prose)
+				continue
+			}
+		}
+		// Anything that is not prose ends the paragraph
+		if len(paragraph) > 0 {
+			return truncateDescription(strings.Join(paragraph, " "))
+		}
+	}
+	return truncateDescription(strings.Join(paragraph, " "))
+}
+
+// isHeadingRule reports whether a line is a heading underline or a thematic break:
+// three or more repeats of one punctuation character
+func isHeadingRule(line string) bool {
+	if len(line) < 3 || !strings.ContainsRune(`=-~^"'#*+_`, rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// cleanReadmeLine reduces a line of README markup to its text, or "" if it has none
+func cleanReadmeLine(line string) string {
+	line = strings.TrimSpace(strings.TrimLeft(line, ">"))
+	line = markdownListStart.ReplaceAllString(line, "")
+	line = markdownImage.ReplaceAllString(line, "")
+	line = markdownLink.ReplaceAllString(line, "$1")
+	line = rstLink.ReplaceAllString(line, "$1")
+	line = markdownAutolink.ReplaceAllString(line, "$1")
+	line = htmlTag.ReplaceAllString(line, "")
+	line = markdownEmphasis.ReplaceAllString(line, "")
+	line = html.UnescapeString(line)
+	line = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, line)
+	line = strings.Join(strings.Fields(line), " ")
+
+	// Lines left with nothing but punctuation were badges or decoration
+	if strings.IndexFunc(line, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		return ""
+	}
+	return line
+}
+
+// truncateDescription cuts text to maxReadmeDescriptionLength, at the end of a sentence
+// when one ends in the second half of the limit and otherwise at a word boundary
+func truncateDescription(text string) string {
+	runes := []rune(text)
+	if len(runes) <= maxReadmeDescriptionLength {
+		return text
+	}
+	cut := string(runes[:maxReadmeDescriptionLength])
+	if i := strings.LastIndex(cut, ". "); i >= len(cut)/2 {
+		return cut[:i+1]
+	}
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:-") + "..."
+}
+
+// describe returns a repository's description, falling back to the first paragraph of
+// its README (read with read) and then to noDescription
+func (g *generator) describe(repoName, description string, read func(name string) ([]byte, error)) string {
+	if description = strings.TrimSpace(description); description != "" {
+		return description
+	}
+	for _, name := range readmeFileNames {
+		data, err := read(name)
+		if err != nil {
+			g.enrichmentError(repoName, "description", fmt.Errorf("reading %s: %w", name, err))
+			break
+		}
+		if data == nil {
+			continue
+		}
+		if description := readmeDescription(string(data)); description != "" {
+			return description
+		}
+		break
+	}
+	return noDescription
+}
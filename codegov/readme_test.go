@@ -0,0 +1,33 @@
+package codegov
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadmeDescription(t *testing.T) {
+	for text, want := range map[string]string{
+		"# Widget\n\n[![CI](https://ci/badge.svg)](https://ci) ![Go](go.svg)\n\nWidget serves *widgets*\nover `HTTP`.\n\nMore text.": "Widget serves widgets over HTTP.",
+		"<p align=\"center\"><img src=\"logo.png\"></p>\n<h1 align=\"center\">Tool</h1>\n\nA tool &amp; a library.":                  "A tool & a library.",
+		"=======\nProject\n=======\n\n.. image:: badge.svg\n\nSee `the docs <https://docs>`_ for details.":                           "See the docs for details.",
+		"Title\n-----\n\n```sh\nmake install\n```\n\n> Quoted summary with a <https://example.gov> link.":                            "Quoted summary with a https://example.gov link.",
+		"<!-- badges\n![x](y)\n-->\nPlain text README\n\n| table |":                                                                  "Plain text README",
+		"# Only headings\n\n## Install\n\n    go install ./...":                                                                      "",
+	} {
+		if got := readmeDescription(text); got != want {
+			t.Errorf("readmeDescription(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestTruncateDescription(t *testing.T) {
+	words := strings.Repeat("word ", 80)
+	if got := truncateDescription(words); len(got) > maxReadmeDescriptionLength+3 || !strings.HasSuffix(got, "word...") {
+		t.Errorf("expected a cut at a word boundary, got %q", got)
+	}
+
+	sentences := strings.Repeat("A short sentence. ", 20)
+	if got := truncateDescription(sentences); len(got) > maxReadmeDescriptionLength || !strings.HasSuffix(got, "sentence.") {
+		t.Errorf("expected a cut at a sentence end, got %q", got)
+	}
+}
@@ -0,0 +1,194 @@
+package codegov
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCodeGovAPIURL is the code.gov API that serves harvested inventories
+const DefaultCodeGovAPIURL = "https://api.code.gov"
+
+// harvestPageSize is the number of repositories requested per code.gov API page
+const harvestPageSize = 100
+
+// HarvestOptions configures FetchHarvest
+type HarvestOptions struct {
+	Agency  string       // Agency acronym, e.g. NSA
+	APIKey  string       // api.data.gov key for the code.gov API
+	BaseURL string       // Defaults to DefaultCodeGovAPIURL
+	Client  *http.Client // Defaults to a client with a 30s timeout
+}
+
+// HarvestedRelease is a repository code.gov has harvested for an agency
+type HarvestedRelease struct {
+	RepoID        string `json:"repoID"`
+	Name          string `json:"name"`
+	RepositoryURL string `json:"repositoryURL"`
+}
+
+// FetchHarvest lists the repositories code.gov currently has for an agency
+func FetchHarvest(ctx context.Context, opts HarvestOptions) ([]HarvestedRelease, error) {
+	if opts.Agency == "" {
+		return nil, fmt.Errorf("agency acronym is required")
+	}
+	base := opts.BaseURL
+	if base == "" {
+		base = DefaultCodeGovAPIURL
+	}
+	client := opts.Client
+	if client == nil {
+		client = newHTTPClient(30 * time.Second)
+	}
+
+	var harvested []HarvestedRelease
+	for {
+		query := url.Values{
+			"agency.acronym": {opts.Agency},
+			"size":           {strconv.Itoa(harvestPageSize)},
+			"from":           {strconv.Itoa(len(harvested))},
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(base, "/")+"/repos?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		setClientHeaders(req)
+		if opts.APIKey != "" {
+			req.Header.Set("X-Api-Key", opts.APIKey)
+		}
+
+		var page struct {
+			Total int                `json:"total"`
+			Repos []HarvestedRelease `json:"repos"`
+		}
+		if err := getHarvestPage(client, req, &page); err != nil {
+			return nil, err
+		}
+		harvested = append(harvested, page.Repos...)
+		if len(page.Repos) == 0 || len(harvested) >= page.Total {
+			return harvested, nil
+		}
+	}
+}
+
+// getHarvestPage sends a code.gov API request and decodes the response into v
+func getHarvestPage(client *http.Client, req *http.Request, v interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp, nil)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// ReconcileEntry is a release found on only one side of a reconciliation
+type ReconcileEntry struct {
+	Name          string `json:"name"`
+	RepositoryURL string `json:"repositoryURL,omitempty"`
+}
+
+// Reconciliation compares a generated inventory with what code.gov has harvested.
+// Releases are matched by repositoryURL, ignoring scheme, case, a trailing slash and
+// a .git suffix, and otherwise by case-insensitive name.
+type Reconciliation struct {
+	Agency       string           `json:"agency"`
+	Published    int              `json:"published"` // Releases in the generated inventory
+	Harvested    int              `json:"harvested"` // Repositories code.gov has for the agency
+	Matched      int              `json:"matched"`
+	NotHarvested []ReconcileEntry `json:"not_harvested,omitempty"` // Published but not picked up by code.gov
+	NotPublished []ReconcileEntry `json:"not_published,omitempty"` // On code.gov but missing from the inventory
+}
+
+// InSync reports whether every release is on both sides
+func (r *Reconciliation) InSync() bool {
+	return len(r.NotHarvested) == 0 && len(r.NotPublished) == 0
+}
+
+// Reconcile compares a generated inventory with an agency's harvested repositories
+func Reconcile(codeGov *CodeGovJSON, agency string, harvested []HarvestedRelease) *Reconciliation {
+	r := &Reconciliation{Agency: agency, Published: len(codeGov.Releases), Harvested: len(harvested)}
+
+	byURL := make(map[string]int, len(harvested))
+	byName := make(map[string]int, len(harvested))
+	for i, h := range harvested {
+		if key := repositoryKey(h.RepositoryURL); key != "" {
+			if _, ok := byURL[key]; !ok {
+				byURL[key] = i
+			}
+		}
+		if _, ok := byName[strings.ToLower(h.Name)]; !ok {
+			byName[strings.ToLower(h.Name)] = i
+		}
+	}
+
+	matched := make([]bool, len(harvested))
+	match := func(i int, ok bool) bool {
+		if !ok || matched[i] {
+			return false
+		}
+		matched[i] = true
+		return true
+	}
+	for _, release := range codeGov.Releases {
+		i, ok := byURL[repositoryKey(release.RepositoryURL)]
+		if !match(i, ok) {
+			i, ok = byName[strings.ToLower(release.Name)]
+			if !match(i, ok) {
+				r.NotHarvested = append(r.NotHarvested, ReconcileEntry{Name: release.Name, RepositoryURL: release.RepositoryURL})
+				continue
+			}
+		}
+		r.Matched++
+	}
+	for i, h := range harvested {
+		if !matched[i] {
+			r.NotPublished = append(r.NotPublished, ReconcileEntry{Name: h.Name, RepositoryURL: h.RepositoryURL})
+		}
+	}
+
+	sortEntries(r.NotHarvested)
+	sortEntries(r.NotPublished)
+	return r
+}
+
+// repositoryKey normalizes a repository URL for matching, or returns "" when it is empty
+func repositoryKey(repositoryURL string) string {
+	key := strings.ToLower(strings.TrimSpace(repositoryURL))
+	if i := strings.Index(key, "://"); i >= 0 {
+		key = key[i+3:]
+	}
+	key = strings.TrimSuffix(key, "/")
+	return strings.TrimSuffix(key, ".git")
+}
+
+func sortEntries(entries []ReconcileEntry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+}
+
+// WriteText writes the releases missing from either side and a count summary
+func (r *Reconciliation) WriteText(w io.Writer) error {
+	ew := &errWriter{w: w}
+	for _, e := range r.NotHarvested {
+		ew.printf("+ not harvested: %s %s\n", e.Name, e.RepositoryURL)
+	}
+	for _, e := range r.NotPublished {
+		ew.printf("- not published: %s %s\n", e.Name, e.RepositoryURL)
+	}
+	if !r.InSync() {
+		ew.printf("\n")
+	}
+	ew.printf("%d published, %d harvested by code.gov for %s: %d matched, %d not harvested, %d not published\n",
+		r.Published, r.Harvested, r.Agency, r.Matched, len(r.NotHarvested), len(r.NotPublished))
+	return ew.err
+}
@@ -0,0 +1,84 @@
+package codegov
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFetchHarvest(t *testing.T) {
+	var repos []HarvestedRelease
+	for i := 0; i < 150; i++ {
+		repos = append(repos, HarvestedRelease{Name: fmt.Sprintf("repo-%d", i), RepositoryURL: fmt.Sprintf("https://github.com/nsa/repo-%d", i)})
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos" || r.URL.Query().Get("agency.acronym") != "NSA" || r.Header.Get("X-Api-Key") != "key" {
+			http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+			return
+		}
+		from, _ := strconv.Atoi(r.URL.Query().Get("from"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		end := from + size
+		if end > len(repos) {
+			end = len(repos)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"total": len(repos), "repos": repos[from:end]})
+	}))
+	defer srv.Close()
+
+	harvested, err := FetchHarvest(context.Background(), HarvestOptions{Agency: "NSA", APIKey: "key", BaseURL: srv.URL + "/"})
+	if err != nil {
+		t.Fatalf("FetchHarvest failed: %v", err)
+	}
+	if len(harvested) != 150 || harvested[149].Name != "repo-149" {
+		t.Errorf("got %d repositories", len(harvested))
+	}
+
+	if _, err := FetchHarvest(context.Background(), HarvestOptions{Agency: "NSA", BaseURL: srv.URL}); err == nil {
+		t.Error("expected an error without the API key")
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	codeGov := &CodeGovJSON{Releases: []Release{
+		{Name: "api", RepositoryURL: "https://github.com/NSA/api"},
+		{Name: "Tool", RepositoryURL: "https://gitlab.example.gov/nsa/tool"},
+		{Name: "new-repo", RepositoryURL: "https://github.com/nsa/new-repo"},
+	}}
+	harvested := []HarvestedRelease{
+		{Name: "api", RepositoryURL: "http://github.com/nsa/api.git/"},
+		{Name: "tool", RepositoryURL: "https://github.com/nsa/tool-mirror"},
+		{Name: "retired", RepositoryURL: "https://github.com/nsa/retired"},
+	}
+
+	r := Reconcile(codeGov, "NSA", harvested)
+	if r.Published != 3 || r.Harvested != 3 || r.Matched != 2 || r.InSync() {
+		t.Errorf("unexpected reconciliation %+v", r)
+	}
+	if len(r.NotHarvested) != 1 || r.NotHarvested[0].Name != "new-repo" {
+		t.Errorf("not harvested: %+v", r.NotHarvested)
+	}
+	if len(r.NotPublished) != 1 || r.NotPublished[0].Name != "retired" {
+		t.Errorf("not published: %+v", r.NotPublished)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"+ not harvested: new-repo", "- not published: retired", "2 matched, 1 not harvested, 1 not published"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("text output missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	if !Reconcile(&CodeGovJSON{}, "NSA", nil).InSync() {
+		t.Error("empty inventories should be in sync")
+	}
+}
@@ -0,0 +1,230 @@
+package codegov
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// RecorderMode selects whether a Recorder captures live traffic or serves fixtures
+type RecorderMode string
+
+const (
+	// RecorderModeRecord forwards requests to the network and captures the responses
+	RecorderModeRecord RecorderMode = "record"
+	// RecorderModeReplay serves responses from the fixture file without network access
+	RecorderModeReplay RecorderMode = "replay"
+)
+
+// sensitiveQueryParams are stripped from recorded URLs
+var sensitiveQueryParams = []string{"access_token", "private_token", "token", "client_secret"}
+
+// recordedResponseHeaders are kept in fixtures; everything else (cookies, request IDs) is dropped
+var recordedResponseHeaders = []string{
+	"Content-Type", "Link", "X-Next-Page", "Retry-After",
+	"X-RateLimit-Remaining", "X-RateLimit-Reset", "RateLimit-Remaining", "RateLimit-Reset",
+}
+
+// Interaction is one recorded request and its response
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest identifies a request. Request headers are never recorded,
+// so Authorization and PRIVATE-TOKEN values cannot leak into fixtures.
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+// RecordedResponse is a sanitized HTTP response
+type RecordedResponse struct {
+	StatusCode int                 `json:"status_code"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       string              `json:"body"`
+}
+
+// Cassette is the on-disk fixture format
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper that records GitHub and GitLab interactions to
+// a fixture file, or replays them, for deterministic tests of generation logic.
+// Use it as GenerateOptions.HTTPClient's Transport.
+type Recorder struct {
+	mode      RecorderMode
+	path      string
+	transport http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     map[int]bool
+}
+
+// NewRecorder creates a recorder backed by the fixture at path. In replay mode the
+// fixture must exist; in record mode live requests go through transport
+// (http.DefaultTransport if nil) and Save writes the fixture.
+func NewRecorder(path string, mode RecorderMode, transport http.RoundTripper) (*Recorder, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	r := &Recorder{
+		mode:      mode,
+		path:      path,
+		transport: transport,
+		used:      make(map[int]bool),
+	}
+
+	switch mode {
+	case RecorderModeReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture: %w", err)
+		}
+		if err := json.Unmarshal(data, &r.cassette); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+		}
+	case RecorderModeRecord:
+	default:
+		return nil, fmt.Errorf("unknown recorder mode %q", mode)
+	}
+
+	return r, nil
+}
+
+// Client returns an HTTP client that uses the recorder as its transport
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip records or replays a single request
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.mode == RecorderModeReplay {
+		return r.replay(req)
+	}
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	headers := make(map[string][]string)
+	for _, name := range recordedResponseHeaders {
+		if values := resp.Header.Values(name); len(values) > 0 {
+			headers[name] = sanitizeValues(values)
+		}
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: RecordedRequest{Method: req.Method, URL: sanitizeURL(req.URL)},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    headers,
+			Body:       redactToken(string(body)),
+		},
+	})
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// replay returns the first unused interaction matching the request's method and URL.
+// Repeated requests are served in recording order; once exhausted, the last match is reused.
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	key := sanitizeURL(req.URL)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	match := -1
+	for i, in := range r.cassette.Interactions {
+		if in.Request.Method != req.Method || in.Request.URL != key {
+			continue
+		}
+		match = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("no recorded interaction for %s %s in %s", req.Method, key, r.path)
+	}
+	r.used[match] = true
+
+	recorded := r.cassette.Interactions[match].Response
+	header := make(http.Header, len(recorded.Headers))
+	for name, values := range recorded.Headers {
+		header[http.CanonicalHeaderKey(name)] = values
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Save writes the recorded interactions to the fixture file
+func (r *Recorder) Save() error {
+	if r.mode != RecorderModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(data, '\n'), 0644)
+}
+
+// sanitizeURL drops credentials and token query parameters from a request URL
+func sanitizeURL(u *url.URL) string {
+	c := *u
+	c.User = nil
+
+	query := c.Query()
+	for _, name := range sensitiveQueryParams {
+		query.Del(name)
+	}
+	c.RawQuery = query.Encode()
+
+	return redactToken(c.String())
+}
+
+func sanitizeValues(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = redactToken(v)
+	}
+	return out
+}
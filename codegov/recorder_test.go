@@ -0,0 +1,148 @@
+package codegov
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateFromFixture(t *testing.T) {
+	rec, err := NewRecorder(filepath.Join("testdata", "github-org.json"), RecorderModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codeGov, err := Generate(GenerateOptions{
+		Organizations: []string{"testorg"},
+		Agency:        "TEST",
+		Contact:       Contact{Email: "code@test.gov"},
+		HTTPClient:    rec.Client(),
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if len(codeGov.Releases) != 1 {
+		t.Fatalf("expected forks to be skipped, got %d releases", len(codeGov.Releases))
+	}
+	release := codeGov.Releases[0]
+
+	if release.Name != "widget" || release.Description != "A widget service" {
+		t.Errorf("unexpected release %+v", release)
+	}
+	if strings.Join(release.Languages, ",") != "Go,Shell" {
+		t.Errorf("unexpected languages %v", release.Languages)
+	}
+	if lic := release.Permissions.Licenses[0]; lic.Name != "MIT" || !strings.HasSuffix(lic.URL, "/LICENSE") {
+		t.Errorf("unexpected license %+v", lic)
+	}
+	if !strings.HasSuffix(release.DisclaimerURL, "/DISCLAIMER.md") {
+		t.Errorf("unexpected disclaimer URL %s", release.DisclaimerURL)
+	}
+	if !strings.HasSuffix(release.DownloadURL, "/v1.4.0") || release.Version != "v1.4.0" {
+		t.Errorf("expected latest non-prerelease download and version, got %s %s", release.DownloadURL, release.Version)
+	}
+	if release.Organization != "testorg" {
+		t.Errorf("unexpected organization %q", release.Organization)
+	}
+	if release.HomepageURL != "https://github.com/testorg/widget" || release.Date.Created != "2019-03-01" {
+		t.Errorf("unexpected homepage or dates: %s %+v", release.HomepageURL, release.Date)
+	}
+	// From the repository's .codegov.yml
+	if release.LaborHours != 1200 || strings.Join(release.Tags, ",") != "widgets,inventory" {
+		t.Errorf("repository metadata not applied: %v %v", release.LaborHours, release.Tags)
+	}
+	if release.Contact != (Contact{Email: "widgets@test.gov", Name: "Widget Team"}) {
+		t.Errorf("unexpected contact %+v", release.Contact)
+	}
+}
+
+func TestRecorderSanitizesFixtures(t *testing.T) {
+	const token = "0123456789abcdef0123456789abcdef01234567"
+	t.Setenv(OAuthTokenEnv, token)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Write([]byte(`{"echo":"` + r.Header.Get("Authorization") + `"}`))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	rec, err := NewRecorder(path, RecorderModeRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("GET", srv.URL+"/repos?access_token="+token+"&page=2", nil)
+	req.Header.Set("Authorization", "token "+token)
+	resp, err := rec.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), token) || strings.Contains(string(data), "session=secret") {
+		t.Fatalf("fixture leaks credentials:\n%s", data)
+	}
+
+	replay, err := NewRecorder(path, RecorderModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = replay.Client().Get(srv.URL + "/repos?page=2")
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected replayed response %d %v", resp.StatusCode, resp.Header)
+	}
+
+	if _, err := replay.Client().Get(srv.URL + "/unrecorded"); err == nil {
+		t.Error("expected error for unrecorded request")
+	}
+}
+
+func TestGenerateProgress(t *testing.T) {
+	rec, err := NewRecorder(filepath.Join("testdata", "github-org.json"), RecorderModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []Progress
+	_, err = Generate(GenerateOptions{
+		Organizations: []string{"testorg"},
+		Agency:        "TEST",
+		Contact:       Contact{Email: "code@test.gov"},
+		HTTPClient:    rec.Client(),
+		Progress:      func(p Progress) { events = append(events, p) },
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	want := []Progress{
+		{Kind: ProgressOrgStarted, Org: "testorg"},
+		{Kind: ProgressOrgListed, Org: "testorg", Total: 1},
+		{Kind: ProgressRepoDone, Repo: "testorg/widget", Done: 1, Total: 1},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("unexpected progress events %+v", events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d: got %+v, want %+v", i, events[i], want[i])
+		}
+	}
+}
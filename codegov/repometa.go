@@ -0,0 +1,360 @@
+package codegov
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// repoMetadataFiles are the per-repository metadata files looked up in the root of the
+// default branch, in order of precedence
+var repoMetadataFiles = []string{".codegov.yml", ".codegov.yaml", "codeinventory.json"}
+
+// maxRepoMetadataSize bounds how much of a metadata file is read
+const maxRepoMetadataSize = 64 << 10
+
+// RepoMetadata is the release metadata maintainers commit next to their code in a
+// .codegov.yml or codeinventory.json file. Fields that are set replace the generated
+// values; Contact is merged field by field.
+type RepoMetadata struct {
+	Version               string                 `json:"version,omitempty"`
+	Organization          string                 `json:"organization,omitempty"`
+	Description           string                 `json:"description,omitempty"`
+	LaborHours            *float64               `json:"laborHours,omitempty"`
+	Tags                  []string               `json:"tags,omitempty"`
+	Contact               *Contact               `json:"contact,omitempty"`
+	Partners              []Partner              `json:"partners,omitempty"`
+	UsageType             string                 `json:"usageType,omitempty"`
+	ExemptionText         string                 `json:"exemptionText,omitempty"`
+	Licenses              []License              `json:"licenses,omitempty"`
+	Permissions           *Permissions           `json:"permissions,omitempty"` // code.gov release layout, as in codeinventory.json
+	Status                string                 `json:"status,omitempty"`
+	HomepageURL           string                 `json:"homepageURL,omitempty"`
+	DisclaimerURL         string                 `json:"disclaimerURL,omitempty"`
+	DisclaimerText        string                 `json:"disclaimerText,omitempty"`
+	Languages             []string               `json:"languages,omitempty"`
+	RelatedCode           []RelatedCode          `json:"relatedCode,omitempty"`
+	ReusedCode            []ReusedCode           `json:"reusedCode,omitempty"`
+	AdditionalInformation map[string]interface{} `json:"additionalInformation,omitempty"`
+}
+
+// ProviderFileReader is implemented by providers that can read files from a repository's
+// default branch, enabling per-repository metadata files
+type ProviderFileReader interface {
+	// ReadFile returns the content of a file in the repository root, or nil if it does not exist
+	ReadFile(client *http.Client, repo ProviderRepository, name string) ([]byte, error)
+}
+
+// ParseRepoMetadata decodes a metadata file; name selects JSON (.json) or YAML.
+// YAML files are checked for unknown keys to catch typos.
+func ParseRepoMetadata(name string, data []byte) (*RepoMetadata, error) {
+	var meta RepoMetadata
+
+	if strings.HasSuffix(name, ".json") {
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return &meta, nil
+	}
+
+	doc, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if doc == nil {
+		return &meta, nil
+	}
+	if _, ok := doc.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("%s: expected a mapping at the top level", name)
+	}
+
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(encoded))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&meta); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return &meta, nil
+}
+
+// Apply merges the metadata into a generated release
+func (m *RepoMetadata) Apply(release *Release) {
+	if m.Version != "" {
+		release.Version = m.Version
+	}
+	if m.Organization != "" {
+		release.Organization = m.Organization
+	}
+	if m.Description != "" {
+		release.Description = m.Description
+	}
+	if m.LaborHours != nil {
+		release.LaborHours = *m.LaborHours
+	}
+	if len(m.Tags) > 0 {
+		release.Tags = m.Tags
+	}
+	if c := m.Contact; c != nil {
+		if c.Email != "" {
+			release.Contact.Email = c.Email
+		}
+		if c.Name != "" {
+			release.Contact.Name = c.Name
+		}
+		if c.URL != "" {
+			release.Contact.URL = c.URL
+		}
+		if c.Phone != "" {
+			release.Contact.Phone = c.Phone
+		}
+	}
+	if len(m.Partners) > 0 {
+		release.Partners = m.Partners
+	}
+	if p := m.Permissions; p != nil {
+		if p.UsageType != "" {
+			release.Permissions.UsageType = p.UsageType
+		}
+		if len(p.Licenses) > 0 {
+			release.Permissions.Licenses = p.Licenses
+		}
+		if p.ExemptionText != "" {
+			release.Permissions.ExemptionText = p.ExemptionText
+		}
+	}
+	if m.UsageType != "" {
+		release.Permissions.UsageType = m.UsageType
+	}
+	if m.ExemptionText != "" {
+		release.Permissions.ExemptionText = m.ExemptionText
+	}
+	// An exemption justification only accompanies exempt usage types
+	if !ExemptUsageType(release.Permissions.UsageType) {
+		release.Permissions.ExemptionText = ""
+	}
+	if len(m.Licenses) > 0 {
+		release.Permissions.Licenses = m.Licenses
+	}
+	if m.Status != "" {
+		release.Status = m.Status
+	}
+	if m.HomepageURL != "" {
+		release.HomepageURL = m.HomepageURL
+	}
+	if m.DisclaimerURL != "" {
+		release.DisclaimerURL = m.DisclaimerURL
+	}
+	if m.DisclaimerText != "" {
+		release.DisclaimerText = m.DisclaimerText
+	}
+	if len(m.Languages) > 0 {
+		release.Languages = m.Languages
+	}
+	if len(m.RelatedCode) > 0 {
+		release.RelatedCode = m.RelatedCode
+	}
+	if len(m.ReusedCode) > 0 {
+		release.ReusedCode = m.ReusedCode
+	}
+	if len(m.AdditionalInformation) > 0 {
+		release.AdditionalInformation = m.AdditionalInformation
+	}
+}
+
+// applyRepoMetadata reads the first metadata file found with read and merges it into release.
+// A malformed file is logged and skipped so one repository cannot fail the run.
+func (g *generator) applyRepoMetadata(release *Release, repoName string, read func(name string) ([]byte, error)) {
+	if g.opts.SkipRepoMetadata {
+		return
+	}
+
+	for _, name := range repoMetadataFiles {
+		data, err := read(name)
+		if err != nil {
+			g.logger.Printf("Error reading %s in %s: %v\n", name, repoName, err)
+			g.enrichmentError(repoName, "metadata", fmt.Errorf("reading %s: %w", name, err))
+			return
+		}
+		if data == nil {
+			continue
+		}
+
+		meta, err := ParseRepoMetadata(name, data)
+		if err != nil {
+			g.logger.Printf("Ignoring metadata for %s: %v\n", repoName, err)
+			g.enrichmentError(repoName, "metadata", err)
+			return
+		}
+		meta.Apply(release)
+		return
+	}
+}
+
+// readRepoFile sends a file request, returning nil content when the file does not exist
+func readRepoFile(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := doAPIRequest(client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, nil)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxRepoMetadataSize))
+}
+
+// gitHubRoot lists the root of a GitHub repository's branch once and reads files from
+// it, so repositories without metadata or extra license files cost a single request
+type gitHubRoot struct {
+	client   *http.Client
+	fullName string
+	branch   string
+	files    map[string]bool // nil until listed
+}
+
+// gitHubFileReader returns a reader for files in a GitHub repository's root
+func gitHubFileReader(client *http.Client, fullName, branch string) func(name string) ([]byte, error) {
+	return (&gitHubRoot{client: client, fullName: fullName, branch: branch}).read
+}
+
+// contentsURL is the contents API URL of a root file, or of the root itself when name is empty
+func (r *gitHubRoot) contentsURL(name string) string {
+	uri := fmt.Sprintf("%s/repos/%s/contents", gitHubAPIOf(r.client).baseURI(), r.fullName)
+	if name != "" {
+		uri += "/" + url.PathEscape(name)
+	}
+	return uri + "?ref=" + url.QueryEscape(r.branch)
+}
+
+// list returns the names in the root, sorted
+func (r *gitHubRoot) list() ([]string, error) {
+	if r.files == nil {
+		req, err := http.NewRequest("GET", r.contentsURL(""), nil)
+		if err != nil {
+			return nil, err
+		}
+		setClientHeaders(req)
+
+		data, err := readRepoFile(r.client, req)
+		if err != nil {
+			return nil, err
+		}
+		var entries []struct {
+			Name string `json:"name"`
+		}
+		// An empty repository has no contents and answers 404
+		if data != nil {
+			if err := json.Unmarshal(data, &entries); err != nil {
+				return nil, err
+			}
+		}
+		r.files = make(map[string]bool, len(entries))
+		for _, e := range entries {
+			r.files[e.Name] = true
+		}
+	}
+
+	names := make([]string, 0, len(r.files))
+	for name := range r.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// read returns a root file's raw content, or nil if the root has no such file
+func (r *gitHubRoot) read(name string) ([]byte, error) {
+	if _, err := r.list(); err != nil {
+		return nil, err
+	}
+	if !r.files[name] {
+		return nil, nil
+	}
+
+	req, err := http.NewRequest("GET", r.contentsURL(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	setClientHeaders(req)
+	req.Header.Set("Accept", "application/vnd.github.raw")
+	return readRepoFile(r.client, req)
+}
+
+// gitLabTree lists the root of a GitLab project's branch once and reads files from it
+type gitLabTree struct {
+	client    *http.Client
+	projectID string
+	branch    string
+	files     map[string]bool // Names mapped to whether they are files; nil until listed
+}
+
+// list returns the file names in the root, sorted
+func (t *gitLabTree) list() ([]string, error) {
+	if t.files == nil {
+		var entries []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		}
+		// An empty project has no tree and answers 404
+		uri := fmt.Sprintf("%s/projects/%s/repository/tree?per_page=100&ref=%s", GetGitLabBaseURI(), t.projectID, url.QueryEscape(t.branch))
+		_, err := getGitLabJSON(t.client, uri, &entries)
+		var apiErr *APIError
+		if err != nil && !(errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound) {
+			return nil, err
+		}
+		t.files = make(map[string]bool, len(entries))
+		for _, e := range entries {
+			t.files[e.Name] = e.Type == "blob"
+		}
+	}
+
+	names := make([]string, 0, len(t.files))
+	for name, file := range t.files {
+		if file {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// read returns a root file's raw content, or nil if the root has no such file
+func (t *gitLabTree) read(name string) ([]byte, error) {
+	if _, err := t.list(); err != nil {
+		return nil, err
+	}
+	if !t.files[name] {
+		return nil, nil
+	}
+
+	uri := fmt.Sprintf("%s/projects/%s/repository/files/%s/raw?ref=%s", GetGitLabBaseURI(), t.projectID, url.PathEscape(name), url.QueryEscape(t.branch))
+	req, err := newGitLabRequest(uri)
+	if err != nil {
+		return nil, err
+	}
+	return readRepoFile(t.client, req)
+}
+
+// providerFileReader returns a reader for providers implementing ProviderFileReader
+func (g *generator) providerFileReader(p Provider, repo ProviderRepository) func(name string) ([]byte, error) {
+	return func(name string) ([]byte, error) {
+		if r, ok := p.(ProviderFileReader); ok {
+			return r.ReadFile(g.client(10*time.Second), repo, name)
+		}
+		return nil, nil
+	}
+}
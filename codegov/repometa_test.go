@@ -0,0 +1,92 @@
+package codegov
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRepoMetadataYAML(t *testing.T) {
+	data := []byte(`---
+# Metadata for code.gov
+description: >
+  Collects widgets
+  from every service.
+laborHours: 350.5
+tags:
+- widgets
+- "inventory: core"   # quoted, contains a colon
+contact: {email: team@agency.gov, name: 'Widget''s Team'}
+permissions:
+  usageType: governmentWideReuse
+  licenses:
+    - name: CC0-1.0
+      URL: https://example.gov/LICENSE
+status: Development
+version: 2.1.0
+partners:
+  - name: Department of Widgets
+    email: partners@widgets.gov
+relatedCode:
+  - name: widget-ui
+    URL: https://example.gov/widget-ui
+    isGovernmentRepo: true
+additionalInformation:
+  program: Widgets
+`)
+
+	meta, err := ParseRepoMetadata(".codegov.yml", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release := Release{
+		Description: "No description provided",
+		LaborHours:  1,
+		Tags:        []string{"none"},
+		Contact:     Contact{Email: "code@agency.gov", Phone: "555-0100"},
+		Permissions: Permissions{Licenses: []License{{Name: "MIT"}}, UsageType: "openSource"},
+		Status:      "Production",
+	}
+	meta.Apply(&release)
+
+	if release.Description != "Collects widgets from every service.\n" {
+		t.Errorf("unexpected description %q", release.Description)
+	}
+	if release.LaborHours != 350.5 || strings.Join(release.Tags, "|") != "widgets|inventory: core" {
+		t.Errorf("unexpected labor hours or tags: %v %v", release.LaborHours, release.Tags)
+	}
+	if release.Contact != (Contact{Email: "team@agency.gov", Name: "Widget's Team", Phone: "555-0100"}) {
+		t.Errorf("contact not merged: %+v", release.Contact)
+	}
+	if release.Permissions.UsageType != "governmentWideReuse" || release.Permissions.Licenses[0].Name != "CC0-1.0" {
+		t.Errorf("unexpected permissions %+v", release.Permissions)
+	}
+	if release.Status != "Development" {
+		t.Errorf("unexpected status %s", release.Status)
+	}
+	if release.Version != "2.1.0" || len(release.Partners) != 1 || release.Partners[0].Email != "partners@widgets.gov" {
+		t.Errorf("unexpected version or partners %s %+v", release.Version, release.Partners)
+	}
+	if len(release.RelatedCode) != 1 || !release.RelatedCode[0].IsGovernmentRepo || release.AdditionalInformation["program"] != "Widgets" {
+		t.Errorf("unexpected related code or additional information %+v %v", release.RelatedCode, release.AdditionalInformation)
+	}
+}
+
+func TestParseRepoMetadataErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"typo":        "labourHours: 10\n",
+		"bad indent":  "contact:\n  email: a@b.gov\n    name: x\n",
+		"not a map":   "- tags\n",
+		"bad quoting": "description: \"unterminated\n",
+	} {
+		if _, err := ParseRepoMetadata(".codegov.yml", []byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// codeinventory.json is third-party, so unknown fields are ignored
+	meta, err := ParseRepoMetadata("codeinventory.json", []byte(`{"name":"widget","usageType":"openSource","tags":["a"]}`))
+	if err != nil || meta.UsageType != "openSource" || len(meta.Tags) != 1 {
+		t.Errorf("unexpected codeinventory.json result %+v, %v", meta, err)
+	}
+}
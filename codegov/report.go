@@ -0,0 +1,113 @@
+package codegov
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// IssueSeverity says whether an issue cost the inventory a release or only some of its data
+type IssueSeverity string
+
+const (
+	SeverityError   IssueSeverity = "error"   // An organization or repository was left out
+	SeverityWarning IssueSeverity = "warning" // A release was published with incomplete data
+)
+
+// Report stages beyond the enrichment stages counted by Metrics (languages, license,
+// release, analysis and metadata)
+const (
+	StageList  = "list"  // Listing an organization's repositories
+	StageBuild = "build" // Building a repository's release
+)
+
+// ReportIssue is one failure recorded during a generation run
+type ReportIssue struct {
+	Severity IssueSeverity `json:"severity"`
+	Org      string        `json:"org,omitempty"`  // Set for organization failures
+	Repo     string        `json:"repo,omitempty"` // "org/repo" or the project path
+	Stage    string        `json:"stage"`
+	Message  string        `json:"message"`
+	Err      error         `json:"-"`
+}
+
+// GenerationReport records what a generation run left out or published incomplete,
+// which Generate only logs
+type GenerationReport struct {
+	Organizations       int              `json:"organizations"`
+	FailedOrganizations int              `json:"failed_organizations"`
+	Repositories        int              `json:"repositories"` // Selected for the inventory
+	Releases            int              `json:"releases"`
+	DeadLinks           int              `json:"dead_links,omitempty"` // Found when GenerateOptions.CheckURLs is set
+	Digests             []DownloadDigest `json:"digests,omitempty"`    // Taken when GenerateOptions.DownloadDigests is set
+	Removed             []RemovedRelease `json:"removed,omitempty"`    // Set when GenerateOptions.TombstoneDays is
+	Issues              []ReportIssue    `json:"issues,omitempty"`
+
+	mu sync.Mutex
+}
+
+// add records an issue; safe for concurrent use by enrichment workers and a no-op on nil
+func (r *GenerationReport) add(issue ReportIssue) {
+	if r == nil {
+		return
+	}
+	if issue.Err != nil && issue.Message == "" {
+		issue.Message = issue.Err.Error()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Issues = append(r.Issues, issue)
+}
+
+// sort orders issues by organization, repository and stage, so reports of runs with
+// concurrent enrichment are stable
+func (r *GenerationReport) sort() {
+	sort.SliceStable(r.Issues, func(i, j int) bool {
+		a, b := r.Issues[i], r.Issues[j]
+		if a.Org != b.Org {
+			return a.Org < b.Org
+		}
+		if a.Repo != b.Repo {
+			return a.Repo < b.Repo
+		}
+		return a.Stage < b.Stage
+	})
+}
+
+// filter returns the issues of a severity
+func (r *GenerationReport) filter(severity IssueSeverity) []ReportIssue {
+	var issues []ReportIssue
+	for _, issue := range r.Issues {
+		if issue.Severity == severity {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// Errors returns the organizations and repositories left out of the inventory
+func (r *GenerationReport) Errors() []ReportIssue {
+	return r.filter(SeverityError)
+}
+
+// Warnings returns the enrichment steps that failed for published releases
+func (r *GenerationReport) Warnings() []ReportIssue {
+	return r.filter(SeverityWarning)
+}
+
+// Clean reports whether the run recorded no issues
+func (r *GenerationReport) Clean() bool {
+	return len(r.Issues) == 0
+}
+
+// Summary describes the run in one line
+func (r *GenerationReport) Summary() string {
+	return fmt.Sprintf("%d organizations (%d failed), %d repositories, %d releases, %d errors, %d warnings",
+		r.Organizations, r.FailedOrganizations, r.Repositories, r.Releases, len(r.Errors()), len(r.Warnings()))
+}
+
+// enrichmentError counts a failed enrichment step and records it as a warning
+func (g *generator) enrichmentError(repo, stage string, err error) {
+	defaultMetrics.addEnrichmentError(stage)
+	g.report.add(ReportIssue{Severity: SeverityWarning, Repo: repo, Stage: stage, Err: err})
+}
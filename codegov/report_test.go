@@ -0,0 +1,96 @@
+package codegov
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// missingOrgTransport answers 404 for the "missing" organization and replays the rest
+type missingOrgTransport struct {
+	rec *Recorder
+}
+
+func (t missingOrgTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.Contains(req.URL.Path, "/orgs/missing/") {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"message":"Not Found"}`)),
+			Request:    req,
+		}, nil
+	}
+	return t.rec.RoundTrip(req)
+}
+
+func TestGenerateWithReport(t *testing.T) {
+	rec, err := NewRecorder(filepath.Join("testdata", "github-org.json"), RecorderModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codeGov, report, err := GenerateWithReport(GenerateOptions{
+		Organizations: []string{"testorg", "missing"},
+		Agency:        "TEST",
+		Contact:       Contact{Email: "code@test.gov"},
+		HTTPClient:    &http.Client{Transport: missingOrgTransport{rec: rec}},
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(codeGov.Releases) != 1 {
+		t.Fatalf("expected the reachable organization's release, got %d", len(codeGov.Releases))
+	}
+
+	if report.Organizations != 2 || report.FailedOrganizations != 1 || report.Repositories != 1 || report.Releases != 1 {
+		t.Errorf("unexpected counts: %s", report.Summary())
+	}
+	errs := report.Errors()
+	if len(errs) != 1 || errs[0].Org != "missing" || errs[0].Stage != StageList || !errors.Is(errs[0].Err, ErrOrgNotFound) {
+		t.Fatalf("unexpected errors %+v", errs)
+	}
+	if report.Clean() {
+		t.Error("a report with errors is not clean")
+	}
+}
+
+func TestGenerationReportSeverities(t *testing.T) {
+	report := &GenerationReport{}
+	report.add(ReportIssue{Severity: SeverityWarning, Repo: "org/b", Stage: "license", Err: errors.New("timeout")})
+	report.add(ReportIssue{Severity: SeverityError, Repo: "org/a", Stage: StageBuild, Err: errors.New("boom")})
+	report.sort()
+
+	if report.Issues[0].Repo != "org/a" || report.Issues[1].Message != "timeout" {
+		t.Errorf("unexpected issues %+v", report.Issues)
+	}
+	if len(report.Errors()) != 1 || len(report.Warnings()) != 1 {
+		t.Errorf("unexpected split: %s", report.Summary())
+	}
+
+	var nilReport *GenerationReport
+	nilReport.add(ReportIssue{Severity: SeverityError}) // Generators built without a report ignore issues
+}
+
+func TestGenerateCanceled(t *testing.T) {
+	rec, err := NewRecorder(filepath.Join("testdata", "github-org.json"), RecorderModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	codeGov, _, err := GenerateWithReport(GenerateOptions{
+		Organizations: []string{"testorg"},
+		Agency:        "TEST",
+		Contact:       Contact{Email: "code@test.gov"},
+		HTTPClient:    &http.Client{Transport: rec},
+		Context:       ctx,
+	})
+	if codeGov != nil || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a canceled run, got %v", err)
+	}
+}
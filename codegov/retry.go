@@ -0,0 +1,157 @@
+package codegov
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how API requests are retried after network errors and
+// 5xx responses. Rate limits are handled separately and do not count as attempts.
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts per request, including the first; 1 disables retries
+	BaseDelay   time.Duration // Wait before the first retry, doubled for each further retry
+	MaxDelay    time.Duration // Upper bound on a single wait
+	Jitter      float64       // Fraction of each wait that is randomized, from 0 to 1
+}
+
+// DefaultRetryPolicy retries a request twice, after about one and then two seconds
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+	Jitter:      0.5,
+}
+
+var (
+	retryPolicyMu sync.RWMutex
+	retryPolicy   = DefaultRetryPolicy
+
+	// retryWait is replaced in tests to avoid real waits
+	retryWait = waitContext
+)
+
+// SetRetryPolicy sets the retry behavior of API requests made outside generation runs,
+// and of runs without GenerateOptions.Retry. Zero fields take their values from
+// DefaultRetryPolicy.
+func SetRetryPolicy(policy RetryPolicy) {
+	policy = policy.withDefaults()
+
+	retryPolicyMu.Lock()
+	defer retryPolicyMu.Unlock()
+	retryPolicy = policy
+}
+
+// withDefaults returns the policy with zero fields taken from DefaultRetryPolicy and
+// Jitter clamped to [0, 1]
+func (policy RetryPolicy) withDefaults() RetryPolicy {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = DefaultRetryPolicy.BaseDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	if policy.Jitter < 0 {
+		policy.Jitter = 0
+	}
+	if policy.Jitter > 1 {
+		policy.Jitter = 1
+	}
+	return policy
+}
+
+func getRetryPolicy() RetryPolicy {
+	retryPolicyMu.RLock()
+	defer retryPolicyMu.RUnlock()
+	return retryPolicy
+}
+
+// delay returns the wait before retry number n (0 for the first retry): the
+// exponential backoff capped at MaxDelay, with up to Jitter of it randomly removed
+func (p RetryPolicy) delay(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 0; i < n && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// transient reports whether a request that failed with err or returned resp may
+// succeed if sent again
+func transient(resp *http.Response, err error) bool {
+	if err != nil {
+		// Client timeouts are retried; a request cancelled by its caller is not
+		return !errors.Is(err, context.Canceled)
+	}
+	switch resp.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// waitContext waits for d, returning early with false once ctx is done
+func waitContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// doWithRetry sends req, retrying network errors and 5xx responses with backoff.
+// A run's client retries with the run's policy and stops waiting when the run is
+// canceled. The final response or error is returned as is, so callers report it as before.
+func doWithRetry(client *http.Client, req *http.Request) (*http.Response, error) {
+	policy, ctx := getRetryPolicy(), req.Context()
+	if run := runOf(client); run != nil {
+		policy, ctx = run.retry, run.ctx
+	}
+	for attempt := 1; ; attempt++ {
+		resp, err := client.Do(req)
+		if attempt >= policy.MaxAttempts || !transient(resp, err) {
+			return resp, err
+		}
+
+		// Requests with a body, such as GraphQL queries, need it rewound before a retry
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return resp, err
+			}
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return resp, err
+			}
+			req.Body = body
+		}
+
+		wait := policy.delay(attempt - 1)
+		if err != nil {
+			log.Printf("Request to %s%s failed (%s), retrying in %s (attempt %d of %d)\n",
+				req.URL.Host, req.URL.Path, redactToken(err.Error()), wait.Round(time.Millisecond), attempt+1, policy.MaxAttempts)
+		} else {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			log.Printf("Request to %s%s returned %d, retrying in %s (attempt %d of %d)\n",
+				req.URL.Host, req.URL.Path, resp.StatusCode, wait.Round(time.Millisecond), attempt+1, policy.MaxAttempts)
+		}
+		if !retryWait(ctx, wait) {
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package codegov
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stubRetrySleep records retry waits instead of sleeping
+func stubRetrySleep(t *testing.T) *[]time.Duration {
+	var waits []time.Duration
+	retryWait = func(ctx context.Context, d time.Duration) bool {
+		waits = append(waits, d)
+		return true
+	}
+	t.Cleanup(func() { retryWait = waitContext })
+	return &waits
+}
+
+func TestRetryTransientGitHubErrors(t *testing.T) {
+	waits := stubRetrySleep(t)
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute})
+	defer SetRetryPolicy(DefaultRetryPolicy)
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`[{"name":"api"}]`))
+	}))
+	defer srv.Close()
+	SetGitHubConfig(GitHubConfig{BaseURI: srv.URL})
+	defer SetGitHubConfig(GitHubConfig{})
+
+	repos, err := getGitHubRepositories(srv.Client(), "testorg")
+	if err != nil {
+		t.Fatalf("expected the page to be retried, got %v", err)
+	}
+	if len(repos) != 1 || calls != 3 {
+		t.Errorf("got %d repositories after %d calls, want 1 after 3", len(repos), calls)
+	}
+	if len(*waits) != 2 || (*waits)[0] != time.Second || (*waits)[1] != 2*time.Second {
+		t.Errorf("waits = %v, want [1s 2s] without jitter", *waits)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	stubRetrySleep(t)
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 2})
+	defer SetRetryPolicy(DefaultRetryPolicy)
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	SetGitHubConfig(GitHubConfig{BaseURI: srv.URL})
+	defer SetGitHubConfig(GitHubConfig{})
+
+	_, err := getGitHubRepositories(srv.Client(), "testorg")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected the last 503 as an APIError, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
+func TestRetryNotFoundIsNotRetried(t *testing.T) {
+	waits := stubRetrySleep(t)
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	if probeURL(srv.Client(), srv.URL+"/LICENSE") {
+		t.Fatal("expected probe to fail")
+	}
+	if calls != 1 || len(*waits) != 0 {
+		t.Errorf("404 was sent %d times with waits %v, want once", calls, *waits)
+	}
+}
+
+func TestRetryNetworkErrorRewindsBody(t *testing.T) {
+	stubRetrySleep(t)
+
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			// Drop the connection without a response
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	defer srv.Close()
+	SetGitHubConfig(GitHubConfig{BaseURI: srv.URL})
+	defer SetGitHubConfig(GitHubConfig{})
+
+	var out struct{}
+	if err := gitHubGraphQL(srv.Client(), "query { viewer { login } }", nil, &out); err != nil {
+		t.Fatalf("expected the query to be retried, got %v", err)
+	}
+	if len(bodies) != 2 || bodies[0] != bodies[1] || bodies[1] == "" {
+		t.Errorf("bodies = %q, want the same query twice", bodies)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second, Jitter: 0.5}
+	for n, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if d := policy.delay(n); d > max || d < max/2 {
+				t.Fatalf("delay(%d) = %s, want between %s and %s", n, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestRetryRunPolicy(t *testing.T) {
+	waits := stubRetrySleep(t)
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	// Two runs with different policies share the package's default policy
+	for _, tt := range []struct {
+		retry RetryPolicy
+		calls int
+		waits []time.Duration
+	}{
+		{RetryPolicy{}, DefaultRetryPolicy.MaxAttempts, nil},
+		{RetryPolicy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 3 * time.Second}, 4, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}},
+	} {
+		calls, *waits = 0, nil
+		g := &generator{opts: GenerateOptions{HTTPClient: srv.Client()}, creds: Credentials{}.resolve(), retry: getRetryPolicy()}
+		if tt.retry != (RetryPolicy{}) {
+			g.retry = tt.retry.withDefaults()
+		}
+		if probeURL(g.client(time.Second), srv.URL) {
+			t.Fatal("expected probe to fail")
+		}
+		if calls != tt.calls {
+			t.Errorf("Retry %+v: calls = %d, want %d", tt.retry, calls, tt.calls)
+		}
+		if tt.waits != nil && (len(*waits) != len(tt.waits) || (*waits)[0] != tt.waits[0] || (*waits)[2] != tt.waits[2]) {
+			t.Errorf("Retry %+v: waits = %v, want %v", tt.retry, *waits, tt.waits)
+		}
+	}
+}
+
+func TestRetryWaitStopsOnCancel(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g := &generator{
+		opts:  GenerateOptions{HTTPClient: srv.Client(), Context: ctx},
+		creds: Credentials{}.resolve(),
+		retry: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour},
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	_, err := doWithRetry(g.client(time.Second), req)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second || calls != 1 {
+		t.Errorf("returned after %s and %d calls, want the hour-long wait cut short after 1", elapsed, calls)
+	}
+}
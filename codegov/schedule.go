@@ -0,0 +1,315 @@
+package codegov
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultScheduleKeep is the number of inventory versions a Scheduler keeps by default
+const DefaultScheduleKeep = 7
+
+// versionTimeFormat names version files, e.g. code-20261018T120000Z.json; it sorts by time
+const versionTimeFormat = "20060102T150405Z"
+
+// ScheduleOptions configures a Scheduler
+type ScheduleOptions struct {
+	Generate GenerateOptions // Options of every run; Context is replaced by the run's context and Previous defaults to the newest version
+	Interval time.Duration   // Time between the start of one run and the next
+	Dir      string          // Holds one code-<UTC time>.json file per version
+	Keep     int             // Versions kept, oldest deleted first; defaults to DefaultScheduleKeep
+	Logger   *log.Logger     // Defaults to log.Default()
+}
+
+// InventoryVersion is one inventory a Scheduler has generated
+type InventoryVersion struct {
+	Name      string    `json:"name"` // File name in ScheduleOptions.Dir
+	Generated time.Time `json:"generated"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+}
+
+// ScheduleStatus describes a Scheduler's versions and runs
+type ScheduleStatus struct {
+	Versions    []InventoryVersion `json:"versions"` // Newest first
+	LastRun     time.Time          `json:"last_run,omitempty"`
+	LastSummary string             `json:"last_summary,omitempty"` // GenerationReport.Summary of the last run
+	LastError   string             `json:"last_error,omitempty"`   // Set when the last run failed
+	NextRun     time.Time          `json:"next_run,omitempty"`
+}
+
+// Scheduler regenerates an inventory on a schedule, keeps the last versions on disk
+// and serves the newest over HTTP. A failed run leaves the previous version in place.
+// Versions already in the directory are picked up on start, so a restarted scheduler
+// serves the last inventory right away.
+type Scheduler struct {
+	opts   ScheduleOptions
+	logger *log.Logger
+	now    func() time.Time
+
+	mu       sync.RWMutex
+	latest   []byte
+	status   ScheduleStatus
+	versions []InventoryVersion // Oldest first
+}
+
+// NewScheduler creates a scheduler and loads the versions already in opts.Dir
+func NewScheduler(opts ScheduleOptions) (*Scheduler, error) {
+	if opts.Interval <= 0 {
+		return nil, fmt.Errorf("schedule interval must be positive")
+	}
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("schedule directory is required")
+	}
+	if err := opts.Generate.validate(); err != nil {
+		return nil, err
+	}
+	if opts.Keep <= 0 {
+		opts.Keep = DefaultScheduleKeep
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	s := &Scheduler{opts: opts, logger: logger, now: time.Now}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the versions in the directory and the newest one's content
+func (s *Scheduler) load() error {
+	paths, err := filepath.Glob(filepath.Join(s.opts.Dir, "code-*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		name := filepath.Base(path)
+		generated, err := time.Parse(versionTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, "code-"), ".json"))
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		s.versions = append(s.versions, InventoryVersion{Name: name, Generated: generated, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])})
+		s.latest = data
+	}
+	return nil
+}
+
+// Run generates an inventory at once and then every interval until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+	for {
+		s.mu.Lock()
+		s.status.NextRun = s.now().Add(s.opts.Interval)
+		s.mu.Unlock()
+
+		if err := s.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Printf("Scheduled generation failed: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce generates the inventory and, unless it is identical to the newest version,
+// stores it as a new version
+func (s *Scheduler) RunOnce(ctx context.Context) error {
+	opts := s.opts.Generate
+	opts.Context = ctx
+	started := s.now()
+
+	// Tombstones carry over from the newest version, so they expire across restarts
+	s.mu.RLock()
+	latest := s.latest
+	s.mu.RUnlock()
+	if opts.TombstoneDays > 0 && opts.Previous == nil && latest != nil {
+		var previous CodeGovJSON
+		if err := json.Unmarshal(latest, &previous); err != nil {
+			s.logger.Printf("Failed to read the newest version for tombstones: %v\n", err)
+		} else {
+			opts.Previous = &previous
+		}
+	}
+	codeGov, report, err := GenerateWithReport(opts)
+
+	var data []byte
+	if err == nil {
+		data, err = MarshalCodeGovJSON(codeGov, EncodeOptions{Compact: opts.Compact})
+	}
+	if err == nil {
+		err = s.store(data, started)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.LastRun = started
+	s.status.LastSummary = ""
+	if report != nil {
+		s.status.LastSummary = report.Summary()
+	}
+	s.status.LastError = ""
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+	return err
+}
+
+// store writes data as a new version and deletes the versions beyond Keep
+func (s *Scheduler) store(data []byte, generated time.Time) error {
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	s.mu.RLock()
+	unchanged := len(s.versions) > 0 && s.versions[len(s.versions)-1].SHA256 == checksum
+	s.mu.RUnlock()
+	if unchanged {
+		s.logger.Printf("Inventory unchanged since the last version\n")
+		return nil
+	}
+
+	version := InventoryVersion{
+		Name:      "code-" + generated.UTC().Format(versionTimeFormat) + ".json",
+		Generated: generated.UTC().Truncate(time.Second),
+		Size:      int64(len(data)),
+		SHA256:    checksum,
+	}
+	if err := writeFileAtomic(filepath.Join(s.opts.Dir, version.Name), data); err != nil {
+		return err
+	}
+	s.logger.Printf("Stored inventory version %s\n", version.Name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n := len(s.versions); n > 0 && s.versions[n-1].Name == version.Name {
+		s.versions = s.versions[:n-1] // Replaced within the same second
+	}
+	s.versions = append(s.versions, version)
+	s.latest = data
+	for len(s.versions) > s.opts.Keep {
+		if err := os.Remove(filepath.Join(s.opts.Dir, s.versions[0].Name)); err != nil && !os.IsNotExist(err) {
+			s.logger.Printf("Failed to delete inventory version %s: %v\n", s.versions[0].Name, err)
+		}
+		s.versions = s.versions[1:]
+	}
+	return nil
+}
+
+// Status returns the scheduler's versions and the outcome of its last run
+func (s *Scheduler) Status() ScheduleStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := s.status
+	status.Versions = make([]InventoryVersion, 0, len(s.versions))
+	for i := len(s.versions) - 1; i >= 0; i-- {
+		status.Versions = append(status.Versions, s.versions[i])
+	}
+	return status
+}
+
+// Handler serves the newest inventory at /code.json, the status at /versions and
+// each kept version at /versions/<name>
+func (s *Scheduler) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/code.json", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		data := s.latest
+		var version InventoryVersion
+		if n := len(s.versions); n > 0 {
+			version = s.versions[n-1]
+		}
+		s.mu.RUnlock()
+
+		if data == nil {
+			http.Error(w, "no inventory has been generated yet", http.StatusServiceUnavailable)
+			return
+		}
+		serveVersion(w, r, version, data)
+	})
+	mux.HandleFunc("/versions", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Status())
+	})
+	mux.HandleFunc("/versions/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/versions/")
+		s.mu.RLock()
+		var version *InventoryVersion
+		for i := range s.versions {
+			if s.versions[i].Name == name {
+				v := s.versions[i]
+				version = &v
+				break
+			}
+		}
+		s.mu.RUnlock()
+
+		if version == nil {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := os.ReadFile(filepath.Join(s.opts.Dir, version.Name))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		serveVersion(w, r, *version, data)
+	})
+	return mux
+}
+
+// serveVersion serves an inventory with its digest as the ETag, so harvesters can
+// make conditional requests
+func serveVersion(w http.ResponseWriter, r *http.Request, version InventoryVersion, data []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	if version.SHA256 != "" {
+		w.Header().Set("ETag", `"`+version.SHA256+`"`)
+	}
+	http.ServeContent(w, r, "code.json", version.Generated, bytes.NewReader(data))
+}
+
+// writeFileAtomic replaces path through a temporary file in the same directory, so
+// readers never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
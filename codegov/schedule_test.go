@@ -0,0 +1,132 @@
+package codegov
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestScheduler(t *testing.T, dir string) *Scheduler {
+	t.Helper()
+
+	rec, err := NewRecorder(filepath.Join("testdata", "github-org.json"), RecorderModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewScheduler(ScheduleOptions{
+		Generate: GenerateOptions{
+			Organizations: []string{"testorg"},
+			Agency:        "TEST",
+			Contact:       Contact{Email: "code@test.gov"},
+			HTTPClient:    &http.Client{Transport: rec},
+			Logger:        log.New(io.Discard, "", 0),
+		},
+		Interval: time.Hour,
+		Dir:      dir,
+		Keep:     2,
+		Logger:   log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestScheduler(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestScheduler(t, dir)
+	clock := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return clock }
+
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+	if resp, err := http.Get(srv.URL + "/code.json"); err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 before the first run, got %v %v", resp, err)
+	}
+
+	// Each run with a changed inventory adds a version; an unchanged one does not
+	for i, organization := range []string{"", "", "Division A", "Division B"} {
+		clock = clock.Add(24 * time.Hour)
+		s.opts.Generate.Organization = organization
+		if err := s.RunOnce(context.Background()); err != nil {
+			t.Fatalf("run %d failed: %v", i, err)
+		}
+	}
+
+	status := s.Status()
+	if len(status.Versions) != 2 || status.Versions[0].Name != "code-20261005T120000Z.json" || status.Versions[1].Name != "code-20261004T120000Z.json" {
+		t.Fatalf("unexpected versions %+v", status.Versions)
+	}
+	if status.LastError != "" || status.LastSummary == "" || !status.LastRun.Equal(clock) {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "code-*.json")); len(files) != 2 {
+		t.Errorf("expected old versions to be deleted, got %v", files)
+	}
+
+	resp, err := http.Get(srv.URL + "/code.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var codeGov CodeGovJSON
+	json.NewDecoder(resp.Body).Decode(&codeGov)
+	resp.Body.Close()
+	etag := resp.Header.Get("ETag")
+	if etag != `"`+status.Versions[0].SHA256+`"` || len(codeGov.Releases) != 1 || codeGov.Releases[0].Organization != "Division B" {
+		t.Fatalf("unexpected latest inventory (ETag %s): %+v", etag, codeGov)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/code.json", nil)
+	req.Header.Set("If-None-Match", etag)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching ETag, got %v %v", resp, err)
+	}
+
+	resp, err = http.Get(srv.URL + "/versions/code-20261004T120000Z.json")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("older version: %v %v", resp, err)
+	}
+	json.NewDecoder(resp.Body).Decode(&codeGov)
+	resp.Body.Close()
+	if codeGov.Releases[0].Organization != "Division A" {
+		t.Errorf("unexpected older version %+v", codeGov.Releases[0])
+	}
+	if resp, _ := http.Get(srv.URL + "/versions/code-20261002T120000Z.json"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted version served with %d", resp.StatusCode)
+	}
+
+	// A restarted scheduler serves the newest version before its first run
+	restarted := newTestScheduler(t, dir)
+	if len(restarted.Status().Versions) != 2 || string(restarted.latest) != string(s.latest) {
+		t.Errorf("versions not reloaded: %+v", restarted.Status())
+	}
+}
+
+func TestSchedulerKeepsVersionOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestScheduler(t, dir)
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	latest := s.latest
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.opts.Generate.Organization = "Division A"
+	if err := s.RunOnce(ctx); err == nil {
+		t.Fatal("expected the run to fail")
+	}
+	if status := s.Status(); status.LastError == "" || len(status.Versions) != 1 || string(s.latest) != string(latest) {
+		t.Errorf("failed run changed the versions: %+v", status)
+	}
+	if _, err := os.Stat(filepath.Join(dir, s.Status().Versions[0].Name)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,394 @@
+package codegov
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed schema/code.json-2.0.0.json
+var codeGovSchema200 []byte
+
+// Schema is a JSON Schema document used to validate code.gov inventories.
+// It supports the draft-04/07 keywords used by the code.gov schemas: $ref (local),
+// type, enum, const, required, properties, additionalProperties, items, allOf,
+// anyOf, oneOf, not, length/size/range bounds, pattern and the uri, email, date
+// and date-time formats. Both the draft-04 boolean and the later numeric forms of
+// exclusiveMinimum and exclusiveMaximum are understood.
+type Schema struct {
+	root map[string]interface{}
+}
+
+// DefaultSchema returns the embedded official code.gov 2.0.0 schema
+func DefaultSchema() *Schema {
+	s, err := ParseSchema(codeGovSchema200)
+	if err != nil {
+		panic(fmt.Sprintf("embedded code.gov schema is invalid: %v", err))
+	}
+	return s
+}
+
+// LoadSchema reads a JSON Schema from a file, e.g. a newer or agency-specific code.gov schema
+func LoadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSchema(data)
+}
+
+// ParseSchema parses a JSON Schema document
+func ParseSchema(data []byte) (*Schema, error) {
+	var root map[string]interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+	return &Schema{root: root}, nil
+}
+
+// Validate checks a JSON document against the schema. Each returned error is
+// prefixed with the slash-separated path of the offending value, e.g.
+// "releases/12/permissions/usageType: not one of [...]".
+func (s *Schema) Validate(data []byte) ([]string, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var errs []string
+	s.validate(s.root, doc, "", nil, &errs)
+	return errs, nil
+}
+
+// validate checks v against node. refs holds the references already followed
+// for v without descending into it, so a reference cycle such as {"$ref": "#"}
+// is reported instead of recursing forever.
+func (s *Schema) validate(node map[string]interface{}, v interface{}, path string, refs map[string]bool, errs *[]string) {
+	fail := func(format string, args ...interface{}) {
+		p := path
+		if p == "" {
+			p = "(root)"
+		}
+		*errs = append(*errs, p+": "+fmt.Sprintf(format, args...))
+	}
+
+	if ref, ok := node["$ref"].(string); ok {
+		if refs[ref] {
+			fail("circular schema reference %s", ref)
+			return
+		}
+		target, err := s.resolve(ref)
+		if err != nil {
+			fail("%v", err)
+			return
+		}
+		followed := make(map[string]bool, len(refs)+1)
+		for r := range refs {
+			followed[r] = true
+		}
+		followed[ref] = true
+		s.validate(target, v, path, followed, errs)
+		return
+	}
+
+	if t, ok := node["type"]; ok && !matchesType(t, v) {
+		fail("expected %s, got %s", typeList(t), jsonType(v))
+		return
+	}
+
+	if enum, ok := node["enum"].([]interface{}); ok && !containsValue(enum, v) {
+		fail("not one of %s", formatValues(enum))
+	}
+	if c, ok := node["const"]; ok && !equalValues(c, v) {
+		fail("must equal %s", formatValues([]interface{}{c}))
+	}
+
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		subs, ok := node[key].([]interface{})
+		if !ok {
+			continue
+		}
+		matched := 0
+		for _, sub := range subs {
+			subNode, _ := sub.(map[string]interface{})
+			var subErrs []string
+			s.validate(subNode, v, path, refs, &subErrs)
+			if len(subErrs) == 0 {
+				matched++
+			} else if key == "allOf" {
+				*errs = append(*errs, subErrs...)
+			}
+		}
+		if key == "anyOf" && matched == 0 {
+			fail("does not match any allowed schema")
+		}
+		if key == "oneOf" && matched != 1 {
+			fail("must match exactly one schema, matched %d", matched)
+		}
+	}
+	if not, ok := node["not"].(map[string]interface{}); ok {
+		var subErrs []string
+		s.validate(not, v, path, refs, &subErrs)
+		if len(subErrs) == 0 {
+			fail("must not match schema")
+		}
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		s.validateObject(node, value, path, errs, fail)
+	case []interface{}:
+		s.validateArray(node, value, path, errs, fail)
+	case string:
+		validateString(node, value, fail)
+	case float64:
+		validateNumber(node, value, fail)
+	}
+}
+
+func (s *Schema) validateObject(node map[string]interface{}, obj map[string]interface{}, path string, errs *[]string, fail func(string, ...interface{})) {
+	if required, ok := node["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, present := obj[name]; !present {
+				fail("%s is required", name)
+			}
+		}
+	}
+
+	properties, _ := node["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if prop, ok := properties[k].(map[string]interface{}); ok {
+			s.validate(prop, obj[k], joinPath(path, k), nil, errs)
+			continue
+		}
+		switch additional := node["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				fail("unexpected property %s", k)
+			}
+		case map[string]interface{}:
+			s.validate(additional, obj[k], joinPath(path, k), nil, errs)
+		}
+	}
+}
+
+func (s *Schema) validateArray(node map[string]interface{}, arr []interface{}, path string, errs *[]string, fail func(string, ...interface{})) {
+	if min, ok := node["minItems"].(float64); ok && float64(len(arr)) < min {
+		fail("must contain at least %v items", min)
+	}
+	if max, ok := node["maxItems"].(float64); ok && float64(len(arr)) > max {
+		fail("must contain at most %v items", max)
+	}
+
+	switch items := node["items"].(type) {
+	case map[string]interface{}:
+		for i, item := range arr {
+			s.validate(items, item, joinPath(path, strconv.Itoa(i)), nil, errs)
+		}
+	case []interface{}:
+		for i, item := range arr {
+			if i >= len(items) {
+				break
+			}
+			if itemNode, ok := items[i].(map[string]interface{}); ok {
+				s.validate(itemNode, item, joinPath(path, strconv.Itoa(i)), nil, errs)
+			}
+		}
+	}
+}
+
+func validateString(node map[string]interface{}, str string, fail func(string, ...interface{})) {
+	length := float64(len([]rune(str)))
+	if min, ok := node["minLength"].(float64); ok && length < min {
+		fail("must be at least %v characters", min)
+	}
+	if max, ok := node["maxLength"].(float64); ok && length > max {
+		fail("must be at most %v characters", max)
+	}
+	if pattern, ok := node["pattern"].(string); ok {
+		if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(str) {
+			fail("does not match pattern %s", pattern)
+		}
+	}
+	if format, ok := node["format"].(string); ok && !matchesFormat(format, str) {
+		fail("%q is not a valid %s", str, format)
+	}
+}
+
+func validateNumber(node map[string]interface{}, n float64, fail func(string, ...interface{})) {
+	// Draft-04 makes minimum and maximum exclusive with a boolean flag
+	exclusiveMin, _ := node["exclusiveMinimum"].(bool)
+	exclusiveMax, _ := node["exclusiveMaximum"].(bool)
+	if min, ok := node["minimum"].(float64); ok {
+		if exclusiveMin && n <= min {
+			fail("must be > %v", min)
+		} else if !exclusiveMin && n < min {
+			fail("must be >= %v", min)
+		}
+	}
+	if max, ok := node["maximum"].(float64); ok {
+		if exclusiveMax && n >= max {
+			fail("must be < %v", max)
+		} else if !exclusiveMax && n > max {
+			fail("must be <= %v", max)
+		}
+	}
+	if min, ok := node["exclusiveMinimum"].(float64); ok && n <= min {
+		fail("must be > %v", min)
+	}
+	if max, ok := node["exclusiveMaximum"].(float64); ok && n >= max {
+		fail("must be < %v", max)
+	}
+}
+
+// resolve follows a local JSON pointer reference such as "#/definitions/release"
+func (s *Schema) resolve(ref string) (map[string]interface{}, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("unsupported schema reference %s", ref)
+	}
+
+	var node interface{} = s.root
+	for _, part := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolvable schema reference %s", ref)
+		}
+		node = m[part]
+	}
+
+	target, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unresolvable schema reference %s", ref)
+	}
+	return target, nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "/" + key
+}
+
+func matchesType(t interface{}, v interface{}) bool {
+	switch t := t.(type) {
+	case string:
+		return isType(t, v)
+	case []interface{}:
+		for _, name := range t {
+			if s, ok := name.(string); ok && isType(s, v) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func isType(name string, v interface{}) bool {
+	switch name {
+	case "integer":
+		n, ok := v.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	}
+	return jsonType(v) == name
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func typeList(t interface{}) string {
+	if names, ok := t.([]interface{}); ok {
+		parts := make([]string, len(names))
+		for i, n := range names {
+			parts[i] = fmt.Sprint(n)
+		}
+		return strings.Join(parts, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+func matchesFormat(format, s string) bool {
+	switch format {
+	case "uri":
+		u, err := url.Parse(s)
+		return err == nil && u.IsAbs()
+	case "email":
+		_, err := mail.ParseAddress(s)
+		return err == nil
+	case "date":
+		// code.gov accepts YYYY-MM-DD or a full ISO 8601 timestamp
+		if _, err := time.Parse("2006-01-02", s); err == nil {
+			return true
+		}
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	}
+	// Unknown formats are annotations only
+	return true
+}
+
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, candidate := range values {
+		if equalValues(candidate, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalValues(a, b interface{}) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
+
+func formatValues(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		data, _ := json.Marshal(v)
+		parts[i] = string(data)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
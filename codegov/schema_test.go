@@ -0,0 +1,171 @@
+package codegov
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// validSchemaRelease is a release meeting every requirement of the code.gov 2.0.0 schema
+const validSchemaRelease = `{"name": "widget", "repositoryURL": "https://example.gov/widget", "description": "A widget",
+	"permissions": {"licenses": [{"URL": "https://example.gov/LICENSE", "name": "MIT"}], "usageType": "openSource"},
+	"laborHours": 12.5, "tags": ["tools"], "contact": {"email": "code@example.gov"}, "status": "Production",
+	"date": {"created": "2024-01-02", "lastModified": "2024-05-06T07:08:09Z"}}`
+
+func TestSchemaKeywords(t *testing.T) {
+	schema := DefaultSchema()
+	document := func(release string) string {
+		return `{"version": "2.0.0", "agency": "TEST", "measurementType": {"method": "modules"}, "releases": [` + release + `]}`
+	}
+	// withField replaces or adds one field of the valid release
+	withField := func(name, value string) string {
+		var release map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(validSchemaRelease), &release); err != nil {
+			t.Fatal(err)
+		}
+		if value == "" {
+			delete(release, name)
+		} else {
+			release[name] = json.RawMessage(value)
+		}
+		data, _ := json.Marshal(release)
+		return document(string(data))
+	}
+
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string // Empty when the document is valid
+	}{
+		{"valid", document(validSchemaRelease), ""},
+		{"no releases", document(""), ""},
+		{"required", `{"version": "2.0.0", "agency": "TEST", "releases": []}`, "(root): measurementType is required"},
+		{"type", `{"version": 2, "agency": "TEST", "measurementType": {"method": "modules"}, "releases": []}`, "version: expected string, got number"},
+		{"root additionalProperties", `{"version": "2.0.0", "agency": "TEST", "measurementType": {"method": "modules"}, "releases": [], "extra": 1}`, "(root): unexpected property extra"},
+		{"enum", `{"version": "2.0.0", "agency": "TEST", "measurementType": {"method": "guesswork"}, "releases": []}`, "measurementType/method: not one of"},
+		{"$ref required", withField("contact", ""), "releases/0: contact is required"},
+		{"$ref additionalProperties", withField("owner", `"me"`), "releases/0: unexpected property owner"},
+		{"nested enum", withField("status", `"Shipped"`), "releases/0/status: not one of"},
+		{"type list allows null", withField("permissions", `{"licenses": null, "usageType": "openSource"}`), ""},
+		{"type list", withField("permissions", `{"licenses": "MIT", "usageType": "openSource"}`), "releases/0/permissions/licenses: expected array or null, got string"},
+		{"items", withField("tags", `["tools", 7]`), "releases/0/tags/1: expected string, got number"},
+		{"number", withField("laborHours", `"12"`), "releases/0/laborHours: expected number, got string"},
+		{"uri format", withField("repositoryURL", `"example.gov/widget"`), `releases/0/repositoryURL: "example.gov/widget" is not a valid uri`},
+		{"email format", withField("contact", `{"email": "nobody"}`), `releases/0/contact/email: "nobody" is not a valid email`},
+		{"date format", withField("date", `{"created": "2024-13-45"}`), `releases/0/date/created: "2024-13-45" is not a valid date`},
+		{"nested items required", withField("permissions", `{"licenses": [{"name": "MIT"}], "usageType": "openSource"}`), "releases/0/permissions/licenses/0: URL is required"},
+		{"boolean", withField("relatedCode", `[{"isGovernmentRepo": "yes"}]`), "releases/0/relatedCode/0/isGovernmentRepo: expected boolean, got string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, err := schema.Validate([]byte(tt.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr == "" {
+				if len(errs) != 0 {
+					t.Errorf("expected no errors, got %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || !strings.HasPrefix(errs[0], tt.wantErr) {
+				t.Errorf("expected one error starting %q, got %v", tt.wantErr, errs)
+			}
+		})
+	}
+}
+
+func TestSchemaExclusiveBounds(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+		valid  bool
+	}{
+		{"draft-04 inclusive minimum", `{"minimum": 0}`, "0", true},
+		{"draft-04 exclusive minimum", `{"minimum": 0, "exclusiveMinimum": true}`, "0", false},
+		{"draft-04 exclusive minimum above", `{"minimum": 0, "exclusiveMinimum": true}`, "0.5", true},
+		{"draft-04 explicit inclusive maximum", `{"maximum": 10, "exclusiveMaximum": false}`, "10", true},
+		{"draft-04 exclusive maximum", `{"maximum": 10, "exclusiveMaximum": true}`, "10", false},
+		{"draft-06 exclusive minimum", `{"exclusiveMinimum": 0}`, "0", false},
+		{"draft-06 exclusive maximum", `{"exclusiveMaximum": 10}`, "9", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := ParseSchema([]byte(tt.schema))
+			if err != nil {
+				t.Fatal(err)
+			}
+			errs, err := schema.Validate([]byte(tt.value))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if valid := len(errs) == 0; valid != tt.valid {
+				t.Errorf("expected valid=%v, got %v", tt.valid, errs)
+			}
+		})
+	}
+}
+
+func TestSchemaReferenceCycle(t *testing.T) {
+	tests := []struct {
+		schema string
+		doc    string
+	}{
+		{`{"$ref": "#"}`, `{}`},
+		{`{"allOf": [{"$ref": "#"}]}`, `{}`},
+		{`{"definitions": {"a": {"$ref": "#/definitions/b"}, "b": {"allOf": [{"$ref": "#/definitions/a"}]}}, "$ref": "#/definitions/a"}`, `1`},
+	}
+
+	for _, tt := range tests {
+		schema, err := ParseSchema([]byte(tt.schema))
+		if err != nil {
+			t.Fatal(err)
+		}
+		errs, err := schema.Validate([]byte(tt.doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(errs) == 0 || !strings.Contains(strings.Join(errs, "\n"), "circular schema reference") {
+			t.Errorf("%s: expected a circular reference error, got %v", tt.schema, errs)
+		}
+	}
+
+	// A recursive schema is fine as long as each reference descends into the document
+	schema, err := ParseSchema([]byte(`{"type": "object", "properties": {"child": {"$ref": "#"}}, "additionalProperties": false}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs, err := schema.Validate([]byte(`{"child": {"child": {"child": {}}}}`))
+	if err != nil || len(errs) != 0 {
+		t.Errorf("expected a nested document to validate, got %v %v", errs, err)
+	}
+	errs, _ = schema.Validate([]byte(`{"child": {"child": {"other": 1}}}`))
+	if len(errs) != 1 || errs[0] != "child/child: unexpected property other" {
+		t.Errorf("unexpected errors %v", errs)
+	}
+}
+
+func FuzzSchemaValidate(f *testing.F) {
+	valid, _ := json.Marshal(patchFixture())
+	f.Add(valid)
+	f.Add([]byte(`{"version": "2.0.0", "agency": "TEST", "measurementType": {"method": "modules"}, "releases": [{"name": "x", "date": {"created": "2024-13-45"}, "contact": {"email": "not an email"}, "laborHours": -1.5, "tags": [1, null]}]}`))
+	f.Add([]byte(`[]`))
+	f.Add([]byte(`null`))
+
+	schema := DefaultSchema()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		errs, err := schema.Validate(data)
+		if err != nil {
+			return
+		}
+		var doc interface{}
+		if json.Unmarshal(data, &doc) == nil {
+			if _, ok := doc.(map[string]interface{}); !ok && len(errs) == 0 {
+				t.Fatalf("non-object document %s passed validation", data)
+			}
+		}
+	})
+}
@@ -0,0 +1,224 @@
+package codegov
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReleaseStatuses are the development statuses defined by the code.gov 2.0.0 schema
+var ReleaseStatuses = []string{"Ideation", "Development", "Alpha", "Beta", "Release Candidate", "Production", "Archival"}
+
+// ValidationResult is one problem found in an inventory. Errors make the inventory
+// invalid; warnings are published as they are but are worth fixing.
+type ValidationResult struct {
+	Severity    IssueSeverity `json:"severity"`
+	Path        string        `json:"path"` // Slash-separated, as in Schema.Validate, e.g. releases/3/contact/email
+	Message     string        `json:"message"`
+	Release     *int          `json:"release,omitempty"`      // Index of the release the path is in; nil for inventory-level results
+	ReleaseName string        `json:"release_name,omitempty"` // Name of that release, when the document decodes
+}
+
+func (i ValidationResult) String() string {
+	if i.Severity == SeverityWarning {
+		return "warning: " + i.Path + ": " + i.Message
+	}
+	return i.Path + ": " + i.Message
+}
+
+// ValidationErrors returns the issues that make an inventory invalid
+func ValidationErrors(issues []ValidationResult) []ValidationResult {
+	var errs []ValidationResult
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			errs = append(errs, issue)
+		}
+	}
+	return errs
+}
+
+// ValidateCodeGovJSONFile validates a code.gov JSON or YAML file against a schema and
+// the semantic checks of ValidateSemantics
+func ValidateCodeGovJSONFile(filePath string, schema *Schema) ([]ValidationResult, error) {
+	data, err := readDocument(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateCodeGovJSON(data, schema)
+}
+
+// ValidateCodeGovJSON validates an inventory document against a schema, reporting
+// schema violations as errors, then runs ValidateSemantics on it. A value the schema
+// already rejected is not reported again, and documents that do not decode into an
+// inventory only get the schema's errors.
+func ValidateCodeGovJSON(data []byte, schema *Schema) ([]ValidationResult, error) {
+	schemaErrs, err := schema.Validate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := make([]ValidationResult, 0, len(schemaErrs))
+	reported := make(map[string]bool, len(schemaErrs))
+	for _, e := range schemaErrs {
+		path, message, _ := strings.Cut(e, ": ")
+		issues = append(issues, ValidationResult{Severity: SeverityError, Path: path, Message: message, Release: releaseIndex(path)})
+		reported[path] = true
+	}
+
+	var codeGov CodeGovJSON
+	if json.Unmarshal(data, &codeGov) != nil {
+		return issues, nil
+	}
+	for _, issue := range ValidateSemantics(&codeGov) {
+		if !reported[issue.Path] {
+			issues = append(issues, issue)
+		}
+	}
+	for i := range issues {
+		if r := issues[i].Release; r != nil && *r < len(codeGov.Releases) {
+			issues[i].ReleaseName = codeGov.Releases[*r].Name
+		}
+	}
+	return issues, nil
+}
+
+// releaseIndex returns the release index of a path under releases/, or nil
+func releaseIndex(path string) *int {
+	rest, ok := strings.CutPrefix(path, "releases/")
+	if !ok {
+		return nil
+	}
+	index, _, _ := strings.Cut(rest, "/")
+	i, err := strconv.Atoi(index)
+	if err != nil {
+		return nil
+	}
+	return &i
+}
+
+// ValidateSemantics checks what a schema cannot: contact and partner emails are
+// addresses, dates are ISO dates in order, status and usageType are known values,
+// release names are unique and tags are normalized. Invalid values are errors;
+// values code.gov accepts but indexes poorly, such as "Data Science" for the tag
+// "data-science", are warnings.
+func ValidateSemantics(codeGov *CodeGovJSON) []ValidationResult {
+	var issues []ValidationResult
+	add := func(severity IssueSeverity, path, format string, args ...interface{}) {
+		issue := ValidationResult{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...), Release: releaseIndex(path)}
+		if issue.Release != nil {
+			issue.ReleaseName = codeGov.Releases[*issue.Release].Name
+		}
+		issues = append(issues, issue)
+	}
+
+	names := make(map[string]int, len(codeGov.Releases))
+	for i, r := range codeGov.Releases {
+		path := fmt.Sprintf("releases/%d", i)
+
+		key := strings.ToLower(strings.TrimSpace(r.Name))
+		if first, ok := names[key]; ok {
+			add(SeverityError, path+"/name", "duplicate release name %q, also used by releases/%d", r.Name, first)
+		} else if key != "" {
+			names[key] = i
+		}
+
+		checkEmail(add, path+"/contact/email", r.Contact.Email)
+		for j, partner := range r.Partners {
+			checkEmail(add, fmt.Sprintf("%s/partners/%d/email", path, j), partner.Email)
+		}
+
+		created := checkDate(add, path+"/date/created", r.Date.Created)
+		modified := checkDate(add, path+"/date/lastModified", r.Date.LastModified)
+		updated := checkDate(add, path+"/date/metadataLastUpdated", r.Date.MetadataLastUpdated)
+		if !created.IsZero() && !modified.IsZero() && modified.Before(created) {
+			add(SeverityWarning, path+"/date/lastModified", "%s is before created %s", r.Date.LastModified, r.Date.Created)
+		}
+		if !created.IsZero() && !updated.IsZero() && updated.Before(created) {
+			add(SeverityWarning, path+"/date/metadataLastUpdated", "%s is before created %s", r.Date.MetadataLastUpdated, r.Date.Created)
+		}
+
+		// Missing status and usageType are reported by the schema as required fields
+		switch known := matchFold(ReleaseStatuses, r.Status); {
+		case r.Status == "":
+		case known == "":
+			add(SeverityError, path+"/status", "%q is not one of %s", r.Status, strings.Join(ReleaseStatuses, ", "))
+		case known != r.Status:
+			add(SeverityError, path+"/status", "%q is not a status, did you mean %q?", r.Status, known)
+		}
+
+		usageType := r.Permissions.UsageType
+		if usageType != "" && !ValidUsageType(usageType) {
+			add(SeverityError, path+"/permissions/usageType", "%q is not a usage type", usageType)
+		} else if ExemptUsageType(usageType) && strings.TrimSpace(r.Permissions.ExemptionText) == "" {
+			add(SeverityWarning, path+"/permissions/exemptionText", "%s needs a justification", usageType)
+		}
+
+		seen := make(map[string]int, len(r.Tags))
+		for j, tag := range r.Tags {
+			tagPath := fmt.Sprintf("%s/tags/%d", path, j)
+			normalized := NormalizeTag(tag)
+			switch {
+			case normalized == "":
+				add(SeverityWarning, tagPath, "empty tag")
+				continue
+			case normalized != tag:
+				add(SeverityWarning, tagPath, "tag %q should be %q", tag, normalized)
+			}
+			if first, ok := seen[normalized]; ok {
+				add(SeverityWarning, tagPath, "duplicate tag %q, also tags/%d", tag, first)
+			} else {
+				seen[normalized] = j
+			}
+		}
+	}
+	return issues
+}
+
+// NormalizeTag returns the form code.gov indexes tags by: lower case, with runs of
+// spaces and underscores replaced by a hyphen, e.g. "Data Science" becomes "data-science"
+func NormalizeTag(tag string) string {
+	words := strings.FieldsFunc(strings.ToLower(tag), func(r rune) bool {
+		return r == ' ' || r == '_' || r == '\t'
+	})
+	return strings.Join(words, "-")
+}
+
+// checkEmail reports an email that is not a bare address; empty emails are left to the schema
+func checkEmail(add func(IssueSeverity, string, string, ...interface{}), path, email string) {
+	if email == "" {
+		return
+	}
+	addr, err := mail.ParseAddress(email)
+	switch {
+	case err != nil:
+		add(SeverityError, path, "%q is not an email address", email)
+	case addr.Address != email:
+		add(SeverityWarning, path, "%q should be the bare address %q", email, addr.Address)
+	}
+}
+
+// checkDate reports a date that is neither YYYY-MM-DD nor an ISO 8601 timestamp and
+// returns the parsed date, or the zero time when it is empty or invalid
+func checkDate(add func(IssueSeverity, string, string, ...interface{}), path, date string) time.Time {
+	if date == "" {
+		return time.Time{}
+	}
+	if t, ok := parseInventoryDate(date); ok {
+		return t
+	}
+	add(SeverityError, path, "%q is not an ISO 8601 date (YYYY-MM-DD)", date)
+	return time.Time{}
+}
+
+// matchFold returns the value equal to s under case folding, or ""
+func matchFold(values []string, s string) string {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return v
+		}
+	}
+	return ""
+}
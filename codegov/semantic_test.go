@@ -0,0 +1,113 @@
+package codegov
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestValidateSemantics(t *testing.T) {
+	release := func(name string) Release {
+		return Release{
+			Name:        name,
+			Permissions: Permissions{UsageType: UsageTypeOpenSource},
+			Contact:     Contact{Email: "code@agency.gov"},
+			Status:      "Production",
+			Tags:        []string{"go"},
+			Date:        DateInfo{Created: "2020-01-02", LastModified: "2024-05-06T07:08:09Z", MetadataLastUpdated: "2024-05-06"},
+		}
+	}
+
+	clean := &CodeGovJSON{Releases: []Release{release("alpha"), release("beta")}}
+	if issues := ValidateSemantics(clean); len(issues) != 0 {
+		t.Fatalf("clean inventory reported %v", issues)
+	}
+
+	bad := release("Alpha")
+	bad.Contact.Email = "Code Team <code@agency.gov>"
+	bad.Partners = []Partner{{Name: "Partner", Email: "not an email"}}
+	bad.Date = DateInfo{Created: "2024-01-02", LastModified: "2023-12-31", MetadataLastUpdated: "01/02/2024"}
+	bad.Status = "production"
+	bad.Permissions = Permissions{UsageType: UsageTypeExemptByCIO}
+	bad.Tags = []string{"Data Science", "data-science", " "}
+	other := release("gamma")
+	other.Status = "Shipped"
+	other.Permissions.UsageType = "public"
+
+	issues := ValidateSemantics(&CodeGovJSON{Releases: []Release{release("alpha"), bad, other}})
+	want := map[string]IssueSeverity{
+		"releases/1/name":                      SeverityError,
+		"releases/1/contact/email":             SeverityWarning,
+		"releases/1/partners/0/email":          SeverityError,
+		"releases/1/date/lastModified":         SeverityWarning,
+		"releases/1/date/metadataLastUpdated":  SeverityError,
+		"releases/1/status":                    SeverityError,
+		"releases/1/permissions/exemptionText": SeverityWarning,
+		"releases/1/tags/0":                    SeverityWarning,
+		"releases/1/tags/1":                    SeverityWarning,
+		"releases/1/tags/2":                    SeverityWarning,
+		"releases/2/status":                    SeverityError,
+		"releases/2/permissions/usageType":     SeverityError,
+	}
+	got := make(map[string]IssueSeverity, len(issues))
+	for _, issue := range issues {
+		got[issue.Path] = issue.Severity
+	}
+	if len(got) != len(want) {
+		t.Errorf("got issues %v", issues)
+	}
+	for path, severity := range want {
+		if got[path] != severity {
+			t.Errorf("%s: got severity %q, want %q", path, got[path], severity)
+		}
+	}
+	if n := len(ValidationErrors(issues)); n != 6 {
+		t.Errorf("got %d errors, want 6", n)
+	}
+	for _, issue := range issues {
+		if issue.Release == nil || issue.Path[:10] != fmt.Sprintf("releases/%d", *issue.Release) {
+			t.Errorf("%s: release index %v", issue.Path, issue.Release)
+		} else if want := []string{"alpha", "Alpha", "gamma"}[*issue.Release]; issue.ReleaseName != want {
+			t.Errorf("%s: release name %q, want %q", issue.Path, issue.ReleaseName, want)
+		}
+	}
+}
+
+func TestValidateCodeGovJSONSkipsSchemaErrors(t *testing.T) {
+	data, _ := json.Marshal(&CodeGovJSON{
+		Version:         "2.0.0",
+		Agency:          "TEST",
+		MeasurementType: MeasurementType{Method: "modules"},
+		Releases: []Release{{
+			Name:          "alpha",
+			RepositoryURL: "https://example.gov/alpha",
+			Description:   "Alpha",
+			Permissions:   Permissions{Licenses: []License{{Name: "MIT", URL: "https://example.gov/LICENSE"}}, UsageType: UsageTypeOpenSource},
+			LaborHours:    1,
+			Tags:          []string{"Go"},
+			Contact:       Contact{Email: "nobody"},
+			Status:        "Production",
+			VCS:           "git",
+			HomepageURL:   "https://example.gov/alpha",
+			DownloadURL:   "https://example.gov/alpha.zip",
+			Date:          DateInfo{Created: "2020-01-02", LastModified: "2020-01-02", MetadataLastUpdated: "2020-01-02"},
+		}},
+	})
+
+	issues, err := ValidateCodeGovJSON(data, DefaultSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var emails int
+	for _, issue := range issues {
+		if issue.Path == "releases/0/contact/email" {
+			emails++
+		}
+	}
+	if emails != 1 {
+		t.Errorf("invalid email reported %d times: %v", emails, issues)
+	}
+	if len(issues) != 2 || len(ValidationErrors(issues)) != 1 {
+		t.Errorf("want the schema's email error and a tag warning, got %v", issues)
+	}
+}
@@ -0,0 +1,173 @@
+package codegov
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/NSACodeGov/CodeGov/internal/keys"
+)
+
+// Signature formats written by SignCodeGovJSON
+const (
+	SignatureEd25519 = "ed25519" // Base64 encoded raw ed25519 signature, written to <file>.sig
+	SignatureJWS     = "jws"     // Detached compact JWS (RFC 7515 appendix F) with alg EdDSA, written to <file>.jws
+)
+
+// jwsHeader is the protected header of detached JWS signatures
+var jwsHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"EdDSA","cty":"code.gov+json"}`))
+
+// CanonicalCodeGovJSON returns the form of an inventory document that signatures
+// cover: compact JSON with object keys sorted, numbers in their shortest form and
+// no HTML escaping. Indentation, key order and JSON versus YAML therefore do not
+// change a signature, but any change to a value does.
+func CanonicalCodeGovJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse inventory: %w", err)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// SignCodeGovJSON signs the canonical form of an inventory document, returning a
+// SignatureEd25519 or SignatureJWS signature
+func SignCodeGovJSON(data []byte, key ed25519.PrivateKey, format string) ([]byte, error) {
+	canonical, err := CanonicalCodeGovJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case SignatureEd25519:
+		return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, canonical)) + "\n"), nil
+	case SignatureJWS:
+		signingInput := jwsHeader + "." + base64.RawURLEncoding.EncodeToString(canonical)
+		sig := ed25519.Sign(key, []byte(signingInput))
+		return []byte(jwsHeader + ".." + base64.RawURLEncoding.EncodeToString(sig) + "\n"), nil
+	default:
+		return nil, fmt.Errorf("unknown signature format %q (expected %s or %s)", format, SignatureEd25519, SignatureJWS)
+	}
+}
+
+// VerifyCodeGovJSON checks a signature from SignCodeGovJSON against the canonical form
+// of an inventory document. The format is recognized from the signature itself.
+func VerifyCodeGovJSON(data, sig []byte, key ed25519.PublicKey) error {
+	canonical, err := CanonicalCodeGovJSON(data)
+	if err != nil {
+		return err
+	}
+
+	signature := strings.TrimSpace(string(sig))
+	if header, rest, ok := strings.Cut(signature, "."); ok {
+		payload, encoded, ok := strings.Cut(rest, ".")
+		if !ok || payload != "" {
+			return fmt.Errorf("signature is not a detached JWS")
+		}
+		if err := checkJWSHeader(header); err != nil {
+			return err
+		}
+		raw, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil || !ed25519.Verify(key, []byte(header+"."+base64.RawURLEncoding.EncodeToString(canonical)), raw) {
+			return fmt.Errorf("inventory signature verification failed")
+		}
+		return nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if !ed25519.Verify(key, canonical, raw) {
+		return fmt.Errorf("inventory signature verification failed")
+	}
+	return nil
+}
+
+// checkJWSHeader rejects JWS headers that are not EdDSA or that need extensions
+func checkJWSHeader(encoded string) error {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("invalid JWS header: %w", err)
+	}
+	var header struct {
+		Alg  string   `json:"alg"`
+		Crit []string `json:"crit"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return fmt.Errorf("invalid JWS header: %w", err)
+	}
+	if header.Alg != "EdDSA" {
+		return fmt.Errorf("unsupported JWS algorithm %q (expected EdDSA)", header.Alg)
+	}
+	if len(header.Crit) > 0 {
+		return fmt.Errorf("unsupported critical JWS header parameters %v", header.Crit)
+	}
+	return nil
+}
+
+// SignatureFile returns where SignCodeGovJSONFile writes the signature of path
+func SignatureFile(path, format string) string {
+	if format == SignatureJWS {
+		return path + ".jws"
+	}
+	return path + ".sig"
+}
+
+// SignCodeGovJSONFile signs a code.gov JSON or YAML file and writes the signature to
+// SignatureFile(path, format), returning that path
+func SignCodeGovJSONFile(path string, key ed25519.PrivateKey, format string) (string, error) {
+	data, err := readDocument(path)
+	if err != nil {
+		return "", err
+	}
+	sig, err := SignCodeGovJSON(data, key, format)
+	if err != nil {
+		return "", err
+	}
+	sigPath := SignatureFile(path, format)
+	return sigPath, os.WriteFile(sigPath, sig, 0644)
+}
+
+// VerifyCodeGovJSONFile checks a code.gov JSON or YAML file against a signature file.
+// With an empty sigPath, <path>.sig is used, or <path>.jws when only that exists.
+func VerifyCodeGovJSONFile(path, sigPath string, key ed25519.PublicKey) error {
+	if sigPath == "" {
+		sigPath = SignatureFile(path, SignatureEd25519)
+		if _, err := os.Stat(sigPath); os.IsNotExist(err) {
+			sigPath = SignatureFile(path, SignatureJWS)
+		}
+	}
+
+	data, err := readDocument(path)
+	if err != nil {
+		return err
+	}
+	sig, err := os.ReadFile(sigPath)
+	if err != nil {
+		return err
+	}
+	return VerifyCodeGovJSON(data, sig, key)
+}
+
+// ParseSigningKey reads an ed25519 private key from PEM (PKCS#8), as written by
+// `openssl genpkey -algorithm ed25519`
+func ParseSigningKey(pemData []byte) (ed25519.PrivateKey, error) {
+	return keys.ParsePrivateKey(pemData)
+}
+
+// ParseVerifyKey reads an ed25519 public key from PEM (PKIX), as written by
+// `openssl pkey -pubout`, or from a base64 encoded raw key
+func ParseVerifyKey(data []byte) (ed25519.PublicKey, error) {
+	return keys.ParsePublicKey(data)
+}
@@ -0,0 +1,104 @@
+package codegov
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalCodeGovJSON(t *testing.T) {
+	indented := []byte("{\n  \"version\": \"2.0.0\",\n  \"agency\": \"R&D\",\n  \"measurementType\": {\"method\": \"projects\"},\n  \"laborHours\": 1.0\n}\n")
+	compact := []byte(`{"laborHours":1,"measurementType":{"method":"projects"},"agency":"R&D","version":"2.0.0"}`)
+
+	a, err := CanonicalCodeGovJSON(indented)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := CanonicalCodeGovJSON(compact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"agency":"R&D","laborHours":1,"measurementType":{"method":"projects"},"version":"2.0.0"}`
+	if string(a) != want || string(b) != want {
+		t.Errorf("canonical forms\n%s\n%s\nwant %s", a, b, want)
+	}
+}
+
+func TestSignCodeGovJSON(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	data := []byte(`{"version":"2.0.0","agency":"TEST","releases":[{"name":"api"}]}`)
+	reformatted := []byte("{\n  \"agency\": \"TEST\",\n  \"releases\": [{\"name\": \"api\"}],\n  \"version\": \"2.0.0\"\n}")
+	tampered := []byte(`{"version":"2.0.0","agency":"TEST","releases":[{"name":"api2"}]}`)
+
+	for _, format := range []string{SignatureEd25519, SignatureJWS} {
+		sig, err := SignCodeGovJSON(data, priv, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if err := VerifyCodeGovJSON(reformatted, sig, pub); err != nil {
+			t.Errorf("%s: reformatted inventory failed verification: %v", format, err)
+		}
+		if VerifyCodeGovJSON(tampered, sig, pub) == nil {
+			t.Errorf("%s: tampered inventory verified", format)
+		}
+		if VerifyCodeGovJSON(data, sig, otherPub) == nil {
+			t.Errorf("%s: verified with the wrong key", format)
+		}
+	}
+
+	sig, _ := SignCodeGovJSON(data, priv, SignatureJWS)
+	if parts := strings.Split(strings.TrimSpace(string(sig)), "."); len(parts) != 3 || parts[1] != "" {
+		t.Errorf("JWS is not detached: %s", sig)
+	}
+	if _, err := SignCodeGovJSON(data, priv, "minisign"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestSignCodeGovJSONFile(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "code.json")
+	if err := writeCodeGovJSON(patchFixture(), path); err != nil {
+		t.Fatal(err)
+	}
+
+	sigPath, err := SignCodeGovJSONFile(path, priv, SignatureJWS)
+	if err != nil || sigPath != path+".jws" {
+		t.Fatalf("SignCodeGovJSONFile = %q, %v", sigPath, err)
+	}
+	if err := VerifyCodeGovJSONFile(path, "", pub); err != nil {
+		t.Errorf("VerifyCodeGovJSONFile failed: %v", err)
+	}
+
+	// The YAML form of the same inventory carries the same signature
+	yamlPath := filepath.Join(dir, "code.yaml")
+	if err := ConvertCodeGovJSONFile(path, yamlPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyCodeGovJSONFile(yamlPath, sigPath, pub); err != nil {
+		t.Errorf("YAML inventory failed verification: %v", err)
+	}
+}
+
+func TestParseSigningKey(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	der, _ := x509.MarshalPKCS8PrivateKey(priv)
+	key, err := ParseSigningKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil || !key.Equal(priv) {
+		t.Fatalf("ParseSigningKey = %v", err)
+	}
+
+	der, _ = x509.MarshalPKIXPublicKey(pub)
+	pubKey, err := ParseVerifyKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil || !pubKey.Equal(pub) {
+		t.Fatalf("ParseVerifyKey = %v", err)
+	}
+	if _, err := ParseSigningKey([]byte("not a key")); err == nil {
+		t.Error("expected an error for a non-PEM key")
+	}
+}
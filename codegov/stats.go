@@ -0,0 +1,158 @@
+package codegov
+
+import (
+	"io"
+	"sort"
+	"time"
+)
+
+// noneValue counts releases that leave a summarized field empty
+const noneValue = "(none)"
+
+// ValueCount is the number of releases with a value, e.g. a language or license
+type ValueCount struct {
+	Value    string `json:"value"`
+	Releases int    `json:"releases"`
+}
+
+// DatedRelease is a release and its lastModified date as published
+type DatedRelease struct {
+	Name string `json:"name"`
+	Date string `json:"date"`
+}
+
+// InventorySummary counts an inventory's releases by language, license, status,
+// usageType and tag, for quarterly reporting. Counts are sorted by number of
+// releases, most first; a release with several languages, licenses or tags counts
+// once under each, and one with none under "(none)".
+type InventorySummary struct {
+	Releases   int          `json:"releases"`
+	LaborHours float64      `json:"laborHours"`
+	Languages  []ValueCount `json:"languages"`
+	Licenses   []ValueCount `json:"licenses"`
+	Statuses   []ValueCount `json:"status"`
+	UsageTypes []ValueCount `json:"usageType"`
+	Tags       []ValueCount `json:"tags"` // Normalized with NormalizeTag, so "Go" and "go" count together
+
+	// OldestLastModified and NewestLastModified are nil when no release has a valid date
+	OldestLastModified *DatedRelease `json:"oldestLastModified,omitempty"`
+	NewestLastModified *DatedRelease `json:"newestLastModified,omitempty"`
+}
+
+// Summarize counts an inventory's releases
+func Summarize(codeGov *CodeGovJSON) *InventorySummary {
+	languages := make(map[string]int)
+	licenses := make(map[string]int)
+	statuses := make(map[string]int)
+	usageTypes := make(map[string]int)
+	tags := make(map[string]int)
+
+	// count adds a release's distinct values to counts
+	count := func(counts map[string]int, values []string) {
+		seen := make(map[string]bool, len(values))
+		for _, v := range values {
+			if v != "" && !seen[v] {
+				seen[v] = true
+				counts[v]++
+			}
+		}
+		if len(seen) == 0 {
+			counts[noneValue]++
+		}
+	}
+
+	summary := &InventorySummary{Releases: len(codeGov.Releases)}
+	var oldest, newest time.Time
+	for _, r := range codeGov.Releases {
+		summary.LaborHours += r.LaborHours
+
+		count(languages, r.Languages)
+		var names []string
+		for _, license := range r.Permissions.Licenses {
+			if license.Name != "" {
+				names = append(names, license.Name)
+			} else {
+				names = append(names, license.URL)
+			}
+		}
+		count(licenses, names)
+		count(statuses, []string{r.Status})
+		count(usageTypes, []string{r.Permissions.UsageType})
+		normalized := make([]string, len(r.Tags))
+		for i, tag := range r.Tags {
+			normalized[i] = NormalizeTag(tag)
+		}
+		count(tags, normalized)
+
+		modified, ok := parseInventoryDate(r.Date.LastModified)
+		if !ok {
+			continue
+		}
+		if summary.OldestLastModified == nil || modified.Before(oldest) {
+			oldest = modified
+			summary.OldestLastModified = &DatedRelease{Name: r.Name, Date: r.Date.LastModified}
+		}
+		if summary.NewestLastModified == nil || modified.After(newest) {
+			newest = modified
+			summary.NewestLastModified = &DatedRelease{Name: r.Name, Date: r.Date.LastModified}
+		}
+	}
+
+	summary.Languages = sortedCounts(languages)
+	summary.Licenses = sortedCounts(licenses)
+	summary.Statuses = sortedCounts(statuses)
+	summary.UsageTypes = sortedCounts(usageTypes)
+	summary.Tags = sortedCounts(tags)
+	return summary
+}
+
+// parseInventoryDate parses a YYYY-MM-DD date or an ISO 8601 timestamp
+func parseInventoryDate(date string) (time.Time, bool) {
+	if t, err := time.Parse("2006-01-02", date); err == nil {
+		return t, true
+	}
+	t, err := time.Parse(time.RFC3339, date)
+	return t, err == nil
+}
+
+// sortedCounts orders counts by number of releases, then value
+func sortedCounts(counts map[string]int) []ValueCount {
+	sorted := make([]ValueCount, 0, len(counts))
+	for value, n := range counts {
+		sorted = append(sorted, ValueCount{Value: value, Releases: n})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Releases != sorted[j].Releases {
+			return sorted[i].Releases > sorted[j].Releases
+		}
+		return sorted[i].Value < sorted[j].Value
+	})
+	return sorted
+}
+
+// WriteText writes the summary as plain text sections, one value per line
+func (s *InventorySummary) WriteText(w io.Writer) error {
+	ew := &errWriter{w: w}
+	ew.printf("Releases: %d\n", s.Releases)
+	ew.printf("Labor hours: %g\n", s.LaborHours)
+	if s.OldestLastModified != nil {
+		ew.printf("Oldest lastModified: %s (%s)\n", s.OldestLastModified.Date, s.OldestLastModified.Name)
+		ew.printf("Newest lastModified: %s (%s)\n", s.NewestLastModified.Date, s.NewestLastModified.Name)
+	}
+	for _, section := range []struct {
+		title  string
+		counts []ValueCount
+	}{
+		{"Languages", s.Languages},
+		{"Licenses", s.Licenses},
+		{"Status", s.Statuses},
+		{"Usage types", s.UsageTypes},
+		{"Tags", s.Tags},
+	} {
+		ew.printf("\n%s:\n", section.title)
+		for _, c := range section.counts {
+			ew.printf("  %6d  %s\n", c.Releases, c.Value)
+		}
+	}
+	return ew.err
+}
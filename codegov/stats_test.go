@@ -0,0 +1,68 @@
+package codegov
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	codeGov := &CodeGovJSON{Releases: []Release{
+		{
+			Name:        "api",
+			Status:      "Production",
+			Languages:   []string{"Go", "Python", "Go"},
+			Tags:        []string{"Go", "cli"},
+			Permissions: Permissions{Licenses: []License{{Name: "MIT"}}, UsageType: UsageTypeOpenSource},
+			LaborHours:  10,
+			Date:        DateInfo{LastModified: "2024-01-02"},
+		},
+		{
+			Name:        "tool",
+			Status:      "Archival",
+			Languages:   []string{"Go"},
+			Tags:        []string{"go"},
+			Permissions: Permissions{Licenses: []License{{URL: "https://example.gov/LICENSE"}}, UsageType: UsageTypeExemptByCIO},
+			LaborHours:  2.5,
+			Date:        DateInfo{LastModified: "2021-06-30T10:00:00Z"},
+		},
+		{
+			Name:        "draft",
+			Status:      "Production",
+			Permissions: Permissions{UsageType: UsageTypeOpenSource},
+			Date:        DateInfo{LastModified: "unknown"},
+		},
+	}}
+
+	s := Summarize(codeGov)
+	if s.Releases != 3 || s.LaborHours != 12.5 {
+		t.Errorf("got %d releases and %g labor hours", s.Releases, s.LaborHours)
+	}
+	for _, c := range []struct {
+		name string
+		got  []ValueCount
+		want []ValueCount
+	}{
+		{"languages", s.Languages, []ValueCount{{"Go", 2}, {"(none)", 1}, {"Python", 1}}},
+		{"licenses", s.Licenses, []ValueCount{{"(none)", 1}, {"MIT", 1}, {"https://example.gov/LICENSE", 1}}},
+		{"status", s.Statuses, []ValueCount{{"Production", 2}, {"Archival", 1}}},
+		{"usageType", s.UsageTypes, []ValueCount{{"openSource", 2}, {"exemptByCIO", 1}}},
+		{"tags", s.Tags, []ValueCount{{"go", 2}, {"(none)", 1}, {"cli", 1}}},
+	} {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+	if s.OldestLastModified == nil || s.OldestLastModified.Name != "tool" || s.NewestLastModified.Name != "api" {
+		t.Errorf("got oldest %+v, newest %+v", s.OldestLastModified, s.NewestLastModified)
+	}
+
+	var buf bytes.Buffer
+	if err := s.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Oldest lastModified: 2021-06-30T10:00:00Z (tool)") {
+		t.Errorf("unexpected text:\n%s", buf.String())
+	}
+}
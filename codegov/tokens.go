@@ -0,0 +1,356 @@
+package codegov
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenStoreEnv selects where the GitHub token is kept when OAUTH_TOKEN is unset:
+// "git-credential" or "keychain"
+const TokenStoreEnv = "CODEGOV_TOKEN_STORE"
+
+// Token store kinds accepted by NewTokenStore
+const (
+	TokenStoreEnvVar        = "env"
+	TokenStoreGitCredential = "git-credential"
+	TokenStoreKeychain      = "keychain"
+)
+
+// ErrTokenNotStored is returned by TokenStore.Get when no token is stored
+var ErrTokenNotStored = errors.New("no token stored")
+
+// gitHubTokenFormat matches classic 40-hex tokens, prefixed tokens (ghp_ personal,
+// gho_ OAuth, ghu_ user-to-server, ghs_ installation, ghr_ refresh) and fine-grained
+// github_pat_ tokens. It is a hint only: tokens are never rejected for their format,
+// since GitHub may issue new ones; VerifyGitHubToken asks GitHub.
+var gitHubTokenFormat = regexp.MustCompile(`^(?:[0-9a-f]{40}|gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})$`)
+
+// ValidGitHubTokenFormat reports whether a token has the shape of a GitHub token
+func ValidGitHubTokenFormat(token string) bool {
+	return gitHubTokenFormat.MatchString(token)
+}
+
+// GitHubTokenInfo describes a token accepted by GitHub
+type GitHubTokenInfo struct {
+	Login     string    // Account the token acts as
+	Scopes    []string  // OAuth scopes of classic tokens; empty for fine-grained tokens
+	ExpiresAt time.Time // Zero when the token does not expire
+}
+
+// VerifyGitHubToken checks a token against the GitHub API's /user endpoint
+func VerifyGitHubToken(client *http.Client, token string) (*GitHubTokenInfo, error) {
+	if token == "" {
+		return nil, fmt.Errorf("no token")
+	}
+
+	client = withCredentials(client, Credentials{GitHubToken: token, NoEnvFallback: true})
+	req, err := http.NewRequest(http.MethodGet, GetGitHubBaseURI()+"/user", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	setClientHeaders(req)
+
+	resp, err := doAPIRequest(client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, nil)
+	}
+
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("failed to decode /user response: %w", err)
+	}
+
+	info := &GitHubTokenInfo{Login: user.Login}
+	for _, scope := range strings.Split(resp.Header.Get("X-OAuth-Scopes"), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			info.Scopes = append(info.Scopes, scope)
+		}
+	}
+	// e.g. "2024-06-01 12:00:00 UTC"
+	if v := resp.Header.Get("GitHub-Authentication-Token-Expiration"); v != "" {
+		if t, err := time.Parse("2006-01-02 15:04:05 MST", v); err == nil {
+			info.ExpiresAt = t
+		}
+	}
+	return info, nil
+}
+
+// TokenStore keeps the GitHub token outside the process environment
+type TokenStore interface {
+	Get() (string, error) // Returns ErrTokenNotStored when empty
+	Set(token string) error
+	Delete() error
+}
+
+var (
+	tokenStoreMu sync.Mutex
+	tokenStore   TokenStore
+
+	// The stored token is read once per store: every error message and recorded
+	// request is redacted with it, and reading a store runs a helper process
+	storedTokenCache struct {
+		valid bool   // Cleared by SetTokenStore
+		kind  string // $CODEGOV_TOKEN_STORE when no store was set
+		token string
+	}
+)
+
+// SetTokenStore sets where GetOAuthToken looks when OAUTH_TOKEN is unset; nil
+// restores the default of consulting $CODEGOV_TOKEN_STORE
+func SetTokenStore(store TokenStore) {
+	tokenStoreMu.Lock()
+	defer tokenStoreMu.Unlock()
+	tokenStore = store
+	storedTokenCache.valid = false
+}
+
+// storedToken returns the token from the store set with SetTokenStore or named by
+// $CODEGOV_TOKEN_STORE, or "" when neither is set or the store holds no token
+func storedToken() string {
+	tokenStoreMu.Lock()
+	defer tokenStoreMu.Unlock()
+
+	store, kind := tokenStore, ""
+	if store == nil {
+		kind = os.Getenv(TokenStoreEnv)
+		if kind == "" || kind == TokenStoreEnvVar {
+			return ""
+		}
+	}
+	if storedTokenCache.valid && storedTokenCache.kind == kind {
+		return storedTokenCache.token
+	}
+
+	token := ""
+	if store == nil {
+		store, _ = NewTokenStore(kind)
+	}
+	if store != nil {
+		if t, err := store.Get(); err == nil {
+			token = t
+		}
+	}
+	storedTokenCache.valid, storedTokenCache.kind, storedTokenCache.token = true, kind, token
+	return token
+}
+
+// NewTokenStore returns the store for a kind: "env" keeps the token in OAUTH_TOKEN for
+// this process only, "git-credential" uses the configured git credential helper
+// (osxkeychain, wincred, libsecret, manager, ...) and "keychain" uses the macOS
+// Keychain or the Linux Secret Service directly
+func NewTokenStore(kind string) (TokenStore, error) {
+	switch kind {
+	case TokenStoreEnvVar:
+		return envTokenStore{}, nil
+	case TokenStoreGitCredential:
+		return &GitCredentialStore{}, nil
+	case TokenStoreKeychain:
+		return &KeychainStore{}, nil
+	}
+	return nil, fmt.Errorf("unknown token store %q (want %s, %s or %s)", kind, TokenStoreEnvVar, TokenStoreGitCredential, TokenStoreKeychain)
+}
+
+// envTokenStore keeps the token in the OAUTH_TOKEN variable of the current process
+type envTokenStore struct{}
+
+func (envTokenStore) Get() (string, error) {
+	if token := os.Getenv(OAuthTokenEnv); token != "" {
+		return token, nil
+	}
+	return "", ErrTokenNotStored
+}
+
+func (envTokenStore) Set(token string) error { return os.Setenv(OAuthTokenEnv, token) }
+
+func (envTokenStore) Delete() error { return os.Unsetenv(OAuthTokenEnv) }
+
+// gitHubWebHost returns the host tokens are stored under: github.com for the public
+// API, or the GitHub Enterprise Server host
+func gitHubWebHost() string {
+	host := gitHubAPI("").apiHost()
+	if host == "api.github.com" {
+		return "github.com"
+	}
+	return host
+}
+
+// GitCredentialStore stores the token with `git credential`, under the GitHub host
+// and the "codegov" user name
+type GitCredentialStore struct {
+	GitPath string // git binary; defaults to "git" on PATH
+	Host    string // Defaults to github.com or the GitHub Enterprise Server host
+}
+
+func (s *GitCredentialStore) credential(action, password string) (string, error) {
+	binary := s.GitPath
+	if binary == "" {
+		binary = "git"
+	}
+	host := s.Host
+	if host == "" {
+		host = gitHubWebHost()
+	}
+
+	input := fmt.Sprintf("protocol=https\nhost=%s\nusername=codegov\n", host)
+	if password != "" {
+		input += "password=" + password + "\n"
+	}
+
+	cmd := exec.Command(binary, "credential", action)
+	cmd.Stdin = strings.NewReader(input + "\n")
+	// Never fall back to prompting on the terminal
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git credential %s failed: %v: %s", action, err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// Get returns the stored token
+func (s *GitCredentialStore) Get() (string, error) {
+	out, err := s.credential("fill", "")
+	if err != nil {
+		// fill fails when no helper has the credential and prompting is disabled
+		return "", ErrTokenNotStored
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if token, ok := strings.CutPrefix(line, "password="); ok && token != "" {
+			return token, nil
+		}
+	}
+	return "", ErrTokenNotStored
+}
+
+// Set stores the token with the configured credential helpers
+func (s *GitCredentialStore) Set(token string) error {
+	_, err := s.credential("approve", token)
+	return err
+}
+
+// Delete erases the token from the configured credential helpers
+func (s *GitCredentialStore) Delete() error {
+	_, err := s.credential("reject", "")
+	return err
+}
+
+// KeychainStore stores the token in the macOS Keychain (via security) or the
+// Linux Secret Service (via secret-tool from libsecret)
+type KeychainStore struct {
+	Service string // Defaults to "codegov"
+	Account string // Defaults to github.com or the GitHub Enterprise Server host
+}
+
+func (s *KeychainStore) names() (service, account string) {
+	service, account = s.Service, s.Account
+	if service == "" {
+		service = "codegov"
+	}
+	if account == "" {
+		account = gitHubWebHost()
+	}
+	return service, account
+}
+
+func runKeychain(stdin string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %v: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// securityCommand formats one command line for `security -i`, double-quoting each
+// argument so spaces and quotes survive its parser
+func securityCommand(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		arg = strings.ReplaceAll(arg, `\`, `\\`)
+		quoted[i] = `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+	}
+	return strings.Join(quoted, " ") + "\n"
+}
+
+// Get returns the stored token
+func (s *KeychainStore) Get() (string, error) {
+	service, account := s.names()
+	var token string
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		token, err = runKeychain("", "security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "linux":
+		token, err = runKeychain("", "secret-tool", "lookup", "service", service, "account", account)
+	default:
+		return "", fmt.Errorf("keychain storage is not supported on %s; use %s", runtime.GOOS, TokenStoreGitCredential)
+	}
+	if err != nil || token == "" {
+		return "", ErrTokenNotStored
+	}
+	return token, nil
+}
+
+// Set stores the token, replacing any stored before
+func (s *KeychainStore) Set(token string) error {
+	service, account := s.names()
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		// Send the command on stdin to `security -i` so the token never appears in the
+		// process list; -U updates an existing item
+		_, err = runKeychain(securityCommand("add-generic-password", "-U", "-s", service, "-a", account, "-w", token),
+			"security", "-i")
+		// Interactive mode may exit cleanly after a failed command, so read the item back
+		if err == nil {
+			if stored, getErr := s.Get(); getErr != nil || stored != token {
+				err = fmt.Errorf("security did not store the token")
+			}
+		}
+	case "linux":
+		_, err = runKeychain(token, "secret-tool", "store", "--label", "codegov GitHub token", "service", service, "account", account)
+	default:
+		err = fmt.Errorf("keychain storage is not supported on %s; use %s", runtime.GOOS, TokenStoreGitCredential)
+	}
+	return err
+}
+
+// Delete removes the stored token
+func (s *KeychainStore) Delete() error {
+	service, account := s.names()
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		_, err = runKeychain("", "security", "delete-generic-password", "-s", service, "-a", account)
+	case "linux":
+		_, err = runKeychain("", "secret-tool", "clear", "service", service, "account", account)
+	default:
+		err = fmt.Errorf("keychain storage is not supported on %s; use %s", runtime.GOOS, TokenStoreGitCredential)
+	}
+	return err
+}
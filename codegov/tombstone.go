@@ -0,0 +1,96 @@
+package codegov
+
+import (
+	"sort"
+	"time"
+)
+
+// StageRemoved is the report stage of releases of GenerateOptions.Previous that the
+// run no longer found
+const StageRemoved = "removed"
+
+// TombstoneTag marks a release kept in the inventory after its repository was deleted
+// or made private. The removal date is the release's date.metadataLastUpdated; both
+// fit the 2.0.0 schema, which has no field for either.
+const TombstoneTag = "removed"
+
+// RemovedRelease is a release of the previous inventory the run no longer found
+type RemovedRelease struct {
+	Name    string `json:"name"`
+	Removed string `json:"removed"` // Date it was first missing, YYYY-MM-DD
+	Expires string `json:"expires"` // Date its tombstone is dropped, YYYY-MM-DD
+	Kept    bool   `json:"kept"`    // Published as a tombstone by this run
+}
+
+// IsTombstone reports whether a release is a tombstone kept for GenerateOptions.TombstoneDays
+func IsTombstone(r Release) bool {
+	if r.Status != "Archival" {
+		return false
+	}
+	for _, tag := range r.Tags {
+		if tag == TombstoneTag {
+			return true
+		}
+	}
+	return false
+}
+
+// applyTombstones keeps the releases of previous that are missing from codeGov, with
+// status Archival and TombstoneTag, until days have passed since they were first
+// missing. Releases are matched by name, and the removals are returned in name order.
+func applyTombstones(codeGov, previous *CodeGovJSON, days int, now time.Time) []RemovedRelease {
+	current := make(map[string]bool, len(codeGov.Releases))
+	for _, r := range codeGov.Releases {
+		current[r.Name] = true
+	}
+
+	today := now.UTC().Format("2006-01-02")
+	var removed []RemovedRelease
+	for _, r := range previous.Releases {
+		if current[r.Name] {
+			continue
+		}
+
+		tombstone := r
+		if IsTombstone(r) {
+			if _, err := time.Parse("2006-01-02", r.Date.MetadataLastUpdated); err != nil {
+				tombstone.Date.MetadataLastUpdated = today
+			}
+		} else {
+			tombstone.Status = "Archival"
+			tombstone.Tags = append(append([]string{}, r.Tags...), TombstoneTag)
+			tombstone.Date.MetadataLastUpdated = today
+		}
+
+		since, _ := time.Parse("2006-01-02", tombstone.Date.MetadataLastUpdated)
+		expires := since.AddDate(0, 0, days).Format("2006-01-02")
+		removal := RemovedRelease{Name: r.Name, Removed: tombstone.Date.MetadataLastUpdated, Expires: expires, Kept: today < expires}
+		if removal.Kept {
+			codeGov.Releases = append(codeGov.Releases, tombstone)
+		}
+		current[r.Name] = true // Previous inventories may repeat a name
+		removed = append(removed, removal)
+	}
+
+	sort.Slice(codeGov.Releases, func(i, j int) bool {
+		return codeGov.Releases[i].Name < codeGov.Releases[j].Name
+	})
+	return removed
+}
+
+// keepTombstones records the releases of GenerateOptions.Previous missing from the run
+// as warnings and keeps them as tombstones
+func (g *generator) keepTombstones(codeGov *CodeGovJSON) {
+	removed := applyTombstones(codeGov, g.opts.Previous, g.opts.TombstoneDays, time.Now())
+	for _, r := range removed {
+		message := "no longer found; kept as Archival until " + r.Expires
+		if !r.Kept {
+			message = "no longer found since " + r.Removed + "; tombstone expired and dropped"
+		}
+		g.report.add(ReportIssue{Severity: SeverityWarning, Repo: r.Name, Stage: StageRemoved, Message: message})
+	}
+	g.report.Removed = removed
+	if len(removed) > 0 {
+		g.logger.Printf("%d previously published releases no longer found\n", len(removed))
+	}
+}
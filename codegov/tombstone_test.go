@@ -0,0 +1,116 @@
+package codegov
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyTombstones(t *testing.T) {
+	day := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	previous := patchFixture()
+	current := patchFixture()
+	current.Releases = current.Releases[1:] // alpha was deleted
+
+	removed := applyTombstones(current, previous, 3, day)
+	if len(removed) != 1 || removed[0] != (RemovedRelease{Name: "alpha", Removed: "2026-10-01", Expires: "2026-10-04", Kept: true}) {
+		t.Fatalf("unexpected removals %+v", removed)
+	}
+	alpha := current.Releases[0]
+	if alpha.Name != "alpha" || !IsTombstone(alpha) || alpha.Date.MetadataLastUpdated != "2026-10-01" || strings.Join(alpha.Tags, ",") != "go,removed" {
+		t.Fatalf("unexpected tombstone %+v", alpha)
+	}
+	if strings.Join(previous.Releases[0].Tags, ",") != "go" {
+		t.Errorf("previous inventory modified: %+v", previous.Releases[0])
+	}
+
+	// The tombstone is diffed as such and stays schema-valid
+	d, err := Diff(previous, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(d.Tombstoned, ",") != "alpha" || len(d.Removed) != 0 || len(d.Changed) != 0 {
+		t.Errorf("unexpected diff %+v", d)
+	}
+	var buf bytes.Buffer
+	d.WriteText(&buf)
+	if !strings.Contains(buf.String(), "- release alpha (kept as Archival tombstone)") || !strings.Contains(buf.String(), "1 tombstoned") {
+		t.Errorf("unexpected diff text:\n%s", buf.String())
+	}
+	summary, err := NewRunSummary(previous, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(summary.subject(), "1 tombstoned") {
+		t.Errorf("unexpected subject %q", summary.subject())
+	}
+
+	// Later runs keep the original removal date until the tombstone expires
+	for _, c := range []struct {
+		day  time.Time
+		kept bool
+	}{
+		{day.AddDate(0, 0, 2), true},
+		{day.AddDate(0, 0, 3), false},
+	} {
+		next := patchFixture()
+		next.Releases = next.Releases[1:]
+		removed := applyTombstones(next, current, 3, c.day)
+		if len(removed) != 1 || removed[0].Removed != "2026-10-01" || removed[0].Kept != c.kept {
+			t.Errorf("%s: unexpected removals %+v", c.day.Format("2006-01-02"), removed)
+		}
+		if kept := len(next.Releases) == 3; kept != c.kept {
+			t.Errorf("%s: tombstone kept %v", c.day.Format("2006-01-02"), kept)
+		}
+	}
+
+	// A release that comes back replaces its tombstone
+	back := patchFixture()
+	if removed := applyTombstones(back, current, 3, day); len(removed) != 0 || IsTombstone(back.Releases[0]) {
+		t.Errorf("returned release still tombstoned: %+v", back.Releases[0])
+	}
+}
+
+func TestGenerateKeepsTombstones(t *testing.T) {
+	rec, err := NewRecorder(filepath.Join("testdata", "github-org.json"), RecorderModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := GenerateOptions{
+		Organizations: []string{"testorg"},
+		Agency:        "TEST",
+		Contact:       Contact{Email: "code@test.gov"},
+		HTTPClient:    &http.Client{Transport: rec},
+		Logger:        log.New(io.Discard, "", 0),
+	}
+	codeGov, _, err := GenerateWithReport(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gone := codeGov.Releases[0]
+	gone.Name = "deleted-tool"
+	gone.RepositoryURL = "https://github.com/testorg/deleted-tool"
+	opts.Previous = &CodeGovJSON{Version: "2.0", Agency: "TEST", Releases: []Release{codeGov.Releases[0], gone}}
+	opts.TombstoneDays = 30
+
+	withTombstones, report, err := GenerateWithReport(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(withTombstones.Releases) != 2 || !IsTombstone(withTombstones.Releases[0]) || IsTombstone(withTombstones.Releases[1]) {
+		t.Fatalf("unexpected releases %+v", withTombstones.Releases)
+	}
+	reported := false
+	for _, w := range report.Warnings() {
+		reported = reported || (w.Stage == StageRemoved && w.Repo == "deleted-tool")
+	}
+	if len(report.Removed) != 1 || report.Removed[0].Name != "deleted-tool" || !reported {
+		t.Errorf("removal not reported: %+v %+v", report.Removed, report.Issues)
+	}
+}
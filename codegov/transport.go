@@ -0,0 +1,162 @@
+package codegov
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// DefaultMaxIdleConnsPerHost keeps enough idle connections to each API host for
+// concurrent enrichment; net/http's default of 2 forces most requests to reconnect
+const DefaultMaxIdleConnsPerHost = 16
+
+// TransportConfig describes the network path to the provider APIs
+type TransportConfig struct {
+	// ProxyURL routes every request through an HTTP(S) proxy, e.g. "http://proxy.agency.gov:3128".
+	// Empty uses HTTPS_PROXY, HTTP_PROXY and NO_PROXY from the environment.
+	ProxyURL string
+
+	// CAFiles are PEM bundles trusted in addition to the system roots, such as the
+	// agency's private CA or a TLS-inspecting proxy's certificate
+	CAFiles []string
+
+	MaxIdleConnsPerHost int           // Defaults to DefaultMaxIdleConnsPerHost
+	IdleConnTimeout     time.Duration // Defaults to 90 seconds
+}
+
+// NewTransport builds a transport from the config. Share one transport across
+// clients so requests to the same host reuse pooled connections.
+func NewTransport(config TransportConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	if config.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	}
+	if config.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = config.IdleConnTimeout
+	}
+
+	if config.ProxyURL != "" {
+		proxy, err := url.Parse(config.ProxyURL)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", config.ProxyURL)
+		}
+		if proxy.Scheme != "http" && proxy.Scheme != "https" {
+			return nil, fmt.Errorf("unsupported proxy scheme %q (want http or https)", proxy.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if len(config.CAFiles) > 0 {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		for _, name := range config.CAFiles {
+			pem, err := os.ReadFile(name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA file: %w", err)
+			}
+			if !roots.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in CA file %s", name)
+			}
+		}
+		transport.TLSClientConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    roots,
+		}
+	}
+
+	return transport, nil
+}
+
+// defaultTransport is shared by every client the package creates when
+// ClientOptions.Transport is unset
+var defaultTransport = func() *http.Transport {
+	transport, _ := NewTransport(TransportConfig{})
+	return transport
+}()
+
+// sharedTransport returns the transport configured with SetClientOptions, or the
+// package's pooled default
+func sharedTransport() http.RoundTripper {
+	clientOptionsMu.RLock()
+	defer clientOptionsMu.RUnlock()
+
+	if clientOptions.Transport != nil {
+		return clientOptions.Transport
+	}
+	return defaultTransport
+}
+
+// newHTTPClient returns a client on the shared transport with the given timeout
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: sharedTransport()}
+}
+
+// runTransport marks the clients of a generation run with the run's settings, which
+// the request helpers read back with runOf; requests pass through unchanged
+type runTransport struct {
+	base  http.RoundTripper
+	ctx    context.Context // Run's context; never nil
+	retry  RetryPolicy
+	gitHub gitHubAPI
+}
+
+func (t *runTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req)
+}
+
+// runOf returns the settings of the run a client belongs to, or nil for clients of
+// the package-level helpers
+func runOf(client *http.Client) *runTransport {
+	run, _ := client.Transport.(*runTransport)
+	return run
+}
+
+// contextTransport fails requests once a run's context is done, canceling those in
+// flight, while keeping each request's own deadline from the client timeout
+type contextTransport struct {
+	base http.RoundTripper
+	ctx  context.Context
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	stop := context.AfterFunc(t.ctx, cancel)
+	release := func() {
+		stop()
+		cancel()
+	}
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		release()
+		return nil, err
+	}
+	// The body is read after RoundTrip returns, so the request lives until it is closed
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody calls release when the response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
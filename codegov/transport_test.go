@@ -0,0 +1,182 @@
+package codegov
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewTransportProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte(`["Go"]`))
+	}))
+	defer proxy.Close()
+
+	transport, err := NewTransport(TransportConfig{ProxyURL: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Timeout: 5 * time.Second, Transport: transport}
+	resp, err := client.Get("http://api.github.example.gov/repos/org/repo/languages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if proxied != "http://api.github.example.gov/repos/org/repo/languages" {
+		t.Errorf("proxy received %q, want the absolute API URL", proxied)
+	}
+}
+
+func TestNewTransportCAFiles(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	untrusted, err := NewTransport(TransportConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&http.Client{Transport: untrusted}).Get(srv.URL); err == nil {
+		t.Fatal("expected a certificate error without the CA file")
+	}
+
+	trusted, err := NewTransport(TransportConfig{CAFiles: []string{caFile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: trusted}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request with CA file: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewTransportErrors(t *testing.T) {
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config TransportConfig
+		want   string
+	}{
+		{"proxy without host", TransportConfig{ProxyURL: "proxy.agency.gov"}, "invalid proxy URL"},
+		{"socks proxy", TransportConfig{ProxyURL: "socks5://proxy.agency.gov:1080"}, "unsupported proxy scheme"},
+		{"missing CA file", TransportConfig{CAFiles: []string{filepath.Join(t.TempDir(), "missing.pem")}}, "failed to read CA file"},
+		{"CA file without certificates", TransportConfig{CAFiles: []string{notPEM}}, "no certificates found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTransport(tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewTransport() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTransportPooling(t *testing.T) {
+	transport, err := NewTransport(TransportConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost {
+		t.Errorf("MaxIdleConnsPerHost = %d, want %d", transport.MaxIdleConnsPerHost, DefaultMaxIdleConnsPerHost)
+	}
+}
+
+// countingTransport counts the requests it forwards
+type countingTransport struct {
+	base     http.RoundTripper
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests++
+	return c.base.RoundTrip(req)
+}
+
+func TestSharedTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	global := &countingTransport{base: http.DefaultTransport}
+	SetClientOptions(ClientOptions{Transport: global})
+	defer SetClientOptions(ClientOptions{})
+
+	if !TestURL(srv.URL) {
+		t.Fatal("expected URL probe to succeed")
+	}
+	if global.requests != 1 {
+		t.Errorf("package-level transport carried %d requests, want 1", global.requests)
+	}
+
+	run := &countingTransport{base: http.DefaultTransport}
+	g := &generator{transport: run}
+	for i := 0; i < 3; i++ {
+		if !probeURL(g.client(time.Second), srv.URL) {
+			t.Fatal("expected URL probe to succeed")
+		}
+	}
+	if run.requests != 3 || global.requests != 1 {
+		t.Errorf("run transport carried %d requests and package transport %d, want 3 and 1", run.requests, global.requests)
+	}
+}
+
+func TestContextTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(`["Go"]`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &http.Client{Timeout: 5 * time.Second, Transport: &contextTransport{base: srv.Client().Transport, ctx: ctx}}
+
+	// The body outlives RoundTrip until it is closed
+	resp, err := client.Get(srv.URL + "/fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != `["Go"]` {
+		t.Fatalf("unexpected body %q, %v", body, err)
+	}
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := client.Get(srv.URL + "/slow"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the in-flight request to be canceled, got %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Error("canceling the run did not end the in-flight request")
+	}
+	if _, err := client.Get(srv.URL + "/fast"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected later requests to fail, got %v", err)
+	}
+}
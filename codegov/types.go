@@ -1,12 +1,16 @@
 package codegov
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // GitHubRepository represents a GitHub repository from the API
 type GitHubRepository struct {
 	Name              string    `json:"name"`
 	Description       string    `json:"description"`
 	HTMLURL           string    `json:"html_url"`
+	CloneURL          string    `json:"clone_url"`
 	Private           bool      `json:"private"`
 	Fork              bool      `json:"fork"`
 	Archived          bool      `json:"archived"`
@@ -18,6 +22,9 @@ type GitHubRepository struct {
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
 	PushedAt          time.Time `json:"pushed_at"`
+	Owner             struct {
+		Login string `json:"login"`
+	} `json:"owner"`
 }
 
 // GitHubLicense represents license information from GitHub API
@@ -31,11 +38,131 @@ type GitHubLicense struct {
 
 // GitHubRelease represents a release from GitHub API
 type GitHubRelease struct {
-	Prerelease bool   `json:"prerelease"`
-	ZipballURL string `json:"zipball_url"`
+	TagName     string    `json:"tag_name"`
+	Prerelease  bool      `json:"prerelease"`
+	ZipballURL  string    `json:"zipball_url"`
 	PublishedAt time.Time `json:"published_at"`
 }
 
+// GitLabProject represents a project from the GitLab API
+type GitLabProject struct {
+	ID                int       `json:"id"`
+	Name              string    `json:"name"`
+	Path              string    `json:"path"`
+	PathWithNamespace string    `json:"path_with_namespace"`
+	Description       string    `json:"description"`
+	WebURL            string    `json:"web_url"`
+	HTTPURLToRepo     string    `json:"http_url_to_repo"`
+	Visibility        string    `json:"visibility"`
+	Archived          bool      `json:"archived"`
+	Topics            []string  `json:"topics"`
+	TagList           []string  `json:"tag_list"`
+	DefaultBranch     string    `json:"default_branch"`
+	ForkedFromProject *struct {
+		ID int `json:"id"`
+	} `json:"forked_from_project"`
+	License *struct {
+		Key     string `json:"key"`
+		Name    string `json:"name"`
+		HTMLURL string `json:"html_url"`
+	} `json:"license"`
+	LicenseURL     string    `json:"license_url"`
+	CreatedAt      time.Time `json:"created_at"`
+	LastActivityAt time.Time `json:"last_activity_at"`
+}
+
+// GitLabRelease represents a release from the GitLab API
+type GitLabRelease struct {
+	TagName         string    `json:"tag_name"`
+	UpcomingRelease bool      `json:"upcoming_release"`
+	ReleasedAt      time.Time `json:"released_at"`
+	Assets          struct {
+		Sources []struct {
+			Format string `json:"format"`
+			URL    string `json:"url"`
+		} `json:"sources"`
+	} `json:"assets"`
+}
+
+// BitbucketRepository represents a repository from the Bitbucket Cloud API
+type BitbucketRepository struct {
+	Name        string    `json:"name"`
+	FullName    string    `json:"full_name"`
+	Description string    `json:"description"`
+	IsPrivate   bool      `json:"is_private"`
+	Language    string    `json:"language"`
+	CreatedOn   time.Time `json:"created_on"`
+	UpdatedOn   time.Time `json:"updated_on"`
+	MainBranch  struct {
+		Name string `json:"name"`
+	} `json:"mainbranch"`
+	Parent *struct {
+		FullName string `json:"full_name"`
+	} `json:"parent"`
+	Links struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+		Clone []struct {
+			Name string `json:"name"`
+			Href string `json:"href"`
+		} `json:"clone"`
+	} `json:"links"`
+}
+
+// BitbucketRepositoryPage is a page of Bitbucket Cloud repositories
+type BitbucketRepositoryPage struct {
+	Values []BitbucketRepository `json:"values"`
+	Next   string                `json:"next"`
+}
+
+// BitbucketServerRepository represents a repository from the Bitbucket Server / Data Center API
+type BitbucketServerRepository struct {
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Public      bool   `json:"public"`
+	Archived    bool   `json:"archived"`
+	Project     struct {
+		Key string `json:"key"`
+	} `json:"project"`
+	Origin *struct {
+		Slug string `json:"slug"`
+	} `json:"origin"`
+	Links struct {
+		Self []struct {
+			Href string `json:"href"`
+		} `json:"self"`
+		Clone []struct {
+			Name string `json:"name"`
+			Href string `json:"href"`
+		} `json:"clone"`
+	} `json:"links"`
+}
+
+// BitbucketServerRepositoryPage is a page of Bitbucket Server repositories
+type BitbucketServerRepositoryPage struct {
+	Values        []BitbucketServerRepository `json:"values"`
+	IsLastPage    bool                        `json:"isLastPage"`
+	NextPageStart int                         `json:"nextPageStart"`
+}
+
+// AzureDevOpsRepository represents a Git repository from the Azure DevOps API
+type AzureDevOpsRepository struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	WebURL        string `json:"webUrl"`
+	RemoteURL     string `json:"remoteUrl"`
+	DefaultBranch string `json:"defaultBranch"` // Full ref name, e.g. "refs/heads/main"; empty for an empty repository
+	IsDisabled    bool   `json:"isDisabled"`
+	IsFork        bool   `json:"isFork"`
+	Project       struct {
+		Name           string    `json:"name"`
+		Visibility     string    `json:"visibility"`
+		LastUpdateTime time.Time `json:"lastUpdateTime"`
+	} `json:"project"`
+}
+
 // License represents a license in code.gov format
 type License struct {
 	URL  string `json:"URL"`
@@ -52,8 +179,37 @@ type Contact struct {
 
 // Permissions represents release permissions
 type Permissions struct {
-	Licenses  []License `json:"licenses"`
-	UsageType string    `json:"usageType"`
+	Licenses      []License `json:"licenses"`
+	UsageType     string    `json:"usageType"`
+	ExemptionText string    `json:"exemptionText,omitempty"` // Justification for an exempt usage type
+}
+
+// Usage types defined by the code.gov 2.0.0 schema
+const (
+	UsageTypeOpenSource               = "openSource"
+	UsageTypeGovernmentWideReuse      = "governmentWideReuse"
+	UsageTypeExemptByLaw              = "exemptByLaw"
+	UsageTypeExemptByNationalSecurity = "exemptByNationalSecurity"
+	UsageTypeExemptByAgencySystem     = "exemptByAgencySystem"
+	UsageTypeExemptByAgencyMission    = "exemptByAgencyMission"
+	UsageTypeExemptByCIO              = "exemptByCIO"
+	UsageTypeExemptByPolicyDate       = "exemptByPolicyDate"
+)
+
+// ValidUsageType reports whether usageType is one of the schema's usage types
+func ValidUsageType(usageType string) bool {
+	switch usageType {
+	case UsageTypeOpenSource, UsageTypeGovernmentWideReuse, UsageTypeExemptByLaw,
+		UsageTypeExemptByNationalSecurity, UsageTypeExemptByAgencySystem,
+		UsageTypeExemptByAgencyMission, UsageTypeExemptByCIO, UsageTypeExemptByPolicyDate:
+		return true
+	}
+	return false
+}
+
+// ExemptUsageType reports whether usageType claims an exemption, which calls for exemptionText
+func ExemptUsageType(usageType string) bool {
+	return ValidUsageType(usageType) && strings.HasPrefix(usageType, "exempt")
 }
 
 // DateInfo represents date information for a release
@@ -63,22 +219,51 @@ type DateInfo struct {
 	MetadataLastUpdated  string `json:"metadataLastUpdated"`
 }
 
+// Partner is an organization that collaborated on a release
+type Partner struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+}
+
+// RelatedCode is a project related to a release, such as one it is part of
+type RelatedCode struct {
+	Name             string `json:"name"`
+	URL              string `json:"URL"`
+	IsGovernmentRepo bool   `json:"isGovernmentRepo"`
+}
+
+// ReusedCode is a project whose code a release reuses
+type ReusedCode struct {
+	Name string `json:"name"`
+	URL  string `json:"URL"`
+}
+
 // Release represents a single release in code.gov format
 type Release struct {
-	Name           string      `json:"name"`
-	RepositoryURL  string      `json:"repositoryURL"`
-	Description    string      `json:"description"`
-	Permissions    Permissions `json:"permissions"`
-	LaborHours     float64     `json:"laborHours"`
-	Tags           []string    `json:"tags"`
-	Contact        Contact     `json:"contact"`
-	Status         string      `json:"status"`
-	VCS            string      `json:"vcs"`
-	HomepageURL    string      `json:"homepageURL"`
-	DownloadURL    string      `json:"downloadURL"`
-	DisclaimerURL  string      `json:"disclaimerURL,omitempty"`
-	Languages      []string    `json:"languages,omitempty"`
-	Date           DateInfo    `json:"date"`
+	Name           string        `json:"name"`
+	Version        string        `json:"version,omitempty"`
+	Organization   string        `json:"organization,omitempty"`
+	RepositoryURL  string        `json:"repositoryURL"`
+	Description    string        `json:"description"`
+	Permissions    Permissions   `json:"permissions"`
+	LaborHours     float64       `json:"laborHours"`
+	Tags           []string      `json:"tags"`
+	Contact        Contact       `json:"contact"`
+	Partners       []Partner     `json:"partners,omitempty"`
+	Status         string        `json:"status"`
+	VCS            string        `json:"vcs"`
+	HomepageURL    string        `json:"homepageURL"`
+	DownloadURL    string        `json:"downloadURL"`
+	DisclaimerURL  string        `json:"disclaimerURL,omitempty"`
+	DisclaimerText string        `json:"disclaimerText,omitempty"`
+	Languages      []string      `json:"languages,omitempty"`
+	RelatedCode    []RelatedCode `json:"relatedCode,omitempty"`
+	ReusedCode     []ReusedCode  `json:"reusedCode,omitempty"`
+	Date           DateInfo      `json:"date"`
+
+	// AdditionalInformation holds free-form fields for inventories that accept them.
+	// The 2.0.0 schema does not define it, so validation reports it when set.
+	AdditionalInformation map[string]interface{} `json:"additionalInformation,omitempty"`
 }
 
 // MeasurementType represents measurement type for code.gov
@@ -0,0 +1,128 @@
+package codegov
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// shortSHALength is the length of the commit SHA prefix published as the version of
+// repositories without releases or tags
+const shortSHALength = 7
+
+// prereleaseMarkers flag tag names that are not stable releases
+var prereleaseMarkers = []string{"alpha", "beta", "rc", "pre", "preview", "dev", "snapshot", "nightly"}
+
+// isPrereleaseTag reports whether a tag names a prerelease, such as v2.0.0-rc.1,
+// 1.4b2 or nightly-2024-05-01
+func isPrereleaseTag(name string) bool {
+	lower := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(name, "v"), "V"))
+	// A semantic version's prerelease part follows a hyphen; build metadata follows a plus
+	if core, _, _ := strings.Cut(lower, "+"); strings.Contains(core, "-") && startsWithDigit(core) {
+		return true
+	}
+	words := strings.FieldsFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) })
+	for _, word := range words {
+		for _, marker := range prereleaseMarkers {
+			if word == marker {
+				return true
+			}
+		}
+		// Short forms glued to a number, e.g. 1.4b2 or 3.0a1
+		if (word == "a" || word == "b") && startsWithDigit(lower) {
+			return true
+		}
+	}
+	return false
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+// latestTag returns the highest stable version among tag names, or "" if every tag is
+// a prerelease. Tags are compared by their numeric parts; among tags without numbers,
+// or with equal ones, the first listed wins, so pass tags newest first.
+func latestTag(names []string) string {
+	best := ""
+	var bestParts []int
+	for _, name := range names {
+		if name == "" || isPrereleaseTag(name) {
+			continue
+		}
+		parts := versionParts(name)
+		if best == "" || compareVersionParts(parts, bestParts) > 0 {
+			best, bestParts = name, parts
+		}
+	}
+	return best
+}
+
+// versionParts returns the numbers in a version string, e.g. [1 4 2] for "release-1.4.2"
+func versionParts(version string) []int {
+	var parts []int
+	for _, field := range strings.FieldsFunc(version, func(r rune) bool { return r < '0' || r > '9' }) {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// compareVersionParts orders versions numerically part by part; a missing part counts as 0
+func compareVersionParts(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// shortSHA returns the prefix of a commit SHA published as a version
+func shortSHA(sha string) string {
+	if len(sha) > shortSHALength {
+		return sha[:shortSHALength]
+	}
+	return sha
+}
+
+// releaseVersion picks a release's version: the tag of the latest release, else the
+// latest stable tag, else the default branch's commit SHA prefix, so harvesters can
+// detect updates to repositories that never cut a release. tags and sha are only
+// called when needed; their errors are reported as warnings and the next source is
+// tried.
+func (g *generator) releaseVersion(repoName, releaseTag string, tags func() ([]string, error), sha func() (string, error)) string {
+	if releaseTag != "" {
+		return releaseTag
+	}
+	if tags != nil {
+		names, err := tags()
+		if err != nil {
+			g.enrichmentError(repoName, "version", err)
+		}
+		if tag := latestTag(names); tag != "" {
+			return tag
+		}
+	}
+	if sha != nil {
+		commit, err := sha()
+		if err != nil {
+			g.enrichmentError(repoName, "version", err)
+		}
+		return shortSHA(commit)
+	}
+	return ""
+}
@@ -0,0 +1,40 @@
+package codegov
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLatestTag(t *testing.T) {
+	for want, tags := range map[string][]string{
+		"v2.10.0":       {"v2.9.1", "v2.10.0", "v2.10.0-rc.2", "v3.0.0-beta"},
+		"release-1.4":   {"release-1.4", "1.4b2", "nightly-2024-05-01"},
+		"stable":        {"stable", "latest"},
+		"v1.0.0+build1": {"v1.0.0+build1"},
+		"":              {"v0.1.0-alpha", "1.0rc1"},
+	} {
+		if got := latestTag(tags); got != want {
+			t.Errorf("latestTag(%q) = %q, want %q", tags, got, want)
+		}
+	}
+}
+
+func TestReleaseVersion(t *testing.T) {
+	g := &generator{report: &GenerationReport{}}
+	noTags := func() ([]string, error) { return nil, nil }
+	sha := func() (string, error) { return "9fceb02d0ae598e95dc970b74767f19372d61af8", nil }
+
+	if got := g.releaseVersion("org/a", "v1.2.0", nil, nil); got != "v1.2.0" {
+		t.Errorf("expected the release tag, got %q", got)
+	}
+	if got := g.releaseVersion("org/b", "", func() ([]string, error) { return []string{"v0.3.0"}, nil }, sha); got != "v0.3.0" {
+		t.Errorf("expected the latest tag, got %q", got)
+	}
+	if got := g.releaseVersion("org/c", "", noTags, sha); got != "9fceb02" {
+		t.Errorf("expected the SHA prefix, got %q", got)
+	}
+	failing := func() ([]string, error) { return nil, errors.New("tags unavailable") }
+	if got := g.releaseVersion("org/d", "", failing, sha); got != "9fceb02" || len(g.report.Warnings()) != 1 {
+		t.Errorf("expected a warning and the SHA prefix, got %q %v", got, g.report.Issues)
+	}
+}
@@ -0,0 +1,505 @@
+package codegov
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a non-blank line of a YAML document
+type yamlLine struct {
+	num    int // 1-based line number for error messages
+	indent int
+	text   string // Content after the indentation, comments included
+}
+
+// parseYAML decodes the block-style YAML subset used by metadata files: nested mappings
+// and sequences, flow sequences and mappings, quoted and plain scalars, comments and
+// literal (|) or folded (>) block scalars. Anchors, tags and multiple documents are not
+// supported. The result uses the same types as encoding/json: map[string]interface{},
+// []interface{}, string, float64, bool and nil.
+func parseYAML(data []byte) (interface{}, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		if stripYAMLComment(text) == "" || (i == 0 && strings.TrimSpace(text) == "---") {
+			continue
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(raw) - len(text), text: text})
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	p := &yamlParser{lines: lines}
+	v, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", lines[p.pos].num)
+	}
+	return v, nil
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// block parses the mapping or sequence starting at the current line
+func (p *yamlParser) block(indent int) (interface{}, error) {
+	if isYAMLSequenceItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	items := []interface{}{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSequenceItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		item := strings.TrimLeft(line.text[1:], " ")
+		itemIndent := indent + len(line.text) - len(item)
+
+		switch {
+		case stripYAMLComment(item) == "":
+			p.pos++
+			v, err := p.nested(indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		case isYAMLSequenceItem(item) || yamlKeyEnd(item) > 0:
+			// A nested block starting on the item's own line, e.g. "- name: MIT"
+			p.lines[p.pos] = yamlLine{num: line.num, indent: itemIndent, text: item}
+			v, err := p.block(itemIndent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		default:
+			p.pos++
+			v, err := parseYAMLScalar(stripYAMLComment(item), line.num)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+	}
+	return items, nil
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && !isYAMLSequenceItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		end := yamlKeyEnd(line.text)
+		if end < 0 {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line.num)
+		}
+		key, err := parseYAMLScalar(line.text[:end], line.num)
+		if err != nil {
+			return nil, err
+		}
+		name := fmt.Sprint(key)
+		if _, dup := m[name]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, name)
+		}
+		p.pos++
+
+		rest := stripYAMLComment(strings.TrimSpace(line.text[end+1:]))
+		switch {
+		case rest == "":
+			// Sequences may sit at the same indentation as their key
+			if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSequenceItem(p.lines[p.pos].text) {
+				m[name], err = p.sequence(indent)
+			} else {
+				m[name], err = p.nested(indent)
+			}
+		case rest[0] == '|' || rest[0] == '>':
+			m[name] = p.blockScalar(indent, rest[0] == '>', strings.HasSuffix(rest, "-"))
+		default:
+			m[name], err = parseYAMLScalar(rest, line.num)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// nested parses a block indented deeper than its parent, or returns nil if there is none
+func (p *yamlParser) nested(parent int) (interface{}, error) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= parent {
+		return nil, nil
+	}
+	return p.block(p.lines[p.pos].indent)
+}
+
+// blockScalar collects the lines of a literal or folded block scalar
+func (p *yamlParser) blockScalar(parent int, folded, strip bool) string {
+	var parts []string
+	indent := -1
+	for p.pos < len(p.lines) && p.lines[p.pos].indent > parent {
+		line := p.lines[p.pos]
+		if indent < 0 {
+			indent = line.indent
+		}
+		parts = append(parts, strings.Repeat(" ", max(line.indent-indent, 0))+line.text)
+		p.pos++
+	}
+
+	sep := "\n"
+	if folded {
+		sep = " "
+	}
+	s := strings.Join(parts, sep)
+	if !strip && s != "" {
+		s += "\n"
+	}
+	return s
+}
+
+// isYAMLSequenceItem reports whether a line starts a sequence item
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// yamlKeyEnd returns the index of the colon ending a mapping key, or -1
+func yamlKeyEnd(text string) int {
+	if strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") {
+		return -1 // A flow collection, whose colons belong to its own entries
+	}
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++ // Skip the escaped character, e.g. \"
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 {
+				quote = c
+			}
+		case c == '#' && (i == 0 || text[i-1] == ' '):
+			return -1
+		case c == ':' && (i+1 == len(text) || text[i+1] == ' '):
+			if i == 0 {
+				return -1
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// stripYAMLComment removes a trailing comment outside quotes and surrounding spaces
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++ // Skip the escaped character, e.g. \"
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || text[i-1] == ' ' || text[i-1] == '[' || text[i-1] == ',' || text[i-1] == '{' {
+				quote = c
+			}
+		case c == '#' && (i == 0 || text[i-1] == ' '):
+			return strings.TrimSpace(text[:i])
+		}
+	}
+	return strings.TrimSpace(text)
+}
+
+// parseYAMLScalar parses a quoted, plain or flow value
+func parseYAMLScalar(s string, num int) (interface{}, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		return nil, nil
+	case s[0] == '"':
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid double-quoted string %s", num, s)
+		}
+		return v, nil
+	case s[0] == '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, fmt.Errorf("line %d: unterminated single-quoted string %s", num, s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case s[0] == '[':
+		if s[len(s)-1] != ']' {
+			return nil, fmt.Errorf("line %d: unterminated flow sequence", num)
+		}
+		items := []interface{}{}
+		for _, part := range splitYAMLFlow(s[1 : len(s)-1]) {
+			v, err := parseYAMLScalar(part, num)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case s[0] == '{':
+		if s[len(s)-1] != '}' {
+			return nil, fmt.Errorf("line %d: unterminated flow mapping", num)
+		}
+		m := make(map[string]interface{})
+		for _, part := range splitYAMLFlow(s[1 : len(s)-1]) {
+			end := yamlKeyEnd(part)
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: expected \"key: value\" in flow mapping", num)
+			}
+			key, err := parseYAMLScalar(part[:end], num)
+			if err != nil {
+				return nil, err
+			}
+			if m[fmt.Sprint(key)], err = parseYAMLScalar(part[end+1:], num); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+
+	switch s {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !strings.ContainsAny(s, "xXpP_") {
+		return f, nil
+	}
+	return s, nil
+}
+
+// splitYAMLFlow splits the contents of a flow collection on top-level commas
+func splitYAMLFlow(s string) []string {
+	var parts []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++ // Skip the escaped character, e.g. \"
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+// yamlNode is a decoded JSON value that keeps its keys in document order
+type yamlNode struct {
+	scalar string // Rendered scalar; empty for mappings and sequences
+	keys   []string
+	values []*yamlNode
+	seq    bool
+}
+
+// jsonToYAML converts a JSON document to block-style YAML that parseYAML reads back
+// to the same JSON. Key order and number literals are kept; strings that would be
+// read as another type or cannot be written plainly are double-quoted.
+func jsonToYAML(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	node, err := readYAMLNode(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after JSON document")
+	}
+
+	var b strings.Builder
+	switch {
+	case node.scalar != "":
+		b.WriteString(node.scalar + "\n")
+	case len(node.values) == 0 && node.seq:
+		b.WriteString("[]\n")
+	case len(node.values) == 0:
+		b.WriteString("{}\n")
+	default:
+		writeYAMLBlock(&b, node, 0)
+	}
+	return []byte(b.String()), nil
+}
+
+// readYAMLNode decodes the next JSON value
+func readYAMLNode(dec *json.Decoder) (*yamlNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		node := &yamlNode{seq: t == '['}
+		for dec.More() {
+			if !node.seq {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, key.(string))
+			}
+			value, err := readYAMLNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			node.values = append(node.values, value)
+		}
+		if _, err := dec.Token(); err != nil { // Closing delimiter
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return &yamlNode{scalar: yamlString(t)}, nil
+	case json.Number:
+		return &yamlNode{scalar: t.String()}, nil
+	case bool:
+		return &yamlNode{scalar: strconv.FormatBool(t)}, nil
+	default:
+		return &yamlNode{scalar: "null"}, nil
+	}
+}
+
+// writeYAMLBlock writes a non-empty mapping or sequence at indent
+func writeYAMLBlock(b *strings.Builder, node *yamlNode, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for i, value := range node.values {
+		if node.seq {
+			if value.scalar == "" && len(value.values) > 0 && !value.seq {
+				// A mapping item starts on the dash line: "- name: MIT"
+				var item strings.Builder
+				writeYAMLBlock(&item, value, indent+2)
+				b.WriteString(pad + "- " + item.String()[indent+2:])
+				continue
+			}
+			b.WriteString(pad + "-")
+		} else {
+			b.WriteString(pad + yamlString(node.keys[i]) + ":")
+		}
+
+		switch {
+		case value.scalar != "":
+			// Block scalars are read in mappings only
+			if text, ok := yamlLiteral(value.scalar); ok && !node.seq {
+				b.WriteString(" |-\n")
+				for _, line := range strings.Split(text, "\n") {
+					b.WriteString(pad + "  " + line + "\n")
+				}
+				continue
+			}
+			b.WriteString(" " + value.scalar + "\n")
+		case len(value.values) == 0 && value.seq:
+			b.WriteString(" []\n")
+		case len(value.values) == 0:
+			b.WriteString(" {}\n")
+		default:
+			b.WriteString("\n")
+			writeYAMLBlock(b, value, indent+2)
+		}
+	}
+}
+
+// yamlString renders a string plainly when parseYAML reads it back unchanged, and
+// double-quoted otherwise
+func yamlString(s string) string {
+	plain := s != "" && s == strings.TrimSpace(s) &&
+		!strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") &&
+		!strings.ContainsAny(s, "\n\r\t") && !strings.Contains(s, ": ") && !strings.Contains(s, " #") &&
+		!strings.HasSuffix(s, ":") && strconv.IsPrint(rune(s[0]))
+	if plain {
+		for _, r := range s {
+			if !strconv.IsPrint(r) {
+				plain = false
+				break
+			}
+		}
+	}
+	if plain {
+		if v, err := parseYAMLScalar(stripYAMLComment(s), 0); err == nil && v == s {
+			return s
+		}
+	}
+	return strconv.Quote(s)
+}
+
+// yamlLiteral returns the text of a quoted multi-line string when a literal block
+// scalar (|-) reads back unchanged: no blank, comment-like or leading-space lines
+// and no trailing newline
+func yamlLiteral(scalar string) (string, bool) {
+	if scalar[0] != '"' {
+		return "", false
+	}
+	s, err := strconv.Unquote(scalar)
+	if err != nil || !strings.Contains(s, "\n") || strings.ContainsAny(s, "\r\t") {
+		return "", false
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if line == "" || line != strings.TrimSpace(line) || strings.HasPrefix(line, "#") {
+			return "", false
+		}
+		for _, r := range line {
+			if !strconv.IsPrint(r) {
+				return "", false
+			}
+		}
+	}
+	return s, true
+}
+
+// isYAMLPath reports whether a file name selects YAML (.yaml or .yml) over JSON
+func isYAMLPath(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".yaml") || strings.HasSuffix(lower, ".yml")
+}
+
+// readDocument reads a JSON or, by extension, YAML file and returns it as JSON
+func readDocument(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !isYAMLPath(path) {
+		return data, err
+	}
+
+	doc, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return json.Marshal(doc)
+}
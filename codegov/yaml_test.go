@@ -0,0 +1,203 @@
+package codegov
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want interface{}
+	}{
+		{"scalars", "s: text\nq: \"a\\tb\"\nsq: 'it''s'\nn: 42\nf: 1.5\nb: true\ntilde: ~\nempty:\n",
+			map[string]interface{}{"s": "text", "q": "a\tb", "sq": "it's", "n": 42.0, "f": 1.5, "b": true, "tilde": nil, "empty": nil}},
+		{"comments", "# heading\nkey: value # trailing\nurl: http://x.gov/#anchor\n",
+			map[string]interface{}{"key": "value", "url": "http://x.gov/#anchor"}},
+		{"nested mapping", "a:\n  b:\n    c: 1\n  d: 2\n",
+			map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": 1.0}, "d": 2.0}}},
+		{"sequence of mappings", "items:\n- name: a\n  n: 1\n- name: b\n",
+			map[string]interface{}{"items": []interface{}{map[string]interface{}{"name": "a", "n": 1.0}, map[string]interface{}{"name": "b"}}}},
+		{"top-level sequence", "- a\n- [b, 'c, d']\n- {k: v}\n",
+			[]interface{}{"a", []interface{}{"b", "c, d"}, map[string]interface{}{"k": "v"}}},
+		{"literal block", "text: |\n  one\n  two\nnext: x\n",
+			map[string]interface{}{"text": "one\ntwo\n", "next": "x"}},
+		{"folded block", "text: >-\n  one\n  two\n",
+			map[string]interface{}{"text": "one two"}},
+		{"document marker", "---\nkey: value\n", map[string]interface{}{"key": "value"}},
+		{"empty document", "# nothing\n", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAML([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("parseYAML failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"tab indentation", "a:\n\tb: 1\n", "tabs are not allowed"},
+		{"bad indentation", "a: 1\n  b: 2\n", "unexpected indentation"},
+		{"duplicate key", "a: 1\na: 2\n", "duplicate key"},
+		{"missing colon", "a: 1\nplain\n", "expected \"key: value\""},
+		{"bad double quote", "a: \"\\q\"\n", "invalid double-quoted string"},
+		{"unterminated single quote", "a: 'open\n", "unterminated single-quoted string"},
+		{"unterminated flow sequence", "a: [1, 2\n", "unterminated flow sequence"},
+		{"unterminated flow mapping", "a: {k: v\n", "unterminated flow mapping"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseYAML([]byte(tt.in))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestInventoryYAMLRoundTrip(t *testing.T) {
+	codeGov := patchFixture()
+	codeGov.Releases[0].Description = "First line\nSecond line: with a colon"
+	codeGov.Releases[0].LaborHours = 1200.5
+	codeGov.Releases[1].Description = "Ends with a newline\n"
+	codeGov.Releases[1].Tags = []string{"true", "", "1e5", "- dash", "it's # not a comment", "key: value", " padded", "ünïcode"}
+	codeGov.Releases[2].Partners = []Partner{{Name: "Agency B", Email: "b@agency.gov"}}
+	codeGov.Releases[2].AdditionalInformation = map[string]interface{}{"nested": []interface{}{[]interface{}{"a"}, map[string]interface{}{}}, "12": nil}
+
+	path := filepath.Join(t.TempDir(), "code.yaml")
+	if err := writeCodeGovJSON(codeGov, path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "description: |-\n      First line\n") {
+		t.Errorf("expected a literal block for the multi-line description:\n%s", data)
+	}
+
+	read, err := ReadCodeGovJSONFile(path)
+	if err != nil {
+		t.Fatalf("failed to read YAML inventory: %v\n%s", err, data)
+	}
+	want, _ := json.Marshal(codeGov)
+	got, _ := json.Marshal(read)
+	if string(got) != string(want) {
+		t.Errorf("round trip changed the inventory:\nwant %s\ngot  %s", want, got)
+	}
+}
+
+func TestJSONToYAMLKeepsOrder(t *testing.T) {
+	data, err := jsonToYAML([]byte(`{"b": 1, "a": [{"y": true, "x": null}], "c": []}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "b: 1\na:\n  - y: true\n    x: null\nc: []\n"
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+
+	doc, err := parseYAML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"b": 1.0, "a": []interface{}{map[string]interface{}{"y": true, "x": nil}}, "c": []interface{}{}}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("unexpected document %#v", doc)
+	}
+}
+
+func TestInvokeCodeGovJsonOverrideYAML(t *testing.T) {
+	dir := t.TempDir()
+	original := filepath.Join(dir, "code.yaml")
+	overrides := filepath.Join(dir, "overrides.yml")
+	final := filepath.Join(dir, "code-final.json")
+
+	if err := writeCodeGovJSON(patchFixture(), original); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(overrides, []byte(`# Reviewed in the inventory PR
+overrides:
+  - project: beta
+    action: replaceproperty
+    property: laborHours
+    value: 40
+  - project: gamma
+    action: removeproject
+`), 0644)
+
+	if err := InvokeCodeGovJsonOverride(original, final, overrides); err != nil {
+		t.Fatalf("override failed: %v", err)
+	}
+	codeGov, err := ReadCodeGovJSONFile(final)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codeGov.Releases) != 2 || codeGov.Releases[1].LaborHours != 40 {
+		t.Errorf("overrides not applied: %+v", codeGov.Releases)
+	}
+
+	// JSON Patch documents may be YAML sequences
+	os.WriteFile(overrides, []byte("- op: remove\n  path: /releases/alpha\n"), 0644)
+	if err := InvokeCodeGovJsonOverride(original, final, overrides); err != nil {
+		t.Fatalf("YAML patch failed: %v", err)
+	}
+	if codeGov, _ = ReadCodeGovJSONFile(final); len(codeGov.Releases) != 2 || codeGov.Releases[0].Name != "beta" {
+		t.Errorf("patch not applied: %+v", codeGov.Releases)
+	}
+}
+
+func FuzzParseYAML(f *testing.F) {
+	f.Add([]byte("version: \"2.0\"\nagency: TEST\nreleases:\n  - name: alpha\n    tags: [go, cli]\n    description: |-\n      First line\n      Second line\n"))
+	f.Add([]byte("---\n# comment\na:\n  - b: {c: 1, d: [x, 'y']}\n  - >\n    folded\n    text\n"))
+	f.Add([]byte("- - a\n  - b\n- key: \"esc\\u00e9\"\n"))
+	f.Add([]byte("a:\n\tb: 1\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// encoding/json replaces invalid UTF-8, so distinct keys could collide
+		if !utf8.Valid(data) {
+			return
+		}
+		doc, err := parseYAML(data)
+		if err != nil {
+			return
+		}
+		// Documents are mappings or sequences; the writer has no top-level scalar form
+		switch doc.(type) {
+		case map[string]interface{}, []interface{}:
+		default:
+			return
+		}
+		js, err := json.Marshal(doc)
+		if err != nil {
+			return
+		}
+		// Anything the parser accepts must survive the writer and parse back unchanged
+		out, err := jsonToYAML(js)
+		if err != nil {
+			t.Fatalf("jsonToYAML(%s): %v", js, err)
+		}
+		again, err := parseYAML(out)
+		if err != nil {
+			t.Fatalf("failed to parse written YAML: %v\n%s", err, out)
+		}
+		if js2, _ := json.Marshal(again); string(js2) != string(js) {
+			t.Fatalf("round trip changed the document:\nwant %s\ngot  %s\nyaml:\n%s", js, js2, out)
+		}
+	})
+}
@@ -4,8 +4,17 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/buildinfo"
+	"github.com/NSACodeGov/CodeGov/internal/egress"
+	"github.com/NSACodeGov/CodeGov/internal/idmap"
 )
 
 // Profile represents the deployment environment
@@ -35,6 +44,56 @@ type Config struct {
 	// MinIO configuration (placeholder for future phases)
 	MinIO MinIOConfig `json:"minio"`
 
+	// Policy engine configuration
+	Policy PolicyConfig `json:"policy"`
+
+	// Audit configuration
+	Audit AuditConfig `json:"audit"`
+
+	// Per-device request limits
+	DeviceLimits DeviceLimitsConfig `json:"device_limits"`
+
+	// Device ID reservations per layer/class
+	DeviceIDs DeviceIDsConfig `json:"device_ids"`
+
+	// How long deleted devices can be restored before they are purged (Go duration,
+	// e.g. "720h"); empty uses the registry default of 30 days
+	DeviceRetention string `json:"device_retention"`
+
+	// Brute-force protection for authentication failures
+	Lockout LockoutConfig `json:"lockout"`
+
+	// code.gov inventory publishing
+	CodeGov CodeGovConfig `json:"codegov"`
+
+	// Open-source landing page served at /
+	Site SiteConfig `json:"site"`
+
+	// Internal services proxied behind the clearance middleware
+	Proxy ProxyConfig `json:"proxy"`
+
+	// Allowlist for outbound requests the server makes itself
+	Egress EgressConfig `json:"egress"`
+
+	// Signed configuration served to devices by CONFIG token
+	DeviceConfig DeviceConfigConfig `json:"device_config"`
+
+	// Telemetry devices submit under their DATA token
+	DataIngest DataIngestConfig `json:"data_ingest"`
+
+	// Clearance and layer derived from external identity attributes
+	IdentityMapping IdentityMappingConfig `json:"identity_mapping"`
+
+	// Ordered identity provider chain of the clearance middleware per profile, e.g.
+	// {"dsmil": ["mtls", "token"]}; profiles without one use header, mapped, token
+	IdentityProviders map[Profile][]string `json:"identity_providers"`
+
+	// Long-running jobs such as inventory generation, managed over the admin API
+	Jobs JobsConfig `json:"jobs"`
+
+	// Heap, goroutine and audit backlog monitoring
+	Watchdog WatchdogConfig `json:"watchdog"`
+
 	// Service metadata
 	Service ServiceConfig `json:"service"`
 
@@ -44,15 +103,21 @@ type Config struct {
 
 // ServerConfig holds HTTP server settings
 type ServerConfig struct {
-	Host string `json:"host"`
-	Port int    `json:"port"`
+	Host           string `json:"host"`
+	Port           int    `json:"port"`
+	ShutdownReport string `json:"shutdown_report"` // File the final shutdown summary is also written to
+
+	// LayerListeners binds an extra listener per layer, e.g. {"data": ":8081"}. Requests
+	// accepted on one take its layer, and any other claimed or registered layer is rejected.
+	LayerListeners map[string]string `json:"layer_listeners"`
 }
 
 // TLSConfig holds TLS/HTTPS settings
 type TLSConfig struct {
-	Enabled  bool   `json:"enabled"`
-	CertFile string `json:"cert_file"`
-	KeyFile  string `json:"key_file"`
+	Enabled      bool   `json:"enabled"`
+	CertFile     string `json:"cert_file"`
+	KeyFile      string `json:"key_file"`
+	ClientCAFile string `json:"client_ca_file"` // PEM CAs verifying client certificates, which stay optional
 }
 
 // LoggingConfig holds logging settings
@@ -79,6 +144,289 @@ type MinIOConfig struct {
 	UseSSL    bool   `json:"use_ssl"`
 }
 
+// PolicyConfig holds policy engine settings
+type PolicyConfig struct {
+	ReplayLog string              `json:"replay_log"` // Records every decision for later replay when set
+	Bundle    PolicyBundleConfig  `json:"bundle"`
+	Objects   PolicyObjectsConfig `json:"objects"`
+}
+
+// PolicyBundleConfig holds settings for bootstrapping policy and devices from a signed bundle
+type PolicyBundleConfig struct {
+	URL          string `json:"url"`           // http(s):// or minio://bucket/key of a tar(.gz) bundle
+	SignatureURL string `json:"signature_url"` // Detached ed25519 signature (defaults to URL + ".sig")
+	PublicKey    string `json:"public_key"`    // PEM file path or base64 ed25519 key used to verify the bundle
+}
+
+// PolicyObjectsConfig holds settings for loading policy and devices from separate
+// MinIO objects and keeping them in sync
+type PolicyObjectsConfig struct {
+	PolicyKey  string `json:"policy_key"`  // bucket/key of the policy JSON; enables the mode
+	DevicesKey string `json:"devices_key"` // bucket/key of the device list JSON (optional)
+	Refresh    string `json:"refresh"`     // How often to re-fetch (Go duration); empty loads at startup only
+	PublicKey  string `json:"public_key"`  // Verifies <key>.sig signatures like bundle.public_key
+	Insecure   bool   `json:"insecure"`    // Accept unsigned objects when no public key is set; development only
+}
+
+// AuditConfig holds audit logging settings
+type AuditConfig struct {
+	InstanceID   string            `json:"instance_id"`   // Stamped on every event; random per process when empty
+	DefaultLevel string            `json:"default_level"` // none, decision, headers or body
+	RouteLevels  map[string]string `json:"route_levels"`  // Route pattern (trailing "*" for prefixes) to level
+	GeoIP        GeoIPConfig       `json:"geoip"`
+
+	// RedactHeaders are omitted from events at the headers level, in addition to credential,
+	// token ID, identity signature and identity mapping headers, which are always omitted
+	RedactHeaders []string `json:"redact_headers"`
+
+	// Sinks are the writers events are sent to; empty writes raw events to stdout
+	Sinks []AuditSinkConfig `json:"sinks"`
+}
+
+// AuditSinkConfig is an audit writer and the transforms applied to the events it
+// receives, e.g. a flattened, redacted feed for a SIEM next to the raw file
+type AuditSinkConfig struct {
+	Type       string                  `json:"type"` // stdout or file
+	Path       string                  `json:"path"` // File sinks only
+	Transforms []audit.TransformConfig `json:"transforms"`
+}
+
+// NewWriter opens the sink's writer with its transform pipeline
+func (c AuditSinkConfig) NewWriter() (audit.Writer, error) {
+	pipeline, err := audit.NewPipeline(c.Transforms)
+	if err != nil {
+		return nil, err
+	}
+	switch c.Type {
+	case "stdout":
+		w := audit.NewStdoutWriter()
+		w.SetPipeline(pipeline)
+		return w, nil
+	case "file":
+		w, err := audit.NewFileWriter(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		w.SetPipeline(pipeline)
+		return w, nil
+	}
+	return nil, fmt.Errorf("unknown audit sink type: %s", c.Type)
+}
+
+// GeoIPConfig holds settings for GeoIP/ASN enrichment of audit events
+type GeoIPConfig struct {
+	Enabled   bool   `json:"enabled"`
+	CountryDB string `json:"country_db"` // Path to a country MMDB file (e.g. GeoLite2-Country.mmdb)
+	ASNDB     string `json:"asn_db"`     // Path to an ASN MMDB file (e.g. GeoLite2-ASN.mmdb)
+}
+
+// DeviceLimitsConfig holds per-device concurrency limits
+type DeviceLimitsConfig struct {
+	MaxInFlight      int            `json:"max_in_flight"`       // Concurrent requests per device; 0 disables the cap
+	LayerMaxInFlight map[string]int `json:"layer_max_in_flight"` // Per-layer overrides, e.g. {"control": 1}
+}
+
+// DeviceIDsConfig reserves device ID ranges so registrations cannot collide,
+// e.g. sensors 1-999 and gateways 1000-1999
+type DeviceIDsConfig struct {
+	Ranges []DeviceIDRangeConfig `json:"ranges"`
+}
+
+// DeviceIDRangeConfig reserves IDs Min-Max for a layer and/or class; an empty
+// layer or class matches every device
+type DeviceIDRangeConfig struct {
+	Layer string `json:"layer"`
+	Class string `json:"class"`
+	Min   int    `json:"min"`
+	Max   int    `json:"max"`
+}
+
+// LockoutConfig holds brute-force protection settings. Durations use Go syntax
+// (e.g. "15m"); zero values fall back to the middleware defaults.
+type LockoutConfig struct {
+	Enabled     bool   `json:"enabled"`
+	MaxFailures int    `json:"max_failures"` // Failures within the window that trigger a ban
+	Window      string `json:"window"`       // How long failures are remembered
+	BaseDelay   string `json:"base_delay"`   // Wait after the first failure, doubled per further failure
+	MaxDelay    string `json:"max_delay"`
+	BanDuration string `json:"ban_duration"`
+
+	// TrustedProxies are reverse proxy addresses or CIDRs whose X-Forwarded-For
+	// names the client; without them failures are counted per peer address
+	TrustedProxies []string `json:"trusted_proxies"`
+}
+
+// ProxyNetworks parses TrustedProxies, treating a bare address as a single-host network
+func (l LockoutConfig) ProxyNetworks() ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(l.TrustedProxies))
+	for _, proxy := range l.TrustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid lockout trusted proxy: %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lockout trusted proxy: %q", proxy)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// CodeGovConfig holds settings for publishing the code.gov inventory
+type CodeGovConfig struct {
+	// JSONPath is the code.json served at /code.json and /api/inventory, a file or a
+	// minio://bucket/key object; the routes are disabled when empty
+	JSONPath string `json:"json_path"`
+
+	// PrivateJSONPath is the full inventory, private repositories included, served at
+	// /api/inventory/private to callers with PrivateClearance; disabled when empty
+	PrivateJSONPath  string `json:"private_json_path"`
+	PrivateClearance int    `json:"private_clearance"` // Clearance level (2-9) required; default 7
+
+	Internal           bool   `json:"internal"`            // /code.json also requires the policy to allow it, like /api/inventory
+	InventoryClearance int    `json:"inventory_clearance"` // Clearance level (2-9) a built-in rule requires for the inventory; 0 leaves it to the policy
+	Refresh            string `json:"refresh"`             // How often an inventory in MinIO is revalidated; default 1m
+}
+
+// SiteConfig holds settings for serving a static landing page next to /code.json
+type SiteConfig struct {
+	Enabled               bool   `json:"enabled"`
+	Dir                   string `json:"dir"`                     // Directory to serve; empty serves the built-in page
+	CacheMaxAge           string `json:"cache_max_age"`           // Cache lifetime of non-HTML assets (Go duration); empty uses 1h
+	ContentSecurityPolicy string `json:"content_security_policy"` // Empty allows same-origin resources only
+}
+
+// ProxyConfig holds reverse-proxy settings
+type ProxyConfig struct {
+	SigningKey string           `json:"signing_key"` // ed25519 private key (PEM) signing forwarded identity headers
+	Upstreams  []UpstreamConfig `json:"upstreams"`
+}
+
+// EgressConfig restricts server-initiated outbound HTTP, e.g. policy bundle downloads.
+// Proxy upstreams are configured explicitly and are not subject to it.
+type EgressConfig struct {
+	Enabled bool     `json:"enabled"`
+	Allow   []string `json:"allow"` // Host names, "*.domain" wildcards, IP addresses and CIDRs
+	Proxy   string   `json:"proxy"` // HTTP(S) proxy for outbound requests; HTTPS_PROXY is ignored when enabled
+}
+
+// DeviceConfigConfig holds settings for serving configuration blobs to devices
+type DeviceConfigConfig struct {
+	Dir        string `json:"dir"`         // One directory per CONFIG token (e.g. 0x8004/) holding <version>.cfg files; the route is disabled when empty
+	SigningKey string `json:"signing_key"` // ed25519 private key (PEM) signing every blob served
+}
+
+// DataIngestConfig holds storage and quota settings for device telemetry; zero
+// quotas use the ingest defaults
+type DataIngestConfig struct {
+	Backends     []string `json:"backends"`       // "minio" and/or "redis"; the route is disabled when empty
+	Bucket       string   `json:"bucket"`         // MinIO bucket; empty uses "telemetry"
+	Prefix       string   `json:"prefix"`         // MinIO object key prefix
+	StreamPrefix string   `json:"stream_prefix"`  // Redis stream key prefix; empty uses "gogovcode:data:"
+	StreamMaxLen int      `json:"stream_max_len"` // Approximate entries kept per Redis stream; 0 uses 10000
+	MaxPayloadKB int      `json:"max_payload_kb"` // Largest payload accepted; 0 uses 64
+	MaxRequests  int      `json:"max_requests"`   // Submissions per device per window; 0 uses 60
+	Window       string   `json:"window"`         // Quota window (Go duration); empty uses 1m
+}
+
+// JobsConfig holds settings for background jobs
+type JobsConfig struct {
+	Dir       string `json:"dir"`       // Job records and results; the job routes are disabled when empty
+	Workers   int    `json:"workers"`   // Jobs run concurrently; 0 uses 2
+	Retention string `json:"retention"` // How long finished jobs are kept (Go duration); empty uses 168h
+}
+
+// IdentityMappingConfig maps attributes asserted by an external identity system to
+// clearances and layers for requests that do not identify a device; the mapping is
+// disabled without rules. The headers must be set by an authenticating proxy that
+// strips any values sent by clients.
+type IdentityMappingConfig struct {
+	GroupsHeader  string               `json:"groups_header"` // Comma-separated groups, e.g. X-Forwarded-Groups
+	ClaimsHeader  string               `json:"claims_header"` // JWT or base64url JSON claims, e.g. X-Forwarded-Access-Token
+	GroupsClaim   string               `json:"groups_claim"`  // Claim holding groups; empty uses "groups"
+	ClientCert    bool                 `json:"client_cert"`   // Read OU and CN from verified client certificates
+	Authoritative bool                 `json:"authoritative"` // Ignore X-Clearance and X-Layer when no rule matches
+	Rules         []IdentityRuleConfig `json:"rules"`
+}
+
+// IdentityRuleConfig maps a matching attribute to a clearance level and/or layer
+type IdentityRuleConfig struct {
+	Match          string `json:"match"`           // "<kind>:<glob>" with kind group, claim (name=value), ou or cn
+	ClearanceLevel int    `json:"clearance_level"` // 2-9; 0 leaves it to later rules
+	Layer          string `json:"layer"`           // Empty leaves it to later rules
+}
+
+// identityProviderNames are the providers an identity provider chain may list
+var identityProviderNames = map[string]bool{"header": true, "token": true, "mtls": true, "jwt": true, "mapped": true}
+
+// IdentityProviderChain returns the identity providers configured for the active
+// profile, or nil for the middleware's default chain
+func (c *Config) IdentityProviderChain() []string {
+	return c.IdentityProviders[c.Profile]
+}
+
+// NewMapper builds the identity mapper, or returns nil when no rules are configured
+func (c IdentityMappingConfig) NewMapper() (*idmap.Mapper, error) {
+	if len(c.Rules) == 0 {
+		return nil, nil
+	}
+	rules := make([]idmap.Rule, len(c.Rules))
+	for i, r := range c.Rules {
+		rules[i] = idmap.Rule{Match: r.Match, ClearanceLevel: r.ClearanceLevel, Layer: r.Layer}
+	}
+	return idmap.New(idmap.Config{
+		GroupsHeader:  c.GroupsHeader,
+		ClaimsHeader:  c.ClaimsHeader,
+		GroupsClaim:   c.GroupsClaim,
+		ClientCert:    c.ClientCert,
+		Authoritative: c.Authoritative,
+		Rules:         rules,
+	})
+}
+
+// WatchdogConfig holds the runtime watchdog's sampling interval and thresholds;
+// zero values use the watchdog defaults
+type WatchdogConfig struct {
+	Enabled       bool   `json:"enabled"`
+	Interval      string `json:"interval"`        // Time between samples (Go duration); empty uses 30s
+	MaxHeapMB     int    `json:"max_heap_mb"`     // Allocated heap past which readiness degrades; 0 uses 1024
+	MaxGoroutines int    `json:"max_goroutines"`  // 0 uses 10000
+	MaxAuditQueue int    `json:"max_audit_queue"` // Audit events waiting to be written; 0 uses 1000
+}
+
+// UpstreamConfig maps a path prefix to an internal service
+type UpstreamConfig struct {
+	Prefix          string `json:"prefix"` // e.g. "/svc/inventory/"; "/" forwards every path gogovcode does not serve
+	URL             string `json:"url"`
+	StripPrefix     bool   `json:"strip_prefix"`
+	ForwardIdentity bool   `json:"forward_identity"` // Send the verified device identity as signed headers
+	Timeout         string `json:"timeout"`          // Wait for response headers (Go duration); empty uses 30s
+	ConnectTimeout  string `json:"connect_timeout"`  // Wait for the connection (Go duration); empty uses 5s
+
+	// Retries are extra attempts for GET, HEAD and OPTIONS requests that fail to connect or
+	// get a 502, 503 or 504, capped at RetryBudget times the requests of the last 10s (0 uses 0.2)
+	Retries     int     `json:"retries"`
+	RetryBudget float64 `json:"retry_budget"`
+
+	// FailureThreshold consecutive failures (0 uses 5) open the upstream's circuit, which
+	// answers 503 for Cooldown (empty uses 30s) before a probe request is let through
+	FailureThreshold int    `json:"failure_threshold"`
+	Cooldown         string `json:"cooldown"`
+}
+
 // ServiceConfig holds service metadata
 type ServiceConfig struct {
 	Name    string `json:"name"`
@@ -111,7 +459,9 @@ func Load() (*Config, error) {
 	}
 
 	// Override with environment variables
-	loadFromEnv(cfg)
+	if err := loadFromEnv(cfg); err != nil {
+		return nil, fmt.Errorf("failed to load environment: %w", err)
+	}
 
 	// Override with command-line flags
 	if *host != "" {
@@ -163,9 +513,18 @@ func defaults() *Config {
 			Bucket:    "audit",
 			UseSSL:    false,
 		},
+		Lockout: LockoutConfig{
+			Enabled: true,
+		},
+		CodeGov: CodeGovConfig{
+			PrivateClearance: 7,
+		},
+		Watchdog: WatchdogConfig{
+			Enabled: true,
+		},
 		Service: ServiceConfig{
 			Name:    "gogovcode",
-			Version: "1.0.0-phase2",
+			Version: buildinfo.Version,
 		},
 		Profile: ProfileDev,
 	}
@@ -181,16 +540,24 @@ func loadFromFile(path string, cfg *Config) error {
 	return json.Unmarshal(data, cfg)
 }
 
-// loadFromEnv loads configuration from environment variables
-func loadFromEnv(cfg *Config) {
+// loadFromEnv loads configuration from environment variables. Numeric variables must
+// be integers; their ranges are checked by Validate.
+func loadFromEnv(cfg *Config) error {
 	if v := os.Getenv("GOGOVCODE_HOST"); v != "" {
 		cfg.Server.Host = v
 	}
-	if v := os.Getenv("GOGOVCODE_PORT"); v != "" {
-		var port int
-		fmt.Sscanf(v, "%d", &port)
-		if port > 0 {
-			cfg.Server.Port = port
+	if err := envInt("GOGOVCODE_PORT", &cfg.Server.Port); err != nil {
+		return err
+	}
+	if v := os.Getenv("GOGOVCODE_SHUTDOWN_REPORT"); v != "" {
+		cfg.Server.ShutdownReport = v
+	}
+	if v := os.Getenv("GOGOVCODE_LAYER_LISTENERS"); v != "" {
+		cfg.Server.LayerListeners = make(map[string]string)
+		for _, pair := range strings.Split(v, ",") {
+			if layer, addr, ok := strings.Cut(strings.TrimSpace(pair), "="); ok {
+				cfg.Server.LayerListeners[layer] = addr
+			}
 		}
 	}
 	if v := os.Getenv("GOGOVCODE_LOG_LEVEL"); v != "" {
@@ -208,6 +575,27 @@ func loadFromEnv(cfg *Config) {
 	if v := os.Getenv("GOGOVCODE_TLS_KEY"); v != "" {
 		cfg.TLS.KeyFile = v
 	}
+	if v := os.Getenv("GOGOVCODE_TLS_CLIENT_CA"); v != "" {
+		cfg.TLS.ClientCAFile = v
+	}
+	if v := os.Getenv("GOGOVCODE_IDENTITY_GROUPS_HEADER"); v != "" {
+		cfg.IdentityMapping.GroupsHeader = v
+	}
+	if v := os.Getenv("GOGOVCODE_IDENTITY_CLAIMS_HEADER"); v != "" {
+		cfg.IdentityMapping.ClaimsHeader = v
+	}
+	if v := os.Getenv("GOGOVCODE_IDENTITY_PROVIDERS"); v != "" {
+		if cfg.IdentityProviders == nil {
+			cfg.IdentityProviders = make(map[Profile][]string)
+		}
+		var names []string
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		cfg.IdentityProviders[cfg.Profile] = names
+	}
 	if v := os.Getenv("GOGOVCODE_REDIS_ENABLED"); v == "true" || v == "1" {
 		cfg.Redis.Enabled = true
 	}
@@ -229,12 +617,157 @@ func loadFromEnv(cfg *Config) {
 	if v := os.Getenv("GOGOVCODE_MINIO_SECRET_KEY"); v != "" {
 		cfg.MinIO.SecretKey = v
 	}
+	if v := os.Getenv("GOGOVCODE_POLICY_REPLAY_LOG"); v != "" {
+		cfg.Policy.ReplayLog = v
+	}
+	if v := os.Getenv("GOGOVCODE_POLICY_BUNDLE_URL"); v != "" {
+		cfg.Policy.Bundle.URL = v
+	}
+	if v := os.Getenv("GOGOVCODE_POLICY_BUNDLE_SIGNATURE_URL"); v != "" {
+		cfg.Policy.Bundle.SignatureURL = v
+	}
+	if v := os.Getenv("GOGOVCODE_POLICY_BUNDLE_PUBLIC_KEY"); v != "" {
+		cfg.Policy.Bundle.PublicKey = v
+	}
+	if v := os.Getenv("GOGOVCODE_POLICY_OBJECT_KEY"); v != "" {
+		cfg.Policy.Objects.PolicyKey = v
+	}
+	if v := os.Getenv("GOGOVCODE_DEVICES_OBJECT_KEY"); v != "" {
+		cfg.Policy.Objects.DevicesKey = v
+	}
+	if v := os.Getenv("GOGOVCODE_POLICY_OBJECTS_REFRESH"); v != "" {
+		cfg.Policy.Objects.Refresh = v
+	}
+	if v := os.Getenv("GOGOVCODE_POLICY_OBJECTS_PUBLIC_KEY"); v != "" {
+		cfg.Policy.Objects.PublicKey = v
+	}
+	if v := os.Getenv("GOGOVCODE_POLICY_OBJECTS_INSECURE"); v != "" {
+		cfg.Policy.Objects.Insecure = v == "true" || v == "1"
+	}
+	if v := os.Getenv("GOGOVCODE_AUDIT_INSTANCE_ID"); v != "" {
+		cfg.Audit.InstanceID = v
+	}
+	if v := os.Getenv("GOGOVCODE_AUDIT_LEVEL"); v != "" {
+		cfg.Audit.DefaultLevel = strings.ToLower(v)
+	}
+	if v := os.Getenv("GOGOVCODE_AUDIT_REDACT_HEADERS"); v != "" {
+		cfg.Audit.RedactHeaders = strings.Split(v, ",")
+	}
+	if v := os.Getenv("GOGOVCODE_GEOIP_ENABLED"); v == "true" || v == "1" {
+		cfg.Audit.GeoIP.Enabled = true
+	}
+	if v := os.Getenv("GOGOVCODE_GEOIP_COUNTRY_DB"); v != "" {
+		cfg.Audit.GeoIP.CountryDB = v
+	}
+	if v := os.Getenv("GOGOVCODE_GEOIP_ASN_DB"); v != "" {
+		cfg.Audit.GeoIP.ASNDB = v
+	}
+	if err := envInt("GOGOVCODE_DEVICE_MAX_IN_FLIGHT", &cfg.DeviceLimits.MaxInFlight); err != nil {
+		return err
+	}
+	if v := os.Getenv("GOGOVCODE_DEVICE_RETENTION"); v != "" {
+		cfg.DeviceRetention = v
+	}
+	if v := os.Getenv("GOGOVCODE_LOCKOUT_ENABLED"); v != "" {
+		cfg.Lockout.Enabled = v == "true" || v == "1"
+	}
+	if err := envInt("GOGOVCODE_LOCKOUT_MAX_FAILURES", &cfg.Lockout.MaxFailures); err != nil {
+		return err
+	}
+	if v := os.Getenv("GOGOVCODE_LOCKOUT_BAN_DURATION"); v != "" {
+		cfg.Lockout.BanDuration = v
+	}
+	if v := os.Getenv("GOGOVCODE_LOCKOUT_TRUSTED_PROXIES"); v != "" {
+		cfg.Lockout.TrustedProxies = strings.Split(v, ",")
+	}
+	if v := os.Getenv("GOGOVCODE_CODE_JSON_PATH"); v != "" {
+		cfg.CodeGov.JSONPath = v
+	}
+	if v := os.Getenv("GOGOVCODE_PRIVATE_CODE_JSON_PATH"); v != "" {
+		cfg.CodeGov.PrivateJSONPath = v
+	}
+	if err := envInt("GOGOVCODE_PRIVATE_CODE_JSON_CLEARANCE", &cfg.CodeGov.PrivateClearance); err != nil {
+		return err
+	}
+	if v := os.Getenv("GOGOVCODE_CODE_JSON_INTERNAL"); v != "" {
+		cfg.CodeGov.Internal = v == "true" || v == "1"
+	}
+	if err := envInt("GOGOVCODE_CODE_JSON_CLEARANCE", &cfg.CodeGov.InventoryClearance); err != nil {
+		return err
+	}
+	if v := os.Getenv("GOGOVCODE_CODE_JSON_REFRESH"); v != "" {
+		cfg.CodeGov.Refresh = v
+	}
+	if v := os.Getenv("GOGOVCODE_SITE_ENABLED"); v != "" {
+		cfg.Site.Enabled = v == "true" || v == "1"
+	}
+	if v := os.Getenv("GOGOVCODE_SITE_DIR"); v != "" {
+		cfg.Site.Dir = v
+	}
+	if v := os.Getenv("GOGOVCODE_PROXY_SIGNING_KEY"); v != "" {
+		cfg.Proxy.SigningKey = v
+	}
+	if v := os.Getenv("GOGOVCODE_JOBS_DIR"); v != "" {
+		cfg.Jobs.Dir = v
+	}
+	if v := os.Getenv("GOGOVCODE_DEVICE_CONFIG_DIR"); v != "" {
+		cfg.DeviceConfig.Dir = v
+	}
+	if v := os.Getenv("GOGOVCODE_DEVICE_CONFIG_SIGNING_KEY"); v != "" {
+		cfg.DeviceConfig.SigningKey = v
+	}
+	if v := os.Getenv("GOGOVCODE_DATA_BACKENDS"); v != "" {
+		cfg.DataIngest.Backends = strings.Split(v, ",")
+	}
+	if err := envInt("GOGOVCODE_DATA_MAX_PAYLOAD_KB", &cfg.DataIngest.MaxPayloadKB); err != nil {
+		return err
+	}
+	if err := envInt("GOGOVCODE_DATA_MAX_REQUESTS", &cfg.DataIngest.MaxRequests); err != nil {
+		return err
+	}
+	if v := os.Getenv("GOGOVCODE_EGRESS_ENABLED"); v == "true" || v == "1" {
+		cfg.Egress.Enabled = true
+	}
+	if v := os.Getenv("GOGOVCODE_EGRESS_ALLOW"); v != "" {
+		cfg.Egress.Allow = strings.Split(v, ",")
+	}
+	if v := os.Getenv("GOGOVCODE_EGRESS_PROXY"); v != "" {
+		cfg.Egress.Proxy = v
+	}
+	if v := os.Getenv("GOGOVCODE_WATCHDOG_ENABLED"); v != "" {
+		cfg.Watchdog.Enabled = v == "true" || v == "1"
+	}
+	if v := os.Getenv("GOGOVCODE_WATCHDOG_INTERVAL"); v != "" {
+		cfg.Watchdog.Interval = v
+	}
+	if err := envInt("GOGOVCODE_WATCHDOG_MAX_HEAP_MB", &cfg.Watchdog.MaxHeapMB); err != nil {
+		return err
+	}
+	if err := envInt("GOGOVCODE_WATCHDOG_MAX_GOROUTINES", &cfg.Watchdog.MaxGoroutines); err != nil {
+		return err
+	}
 	if v := os.Getenv("GOGOVCODE_SERVICE_NAME"); v != "" {
 		cfg.Service.Name = v
 	}
 	if v := os.Getenv("GOGOVCODE_SERVICE_VERSION"); v != "" {
 		cfg.Service.Version = v
 	}
+	return nil
+}
+
+// envInt sets *dst from an integer environment variable, leaving it unchanged when the
+// variable is unset
+func envInt(name string, dst *int) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("invalid %s: %q is not an integer", name, v)
+	}
+	*dst = n
+	return nil
 }
 
 // applyProfileDefaults applies profile-specific defaults
@@ -280,6 +813,21 @@ func (c *Config) Validate() error {
 	if c.Server.Port < 1 || c.Server.Port > 65535 {
 		return fmt.Errorf("invalid server port: %d", c.Server.Port)
 	}
+	addrs := map[string]string{c.Addr(): "the server"}
+	for layer, addr := range c.Server.LayerListeners {
+		switch layer {
+		case "data", "transport", "control", "application":
+		default:
+			return fmt.Errorf("layer listener for unknown layer %q", layer)
+		}
+		if _, port, err := net.SplitHostPort(addr); err != nil || port == "" {
+			return fmt.Errorf("invalid %s layer listener address %q", layer, addr)
+		}
+		if other, ok := addrs[addr]; ok {
+			return fmt.Errorf("%s layer listener address %s is already used by %s", layer, addr, other)
+		}
+		addrs[addr] = "the " + layer + " layer"
+	}
 
 	if c.TLS.Enabled {
 		if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
@@ -297,5 +845,271 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid log format: %s", c.Logging.Format)
 	}
 
+	if c.Policy.Bundle.URL != "" && c.Policy.Bundle.PublicKey == "" {
+		return fmt.Errorf("policy bundle URL set but no public key specified")
+	}
+	if strings.HasPrefix(c.Policy.Bundle.URL, "minio://") && !c.MinIO.Enabled {
+		return fmt.Errorf("policy bundle stored in MinIO but MinIO is not enabled")
+	}
+	if objects := c.Policy.Objects; objects.PolicyKey != "" || objects.DevicesKey != "" {
+		if objects.PolicyKey == "" {
+			return fmt.Errorf("policy objects: devices_key set without policy_key")
+		}
+		if c.Policy.Bundle.URL != "" {
+			return fmt.Errorf("policy objects and a policy bundle cannot both be configured")
+		}
+		if !c.MinIO.Enabled {
+			return fmt.Errorf("policy objects stored in MinIO but MinIO is not enabled")
+		}
+		if objects.PublicKey == "" && !objects.Insecure {
+			return fmt.Errorf("policy objects set but no public key specified; set insecure to accept unsigned objects")
+		}
+		for _, key := range []string{objects.PolicyKey, objects.DevicesKey} {
+			if bucket, object, ok := strings.Cut(key, "/"); key != "" && (!ok || bucket == "" || object == "") {
+				return fmt.Errorf("invalid policy object key %q: expected bucket/key", key)
+			}
+		}
+		if objects.Refresh != "" {
+			if d, err := time.ParseDuration(objects.Refresh); err != nil || d <= 0 {
+				return fmt.Errorf("invalid policy objects refresh: %q", objects.Refresh)
+			}
+		}
+	}
+
+	if !audit.ValidLevel(audit.Level(c.Audit.DefaultLevel)) {
+		return fmt.Errorf("invalid audit level: %s", c.Audit.DefaultLevel)
+	}
+	for route, level := range c.Audit.RouteLevels {
+		if !audit.ValidLevel(audit.Level(level)) {
+			return fmt.Errorf("invalid audit level for route %s: %s", route, level)
+		}
+	}
+
+	if c.DeviceLimits.MaxInFlight < 0 {
+		return fmt.Errorf("invalid device max in-flight: %d", c.DeviceLimits.MaxInFlight)
+	}
+	validLayers := map[string]bool{"data": true, "transport": true, "control": true, "application": true}
+	for layer, limit := range c.DeviceLimits.LayerMaxInFlight {
+		if !validLayers[layer] {
+			return fmt.Errorf("invalid layer in device limits: %s", layer)
+		}
+		if limit < 0 {
+			return fmt.Errorf("invalid device max in-flight for layer %s: %d", layer, limit)
+		}
+	}
+	if c.DeviceRetention != "" {
+		if d, err := time.ParseDuration(c.DeviceRetention); err != nil || d <= 0 {
+			return fmt.Errorf("invalid device retention: %q", c.DeviceRetention)
+		}
+	}
+	validClasses := map[string]bool{"sensor": true, "actuator": true, "gateway": true, "controller": true}
+	for _, r := range c.DeviceIDs.Ranges {
+		if r.Layer != "" && !validLayers[r.Layer] {
+			return fmt.Errorf("invalid layer in device ID range: %s", r.Layer)
+		}
+		if r.Class != "" && !validClasses[r.Class] {
+			return fmt.Errorf("invalid class in device ID range: %s", r.Class)
+		}
+		if r.Min < 1 || r.Max > 0xFFFF || r.Min > r.Max {
+			return fmt.Errorf("invalid device ID range: %d-%d", r.Min, r.Max)
+		}
+	}
+
+	if c.Lockout.MaxFailures < 0 {
+		return fmt.Errorf("invalid lockout max failures: %d", c.Lockout.MaxFailures)
+	}
+	for name, value := range map[string]string{
+		"window":       c.Lockout.Window,
+		"base_delay":   c.Lockout.BaseDelay,
+		"max_delay":    c.Lockout.MaxDelay,
+		"ban_duration": c.Lockout.BanDuration,
+	} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			return fmt.Errorf("invalid lockout %s: %q", name, value)
+		}
+	}
+	if _, err := c.Lockout.ProxyNetworks(); err != nil {
+		return err
+	}
+
+	if c.CodeGov.PrivateJSONPath != "" {
+		if c.CodeGov.PrivateClearance < 2 || c.CodeGov.PrivateClearance > 9 {
+			return fmt.Errorf("invalid private inventory clearance: %d", c.CodeGov.PrivateClearance)
+		}
+		if c.CodeGov.PrivateJSONPath == c.CodeGov.JSONPath {
+			return fmt.Errorf("private inventory must not be the public code.json")
+		}
+	}
+	for _, path := range []string{c.CodeGov.JSONPath, c.CodeGov.PrivateJSONPath} {
+		rest, ok := strings.CutPrefix(path, "minio://")
+		if !ok {
+			continue
+		}
+		if bucket, object, ok := strings.Cut(rest, "/"); !ok || bucket == "" || object == "" {
+			return fmt.Errorf("invalid inventory object %q: expected minio://bucket/key", path)
+		}
+		if !c.MinIO.Enabled {
+			return fmt.Errorf("inventory stored in MinIO but MinIO is not enabled")
+		}
+	}
+	if c.CodeGov.JSONPath == "" && (c.CodeGov.Internal || c.CodeGov.InventoryClearance != 0) {
+		return fmt.Errorf("inventory access configured but codegov.json_path is not set")
+	}
+	if level := c.CodeGov.InventoryClearance; level != 0 && (level < 2 || level > 9) {
+		return fmt.Errorf("invalid inventory clearance: %d", level)
+	}
+	if c.CodeGov.Refresh != "" {
+		if d, err := time.ParseDuration(c.CodeGov.Refresh); err != nil || d <= 0 {
+			return fmt.Errorf("invalid inventory refresh: %q", c.CodeGov.Refresh)
+		}
+	}
+
+	if c.Site.CacheMaxAge != "" {
+		if d, err := time.ParseDuration(c.Site.CacheMaxAge); err != nil || d < 0 {
+			return fmt.Errorf("invalid site cache max age: %q", c.Site.CacheMaxAge)
+		}
+	}
+	if c.Site.Enabled && c.Site.Dir != "" {
+		if info, err := os.Stat(c.Site.Dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("site directory not found: %s", c.Site.Dir)
+		}
+	}
+
+	prefixes := make(map[string]bool, len(c.Proxy.Upstreams))
+	for _, upstream := range c.Proxy.Upstreams {
+		if !strings.HasPrefix(upstream.Prefix, "/") || !strings.HasSuffix(upstream.Prefix, "/") {
+			return fmt.Errorf("invalid upstream prefix %q: must start and end with /", upstream.Prefix)
+		}
+		if upstream.Prefix == "/" && c.Site.Enabled {
+			return fmt.Errorf("upstream prefix / conflicts with the landing page; disable the site")
+		}
+		if prefixes[upstream.Prefix] {
+			return fmt.Errorf("duplicate upstream prefix %q", upstream.Prefix)
+		}
+		prefixes[upstream.Prefix] = true
+		if u, err := url.Parse(upstream.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid upstream URL for %s: %q", upstream.Prefix, upstream.URL)
+		}
+		for _, setting := range [][2]string{
+			{"timeout", upstream.Timeout},
+			{"connect timeout", upstream.ConnectTimeout},
+			{"cooldown", upstream.Cooldown},
+		} {
+			if setting[1] == "" {
+				continue
+			}
+			if d, err := time.ParseDuration(setting[1]); err != nil || d <= 0 {
+				return fmt.Errorf("invalid upstream %s for %s: %q", setting[0], upstream.Prefix, setting[1])
+			}
+		}
+		if upstream.Retries < 0 || upstream.FailureThreshold < 0 {
+			return fmt.Errorf("upstream %s: retries and failure threshold must not be negative", upstream.Prefix)
+		}
+		if upstream.RetryBudget < 0 || upstream.RetryBudget > 1 {
+			return fmt.Errorf("upstream %s: retry budget must be between 0 and 1", upstream.Prefix)
+		}
+		if upstream.ForwardIdentity && c.Proxy.SigningKey == "" {
+			return fmt.Errorf("upstream %s forwards identity but no proxy signing key is configured", upstream.Prefix)
+		}
+	}
+
+	if c.DeviceConfig.Dir != "" {
+		if c.DeviceConfig.SigningKey == "" {
+			return fmt.Errorf("device config directory set but no signing key specified")
+		}
+		if info, err := os.Stat(c.DeviceConfig.Dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("device config directory not found: %s", c.DeviceConfig.Dir)
+		}
+	}
+
+	for _, backend := range c.DataIngest.Backends {
+		switch backend {
+		case "minio":
+			if !c.MinIO.Enabled {
+				return fmt.Errorf("device data stored in MinIO but MinIO is not enabled")
+			}
+		case "redis":
+			if !c.Redis.Enabled {
+				return fmt.Errorf("device data stored in Redis but Redis is not enabled")
+			}
+		default:
+			return fmt.Errorf("invalid device data backend: %q", backend)
+		}
+	}
+	if c.DataIngest.MaxPayloadKB < 0 || c.DataIngest.MaxRequests < 0 || c.DataIngest.StreamMaxLen < 0 {
+		return fmt.Errorf("device data quotas must not be negative")
+	}
+	if c.DataIngest.Window != "" {
+		if d, err := time.ParseDuration(c.DataIngest.Window); err != nil || d <= 0 {
+			return fmt.Errorf("invalid device data window: %q", c.DataIngest.Window)
+		}
+	}
+
+	if c.Jobs.Workers < 0 {
+		return fmt.Errorf("job workers must not be negative")
+	}
+	if c.Jobs.Retention != "" {
+		if d, err := time.ParseDuration(c.Jobs.Retention); err != nil || d <= 0 {
+			return fmt.Errorf("invalid job retention: %q", c.Jobs.Retention)
+		}
+	}
+
+	if _, err := c.IdentityMapping.NewMapper(); err != nil {
+		return err
+	}
+	if c.IdentityMapping.ClientCert && len(c.IdentityMapping.Rules) > 0 && (!c.TLS.Enabled || c.TLS.ClientCAFile == "") {
+		return fmt.Errorf("identity mapping reads client certificates but TLS with a client CA file is not configured")
+	}
+	for profile, names := range c.IdentityProviders {
+		if len(names) == 0 {
+			return fmt.Errorf("identity providers of profile %s: the chain is empty", profile)
+		}
+		for _, name := range names {
+			switch {
+			case !identityProviderNames[name]:
+				return fmt.Errorf("identity providers of profile %s: unknown provider %q", profile, name)
+			case name != "header" && name != "token" && len(c.IdentityMapping.Rules) == 0:
+				return fmt.Errorf("identity providers of profile %s: %s requires identity mapping rules", profile, name)
+			case name == "mtls" && !c.IdentityMapping.ClientCert:
+				return fmt.Errorf("identity providers of profile %s: mtls requires identity_mapping.client_cert", profile)
+			case name == "jwt" && c.IdentityMapping.ClaimsHeader == "":
+				return fmt.Errorf("identity providers of profile %s: jwt requires identity_mapping.claims_header", profile)
+			}
+		}
+	}
+
+	if c.Egress.Enabled {
+		if _, err := egress.New(c.Egress.Allow, c.Egress.Proxy); err != nil {
+			return err
+		}
+	}
+
+	if c.Watchdog.Interval != "" {
+		if d, err := time.ParseDuration(c.Watchdog.Interval); err != nil || d <= 0 {
+			return fmt.Errorf("invalid watchdog interval: %q", c.Watchdog.Interval)
+		}
+	}
+	if c.Watchdog.MaxHeapMB < 0 || c.Watchdog.MaxGoroutines < 0 || c.Watchdog.MaxAuditQueue < 0 {
+		return fmt.Errorf("watchdog thresholds must not be negative")
+	}
+
+	if c.Audit.GeoIP.Enabled && c.Audit.GeoIP.CountryDB == "" && c.Audit.GeoIP.ASNDB == "" {
+		return fmt.Errorf("GeoIP enrichment enabled but no database specified")
+	}
+	for i, sink := range c.Audit.Sinks {
+		switch {
+		case sink.Type != "stdout" && sink.Type != "file":
+			return fmt.Errorf("audit sink %d: unknown type %q", i, sink.Type)
+		case sink.Type == "file" && sink.Path == "":
+			return fmt.Errorf("audit sink %d: file sinks need a path", i)
+		}
+		if _, err := audit.NewPipeline(sink.Transforms); err != nil {
+			return fmt.Errorf("audit sink %d: %w", i, err)
+		}
+	}
+
 	return nil
 }
@@ -1,8 +1,12 @@
 package config
 
 import (
+	"flag"
 	"os"
+	"strings"
 	"testing"
+
+	"github.com/NSACodeGov/CodeGov/internal/audit"
 )
 
 func TestDefaults(t *testing.T) {
@@ -37,7 +41,9 @@ func TestLoadFromEnv(t *testing.T) {
 	}()
 
 	cfg := defaults()
-	loadFromEnv(cfg)
+	if err := loadFromEnv(cfg); err != nil {
+		t.Fatal(err)
+	}
 
 	if cfg.Server.Port != 9000 {
 		t.Errorf("expected port 9000 from env, got %d", cfg.Server.Port)
@@ -48,6 +54,120 @@ func TestLoadFromEnv(t *testing.T) {
 	}
 }
 
+// loadWithArgs runs Load on a fresh command line, since Load defines and parses its flags
+func loadWithArgs(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	commandLine, osArgs := flag.CommandLine, os.Args
+	t.Cleanup(func() { flag.CommandLine, os.Args = commandLine, osArgs })
+	flag.CommandLine = flag.NewFlagSet("gogovcode", flag.ContinueOnError)
+	os.Args = append([]string{"gogovcode"}, args...)
+	return Load()
+}
+
+func TestLoadPort(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"valid", "9090", 9090, false},
+		{"padded", " 9090 ", 9090, false},
+		// Values fmt.Sscanf used to read a prefix of, or ignore, now fail loading
+		{"trailing garbage", "8080abc", 0, true},
+		{"float", "80.5", 0, true},
+		{"not a number", "http", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GOGOVCODE_PORT", tt.value)
+			cfg, err := loadWithArgs(t)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "GOGOVCODE_PORT") {
+					t.Errorf("expected an error naming GOGOVCODE_PORT, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Server.Port != tt.want {
+				t.Errorf("got port %d, want %d", cfg.Server.Port, tt.want)
+			}
+		})
+	}
+
+	// Zero is no longer ignored in favor of the default; Validate rejects it
+	t.Setenv("GOGOVCODE_PORT", "0")
+	cfg, err := loadWithArgs(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "invalid server port") {
+		t.Errorf("expected port 0 to fail validation, got %v", err)
+	}
+
+	// The --port flag still overrides the environment
+	t.Setenv("GOGOVCODE_PORT", "9090")
+	if cfg, err := loadWithArgs(t, "--port", "7070"); err != nil || cfg.Server.Port != 7070 {
+		t.Errorf("Load(--port 7070) = %v, %v", cfg, err)
+	}
+}
+
+func TestLoadFromEnvIntegers(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		value   string
+		get     func(*Config) int
+		want    int
+		wantErr bool
+	}{
+		{"device max in-flight", "GOGOVCODE_DEVICE_MAX_IN_FLIGHT", "4", func(c *Config) int { return c.DeviceLimits.MaxInFlight }, 4, false},
+		{"zero disables the device cap", "GOGOVCODE_DEVICE_MAX_IN_FLIGHT", "0", func(c *Config) int { return c.DeviceLimits.MaxInFlight }, 0, false},
+		{"lockout max failures", "GOGOVCODE_LOCKOUT_MAX_FAILURES", " 7 ", func(c *Config) int { return c.Lockout.MaxFailures }, 7, false},
+		{"inventory clearance", "GOGOVCODE_CODE_JSON_CLEARANCE", "5", func(c *Config) int { return c.CodeGov.InventoryClearance }, 5, false},
+		{"trailing garbage", "GOGOVCODE_DEVICE_MAX_IN_FLIGHT", "4x", nil, 0, true},
+		{"not a number", "GOGOVCODE_LOCKOUT_MAX_FAILURES", "five", nil, 0, true},
+		{"float", "GOGOVCODE_PORT", "80.5", nil, 0, true},
+		{"overflow", "GOGOVCODE_WATCHDOG_MAX_GOROUTINES", "99999999999999999999", nil, 0, true},
+		{"private clearance", "GOGOVCODE_PRIVATE_CODE_JSON_CLEARANCE", "0x07", nil, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+			cfg := defaults()
+			cfg.DeviceLimits.MaxInFlight = 2
+
+			err := loadFromEnv(cfg)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.env) {
+					t.Errorf("expected an error naming %s, got %v", tt.env, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.get(cfg); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// Negative values parse, and Validate rejects them
+	t.Setenv("GOGOVCODE_LOCKOUT_MAX_FAILURES", "-1")
+	cfg := defaults()
+	if err := loadFromEnv(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "max failures") {
+		t.Errorf("expected Validate to reject a negative max failures, got %v", err)
+	}
+}
+
 func TestValidate(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -92,6 +212,410 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "geoip enabled without database",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Audit:   AuditConfig{GeoIP: GeoIPConfig{Enabled: true}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "device limits with unknown layer",
+			cfg: &Config{
+				Server:       ServerConfig{Port: 8080},
+				Logging:      LoggingConfig{Level: "info", Format: "json"},
+				DeviceLimits: DeviceLimitsConfig{LayerMaxInFlight: map[string]int{"kernel": 1}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "device ID range with unknown class",
+			cfg: &Config{
+				Server:    ServerConfig{Port: 8080},
+				Logging:   LoggingConfig{Level: "info", Format: "json"},
+				DeviceIDs: DeviceIDsConfig{Ranges: []DeviceIDRangeConfig{{Class: "drone", Min: 1, Max: 99}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "device ID range with min above max",
+			cfg: &Config{
+				Server:    ServerConfig{Port: 8080},
+				Logging:   LoggingConfig{Level: "info", Format: "json"},
+				DeviceIDs: DeviceIDsConfig{Ranges: []DeviceIDRangeConfig{{Class: "sensor", Min: 999, Max: 1}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative device retention",
+			cfg: &Config{
+				Server:          ServerConfig{Port: 8080},
+				Logging:         LoggingConfig{Level: "info", Format: "json"},
+				DeviceRetention: "-1h",
+			},
+			wantErr: true,
+		},
+		{
+			name: "lockout with invalid ban duration",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Lockout: LockoutConfig{Enabled: true, BanDuration: "forever"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "lockout with trusted proxies",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Lockout: LockoutConfig{Enabled: true, TrustedProxies: []string{"10.0.0.0/8", "::1"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "lockout with invalid trusted proxy",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Lockout: LockoutConfig{Enabled: true, TrustedProxies: []string{"proxy.internal"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "site with invalid cache max age",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Site:    SiteConfig{Enabled: true, CacheMaxAge: "a week"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "site with missing directory",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Site:    SiteConfig{Enabled: true, Dir: "/nonexistent/site"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "upstream prefix without trailing slash",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Proxy:   ProxyConfig{Upstreams: []UpstreamConfig{{Prefix: "/svc/inventory", URL: "http://inventory.internal:8080"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "catch-all upstream with landing page",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Site:    SiteConfig{Enabled: true},
+				Proxy:   ProxyConfig{Upstreams: []UpstreamConfig{{Prefix: "/", URL: "http://legacy.internal:8080"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "upstream with invalid timeout",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Proxy:   ProxyConfig{Upstreams: []UpstreamConfig{{Prefix: "/svc/inventory/", URL: "http://inventory.internal:8080", Timeout: "soon"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "upstream retry budget above one",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Proxy:   ProxyConfig{Upstreams: []UpstreamConfig{{Prefix: "/svc/inventory/", URL: "http://inventory.internal:8080", Retries: 2, RetryBudget: 1.5}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "egress allowlist with invalid network",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Egress:  EgressConfig{Enabled: true, Allow: []string{"10.0.0.0/40"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "upstream forwarding identity without signing key",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Proxy:   ProxyConfig{Upstreams: []UpstreamConfig{{Prefix: "/svc/inventory/", URL: "http://inventory.internal:8080", ForwardIdentity: true}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid watchdog interval",
+			cfg: &Config{
+				Server:   ServerConfig{Port: 8080},
+				Logging:  LoggingConfig{Level: "info", Format: "json"},
+				Watchdog: WatchdogConfig{Enabled: true, Interval: "often"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "device config without signing key",
+			cfg: &Config{
+				Server:       ServerConfig{Port: 8080},
+				Logging:      LoggingConfig{Level: "info", Format: "json"},
+				DeviceConfig: DeviceConfigConfig{Dir: "."},
+			},
+			wantErr: true,
+		},
+		{
+			name: "device data in redis without redis enabled",
+			cfg: &Config{
+				Server:     ServerConfig{Port: 8080},
+				Logging:    LoggingConfig{Level: "info", Format: "json"},
+				DataIngest: DataIngestConfig{Backends: []string{"redis"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "policy bundle without public key",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Policy:  PolicyConfig{Bundle: PolicyBundleConfig{URL: "https://example.gov/policy.tar"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "policy bundle in minio without minio enabled",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Policy:  PolicyConfig{Bundle: PolicyBundleConfig{URL: "minio://policy/bundle.tar", PublicKey: "key.pem"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "policy objects without minio enabled",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Policy:  PolicyConfig{Objects: PolicyObjectsConfig{PolicyKey: "policy/policy.json"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "policy objects without public key",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Policy:  PolicyConfig{Objects: PolicyObjectsConfig{PolicyKey: "policy/policy.json"}},
+				MinIO:   MinIOConfig{Enabled: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "insecure policy objects",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Policy:  PolicyConfig{Objects: PolicyObjectsConfig{PolicyKey: "policy/policy.json", Insecure: true}},
+				MinIO:   MinIOConfig{Enabled: true},
+			},
+			wantErr: false,
+		},
+		{
+			name: "policy objects with invalid key",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Policy:  PolicyConfig{Objects: PolicyObjectsConfig{PolicyKey: "policy/policy.json", DevicesKey: "devices.json"}},
+				MinIO:   MinIOConfig{Enabled: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid job retention",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Jobs:    JobsConfig{Dir: "/var/lib/gogovcode/jobs", Retention: "a week"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "identity mapping rule with clearance level out of range",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				IdentityMapping: IdentityMappingConfig{
+					GroupsHeader: "X-Forwarded-Groups",
+					Rules:        []IdentityRuleConfig{{Match: "group:dsmil-admins", ClearanceLevel: 12}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "identity mapping from client certificates without client CA",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				IdentityMapping: IdentityMappingConfig{
+					ClientCert: true,
+					Rules:      []IdentityRuleConfig{{Match: "ou:Transport*", Layer: "transport"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "jwt identity provider without claims header",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				IdentityMapping: IdentityMappingConfig{
+					GroupsHeader: "X-Forwarded-Groups",
+					Rules:        []IdentityRuleConfig{{Match: "group:dsmil-admins", ClearanceLevel: 9}},
+				},
+				IdentityProviders: map[Profile][]string{ProfileProd: {"jwt", "token"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "layer listener on the server address",
+			cfg: &Config{
+				Server:  ServerConfig{Host: "0.0.0.0", Port: 8080, LayerListeners: map[string]string{"data": "0.0.0.0:8080"}},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "layer listener for unknown layer",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080, LayerListeners: map[string]string{"kernel": ":8081"}},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown identity provider",
+			cfg: &Config{
+				Server:            ServerConfig{Port: 8080},
+				Logging:           LoggingConfig{Level: "info", Format: "json"},
+				IdentityProviders: map[Profile][]string{ProfileDev: {"header", "kerberos"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "private inventory with invalid clearance",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				CodeGov: CodeGovConfig{PrivateJSONPath: "private.json", PrivateClearance: 10},
+			},
+			wantErr: true,
+		},
+		{
+			name: "private inventory at the public path",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				CodeGov: CodeGovConfig{JSONPath: "code.json", PrivateJSONPath: "code.json", PrivateClearance: 7},
+			},
+			wantErr: true,
+		},
+		{
+			name: "internal inventory in MinIO",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				MinIO:   MinIOConfig{Enabled: true, Endpoint: "localhost:9000"},
+				CodeGov: CodeGovConfig{JSONPath: "minio://inventory/code.json", Internal: true, InventoryClearance: 5, Refresh: "30s"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "inventory in MinIO without MinIO",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				CodeGov: CodeGovConfig{JSONPath: "minio://inventory/code.json"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "inventory object without key",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				MinIO:   MinIOConfig{Enabled: true, Endpoint: "localhost:9000"},
+				CodeGov: CodeGovConfig{JSONPath: "minio://inventory"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "inventory clearance without inventory",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				CodeGov: CodeGovConfig{InventoryClearance: 5},
+			},
+			wantErr: true,
+		},
+		{
+			name: "inventory with invalid clearance",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				CodeGov: CodeGovConfig{JSONPath: "code.json", InventoryClearance: 1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid default audit level",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Audit:   AuditConfig{DefaultLevel: "verbose"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid route audit level",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Audit:   AuditConfig{DefaultLevel: "none", RouteLevels: map[string]string{"/api/*": "full"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "audit file sink without path",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Audit:   AuditConfig{Sinks: []AuditSinkConfig{{Type: "file"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "audit sink with invalid transform",
+			cfg: &Config{
+				Server:  ServerConfig{Port: 8080},
+				Logging: LoggingConfig{Level: "info", Format: "json"},
+				Audit: AuditConfig{Sinks: []AuditSinkConfig{{
+					Type:       "stdout",
+					Transforms: []audit.TransformConfig{{Type: "flatten"}, {Type: "truncate"}},
+				}}},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
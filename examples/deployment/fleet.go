@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/NSACodeGov/CodeGov/internal/ingest"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// Simulated fleet device IDs, referenced by the flows
+const (
+	sensorLobby      uint16 = 10
+	sensorRoof       uint16 = 11
+	sensorQuarantine uint16 = 12
+	gatewayNorth     uint16 = 20
+	controllerHVAC   uint16 = 30
+	opsConsole       uint16 = 40
+	unregistered     uint16 = 99
+)
+
+// fleet is a small building deployment: sensors on the data layer report through a
+// gateway, a controller acts on their readings and an operations console administers
+// the devices. One sensor has been quarantined after failing attestation.
+func fleet() []*models.Device {
+	return []*models.Device{
+		{
+			ID:        sensorLobby,
+			Name:      "sensor-bldg42-lobby",
+			Layer:     models.LayerData,
+			Class:     models.DeviceClassSensor,
+			Clearance: models.ClearanceLevel3,
+			Labels:    map[string]string{"site": "bldg-42"},
+		},
+		{
+			ID:        sensorRoof,
+			Name:      "sensor-bldg42-roof",
+			Layer:     models.LayerData,
+			Class:     models.DeviceClassSensor,
+			Clearance: models.ClearanceLevel3,
+			Labels:    map[string]string{"site": "bldg-42"},
+		},
+		{
+			ID:        sensorQuarantine,
+			Name:      "sensor-bldg7-annex",
+			Layer:     models.LayerData,
+			Class:     models.DeviceClassSensor,
+			Clearance: models.ClearanceLevel3,
+			Labels:    map[string]string{"site": "bldg-7", "state": "quarantined"},
+		},
+		{
+			ID:        gatewayNorth,
+			Name:      "gateway-bldg42-north",
+			Layer:     models.LayerTransport,
+			Class:     models.DeviceClassGateway,
+			Clearance: models.ClearanceLevel5,
+			Labels:    map[string]string{"site": "bldg-42"},
+		},
+		{
+			ID:        controllerHVAC,
+			Name:      "controller-bldg42-hvac",
+			Layer:     models.LayerControl,
+			Class:     models.DeviceClassController,
+			Clearance: models.ClearanceLevel7,
+			Labels:    map[string]string{"site": "bldg-42"},
+		},
+		{
+			ID:        opsConsole,
+			Name:      "ops-console-01",
+			Layer:     models.LayerApplication,
+			Class:     models.DeviceClassController,
+			Clearance: models.ClearanceLevel9,
+			Labels:    map[string]string{"role": "ops"},
+		},
+	}
+}
+
+// memoryBackend keeps telemetry in memory so the harness needs no Redis or MinIO.
+// It implements ingest.Reader, so consumers can query what the sensors submit.
+type memoryBackend struct {
+	mu      sync.Mutex
+	records []*ingest.Record
+}
+
+func (b *memoryBackend) Name() string { return "memory" }
+
+func (b *memoryBackend) Store(ctx context.Context, record *ingest.Record) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.records = append(b.records, record)
+	return nil
+}
+
+func (b *memoryBackend) Read(ctx context.Context, q ingest.Query) ([]*ingest.Record, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var records []*ingest.Record
+	for _, record := range b.records {
+		if record.Token != q.Token {
+			continue
+		}
+		if (!q.Since.IsZero() && record.ReceivedAt.Before(q.Since)) || (!q.Until.IsZero() && record.ReceivedAt.After(q.Until)) {
+			continue
+		}
+		records = append(records, record)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].ReceivedAt.After(records[j].ReceivedAt)
+	})
+	if len(records) > q.Limit {
+		records = records[:q.Limit]
+	}
+	return records, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// flow is one request a fleet member makes and the outcome the policy should produce
+type flow struct {
+	name      string
+	device    uint16 // Sent as X-Device-ID; 0 for an anonymous request
+	dataToken bool   // Authenticate with the device's DATA token in X-Token-ID instead
+	method    string
+	path      string
+	body      string
+	status    int            // Expected response status
+	audit     audit.Decision // Expected among the request's audit events; "" for unaudited routes
+}
+
+// flows walks the deployment through a day of traffic: telemetry moving up the layers,
+// control commands, administration and the requests the policy must refuse
+var flows = []flow{
+	{name: "anonymous health check", method: "GET", path: "/healthz", status: 200},
+	{name: "anonymous request for a protected route is denied", method: "GET", path: "/api/restricted", status: 403, audit: audit.DecisionDeny},
+	{name: "unregistered device is rejected", device: unregistered, method: "GET", path: "/api/device/status", status: 401, audit: audit.DecisionDeny},
+
+	{name: "lobby sensor reports its status", device: sensorLobby, method: "POST", path: "/api/device/status",
+		body: `{"status": "operational", "uptime_seconds": 86400, "firmware": "2.4.1", "metrics": {"temp_c": 21.5}}`, status: 202, audit: audit.DecisionAllow},
+	{name: "lobby sensor submits telemetry under its DATA token", device: sensorLobby, dataToken: true, method: "POST", path: "/api/device/data",
+		body: `{"temp_c": 21.5, "humidity": 0.41}`, status: 202, audit: audit.DecisionAllow},
+	{name: "roof sensor submits telemetry under its DATA token", device: sensorRoof, dataToken: true, method: "POST", path: "/api/device/data",
+		body: `{"temp_c": 8.0, "wind_mps": 4.2}`, status: 202, audit: audit.DecisionAllow},
+	{name: "quarantined sensor is cut off", device: sensorQuarantine, method: "POST", path: "/api/device/status",
+		body: `{"status": "operational", "uptime_seconds": 60}`, status: 403, audit: audit.DecisionDeny},
+	{name: "sensor may not submit telemetry without its DATA token", device: sensorRoof, method: "POST", path: "/api/device/data",
+		body: `{"temp_c": 8.1}`, status: 403, audit: audit.DecisionAllow},
+
+	{name: "gateway reads the lobby sensor's telemetry", device: gatewayNorth, method: "GET", path: "/api/data/records?device=10", status: 200, audit: audit.DecisionAllow},
+	{name: "sensor may not read telemetry", device: sensorLobby, method: "GET", path: "/api/data/records?device=11", status: 403, audit: audit.DecisionDeny},
+	{name: "gateway may not read control-layer telemetry", device: gatewayNorth, method: "GET", path: "/api/data/records?device=30", status: 403, audit: audit.DecisionDeny},
+
+	{name: "gateway lacks the clearance for control commands", device: gatewayNorth, method: "POST", path: "/api/high-security",
+		body: `{"setpoint_c": 20}`, status: 403, audit: audit.DecisionDeny},
+	{name: "controller issues a control command", device: controllerHVAC, method: "POST", path: "/api/high-security",
+		body: `{"setpoint_c": 20}`, status: 200, audit: audit.DecisionAllow},
+	{name: "controller may not administer devices", device: controllerHVAC, method: "GET", path: "/api/admin/devices", status: 403, audit: audit.DecisionDeny},
+
+	{name: "ops console lists the fleet", device: opsConsole, method: "GET", path: "/api/admin/devices", status: 200, audit: audit.DecisionAllow},
+	{name: "ops console decommissions the roof sensor", device: opsConsole, method: "DELETE", path: "/api/admin/devices/11", status: 200, audit: audit.DecisionAllow},
+	{name: "decommissioned sensor is rejected", device: sensorRoof, method: "GET", path: "/api/device/status", status: 401, audit: audit.DecisionDeny},
+}
+
+// requestID names a flow's request, so its audit events can be found
+func requestID(i int) string {
+	return fmt.Sprintf("flow-%02d", i+1)
+}
+
+// run sends a flow's request and returns the response status
+func (f flow) run(client *http.Client, baseURL, id string, devices map[uint16]*models.Device) (int, error) {
+	var body io.Reader
+	if f.body != "" {
+		body = strings.NewReader(f.body)
+	}
+	req, err := http.NewRequest(f.method, baseURL+f.path, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("X-Request-ID", id)
+	if f.body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch {
+	case f.dataToken:
+		req.Header.Set("X-Token-ID", strconv.Itoa(int(devices[f.device].GetDataToken())))
+	case f.device != 0:
+		req.Header.Set("X-Device-ID", strconv.Itoa(int(f.device)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// readAuditDecisions groups the decisions in an audit file by request ID
+func readAuditDecisions(path string) (map[string][]audit.Decision, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	decisions := make(map[string][]audit.Decision)
+	events := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var event audit.AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, 0, fmt.Errorf("malformed audit event: %w", err)
+		}
+		events++
+		if event.RequestID != "" {
+			decisions[event.RequestID] = append(decisions[event.RequestID], event.Decision)
+		}
+	}
+	return decisions, events, scanner.Err()
+}
+
+// hasDecision reports whether want is among a request's audit decisions
+func hasDecision(decisions []audit.Decision, want audit.Decision) bool {
+	for _, d := range decisions {
+		if d == want {
+			return true
+		}
+	}
+	return false
+}
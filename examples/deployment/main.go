@@ -0,0 +1,170 @@
+// Command deployment boots the GoGovCode server in-process with a realistic policy, a
+// simulated device fleet and an audit file sink, then drives allowed and denied flows
+// through it. Each flow's response status and audit decision are checked, so the
+// harness exits non-zero when a policy or middleware change alters an outcome.
+//
+//	go run ./examples/deployment
+//	go run ./examples/deployment -audit audit.jsonl -serve
+package main
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/api/middleware"
+	"github.com/NSACodeGov/CodeGov/api/routes"
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/health"
+	"github.com/NSACodeGov/CodeGov/internal/ingest"
+	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/internal/policy"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// policyJSON is the deployment's policy, in the format served by /api/admin/policy
+// and shipped in signed policy bundles
+//
+//go:embed policy.json
+var policyJSON []byte
+
+func main() {
+	auditPath := flag.String("audit", "", "Audit log file (default: a new file in the temp directory)")
+	addr := flag.String("addr", "127.0.0.1:0", "Address to listen on")
+	logLevel := flag.String("log-level", "error", "Server log level")
+	serve := flag.Bool("serve", false, "Keep serving after the flows until interrupted")
+	flag.Parse()
+
+	if err := run(*auditPath, *addr, *logLevel, *serve); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(auditPath, addr, logLevel string, serve bool) error {
+	if auditPath == "" {
+		dir, err := os.MkdirTemp("", "gogovcode-deployment-")
+		if err != nil {
+			return err
+		}
+		auditPath = filepath.Join(dir, "audit.jsonl")
+	}
+
+	logger := logging.New("gogovcode-deployment", "example", logLevel, "json")
+
+	// Every decision and registry change goes to the audit file
+	auditLogger := audit.NewLogger()
+	fileWriter, err := audit.NewFileWriter(auditPath)
+	if err != nil {
+		return err
+	}
+	auditLogger.AddWriter(fileWriter)
+	defer auditLogger.Close()
+
+	registry := models.NewDeviceRegistry()
+	registry.SetObserver(func(change models.RegistryChange) {
+		auditLogger.Log(audit.NewChangeEvent("system", "device."+change.Action,
+			fmt.Sprintf("device/%d", change.DeviceID), change.Before, change.After))
+	})
+	devices := make(map[uint16]*models.Device)
+	for _, device := range fleet() {
+		if err := registry.Register(device); err != nil {
+			return fmt.Errorf("failed to register device %d: %w", device.ID, err)
+		}
+		devices[device.ID] = device
+	}
+
+	engine := policy.NewEngine(registry)
+	if err := engine.LoadFromJSON(policyJSON); err != nil {
+		return fmt.Errorf("failed to load policy: %w", err)
+	}
+
+	healthChecker := health.New("gogovcode-deployment", "example")
+	healthChecker.RegisterCheck("policy-engine", func(ctx context.Context) error {
+		if len(engine.GetPolicy().Rules) == 0 {
+			return errors.New("no policy rules loaded")
+		}
+		return nil
+	}, true)
+
+	handler := routes.Setup(&routes.Config{
+		Logger:        logger,
+		HealthChecker: healthChecker,
+		ClearanceConfig: &middleware.ClearanceConfig{
+			PolicyEngine:   engine,
+			AuditLogger:    auditLogger,
+			Logger:         logger,
+			DeviceRegistry: registry,
+			Enabled:        true,
+		},
+		DataIngester: ingest.New(ingest.Quota{}, &memoryBackend{}),
+		Subsystems: map[string]bool{
+			"clearance":   true,
+			"data_ingest": true,
+		},
+	})
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	baseURL := "http://" + listener.Addr().String()
+	fmt.Printf("server listening on %s, %d devices, %d policy rules\n", baseURL, len(devices), len(engine.GetPolicy().Rules))
+	fmt.Printf("audit log: %s\n\n", auditPath)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	statuses := make([]int, len(flows))
+	for i, f := range flows {
+		status, err := f.run(client, baseURL, requestID(i), devices)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		statuses[i] = status
+	}
+
+	decisions, events, err := readAuditDecisions(auditPath)
+	if err != nil {
+		return err
+	}
+
+	failures := 0
+	for i, f := range flows {
+		id := requestID(i)
+		result := "ok"
+		switch {
+		case statuses[i] != f.status:
+			result = fmt.Sprintf("FAIL: got status %d", statuses[i])
+		case f.audit != "" && !hasDecision(decisions[id], f.audit):
+			result = fmt.Sprintf("FAIL: audit decisions %v", decisions[id])
+		}
+		if result != "ok" {
+			failures++
+		}
+		fmt.Printf("%s  %-6s %-30s %d  %-5s  %-55s %s\n", id, f.method, f.path, f.status, f.audit, f.name, result)
+	}
+	fmt.Printf("\n%d flows, %d failed, %d audit events\n", len(flows), failures, events)
+
+	if serve {
+		fmt.Println("serving until interrupted; try curl -H 'X-Device-ID: 40' " + baseURL + "/api/admin/devices")
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		<-ctx.Done()
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d of %d flows did not match the expected outcome", failures, len(flows))
+	}
+	return nil
+}
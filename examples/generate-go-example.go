@@ -10,8 +10,8 @@ import (
 func main() {
 	// Example 1: Set GitHub OAuth token for higher API rate limits
 	// You can also set the OAUTH_TOKEN environment variable instead
-	token := "your_40_character_github_token_here"
-	if token != "your_40_character_github_token_here" {
+	token := "ghp_your_github_token_here"
+	if token != "ghp_your_github_token_here" {
 		if err := codegov.SetOAuthToken(token); err != nil {
 			log.Fatalf("Error setting OAuth token: %v\n", err)
 		}
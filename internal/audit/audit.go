@@ -1,14 +1,21 @@
 package audit
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/NSACodeGov/CodeGov/internal/geoip"
 	"github.com/NSACodeGov/CodeGov/pkg/models"
 )
 
@@ -24,6 +31,8 @@ const (
 type AuditEvent struct {
 	EventID        string           `json:"event_id"`
 	Timestamp      time.Time        `json:"timestamp"`
+	InstanceID     string           `json:"instance_id,omitempty"`
+	Sequence       uint64           `json:"sequence,omitempty"` // Monotonic per instance, starting at 1
 	Actor          string           `json:"actor"`
 	Clearance      models.Clearance `json:"clearance"`
 	DeviceID       uint16           `json:"device_id"`
@@ -36,35 +45,138 @@ type AuditEvent struct {
 	RequestID      string           `json:"request_id,omitempty"`
 	SourceIP       string           `json:"source_ip,omitempty"`
 	StatusCode     int              `json:"status_code,omitempty"`
+	Geo            *geoip.Info      `json:"geo,omitempty"`
+	BeforeDigest   string           `json:"before_digest,omitempty"`
+	AfterDigest    string           `json:"after_digest,omitempty"`
 	AdditionalData map[string]interface{} `json:"additional_data,omitempty"`
 }
 
+// ErrClosed is returned by Log once the logger is closed
+var ErrClosed = errors.New("audit logger closed")
+
 // Writer defines the interface for audit event writers
 type Writer interface {
 	Write(event *AuditEvent) error
 	Close() error
 }
 
+// Enricher annotates audit events before they are written
+type Enricher interface {
+	Enrich(event *AuditEvent)
+}
+
 // Logger is the main audit logger
 type Logger struct {
-	mu      sync.RWMutex
-	writers []Writer
-	enabled bool
+	mu         sync.RWMutex
+	writers    []Writer
+	counts     []*writerCounts // Per-writer totals, parallel to writers
+	enrichers  []Enricher
+	enabled    bool
+	instanceID string
+
+	// Sequence state, guarded separately so concurrent Log calls stay ordered
+	seqMu    sync.Mutex
+	sequence uint64
+	lastTime time.Time
+
+	pending atomic.Int64 // Log calls waiting for or writing to the writers
+	closing atomic.Bool  // Set when Close starts waiting for pending events
+	closed  bool         // Guarded by mu
+	flushed atomic.Int64 // Events written while Close waited for them
+	dropped atomic.Int64 // Events logged after Close
+}
+
+// writerCounts tallies a writer's results
+type writerCounts struct {
+	name    string
+	written atomic.Int64
+	failed  atomic.Int64
+}
+
+// WriterStats is the number of events a writer accepted and rejected
+type WriterStats struct {
+	Writer  string `json:"writer"` // e.g. stdout or file:/var/log/gogovcode/audit.log
+	Written int64  `json:"written"`
+	Failed  int64  `json:"failed"`
+}
+
+// Stats summarizes what the logger has written
+type Stats struct {
+	Writers []WriterStats `json:"writers"`
+	Pending int64         `json:"pending"`
+	Flushed int64         `json:"flushed"` // Events still being written when Close was called
+	Dropped int64         `json:"dropped"` // Events logged after Close and never written
 }
 
 // NewLogger creates a new audit logger
 func NewLogger() *Logger {
 	return &Logger{
-		writers: make([]Writer, 0),
-		enabled: true,
+		writers:    make([]Writer, 0),
+		enabled:    true,
+		instanceID: generateInstanceID(),
 	}
 }
 
+// SetInstanceID overrides the generated instance ID stamped on every event
+func (l *Logger) SetInstanceID(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.instanceID = id
+}
+
+// InstanceID returns the instance ID stamped on every event
+func (l *Logger) InstanceID() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.instanceID
+}
+
+// stamp assigns the next sequence number and a timestamp that never moves
+// backwards, so events stay totally ordered per instance despite clock steps
+func (l *Logger) stamp(event *AuditEvent) {
+	l.seqMu.Lock()
+	defer l.seqMu.Unlock()
+
+	l.sequence++
+	event.Sequence = l.sequence
+	event.InstanceID = l.instanceID
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	if event.Timestamp.Before(l.lastTime) {
+		event.Timestamp = l.lastTime
+	}
+	l.lastTime = event.Timestamp
+}
+
 // AddWriter adds a writer to the audit logger
 func (l *Logger) AddWriter(w Writer) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	l.writers = append(l.writers, w)
+	l.counts = append(l.counts, &writerCounts{name: writerName(w)})
+}
+
+// writerName names a writer in Stats
+func writerName(w Writer) string {
+	switch w := w.(type) {
+	case *StdoutWriter:
+		return "stdout"
+	case *FileWriter:
+		return "file:" + w.path
+	case *MinIOWriter:
+		return "minio:" + w.bucket
+	default:
+		return fmt.Sprintf("%T", w)
+	}
+}
+
+// AddEnricher adds an enrichment stage run before events are written
+func (l *Logger) AddEnricher(e Enricher) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enrichers = append(l.enrichers, e)
 }
 
 // SetEnabled enables or disables audit logging
@@ -74,11 +186,41 @@ func (l *Logger) SetEnabled(enabled bool) {
 	l.enabled = enabled
 }
 
+// Pending returns the number of events being logged that have not reached every
+// writer yet; a growing backlog means a writer cannot keep up
+func (l *Logger) Pending() int64 {
+	return l.pending.Load()
+}
+
+// Stats returns per-writer totals and, after Close, the events flushed and dropped
+func (l *Logger) Stats() Stats {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	stats := Stats{
+		Writers: make([]WriterStats, len(l.counts)),
+		Pending: l.pending.Load(),
+		Flushed: l.flushed.Load(),
+		Dropped: l.dropped.Load(),
+	}
+	for i, c := range l.counts {
+		stats.Writers[i] = WriterStats{Writer: c.name, Written: c.written.Load(), Failed: c.failed.Load()}
+	}
+	return stats
+}
+
 // Log writes an audit event to all registered writers
 func (l *Logger) Log(event *AuditEvent) error {
+	l.pending.Add(1)
+	defer l.pending.Add(-1)
+
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 
+	if l.closed {
+		l.dropped.Add(1)
+		return ErrClosed
+	}
 	if !l.enabled {
 		return nil
 	}
@@ -87,26 +229,42 @@ func (l *Logger) Log(event *AuditEvent) error {
 	if event.EventID == "" {
 		event.EventID = generateEventID()
 	}
-	if event.Timestamp.IsZero() {
-		event.Timestamp = time.Now().UTC()
+	l.stamp(event)
+
+	// Annotate before writing
+	for _, enricher := range l.enrichers {
+		enricher.Enrich(event)
 	}
 
 	// Write to all writers
 	var lastErr error
-	for _, writer := range l.writers {
+	for i, writer := range l.writers {
 		if err := writer.Write(event); err != nil {
+			l.counts[i].failed.Add(1)
 			lastErr = err
+			continue
 		}
+		l.counts[i].written.Add(1)
+	}
+	if l.closing.Load() {
+		l.flushed.Add(1)
 	}
 
 	return lastErr
 }
 
-// Close closes all writers
+// Close waits for events being logged to be written, then closes all writers.
+// Events logged afterwards are dropped.
 func (l *Logger) Close() error {
+	l.closing.Store(true)
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+
 	var lastErr error
 	for _, writer := range l.writers {
 		if err := writer.Close(); err != nil {
@@ -119,7 +277,8 @@ func (l *Logger) Close() error {
 
 // StdoutWriter writes audit events to stdout
 type StdoutWriter struct {
-	mu sync.Mutex
+	mu       sync.Mutex
+	pipeline Pipeline
 }
 
 // NewStdoutWriter creates a new stdout writer
@@ -127,15 +286,25 @@ func NewStdoutWriter() *StdoutWriter {
 	return &StdoutWriter{}
 }
 
+// SetPipeline sets the transforms applied to events before they are written
+func (w *StdoutWriter) SetPipeline(p Pipeline) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pipeline = p
+}
+
 // Write writes an event to stdout
 func (w *StdoutWriter) Write(event *AuditEvent) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	data, err := json.Marshal(event)
+	data, err := w.pipeline.Encode(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal audit event: %w", err)
 	}
+	if data == nil {
+		return nil
+	}
 
 	fmt.Println(string(data))
 	return nil
@@ -148,8 +317,10 @@ func (w *StdoutWriter) Close() error {
 
 // FileWriter writes audit events to a file
 type FileWriter struct {
-	mu   sync.Mutex
-	file *os.File
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	pipeline Pipeline
 }
 
 // NewFileWriter creates a new file writer
@@ -160,19 +331,30 @@ func NewFileWriter(path string) (*FileWriter, error) {
 	}
 
 	return &FileWriter{
+		path: path,
 		file: file,
 	}, nil
 }
 
+// SetPipeline sets the transforms applied to events before they are written
+func (w *FileWriter) SetPipeline(p Pipeline) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pipeline = p
+}
+
 // Write writes an event to the file
 func (w *FileWriter) Write(event *AuditEvent) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	data, err := json.Marshal(event)
+	data, err := w.pipeline.Encode(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal audit event: %w", err)
 	}
+	if data == nil {
+		return nil
+	}
 
 	if _, err := w.file.Write(append(data, '\n')); err != nil {
 		return fmt.Errorf("failed to write audit event: %w", err)
@@ -193,6 +375,36 @@ func (w *FileWriter) Close() error {
 	return nil
 }
 
+// ScanFile reads the events a FileWriter without transforms wrote to path, calling fn
+// for each in order. Lines that do not decode as events, e.g. those a transform
+// rewrote, are skipped and counted.
+func ScanFile(path string, fn func(*AuditEvent)) (skipped int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var event AuditEvent
+			if json.Unmarshal(line, &event) != nil || event.EventID == "" || event.Timestamp.IsZero() {
+				skipped++
+			} else {
+				fn(&event)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return skipped, nil
+		}
+		if err != nil {
+			return skipped, fmt.Errorf("failed to read audit file: %w", err)
+		}
+	}
+}
+
 // MinIOWriter is a stub for MinIO-backed audit logging
 // Full implementation will come in Phase 4
 type MinIOWriter struct {
@@ -266,6 +478,15 @@ func generateEventID() string {
 	return "evt-" + hex.EncodeToString(b)
 }
 
+// generateInstanceID generates a random per-process instance ID
+func generateInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("inst-%d", time.Now().UnixNano())
+	}
+	return "inst-" + hex.EncodeToString(b)
+}
+
 // NewEvent creates a new audit event with common fields populated
 func NewEvent(decision Decision, action, resource, reason string) *AuditEvent {
 	return &AuditEvent{
@@ -277,3 +498,26 @@ func NewEvent(decision Decision, action, resource, reason string) *AuditEvent {
 		Reason:    reason,
 	}
 }
+
+// NewChangeEvent creates an audit event for an administrative mutation.
+// The before and after states are recorded as SHA-256 digests of their JSON encoding.
+func NewChangeEvent(actor, action, resource string, before, after interface{}) *AuditEvent {
+	event := NewEvent(DecisionAllow, action, resource, fmt.Sprintf("%s applied to %s", action, resource))
+	event.Actor = actor
+	event.BeforeDigest = Digest(before)
+	event.AfterDigest = Digest(after)
+	return event
+}
+
+// Digest returns the hex SHA-256 of v's JSON encoding, or "" for nil values
+func Digest(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
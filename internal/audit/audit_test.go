@@ -3,10 +3,13 @@ package audit
 import (
 	"bytes"
 	"encoding/json"
+	"net"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/NSACodeGov/CodeGov/internal/geoip"
 	"github.com/NSACodeGov/CodeGov/pkg/models"
 )
 
@@ -103,6 +106,98 @@ func TestLogDisabled(t *testing.T) {
 	}
 }
 
+// blockingWriter holds each write until released
+type blockingWriter struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(event *AuditEvent) error {
+	w.started <- struct{}{}
+	<-w.release
+	return nil
+}
+
+func (w *blockingWriter) Close() error { return nil }
+
+func TestPending(t *testing.T) {
+	logger := NewLogger()
+	writer := &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+	logger.AddWriter(writer)
+
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			logger.Log(&AuditEvent{Action: "/test"})
+			done <- struct{}{}
+		}()
+	}
+	<-writer.started
+	<-writer.started
+	if got := logger.Pending(); got != 2 {
+		t.Errorf("expected 2 pending events, got %d", got)
+	}
+
+	close(writer.release)
+	<-done
+	<-done
+	if got := logger.Pending(); got != 0 {
+		t.Errorf("expected no pending events, got %d", got)
+	}
+}
+
+// failingWriter rejects every event
+type failingWriter struct{}
+
+func (failingWriter) Write(event *AuditEvent) error { return os.ErrClosed }
+func (failingWriter) Close() error                  { return nil }
+
+func TestStats(t *testing.T) {
+	logger := NewLogger()
+	logger.AddWriter(&bufferWriter{})
+	logger.AddWriter(failingWriter{})
+	blocking := &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+	logger.AddWriter(blocking)
+
+	done := make(chan struct{})
+	go func() {
+		logger.Log(&AuditEvent{Action: "/test"})
+		done <- struct{}{}
+	}()
+	<-blocking.started
+
+	// Close waits for the event in flight, which counts as flushed
+	closed := make(chan struct{})
+	go func() {
+		logger.Close()
+		close(closed)
+	}()
+	for !logger.closing.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	close(blocking.release)
+	<-done
+	<-closed
+
+	if err := logger.Log(&AuditEvent{Action: "/late"}); err != ErrClosed {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+
+	stats := logger.Stats()
+	if stats.Flushed != 1 || stats.Dropped != 1 || stats.Pending != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if len(stats.Writers) != 3 {
+		t.Fatalf("expected 3 writers, got %+v", stats.Writers)
+	}
+	if w := stats.Writers[0]; w.Writer != "*audit.bufferWriter" || w.Written != 1 || w.Failed != 0 {
+		t.Errorf("unexpected buffer writer stats %+v", w)
+	}
+	if w := stats.Writers[1]; w.Written != 0 || w.Failed != 1 {
+		t.Errorf("unexpected failing writer stats %+v", w)
+	}
+}
+
 func TestStdoutWriter(t *testing.T) {
 	writer := NewStdoutWriter()
 
@@ -181,6 +276,38 @@ func TestFileWriter(t *testing.T) {
 	}
 }
 
+func TestScanFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writer, err := NewFileWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"evt-1", "evt-2"} {
+		writer.Write(&AuditEvent{EventID: id, Timestamp: time.Now(), Action: "/test", Decision: DecisionDeny})
+	}
+	writer.Close()
+
+	// Lines rewritten by transforms are skipped
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString(`{"event.id":"evt-3"}` + "\nnot json\n")
+	file.Close()
+
+	var ids []string
+	skipped, err := ScanFile(path, func(event *AuditEvent) {
+		ids = append(ids, event.EventID)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != "evt-1" || ids[1] != "evt-2" || skipped != 2 {
+		t.Errorf("unexpected scan: %v, %d skipped", ids, skipped)
+	}
+
+	if _, err := ScanFile(filepath.Join(t.TempDir(), "missing.log"), func(*AuditEvent) {}); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
 func TestMinIOWriter(t *testing.T) {
 	writer := NewMinIOWriter("localhost:9000", "audit")
 
@@ -289,3 +416,105 @@ func (w *bufferWriter) Write(event *AuditEvent) error {
 func (w *bufferWriter) Close() error {
 	return nil
 }
+
+type stubResolver struct {
+	info *geoip.Info
+}
+
+func (s *stubResolver) Resolve(ip net.IP) *geoip.Info {
+	return s.info
+}
+
+func TestGeoIPEnricher(t *testing.T) {
+	logger := NewLogger()
+	var buf bytes.Buffer
+	logger.AddWriter(&bufferWriter{buf: &buf})
+	logger.AddEnricher(NewGeoIPEnricher(&stubResolver{info: &geoip.Info{Country: "US", ASN: 64500}}))
+
+	event := &AuditEvent{Actor: "device-1", SourceIP: "203.0.113.7:51234"}
+	logger.Log(event)
+
+	if event.Geo == nil || event.Geo.Country != "US" || event.Geo.ASN != 64500 {
+		t.Errorf("expected geo enrichment, got %+v", event.Geo)
+	}
+
+	private := &AuditEvent{Actor: "device-1", SourceIP: "10.0.0.5:51234"}
+	logger.Log(private)
+
+	if private.Geo != nil {
+		t.Errorf("expected no enrichment for private address, got %+v", private.Geo)
+	}
+}
+
+func TestNewChangeEvent(t *testing.T) {
+	type state struct {
+		Clearance models.Clearance `json:"clearance"`
+	}
+	var none *state
+
+	event := NewChangeEvent("admin", "device.clearance", "device/1", &state{models.ClearanceLevel3}, &state{models.ClearanceLevel5})
+
+	if event.Decision != DecisionAllow {
+		t.Errorf("expected allow decision, got %s", event.Decision)
+	}
+	if event.BeforeDigest == "" || event.AfterDigest == "" {
+		t.Fatal("expected before and after digests")
+	}
+	if event.BeforeDigest == event.AfterDigest {
+		t.Error("expected digests to differ for different states")
+	}
+
+	created := NewChangeEvent("admin", "device.register", "device/1", none, &state{models.ClearanceLevel3})
+	if created.BeforeDigest != "" {
+		t.Errorf("expected empty before digest for nil state, got %s", created.BeforeDigest)
+	}
+	if created.AfterDigest != event.BeforeDigest {
+		t.Error("expected identical states to produce identical digests")
+	}
+}
+
+func TestSequenceAndMonotonicTimestamps(t *testing.T) {
+	logger := NewLogger()
+	logger.SetInstanceID("node-a")
+	logger.AddWriter(&bufferWriter{})
+
+	now := time.Now().UTC()
+	first := &AuditEvent{Actor: "a", Timestamp: now}
+	skewed := &AuditEvent{Actor: "b", Timestamp: now.Add(-time.Minute)} // clock stepped backwards
+	third := &AuditEvent{Actor: "c"}
+
+	logger.Log(first)
+	logger.Log(skewed)
+	logger.Log(third)
+
+	for i, event := range []*AuditEvent{first, skewed, third} {
+		if event.Sequence != uint64(i+1) {
+			t.Errorf("event %d: expected sequence %d, got %d", i, i+1, event.Sequence)
+		}
+		if event.InstanceID != "node-a" {
+			t.Errorf("event %d: expected instance ID node-a, got %s", i, event.InstanceID)
+		}
+	}
+
+	if skewed.Timestamp.Before(first.Timestamp) {
+		t.Error("expected timestamp not to move backwards")
+	}
+	if third.Timestamp.Before(skewed.Timestamp) {
+		t.Error("expected timestamps to be non-decreasing")
+	}
+}
+
+func TestLevelIncludes(t *testing.T) {
+	if !LevelBody.Includes(LevelHeaders) {
+		t.Error("expected body level to include headers")
+	}
+	if LevelDecision.Includes(LevelHeaders) {
+		t.Error("expected decision level not to include headers")
+	}
+	if !ValidLevel("") || ValidLevel("verbose") {
+		t.Error("unexpected ValidLevel result")
+	}
+	if !RedactHeader("Authorization") || RedactHeader("X-Device-ID") {
+		t.Error("unexpected RedactHeader result")
+	}
+}
@@ -0,0 +1,43 @@
+package audit
+
+import (
+	"net"
+
+	"github.com/NSACodeGov/CodeGov/internal/geoip"
+)
+
+// GeoResolver resolves location and network information for an IP address
+type GeoResolver interface {
+	Resolve(ip net.IP) *geoip.Info
+}
+
+// GeoIPEnricher annotates events with the country and ASN of the source IP
+type GeoIPEnricher struct {
+	resolver GeoResolver
+}
+
+// NewGeoIPEnricher creates a new GeoIP enricher
+func NewGeoIPEnricher(resolver GeoResolver) *GeoIPEnricher {
+	return &GeoIPEnricher{
+		resolver: resolver,
+	}
+}
+
+// Enrich sets event.Geo for public source addresses
+func (e *GeoIPEnricher) Enrich(event *AuditEvent) {
+	if event.SourceIP == "" || event.Geo != nil {
+		return
+	}
+
+	host := event.SourceIP
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+		return
+	}
+
+	event.Geo = e.resolver.Resolve(ip)
+}
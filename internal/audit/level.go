@@ -0,0 +1,62 @@
+package audit
+
+import "strings"
+
+// Level controls how much request detail is captured in an audit event
+type Level string
+
+const (
+	// LevelNone suppresses audit events of allowed requests; denials are still
+	// recorded at LevelDecision
+	LevelNone Level = "none"
+	// LevelDecision records the decision and request path without the query string
+	LevelDecision Level = "decision"
+	// LevelHeaders adds the full URL and request headers (credentials redacted)
+	LevelHeaders Level = "headers"
+	// LevelBody adds a SHA-256 digest of the request body on top of LevelHeaders
+	LevelBody Level = "body"
+)
+
+// DefaultLevel is used when neither policy nor route configuration sets a level
+const DefaultLevel = LevelDecision
+
+// redactedHeaders are never copied into audit events
+var redactedHeaders = map[string]bool{
+	"authorization":       true,
+	"cookie":              true,
+	"proxy-authorization": true,
+	"set-cookie":          true,
+}
+
+// ValidLevel reports whether l is a known audit level (empty means unset)
+func ValidLevel(l Level) bool {
+	switch l {
+	case "", LevelNone, LevelDecision, LevelHeaders, LevelBody:
+		return true
+	}
+	return false
+}
+
+// Includes reports whether l captures at least as much detail as other
+func (l Level) Includes(other Level) bool {
+	return levelRank(l) >= levelRank(other)
+}
+
+func levelRank(l Level) int {
+	switch l {
+	case LevelNone:
+		return 0
+	case LevelDecision:
+		return 1
+	case LevelHeaders:
+		return 2
+	case LevelBody:
+		return 3
+	}
+	return 1
+}
+
+// RedactHeader reports whether a header must be omitted from audit events
+func RedactHeader(name string) bool {
+	return redactedHeaders[strings.ToLower(name)]
+}
@@ -0,0 +1,228 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// RedactedValue replaces the values of redacted fields
+const RedactedValue = "[REDACTED]"
+
+// Record is an audit event in its JSON form, as transforms see it. Keys are the
+// event's JSON field names; nested objects stay map[string]interface{} until flattened
+// and numbers are json.Number, so they are written back unchanged.
+type Record map[string]interface{}
+
+// Transform rewrites a record before a writer encodes it. Transforms may modify the
+// record in place; returning nil drops the event for that writer.
+type Transform func(Record) Record
+
+// Pipeline is an ordered list of transforms applied to each event a writer receives.
+// Each writer encodes its own copy, so a pipeline never changes what other writers see.
+type Pipeline []Transform
+
+// Encode returns the JSON encoding of an event after the pipeline has run, or nil if a
+// transform dropped it. An empty pipeline encodes the event unchanged.
+func (p Pipeline) Encode(event *AuditEvent) ([]byte, error) {
+	data, err := json.Marshal(event)
+	if err != nil || len(p) == 0 {
+		return data, err
+	}
+
+	var record Record
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&record); err != nil {
+		return nil, err
+	}
+	for _, transform := range p {
+		if record = transform(record); record == nil {
+			return nil, nil
+		}
+	}
+	return json.Marshal(record)
+}
+
+// Redact replaces the values of fields with RedactedValue. Fields are dotted paths
+// into the record, e.g. "source_ip" or "additional_data.headers.X-Api-Key"; fields
+// the event does not have are left absent.
+func Redact(fields ...string) Transform {
+	return func(r Record) Record {
+		for _, field := range fields {
+			if parent, key, ok := r.lookup(field); ok {
+				parent[key] = RedactedValue
+			}
+		}
+		return r
+	}
+}
+
+// Enrich sets fields to fixed values, e.g. the deployment or data center a feed
+// comes from. Existing fields are overwritten.
+func Enrich(values map[string]interface{}) Transform {
+	return func(r Record) Record {
+		for field, value := range values {
+			r[field] = value
+		}
+		return r
+	}
+}
+
+// Truncate shortens string values longer than max bytes, at any depth, so that
+// oversized headers or reasons cannot exceed a receiver's message size
+func Truncate(max int) Transform {
+	var truncate func(v interface{}) interface{}
+	truncate = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case string:
+			if len(v) <= max {
+				return v
+			}
+			cut := max
+			for cut > 0 && !utf8.RuneStart(v[cut]) {
+				cut--
+			}
+			return v[:cut]
+		case map[string]interface{}:
+			for k, child := range v {
+				v[k] = truncate(child)
+			}
+		case []interface{}:
+			for i, child := range v {
+				v[i] = truncate(child)
+			}
+		}
+		return v
+	}
+
+	return func(r Record) Record {
+		for k, v := range r {
+			r[k] = truncate(v)
+		}
+		return r
+	}
+}
+
+// MapFields renames fields. Keys of mapping are dotted paths as in Redact and values
+// are the top-level names the fields are moved to, e.g. {"source_ip": "src",
+// "geo.country": "cs1"} for a CEF-oriented receiver.
+func MapFields(mapping map[string]string) Transform {
+	// Apply renames in a fixed order so overlapping mappings behave the same every time
+	sources := make([]string, 0, len(mapping))
+	for source := range mapping {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	return func(r Record) Record {
+		moved := make(map[string]interface{}, len(mapping))
+		for _, source := range sources {
+			if parent, key, ok := r.lookup(source); ok {
+				moved[mapping[source]] = parent[key]
+				delete(parent, key)
+			}
+		}
+		for target, value := range moved {
+			r[target] = value
+		}
+		return r
+	}
+}
+
+// Flatten replaces nested objects with top-level fields joined by sep, e.g.
+// "additional_data.headers.User-Agent", for receivers that only accept flat
+// key-value events. Arrays are kept as values.
+func Flatten(sep string) Transform {
+	var flatten func(out Record, prefix string, m map[string]interface{})
+	flatten = func(out Record, prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			if child, ok := v.(map[string]interface{}); ok {
+				flatten(out, prefix+k+sep, child)
+				continue
+			}
+			out[prefix+k] = v
+		}
+	}
+
+	return func(r Record) Record {
+		out := make(Record, len(r))
+		flatten(out, "", r)
+		return out
+	}
+}
+
+// lookup resolves a dotted path to the map holding the field and its key. A key that
+// itself contains dots, as left by Flatten, matches before the path is split.
+func (r Record) lookup(path string) (map[string]interface{}, string, bool) {
+	m := map[string]interface{}(r)
+	for {
+		if _, ok := m[path]; ok {
+			return m, path, true
+		}
+		head, rest, found := strings.Cut(path, ".")
+		if !found {
+			return nil, "", false
+		}
+		child, ok := m[head].(map[string]interface{})
+		if !ok {
+			return nil, "", false
+		}
+		m, path = child, rest
+	}
+}
+
+// TransformConfig describes one pipeline stage in configuration files
+type TransformConfig struct {
+	Type      string            `json:"type"`       // redact, enrich, truncate, map or flatten
+	Fields    []string          `json:"fields"`     // redact: dotted field paths
+	Values    map[string]string `json:"values"`     // enrich: fields to set
+	MaxLength int               `json:"max_length"` // truncate: maximum string length in bytes
+	Mapping   map[string]string `json:"mapping"`    // map: dotted field path to new name
+	Separator string            `json:"separator"`  // flatten: key separator; empty uses "."
+}
+
+// NewPipeline builds a pipeline from its configuration
+func NewPipeline(configs []TransformConfig) (Pipeline, error) {
+	pipeline := make(Pipeline, 0, len(configs))
+	for i, c := range configs {
+		switch c.Type {
+		case "redact":
+			if len(c.Fields) == 0 {
+				return nil, fmt.Errorf("audit transform %d: redact needs fields", i)
+			}
+			pipeline = append(pipeline, Redact(c.Fields...))
+		case "enrich":
+			if len(c.Values) == 0 {
+				return nil, fmt.Errorf("audit transform %d: enrich needs values", i)
+			}
+			values := make(map[string]interface{}, len(c.Values))
+			for k, v := range c.Values {
+				values[k] = v
+			}
+			pipeline = append(pipeline, Enrich(values))
+		case "truncate":
+			if c.MaxLength <= 0 {
+				return nil, fmt.Errorf("audit transform %d: truncate needs a positive max_length", i)
+			}
+			pipeline = append(pipeline, Truncate(c.MaxLength))
+		case "map":
+			if len(c.Mapping) == 0 {
+				return nil, fmt.Errorf("audit transform %d: map needs a mapping", i)
+			}
+			pipeline = append(pipeline, MapFields(c.Mapping))
+		case "flatten":
+			sep := c.Separator
+			if sep == "" {
+				sep = "."
+			}
+			pipeline = append(pipeline, Flatten(sep))
+		default:
+			return nil, fmt.Errorf("audit transform %d: unknown type %q", i, c.Type)
+		}
+	}
+	return pipeline, nil
+}
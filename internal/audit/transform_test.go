@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func transformFixture() *AuditEvent {
+	return &AuditEvent{
+		EventID:   "evt-1",
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Sequence:  9007199254740993, // Beyond float64 precision
+		Actor:     "device-7",
+		DeviceID:  7,
+		Action:    "GET /api/data",
+		Decision:  DecisionDeny,
+		Reason:    strings.Repeat("é", 10),
+		SourceIP:  "203.0.113.9:4411",
+		AdditionalData: map[string]interface{}{
+			"headers": map[string]string{"User-Agent": "sensor/2.4", "X-Api-Key": "secret"},
+		},
+	}
+}
+
+func TestPipelineEncode(t *testing.T) {
+	pipeline, err := NewPipeline([]TransformConfig{
+		{Type: "truncate", MaxLength: 8},
+		{Type: "redact", Fields: []string{"additional_data.headers.X-Api-Key", "missing.field"}},
+		{Type: "flatten"},
+		{Type: "map", Mapping: map[string]string{"source_ip": "src", "additional_data.headers.User-Agent": "requestClientApplication"}},
+		{Type: "enrich", Values: map[string]string{"deviceVendor": "NSACodeGov"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := transformFixture()
+	data, err := pipeline.Encode(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	if err := dec.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"additional_data.headers.X-Api-Key": RedactedValue,
+		"requestClientApplication":          "sensor/2",
+		"src":                               "203.0.11",
+		"reason":                            "éééé", // Cut at a rune boundary
+		"deviceVendor":                      "NSACodeGov",
+		"sequence":                          "9007199254740993",
+	}
+	for field, value := range want {
+		if v, ok := got[field]; !ok || fmt.Sprint(v) != value {
+			t.Errorf("%s = %v, want %q", field, v, value)
+		}
+	}
+	for _, field := range []string{"source_ip", "additional_data", "missing.field"} {
+		if _, ok := got[field]; ok {
+			t.Errorf("unexpected field %s in %s", field, data)
+		}
+	}
+
+	// The event itself is untouched, so other writers still get the raw form
+	if event.SourceIP != "203.0.113.9:4411" || event.AdditionalData["headers"].(map[string]string)["X-Api-Key"] != "secret" {
+		t.Errorf("pipeline modified the event: %+v", event)
+	}
+}
+
+func TestWritersApplyOwnPipeline(t *testing.T) {
+	dir := t.TempDir()
+	raw, err := NewFileWriter(filepath.Join(dir, "raw.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	siem, err := NewFileWriter(filepath.Join(dir, "siem.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	denyOnly := func(r Record) Record {
+		if r["decision"] != string(DecisionDeny) {
+			return nil
+		}
+		return r
+	}
+	siem.SetPipeline(Pipeline{denyOnly, Redact("source_ip"), Flatten(".")})
+
+	logger := NewLogger()
+	logger.AddWriter(raw)
+	logger.AddWriter(siem)
+	logger.Log(transformFixture())
+	allowed := transformFixture()
+	allowed.Decision = DecisionAllow
+	logger.Log(allowed)
+	logger.Close()
+
+	rawData, _ := os.ReadFile(filepath.Join(dir, "raw.jsonl"))
+	siemData, _ := os.ReadFile(filepath.Join(dir, "siem.jsonl"))
+	if n := strings.Count(string(rawData), "\n"); n != 2 {
+		t.Errorf("expected 2 raw events, got %d", n)
+	}
+	if !strings.Contains(string(rawData), `"source_ip":"203.0.113.9:4411"`) || !strings.Contains(string(rawData), `"additional_data":{`) {
+		t.Errorf("raw events were transformed: %s", rawData)
+	}
+	if n := strings.Count(string(siemData), "\n"); n != 1 {
+		t.Fatalf("expected 1 SIEM event, got %d: %s", n, siemData)
+	}
+	if !strings.Contains(string(siemData), `"source_ip":"[REDACTED]"`) || !strings.Contains(string(siemData), `"additional_data.headers.User-Agent":"sensor/2.4"`) {
+		t.Errorf("SIEM event was not transformed: %s", siemData)
+	}
+}
+
+func TestNewPipelineRejectsInvalidStages(t *testing.T) {
+	for _, c := range []TransformConfig{
+		{Type: "redact"},
+		{Type: "enrich"},
+		{Type: "truncate", MaxLength: -1},
+		{Type: "map"},
+		{Type: "uppercase"},
+	} {
+		if _, err := NewPipeline([]TransformConfig{c}); err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
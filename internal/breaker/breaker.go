@@ -0,0 +1,256 @@
+// Package breaker protects callers of an unreliable dependency: a circuit breaker
+// stops sending requests after repeated failures, and a retry budget caps how much
+// extra load retries may add while the dependency is struggling.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Defaults for breakers and retry budgets
+const (
+	DefaultFailureThreshold = 5
+	DefaultCooldown         = 30 * time.Second
+	DefaultRetryRatio       = 0.2 // Retries allowed per request by a retry budget
+)
+
+// ErrOpen is returned by Allow callers when the circuit is open
+var ErrOpen = errors.New("circuit open")
+
+// State is the state of a circuit breaker
+type State string
+
+const (
+	StateClosed   State = "closed"    // Requests flow normally
+	StateOpen     State = "open"      // Requests fail fast until the cooldown ends
+	StateHalfOpen State = "half-open" // A single probe request decides whether to close again
+)
+
+// Breaker is a consecutive-failure circuit breaker. After threshold failures in a
+// row it opens for cooldown, then lets one probe through: a success closes it and
+// a failure opens it for another cooldown.
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+	lastErr  error
+	onChange []func(name string, from, to State)
+}
+
+// New creates a closed breaker; zero threshold or cooldown use the defaults
+func New(name string, threshold int, cooldown time.Duration) *Breaker {
+	if threshold <= 0 {
+		threshold = DefaultFailureThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultCooldown
+	}
+	return &Breaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		state:     StateClosed,
+	}
+}
+
+// OnChange registers a callback invoked whenever the breaker changes state
+func (b *Breaker) OnChange(fn func(name string, from, to State)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onChange = append(b.onChange, fn)
+}
+
+// Allow reports whether a request may be sent. Once the cooldown has passed an
+// open breaker admits exactly one probe; every admitted request must be followed
+// by Success, Failure or Ignore.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	var from State
+	allowed := true
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			allowed = false
+			break
+		}
+		from = b.state
+		b.state = StateHalfOpen
+		b.probing = true
+	case StateHalfOpen:
+		if b.probing {
+			allowed = false
+			break
+		}
+		b.probing = true
+	}
+	callbacks := b.onChange
+	b.mu.Unlock()
+
+	if from != "" {
+		for _, fn := range callbacks {
+			fn(b.name, from, StateHalfOpen)
+		}
+	}
+	return allowed
+}
+
+// Success records a successful request, closing a half-open breaker
+func (b *Breaker) Success() {
+	b.transition(func() State {
+		b.failures = 0
+		b.probing = false
+		b.lastErr = nil
+		return StateClosed
+	})
+}
+
+// Failure records a failed request, opening the breaker at the threshold or when the probe failed
+func (b *Breaker) Failure(err error) {
+	b.transition(func() State {
+		b.failures++
+		b.probing = false
+		b.lastErr = err
+		if b.state == StateHalfOpen || b.failures >= b.threshold {
+			b.openedAt = b.now()
+			return StateOpen
+		}
+		return b.state
+	})
+}
+
+// Ignore releases an admitted request whose outcome says nothing about the
+// dependency, e.g. one the caller cancelled, without counting it either way
+func (b *Breaker) Ignore() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// transition applies update under the lock and notifies callbacks of a state change
+func (b *Breaker) transition(update func() State) {
+	b.mu.Lock()
+	from := b.state
+	b.state = update()
+	to := b.state
+	callbacks := b.onChange
+	b.mu.Unlock()
+
+	if from != to {
+		for _, fn := range callbacks {
+			fn(b.name, from, to)
+		}
+	}
+}
+
+// State returns the breaker's current state
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// RetryAfter returns how long an open breaker keeps rejecting requests
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != StateOpen {
+		return 0
+	}
+	if wait := b.cooldown - b.now().Sub(b.openedAt); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// HealthCheck reports an error while the breaker is not closed. Register it as a
+// non-critical check so a broken dependency shows as degraded rather than unhealthy.
+func (b *Breaker) HealthCheck() func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if b.state == StateClosed {
+			return nil
+		}
+		return fmt.Errorf("degraded: %s circuit %s since %s after %d failures (%v)",
+			b.name, b.state, b.openedAt.UTC().Format(time.RFC3339), b.failures, b.lastErr)
+	}
+}
+
+// budgetWindow is the period over which a retry budget counts requests and retries
+const budgetWindow = 10
+
+// RetryBudget caps retries at a fraction of recent requests, plus a small allowance
+// per second so low-traffic callers can still retry. Counts cover the last ten seconds.
+type RetryBudget struct {
+	ratio        float64
+	minPerSecond int
+	now          func() time.Time
+
+	mu      sync.Mutex
+	buckets [budgetWindow]budgetBucket
+}
+
+// budgetBucket counts the requests and retries of one second
+type budgetBucket struct {
+	second   int64
+	requests int
+	retries  int
+}
+
+// NewRetryBudget allows retries up to ratio of requests plus minPerSecond retries each second
+func NewRetryBudget(ratio float64, minPerSecond int) *RetryBudget {
+	return &RetryBudget{ratio: ratio, minPerSecond: minPerSecond, now: time.Now}
+}
+
+// Request records a request that may later be retried
+func (b *RetryBudget) Request() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bucket().requests++
+}
+
+// Withdraw reports whether a retry fits in the budget and, if so, records it
+func (b *RetryBudget) Withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := b.bucket()
+	var requests, retries int
+	for _, bucket := range b.buckets {
+		if bucket.second > current.second-budgetWindow {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+
+	allowed := float64(b.minPerSecond*budgetWindow) + b.ratio*float64(requests)
+	if float64(retries+1) > allowed {
+		return false
+	}
+	current.retries++
+	return true
+}
+
+// bucket returns the current second's bucket, resetting it if it holds an old second
+func (b *RetryBudget) bucket() *budgetBucket {
+	second := b.now().Unix()
+	bucket := &b.buckets[second%budgetWindow]
+	if bucket.second != second {
+		*bucket = budgetBucket{second: second}
+	}
+	return bucket
+}
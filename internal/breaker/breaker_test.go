@@ -0,0 +1,104 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := New("inventory", 3, time.Minute)
+	b.now = func() time.Time { return now }
+
+	var transitions []State
+	b.OnChange(func(name string, from, to State) {
+		transitions = append(transitions, to)
+	})
+
+	failure := errors.New("connection refused")
+	for i := 0; i < 2; i++ {
+		if !b.Allow() {
+			t.Fatal("closed breaker rejected a request")
+		}
+		b.Failure(failure)
+	}
+	if b.State() != StateClosed {
+		t.Fatalf("breaker opened below the threshold")
+	}
+	b.Allow()
+	b.Failure(failure)
+	if b.State() != StateOpen || b.Allow() {
+		t.Fatalf("expected an open breaker rejecting requests, got %s", b.State())
+	}
+	if err := b.HealthCheck()(context.Background()); err == nil {
+		t.Error("expected the health check to fail while open")
+	}
+	if got := b.RetryAfter(); got != time.Minute {
+		t.Errorf("expected a one minute retry-after, got %s", got)
+	}
+
+	// After the cooldown one probe is admitted; its failure reopens the breaker
+	now = now.Add(time.Minute)
+	if !b.Allow() || b.Allow() {
+		t.Fatal("expected exactly one probe in half-open state")
+	}
+	b.Failure(failure)
+	if b.State() != StateOpen {
+		t.Fatalf("failed probe should reopen the breaker, got %s", b.State())
+	}
+
+	now = now.Add(time.Minute)
+	b.Allow()
+	b.Success()
+	if b.State() != StateClosed || !b.Allow() {
+		t.Fatalf("successful probe should close the breaker, got %s", b.State())
+	}
+	if err := b.HealthCheck()(context.Background()); err != nil {
+		t.Errorf("unexpected health check error: %v", err)
+	}
+
+	want := []State{StateOpen, StateHalfOpen, StateOpen, StateHalfOpen, StateClosed}
+	if len(transitions) != len(want) {
+		t.Fatalf("unexpected transitions %v", transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("unexpected transitions %v", transitions)
+		}
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewRetryBudget(0.2, 0)
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		b.Request()
+	}
+	if !b.Withdraw() || !b.Withdraw() {
+		t.Fatal("expected two retries for ten requests at 20%")
+	}
+	if b.Withdraw() {
+		t.Fatal("expected the budget to be exhausted")
+	}
+
+	// Requests and retries age out of the ten-second window
+	now = now.Add(budgetWindow * time.Second)
+	if b.Withdraw() {
+		t.Fatal("expected no retries without recent requests")
+	}
+
+	min := NewRetryBudget(0, 1)
+	min.now = func() time.Time { return now }
+	for i := 0; i < budgetWindow; i++ {
+		if !min.Withdraw() {
+			t.Fatalf("retry %d rejected within the per-second allowance", i)
+		}
+	}
+	if min.Withdraw() {
+		t.Fatal("expected the per-second allowance to be exhausted")
+	}
+}
@@ -0,0 +1,62 @@
+// Package buildinfo reports how the running binary was built.
+//
+// Release builds set the version, commit and date with -ldflags, e.g.
+//
+//	go build -ldflags "-X github.com/NSACodeGov/CodeGov/internal/buildinfo.Version=1.2.0 \
+//	  -X github.com/NSACodeGov/CodeGov/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/NSACodeGov/CodeGov/internal/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/gogovcode
+//
+// Without ldflags the commit and date fall back to the VCS stamp the Go toolchain
+// embeds when building from a git checkout.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time with -ldflags "-X"
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// Info describes the running binary
+type Info struct {
+	Version    string          `json:"version"`
+	Commit     string          `json:"commit,omitempty"`
+	BuildDate  string          `json:"build_date,omitempty"`
+	GoVersion  string          `json:"go_version"`
+	Modified   bool            `json:"modified,omitempty"` // Built from a checkout with uncommitted changes
+	Subsystems map[string]bool `json:"subsystems,omitempty"`
+}
+
+// Get returns the build information of the running binary
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: Date,
+		GoVersion: runtime.Version(),
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	return info
+}
@@ -0,0 +1,36 @@
+package buildinfo
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestGetUsesLinkerValues(t *testing.T) {
+	defer func(version, commit, date string) {
+		Version, Commit, Date = version, commit, date
+	}(Version, Commit, Date)
+
+	Version = "1.2.0"
+	Commit = "0123456789abcdef"
+	Date = "2026-01-02T03:04:05Z"
+
+	info := Get()
+	if info.Version != "1.2.0" {
+		t.Errorf("Version = %q, want 1.2.0", info.Version)
+	}
+	if info.Commit != "0123456789abcdef" {
+		t.Errorf("Commit = %q, want 0123456789abcdef", info.Commit)
+	}
+	if info.BuildDate != "2026-01-02T03:04:05Z" {
+		t.Errorf("BuildDate = %q, want 2026-01-02T03:04:05Z", info.BuildDate)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("GoVersion = %q, want %q", info.GoVersion, runtime.Version())
+	}
+}
+
+func TestGetDefaultsToDev(t *testing.T) {
+	if info := Get(); info.Version != "dev" {
+		t.Errorf("Version = %q, want dev when not set by the linker", info.Version)
+	}
+}
@@ -0,0 +1,190 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// File names expected inside a bundle archive
+const (
+	PolicyFile  = "policy.json"
+	DevicesFile = "devices.json"
+)
+
+// maxBundleSize bounds how much data is read for a bundle or its signature
+const maxBundleSize = 32 << 20
+
+// Bundle is the verified content of a signed policy bundle
+type Bundle struct {
+	Policy  []byte           // Raw policy JSON, loaded with policy.Engine.LoadFromJSON
+	Devices []*models.Device // Devices to register before the policy is applied
+	Digest  string           // sha256 digest of the archive, for logging
+}
+
+// Source describes where to fetch a bundle and how to verify it
+type Source struct {
+	URL          string // http(s):// or minio://bucket/key
+	SignatureURL string // Detached signature; defaults to URL + ".sig"
+	PublicKey    ed25519.PublicKey
+	MinIO        *MinIOCredentials // Required for minio:// URLs
+	Client       *http.Client      // Defaults to a client with a 30s timeout
+}
+
+// Load fetches a bundle and its signature, verifies the signature and parses the archive.
+// Nothing from the bundle is returned unless the signature is valid.
+func Load(ctx context.Context, src Source) (*Bundle, error) {
+	if len(src.PublicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("bundle public key is not configured")
+	}
+
+	client := src.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	sigURL := src.SignatureURL
+	if sigURL == "" {
+		sigURL = src.URL + ".sig"
+	}
+
+	data, err := fetch(ctx, client, src.URL, src.MinIO)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bundle: %w", err)
+	}
+	sig, err := fetch(ctx, client, sigURL, src.MinIO)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bundle signature: %w", err)
+	}
+
+	if err := Verify(data, sig, src.PublicKey); err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+// Verify checks a detached ed25519 signature over the archive bytes.
+// The signature may be raw (64 bytes) or base64 encoded.
+func Verify(data, sig []byte, key ed25519.PublicKey) error {
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+		if err != nil {
+			return fmt.Errorf("invalid bundle signature encoding: %w", err)
+		}
+		sig = decoded
+	}
+
+	if len(sig) != ed25519.SignatureSize || !ed25519.Verify(key, data, sig) {
+		return fmt.Errorf("bundle signature verification failed")
+	}
+
+	return nil
+}
+
+// Parse reads policy and devices from a tar archive, optionally gzip compressed
+func Parse(data []byte) (*Bundle, error) {
+	var r io.Reader = bytes.NewReader(data)
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress bundle: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	b := &Bundle{Digest: digest(data)}
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		content, err := io.ReadAll(io.LimitReader(tr, maxBundleSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from bundle: %w", hdr.Name, err)
+		}
+
+		switch path.Clean(strings.TrimPrefix(hdr.Name, "./")) {
+		case PolicyFile:
+			b.Policy = content
+		case DevicesFile:
+			if err := json.Unmarshal(content, &b.Devices); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", DevicesFile, err)
+			}
+		}
+	}
+
+	if b.Policy == nil {
+		return nil, fmt.Errorf("bundle does not contain %s", PolicyFile)
+	}
+
+	return b, nil
+}
+
+// digest returns the sha256 digest of data in "sha256:<hex>" form
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// fetch downloads a bundle object over HTTP(S) or from MinIO
+func fetch(ctx context.Context, client *http.Client, rawURL string, creds *MinIOCredentials) ([]byte, error) {
+	var req *http.Request
+	var err error
+
+	if strings.HasPrefix(rawURL, "minio://") {
+		if creds == nil {
+			return nil, fmt.Errorf("minio URL %s requires MinIO credentials", rawURL)
+		}
+		req, err = creds.newGetRequest(ctx, strings.TrimPrefix(rawURL, "minio://"))
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: unexpected status %d", req.URL.Redacted(), resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBundleSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBundleSize {
+		return nil, fmt.Errorf("GET %s: response exceeds %d bytes", req.URL.Redacted(), maxBundleSize)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,128 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func buildArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func serveBundle(t *testing.T, archive, sig []byte) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bundle.tar":
+			w.Write(archive)
+		case "/bundle.tar.sig":
+			w.Write(sig)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestLoad(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	archive := buildArchive(t, map[string]string{
+		"./policy.json": `{"version":"1.0","rules":[]}`,
+		"devices.json":  `[{"device_id":7,"name":"sensor-007","layer":"data"}]`,
+	})
+	sig := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, archive)))
+
+	srv := serveBundle(t, archive, sig)
+
+	b, err := Load(context.Background(), Source{URL: srv.URL + "/bundle.tar", PublicKey: pub})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if string(b.Policy) != `{"version":"1.0","rules":[]}` {
+		t.Errorf("unexpected policy %s", b.Policy)
+	}
+	if len(b.Devices) != 1 || b.Devices[0].ID != 7 {
+		t.Errorf("unexpected devices %+v", b.Devices)
+	}
+	if !strings.HasPrefix(b.Digest, "sha256:") {
+		t.Errorf("unexpected digest %s", b.Digest)
+	}
+}
+
+func TestLoadRejectsBadSignature(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(nil)
+	_, otherPriv, _ := ed25519.GenerateKey(nil)
+	archive := buildArchive(t, map[string]string{"policy.json": `{"version":"1.0"}`})
+
+	srv := serveBundle(t, archive, ed25519.Sign(otherPriv, archive))
+
+	if _, err := Load(context.Background(), Source{URL: srv.URL + "/bundle.tar", PublicKey: pub}); err == nil {
+		t.Fatal("expected signature verification to fail")
+	}
+
+	if _, err := Load(context.Background(), Source{URL: srv.URL + "/bundle.tar"}); err == nil {
+		t.Fatal("expected error without public key")
+	}
+}
+
+func TestParseRequiresPolicy(t *testing.T) {
+	archive := buildArchive(t, map[string]string{"devices.json": `[]`})
+	if _, err := Parse(archive); err == nil {
+		t.Fatal("expected error for bundle without policy")
+	}
+}
+func TestMinIORequestSigned(t *testing.T) {
+	var auth, path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		path = r.URL.EscapedPath()
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	creds := &MinIOCredentials{
+		Endpoint:  strings.TrimPrefix(srv.URL, "http://"),
+		AccessKey: "minio",
+		SecretKey: "minio123",
+	}
+
+	data, err := fetch(context.Background(), srv.Client(), "minio://policy/prod/bundle v1.tar", creds)
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if string(data) != "ok" {
+		t.Errorf("unexpected body %q", data)
+	}
+	if path != "/policy/prod/bundle%20v1.tar" {
+		t.Errorf("unexpected path %s", path)
+	}
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=minio/") || !strings.Contains(auth, "Signature=") {
+		t.Errorf("unexpected Authorization header %q", auth)
+	}
+
+	if _, err := fetch(context.Background(), srv.Client(), "minio://policy/bundle.tar", nil); err == nil {
+		t.Fatal("expected error without MinIO credentials")
+	}
+}
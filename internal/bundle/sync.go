@@ -0,0 +1,302 @@
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/internal/policy"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// Objects locates a policy and a device list stored as separate MinIO objects, for
+// fleets that update the two independently instead of publishing bundles
+type Objects struct {
+	Policy    string // bucket/key of the policy JSON
+	Devices   string // bucket/key of the device list; optional
+	MinIO     *MinIOCredentials
+	PublicKey ed25519.PublicKey // Each object needs a valid detached signature at <key>.sig
+	Insecure  bool              // Accept unsigned objects when PublicKey is not set; for development only
+	Client    *http.Client      // Defaults to a client with a 30s timeout
+}
+
+// devicesDocument is a versioned device list. A bare JSON array of devices is also
+// accepted, as version "".
+type devicesDocument struct {
+	Version string           `json:"version"`
+	Devices []*models.Device `json:"devices"`
+}
+
+// SyncResult describes one synchronization. Digests are those of the fetched objects;
+// a document whose digest matches the last applied one is left alone.
+type SyncResult struct {
+	PolicyDigest   string
+	PolicyVersion  string // Version of a newly applied policy
+	PolicyChanged  bool
+	DevicesDigest  string
+	DevicesVersion string // Version of a newly applied device list
+	DevicesChanged bool   // Set even when some devices failed to apply
+	Registered     int  // New or restored devices
+	Updated        int  // Devices whose clearance or labels changed
+	Deleted        int  // Devices missing from the list, soft-deleted
+}
+
+// Syncer keeps a policy engine and device registry in line with Objects. Every
+// instance deployed from the same image and configuration converges on the same
+// documents; the digests make refreshes of unchanged objects cheap no-ops. A document
+// whose version is older than the one last applied is refused, so a validly signed
+// but outdated object cannot be replayed.
+type Syncer struct {
+	objects  Objects
+	engine   *policy.Engine
+	registry *models.DeviceRegistry
+
+	// PolicyLoaded is called after each policy load, e.g. to reinstall rules the
+	// server adds to every policy
+	PolicyLoaded func() error
+
+	mu             sync.Mutex
+	policyDigest   string
+	policyVersion  string
+	devicesDigest  string
+	devicesVersion string
+}
+
+// NewSyncer creates a syncer for an engine and registry
+func NewSyncer(objects Objects, engine *policy.Engine, registry *models.DeviceRegistry) *Syncer {
+	if objects.Client == nil {
+		objects.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Syncer{objects: objects, engine: engine, registry: registry}
+}
+
+// Sync fetches the objects and applies those that changed since the last sync:
+// devices first, so the policy's device rules and selectors see them, then the
+// policy. A failed device list does not hold back the policy. A document that fails
+// to fetch, verify or apply is retried on the next sync.
+func (s *Syncer) Sync(ctx context.Context) (SyncResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result SyncResult
+	if len(s.objects.PublicKey) != ed25519.PublicKeySize && !s.objects.Insecure {
+		return result, fmt.Errorf("objects public key is not configured")
+	}
+	var devicesErr error
+	if s.objects.Devices != "" {
+		devicesErr = s.syncDevices(ctx, &result)
+	}
+	return result, errors.Join(devicesErr, s.syncPolicy(ctx, &result))
+}
+
+// syncDevices applies the device list if its digest changed
+func (s *Syncer) syncDevices(ctx context.Context, result *SyncResult) error {
+	data, err := s.fetch(ctx, s.objects.Devices)
+	if err != nil {
+		return fmt.Errorf("devices: %w", err)
+	}
+	result.DevicesDigest = digest(data)
+	if result.DevicesDigest == s.devicesDigest {
+		return nil
+	}
+
+	var doc devicesDocument
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &doc.Devices)
+	} else {
+		err = json.Unmarshal(trimmed, &doc)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse devices: %w", err)
+	}
+	if compareVersions(doc.Version, s.devicesVersion) < 0 {
+		return fmt.Errorf("devices version %q is older than the applied version %q", doc.Version, s.devicesVersion)
+	}
+	result.DevicesVersion = doc.Version
+	result.DevicesChanged = true
+	if err := s.applyDevices(doc.Devices, result); err != nil {
+		return err
+	}
+	s.devicesDigest = result.DevicesDigest
+	s.devicesVersion = doc.Version
+	return nil
+}
+
+// syncPolicy loads the policy if its digest changed
+func (s *Syncer) syncPolicy(ctx context.Context, result *SyncResult) error {
+	data, err := s.fetch(ctx, s.objects.Policy)
+	if err != nil {
+		return fmt.Errorf("policy: %w", err)
+	}
+	result.PolicyDigest = digest(data)
+	if result.PolicyDigest == s.policyDigest {
+		return nil
+	}
+
+	var header struct {
+		Version string `json:"version"`
+	}
+	json.Unmarshal(data, &header)
+	if compareVersions(header.Version, s.policyVersion) < 0 {
+		return fmt.Errorf("policy version %q is older than the applied version %q", header.Version, s.policyVersion)
+	}
+	result.PolicyVersion = header.Version
+	if err := s.engine.LoadFromJSON(data); err != nil {
+		return err
+	}
+	if s.PolicyLoaded != nil {
+		if err := s.PolicyLoaded(); err != nil {
+			return err
+		}
+	}
+	s.policyDigest = result.PolicyDigest
+	s.policyVersion = header.Version
+	result.PolicyChanged = true
+	return nil
+}
+
+// Run syncs every interval until ctx is done, reporting each outcome to report
+func (s *Syncer) Run(ctx context.Context, interval time.Duration, report func(SyncResult, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result, err := s.Sync(ctx)
+			if report != nil {
+				report(result, err)
+			}
+		}
+	}
+}
+
+// fetch downloads an object and verifies its signature, unless the objects are insecure
+func (s *Syncer) fetch(ctx context.Context, object string) ([]byte, error) {
+	data, err := fetch(ctx, s.objects.Client, "minio://"+object, s.objects.MinIO)
+	if err != nil || s.objects.PublicKey == nil {
+		return data, err
+	}
+	sig, err := fetch(ctx, s.objects.Client, "minio://"+object+".sig", s.objects.MinIO)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signature: %w", err)
+	}
+	if err := Verify(data, sig, s.objects.PublicKey); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// applyDevices reconciles the registry with a device list: listed devices are
+// registered or restored and their clearance and labels updated, and registered
+// devices missing from the list are soft-deleted. A device's layer, class and name
+// cannot change in place; such entries are reported and the rest still applied.
+func (s *Syncer) applyDevices(devices []*models.Device, result *SyncResult) error {
+	var errs []error
+	listed := make(map[uint16]bool, len(devices))
+	for _, device := range devices {
+		listed[device.ID] = true
+
+		current, err := s.registry.GetDevice(device.ID)
+		if err != nil {
+			if _, deletedErr := s.registry.GetDeletedDevice(device.ID); deletedErr == nil {
+				err = s.registry.Restore(device.ID)
+			} else {
+				err = s.registry.Register(device)
+			}
+			if err == nil {
+				result.Registered++
+				current, err = s.registry.GetDevice(device.ID)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("device %d: %w", device.ID, err))
+				continue
+			}
+		}
+
+		if current.Layer != device.Layer || current.Class != device.Class || current.Name != device.Name {
+			errs = append(errs, fmt.Errorf("device %d: layer, class and name cannot change; delete and re-register it", device.ID))
+			continue
+		}
+		updated := false
+		if current.Clearance != device.Clearance {
+			if err := s.registry.SetClearance(device.ID, device.Clearance); err != nil {
+				errs = append(errs, fmt.Errorf("device %d: %w", device.ID, err))
+				continue
+			}
+			updated = true
+		}
+		if !equalLabels(current.Labels, device.Labels) {
+			if err := s.registry.SetLabels(device.ID, device.Labels); err != nil {
+				errs = append(errs, fmt.Errorf("device %d: %w", device.ID, err))
+				continue
+			}
+			updated = true
+		}
+		if updated {
+			result.Updated++
+		}
+	}
+
+	for _, device := range s.registry.ListDevices() {
+		if listed[device.ID] {
+			continue
+		}
+		if err := s.registry.Delete(device.ID); err != nil {
+			errs = append(errs, fmt.Errorf("device %d: %w", device.ID, err))
+			continue
+		}
+		result.Deleted++
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to apply devices: %w", err)
+	}
+	return nil
+}
+
+// equalLabels compares label sets, treating nil and empty as equal
+func equalLabels(a, b map[string]string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compareVersions orders dotted numeric versions such as "1.10" and "1.9"; a missing
+// part counts as 0 and a part that is not a number sorts first
+func compareVersions(a, b string) int {
+	x, y := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(x) || i < len(y); i++ {
+		m, n := versionPart(x, i), versionPart(y, i)
+		if m != n {
+			if m < n {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionPart returns the i'th part of a split version, 0 when missing and -1 when not a number
+func versionPart(parts []string, i int) int {
+	if i >= len(parts) || parts[i] == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(parts[i])
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,281 @@
+package bundle
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/NSACodeGov/CodeGov/internal/policy"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// objectStore serves objects at /bucket/key like MinIO
+type objectStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *objectStore) put(key string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects["/"+key] = data
+}
+
+func (s *objectStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(data)
+}
+
+func newObjectSyncer(t *testing.T, store *objectStore, key ed25519.PublicKey) (*Syncer, *models.DeviceRegistry, *policy.Engine) {
+	t.Helper()
+
+	srv := httptest.NewServer(store)
+	t.Cleanup(srv.Close)
+
+	registry := models.NewDeviceRegistry()
+	engine := policy.NewEngine(registry)
+	syncer := NewSyncer(Objects{
+		Policy:  "policy/policy.json",
+		Devices: "policy/devices.json",
+		MinIO: &MinIOCredentials{
+			Endpoint:  strings.TrimPrefix(srv.URL, "http://"),
+			AccessKey: "minio",
+			SecretKey: "minio123",
+		},
+		PublicKey: key,
+		Insecure:  key == nil,
+		Client:    srv.Client(),
+	}, engine, registry)
+	return syncer, registry, engine
+}
+
+func marshalDevices(t *testing.T, devices ...*models.Device) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(devices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestSync(t *testing.T) {
+	store := &objectStore{objects: map[string][]byte{}}
+	store.put("policy/policy.json", []byte(`{"version":"1.0","rules":[]}`))
+	store.put("policy/devices.json", marshalDevices(t,
+		&models.Device{ID: 7, Name: "sensor-007", Layer: models.LayerData, Class: models.DeviceClassSensor, Clearance: 0x01010101},
+		&models.Device{ID: 8, Name: "sensor-008", Layer: models.LayerData, Class: models.DeviceClassSensor},
+	))
+	syncer, registry, engine := newObjectSyncer(t, store, nil)
+	var loads int
+	syncer.PolicyLoaded = func() error { loads++; return nil }
+
+	result, err := syncer.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !result.PolicyChanged || result.PolicyVersion != "1.0" || !result.DevicesChanged || result.Registered != 2 {
+		t.Errorf("unexpected first sync %+v", result)
+	}
+	if len(registry.ListDevices()) != 2 || engine.GetPolicy().Version != "1.0" || loads != 1 {
+		t.Fatalf("got %d devices, policy %q, %d loads", len(registry.ListDevices()), engine.GetPolicy().Version, loads)
+	}
+
+	// Unchanged objects are not applied again
+	result, err = syncer.Sync(context.Background())
+	if err != nil || result.PolicyChanged || result.DevicesChanged || loads != 1 {
+		t.Errorf("unchanged sync = %+v, %v (%d loads)", result, err, loads)
+	}
+
+	store.put("policy/policy.json", []byte(`{"version":"1.1","rules":[]}`))
+	store.put("policy/devices.json", marshalDevices(t,
+		&models.Device{ID: 7, Name: "sensor-007", Layer: models.LayerData, Class: models.DeviceClassSensor, Clearance: 0x02020202, Labels: map[string]string{"site": "a"}},
+		&models.Device{ID: 9, Name: "sensor-009", Layer: models.LayerData, Class: models.DeviceClassSensor},
+	))
+	result, err = syncer.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if result.PolicyVersion != "1.1" || result.Registered != 1 || result.Updated != 1 || result.Deleted != 1 {
+		t.Errorf("unexpected update sync %+v", result)
+	}
+	device, err := registry.GetDevice(7)
+	if err != nil || device.Clearance != 0x02020202 || device.Labels["site"] != "a" {
+		t.Errorf("device 7 = %+v, %v", device, err)
+	}
+	if _, err := registry.GetDeletedDevice(8); err != nil {
+		t.Errorf("device 8 not soft-deleted: %v", err)
+	}
+
+	// Device 8 comes back from its tombstone
+	store.put("policy/devices.json", marshalDevices(t,
+		&models.Device{ID: 8, Name: "sensor-008", Layer: models.LayerData, Class: models.DeviceClassSensor},
+	))
+	result, err = syncer.Sync(context.Background())
+	if err != nil || result.Registered != 1 || result.Deleted != 2 {
+		t.Errorf("restore sync = %+v, %v", result, err)
+	}
+}
+
+func TestSyncDeviceErrorsDoNotBlockPolicy(t *testing.T) {
+	store := &objectStore{objects: map[string][]byte{}}
+	store.put("policy/policy.json", []byte(`{"version":"1.0","rules":[]}`))
+	store.put("policy/devices.json", marshalDevices(t,
+		&models.Device{ID: 7, Name: "sensor-007", Layer: models.LayerData, Class: models.DeviceClassSensor},
+	))
+	syncer, _, engine := newObjectSyncer(t, store, nil)
+	if _, err := syncer.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	store.put("policy/policy.json", []byte(`{"version":"2.0","rules":[]}`))
+	store.put("policy/devices.json", marshalDevices(t,
+		&models.Device{ID: 7, Name: "sensor-007", Layer: models.LayerControl, Class: models.DeviceClassSensor},
+	))
+	result, err := syncer.Sync(context.Background())
+	if err == nil {
+		t.Fatal("expected an error for a device changing layer")
+	}
+	if !result.PolicyChanged || engine.GetPolicy().Version != "2.0" {
+		t.Errorf("policy not applied: %+v", result)
+	}
+
+	// The failed device list is retried
+	if _, err := syncer.Sync(context.Background()); err == nil {
+		t.Error("expected the failed device list to be retried")
+	}
+}
+
+func TestSyncVerifiesSignatures(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	_, otherPriv, _ := ed25519.GenerateKey(nil)
+	policyJSON := []byte(`{"version":"3.0","rules":[]}`)
+	devicesJSON := []byte(`[]`)
+
+	store := &objectStore{objects: map[string][]byte{}}
+	store.put("policy/policy.json", policyJSON)
+	store.put("policy/policy.json.sig", ed25519.Sign(otherPriv, policyJSON))
+	store.put("policy/devices.json", devicesJSON)
+	store.put("policy/devices.json.sig", ed25519.Sign(priv, devicesJSON))
+	syncer, _, engine := newObjectSyncer(t, store, pub)
+
+	if _, err := syncer.Sync(context.Background()); err == nil {
+		t.Fatal("expected signature verification to fail")
+	}
+	if engine.GetPolicy().Version == "3.0" {
+		t.Fatal("policy with a bad signature was loaded")
+	}
+
+	store.put("policy/policy.json.sig", ed25519.Sign(priv, policyJSON))
+	if result, err := syncer.Sync(context.Background()); err != nil || !result.PolicyChanged {
+		t.Fatalf("signed sync = %+v, %v", result, err)
+	}
+}
+
+func TestSyncRequiresPublicKey(t *testing.T) {
+	store := &objectStore{objects: map[string][]byte{}}
+	store.put("policy/policy.json", []byte(`{"version":"1.0","rules":[]}`))
+	store.put("policy/devices.json", []byte(`[]`))
+	syncer, _, _ := newObjectSyncer(t, store, nil)
+	syncer.objects.Insecure = false
+
+	if _, err := syncer.Sync(context.Background()); err == nil || !strings.Contains(err.Error(), "public key") {
+		t.Fatalf("expected a missing public key error, got %v", err)
+	}
+	if syncer.policyDigest != "" || syncer.devicesDigest != "" {
+		t.Error("unsigned objects were applied")
+	}
+}
+
+func TestSyncRejectsOlderVersions(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	store := &objectStore{objects: map[string][]byte{}}
+	publish := func(key string, data []byte) {
+		store.put(key, data)
+		store.put(key+".sig", ed25519.Sign(priv, data))
+	}
+	device := func(clearance models.Clearance) *models.Device {
+		return &models.Device{ID: 7, Name: "sensor-007", Layer: models.LayerData, Class: models.DeviceClassSensor, Clearance: clearance}
+	}
+	versionedDevices := func(version string, devices ...*models.Device) []byte {
+		data, err := json.Marshal(devicesDocument{Version: version, Devices: devices})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	oldPolicy := []byte(`{"version":"1.9","rules":[]}`)
+	oldDevices := versionedDevices("4", device(0x03030303))
+	publish("policy/policy.json", oldPolicy)
+	publish("policy/devices.json", oldDevices)
+	syncer, registry, engine := newObjectSyncer(t, store, pub)
+	if _, err := syncer.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	newPolicy := []byte(`{"version":"1.10","rules":[]}`)
+	publish("policy/policy.json", newPolicy)
+	publish("policy/devices.json", versionedDevices("5", device(0x04040404)))
+	result, err := syncer.Sync(context.Background())
+	if err != nil || result.PolicyVersion != "1.10" || result.DevicesVersion != "5" {
+		t.Fatalf("newer sync = %+v, %v", result, err)
+	}
+
+	// Replaying the earlier, validly signed objects changes nothing
+	publish("policy/policy.json", oldPolicy)
+	publish("policy/devices.json", oldDevices)
+	if _, err := syncer.Sync(context.Background()); err == nil || !strings.Contains(err.Error(), "older") {
+		t.Fatalf("expected replayed objects to be refused, got %v", err)
+	}
+	if engine.GetPolicy().Version != "1.10" {
+		t.Errorf("policy rolled back to %q", engine.GetPolicy().Version)
+	}
+	if d, err := registry.GetDevice(7); err != nil || d.Clearance != 0x04040404 {
+		t.Errorf("device 7 rolled back: %+v, %v", d, err)
+	}
+
+	// Once a version is applied, an unversioned device array is older
+	publish("policy/policy.json", newPolicy)
+	publish("policy/devices.json", marshalDevices(t, device(0x05050505)))
+	if _, err := syncer.Sync(context.Background()); err == nil {
+		t.Error("expected an unversioned device list to be refused")
+	}
+
+	// The same version may be republished with new content
+	publish("policy/devices.json", versionedDevices("5", device(0x05050505)))
+	if result, err := syncer.Sync(context.Background()); err != nil || result.Updated != 1 {
+		t.Errorf("republished sync = %+v, %v", result, err)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1", "1.0", 0},
+		{"1.10", "1.9", 1},
+		{"2.0", "10.0", -1},
+		{"", "1.0", -1},
+		{"", "", 0},
+		{"1.0-rc", "1.0", -1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Package devconfig serves configuration blobs to devices, keyed by their CONFIG token.
+//
+// Blobs live in a directory per token, one file per version:
+//
+//	<dir>/0x8004/1.cfg
+//	<dir>/0x8004/2.cfg
+//
+// Every blob handed out is signed with ed25519 over the token, version and SHA-256 of
+// its content, so a device can check that a blob is authentic, current for the version
+// it asked for and meant for it rather than replayed from another device.
+package devconfig
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Extension is the file extension of configuration blobs
+const Extension = ".cfg"
+
+// MaxBlobSize bounds the size of a configuration blob
+const MaxBlobSize = 8 << 20
+
+// signatureVersion prefixes the signed payload so the format can change later
+const signatureVersion = "gogovcode-config-v1"
+
+// Lookup errors
+var (
+	ErrNotFound  = errors.New("no configuration for token")
+	ErrNoVersion = errors.New("no configuration version matches the request")
+	ErrSignature = errors.New("configuration signature invalid")
+)
+
+// Blob is a signed configuration version for one CONFIG token
+type Blob struct {
+	Token     uint16
+	Version   uint64
+	Data      []byte
+	SHA256    string // Hex digest of Data
+	Signature []byte // ed25519 over Payload
+	ModTime   time.Time
+}
+
+// Request selects a version. Version asks for exactly that version (e.g. to roll back);
+// otherwise the newest version not above MaxVersion (zero for no limit) is chosen.
+type Request struct {
+	Version    uint64
+	MaxVersion uint64
+}
+
+// Store reads configuration blobs from a directory and signs them
+type Store struct {
+	dir string
+	key ed25519.PrivateKey
+}
+
+// NewStore creates a store for dir signing with key. Files are read on every lookup,
+// so new versions can be dropped in without a restart.
+func NewStore(dir string, key ed25519.PrivateKey) *Store {
+	return &Store{dir: dir, key: key}
+}
+
+// PublicKey returns the key devices verify blobs with
+func (s *Store) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// TokenDir is the directory name holding a token's versions, e.g. "0x8004"
+func TokenDir(token uint16) string {
+	return fmt.Sprintf("0x%04X", token)
+}
+
+// Versions lists the versions available for a token in ascending order
+func (s *Store) Versions(token uint16) ([]uint64, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, TokenDir(token)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasSuffix(name, Extension) {
+			continue
+		}
+		version, err := strconv.ParseUint(strings.TrimSuffix(name, Extension), 10, 64)
+		if err != nil || version == 0 {
+			continue // Not a version file; editors' backups and notes are ignored
+		}
+		versions = append(versions, version)
+	}
+	if len(versions) == 0 {
+		return nil, ErrNotFound
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions, nil
+}
+
+// Resolve picks the version a request asks for without reading it
+func (s *Store) Resolve(token uint16, req Request) (uint64, error) {
+	versions, err := s.Versions(token)
+	if err != nil {
+		return 0, err
+	}
+
+	if req.Version > 0 {
+		for _, v := range versions {
+			if v == req.Version {
+				return v, nil
+			}
+		}
+		return 0, ErrNoVersion
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		if req.MaxVersion == 0 || versions[i] <= req.MaxVersion {
+			return versions[i], nil
+		}
+	}
+	return 0, ErrNoVersion
+}
+
+// Get reads and signs a version of a token's configuration
+func (s *Store) Get(token uint16, version uint64) (*Blob, error) {
+	path := filepath.Join(s.dir, TokenDir(token), strconv.FormatUint(version, 10)+Extension)
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNoVersion
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > MaxBlobSize {
+		return nil, fmt.Errorf("configuration %s is larger than %d bytes", path, MaxBlobSize)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	blob := &Blob{
+		Token:   token,
+		Version: version,
+		Data:    data,
+		SHA256:  hex.EncodeToString(sum[:]),
+		ModTime: info.ModTime().UTC(),
+	}
+	blob.Signature = ed25519.Sign(s.key, Payload(token, version, blob.SHA256))
+	return blob, nil
+}
+
+// Payload is the signed form of a blob: its token, version and content digest
+func Payload(token uint16, version uint64, sha256Hex string) []byte {
+	return []byte(strings.Join([]string{
+		signatureVersion,
+		TokenDir(token),
+		strconv.FormatUint(version, 10),
+		sha256Hex,
+	}, "\n"))
+}
+
+// Verify checks a blob received by a device against the store's public key
+func Verify(key ed25519.PublicKey, token uint16, version uint64, data, signature []byte) error {
+	sum := sha256.Sum256(data)
+	if len(signature) != ed25519.SignatureSize || !ed25519.Verify(key, Payload(token, version, hex.EncodeToString(sum[:])), signature) {
+		return ErrSignature
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package devconfig
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	dir := t.TempDir()
+	tokenDir := filepath.Join(dir, TokenDir(0x8004))
+	os.MkdirAll(tokenDir, 0755)
+	for name, content := range map[string]string{
+		"1.cfg":     "interval=60",
+		"2.cfg":     "interval=30",
+		"10.cfg":    "interval=10",
+		"2.cfg.bak": "ignored",
+		"notes.cfg": "ignored",
+	} {
+		os.WriteFile(filepath.Join(tokenDir, name), []byte(content), 0644)
+	}
+
+	_, key, _ := ed25519.GenerateKey(nil)
+	return NewStore(dir, key)
+}
+
+func TestResolve(t *testing.T) {
+	store := newTestStore(t)
+
+	versions, err := store.Versions(0x8004)
+	if err != nil || len(versions) != 3 || versions[2] != 10 {
+		t.Fatalf("unexpected versions %v (%v)", versions, err)
+	}
+
+	tests := []struct {
+		req  Request
+		want uint64
+		err  error
+	}{
+		{Request{}, 10, nil},
+		{Request{MaxVersion: 9}, 2, nil},
+		{Request{Version: 1}, 1, nil},
+		{Request{Version: 3}, 0, ErrNoVersion},
+		{Request{MaxVersion: 0}, 10, nil},
+	}
+	for _, tt := range tests {
+		got, err := store.Resolve(0x8004, tt.req)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("Resolve(%+v) = %d, %v; want %d, %v", tt.req, got, err, tt.want, tt.err)
+		}
+	}
+
+	if _, err := store.Resolve(0x8007, Request{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown token, got %v", err)
+	}
+}
+
+func TestGetSignsTokenAndVersion(t *testing.T) {
+	store := newTestStore(t)
+
+	blob, err := store.Get(0x8004, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(blob.Data) != "interval=30" || len(blob.SHA256) != 64 {
+		t.Fatalf("unexpected blob %+v", blob)
+	}
+
+	key := store.PublicKey()
+	if err := Verify(key, 0x8004, 2, blob.Data, blob.Signature); err != nil {
+		t.Errorf("signature rejected: %v", err)
+	}
+	// The same bytes must not verify for another device or version
+	if err := Verify(key, 0x8007, 2, blob.Data, blob.Signature); !errors.Is(err, ErrSignature) {
+		t.Errorf("signature accepted for another token: %v", err)
+	}
+	if err := Verify(key, 0x8004, 1, blob.Data, blob.Signature); !errors.Is(err, ErrSignature) {
+		t.Errorf("signature accepted for another version: %v", err)
+	}
+
+	if _, err := store.Get(0x8004, 3); !errors.Is(err, ErrNoVersion) {
+		t.Errorf("expected ErrNoVersion, got %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+// Package egress restricts the outbound HTTP requests the server makes itself, such
+// as policy bundle downloads, to an allowlist of hosts and networks.
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AuditAction is the audit action recorded for denied outbound requests
+const AuditAction = "egress.deny"
+
+// ErrDenied is wrapped by errors for requests to destinations outside the allowlist
+var ErrDenied = errors.New("egress denied")
+
+// Policy is an outbound allowlist. A destination is allowed when its host name
+// matches a host rule, or when it is an address, or resolves to addresses, within
+// an allowed network. Requests sent through the proxy can only be matched by host
+// name or address, since the proxy resolves names.
+type Policy struct {
+	hosts    []string // Exact names, or "*.example.gov" for any subdomain
+	networks []*net.IPNet
+	proxy    *url.URL
+
+	// OnDeny is called for every denied destination, e.g. to audit it
+	OnDeny func(host, reason string)
+
+	resolver *net.Resolver
+}
+
+// New parses allowlist entries (host names, "*.domain" wildcards, IP addresses
+// and CIDRs) and an optional HTTP(S) proxy URL
+func New(allow []string, proxy string) (*Policy, error) {
+	p := &Policy{resolver: net.DefaultResolver}
+
+	for _, entry := range allow {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case strings.Contains(entry, "/"):
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid egress network %q: %w", entry, err)
+			}
+			p.networks = append(p.networks, network)
+		case net.ParseIP(entry) != nil:
+			ip := net.ParseIP(entry)
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			p.networks = append(p.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		default:
+			if strings.ContainsAny(strings.TrimPrefix(entry, "*."), "*:") {
+				return nil, fmt.Errorf("invalid egress host %q", entry)
+			}
+			p.hosts = append(p.hosts, entry)
+		}
+	}
+
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid egress proxy %q", proxy)
+		}
+		p.proxy = u
+	}
+
+	return p, nil
+}
+
+// matchHost reports whether a host name matches a host rule
+func (p *Policy) matchHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, rule := range p.hosts {
+		if suffix, ok := strings.CutPrefix(rule, "*"); ok {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+		} else if host == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// matchIP reports whether an address lies in an allowed network
+func (p *Policy) matchIP(ip net.IP) bool {
+	for _, network := range p.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// deny reports a denied destination and returns the error for it
+func (p *Policy) deny(host, reason string) error {
+	if p.OnDeny != nil {
+		p.OnDeny(host, reason)
+	}
+	return fmt.Errorf("%w: %s: %s", ErrDenied, host, reason)
+}
+
+// Transport returns a transport enforcing the policy. Host names are checked before
+// the request is sent and resolved addresses when connecting, so a name that
+// resolves outside the allowed networks is refused.
+func (p *Policy) Transport() http.RoundTripper {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	// The environment's proxy settings are ignored; only the configured proxy is used
+	base.Proxy = nil
+	if p.proxy != nil {
+		base.Proxy = http.ProxyURL(p.proxy)
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	base.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		// The configured proxy and named hosts are trusted as they resolve
+		if (p.proxy != nil && addr == proxyAddr(p.proxy)) || p.matchHost(host) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		var ips []net.IP
+		if ip := net.ParseIP(host); ip != nil {
+			ips = []net.IP{ip}
+		} else {
+			addrs, err := p.resolver.LookupIPAddr(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range addrs {
+				ips = append(ips, a.IP)
+			}
+		}
+
+		var lastErr error
+		for _, ip := range ips {
+			if !p.matchIP(ip) {
+				continue
+			}
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, p.deny(host, "no allowed address")
+	}
+
+	return &transport{policy: p, base: base}
+}
+
+// proxyAddr returns the host:port a proxy URL is dialled at
+func proxyAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+// transport checks each request's host before handing it to the base transport
+type transport struct {
+	policy *Policy
+	base   *http.Transport
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	if t.policy.matchHost(host) {
+		return t.base.RoundTrip(req)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if !t.policy.matchIP(ip) {
+			return nil, t.policy.deny(host, "address not allowed")
+		}
+		return t.base.RoundTrip(req)
+	}
+
+	// Other names are allowed only by the addresses they resolve to, which are
+	// checked when connecting; behind a proxy they cannot be checked at all
+	if t.policy.proxy != nil || len(t.policy.networks) == 0 {
+		return nil, t.policy.deny(host, "host not allowed")
+	}
+	return t.base.RoundTrip(req)
+}
+
+// Client returns an HTTP client with the given timeout using the policy's transport
+func (p *Policy) Client(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: p.Transport()}
+}
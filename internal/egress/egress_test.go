@@ -0,0 +1,76 @@
+package egress
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPolicyTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	byName := "http://localhost:" + u.Port()
+
+	tests := []struct {
+		name    string
+		allow   []string
+		proxy   string
+		target  string
+		allowed bool
+	}{
+		{"network allows address", []string{"127.0.0.0/8"}, "", srv.URL, true},
+		{"single address", []string{"127.0.0.1"}, "", srv.URL, true},
+		{"host rule", []string{"localhost"}, "", byName, true},
+		{"name resolved into network", []string{"127.0.0.0/8"}, "", byName, true},
+		{"name resolved outside network", []string{"10.0.0.0/8"}, "", byName, false},
+		{"address outside network", []string{"10.0.0.0/8", "api.github.com"}, "", srv.URL, false},
+		{"wildcard does not match", []string{"*.example.gov"}, "", byName, false},
+		{"unresolvable behind proxy", []string{"127.0.0.0/8"}, "http://proxy.invalid:3128", byName, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(tt.allow, tt.proxy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var denied []string
+			p.OnDeny = func(host, reason string) {
+				denied = append(denied, host)
+			}
+
+			resp, err := p.Client(5 * time.Second).Get(tt.target)
+			if tt.allowed {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				resp.Body.Close()
+				return
+			}
+			if !errors.Is(err, ErrDenied) {
+				t.Fatalf("expected ErrDenied, got %v", err)
+			}
+			if len(denied) != 1 {
+				t.Errorf("expected one denial callback, got %v", denied)
+			}
+		})
+	}
+}
+
+func TestNewRejectsInvalidEntries(t *testing.T) {
+	for _, entry := range []string{"10.0.0.0/33", "api.*.gov", "host:443"} {
+		if _, err := New([]string{entry}, ""); err == nil {
+			t.Errorf("%s: expected an error", entry)
+		}
+	}
+	if _, err := New(nil, "socks5://proxy:1080"); err == nil || !strings.Contains(err.Error(), "proxy") {
+		t.Errorf("expected a proxy error, got %v", err)
+	}
+}
@@ -0,0 +1,361 @@
+// Package geoip provides a minimal reader for MaxMind DB (MMDB) files,
+// sufficient for country and ASN lookups without external dependencies.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// metadataMarker precedes the metadata section at the end of an MMDB file
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparator is the size of the zero padding between tree and data
+const dataSectionSeparator = 16
+
+// Reader reads an MMDB database held in memory
+type Reader struct {
+	buf          []byte
+	nodeCount    uint
+	recordSize   uint
+	ipVersion    uint
+	databaseType string
+	treeSize     uint
+	ipv4Start    uint
+}
+
+// Open loads an MMDB database from disk
+func Open(path string) (*Reader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MMDB file: %w", err)
+	}
+	return FromBytes(data)
+}
+
+// FromBytes parses an MMDB database from memory
+func FromBytes(buf []byte) (*Reader, error) {
+	idx := bytes.LastIndex(buf, metadataMarker)
+	if idx < 0 {
+		return nil, fmt.Errorf("invalid MMDB file: metadata marker not found")
+	}
+
+	metaStart := idx + len(metadataMarker)
+	d := decoder{buf: buf[metaStart:]}
+	raw, _, err := d.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MMDB metadata: %w", err)
+	}
+	meta, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid MMDB metadata: expected map")
+	}
+
+	r := &Reader{
+		buf:        buf,
+		nodeCount:  uint(toUint(meta["node_count"])),
+		recordSize: uint(toUint(meta["record_size"])),
+		ipVersion:  uint(toUint(meta["ip_version"])),
+	}
+	if dbType, ok := meta["database_type"].(string); ok {
+		r.databaseType = dbType
+	}
+
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported MMDB record size %d", r.recordSize)
+	}
+
+	r.treeSize = r.nodeCount * r.recordSize * 2 / 8
+	if r.treeSize+dataSectionSeparator > uint(idx) {
+		return nil, fmt.Errorf("invalid MMDB file: search tree exceeds file size")
+	}
+
+	// IPv4 addresses live under ::/96 in IPv6 databases
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readRecord(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// DatabaseType returns the database_type metadata field, e.g. "GeoLite2-ASN"
+func (r *Reader) DatabaseType() string {
+	return r.databaseType
+}
+
+// Lookup returns the decoded record for ip, or nil if the address is not in the database
+func (r *Reader) Lookup(ip net.IP) (map[string]interface{}, error) {
+	bits, node, err := r.startFor(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		bit := (bits[i/8] >> (7 - uint(i%8))) & 1
+		node = r.readRecord(node, uint(bit))
+	}
+
+	if node == r.nodeCount {
+		return nil, nil
+	}
+	if node < r.nodeCount {
+		return nil, fmt.Errorf("invalid MMDB file: search tree too deep")
+	}
+
+	offset := node - r.nodeCount - dataSectionSeparator
+	d := decoder{buf: r.buf[r.treeSize+dataSectionSeparator:]}
+	value, _, err := d.decode(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	record, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected MMDB record type %T", value)
+	}
+	return record, nil
+}
+
+// startFor returns the address bits and starting node for a lookup
+func (r *Reader) startFor(ip net.IP) ([]byte, uint, error) {
+	if ip4 := ip.To4(); ip4 != nil {
+		if r.ipVersion == 6 {
+			return ip4, r.ipv4Start, nil
+		}
+		return ip4, 0, nil
+	}
+	if r.ipVersion == 4 {
+		return nil, 0, fmt.Errorf("cannot look up IPv6 address in IPv4 database")
+	}
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return nil, 0, fmt.Errorf("invalid IP address")
+	}
+	return ip16, 0, nil
+}
+
+// readRecord reads the left (bit 0) or right (bit 1) record of a node
+func (r *Reader) readRecord(node, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		b := r.buf[off : off+3]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		off := node * 7
+		b := r.buf[off : off+7]
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		off := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(r.buf[off : off+4]))
+	}
+}
+
+// MMDB data section type identifiers
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// decoder decodes values from an MMDB data section
+type decoder struct {
+	buf []byte
+}
+
+// decode decodes the value at offset and returns it with the offset of the next value
+func (d *decoder) decode(offset uint) (interface{}, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("offset %d out of range", offset)
+	}
+
+	ctrl := d.buf[offset]
+	offset++
+	typeNum := uint(ctrl >> 5)
+
+	if typeNum == typePointer {
+		ptr, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(ptr)
+		return value, next, err
+	}
+
+	if typeNum == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, fmt.Errorf("truncated extended type")
+		}
+		typeNum = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size, offset, err := d.size(ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch typeNum {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			keyStr, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key is %T, not string", key)
+			}
+			value, next, err := d.decode(next)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[keyStr] = value
+			offset = next
+		}
+		return m, offset, nil
+
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	end := offset + size
+	if end > uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("value at %d exceeds data section", offset)
+	}
+	b := d.buf[offset:end]
+
+	switch typeNum {
+	case typeString:
+		return string(b), end, nil
+	case typeBytes:
+		return append([]byte(nil), b...), end, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), end, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), end, nil
+	case typeUint16, typeUint32, typeUint64, typeUint128:
+		if size > 8 {
+			// uint128 values larger than 64 bits are not needed for geo lookups
+			b = b[size-8:]
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, end, nil
+	case typeInt32:
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), end, nil
+	case typeContainer, typeEndMarker:
+		return nil, end, nil
+	}
+
+	return nil, 0, fmt.Errorf("unknown MMDB data type %d", typeNum)
+}
+
+// size decodes the payload size from the control byte and following bytes
+func (d *decoder) size(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1F)
+	if size < 29 {
+		return size, offset, nil
+	}
+
+	n := size - 28
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, fmt.Errorf("truncated size")
+	}
+	b := d.buf[offset : offset+n]
+
+	switch size {
+	case 29:
+		size = 29 + uint(b[0])
+	case 30:
+		size = 285 + (uint(b[0])<<8 | uint(b[1]))
+	default:
+		size = 65821 + (uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]))
+	}
+	return size, offset + n, nil
+}
+
+// pointer decodes a pointer and returns its target and the offset after it
+func (d *decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint((ctrl>>3)&0x3) + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, fmt.Errorf("truncated pointer")
+	}
+	b := d.buf[offset : offset+n]
+	vvv := uint(ctrl & 0x7)
+
+	var ptr uint
+	switch n {
+	case 1:
+		ptr = vvv<<8 | uint(b[0])
+	case 2:
+		ptr = (vvv<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+	case 3:
+		ptr = (vvv<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+	default:
+		ptr = uint(binary.BigEndian.Uint32(b))
+	}
+	return ptr, offset + n, nil
+}
+
+// toUint converts a decoded numeric value to uint64
+func toUint(v interface{}) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int64:
+		return uint64(n)
+	}
+	return 0
+}
@@ -0,0 +1,115 @@
+package geoip
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// encodeString encodes an MMDB UTF-8 string shorter than 285 bytes
+func encodeString(s string) []byte {
+	if len(s) < 29 {
+		return append([]byte{byte(typeString<<5 | len(s))}, s...)
+	}
+	return append([]byte{typeString<<5 | 29, byte(len(s) - 29)}, s...)
+}
+
+// encodeUint32 encodes an MMDB uint32
+func encodeUint32(v uint32) []byte {
+	return []byte{typeUint32<<5 | 4, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+// encodeUint16 encodes an MMDB uint16
+func encodeUint16(v uint16) []byte {
+	return []byte{typeUint16<<5 | 2, byte(v >> 8), byte(v)}
+}
+
+// encodeMap encodes an MMDB map from alternating key/value encodings
+func encodeMap(pairs ...[]byte) []byte {
+	out := []byte{byte(typeMap<<5 | len(pairs)/2)}
+	for _, p := range pairs {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// buildTestDB builds an IPv4 database with one node: addresses in 0.0.0.0/1
+// resolve to data, addresses in 128.0.0.0/1 are not found
+func buildTestDB(data []byte) []byte {
+	const nodeCount = 1
+	left := nodeCount + dataSectionSeparator // data offset 0
+
+	var buf bytes.Buffer
+	buf.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left)})
+	buf.Write([]byte{0, 0, nodeCount})
+	buf.Write(make([]byte, dataSectionSeparator))
+	buf.Write(data)
+	buf.Write(metadataMarker)
+	buf.Write(encodeMap(
+		encodeString("node_count"), encodeUint32(nodeCount),
+		encodeString("record_size"), encodeUint16(24),
+		encodeString("ip_version"), encodeUint16(4),
+		encodeString("database_type"), encodeString("Test"),
+	))
+	return buf.Bytes()
+}
+
+func TestReaderLookup(t *testing.T) {
+	db := buildTestDB(encodeMap(
+		encodeString("country"), encodeMap(encodeString("iso_code"), encodeString("US")),
+	))
+
+	reader, err := FromBytes(db)
+	if err != nil {
+		t.Fatalf("failed to parse database: %v", err)
+	}
+	if reader.DatabaseType() != "Test" {
+		t.Errorf("expected database type Test, got %q", reader.DatabaseType())
+	}
+
+	record, err := reader.Lookup(net.ParseIP("10.1.2.3"))
+	if err != nil {
+		t.Fatalf("lookup failed: %v", err)
+	}
+	if isoCode(record, "country") != "US" {
+		t.Errorf("expected country US, got %v", record)
+	}
+
+	record, err = reader.Lookup(net.ParseIP("200.1.2.3"))
+	if err != nil {
+		t.Fatalf("lookup failed: %v", err)
+	}
+	if record != nil {
+		t.Errorf("expected no record, got %v", record)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	country, _ := FromBytes(buildTestDB(encodeMap(
+		encodeString("country"), encodeMap(encodeString("iso_code"), encodeString("DE")),
+	)))
+	asn, _ := FromBytes(buildTestDB(encodeMap(
+		encodeString("autonomous_system_number"), encodeUint32(64500),
+		encodeString("autonomous_system_organization"), encodeString("Example"),
+	)))
+
+	resolver := NewResolverFromReaders(country, asn)
+
+	info := resolver.Resolve(net.ParseIP("10.0.0.1"))
+	if info == nil {
+		t.Fatal("expected info for address")
+	}
+	if info.Country != "DE" || info.ASN != 64500 || info.ASOrg != "Example" {
+		t.Errorf("unexpected info: %+v", info)
+	}
+
+	if info := resolver.Resolve(net.ParseIP("192.0.2.1")); info != nil {
+		t.Errorf("expected nil info for unknown address, got %+v", info)
+	}
+}
+
+func TestFromBytesInvalid(t *testing.T) {
+	if _, err := FromBytes([]byte("not a database")); err == nil {
+		t.Error("expected error for missing metadata marker")
+	}
+}
@@ -0,0 +1,89 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+)
+
+// Info holds the location and network annotations for an IP address
+type Info struct {
+	Country string `json:"country,omitempty"` // ISO 3166-1 alpha-2 code
+	ASN     uint   `json:"asn,omitempty"`
+	ASOrg   string `json:"as_org,omitempty"`
+}
+
+// Resolver combines a country database and an ASN database.
+// Either database may be omitted.
+type Resolver struct {
+	country *Reader
+	asn     *Reader
+}
+
+// NewResolver opens the country and ASN databases; empty paths are skipped
+func NewResolver(countryDB, asnDB string) (*Resolver, error) {
+	r := &Resolver{}
+
+	if countryDB != "" {
+		reader, err := Open(countryDB)
+		if err != nil {
+			return nil, fmt.Errorf("country database: %w", err)
+		}
+		r.country = reader
+	}
+
+	if asnDB != "" {
+		reader, err := Open(asnDB)
+		if err != nil {
+			return nil, fmt.Errorf("ASN database: %w", err)
+		}
+		r.asn = reader
+	}
+
+	if r.country == nil && r.asn == nil {
+		return nil, fmt.Errorf("at least one GeoIP database is required")
+	}
+
+	return r, nil
+}
+
+// NewResolverFromReaders creates a resolver from already opened databases
+func NewResolverFromReaders(country, asn *Reader) *Resolver {
+	return &Resolver{country: country, asn: asn}
+}
+
+// Resolve looks up ip in the configured databases.
+// It returns nil if nothing is known about the address.
+func (r *Resolver) Resolve(ip net.IP) *Info {
+	info := &Info{}
+
+	if r.country != nil {
+		if record, err := r.country.Lookup(ip); err == nil && record != nil {
+			info.Country = isoCode(record, "country")
+			if info.Country == "" {
+				info.Country = isoCode(record, "registered_country")
+			}
+		}
+	}
+
+	if r.asn != nil {
+		if record, err := r.asn.Lookup(ip); err == nil && record != nil {
+			info.ASN = uint(toUint(record["autonomous_system_number"]))
+			info.ASOrg, _ = record["autonomous_system_organization"].(string)
+		}
+	}
+
+	if *info == (Info{}) {
+		return nil
+	}
+	return info
+}
+
+// isoCode extracts record[key].iso_code
+func isoCode(record map[string]interface{}, key string) string {
+	section, ok := record[key].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	code, _ := section["iso_code"].(string)
+	return code
+}
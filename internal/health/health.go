@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 )
@@ -20,33 +21,57 @@ const (
 // CheckFunc is a function that performs a health check
 type CheckFunc func(ctx context.Context) error
 
+// DefaultGroup holds checks registered without a group
+const DefaultGroup = "default"
+
 // Check represents a single health check
 type Check struct {
 	Name     string
 	Checker  CheckFunc
-	Critical bool // If true, failure marks overall status as unhealthy
+	Critical bool    // If true, failure marks the check's group as unhealthy
+	Group    string  // Dependency group, DefaultGroup if unset
+	Weight   float64 // Contribution to the group's readiness score
+}
+
+// Group represents a set of related checks (e.g. storage, identity, policy)
+type Group struct {
+	Name     string
+	Critical bool    // If true, an unhealthy group marks overall status as unhealthy
+	Weight   float64 // Contribution to the overall readiness score
 }
 
 // Response represents a health check response
 type Response struct {
-	Status    Status              `json:"status"`
-	Timestamp string              `json:"timestamp"`
-	Service   string              `json:"service"`
-	Version   string              `json:"version"`
+	Status    Status                 `json:"status"`
+	Timestamp string                 `json:"timestamp"`
+	Service   string                 `json:"service"`
+	Version   string                 `json:"version"`
+	Score     *float64               `json:"score,omitempty"` // Weighted readiness in [0, 1]
+	Degraded  []string               `json:"degraded,omitempty"` // Failing checks the service is running without
 	Checks    map[string]CheckResult `json:"checks,omitempty"`
+	Groups    map[string]GroupResult `json:"groups,omitempty"`
 }
 
 // CheckResult represents the result of a single check
 type CheckResult struct {
-	Status    Status `json:"status"`
-	Message   string `json:"message,omitempty"`
-	Duration  string `json:"duration"`
+	Status   Status `json:"status"`
+	Message  string `json:"message,omitempty"`
+	Duration string `json:"duration"`
+	Group    string `json:"group,omitempty"`
+}
+
+// GroupResult represents the aggregated result of a dependency group
+type GroupResult struct {
+	Status   Status  `json:"status"`
+	Critical bool    `json:"critical"`
+	Score    float64 `json:"score"`
 }
 
 // Checker manages health checks
 type Checker struct {
 	mu          sync.RWMutex
 	checks      map[string]Check
+	groups      map[string]Group
 	serviceName string
 	serviceVer  string
 }
@@ -54,24 +79,60 @@ type Checker struct {
 // New creates a new health checker
 func New(serviceName, serviceVersion string) *Checker {
 	return &Checker{
-		checks:      make(map[string]Check),
+		checks: make(map[string]Check),
+		groups: map[string]Group{
+			DefaultGroup: {Name: DefaultGroup, Critical: true, Weight: 1},
+		},
 		serviceName: serviceName,
 		serviceVer:  serviceVersion,
 	}
 }
 
-// RegisterCheck adds a health check
-func (c *Checker) RegisterCheck(name string, checker CheckFunc, critical bool) {
+// RegisterGroup declares a dependency group with its criticality and readiness weight
+func (c *Checker) RegisterGroup(name string, critical bool, weight float64) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if weight <= 0 {
+		weight = 1
+	}
+	c.groups[name] = Group{
+		Name:     name,
+		Critical: critical,
+		Weight:   weight,
+	}
+}
+
+// RegisterGroupCheck adds a health check to a dependency group.
+// Groups that were not declared with RegisterGroup are non-critical with weight 1.
+func (c *Checker) RegisterGroupCheck(group, name string, checker CheckFunc, critical bool, weight float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if group == "" {
+		group = DefaultGroup
+	}
+	if weight <= 0 {
+		weight = 1
+	}
+	if _, ok := c.groups[group]; !ok {
+		c.groups[group] = Group{Name: group, Weight: 1}
+	}
+
 	c.checks[name] = Check{
 		Name:     name,
 		Checker:  checker,
 		Critical: critical,
+		Group:    group,
+		Weight:   weight,
 	}
 }
 
+// RegisterCheck adds a health check to the default group
+func (c *Checker) RegisterCheck(name string, checker CheckFunc, critical bool) {
+	c.RegisterGroupCheck(DefaultGroup, name, checker, critical, 1)
+}
+
 // RunChecks executes all registered health checks
 func (c *Checker) RunChecks(ctx context.Context) Response {
 	c.mu.RLock()
@@ -79,6 +140,10 @@ func (c *Checker) RunChecks(ctx context.Context) Response {
 	for k, v := range c.checks {
 		checks[k] = v
 	}
+	groups := make(map[string]Group, len(c.groups))
+	for k, v := range c.groups {
+		groups[k] = v
+	}
 	c.mu.RUnlock()
 
 	response := Response{
@@ -126,8 +191,13 @@ func (c *Checker) RunChecks(ctx context.Context) Response {
 	}()
 
 	// Collect results
-	hasDegraded := false
-	hasUnhealthy := false
+	type groupTally struct {
+		healthyWeight float64
+		totalWeight   float64
+		unhealthy     bool
+		degraded      bool
+	}
+	tallies := make(map[string]*groupTally)
 
 	for res := range resultCh {
 		check := checks[res.name]
@@ -135,24 +205,82 @@ func (c *Checker) RunChecks(ctx context.Context) Response {
 		checkResult := CheckResult{
 			Status:   StatusHealthy,
 			Duration: res.duration.String(),
+			Group:    check.Group,
 		}
 
+		tally, ok := tallies[check.Group]
+		if !ok {
+			tally = &groupTally{}
+			tallies[check.Group] = tally
+		}
+		tally.totalWeight += check.Weight
+
 		if res.err != nil {
 			checkResult.Message = res.err.Error()
 
 			if check.Critical {
 				checkResult.Status = StatusUnhealthy
-				hasUnhealthy = true
+				tally.unhealthy = true
 			} else {
 				checkResult.Status = StatusDegraded
-				hasDegraded = true
+				tally.degraded = true
 			}
+		} else {
+			tally.healthyWeight += check.Weight
 		}
 
 		response.Checks[res.name] = checkResult
 	}
 
-	// Determine overall status
+	// Aggregate groups and determine overall status
+	hasDegraded := false
+	hasUnhealthy := false
+	var scoreSum, weightSum float64
+
+	if len(tallies) > 0 {
+		response.Groups = make(map[string]GroupResult, len(tallies))
+	}
+
+	for name, tally := range tallies {
+		group := groups[name]
+
+		groupResult := GroupResult{
+			Status:   StatusHealthy,
+			Critical: group.Critical,
+			Score:    tally.healthyWeight / tally.totalWeight,
+		}
+
+		if tally.unhealthy {
+			groupResult.Status = StatusUnhealthy
+			if group.Critical {
+				hasUnhealthy = true
+			} else {
+				hasDegraded = true
+			}
+		} else if tally.degraded {
+			groupResult.Status = StatusDegraded
+			hasDegraded = true
+		}
+
+		response.Groups[name] = groupResult
+		scoreSum += groupResult.Score * group.Weight
+		weightSum += group.Weight
+	}
+
+	// Failures that do not make the service unhealthy mean it runs in degraded mode
+	for name, result := range response.Checks {
+		if result.Status == StatusDegraded || (result.Status == StatusUnhealthy && !groups[result.Group].Critical) {
+			response.Degraded = append(response.Degraded, name)
+		}
+	}
+	sort.Strings(response.Degraded)
+
+	score := 1.0
+	if weightSum > 0 {
+		score = scoreSum / weightSum
+	}
+	response.Score = &score
+
 	if hasUnhealthy {
 		response.Status = StatusUnhealthy
 	} else if hasDegraded {
@@ -110,6 +110,10 @@ func TestRunChecks_NonCriticalFailure(t *testing.T) {
 	if checkResult.Status != StatusDegraded {
 		t.Errorf("expected check status degraded, got %s", checkResult.Status)
 	}
+
+	if len(response.Degraded) != 1 || response.Degraded[0] != "non-critical-check" {
+		t.Errorf("expected degraded list [non-critical-check], got %v", response.Degraded)
+	}
 }
 
 func TestRunChecks_MixedFailures(t *testing.T) {
@@ -213,3 +217,53 @@ func TestMinIOCheck_Disabled(t *testing.T) {
 		t.Errorf("expected no error when disabled, got %v", err)
 	}
 }
+
+func TestRunChecks_Groups(t *testing.T) {
+	checker := New("test", "1.0.0")
+
+	checker.RegisterGroup("policy", true, 3)
+	checker.RegisterGroup("audit", false, 1)
+
+	checker.RegisterGroupCheck("policy", "policy-engine", func(ctx context.Context) error {
+		return nil
+	}, true, 1)
+	checker.RegisterGroupCheck("audit", "audit-sink", func(ctx context.Context) error {
+		return errors.New("sink unreachable")
+	}, true, 1)
+
+	response := checker.RunChecks(context.Background())
+
+	// A failed critical check in a non-critical group degrades rather than fails readiness
+	if response.Status != StatusDegraded {
+		t.Errorf("expected status degraded, got %s", response.Status)
+	}
+
+	if response.Groups["audit"].Status != StatusUnhealthy {
+		t.Errorf("expected audit group unhealthy, got %s", response.Groups["audit"].Status)
+	}
+	if response.Groups["policy"].Status != StatusHealthy {
+		t.Errorf("expected policy group healthy, got %s", response.Groups["policy"].Status)
+	}
+
+	if response.Score == nil || *response.Score != 0.75 {
+		t.Errorf("expected weighted score 0.75, got %v", response.Score)
+	}
+}
+
+func TestRunChecks_CriticalGroupFailure(t *testing.T) {
+	checker := New("test", "1.0.0")
+
+	checker.RegisterGroup("identity", true, 1)
+	checker.RegisterGroupCheck("identity", "idp", func(ctx context.Context) error {
+		return errors.New("idp down")
+	}, true, 1)
+
+	response := checker.RunChecks(context.Background())
+
+	if response.Status != StatusUnhealthy {
+		t.Errorf("expected status unhealthy, got %s", response.Status)
+	}
+	if response.Score == nil || *response.Score != 0 {
+		t.Errorf("expected score 0, got %v", response.Score)
+	}
+}
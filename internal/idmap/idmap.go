@@ -0,0 +1,297 @@
+// Package idmap maps attributes asserted by an external identity system (groups,
+// token claims and client certificate subjects) to DSMIL clearances and layers, so
+// identity providers that know nothing about clearances can still drive policy.
+package idmap
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// Attribute kinds a rule can match
+const (
+	KindGroup = "group" // A group from the groups header or the groups claim
+	KindClaim = "claim" // A claim as name=value; array claims yield one attribute per element
+	KindOU    = "ou"    // An organizational unit of the verified client certificate's subject
+	KindCN    = "cn"    // The verified client certificate's common name
+)
+
+// Attribute sources, for identity providers that read only some of them
+const (
+	SourceGroups     = "groups"      // Config.GroupsHeader
+	SourceClaims     = "claims"      // Config.ClaimsHeader, including groups from the groups claim
+	SourceClientCert = "client_cert" // Config.ClientCert
+)
+
+// DefaultGroupsClaim is the claim read as groups when Config.GroupsClaim is empty
+const DefaultGroupsClaim = "groups"
+
+// Rule maps requests with a matching attribute to a clearance level, a layer or both.
+// Match is "<kind>:<pattern>", where the pattern is a path.Match glob, e.g.
+// "group:dsmil-ops-*", "ou:Transport Engineering" or "claim:department=cyber".
+type Rule struct {
+	Match          string
+	ClearanceLevel int    // 2-9; 0 leaves the clearance to later rules
+	Layer          string // Empty leaves the layer to later rules
+}
+
+// Config says where attributes are read from and how they are mapped. The headers must
+// be set by an authenticating proxy that strips any values sent by clients.
+type Config struct {
+	GroupsHeader string // Comma-separated groups, e.g. X-Forwarded-Groups
+	ClaimsHeader string // A JWT (optionally "Bearer "-prefixed) or base64url JSON object of verified claims
+	GroupsClaim  string // Claim holding groups; empty uses DefaultGroupsClaim
+	ClientCert   bool   // Read OU and CN from the client certificate the TLS handshake verified
+
+	// Mapped values replace X-Clearance and X-Layer. Authoritative ignores those headers
+	// even when no rule matches, so only the identity system can grant clearance.
+	Authoritative bool
+
+	Rules []Rule // Tried in order; the first rule setting a clearance or layer decides it
+}
+
+// Result is the clearance and layer mapped from a request's attributes
+type Result struct {
+	Clearance models.Clearance // 0 if no matching rule set one
+	Layer     models.Layer     // Empty if no matching rule set one
+	Rules     []string         // Match of every rule that contributed
+}
+
+// Mapper applies a Config to requests
+type Mapper struct {
+	config Config
+	rules  []rule
+}
+
+// rule is a Rule with its match split
+type rule struct {
+	Rule
+	kind    string
+	pattern string
+}
+
+// validLayers are the layers a rule may assign
+var validLayers = map[models.Layer]bool{
+	models.LayerData:        true,
+	models.LayerTransport:   true,
+	models.LayerControl:     true,
+	models.LayerApplication: true,
+}
+
+// New validates config and creates a mapper
+func New(config Config) (*Mapper, error) {
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = DefaultGroupsClaim
+	}
+	if config.GroupsHeader == "" && config.ClaimsHeader == "" && !config.ClientCert {
+		return nil, fmt.Errorf("identity mapping has no attribute source: set a groups header, a claims header or client certificates")
+	}
+
+	m := &Mapper{config: config}
+	for i, r := range config.Rules {
+		kind, pattern, ok := strings.Cut(r.Match, ":")
+		switch {
+		case !ok || pattern == "":
+			return nil, fmt.Errorf("identity mapping rule %d: match %q must be <kind>:<pattern>", i, r.Match)
+		case kind != KindGroup && kind != KindClaim && kind != KindOU && kind != KindCN:
+			return nil, fmt.Errorf("identity mapping rule %d: unknown attribute kind %q", i, kind)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("identity mapping rule %d: invalid pattern %q", i, pattern)
+		}
+		if r.ClearanceLevel == 0 && r.Layer == "" {
+			return nil, fmt.Errorf("identity mapping rule %d (%s) sets neither a clearance level nor a layer", i, r.Match)
+		}
+		if r.ClearanceLevel != 0 && !models.ValidateClearance(levelClearance(r.ClearanceLevel)) {
+			return nil, fmt.Errorf("identity mapping rule %d (%s): clearance level must be 2-9, got %d", i, r.Match, r.ClearanceLevel)
+		}
+		if r.Layer != "" && !validLayers[models.Layer(r.Layer)] {
+			return nil, fmt.Errorf("identity mapping rule %d (%s): invalid layer %q", i, r.Match, r.Layer)
+		}
+		m.rules = append(m.rules, rule{Rule: r, kind: kind, pattern: pattern})
+	}
+	return m, nil
+}
+
+// Authoritative reports whether X-Clearance and X-Layer are ignored when no rule matches
+func (m *Mapper) Authoritative() bool {
+	return m.config.Authoritative
+}
+
+// levelClearance converts a level to its repeating-byte clearance, e.g. 5 to 0x05050505
+func levelClearance(level int) models.Clearance {
+	return models.Clearance(uint32(level) * 0x01010101)
+}
+
+// Attributes returns a request's attributes as sorted "<kind>:<value>" strings.
+// A claims header that cannot be decoded contributes nothing.
+func (m *Mapper) Attributes(r *http.Request) []string {
+	return m.SourceAttributes(r)
+}
+
+// SourceAttributes is Attributes limited to the given Source* sources; with none it
+// reads them all
+func (m *Mapper) SourceAttributes(r *http.Request, sources ...string) []string {
+	from := func(source string) bool {
+		if len(sources) == 0 {
+			return true
+		}
+		for _, s := range sources {
+			if s == source {
+				return true
+			}
+		}
+		return false
+	}
+
+	seen := make(map[string]bool)
+	add := func(kind, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			seen[kind+":"+value] = true
+		}
+	}
+
+	if m.config.GroupsHeader != "" && from(SourceGroups) {
+		for _, value := range r.Header.Values(m.config.GroupsHeader) {
+			for _, group := range strings.Split(value, ",") {
+				add(KindGroup, group)
+			}
+		}
+	}
+	if m.config.ClaimsHeader != "" && from(SourceClaims) {
+		for name, value := range decodeClaims(r.Header.Get(m.config.ClaimsHeader)) {
+			values, isList := value.([]interface{})
+			if !isList {
+				values = []interface{}{value}
+			}
+			for _, v := range values {
+				var s string
+				switch v := v.(type) {
+				case string:
+					s = v
+				case float64, bool:
+					s = fmt.Sprint(v)
+				default:
+					continue
+				}
+				add(KindClaim, name+"="+s)
+				if name == m.config.GroupsClaim {
+					add(KindGroup, s)
+				}
+			}
+		}
+	}
+	if m.config.ClientCert && from(SourceClientCert) && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		subject := r.TLS.VerifiedChains[0][0].Subject
+		for _, ou := range subject.OrganizationalUnit {
+			add(KindOU, ou)
+		}
+		add(KindCN, subject.CommonName)
+	}
+
+	attrs := make([]string, 0, len(seen))
+	for attr := range seen {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+	return attrs
+}
+
+// decodeClaims returns the claims in a JWT's payload or a base64url JSON object,
+// or nil. Signatures are not checked: the proxy setting the header verified them.
+func decodeClaims(value string) map[string]interface{} {
+	value = strings.TrimSpace(strings.TrimPrefix(value, "Bearer "))
+	if parts := strings.Split(value, "."); len(parts) == 3 {
+		value = parts[1]
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil
+	}
+	var claims map[string]interface{}
+	if json.Unmarshal(data, &claims) != nil {
+		return nil
+	}
+	return claims
+}
+
+// Map applies the rules to attributes. It reports false when no rule matched.
+func (m *Mapper) Map(attrs []string) (Result, bool) {
+	var result Result
+	for _, rule := range m.rules {
+		if !rule.matches(attrs) {
+			continue
+		}
+		contributed := false
+		if result.Clearance == 0 && rule.ClearanceLevel != 0 {
+			result.Clearance = levelClearance(rule.ClearanceLevel)
+			contributed = true
+		}
+		if result.Layer == "" && rule.Layer != "" {
+			result.Layer = models.Layer(rule.Layer)
+			contributed = true
+		}
+		if contributed {
+			result.Rules = append(result.Rules, rule.Match)
+		}
+		if result.Clearance != 0 && result.Layer != "" {
+			break
+		}
+	}
+	return result, len(result.Rules) > 0
+}
+
+// Resolve maps a request's attributes
+func (m *Mapper) Resolve(r *http.Request) (Result, bool) {
+	return m.Map(m.Attributes(r))
+}
+
+// ResolveSources maps a request's attributes from the given Source* sources
+func (m *Mapper) ResolveSources(r *http.Request, sources ...string) (Result, bool) {
+	return m.Map(m.SourceAttributes(r, sources...))
+}
+
+// Headers returns the configured request headers identity attributes are read from
+func (m *Mapper) Headers() []string {
+	var headers []string
+	for _, header := range []string{m.config.GroupsHeader, m.config.ClaimsHeader} {
+		if header != "" {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
+
+// HasSource reports whether the mapper is configured to read a Source* source
+func (m *Mapper) HasSource(source string) bool {
+	switch source {
+	case SourceGroups:
+		return m.config.GroupsHeader != ""
+	case SourceClaims:
+		return m.config.ClaimsHeader != ""
+	case SourceClientCert:
+		return m.config.ClientCert
+	}
+	return false
+}
+
+// matches reports whether any attribute of the rule's kind matches its pattern
+func (r rule) matches(attrs []string) bool {
+	for _, attr := range attrs {
+		kind, value, _ := strings.Cut(attr, ":")
+		if kind != r.kind {
+			continue
+		}
+		if ok, _ := path.Match(r.pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package idmap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+func TestResolve(t *testing.T) {
+	m, err := New(Config{
+		GroupsHeader: "X-Forwarded-Groups",
+		ClaimsHeader: "X-Forwarded-Access-Token",
+		ClientCert:   true,
+		Rules: []Rule{
+			{Match: "group:dsmil-admins", ClearanceLevel: 9},
+			{Match: "ou:Transport*", Layer: "transport"},
+			{Match: "claim:department=cyber", ClearanceLevel: 5, Layer: "control"},
+			{Match: "group:staff", ClearanceLevel: 2},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"jdoe","department":"cyber","groups":["staff","dsmil-admins"]}`))
+	r := httptest.NewRequest("GET", "/api/restricted", nil)
+	r.Header.Set("X-Forwarded-Access-Token", "Bearer eyJhbGciOiJSUzI1NiJ9."+payload+".c2lnbmF0dXJl")
+	r.Header.Set("X-Forwarded-Groups", "ops, staff")
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+		{Subject: pkix.Name{CommonName: "gw-7", OrganizationalUnit: []string{"Transport Engineering"}}},
+	}}}
+
+	attrs := strings.Join(m.Attributes(r), ",")
+	for _, want := range []string{"claim:sub=jdoe", "cn:gw-7", "group:dsmil-admins", "group:ops", "ou:Transport Engineering"} {
+		if !strings.Contains(attrs, want) {
+			t.Errorf("attributes %s lack %s", attrs, want)
+		}
+	}
+
+	result, ok := m.Resolve(r)
+	if !ok || result.Clearance != models.ClearanceLevel9 || result.Layer != models.LayerTransport {
+		t.Fatalf("expected level 9 on the transport layer, got %+v", result)
+	}
+	if strings.Join(result.Rules, ",") != "group:dsmil-admins,ou:Transport*" {
+		t.Errorf("unexpected contributing rules %v", result.Rules)
+	}
+
+	// Limited to the certificate, only the OU rule applies
+	if result, ok := m.ResolveSources(r, SourceClientCert); !ok || result.Clearance != 0 || result.Layer != models.LayerTransport {
+		t.Errorf("expected only the transport layer from the certificate, got %+v", result)
+	}
+
+	// Without the admin group and the certificate, the claim rule decides both
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Forwarded-Access-Token", base64.RawURLEncoding.EncodeToString([]byte(`{"department":"cyber"}`)))
+	if result, ok := m.Resolve(r); !ok || result.Clearance != models.ClearanceLevel5 || result.Layer != models.LayerControl {
+		t.Errorf("expected level 5 on the control layer, got %+v", result)
+	}
+
+	r.Header.Set("X-Forwarded-Access-Token", "not a token")
+	if _, ok := m.Resolve(r); ok {
+		t.Error("expected no mapping for an undecodable claims header")
+	}
+}
+
+func TestNewRejectsInvalidRules(t *testing.T) {
+	for name, rule := range map[string]Rule{
+		"no kind":       {Match: "dsmil-admins", ClearanceLevel: 5},
+		"unknown kind":  {Match: "role:admin", ClearanceLevel: 5},
+		"bad pattern":   {Match: "group:[ops", ClearanceLevel: 5},
+		"nothing set":   {Match: "group:ops"},
+		"level too low": {Match: "group:ops", ClearanceLevel: 1},
+		"bad layer":     {Match: "group:ops", Layer: "physical"},
+	} {
+		if _, err := New(Config{GroupsHeader: "X-Forwarded-Groups", Rules: []Rule{rule}}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := New(Config{Rules: []Rule{{Match: "group:ops", ClearanceLevel: 3}}}); err == nil {
+		t.Error("expected an error without attribute sources")
+	}
+}
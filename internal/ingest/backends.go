@@ -0,0 +1,201 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/internal/redis"
+	"github.com/NSACodeGov/CodeGov/internal/sigv4"
+)
+
+// MinIOBackend writes each record as an object under
+// <prefix><token>/<yyyy-mm-dd>/<unix-nanos>-<id>, with the labels as object metadata
+type MinIOBackend struct {
+	Endpoint  string // host:port
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	Prefix    string
+	UseSSL    bool
+	Region    string       // Defaults to us-east-1
+	Client    *http.Client // Defaults to a client with a 10s timeout
+}
+
+// Name identifies the backend in responses and logs
+func (b *MinIOBackend) Name() string { return "minio" }
+
+// ObjectKey is the key a record is stored under
+func (b *MinIOBackend) ObjectKey(record *Record) string {
+	return fmt.Sprintf("%s0x%04X/%s/%d-%s", b.Prefix, record.Token,
+		record.ReceivedAt.Format("2006-01-02"), record.ReceivedAt.UnixNano(), record.ID)
+}
+
+// Store uploads the record with a SigV4-signed PUT
+func (b *MinIOBackend) Store(ctx context.Context, record *Record) error {
+	scheme := "http"
+	if b.UseSSL {
+		scheme = "https"
+	}
+	url := scheme + "://" + b.Endpoint + "/" + sigv4.URIEncode(b.Bucket, false) + "/" + sigv4.URIEncode(b.ObjectKey(record), false)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(record.Data))
+	if err != nil {
+		return err
+	}
+	contentType := record.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range record.Labels() {
+		req.Header.Set("X-Amz-Meta-"+name, value)
+	}
+	if b.AccessKey != "" {
+		creds := sigv4.Credentials{AccessKey: b.AccessKey, SecretKey: b.SecretKey, Region: b.Region}
+		creds.Sign(req, sigv4.PayloadHash(record.Data), time.Now())
+	}
+
+	client := b.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("PUT %s: %s: %s", b.ObjectKey(record), resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// DefaultStreamPrefix and DefaultStreamMaxLen apply when RedisBackend leaves them empty
+const (
+	DefaultStreamPrefix = "gogovcode:data:"
+	DefaultStreamMaxLen = 10000
+)
+
+// RedisBackend appends each record to a stream per DATA token, <prefix><token>,
+// with the labels and the payload as fields. Streams are trimmed to about MaxLen entries.
+type RedisBackend struct {
+	Endpoint string
+	Password string
+	Prefix   string
+	MaxLen   int
+
+	// Monitor, if set, makes Store and Read fail at once with redis.ErrUnavailable
+	// while Redis is down, instead of each request waiting on a dial timeout
+	Monitor *redis.Monitor
+}
+
+// Name identifies the backend in responses and logs
+func (b *RedisBackend) Name() string { return "redis" }
+
+// StreamKey is the stream a record is appended to
+func (b *RedisBackend) StreamKey(record *Record) string {
+	prefix := b.Prefix
+	if prefix == "" {
+		prefix = DefaultStreamPrefix
+	}
+	return fmt.Sprintf("%s0x%04X", prefix, record.Token)
+}
+
+// available reports whether Redis is worth dialling
+func (b *RedisBackend) available() error {
+	if b.Monitor != nil && !b.Monitor.Available() {
+		return redis.ErrUnavailable
+	}
+	return nil
+}
+
+// Store appends the record with XADD
+func (b *RedisBackend) Store(ctx context.Context, record *Record) error {
+	if err := b.available(); err != nil {
+		return err
+	}
+	maxLen := b.MaxLen
+	if maxLen <= 0 {
+		maxLen = DefaultStreamMaxLen
+	}
+
+	labels := record.Labels()
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := []string{"XADD", b.StreamKey(record), "MAXLEN", "~", strconv.Itoa(maxLen), "*"}
+	for _, name := range names {
+		args = append(args, name, labels[name])
+	}
+	args = append(args, "payload", string(record.Data))
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	_, err := redis.Do(ctx, b.Endpoint, b.Password, args...)
+	return err
+}
+
+// Read implements Reader with XREVRANGE over the token's stream. Entry IDs carry the
+// time Redis appended them, so Since and Until bound the range and are then applied
+// to each record's received_at label.
+func (b *RedisBackend) Read(ctx context.Context, q Query) ([]*Record, error) {
+	if err := b.available(); err != nil {
+		return nil, err
+	}
+	start, end := "-", "+"
+	if !q.Since.IsZero() {
+		start = strconv.FormatInt(q.Since.UnixMilli(), 10)
+	}
+	if !q.Until.IsZero() {
+		end = strconv.FormatInt(q.Until.UnixMilli(), 10)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	key := b.StreamKey(&Record{Token: q.Token})
+	reply, err := redis.DoReply(ctx, b.Endpoint, b.Password, "XREVRANGE", key, end, start, "COUNT", strconv.Itoa(q.Limit))
+	if err != nil {
+		return nil, err
+	}
+
+	entries, _ := reply.([]interface{})
+	records := make([]*Record, 0, len(entries))
+	for _, e := range entries {
+		entry, ok := e.([]interface{})
+		if !ok || len(entry) != 2 {
+			return nil, fmt.Errorf("%s: malformed stream entry", key)
+		}
+		fields, _ := entry[1].([]interface{})
+		labels := make(map[string]string, len(fields)/2)
+		var payload string
+		for n := 0; n+1 < len(fields); n += 2 {
+			name, _ := fields[n].(string)
+			value, _ := fields[n+1].(string)
+			if name == "payload" {
+				payload = value
+			} else {
+				labels[name] = value
+			}
+		}
+		record, err := ParseRecord(labels, []byte(payload))
+		if err != nil {
+			return nil, fmt.Errorf("%s entry %v: %w", key, entry[0], err)
+		}
+		if (!q.Since.IsZero() && record.ReceivedAt.Before(q.Since)) || (!q.Until.IsZero() && record.ReceivedAt.After(q.Until)) {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
@@ -0,0 +1,183 @@
+// Package ingest stores telemetry that devices submit under their DATA token. Each
+// record is labelled with the submitting device's layer and clearance and written
+// to every configured backend (MinIO objects and/or Redis streams), within
+// per-device size and frequency quotas. Consumers read records back from backends
+// that support queries, subject to the records' labels.
+package ingest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// Quota defaults
+const (
+	DefaultMaxBytes    = 64 << 10 // Largest payload accepted
+	DefaultMaxRequests = 60       // Submissions per device per window
+	DefaultWindow      = time.Minute
+)
+
+// Record is one telemetry submission
+type Record struct {
+	ID          string
+	DeviceID    uint16
+	Token       uint16 // The device's DATA token
+	Layer       models.Layer
+	Class       models.DeviceClass
+	Clearance   models.Clearance
+	ContentType string
+	ReceivedAt  time.Time
+	Data        []byte
+}
+
+// NewRecord creates a record for a device's payload with a random ID
+func NewRecord(device *models.Device, contentType string, data []byte) *Record {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return &Record{
+		ID:          hex.EncodeToString(id),
+		DeviceID:    device.ID,
+		Token:       device.GetDataToken(),
+		Layer:       device.Layer,
+		Class:       device.Class,
+		Clearance:   device.Clearance,
+		ContentType: contentType,
+		ReceivedAt:  time.Now().UTC(),
+		Data:        data,
+	}
+}
+
+// Labels describes the record for storage metadata, so consumers can filter by
+// layer and clearance without reading payloads
+func (r *Record) Labels() map[string]string {
+	return map[string]string{
+		"record_id":    r.ID,
+		"device_id":    strconv.Itoa(int(r.DeviceID)),
+		"token":        fmt.Sprintf("0x%04X", r.Token),
+		"layer":        string(r.Layer),
+		"class":        string(r.Class),
+		"clearance":    fmt.Sprintf("%08X", uint32(r.Clearance)),
+		"content_type": r.ContentType,
+		"received_at":  r.ReceivedAt.Format(time.RFC3339Nano),
+	}
+}
+
+// Backend stores records
+type Backend interface {
+	Name() string
+	Store(ctx context.Context, record *Record) error
+}
+
+// Quota bounds what one device may submit; zero values use the defaults
+type Quota struct {
+	MaxBytes    int64
+	MaxRequests int
+	Window      time.Duration
+}
+
+// ErrQuota is returned by Allow when a device has used its submissions for the window
+var ErrQuota = errors.New("device data quota exceeded")
+
+// Ingester applies quotas and writes records to its backends
+type Ingester struct {
+	quota    Quota
+	backends []Backend
+
+	mu      sync.Mutex
+	windows map[uint16]*window
+	now     func() time.Time
+}
+
+// window counts one device's submissions since start
+type window struct {
+	start time.Time
+	count int
+}
+
+// New creates an ingester writing to backends
+func New(quota Quota, backends ...Backend) *Ingester {
+	if quota.MaxBytes <= 0 {
+		quota.MaxBytes = DefaultMaxBytes
+	}
+	if quota.MaxRequests <= 0 {
+		quota.MaxRequests = DefaultMaxRequests
+	}
+	if quota.Window <= 0 {
+		quota.Window = DefaultWindow
+	}
+	return &Ingester{
+		quota:    quota,
+		backends: backends,
+		windows:  make(map[uint16]*window),
+		now:      time.Now,
+	}
+}
+
+// Quota returns the quota in effect, with defaults applied
+func (i *Ingester) Quota() Quota {
+	return i.quota
+}
+
+// Backends returns the names of the configured backends
+func (i *Ingester) Backends() []string {
+	names := make([]string, len(i.backends))
+	for n, b := range i.backends {
+		names[n] = b.Name()
+	}
+	return names
+}
+
+// Allow counts a submission against a device's quota. When the quota is used up it
+// returns ErrQuota and how long until the window resets.
+func (i *Ingester) Allow(deviceID uint16) (time.Duration, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := i.now()
+	w, ok := i.windows[deviceID]
+	if !ok || now.Sub(w.start) >= i.quota.Window {
+		if len(i.windows) >= 1024 {
+			i.prune(now)
+		}
+		w = &window{start: now}
+		i.windows[deviceID] = w
+	}
+	if w.count >= i.quota.MaxRequests {
+		return w.start.Add(i.quota.Window).Sub(now), ErrQuota
+	}
+	w.count++
+	return 0, nil
+}
+
+// prune drops expired windows; it must be called with i.mu held
+func (i *Ingester) prune(now time.Time) {
+	for id, w := range i.windows {
+		if now.Sub(w.start) >= i.quota.Window {
+			delete(i.windows, id)
+		}
+	}
+}
+
+// Store writes a record to every backend, returning the names of those that stored it.
+// A record that failed on some backends may be submitted again; consumers can drop
+// the duplicates by record ID.
+func (i *Ingester) Store(ctx context.Context, record *Record) ([]string, error) {
+	var stored []string
+	var errs []error
+	for _, backend := range i.backends {
+		if err := backend.Store(ctx, record); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", backend.Name(), err))
+			continue
+		}
+		stored = append(stored, backend.Name())
+	}
+	return stored, errors.Join(errs...)
+}
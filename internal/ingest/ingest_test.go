@@ -0,0 +1,209 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/internal/redis"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+func testDevice() *models.Device {
+	return &models.Device{ID: 1, Layer: models.LayerData, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel3}
+}
+
+func TestAllowQuota(t *testing.T) {
+	ingester := New(Quota{MaxRequests: 2, Window: time.Minute})
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	ingester.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, err := ingester.Allow(1); err != nil {
+			t.Fatalf("submission %d rejected: %v", i, err)
+		}
+	}
+	now = now.Add(20 * time.Second)
+	wait, err := ingester.Allow(1)
+	if !errors.Is(err, ErrQuota) || wait != 40*time.Second {
+		t.Fatalf("expected the quota to be exhausted for 40s, got %v, %v", wait, err)
+	}
+	if _, err := ingester.Allow(2); err != nil {
+		t.Errorf("quotas are per device: %v", err)
+	}
+
+	now = now.Add(40 * time.Second)
+	if _, err := ingester.Allow(1); err != nil {
+		t.Errorf("expected a new window: %v", err)
+	}
+	if q := ingester.Quota(); q.MaxBytes != DefaultMaxBytes {
+		t.Errorf("unexpected default size quota %d", q.MaxBytes)
+	}
+}
+
+func TestMinIOBackend(t *testing.T) {
+	var gotPath, gotLayer, gotClearance, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotLayer = r.Header.Get("X-Amz-Meta-Layer")
+		gotClearance = r.Header.Get("X-Amz-Meta-Clearance")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	backend := &MinIOBackend{Endpoint: strings.TrimPrefix(srv.URL, "http://"), AccessKey: "key", SecretKey: "secret", Bucket: "telemetry", Prefix: "site-a/"}
+	record := NewRecord(testDevice(), "application/json", []byte(`{"temp_c":21.5}`))
+
+	stored, err := New(Quota{}, backend).Store(context.Background(), record)
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("Store = %v, %v", stored, err)
+	}
+	if !strings.HasPrefix(gotPath, "/telemetry/site-a/0x8005/") || !strings.HasSuffix(gotPath, "-"+record.ID) {
+		t.Errorf("unexpected object path %s", gotPath)
+	}
+	if gotLayer != "data" || gotClearance != "03030303" || gotBody != `{"temp_c":21.5}` {
+		t.Errorf("unexpected upload: layer %q clearance %q body %q", gotLayer, gotClearance, gotBody)
+	}
+
+	backend.AccessKey = ""
+	if _, err := New(Quota{}, backend).Store(context.Background(), record); err == nil || !strings.Contains(err.Error(), "minio: PUT") {
+		t.Errorf("expected the rejected upload to be reported, got %v", err)
+	}
+}
+
+// fakeStream serves XADD and XREVRANGE for one Redis stream, newest entry first
+func fakeStream(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var entries [][]string
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			var n int
+			fmt.Fscanf(r, "*%d\r\n", &n)
+			args := make([]string, n)
+			for i := range args {
+				var size int
+				fmt.Fscanf(r, "$%d\r\n", &size)
+				buf := make([]byte, size+2)
+				io.ReadFull(r, buf)
+				args[i] = string(buf[:size])
+			}
+			switch args[0] {
+			case "PING":
+				fmt.Fprint(conn, "+PONG\r\n")
+			case "XADD":
+				entries = append(entries, args[6:])
+				fmt.Fprintf(conn, "$15\r\n170000000000%d-0\r\n", len(entries))
+			case "XREVRANGE":
+				fmt.Fprintf(conn, "*%d\r\n", len(entries))
+				for i := len(entries) - 1; i >= 0; i-- {
+					id := fmt.Sprintf("170000000000%d-0", i+1)
+					fmt.Fprintf(conn, "*2\r\n$%d\r\n%s\r\n*%d\r\n", len(id), id, len(entries[i]))
+					for _, field := range entries[i] {
+						fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(field), field)
+					}
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestRedisRead(t *testing.T) {
+	ingester := New(Quota{}, &RedisBackend{Endpoint: fakeStream(t)})
+	if ingester.Reader() != "redis" {
+		t.Fatalf("expected redis to serve reads, got %q", ingester.Reader())
+	}
+
+	first := NewRecord(testDevice(), "application/json", []byte(`{"temp_c":21.5}`))
+	second := NewRecord(testDevice(), "text/plain", []byte("door open"))
+	for _, record := range []*Record{first, second} {
+		if _, err := ingester.Store(context.Background(), record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := ingester.Read(context.Background(), Query{Token: 0x8005})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].ID != second.ID || records[1].ID != first.ID {
+		t.Fatalf("expected both records newest first, got %+v", records)
+	}
+	got := records[1]
+	if got.DeviceID != 1 || got.Token != 0x8005 || got.Layer != models.LayerData || got.Clearance != models.ClearanceLevel3 ||
+		got.ContentType != "application/json" || string(got.Data) != `{"temp_c":21.5}` || !got.ReceivedAt.Equal(first.ReceivedAt) {
+		t.Errorf("record not rebuilt from its labels: %+v", got)
+	}
+
+	if _, err := New(Quota{}, &MinIOBackend{}).Read(context.Background(), Query{}); !errors.Is(err, ErrNoReader) {
+		t.Errorf("expected ErrNoReader without a readable backend, got %v", err)
+	}
+}
+
+func TestRedisUnavailable(t *testing.T) {
+	endpoint := fakeStream(t)
+	monitor := redis.NewMonitor(endpoint, "", time.Minute)
+	backend := &RedisBackend{Endpoint: endpoint, Monitor: monitor}
+	record := NewRecord(testDevice(), "text/plain", []byte("door open"))
+
+	// Until a probe succeeds the backend does not dial Redis
+	if err := backend.Store(context.Background(), record); !errors.Is(err, redis.ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable before the first probe, got %v", err)
+	}
+	if _, err := backend.Read(context.Background(), Query{Token: 0x8005}); !errors.Is(err, redis.ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable before the first probe, got %v", err)
+	}
+
+	if err := monitor.Probe(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Store(context.Background(), record); err != nil {
+		t.Fatalf("expected the store to succeed once Redis is available, got %v", err)
+	}
+	if records, err := backend.Read(context.Background(), Query{Token: 0x8005, Limit: 10}); err != nil || len(records) != 1 {
+		t.Errorf("expected the stored record, got %v, %v", records, err)
+	}
+}
+
+func TestReadable(t *testing.T) {
+	record := &Record{Layer: models.LayerControl, Clearance: models.ClearanceLevel5}
+	for _, tc := range []struct {
+		layer     models.Layer
+		clearance models.Clearance
+		want      bool
+	}{
+		{models.LayerApplication, models.ClearanceLevel5, true},
+		{models.LayerControl, models.ClearanceLevel9, true},
+		{models.LayerTransport, models.ClearanceLevel9, false}, // Data never flows down a layer
+		{models.LayerApplication, models.ClearanceLevel4, false},
+	} {
+		if got := record.Readable(tc.layer, tc.clearance); got != tc.want {
+			t.Errorf("Readable(%s, %s) = %v, want %v", tc.layer, tc.clearance, got, tc.want)
+		}
+	}
+}
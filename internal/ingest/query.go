@@ -0,0 +1,112 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// Query limits
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 1000
+)
+
+// Query selects records submitted under one DATA token, newest first
+type Query struct {
+	Token uint16
+	Since time.Time // Zero for no lower bound
+	Until time.Time // Zero for no upper bound
+	Limit int       // Defaults to DefaultQueryLimit, capped at MaxQueryLimit
+}
+
+// Reader is implemented by backends that can return stored records
+type Reader interface {
+	Backend
+	Read(ctx context.Context, q Query) ([]*Record, error)
+}
+
+// ErrNoReader is returned by Read when no configured backend supports reads
+var ErrNoReader = errors.New("no data backend supports reads")
+
+// Readable reports whether a consumer on layer holding clearance may read the record.
+// The clearance must dominate the record's label, and data only flows upward from
+// the layer it was submitted on, so a transport consumer cannot read control data.
+func (r *Record) Readable(layer models.Layer, clearance models.Clearance) bool {
+	return clearance.IsHigherOrEqual(r.Clearance) && models.CanAccessLayer(r.Layer, layer)
+}
+
+// ParseRecord rebuilds a record from its Labels and payload
+func ParseRecord(labels map[string]string, data []byte) (*Record, error) {
+	deviceID, err := strconv.ParseUint(labels["device_id"], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("device_id: %w", err)
+	}
+	token, err := strconv.ParseUint(strings.TrimPrefix(labels["token"], "0x"), 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("token: %w", err)
+	}
+	clearance, err := strconv.ParseUint(labels["clearance"], 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("clearance: %w", err)
+	}
+	received, err := time.Parse(time.RFC3339Nano, labels["received_at"])
+	if err != nil {
+		return nil, fmt.Errorf("received_at: %w", err)
+	}
+	return &Record{
+		ID:          labels["record_id"],
+		DeviceID:    uint16(deviceID),
+		Token:       uint16(token),
+		Layer:       models.Layer(labels["layer"]),
+		Class:       models.DeviceClass(labels["class"]),
+		Clearance:   models.Clearance(clearance),
+		ContentType: labels["content_type"],
+		ReceivedAt:  received,
+		Data:        data,
+	}, nil
+}
+
+// Reader returns the name of the backend Read uses, or "" if none supports reads
+func (i *Ingester) Reader() string {
+	if r := i.reader(); r != nil {
+		return r.Name()
+	}
+	return ""
+}
+
+// reader returns the first backend that supports reads
+func (i *Ingester) reader() Reader {
+	for _, backend := range i.backends {
+		if r, ok := backend.(Reader); ok {
+			return r
+		}
+	}
+	return nil
+}
+
+// Read returns the records matching q from the first backend that supports reads.
+// Callers filter the result with Record.Readable; the labels are stored with the
+// records, so a record keeps the clearance its device held when it was submitted.
+func (i *Ingester) Read(ctx context.Context, q Query) ([]*Record, error) {
+	r := i.reader()
+	if r == nil {
+		return nil, ErrNoReader
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultQueryLimit
+	}
+	if q.Limit > MaxQueryLimit {
+		q.Limit = MaxQueryLimit
+	}
+	records, err := r.Read(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", r.Name(), err)
+	}
+	return records, nil
+}
@@ -0,0 +1,435 @@
+// Package jobs runs long operations, such as generating the code.gov inventory, outside
+// the HTTP request that submits them. Clients submit a job, poll its status, cancel it
+// and fetch its result later.
+//
+// Job records and results are persisted in a directory, one pair of files per job:
+//
+//	<dir>/<id>.json
+//	<dir>/<id>.result
+//
+// so finished jobs survive restarts. Jobs that were queued or running when the process
+// stopped are marked failed on the next start.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults
+const (
+	DefaultWorkers   = 2
+	DefaultQueueSize = 64
+	DefaultRetention = 7 * 24 * time.Hour
+)
+
+// Status is a job's lifecycle state
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+)
+
+// Lookup and submission errors
+var (
+	ErrNotFound    = errors.New("job not found")
+	ErrUnknownKind = errors.New("unknown job kind")
+	ErrQueueFull   = errors.New("job queue is full")
+	ErrFinished    = errors.New("job already finished")
+	ErrNoResult    = errors.New("job has no result")
+)
+
+// Result is what a successful job produces
+type Result struct {
+	Data        []byte
+	ContentType string
+	Summary     string // One-line outcome stored on the job record, e.g. "42 releases"
+}
+
+// Func runs one job of a kind with the parameters it was submitted with. It must
+// return promptly once ctx is done.
+type Func func(ctx context.Context, params json.RawMessage) (*Result, error)
+
+// Job is a persisted job record
+type Job struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	Status      Status          `json:"status"`
+	Params      json.RawMessage `json:"params,omitempty"`
+	Actor       string          `json:"actor,omitempty"` // Who submitted the job
+	Submitted   time.Time       `json:"submitted"`
+	Started     *time.Time      `json:"started,omitempty"`
+	Finished    *time.Time      `json:"finished,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Summary     string          `json:"summary,omitempty"`
+	ContentType string          `json:"content_type,omitempty"` // Of the result, set once the job succeeds
+	ResultSize  int             `json:"result_size,omitempty"`
+}
+
+// Done reports whether the job has finished, successfully or not
+func (j *Job) Done() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed || j.Status == StatusCanceled
+}
+
+// Options configures a Manager
+type Options struct {
+	Workers   int           // Jobs run concurrently; defaults to DefaultWorkers
+	QueueSize int           // Jobs waiting to run before Submit fails; defaults to DefaultQueueSize
+	Retention time.Duration // Finished jobs are purged after this long; defaults to DefaultRetention
+}
+
+// Manager queues, runs and persists jobs
+type Manager struct {
+	dir       string
+	workers   int
+	retention time.Duration
+	queue     chan string
+
+	mu      sync.Mutex
+	kinds   map[string]Func
+	jobs    map[string]*Job
+	cancels map[string]context.CancelFunc // Of running jobs
+}
+
+// New creates a manager persisting jobs in dir, loading the jobs already there
+func New(dir string, opts Options) (*Manager, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create job directory: %w", err)
+	}
+
+	m := &Manager{
+		dir:       dir,
+		workers:   opts.Workers,
+		retention: opts.Retention,
+		queue:     make(chan string, opts.QueueSize),
+		kinds:     make(map[string]Func),
+		jobs:      make(map[string]*Job),
+		cancels:   make(map[string]context.CancelFunc),
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// load reads persisted job records, failing those a previous process left unfinished
+func (m *Manager) load() error {
+	paths, err := filepath.Glob(filepath.Join(m.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read job record: %w", err)
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil || job.ID == "" {
+			return fmt.Errorf("invalid job record %s", filepath.Base(path))
+		}
+		if !job.Done() {
+			now := time.Now().UTC()
+			job.Status = StatusFailed
+			job.Error = "interrupted by a restart"
+			job.Finished = &now
+			if err := m.persist(&job); err != nil {
+				return err
+			}
+		}
+		m.jobs[job.ID] = &job
+	}
+	return nil
+}
+
+// Register makes a kind of job available to Submit
+func (m *Manager) Register(kind string, fn Func) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.kinds[kind] = fn
+}
+
+// Kinds returns the registered kinds, sorted
+func (m *Manager) Kinds() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kinds := make([]string, 0, len(m.kinds))
+	for kind := range m.kinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Run executes queued jobs until ctx is done, then cancels the running ones. Jobs
+// interrupted this way are failed rather than canceled, since nobody asked for it.
+func (m *Manager) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < m.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-m.queue:
+					m.run(ctx, id)
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case now := <-ticker.C:
+			m.Purge(now)
+		}
+	}
+}
+
+// Submit records a job of kind and queues it
+func (m *Manager) Submit(kind string, params json.RawMessage, actor string) (*Job, error) {
+	id := make([]byte, 8)
+	rand.Read(id)
+	job := &Job{
+		ID:        hex.EncodeToString(id),
+		Kind:      kind,
+		Status:    StatusQueued,
+		Params:    params,
+		Actor:     actor,
+		Submitted: time.Now().UTC(),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.kinds[kind]; !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKind, kind)
+	}
+	if len(m.queue) == cap(m.queue) {
+		return nil, ErrQueueFull
+	}
+	if err := m.persist(job); err != nil {
+		return nil, err
+	}
+	m.jobs[job.ID] = job
+	m.queue <- job.ID
+
+	copied := *job
+	return &copied, nil
+}
+
+// Get returns a copy of a job's record
+func (m *Manager) Get(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *job
+	return &copied, nil
+}
+
+// List returns copies of every job's record, newest first
+func (m *Manager) List() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		copied := *job
+		jobs = append(jobs, &copied)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].Submitted.Equal(jobs[j].Submitted) {
+			return jobs[i].Submitted.After(jobs[j].Submitted)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs
+}
+
+// Cancel stops a queued or running job. A queued job is canceled at once; a running
+// job is canceled when its Func returns.
+func (m *Manager) Cancel(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	switch {
+	case job.Done():
+		return nil, ErrFinished
+	case job.Status == StatusQueued:
+		m.finish(job, StatusCanceled, "canceled before it started")
+	default:
+		m.cancels[id]()
+	}
+	copied := *job
+	return &copied, nil
+}
+
+// Result returns the output of a succeeded job and its content type
+func (m *Manager) Result(id string) ([]byte, string, error) {
+	job, err := m.Get(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if job.Status != StatusSucceeded {
+		return nil, "", fmt.Errorf("%w: job is %s", ErrNoResult, job.Status)
+	}
+	data, err := os.ReadFile(m.resultPath(id))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read job result: %w", err)
+	}
+	return data, job.ContentType, nil
+}
+
+// Purge deletes jobs that finished more than the retention period before now
+func (m *Manager) Purge(now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	purged := 0
+	for id, job := range m.jobs {
+		if job.Finished == nil || now.Sub(*job.Finished) < m.retention {
+			continue
+		}
+		os.Remove(m.resultPath(id))
+		os.Remove(m.recordPath(id))
+		delete(m.jobs, id)
+		purged++
+	}
+	return purged
+}
+
+// run executes one queued job
+func (m *Manager) run(parent context.Context, id string) {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	if !ok || job.Status != StatusQueued {
+		// Canceled or purged while queued
+		m.mu.Unlock()
+		return
+	}
+	fn := m.kinds[job.Kind]
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	now := time.Now().UTC()
+	job.Status = StatusRunning
+	job.Started = &now
+	m.cancels[id] = cancel
+	m.persist(job)
+	params := job.Params
+	m.mu.Unlock()
+
+	result, err := call(ctx, fn, params)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.cancels, id)
+	switch {
+	case err == nil:
+		if werr := writeFile(m.resultPath(id), result.Data); werr != nil {
+			m.finish(job, StatusFailed, "failed to store result: "+werr.Error())
+			return
+		}
+		job.ContentType = result.ContentType
+		job.ResultSize = len(result.Data)
+		job.Summary = result.Summary
+		m.finish(job, StatusSucceeded, "")
+	case parent.Err() != nil:
+		m.finish(job, StatusFailed, "interrupted by shutdown")
+	case ctx.Err() != nil:
+		m.finish(job, StatusCanceled, "canceled while running")
+	default:
+		m.finish(job, StatusFailed, err.Error())
+	}
+}
+
+// call runs fn, turning a panic into an error so one job cannot stop a worker
+func call(ctx context.Context, fn Func, params json.RawMessage) (result *Result, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	result, err = fn(ctx, params)
+	if err == nil && result == nil {
+		result = &Result{}
+	}
+	return result, err
+}
+
+// finish records a job's final status; the caller holds m.mu
+func (m *Manager) finish(job *Job, status Status, message string) {
+	now := time.Now().UTC()
+	job.Status = status
+	job.Error = message
+	job.Finished = &now
+	m.persist(job)
+}
+
+// persist writes a job's record
+func (m *Manager) persist(job *Job) error {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(m.recordPath(job.ID), data); err != nil {
+		return fmt.Errorf("failed to persist job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+func (m *Manager) recordPath(id string) string {
+	return filepath.Join(m.dir, id+".json")
+}
+
+func (m *Manager) resultPath(id string) string {
+	return filepath.Join(m.dir, id+".result")
+}
+
+// writeFile replaces path atomically, so a crash never leaves a partial record
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
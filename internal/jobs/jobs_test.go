@@ -0,0 +1,150 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// wait polls until the job reaches a final status
+func wait(t *testing.T, m *Manager, id string) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Done() {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	dir := t.TempDir()
+	m, err := New(dir, Options{Workers: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Register("echo", func(ctx context.Context, params json.RawMessage) (*Result, error) {
+		return &Result{Data: params, ContentType: "application/json", Summary: "echoed"}, nil
+	})
+	m.Register("block", func(ctx context.Context, params json.RawMessage) (*Result, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	m.Register("fail", func(ctx context.Context, params json.RawMessage) (*Result, error) {
+		return nil, errors.New("organization unreachable")
+	})
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+
+	if _, err := m.Submit("unknown", nil, "device-4"); !errors.Is(err, ErrUnknownKind) {
+		t.Errorf("expected ErrUnknownKind, got %v", err)
+	}
+
+	job, err := m.Submit("echo", json.RawMessage(`{"orgs":["agency"]}`), "device-4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job = wait(t, m, job.ID); job.Status != StatusSucceeded || job.Summary != "echoed" || job.Actor != "device-4" {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	data, contentType, err := m.Result(job.ID)
+	if err != nil || string(data) != `{"orgs":["agency"]}` || contentType != "application/json" {
+		t.Errorf("unexpected result %q %q %v", data, contentType, err)
+	}
+
+	failed, _ := m.Submit("fail", nil, "")
+	if failed = wait(t, m, failed.ID); failed.Status != StatusFailed || failed.Error != "organization unreachable" {
+		t.Errorf("unexpected failed job %+v", failed)
+	}
+	if _, _, err := m.Result(failed.ID); !errors.Is(err, ErrNoResult) {
+		t.Errorf("expected ErrNoResult, got %v", err)
+	}
+
+	// The single worker is busy with the first blocking job, so the second stays queued
+	running, _ := m.Submit("block", nil, "")
+	queued, _ := m.Submit("block", nil, "")
+	for {
+		if job, _ := m.Get(running.ID); job.Status == StatusRunning {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if job, err := m.Cancel(queued.ID); err != nil || job.Status != StatusCanceled {
+		t.Errorf("expected the queued job to be canceled at once, got %+v %v", job, err)
+	}
+	if _, err := m.Cancel(running.ID); err != nil {
+		t.Fatal(err)
+	}
+	if job := wait(t, m, running.ID); job.Status != StatusCanceled {
+		t.Errorf("expected the running job to be canceled, got %+v", job)
+	}
+	if _, err := m.Cancel(running.ID); !errors.Is(err, ErrFinished) {
+		t.Errorf("expected ErrFinished, got %v", err)
+	}
+
+	// Shutting down fails the job that was running
+	interrupted, _ := m.Submit("block", nil, "")
+	for {
+		if job, _ := m.Get(interrupted.ID); job.Status == StatusRunning {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	stop()
+	<-done
+	if job, _ := m.Get(interrupted.ID); job.Status != StatusFailed || job.Error != "interrupted by shutdown" {
+		t.Errorf("unexpected interrupted job %+v", job)
+	}
+
+	// Records survive a restart
+	reloaded, err := New(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs := reloaded.List(); len(jobs) != 5 {
+		t.Errorf("expected 5 persisted jobs, got %d", len(jobs))
+	}
+	if data, _, err := reloaded.Result(job.ID); err != nil || string(data) != `{"orgs":["agency"]}` {
+		t.Errorf("result lost across restart: %q %v", data, err)
+	}
+}
+
+func TestLoadFailsUnfinishedJobs(t *testing.T) {
+	dir := t.TempDir()
+	record := `{"id":"00112233aabbccdd","kind":"inventory","status":"running","submitted":"2026-01-02T03:04:05Z"}`
+	if err := os.WriteFile(filepath.Join(dir, "00112233aabbccdd.json"), []byte(record), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := New(dir, Options{Retention: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := m.Get("00112233aabbccdd")
+	if err != nil || job.Status != StatusFailed || job.Error != "interrupted by a restart" {
+		t.Fatalf("unexpected job %+v %v", job, err)
+	}
+
+	if n := m.Purge(job.Finished.Add(2 * time.Hour)); n != 1 {
+		t.Errorf("expected the finished job to be purged, purged %d", n)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "00112233aabbccdd.json")); !os.IsNotExist(err) {
+		t.Errorf("expected the record to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+// Package keys loads the ed25519 keys used to sign bundles, device identities
+// and inventories from PEM files or inline values.
+package keys
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ParsePrivateKey parses an ed25519 private key from PEM (PKCS #8) data, as written
+// by "openssl genpkey -algorithm ed25519"
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("private key must be a PEM file")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an ed25519 key")
+	}
+	return edKey, nil
+}
+
+// ParsePublicKey parses an ed25519 public key from PEM (PKIX) data or a base64 encoded raw key
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key is not an ed25519 key")
+		}
+		return edKey, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be a PEM file or a base64 encoded ed25519 key")
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// LoadPrivateKey reads an ed25519 private key from a PEM (PKCS #8) file
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	return ParsePrivateKey(data)
+}
+
+// LoadPublicKey reads an ed25519 public key from a PEM (PKIX) file or a base64 encoded raw key
+func LoadPublicKey(pathOrKey string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(pathOrKey)
+	if err != nil {
+		// Not a readable file; treat the value as an inline base64 key
+		data = []byte(pathOrKey)
+	}
+	return ParsePublicKey(data)
+}
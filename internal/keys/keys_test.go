@@ -0,0 +1,69 @@
+package keys
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPublicKey(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(nil)
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "bundle.pem")
+	os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)
+
+	fromPEM, err := LoadPublicKey(path)
+	if err != nil || !fromPEM.Equal(pub) {
+		t.Fatalf("LoadPublicKey(pem) = %v, %v", fromPEM, err)
+	}
+
+	fromBase64, err := LoadPublicKey(base64.StdEncoding.EncodeToString(pub))
+	if err != nil || !fromBase64.Equal(pub) {
+		t.Fatalf("LoadPublicKey(base64) = %v, %v", fromBase64, err)
+	}
+
+	if _, err := LoadPublicKey("not-a-key"); err == nil {
+		t.Fatal("expected error for invalid key")
+	}
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	dir := t.TempDir()
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privPath := filepath.Join(dir, "signing.pem")
+	os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+
+	der, err = x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPath := filepath.Join(dir, "signing.pub")
+	os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)
+
+	loaded, err := LoadPrivateKey(privPath)
+	if err != nil || !loaded.Equal(priv) {
+		t.Fatalf("LoadPrivateKey() = %v", err)
+	}
+	if _, err := LoadPrivateKey(pubPath); err == nil {
+		t.Error("expected a public key to be rejected as a private key")
+	}
+	if _, err := LoadPrivateKey(filepath.Join(dir, "missing.pem")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	if _, err := ParsePrivateKey([]byte("not-a-key")); err == nil {
+		t.Error("expected an error for data that is not PEM")
+	}
+}
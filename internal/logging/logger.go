@@ -27,6 +27,7 @@ const (
 	RequestIDKey contextKey = "request_id"
 	DeviceIDKey  contextKey = "device_id"
 	LayerKey     contextKey = "layer"
+	RouteKey     contextKey = "route"
 )
 
 // Logger provides structured logging with correlation IDs
@@ -50,6 +51,7 @@ type Entry struct {
 	RequestID  string                 `json:"request_id,omitempty"`
 	DeviceID   string                 `json:"device_id,omitempty"`
 	Layer      string                 `json:"layer,omitempty"`
+	Route      string                 `json:"route,omitempty"`
 	Fields     map[string]interface{} `json:"fields,omitempty"`
 }
 
@@ -155,6 +157,9 @@ func (l *Logger) log(ctx context.Context, level Level, msg string, fields ...map
 	if layer, ok := ctx.Value(LayerKey).(string); ok && layer != "" {
 		entry.Layer = layer
 	}
+	if route, ok := ctx.Value(RouteKey).(string); ok && route != "" {
+		entry.Route = route
+	}
 
 	// Add default fields
 	l.mu.Lock()
@@ -245,6 +250,19 @@ func WithLayer(ctx context.Context, layer string) context.Context {
 	return context.WithValue(ctx, LayerKey, layer)
 }
 
+// WithRoute adds the normalized route pattern (e.g. /api/device/{id}/status) to the context
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, RouteKey, route)
+}
+
+// GetRoute retrieves the normalized route pattern from context
+func GetRoute(ctx context.Context) string {
+	if route, ok := ctx.Value(RouteKey).(string); ok {
+		return route
+	}
+	return ""
+}
+
 // GetRequestID retrieves the request ID from context
 func GetRequestID(ctx context.Context) string {
 	if requestID, ok := ctx.Value(RequestIDKey).(string); ok {
@@ -140,6 +140,7 @@ func TestContextValues(t *testing.T) {
 	ctx := WithRequestID(context.Background(), "req-123")
 	ctx = WithDeviceID(ctx, "device-456")
 	ctx = WithLayer(ctx, "api")
+	ctx = WithRoute(ctx, "/api/device/{id}/status")
 
 	logger.InfoContext(ctx, "test")
 
@@ -159,6 +160,10 @@ func TestContextValues(t *testing.T) {
 	if entry.Layer != "api" {
 		t.Errorf("expected layer 'api', got %s", entry.Layer)
 	}
+
+	if entry.Route != "/api/device/{id}/status" {
+		t.Errorf("expected route '/api/device/{id}/status', got %s", entry.Route)
+	}
 }
 
 func TestTextFormat(t *testing.T) {
@@ -0,0 +1,141 @@
+package oscal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/policy"
+)
+
+// ComponentDefinitionDocument is the JSON root of an OSCAL component definition
+type ComponentDefinitionDocument struct {
+	ComponentDefinition *ComponentDefinition `json:"component-definition"`
+}
+
+// ComponentDefinition describes how gogovcode implements its controls
+type ComponentDefinition struct {
+	UUID       string      `json:"uuid"`
+	Metadata   Metadata    `json:"metadata"`
+	Components []Component `json:"components"`
+}
+
+// Component is a defined component and the controls it implements
+type Component struct {
+	UUID                   string                  `json:"uuid"`
+	Type                   string                  `json:"type"`
+	Title                  string                  `json:"title"`
+	Description            string                  `json:"description"`
+	Props                  []Property              `json:"props,omitempty"`
+	ControlImplementations []ControlImplementation `json:"control-implementations"`
+}
+
+// ControlImplementation groups the requirements implemented from one catalog
+type ControlImplementation struct {
+	UUID                    string                   `json:"uuid"`
+	Source                  string                   `json:"source"`
+	Description             string                   `json:"description"`
+	ImplementedRequirements []ImplementedRequirement `json:"implemented-requirements"`
+}
+
+// ImplementedRequirement describes how a single control is implemented
+type ImplementedRequirement struct {
+	UUID        string     `json:"uuid"`
+	ControlID   string     `json:"control-id"`
+	Description string     `json:"description"`
+	Props       []Property `json:"props,omitempty"`
+}
+
+// NewComponentDefinition describes the system's controls as configured, with each
+// policy rule recorded against access enforcement. The policy may be nil.
+func NewComponentDefinition(system System, p *policy.Policy, now time.Time) *ComponentDefinitionDocument {
+	if p == nil {
+		p = &policy.Policy{}
+	}
+
+	var requirements []ImplementedRequirement
+	add := func(controlID, description string, props ...Property) {
+		requirements = append(requirements, ImplementedRequirement{
+			UUID:        newUUID("implemented-requirement", system.title(), controlID),
+			ControlID:   controlID,
+			Description: description,
+			Props:       props,
+		})
+	}
+
+	enforcement := fmt.Sprintf("Requests are evaluated against a policy of %d rules matching route, method, clearance, layer and device; the highest-priority matching rule decides.", len(p.Rules))
+	if !system.ClearanceEnforced {
+		enforcement += " The clearance middleware is disabled, so the policy is not enforced."
+	}
+	props := []Property{{Name: "policy-digest", Value: audit.Digest(p), NS: Namespace}}
+	var reviewed []Property
+	for _, rule := range p.Rules {
+		props = append(props, Property{Name: "policy-rule", Value: rule.ID, NS: Namespace, Remarks: describeRule(rule)})
+		if rule.ReviewBy != nil {
+			reviewed = append(reviewed, Property{Name: "review-by", Value: rule.ReviewBy.UTC().Format(time.RFC3339), NS: Namespace, Remarks: "Rule " + rule.ID})
+		}
+	}
+	add(ControlAccessEnforcement, enforcement, props...)
+	if len(reviewed) > 0 {
+		add(ControlPrivilegeReview, fmt.Sprintf("%d policy rules carry a review_by date by which they must be recertified; rules approaching it are listed at /api/admin/policy/review.", len(reviewed)), reviewed...)
+	}
+	if system.Lockout {
+		add(ControlLogonAttempts, "Repeated authentication failures lock out the source with increasing delays; lockouts and their lifting are audited.")
+	}
+
+	sinks := "stdout"
+	if len(system.AuditSinks) > 0 {
+		sinks = strings.Join(system.AuditSinks, ", ")
+	}
+	level := system.AuditLevel
+	if level == "" {
+		level = string(audit.DefaultLevel)
+	}
+	add(ControlEventLogging, fmt.Sprintf("Access decisions, lockouts, policy and device changes, job submissions and denied egress are audited at level %s, written to %s.", level, sinks))
+	add(ControlRecordContent, "Each audit record carries its time, instance and sequence number, actor, device, clearance, layer, action, method, resource, decision, reason, request ID and source address; changes carry digests of the state before and after.")
+	add(ControlRecordGeneration, "Audit records are generated by the server itself for every decision it makes, independent of the upstream services it protects.")
+	if system.TLS {
+		add(ControlTransmission, "The server only accepts TLS connections.")
+	}
+
+	componentProps := []Property{{Name: "version", Value: system.Build.Version, NS: Namespace}}
+	if system.Build.Commit != "" {
+		componentProps = append(componentProps, Property{Name: "commit", Value: system.Build.Commit, NS: Namespace})
+	}
+
+	return &ComponentDefinitionDocument{ComponentDefinition: &ComponentDefinition{
+		UUID:     newUUID("component-definition", system.title(), system.Build.Version, audit.Digest(requirements)),
+		Metadata: newMetadata(system.title()+" component definition", system, now),
+		Components: []Component{{
+			UUID:        system.componentUUID(),
+			Type:        "software",
+			Title:       system.title(),
+			Description: "Clearance-enforcing gateway that serves the code.gov inventory and protects internal services with a device and clearance policy.",
+			Props:       componentProps,
+			ControlImplementations: []ControlImplementation{{
+				UUID:                    newUUID("control-implementation", system.title()),
+				Source:                  CatalogSource,
+				Description:             "NIST SP 800-53 rev5 controls implemented by " + system.title() + " as configured",
+				ImplementedRequirements: requirements,
+			}},
+		}},
+	}}
+}
+
+// describeRule summarizes a policy rule in one line
+func describeRule(rule *policy.Rule) string {
+	methods := "any method"
+	if len(rule.Methods) > 0 {
+		methods = strings.Join(rule.Methods, ",")
+	}
+	routes := "any route"
+	if len(rule.Routes) > 0 {
+		routes = strings.Join(rule.Routes, ", ")
+	}
+	description := fmt.Sprintf("%s %s on %s", rule.Effect, methods, routes)
+	if level := rule.RequiredClearance.Level(); level > 0 {
+		description += fmt.Sprintf(" at clearance level %d", level)
+	}
+	return description + fmt.Sprintf(", priority %d", rule.Priority)
+}
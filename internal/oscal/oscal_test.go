@@ -0,0 +1,157 @@
+package oscal
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/buildinfo"
+	"github.com/NSACodeGov/CodeGov/internal/policy"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func testSystem() System {
+	return System{
+		Build:             buildinfo.Info{Version: "1.2.3"},
+		InstanceID:        "gw-1",
+		ClearanceEnforced: true,
+		AuditSinks:        []string{"file"},
+		Lockout:           true,
+	}
+}
+
+func testPolicy() *policy.Policy {
+	review := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &policy.Policy{Version: "1.0", Rules: []*policy.Rule{
+		{ID: "allow-inventory", Effect: policy.EffectAllow, Routes: []string{"/api/inventory"}, Methods: []string{"GET"}, RequiredClearance: models.ClearanceLevel5, Priority: 90, ReviewBy: &review},
+		{ID: "allow-all", Effect: policy.EffectAllow, Priority: 60},
+		{ID: "deny-all", Effect: policy.EffectDeny, Priority: 0},
+	}}
+}
+
+func TestNewUUID(t *testing.T) {
+	a, b := newUUID("component", "gogovcode"), newUUID("component", "gogovcode")
+	if a != b || !uuidPattern.MatchString(a) {
+		t.Errorf("expected a stable version 5 UUID, got %s and %s", a, b)
+	}
+	if newUUID("component", "other") == a {
+		t.Error("different names share a UUID")
+	}
+}
+
+func TestNewComponentDefinition(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	document := NewComponentDefinition(testSystem(), testPolicy(), now)
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), `{"component-definition":{"uuid":`) || !strings.Contains(string(data), `"oscal-version":"`+Version+`"`) {
+		t.Errorf("unexpected document %s", data)
+	}
+
+	component := document.ComponentDefinition.Components[0]
+	var controls []string
+	for _, requirement := range component.ControlImplementations[0].ImplementedRequirements {
+		controls = append(controls, requirement.ControlID)
+		if !uuidPattern.MatchString(requirement.UUID) {
+			t.Errorf("invalid UUID %q", requirement.UUID)
+		}
+	}
+	if strings.Join(controls, ",") != "ac-3,ac-6.7,ac-7,au-2,au-3,au-12" {
+		t.Errorf("unexpected controls %v", controls)
+	}
+
+	enforcement := component.ControlImplementations[0].ImplementedRequirements[0]
+	if len(enforcement.Props) != 4 || enforcement.Props[1].Value != "allow-inventory" || enforcement.Props[1].Remarks != "allow GET on /api/inventory at clearance level 5, priority 90" {
+		t.Errorf("unexpected rule properties %+v", enforcement.Props)
+	}
+
+	if again := NewComponentDefinition(testSystem(), testPolicy(), now.Add(time.Hour)); again.ComponentDefinition.UUID != document.ComponentDefinition.UUID {
+		t.Error("unchanged configuration exported under a new UUID")
+	}
+}
+
+func TestNewAssessmentResults(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	trail := NewAuditTrail(now.Add(-24*time.Hour), now)
+	for i, event := range []*audit.AuditEvent{
+		{EventID: "evt-1", Timestamp: now.Add(-time.Hour), Action: "GET /api/inventory", Decision: audit.DecisionAllow},
+		{EventID: "evt-2", Timestamp: now.Add(-2 * time.Hour), Action: "GET /api/inventory", Decision: audit.DecisionAllow},
+		{EventID: "evt-3", Timestamp: now.Add(-3 * time.Hour), Action: "auth.lockout", Decision: audit.DecisionDeny},
+		{EventID: "evt-4", Timestamp: now.Add(-4 * time.Hour), Action: "policy.upsert", Decision: audit.DecisionAllow, AfterDigest: "sha256:00"},
+		{EventID: "evt-5", Timestamp: now.Add(-48 * time.Hour), Action: "GET /api/inventory", Decision: audit.DecisionAllow},
+	} {
+		event.Sequence = uint64(i + 1)
+		trail.Add(event)
+	}
+	if trail.Events != 4 {
+		t.Errorf("expected 4 events in the period, got %d", trail.Events)
+	}
+
+	lint := policy.Lint(testPolicy(), nil)
+	document := NewAssessmentResults(testSystem(), testPolicy(), lint, trail, "", now)
+	results := document.AssessmentResults
+	if results.ImportAP.Href != DefaultImportAP || len(results.Results) != 1 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	result := results.Results[0]
+	if !result.Start.Equal(trail.Start) || !result.End.Equal(trail.End) {
+		t.Errorf("unexpected period %s to %s", result.Start, result.End)
+	}
+	states := make(map[string]string)
+	for _, finding := range result.Findings {
+		states[finding.Target.TargetID] = finding.Target.Status.State
+		for _, related := range finding.RelatedObservations {
+			found := false
+			for _, o := range result.Observations {
+				found = found || o.UUID == related.ObservationUUID
+			}
+			if !found {
+				t.Errorf("finding %s refers to unknown observation %s", finding.Target.TargetID, related.ObservationUUID)
+			}
+		}
+	}
+	want := map[string]string{
+		"ac-3_obj":   StateNotSatisfied, // allow-all matches every request
+		"ac-6.7_obj": StateNotSatisfied, // allow-inventory is overdue for review
+		"ac-7_obj":   StateSatisfied,
+		"au-12_obj":  StateSatisfied,
+		"cm-3_obj":   StateSatisfied,
+	}
+	if len(states) != len(want) {
+		t.Errorf("unexpected findings %v", states)
+	}
+	for target, state := range want {
+		if states[target] != state {
+			t.Errorf("%s: expected %s, got %q", target, state, states[target])
+		}
+	}
+
+	// Without an audit trail, the audit controls are not assessed
+	document = NewAssessmentResults(testSystem(), testPolicy(), nil, nil, "https://ssp.example.gov/ap.json", now)
+	result = document.AssessmentResults.Results[0]
+	for _, finding := range result.Findings {
+		if finding.Target.TargetID == "au-12_obj" {
+			t.Error("audit trail assessed without one")
+		}
+	}
+	if result.Remarks == "" || document.AssessmentResults.ImportAP.Href != "https://ssp.example.gov/ap.json" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// An empty period fails record generation
+	document = NewAssessmentResults(testSystem(), testPolicy(), nil, NewAuditTrail(now.Add(-time.Hour), now), "", now)
+	for _, finding := range document.AssessmentResults.Results[0].Findings {
+		if finding.Target.TargetID == "au-12_obj" && finding.Target.Status.State != StateNotSatisfied {
+			t.Error("empty audit trail satisfied record generation")
+		}
+	}
+}
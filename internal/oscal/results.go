@@ -0,0 +1,340 @@
+package oscal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/internal/audit"
+	"github.com/NSACodeGov/CodeGov/internal/policy"
+)
+
+// DefaultImportAP is the assessment plan results refer to when none is given
+const DefaultImportAP = "#assessment-plan"
+
+// MaxEvidence bounds the audit events cited as evidence by each observation
+const MaxEvidence = 20
+
+// Objective states of a finding
+const (
+	StateSatisfied    = "satisfied"
+	StateNotSatisfied = "not-satisfied"
+)
+
+// AssessmentResultsDocument is the JSON root of OSCAL assessment results
+type AssessmentResultsDocument struct {
+	AssessmentResults *AssessmentResults `json:"assessment-results"`
+}
+
+// AssessmentResults holds the results of assessing the system's controls
+type AssessmentResults struct {
+	UUID     string   `json:"uuid"`
+	Metadata Metadata `json:"metadata"`
+	ImportAP ImportAP `json:"import-ap"`
+	Results  []Result `json:"results"`
+}
+
+// ImportAP refers to the assessment plan the results belong to
+type ImportAP struct {
+	Href string `json:"href"`
+}
+
+// Result is one assessment over a period of time
+type Result struct {
+	UUID             string           `json:"uuid"`
+	Title            string           `json:"title"`
+	Description      string           `json:"description"`
+	Start            time.Time        `json:"start"`
+	End              time.Time        `json:"end"`
+	Props            []Property       `json:"props,omitempty"`
+	ReviewedControls ReviewedControls `json:"reviewed-controls"`
+	Observations     []Observation    `json:"observations,omitempty"`
+	Findings         []Finding        `json:"findings,omitempty"`
+	Remarks          string           `json:"remarks,omitempty"`
+}
+
+// ReviewedControls lists the controls a result covers
+type ReviewedControls struct {
+	ControlSelections []ControlSelection `json:"control-selections"`
+}
+
+// ControlSelection selects controls by ID
+type ControlSelection struct {
+	IncludeControls []SelectControl `json:"include-controls"`
+}
+
+// SelectControl selects a single control
+type SelectControl struct {
+	ControlID string `json:"control-id"`
+}
+
+// Observation is evidence collected during the assessment
+type Observation struct {
+	UUID             string             `json:"uuid"`
+	Title            string             `json:"title"`
+	Description      string             `json:"description"`
+	Props            []Property         `json:"props,omitempty"`
+	Methods          []string           `json:"methods"`
+	Types            []string           `json:"types,omitempty"`
+	Subjects         []SubjectReference `json:"subjects,omitempty"`
+	RelevantEvidence []Evidence         `json:"relevant-evidence,omitempty"`
+	Collected        time.Time          `json:"collected"`
+}
+
+// SubjectReference names what an observation is about
+type SubjectReference struct {
+	SubjectUUID string `json:"subject-uuid"`
+	Type        string `json:"type"`
+}
+
+// Evidence cites a record supporting an observation
+type Evidence struct {
+	Description string `json:"description"`
+}
+
+// Finding is the assessed state of a control
+type Finding struct {
+	UUID                string               `json:"uuid"`
+	Title               string               `json:"title"`
+	Description         string               `json:"description"`
+	Target              FindingTarget        `json:"target"`
+	RelatedObservations []RelatedObservation `json:"related-observations,omitempty"`
+}
+
+// FindingTarget is the control objective a finding assesses
+type FindingTarget struct {
+	Type     string          `json:"type"`
+	TargetID string          `json:"target-id"`
+	Status   ObjectiveStatus `json:"status"`
+}
+
+// ObjectiveStatus is whether an objective is satisfied
+type ObjectiveStatus struct {
+	State   string `json:"state"`
+	Remarks string `json:"remarks,omitempty"`
+}
+
+// RelatedObservation links a finding to its evidence
+type RelatedObservation struct {
+	ObservationUUID string `json:"observation-uuid"`
+}
+
+// AuditTrail summarizes the audit events of a period by action and decision
+type AuditTrail struct {
+	Start   time.Time
+	End     time.Time
+	Events  int // Events within the period
+	Skipped int // Records that could not be read as events
+
+	groups map[trailKey]*trailGroup
+}
+
+type trailKey struct {
+	action   string
+	decision audit.Decision
+}
+
+type trailGroup struct {
+	count       int
+	first, last time.Time
+	change      bool // Events carry before/after digests of a configuration change
+	evidence    []Evidence
+}
+
+// NewAuditTrail returns an empty trail for events from start until end
+func NewAuditTrail(start, end time.Time) *AuditTrail {
+	return &AuditTrail{Start: start.UTC().Truncate(time.Second), End: end.UTC().Truncate(time.Second), groups: make(map[trailKey]*trailGroup)}
+}
+
+// Add records an event, ignoring those outside the trail's period
+func (t *AuditTrail) Add(event *audit.AuditEvent) {
+	if event.Timestamp.Before(t.Start) || !event.Timestamp.Before(t.End) {
+		return
+	}
+	t.Events++
+
+	key := trailKey{action: event.Action, decision: event.Decision}
+	group := t.groups[key]
+	if group == nil {
+		group = &trailGroup{first: event.Timestamp}
+		t.groups[key] = group
+	}
+	group.count++
+	if event.Timestamp.Before(group.first) {
+		group.first = event.Timestamp
+	}
+	if event.Timestamp.After(group.last) {
+		group.last = event.Timestamp
+	}
+	group.change = group.change || event.BeforeDigest != "" || event.AfterDigest != ""
+	if len(group.evidence) < MaxEvidence {
+		group.evidence = append(group.evidence, Evidence{Description: fmt.Sprintf("Audit event %s at %s: %s %s %s %s (%s)",
+			event.EventID, event.Timestamp.UTC().Format(time.RFC3339), event.Actor, event.Decision, event.Method, event.Resource, event.Reason)})
+	}
+}
+
+// controls returns the controls an action and decision are evidence for
+func (k trailKey) controls(change bool) []string {
+	controls := []string{ControlRecordGeneration}
+	if k.decision == audit.DecisionDeny {
+		controls = append(controls, ControlAccessEnforcement)
+	}
+	if strings.HasPrefix(k.action, "auth.") {
+		controls = append(controls, ControlLogonAttempts)
+	}
+	if change {
+		controls = append(controls, ControlChangeControl)
+	}
+	return controls
+}
+
+// NewAssessmentResults assesses the system's controls from the policy's lint findings
+// and the audit trail, which may be nil when no audit file is available. importAP is
+// the assessment plan the results belong to; empty uses DefaultImportAP.
+func NewAssessmentResults(system System, p *policy.Policy, lint []policy.LintFinding, trail *AuditTrail, importAP string, now time.Time) *AssessmentResultsDocument {
+	if p == nil {
+		p = &policy.Policy{}
+	}
+	if importAP == "" {
+		importAP = DefaultImportAP
+	}
+	now = now.UTC().Truncate(time.Second)
+	subjects := []SubjectReference{{SubjectUUID: system.componentUUID(), Type: "component"}}
+
+	var observations []Observation
+	related := make(map[string][]RelatedObservation)
+	observe := func(o Observation, controls ...string) {
+		o.Subjects = subjects
+		o.Collected = now
+		observations = append(observations, o)
+		for _, control := range controls {
+			related[control] = append(related[control], RelatedObservation{ObservationUUID: o.UUID})
+		}
+	}
+
+	// The policy in force and each of its lint findings
+	digest := audit.Digest(p)
+	observe(Observation{
+		UUID:        newUUID("observation", "policy", digest),
+		Title:       "Active access policy",
+		Description: fmt.Sprintf("The policy in force has %d rules.", len(p.Rules)),
+		Props:       []Property{{Name: "policy-digest", Value: digest, NS: Namespace}},
+		Methods:     []string{"EXAMINE"},
+	}, ControlAccessEnforcement)
+	failed := make(map[string][]string)
+	for _, f := range lint {
+		control := ControlAccessEnforcement
+		if f.Check == policy.LintExpired || f.Check == policy.LintReviewOverdue {
+			control = ControlPrivilegeReview
+		}
+		observe(Observation{
+			UUID:        newUUID("observation", "lint", digest, string(f.Check), f.RuleID),
+			Title:       "Policy lint: " + string(f.Check),
+			Description: fmt.Sprintf("Rule %s: %s", f.RuleID, f.Message),
+			Props:       []Property{{Name: "policy-rule", Value: f.RuleID, NS: Namespace}},
+			Methods:     []string{"EXAMINE"},
+			Types:       []string{"finding"},
+		}, control)
+		if f.Check != policy.LintUnusedClearance && f.Check != policy.LintUnreachable {
+			failed[control] = append(failed[control], f.String())
+		}
+	}
+
+	// The audit events of the period, grouped by action and decision
+	start, end := now, now
+	remarks := ""
+	if trail != nil {
+		start, end = trail.Start, trail.End
+		keys := make([]trailKey, 0, len(trail.groups))
+		for key := range trail.groups {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].action != keys[j].action {
+				return keys[i].action < keys[j].action
+			}
+			return keys[i].decision < keys[j].decision
+		})
+		for _, key := range keys {
+			group := trail.groups[key]
+			observe(Observation{
+				UUID:        newUUID("observation", "audit", start.Format(time.RFC3339), end.Format(time.RFC3339), key.action, string(key.decision)),
+				Title:       fmt.Sprintf("Audit trail: %s %s", key.action, key.decision),
+				Description: fmt.Sprintf("%d %s events with decision %s between %s and %s.", group.count, key.action, key.decision, group.first.UTC().Format(time.RFC3339), group.last.UTC().Format(time.RFC3339)),
+				Props: []Property{
+					{Name: "action", Value: key.action, NS: Namespace},
+					{Name: "decision", Value: string(key.decision), NS: Namespace},
+					{Name: "count", Value: fmt.Sprint(group.count), NS: Namespace},
+				},
+				Methods:          []string{"TEST"},
+				RelevantEvidence: group.evidence,
+			}, key.controls(group.change)...)
+		}
+		if trail.Skipped > 0 {
+			remarks = fmt.Sprintf("%d audit records could not be read as events and were left out.", trail.Skipped)
+		}
+		if trail.Events == 0 {
+			failed[ControlRecordGeneration] = append(failed[ControlRecordGeneration], "no audit events were recorded in the period")
+		}
+	} else {
+		remarks = "No raw audit file is configured, so the audit trail was not assessed."
+	}
+
+	// One finding per assessed control
+	controls := []string{ControlAccessEnforcement}
+	reviewed := related[ControlPrivilegeReview] != nil
+	for _, rule := range p.Rules {
+		reviewed = reviewed || rule.ReviewBy != nil
+	}
+	if reviewed {
+		controls = append(controls, ControlPrivilegeReview)
+	}
+	if system.Lockout {
+		controls = append(controls, ControlLogonAttempts)
+	}
+	if trail != nil {
+		controls = append(controls, ControlRecordGeneration)
+		if related[ControlChangeControl] != nil {
+			controls = append(controls, ControlChangeControl)
+		}
+	}
+	if !system.ClearanceEnforced {
+		failed[ControlAccessEnforcement] = append(failed[ControlAccessEnforcement], "the clearance middleware is disabled")
+	}
+
+	var findings []Finding
+	selected := make([]SelectControl, 0, len(controls))
+	for _, control := range controls {
+		selected = append(selected, SelectControl{ControlID: control})
+		status := ObjectiveStatus{State: StateSatisfied}
+		if reasons := failed[control]; len(reasons) > 0 {
+			status = ObjectiveStatus{State: StateNotSatisfied, Remarks: strings.Join(reasons, "; ")}
+		}
+		findings = append(findings, Finding{
+			UUID:                newUUID("finding", start.Format(time.RFC3339), end.Format(time.RFC3339), control, status.State),
+			Title:               "Control " + strings.ToUpper(control),
+			Description:         fmt.Sprintf("%s assessed from %d observations.", strings.ToUpper(control), len(related[control])),
+			Target:              FindingTarget{Type: "objective-id", TargetID: control + "_obj", Status: status},
+			RelatedObservations: related[control],
+		})
+	}
+
+	title := system.title()
+	return &AssessmentResultsDocument{AssessmentResults: &AssessmentResults{
+		UUID:     newUUID("assessment-results", title, start.Format(time.RFC3339), end.Format(time.RFC3339), digest, now.Format(time.RFC3339)),
+		Metadata: newMetadata(title+" assessment results", system, now),
+		ImportAP: ImportAP{Href: importAP},
+		Results: []Result{{
+			UUID:             newUUID("result", title, start.Format(time.RFC3339), end.Format(time.RFC3339)),
+			Title:            title + " automated assessment",
+			Description:      "Controls assessed from the active policy and the audit trail recorded by " + title + ".",
+			Start:            start,
+			End:              end,
+			ReviewedControls: ReviewedControls{ControlSelections: []ControlSelection{{IncludeControls: selected}}},
+			Observations:     observations,
+			Findings:         findings,
+			Remarks:          remarks,
+		}},
+	}}
+}
@@ -0,0 +1,230 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// LintCheck identifies the kind of problem a lint finding reports
+type LintCheck string
+
+const (
+	LintUnreachable     LintCheck = "unreachable"      // Rule is always shadowed by an earlier or higher-priority rule
+	LintUnconstrained   LintCheck = "unconstrained"    // Rule matches every request
+	LintWildcardAllow   LintCheck = "wildcard-allow"   // Allow rule for all routes at high priority
+	LintUnusedClearance LintCheck = "unused-clearance" // No registered device meets the required clearance
+	LintExpired         LintCheck = "expired"          // Rule has passed expires_at and no longer matches
+	LintReviewOverdue   LintCheck = "review-overdue"   // Rule has passed its review_by date
+)
+
+// LintHighPriority is the priority at or above which wildcard allow rules are flagged
+const LintHighPriority = 50
+
+// LintFinding is a single lint result
+type LintFinding struct {
+	Check   LintCheck `json:"check"`
+	RuleID  string    `json:"rule_id"`
+	Message string    `json:"message"`
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s: rule %s: %s", f.Check, f.RuleID, f.Message)
+}
+
+// Lint checks a policy for rules that are valid but likely mistakes.
+// The registry is optional; without it clearance usage is not checked.
+func Lint(policy *Policy, registry *models.DeviceRegistry) []LintFinding {
+	return lint(policy, registry, time.Now().UTC())
+}
+
+// lint checks a policy, judging rule expiry and review dates at now
+func lint(policy *Policy, registry *models.DeviceRegistry, now time.Time) []LintFinding {
+	var findings []LintFinding
+
+	lowest := 0
+	for i, rule := range policy.Rules {
+		if i == 0 || rule.Priority < lowest {
+			lowest = rule.Priority
+		}
+	}
+
+	var devices []*models.Device
+	if registry != nil {
+		devices = registry.ListDevices()
+	}
+
+	for i, rule := range policy.Rules {
+		for j, other := range policy.Rules {
+			// Evaluation keeps the first rule among equal priorities
+			if i == j || other.Expired(now) || other.Priority < rule.Priority || (other.Priority == rule.Priority && j > i) {
+				continue
+			}
+			if covers(other, rule) {
+				findings = append(findings, LintFinding{
+					Check:   LintUnreachable,
+					RuleID:  rule.ID,
+					Message: fmt.Sprintf("shadowed by rule %s (priority %d)", other.ID, other.Priority),
+				})
+				break
+			}
+		}
+
+		// A catch-all deny at the lowest priority is the intended default
+		if unconstrained(rule) && !(rule.Effect == EffectDeny && rule.Priority == lowest) {
+			findings = append(findings, LintFinding{
+				Check:   LintUnconstrained,
+				RuleID:  rule.ID,
+				Message: fmt.Sprintf("%s rule matches every request", rule.Effect),
+			})
+		}
+
+		if rule.Effect == EffectAllow && rule.Priority >= LintHighPriority && allRoutes(rule.Routes) {
+			findings = append(findings, LintFinding{
+				Check:   LintWildcardAllow,
+				RuleID:  rule.ID,
+				Message: fmt.Sprintf("allows all routes at priority %d", rule.Priority),
+			})
+		}
+
+		if rule.Expired(now) {
+			findings = append(findings, LintFinding{
+				Check:   LintExpired,
+				RuleID:  rule.ID,
+				Message: fmt.Sprintf("expired at %s and no longer matches", rule.ExpiresAt.Format(time.RFC3339)),
+			})
+		} else if rule.ReviewDue(now) {
+			findings = append(findings, LintFinding{
+				Check:   LintReviewOverdue,
+				RuleID:  rule.ID,
+				Message: fmt.Sprintf("review was due by %s", rule.ReviewBy.Format(time.RFC3339)),
+			})
+		}
+
+		if rule.RequiredClearance > 0 && len(devices) > 0 && !clearanceInUse(devices, rule.RequiredClearance) {
+			findings = append(findings, LintFinding{
+				Check:   LintUnusedClearance,
+				RuleID:  rule.ID,
+				Message: fmt.Sprintf("no registered device holds clearance %s or higher", rule.RequiredClearance),
+			})
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].RuleID < findings[j].RuleID
+	})
+
+	return findings
+}
+
+// covers reports whether every request matched by inner is also matched by outer
+func covers(outer, inner *Rule) bool {
+	for _, route := range routesOrWildcard(inner.Routes) {
+		if !routeCovered(outer.Routes, route) {
+			return false
+		}
+	}
+	for _, method := range methodsOrWildcard(inner.Methods) {
+		if !matchesMethod(outer.Methods, method) {
+			return false
+		}
+	}
+
+	if outer.RequiredClearance > 0 && !inner.RequiredClearance.IsHigherOrEqual(outer.RequiredClearance) {
+		return false
+	}
+	if len(outer.AllowedLayers) > 0 {
+		if len(inner.AllowedLayers) == 0 {
+			return false
+		}
+		for _, layer := range inner.AllowedLayers {
+			if !containsLayer(outer.AllowedLayers, layer) {
+				return false
+			}
+		}
+	}
+	if len(outer.AllowedDevices) > 0 {
+		if len(inner.AllowedDevices) == 0 {
+			return false
+		}
+		for _, device := range inner.AllowedDevices {
+			if !containsDevice(outer.AllowedDevices, device) {
+				return false
+			}
+		}
+	}
+	// Denied devices make the inner rule match regardless of its other device constraints
+	for _, device := range inner.DeniedDevices {
+		if containsDevice(outer.DeniedDevices, device) {
+			continue
+		}
+		if len(outer.AllowedDevices) > 0 && !containsDevice(outer.AllowedDevices, device) || outer.DeviceSelector != "" {
+			return false
+		}
+	}
+	if outer.DeviceSelector != "" && outer.DeviceSelector != inner.DeviceSelector {
+		return false
+	}
+
+	return true
+}
+
+// routeCovered reports whether a route pattern only matches paths the patterns also match
+func routeCovered(patterns []string, route string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if pattern == "*" || pattern == route {
+			return true
+		}
+		if strings.HasSuffix(pattern, "*") && strings.HasPrefix(route, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unconstrained reports whether a rule matches every request
+func unconstrained(rule *Rule) bool {
+	return allRoutes(rule.Routes) &&
+		(len(rule.Methods) == 0 || containsString(rule.Methods, "*")) &&
+		rule.RequiredClearance == 0 &&
+		len(rule.AllowedLayers) == 0 &&
+		len(rule.AllowedDevices) == 0 &&
+		rule.DeviceSelector == ""
+}
+
+// allRoutes reports whether the route patterns match every path
+func allRoutes(routes []string) bool {
+	return len(routes) == 0 || containsString(routes, "*")
+}
+
+func routesOrWildcard(routes []string) []string {
+	if len(routes) == 0 {
+		return []string{"*"}
+	}
+	return routes
+}
+
+func methodsOrWildcard(methods []string) []string {
+	if len(methods) == 0 {
+		return []string{"*"}
+	}
+	return methods
+}
+
+// clearanceInUse reports whether any device meets the clearance
+func clearanceInUse(devices []*models.Device, clearance models.Clearance) bool {
+	for _, device := range devices {
+		if device.Clearance.IsHigherOrEqual(clearance) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+func lintChecks(findings []LintFinding) map[string][]LintCheck {
+	checks := make(map[string][]LintCheck)
+	for _, f := range findings {
+		checks[f.RuleID] = append(checks[f.RuleID], f.Check)
+	}
+	return checks
+}
+
+func TestLint(t *testing.T) {
+	registry := models.NewDeviceRegistry()
+	registry.Register(&models.Device{ID: 1, Layer: models.LayerData, Clearance: models.ClearanceLevel5})
+
+	policy := &Policy{
+		Version: "1.0",
+		Rules: []*Rule{
+			{ID: "admin", Effect: EffectAllow, Routes: []string{"/api/admin/*"}, Methods: []string{"GET"}, Priority: 80},
+			{ID: "admin-users", Effect: EffectDeny, Routes: []string{"/api/admin/users"}, Methods: []string{"GET"}, Priority: 70},
+			{ID: "admin-post", Effect: EffectAllow, Routes: []string{"/api/admin/users"}, Methods: []string{"POST"}, Priority: 70},
+			{ID: "top-secret", Effect: EffectAllow, Routes: []string{"/api/secret"}, RequiredClearance: models.ClearanceLevel9, Priority: 60},
+			{ID: "open", Effect: EffectAllow, Routes: []string{"*"}, Methods: []string{"GET"}, Priority: 90},
+			{ID: "anything", Effect: EffectAllow, Priority: 5},
+			{ID: "deny-default", Effect: EffectDeny, Routes: []string{"*"}, Priority: 0},
+		},
+	}
+
+	checks := lintChecks(Lint(policy, registry))
+
+	tests := []struct {
+		ruleID string
+		want   []LintCheck
+	}{
+		{"admin", []LintCheck{LintUnreachable}},
+		{"admin-users", []LintCheck{LintUnreachable}},
+		{"admin-post", nil},
+		{"top-secret", []LintCheck{LintUnusedClearance}},
+		{"open", []LintCheck{LintWildcardAllow}},
+		{"anything", []LintCheck{LintUnconstrained}},
+		{"deny-default", []LintCheck{LintUnreachable}},
+	}
+
+	for _, tt := range tests {
+		got := checks[tt.ruleID]
+		if len(got) != len(tt.want) {
+			t.Errorf("rule %s: expected %v, got %v", tt.ruleID, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("rule %s: expected %v, got %v", tt.ruleID, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestLintCovers(t *testing.T) {
+	tests := []struct {
+		name         string
+		outer, inner *Rule
+		want         bool
+	}{
+		{
+			name:  "prefix covers nested prefix",
+			outer: &Rule{Routes: []string{"/api/*"}},
+			inner: &Rule{Routes: []string{"/api/admin/*"}, Methods: []string{"GET"}},
+			want:  true,
+		},
+		{
+			name:  "method subset not covered",
+			outer: &Rule{Routes: []string{"*"}, Methods: []string{"GET"}},
+			inner: &Rule{Routes: []string{"/x"}},
+			want:  false,
+		},
+		{
+			name:  "higher clearance not covered",
+			outer: &Rule{RequiredClearance: models.ClearanceLevel7},
+			inner: &Rule{RequiredClearance: models.ClearanceLevel5},
+			want:  false,
+		},
+		{
+			name:  "lower clearance covered",
+			outer: &Rule{RequiredClearance: models.ClearanceLevel3},
+			inner: &Rule{RequiredClearance: models.ClearanceLevel5},
+			want:  true,
+		},
+		{
+			name:  "layer restriction not covered",
+			outer: &Rule{AllowedLayers: []models.Layer{models.LayerData}},
+			inner: &Rule{},
+			want:  false,
+		},
+		{
+			name:  "selector not covered",
+			outer: &Rule{DeviceSelector: "site=a"},
+			inner: &Rule{DeviceSelector: "site=b"},
+			want:  false,
+		},
+		{
+			name:  "denied device escapes allow list",
+			outer: &Rule{AllowedDevices: []uint16{1}},
+			inner: &Rule{AllowedDevices: []uint16{1}, DeniedDevices: []uint16{2}},
+			want:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := covers(tt.outer, tt.inner); got != tt.want {
+				t.Errorf("covers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintExpiryAndReview(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.AddDate(0, 1, 0)
+
+	policy := &Policy{
+		Version: "1.0",
+		Rules: []*Rule{
+			{ID: "expired", Effect: EffectAllow, Routes: []string{"/api/*"}, Priority: 50, ExpiresAt: &past},
+			{ID: "overdue", Effect: EffectAllow, Routes: []string{"/api/data"}, Priority: 10, ReviewBy: &past},
+			{ID: "current", Effect: EffectAllow, Routes: []string{"/api/other"}, Priority: 10, ReviewBy: &future},
+		},
+	}
+
+	checks := lintChecks(lint(policy, nil, now))
+
+	// Expired rules shadow nothing, since they no longer match
+	if got := checks["overdue"]; len(got) != 1 || got[0] != LintReviewOverdue {
+		t.Errorf("overdue: expected [%s], got %v", LintReviewOverdue, got)
+	}
+	if got := checks["expired"]; len(got) != 1 || got[0] != LintExpired {
+		t.Errorf("expired: expected [%s], got %v", LintExpired, got)
+	}
+	if got := checks["current"]; len(got) != 0 {
+		t.Errorf("current: expected no findings, got %v", got)
+	}
+}
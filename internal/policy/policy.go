@@ -4,9 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/NSACodeGov/CodeGov/internal/audit"
 	"github.com/NSACodeGov/CodeGov/pkg/models"
 )
 
@@ -29,7 +33,21 @@ type Rule struct {
 	AllowedLayers     []models.Layer   `json:"allowed_layers,omitempty"`
 	AllowedDevices    []uint16         `json:"allowed_devices,omitempty"`
 	DeniedDevices     []uint16         `json:"denied_devices,omitempty"`
-	Priority          int              `json:"priority"` // Higher priority wins in conflicts
+	DeviceSelector    string           `json:"device_selector,omitempty"` // Label selector, e.g. "site=bldg-42,class in (sensor,gateway)"
+	AuditLevel        audit.Level      `json:"audit_level,omitempty"`     // Obligation: audit detail for matching requests
+	Priority          int              `json:"priority"`                  // Higher priority wins in conflicts
+	ExpiresAt         *time.Time       `json:"expires_at,omitempty"`      // Rule stops matching at this time
+	ReviewBy          *time.Time       `json:"review_by,omitempty"`       // Date the rule must be recertified by
+}
+
+// Expired reports whether the rule has passed its expiry time
+func (r *Rule) Expired(at time.Time) bool {
+	return r.ExpiresAt != nil && !at.Before(*r.ExpiresAt)
+}
+
+// ReviewDue reports whether the rule's review date falls before the given time
+func (r *Rule) ReviewDue(before time.Time) bool {
+	return r.ReviewBy != nil && r.ReviewBy.Before(before)
 }
 
 // Policy represents a collection of policy rules
@@ -40,30 +58,71 @@ type Policy struct {
 
 // Context represents the request context for policy evaluation
 type Context struct {
-	Route       string
-	Method      string
-	DeviceID    uint16
-	Layer       models.Layer
-	Clearance   models.Clearance
-	RequestID   string
-	SourceIP    string
-	TokenID     uint16
-	TokenOffset models.TokenOffset
+	Route       string             `json:"route"`
+	Method      string             `json:"method"`
+	DeviceID    uint16             `json:"device_id,omitempty"`
+	Layer       models.Layer       `json:"layer,omitempty"`
+	Clearance   models.Clearance   `json:"clearance,omitempty"`
+	RequestID   string             `json:"request_id,omitempty"`
+	SourceIP    string             `json:"source_ip,omitempty"`
+	TokenID     uint16             `json:"token_id,omitempty"`
+	TokenOffset models.TokenOffset `json:"token_offset,omitempty"`
 }
 
 // Decision represents a policy decision
 type Decision struct {
-	Effect   Effect
-	Reason   string
-	RuleID   string
-	RuleName string
+	Effect   Effect `json:"effect"`
+	Reason   string `json:"reason"`
+	RuleID   string `json:"rule_id,omitempty"`
+	RuleName string `json:"rule_name,omitempty"`
+
+	// AuditLevel is the audit detail obligation of the matched rule, if any
+	AuditLevel audit.Level `json:"audit_level,omitempty"`
+
+	// Remediation explains a deny decision; nil when the request is allowed
+	Remediation *Remediation `json:"remediation,omitempty"`
 }
 
 // Engine is the policy engine
 type Engine struct {
-	mu       sync.RWMutex
-	policy   *Policy
-	registry *models.DeviceRegistry
+	mu        sync.RWMutex
+	policy    *Policy
+	registry  *models.DeviceRegistry
+	selectors map[string]Selector // Parsed device selectors keyed by expression
+	recorder  *Recorder           // Optional decision recorder for replay
+	observer  func(Change)        // Notified after every policy mutation
+	now       func() time.Time    // Clock for rule expiry
+
+	generation uint64    // Incremented whenever a policy is installed
+	modified   time.Time // Time the active policy was installed
+
+	allowed atomic.Uint64 // Decisions made by Evaluate
+	denied  atomic.Uint64
+
+	cacheMu         sync.Mutex
+	selectorMatches map[selectorCacheKey]bool
+	cacheGeneration uint64
+}
+
+// Policy change actions
+const (
+	ChangeActionReload     = "reload"
+	ChangeActionRuleUpsert = "rule_upsert"
+	ChangeActionRuleRemove = "rule_remove"
+)
+
+// Change describes a mutation of the active policy
+type Change struct {
+	Action string
+	RuleID string  // Set for rule-level changes
+	Before *Policy // Policy before the change
+	After  *Policy // Policy after the change
+}
+
+// selectorCacheKey identifies a cached selector evaluation for a device
+type selectorCacheKey struct {
+	selector string
+	deviceID uint16
 }
 
 // NewEngine creates a new policy engine
@@ -73,7 +132,10 @@ func NewEngine(registry *models.DeviceRegistry) *Engine {
 			Version: "1.0",
 			Rules:   make([]*Rule, 0),
 		},
-		registry: registry,
+		registry:        registry,
+		selectors:       make(map[string]Selector),
+		selectorMatches: make(map[selectorCacheKey]bool),
+		now:             func() time.Time { return time.Now().UTC() },
 	}
 }
 
@@ -93,9 +155,7 @@ func (e *Engine) LoadFromFile(path string) error {
 		return fmt.Errorf("policy validation failed: %w", err)
 	}
 
-	e.mu.Lock()
-	defer e.mu.Unlock()
-	e.policy = &policy
+	e.setPolicy(&policy)
 
 	return nil
 }
@@ -111,10 +171,112 @@ func (e *Engine) LoadFromJSON(data []byte) error {
 		return fmt.Errorf("policy validation failed: %w", err)
 	}
 
+	e.setPolicy(&policy)
+
+	return nil
+}
+
+// SetObserver registers a function called after every policy mutation
+func (e *Engine) SetObserver(fn func(Change)) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	e.policy = &policy
+	e.observer = fn
+}
 
+// setPolicy installs a validated policy, rebuilds derived state and notifies the observer
+func (e *Engine) setPolicy(policy *Policy) {
+	e.mu.Lock()
+	e.installPolicy(policy, Change{Action: ChangeActionReload})
+}
+
+// installPolicy swaps in a validated policy and reports the change; it must be
+// called with e.mu held and releases it before notifying the observer
+func (e *Engine) installPolicy(policy *Policy, change Change) {
+	selectors := make(map[string]Selector)
+	for _, rule := range policy.Rules {
+		if rule.DeviceSelector == "" {
+			continue
+		}
+		// Already validated, so parsing cannot fail here
+		if selector, err := ParseSelector(rule.DeviceSelector); err == nil {
+			selectors[rule.DeviceSelector] = selector
+		}
+	}
+
+	change.Before = e.policy
+	change.After = policy
+	e.policy = policy
+	e.selectors = selectors
+	e.generation++
+	e.modified = time.Now().UTC()
+	observer := e.observer
+	e.mu.Unlock()
+
+	e.cacheMu.Lock()
+	e.selectorMatches = make(map[selectorCacheKey]bool)
+	e.cacheMu.Unlock()
+
+	if observer != nil {
+		observer(change)
+	}
+}
+
+// UpsertRule adds a rule or replaces the rule with the same ID. The write lock is
+// held from reading the active policy to installing the candidate, so concurrent
+// mutations cannot drop each other's rules.
+func (e *Engine) UpsertRule(rule *Rule) error {
+	if rule == nil {
+		return fmt.Errorf("rule is required")
+	}
+	e.mu.Lock()
+	current := e.policy
+	candidate := &Policy{
+		Version: current.Version,
+		Rules:   make([]*Rule, 0, len(current.Rules)+1),
+	}
+
+	replaced := false
+	for _, existing := range current.Rules {
+		if existing.ID == rule.ID {
+			candidate.Rules = append(candidate.Rules, rule)
+			replaced = true
+			continue
+		}
+		candidate.Rules = append(candidate.Rules, existing)
+	}
+	if !replaced {
+		candidate.Rules = append(candidate.Rules, rule)
+	}
+
+	if err := e.Validate(candidate); err != nil {
+		e.mu.Unlock()
+		return fmt.Errorf("policy validation failed: %w", err)
+	}
+
+	e.installPolicy(candidate, Change{Action: ChangeActionRuleUpsert, RuleID: rule.ID})
+	return nil
+}
+
+// RemoveRule removes the rule with the given ID
+func (e *Engine) RemoveRule(id string) error {
+	e.mu.Lock()
+	current := e.policy
+	candidate := &Policy{
+		Version: current.Version,
+		Rules:   make([]*Rule, 0, len(current.Rules)),
+	}
+
+	for _, existing := range current.Rules {
+		if existing.ID != id {
+			candidate.Rules = append(candidate.Rules, existing)
+		}
+	}
+	if len(candidate.Rules) == len(current.Rules) {
+		e.mu.Unlock()
+		return fmt.Errorf("rule %s not found", id)
+	}
+
+	e.installPolicy(candidate, Change{Action: ChangeActionRuleRemove, RuleID: id})
 	return nil
 }
 
@@ -129,6 +291,9 @@ func (e *Engine) Validate(policy *Policy) error {
 
 	for i, rule := range policy.Rules {
 		// Check required fields
+		if rule == nil {
+			return fmt.Errorf("rule %d: rule is empty", i)
+		}
 		if rule.ID == "" {
 			return fmt.Errorf("rule %d: ID is required", i)
 		}
@@ -169,6 +334,23 @@ func (e *Engine) Validate(policy *Policy) error {
 			}
 		}
 
+		// Validate audit obligation
+		if !audit.ValidLevel(rule.AuditLevel) {
+			return fmt.Errorf("rule %s: invalid audit level '%s'", rule.ID, rule.AuditLevel)
+		}
+
+		// Validate device selector
+		if rule.DeviceSelector != "" {
+			if _, err := ParseSelector(rule.DeviceSelector); err != nil {
+				return fmt.Errorf("rule %s: invalid device selector: %w", rule.ID, err)
+			}
+		}
+
+		// A review after expiry could never recertify the rule
+		if rule.ReviewBy != nil && rule.ExpiresAt != nil && rule.ReviewBy.After(*rule.ExpiresAt) {
+			return fmt.Errorf("rule %s: review_by is after expires_at", rule.ID)
+		}
+
 		// Check for conflicts with other rules
 		for j := i + 1; j < len(policy.Rules); j++ {
 			other := policy.Rules[j]
@@ -214,6 +396,36 @@ func (e *Engine) Evaluate(ctx *Context) *Decision {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
+	decision := e.evaluate(ctx)
+	if decision.Effect == EffectAllow {
+		e.allowed.Add(1)
+	} else {
+		e.denied.Add(1)
+	}
+
+	if e.recorder != nil {
+		e.recorder.Record(ctx, decision)
+	}
+
+	return decision
+}
+
+// Decisions returns the number of requests Evaluate has allowed and denied
+func (e *Engine) Decisions() (allowed, denied uint64) {
+	return e.allowed.Load(), e.denied.Load()
+}
+
+// Simulate evaluates a context without recording the decision.
+// It is intended for what-if queries such as permission inspection.
+func (e *Engine) Simulate(ctx *Context) *Decision {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.evaluate(ctx)
+}
+
+// evaluate performs the evaluation; callers must hold e.mu
+func (e *Engine) evaluate(ctx *Context) *Decision {
 	// Default deny
 	decision := &Decision{
 		Effect: EffectDeny,
@@ -222,9 +434,13 @@ func (e *Engine) Evaluate(ctx *Context) *Decision {
 
 	var matchedRule *Rule
 	highestPriority := -1
+	now := e.now()
 
-	// Find matching rules
+	// Find matching rules; expired rules no longer take part
 	for _, rule := range e.policy.Rules {
+		if rule.Expired(now) {
+			continue
+		}
 		if e.ruleMatches(rule, ctx) {
 			// Higher priority wins
 			if rule.Priority > highestPriority {
@@ -238,6 +454,7 @@ func (e *Engine) Evaluate(ctx *Context) *Decision {
 		decision.Effect = matchedRule.Effect
 		decision.RuleID = matchedRule.ID
 		decision.RuleName = matchedRule.Name
+		decision.AuditLevel = matchedRule.AuditLevel
 
 		if matchedRule.Effect == EffectAllow {
 			decision.Reason = fmt.Sprintf("allowed by rule '%s'", matchedRule.Name)
@@ -246,6 +463,10 @@ func (e *Engine) Evaluate(ctx *Context) *Decision {
 		}
 	}
 
+	if decision.Effect == EffectDeny {
+		decision.Remediation = e.remediation(ctx, matchedRule)
+	}
+
 	return decision
 }
 
@@ -281,9 +502,55 @@ func (e *Engine) ruleMatches(rule *Rule, ctx *Context) bool {
 		return false
 	}
 
+	// Check device labels
+	if rule.DeviceSelector != "" && !e.matchesSelector(rule.DeviceSelector, ctx.DeviceID) {
+		return false
+	}
+
 	return true
 }
 
+// matchesSelector evaluates a device selector against the registry,
+// caching results until the registry changes
+func (e *Engine) matchesSelector(expr string, deviceID uint16) bool {
+	if e.registry == nil || deviceID == 0 {
+		return false
+	}
+
+	selector, ok := e.selectors[expr]
+	if !ok {
+		return false
+	}
+
+	generation := e.registry.Generation()
+	key := selectorCacheKey{selector: expr, deviceID: deviceID}
+
+	e.cacheMu.Lock()
+	if generation != e.cacheGeneration {
+		e.selectorMatches = make(map[selectorCacheKey]bool)
+		e.cacheGeneration = generation
+	}
+	if matched, ok := e.selectorMatches[key]; ok {
+		e.cacheMu.Unlock()
+		return matched
+	}
+	e.cacheMu.Unlock()
+
+	device, err := e.registry.GetDevice(deviceID)
+	if err != nil {
+		return false
+	}
+	matched := selector.Matches(device.SelectorLabels())
+
+	e.cacheMu.Lock()
+	if generation == e.cacheGeneration {
+		e.selectorMatches[key] = matched
+	}
+	e.cacheMu.Unlock()
+
+	return matched
+}
+
 // matchesRoute checks if a route matches any pattern
 func matchesRoute(patterns []string, route string) bool {
 	if len(patterns) == 0 {
@@ -341,6 +608,55 @@ func containsDevice(devices []uint16, deviceID uint16) bool {
 	return false
 }
 
+// Generation returns a counter that changes whenever the active policy changes
+func (e *Engine) Generation() uint64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.generation
+}
+
+// LastModified returns the time the active policy was installed
+func (e *Engine) LastModified() time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.modified
+}
+
+// ExpiredRules returns the active policy's rules that have passed their expiry time
+func (e *Engine) ExpiredRules() []*Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	now := e.now()
+	var expired []*Rule
+	for _, rule := range e.policy.Rules {
+		if rule.Expired(now) {
+			expired = append(expired, rule)
+		}
+	}
+	return expired
+}
+
+// RulesDueForReview returns the rules whose review date falls within the given
+// period, including overdue ones, ordered by review date
+func (e *Engine) RulesDueForReview(within time.Duration) []*Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	deadline := e.now().Add(within)
+	var due []*Rule
+	for _, rule := range e.policy.Rules {
+		if rule.ReviewDue(deadline) {
+			due = append(due, rule)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].ReviewBy.Before(*due[j].ReviewBy)
+	})
+	return due
+}
+
 // GetPolicy returns a copy of the current policy
 func (e *Engine) GetPolicy() *Policy {
 	e.mu.RLock()
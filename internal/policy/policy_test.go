@@ -2,7 +2,10 @@ package policy
 
 import (
 	"encoding/json"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/NSACodeGov/CodeGov/pkg/models"
 )
@@ -69,6 +72,20 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid audit level",
+			policy: &Policy{
+				Version: "1.0",
+				Rules: []*Rule{
+					{
+						ID:         "rule1",
+						Effect:     EffectAllow,
+						AuditLevel: "verbose",
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid effect",
 			policy: &Policy{
@@ -245,6 +262,11 @@ func TestEvaluate(t *testing.T) {
 			}
 		})
 	}
+
+	engine.Simulate(&Context{Route: "/public", Method: "GET"})
+	if allowed, denied := engine.Decisions(); allowed != 3 || denied != 4 {
+		t.Errorf("expected 3 allowed and 4 denied decisions, got %d and %d", allowed, denied)
+	}
 }
 
 func TestCheckConflict(t *testing.T) {
@@ -329,3 +351,171 @@ func mustMarshal(p *Policy) []byte {
 	data, _ := json.Marshal(p)
 	return data
 }
+
+func TestRuleEditsNotifyObserver(t *testing.T) {
+	engine := NewEngine(nil)
+
+	var changes []Change
+	engine.SetObserver(func(c Change) {
+		changes = append(changes, c)
+	})
+
+	engine.LoadFromJSON(mustMarshal(&Policy{Version: "1.0"}))
+
+	rule := &Rule{ID: "allow-x", Name: "Allow x", Effect: EffectAllow, Routes: []string{"/x"}, Methods: []string{"GET"}, Priority: 10}
+	if err := engine.UpsertRule(rule); err != nil {
+		t.Fatalf("failed to upsert rule: %v", err)
+	}
+	if d := engine.Evaluate(&Context{Route: "/x", Method: "GET"}); d.Effect != EffectAllow {
+		t.Errorf("expected upserted rule to allow, got %s", d.Effect)
+	}
+
+	if err := engine.UpsertRule(&Rule{ID: "bad", Effect: "maybe"}); err == nil {
+		t.Error("expected error when upserting invalid rule")
+	}
+
+	if err := engine.RemoveRule("allow-x"); err != nil {
+		t.Fatalf("failed to remove rule: %v", err)
+	}
+	if err := engine.RemoveRule("allow-x"); err == nil {
+		t.Error("expected error when removing unknown rule")
+	}
+
+	wantActions := []string{ChangeActionReload, ChangeActionRuleUpsert, ChangeActionRuleRemove}
+	if len(changes) != len(wantActions) {
+		t.Fatalf("expected %d changes, got %d", len(wantActions), len(changes))
+	}
+	for i, action := range wantActions {
+		if changes[i].Action != action {
+			t.Errorf("change %d: expected action %s, got %s", i, action, changes[i].Action)
+		}
+	}
+	if len(changes[1].After.Rules) != 1 || len(changes[2].After.Rules) != 0 {
+		t.Error("unexpected policy snapshots in change events")
+	}
+}
+
+func TestConcurrentRuleEdits(t *testing.T) {
+	engine := NewEngine(nil)
+	engine.LoadFromJSON(mustMarshal(&Policy{Version: "1.0"}))
+
+	const writers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rule := &Rule{ID: fmt.Sprintf("rule-%d", i), Effect: EffectAllow, Routes: []string{fmt.Sprintf("/r/%d", i)}, Methods: []string{"GET"}}
+			if err := engine.UpsertRule(rule); err != nil {
+				t.Errorf("failed to upsert %s: %v", rule.ID, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := len(engine.GetPolicy().Rules); got != writers {
+		t.Fatalf("expected %d rules after concurrent upserts, got %d", writers, got)
+	}
+
+	for i := 0; i < writers; i += 2 {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := engine.RemoveRule(fmt.Sprintf("rule-%d", i)); err != nil {
+				t.Errorf("failed to remove rule-%d: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := len(engine.GetPolicy().Rules); got != writers/2 {
+		t.Errorf("expected %d rules after concurrent removals, got %d", writers/2, got)
+	}
+}
+
+func TestGenerationAdvancesOnChange(t *testing.T) {
+	engine := NewEngine(nil)
+	start := engine.Generation()
+
+	engine.LoadFromJSON(mustMarshal(&Policy{Version: "1.0"}))
+	engine.UpsertRule(&Rule{ID: "bad", Effect: "maybe"})
+	if got := engine.Generation(); got != start+1 {
+		t.Errorf("expected generation %d after one successful load, got %d", start+1, got)
+	}
+	if engine.LastModified().IsZero() {
+		t.Error("expected last modified time to be set")
+	}
+}
+
+func TestRuleExpiryAndReview(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(days int) *time.Time {
+		t := now.AddDate(0, 0, days)
+		return &t
+	}
+
+	engine := NewEngine(nil)
+	engine.now = func() time.Time { return now }
+	err := engine.LoadFromJSON([]byte(`{"version":"1.0","rules":[
+		{"id":"temp-access","name":"Temporary access","effect":"allow","routes":["/api/test"],"methods":["GET"],"priority":20,"expires_at":"2026-02-28T00:00:00Z"},
+		{"id":"legacy","name":"Legacy access","effect":"allow","routes":["/api/legacy"],"priority":10,"review_by":"2026-03-20T00:00:00Z"},
+		{"id":"base","name":"Base","effect":"deny","routes":["/api/test"],"priority":10,"review_by":"2026-02-01T00:00:00Z"},
+		{"id":"annual","name":"Annual","effect":"allow","routes":["/api/annual"],"priority":10,"review_by":"2026-12-01T00:00:00Z"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decision := engine.Evaluate(&Context{Route: "/api/test", Method: "GET"})
+	if decision.RuleID != "base" {
+		t.Errorf("expired rule still matched: %+v", decision)
+	}
+
+	expired := engine.ExpiredRules()
+	if len(expired) != 1 || expired[0].ID != "temp-access" {
+		t.Errorf("unexpected expired rules %v", expired)
+	}
+
+	due := engine.RulesDueForReview(30 * 24 * time.Hour)
+	if len(due) != 2 || due[0].ID != "base" || due[1].ID != "legacy" {
+		t.Errorf("expected overdue then upcoming reviews, got %v", due)
+	}
+
+	// Moving the clock past expiry changes nothing for rules without one
+	engine.now = func() time.Time { return *at(365) }
+	if decision := engine.Evaluate(&Context{Route: "/api/annual", Method: "GET"}); decision.RuleID != "annual" {
+		t.Errorf("rules without expiry must keep matching: %+v", decision)
+	}
+
+	bad := &Policy{Version: "1.0", Rules: []*Rule{
+		{ID: "r", Effect: EffectAllow, ExpiresAt: at(10), ReviewBy: at(20)},
+	}}
+	if err := engine.Validate(bad); err == nil {
+		t.Error("expected review_by after expires_at to be rejected")
+	}
+}
+
+func FuzzLoadFromJSON(f *testing.F) {
+	f.Add([]byte(`{"version":"1.0","rules":[{"id":"r1","effect":"allow","routes":["/api/*"],"methods":["GET"],"required_clearance":50529027,"allowed_layers":["data"],"device_selector":"class in (sensor)","priority":10}]}`))
+	f.Add([]byte(`{"version":"1.0","rules":[{"id":"r1","effect":"deny","routes":["*"],"methods":["*"],"expires_at":"2030-01-01T00:00:00Z","review_by":"2029-01-01T00:00:00Z"}]}`))
+	f.Add([]byte(`{"version":"1.0","rules":[null]}`))
+	f.Add([]byte(`{"version":"1.0","rules":[{"id":"a","effect":"allow"},{"id":"a","effect":"allow"}]}`))
+
+	registry := models.NewDeviceRegistry()
+	registry.Register(&models.Device{ID: 1, Layer: models.LayerData, Class: models.DeviceClassSensor, Clearance: models.ClearanceLevel3})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		engine := NewEngine(registry)
+		if err := engine.LoadFromJSON(data); err != nil {
+			return
+		}
+		// A policy that loads must evaluate and lint without panicking
+		for _, ctx := range []*Context{
+			{Route: "/api/data", Method: "GET", DeviceID: 1, Layer: models.LayerData, Clearance: models.ClearanceLevel3},
+			{Route: "/", Method: "POST"},
+		} {
+			if decision := engine.Evaluate(ctx); decision == nil {
+				t.Fatal("expected a decision")
+			}
+		}
+		Lint(engine.GetPolicy(), registry)
+	})
+}
@@ -0,0 +1,99 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// Deny codes classify why a request was denied
+const (
+	DenyNoMatchingRule        = "no_matching_rule"             // No rule allows the route and method for anyone
+	DenyRule                  = "denied_by_rule"               // An explicit deny rule matched
+	DenyDeviceDenied          = "device_denied"                // A deny rule lists the device
+	DenyInsufficientClearance = "insufficient_clearance"       // An allow rule needs a higher clearance
+	DenyLayerNotAllowed       = "layer_not_allowed"            // An allow rule needs a different layer
+	DenyDeviceNotAllowed      = "device_not_allowed"           // An allow rule is limited to other devices
+	DenyDeviceRequired        = "device_registration_required" // An allow rule needs an identified, registered device
+)
+
+// Remediation tells a denied client what it lacked, so device firmware and operator
+// tooling can self-diagnose without parsing the prose reason
+type Remediation struct {
+	Code              string           `json:"code"`
+	RequiredClearance models.Clearance `json:"required_clearance,omitempty"` // Clearance the closest allow rule requires
+	AllowedLayers     []models.Layer   `json:"allowed_layers,omitempty"`     // Layers the closest allow rule accepts
+	DeviceRequired    bool             `json:"device_required,omitempty"`    // The closest allow rule needs a registered device
+	Hint              string           `json:"hint"`
+}
+
+// remediation explains a deny decision. For an explicit deny it reports the matched
+// rule; otherwise it finds the allow rule for the route and method that the request
+// came closest to satisfying and reports the requirements it missed. Callers must
+// hold e.mu.
+func (e *Engine) remediation(ctx *Context, matched *Rule) *Remediation {
+	if matched != nil {
+		if containsDevice(matched.DeniedDevices, ctx.DeviceID) {
+			return &Remediation{Code: DenyDeviceDenied, Hint: fmt.Sprintf("device %d is denied by rule '%s'", ctx.DeviceID, matched.Name)}
+		}
+		return &Remediation{Code: DenyRule, Hint: fmt.Sprintf("rule '%s' denies this request", matched.Name)}
+	}
+
+	var closest *Remediation
+	misses := -1
+	now := e.now()
+	for _, rule := range e.policy.Rules {
+		if rule.Effect != EffectAllow || rule.Expired(now) {
+			continue
+		}
+		if !matchesRoute(rule.Routes, ctx.Route) || !matchesMethod(rule.Methods, ctx.Method) {
+			continue
+		}
+
+		r, n := e.missedRequirements(rule, ctx)
+		if closest == nil || n < misses {
+			closest, misses = r, n
+		}
+	}
+
+	if closest == nil {
+		return &Remediation{Code: DenyNoMatchingRule, Hint: fmt.Sprintf("no rule allows %s %s", ctx.Method, ctx.Route)}
+	}
+	return closest
+}
+
+// missedRequirements lists what the context lacks to match an allow rule and how
+// many requirements it missed
+func (e *Engine) missedRequirements(rule *Rule, ctx *Context) (*Remediation, int) {
+	r := &Remediation{}
+	var hints []string
+
+	if ctx.DeviceID == 0 && (len(rule.AllowedDevices) > 0 || rule.DeviceSelector != "") {
+		r.Code = DenyDeviceRequired
+		r.DeviceRequired = true
+		hints = append(hints, "identify as a registered device with X-Device-ID or X-Token-ID")
+	} else if (len(rule.AllowedDevices) > 0 && !containsDevice(rule.AllowedDevices, ctx.DeviceID)) ||
+		(rule.DeviceSelector != "" && !e.matchesSelector(rule.DeviceSelector, ctx.DeviceID)) {
+		r.Code = DenyDeviceNotAllowed
+		hints = append(hints, fmt.Sprintf("device %d is not among the devices allowed by rule '%s'", ctx.DeviceID, rule.Name))
+	}
+	if len(rule.AllowedLayers) > 0 && !containsLayer(rule.AllowedLayers, ctx.Layer) {
+		r.Code = DenyLayerNotAllowed
+		r.AllowedLayers = rule.AllowedLayers
+		hints = append(hints, fmt.Sprintf("layer must be one of %v", rule.AllowedLayers))
+	}
+	if rule.RequiredClearance > 0 && !ctx.Clearance.IsHigherOrEqual(rule.RequiredClearance) {
+		r.Code = DenyInsufficientClearance
+		r.RequiredClearance = rule.RequiredClearance
+		hints = append(hints, fmt.Sprintf("clearance level %d or higher is required", rule.RequiredClearance.Level()))
+	}
+
+	if len(hints) == 0 {
+		// Only a higher-priority rule stood in the way
+		r.Code = DenyRule
+		hints = append(hints, fmt.Sprintf("rule '%s' would allow this request but is overridden", rule.Name))
+	}
+	r.Hint = strings.Join(hints, "; ")
+	return r, len(hints)
+}
@@ -0,0 +1,113 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+func TestDenyRemediation(t *testing.T) {
+	engine := NewEngine(nil)
+	err := engine.LoadFromJSON(mustMarshal(&Policy{
+		Version: "1.0",
+		Rules: []*Rule{
+			{
+				ID:                "control-write",
+				Name:              "Control writes",
+				Effect:            EffectAllow,
+				Routes:            []string{"/api/control"},
+				Methods:           []string{"POST"},
+				RequiredClearance: models.ClearanceLevel7,
+				AllowedLayers:     []models.Layer{models.LayerControl},
+				Priority:          10,
+			},
+			{
+				ID:             "device-status",
+				Name:           "Device status",
+				Effect:         EffectAllow,
+				Routes:         []string{"/api/device/status"},
+				Methods:        []string{"GET"},
+				AllowedDevices: []uint16{1, 2},
+				Priority:       10,
+			},
+			{
+				ID:            "block-seven",
+				Name:          "Block device 7",
+				Effect:        EffectDeny,
+				Routes:        []string{"/api/device/config"},
+				Methods:       []string{"GET"},
+				DeniedDevices: []uint16{7},
+				Priority:      20,
+			},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("LoadFromJSON: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		ctx       *Context
+		code      string
+		clearance models.Clearance
+	}{
+		{
+			name:      "clearance too low",
+			ctx:       &Context{Route: "/api/control", Method: "POST", DeviceID: 1, Layer: models.LayerControl, Clearance: models.ClearanceLevel3},
+			code:      DenyInsufficientClearance,
+			clearance: models.ClearanceLevel7,
+		},
+		{
+			name: "wrong layer",
+			ctx:  &Context{Route: "/api/control", Method: "POST", DeviceID: 1, Layer: models.LayerData, Clearance: models.ClearanceLevel7},
+			code: DenyLayerNotAllowed,
+		},
+		{
+			name: "anonymous device",
+			ctx:  &Context{Route: "/api/device/status", Method: "GET"},
+			code: DenyDeviceRequired,
+		},
+		{
+			name: "device not in list",
+			ctx:  &Context{Route: "/api/device/status", Method: "GET", DeviceID: 3},
+			code: DenyDeviceNotAllowed,
+		},
+		{
+			name: "explicitly denied device",
+			ctx:  &Context{Route: "/api/device/config", Method: "GET", DeviceID: 7},
+			code: DenyDeviceDenied,
+		},
+		{
+			name: "no rule for route",
+			ctx:  &Context{Route: "/api/unknown", Method: "GET", DeviceID: 1},
+			code: DenyNoMatchingRule,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := engine.Evaluate(tt.ctx)
+			if decision.Effect != EffectDeny {
+				t.Fatalf("expected deny, got %s", decision.Effect)
+			}
+			rem := decision.Remediation
+			if rem == nil {
+				t.Fatal("expected remediation on deny")
+			}
+			if rem.Code != tt.code {
+				t.Errorf("code = %q, want %q (hint %q)", rem.Code, tt.code, rem.Hint)
+			}
+			if rem.RequiredClearance != tt.clearance {
+				t.Errorf("required clearance = %v, want %v", rem.RequiredClearance, tt.clearance)
+			}
+			if rem.Hint == "" {
+				t.Error("expected a hint")
+			}
+		})
+	}
+
+	allowed := engine.Evaluate(&Context{Route: "/api/device/status", Method: "GET", DeviceID: 1})
+	if allowed.Effect != EffectAllow || allowed.Remediation != nil {
+		t.Errorf("allowed decision = %+v, want allow without remediation", allowed)
+	}
+}
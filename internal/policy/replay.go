@@ -0,0 +1,164 @@
+package policy
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ReplayRecord is a single recorded evaluation
+type ReplayRecord struct {
+	Timestamp time.Time `json:"ts"`
+	Context   *Context  `json:"ctx"`
+	Decision  *Decision `json:"decision"`
+}
+
+// Recorder writes every evaluation input and decision as JSON lines
+type Recorder struct {
+	mu     sync.Mutex
+	closer io.Closer
+	enc    *json.Encoder
+	now    func() time.Time
+}
+
+// NewRecorder creates a recorder writing to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		enc: json.NewEncoder(w),
+		now: func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// NewFileRecorder creates a recorder appending to the replay file at path
+func NewFileRecorder(path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replay file: %w", err)
+	}
+
+	r := NewRecorder(file)
+	r.closer = file
+	return r, nil
+}
+
+// Record appends an evaluation to the replay log
+func (r *Recorder) Record(ctx *Context, decision *Decision) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Copy so later mutations by the caller do not leak into the record
+	ctxCopy := *ctx
+	decisionCopy := *decision
+
+	return r.enc.Encode(&ReplayRecord{
+		Timestamp: r.now(),
+		Context:   &ctxCopy,
+		Decision:  &decisionCopy,
+	})
+}
+
+// Close closes the underlying file, if any
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+	return nil
+}
+
+// SetRecorder enables decision recording; pass nil to disable it
+func (e *Engine) SetRecorder(r *Recorder) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.recorder = r
+}
+
+// ReplayDiff describes an evaluation whose outcome changed under the candidate policy
+type ReplayDiff struct {
+	Timestamp time.Time `json:"ts"`
+	Context   *Context  `json:"ctx"`
+	Recorded  *Decision `json:"recorded"`
+	Candidate *Decision `json:"candidate"`
+}
+
+// ReplayReport summarises a replay run
+type ReplayReport struct {
+	Total        int          `json:"total"`
+	Changed      int          `json:"changed"`
+	NewlyAllowed int          `json:"newly_allowed"`
+	NewlyDenied  int          `json:"newly_denied"`
+	RuleChanged  int          `json:"rule_changed"`
+	Diffs        []ReplayDiff `json:"diffs,omitempty"`
+}
+
+// Replay re-evaluates every recorded context against the candidate engine
+// and reports decisions that differ from what was recorded
+func Replay(r io.Reader, candidate *Engine) (*ReplayReport, error) {
+	report := &ReplayReport{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record ReplayRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d: failed to parse replay record: %w", line, err)
+		}
+		if record.Context == nil || record.Decision == nil {
+			return nil, fmt.Errorf("line %d: incomplete replay record", line)
+		}
+
+		report.Total++
+		decision := candidate.Evaluate(record.Context)
+
+		if decision.Effect == record.Decision.Effect && decision.RuleID == record.Decision.RuleID {
+			continue
+		}
+
+		report.Changed++
+		switch {
+		case decision.Effect == EffectAllow && record.Decision.Effect == EffectDeny:
+			report.NewlyAllowed++
+		case decision.Effect == EffectDeny && record.Decision.Effect == EffectAllow:
+			report.NewlyDenied++
+		default:
+			report.RuleChanged++
+		}
+
+		report.Diffs = append(report.Diffs, ReplayDiff{
+			Timestamp: record.Timestamp,
+			Context:   record.Context,
+			Recorded:  record.Decision,
+			Candidate: decision,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read replay log: %w", err)
+	}
+
+	return report, nil
+}
+
+// ReplayFile replays the decision log at path against the candidate engine
+func ReplayFile(path string, candidate *Engine) (*ReplayReport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replay file: %w", err)
+	}
+	defer file.Close()
+
+	return Replay(file, candidate)
+}
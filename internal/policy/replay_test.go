@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	recorded := NewEngine(nil)
+	recorded.LoadFromJSON(mustMarshal(&Policy{
+		Version: "1.0",
+		Rules: []*Rule{
+			{ID: "allow-public", Name: "Allow public", Effect: EffectAllow, Routes: []string{"/public"}, Methods: []string{"*"}, Priority: 10},
+			{ID: "allow-admin", Name: "Allow admin", Effect: EffectAllow, Routes: []string{"/admin"}, Methods: []string{"*"}, Priority: 10},
+		},
+	}))
+
+	var buf bytes.Buffer
+	recorded.SetRecorder(NewRecorder(&buf))
+
+	recorded.Evaluate(&Context{Route: "/public", Method: "GET"})
+	recorded.Evaluate(&Context{Route: "/admin", Method: "GET"})
+	recorded.Evaluate(&Context{Route: "/other", Method: "GET"})
+
+	if lines := strings.Count(buf.String(), "\n"); lines != 3 {
+		t.Fatalf("expected 3 recorded evaluations, got %d", lines)
+	}
+
+	candidate := NewEngine(nil)
+	candidate.LoadFromJSON(mustMarshal(&Policy{
+		Version: "1.0",
+		Rules: []*Rule{
+			{ID: "allow-public", Name: "Allow public", Effect: EffectAllow, Routes: []string{"/public"}, Methods: []string{"*"}, Priority: 10},
+			{ID: "allow-other", Name: "Allow other", Effect: EffectAllow, Routes: []string{"/other"}, Methods: []string{"*"}, Priority: 10},
+		},
+	}))
+
+	report, err := Replay(&buf, candidate)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+
+	if report.Total != 3 {
+		t.Errorf("expected 3 replayed evaluations, got %d", report.Total)
+	}
+	if report.Changed != 2 {
+		t.Errorf("expected 2 changed decisions, got %d", report.Changed)
+	}
+	if report.NewlyDenied != 1 || report.NewlyAllowed != 1 {
+		t.Errorf("expected 1 newly denied and 1 newly allowed, got %d/%d", report.NewlyDenied, report.NewlyAllowed)
+	}
+	if len(report.Diffs) != 2 || report.Diffs[0].Context.Route != "/admin" {
+		t.Errorf("unexpected diffs: %+v", report.Diffs)
+	}
+}
+
+func TestReplayInvalidRecord(t *testing.T) {
+	if _, err := Replay(strings.NewReader("not json\n"), NewEngine(nil)); err == nil {
+		t.Error("expected error for malformed replay log")
+	}
+}
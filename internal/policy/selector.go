@@ -0,0 +1,194 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SelectorOperator is the comparison used by a selector requirement
+type SelectorOperator string
+
+const (
+	SelectorEquals       SelectorOperator = "="
+	SelectorNotEquals    SelectorOperator = "!="
+	SelectorIn           SelectorOperator = "in"
+	SelectorNotIn        SelectorOperator = "notin"
+	SelectorExists       SelectorOperator = "exists"
+	SelectorDoesNotExist SelectorOperator = "!"
+)
+
+// Requirement is a single label match expression
+type Requirement struct {
+	Key      string
+	Operator SelectorOperator
+	Values   []string
+}
+
+// Selector is a conjunction of label requirements
+type Selector []Requirement
+
+// ParseSelector parses a label selector such as
+// "site=bldg-42,class in (sensor,gateway),!decommissioned"
+func ParseSelector(expr string) (Selector, error) {
+	var selector Selector
+
+	for _, term := range splitSelectorTerms(expr) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		req, err := parseRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+		selector = append(selector, req)
+	}
+
+	if len(selector) == 0 {
+		return nil, fmt.Errorf("empty selector")
+	}
+
+	return selector, nil
+}
+
+// splitSelectorTerms splits on commas that are not inside parentheses
+func splitSelectorTerms(expr string) []string {
+	var terms []string
+	depth := 0
+	start := 0
+
+	for i, c := range expr {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, expr[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(terms, expr[start:])
+}
+
+func parseRequirement(term string) (Requirement, error) {
+	if strings.HasPrefix(term, "!") {
+		key := strings.TrimSpace(term[1:])
+		if !validLabelKey(key) {
+			return Requirement{}, fmt.Errorf("invalid label key in %q", term)
+		}
+		return Requirement{Key: key, Operator: SelectorDoesNotExist}, nil
+	}
+
+	if idx := strings.Index(term, "!="); idx >= 0 {
+		return newValueRequirement(term, term[:idx], SelectorNotEquals, term[idx+2:])
+	}
+	if idx := strings.Index(term, "=="); idx >= 0 {
+		return newValueRequirement(term, term[:idx], SelectorEquals, term[idx+2:])
+	}
+	if idx := strings.Index(term, "="); idx >= 0 {
+		return newValueRequirement(term, term[:idx], SelectorEquals, term[idx+1:])
+	}
+
+	fields := strings.Fields(term)
+	if len(fields) >= 2 {
+		op := SelectorOperator(strings.ToLower(fields[1]))
+		if op == SelectorIn || op == SelectorNotIn {
+			// The key may itself contain "in", so skip past it before stripping the operator
+			rest := strings.TrimLeft(strings.TrimPrefix(strings.TrimSpace(term), fields[0]), " \t")
+			rest = strings.TrimSpace(rest[len(fields[1]):])
+			if !strings.HasPrefix(rest, "(") || !strings.HasSuffix(rest, ")") {
+				return Requirement{}, fmt.Errorf("expected parenthesised value list in %q", term)
+			}
+
+			var values []string
+			for _, v := range strings.Split(rest[1:len(rest)-1], ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					values = append(values, v)
+				}
+			}
+			if len(values) == 0 {
+				return Requirement{}, fmt.Errorf("empty value list in %q", term)
+			}
+			if !validLabelKey(fields[0]) {
+				return Requirement{}, fmt.Errorf("invalid label key in %q", term)
+			}
+			return Requirement{Key: fields[0], Operator: op, Values: values}, nil
+		}
+	}
+
+	if len(fields) == 1 && validLabelKey(fields[0]) {
+		return Requirement{Key: fields[0], Operator: SelectorExists}, nil
+	}
+
+	return Requirement{}, fmt.Errorf("invalid selector term %q", term)
+}
+
+func newValueRequirement(term, key string, op SelectorOperator, value string) (Requirement, error) {
+	key = strings.TrimSpace(key)
+	value = strings.TrimSpace(value)
+	if !validLabelKey(key) {
+		return Requirement{}, fmt.Errorf("invalid label key in %q", term)
+	}
+	return Requirement{Key: key, Operator: op, Values: []string{value}}, nil
+}
+
+// validLabelKey checks that a key contains only label-safe characters
+func validLabelKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '/') {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches reports whether the labels satisfy every requirement
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, req := range s {
+		if !req.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches reports whether the labels satisfy the requirement
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+
+	switch r.Operator {
+	case SelectorExists:
+		return ok
+	case SelectorDoesNotExist:
+		return !ok
+	case SelectorEquals:
+		return ok && value == r.Values[0]
+	case SelectorNotEquals:
+		return !ok || value != r.Values[0]
+	case SelectorIn:
+		return ok && containsString(r.Values, value)
+	case SelectorNotIn:
+		return !ok || !containsString(r.Values, value)
+	}
+
+	return false
+}
+
+// containsString checks if a string is in the list
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantLen int
+		wantKey string
+		wantErr bool
+	}{
+		{name: "equality", expr: "site=bldg-42", wantLen: 1},
+		{name: "double equals", expr: "site==bldg-42", wantLen: 1},
+		{name: "inequality", expr: "site!=bldg-42", wantLen: 1},
+		{name: "set membership", expr: "class in (sensor,gateway)", wantLen: 1},
+		{name: "set exclusion", expr: "class notin (actuator)", wantLen: 1},
+		{name: "key containing in", expr: "domain in (a,b)", wantLen: 1, wantKey: "domain"},
+		{name: "key ending in in", expr: "main in (x)", wantLen: 1, wantKey: "main"},
+		{name: "key containing notin", expr: "notinventory notin (x)", wantLen: 1, wantKey: "notinventory"},
+		{name: "key starting with in", expr: "  index  IN  (1, 2)", wantLen: 1, wantKey: "index"},
+		{name: "exists", expr: "site", wantLen: 1},
+		{name: "does not exist", expr: "!decommissioned", wantLen: 1},
+		{name: "combined", expr: "site=bldg-42, class in (sensor, gateway), !decommissioned", wantLen: 3},
+		{name: "empty", expr: "", wantErr: true},
+		{name: "missing parens", expr: "class in sensor", wantErr: true},
+		{name: "empty set", expr: "class in ()", wantErr: true},
+		{name: "invalid key", expr: "si te=x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := ParseSelector(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSelector(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if !tt.wantErr && len(selector) != tt.wantLen {
+				t.Errorf("expected %d requirements, got %d", tt.wantLen, len(selector))
+			}
+			if tt.wantKey != "" && selector[0].Key != tt.wantKey {
+				t.Errorf("expected key %q, got %q", tt.wantKey, selector[0].Key)
+			}
+		})
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	labels := map[string]string{
+		"site":  "bldg-42",
+		"class": "sensor",
+	}
+
+	tests := []struct {
+		expr  string
+		match bool
+	}{
+		{"site=bldg-42", true},
+		{"site=bldg-7", false},
+		{"site!=bldg-7", true},
+		{"class in (sensor,gateway)", true},
+		{"class notin (sensor,gateway)", false},
+		{"site", true},
+		{"rack", false},
+		{"!rack", true},
+		{"site=bldg-42,class in (actuator)", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			selector, err := ParseSelector(tt.expr)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			if got := selector.Matches(labels); got != tt.match {
+				t.Errorf("expected match %v, got %v", tt.match, got)
+			}
+		})
+	}
+}
+
+func TestEvaluateDeviceSelector(t *testing.T) {
+	registry := models.NewDeviceRegistry()
+	registry.Register(&models.Device{ID: 1, Class: models.DeviceClassSensor, Layer: models.LayerData, Labels: map[string]string{"site": "bldg-42"}})
+	registry.Register(&models.Device{ID: 2, Class: models.DeviceClassActuator, Layer: models.LayerData, Labels: map[string]string{"site": "bldg-42"}})
+
+	engine := NewEngine(registry)
+	err := engine.LoadFromJSON(mustMarshal(&Policy{
+		Version: "1.0",
+		Rules: []*Rule{
+			{
+				ID:             "allow-site",
+				Name:           "Allow bldg-42 sensors",
+				Effect:         EffectAllow,
+				Routes:         []string{"/telemetry"},
+				Methods:        []string{"*"},
+				DeviceSelector: "site=bldg-42,class in (sensor,gateway)",
+				Priority:       10,
+			},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+
+	ctx := &Context{Route: "/telemetry", Method: "GET", DeviceID: 1}
+	if d := engine.Evaluate(ctx); d.Effect != EffectAllow {
+		t.Errorf("expected device 1 to be allowed, got %s", d.Effect)
+	}
+
+	ctx.DeviceID = 2
+	if d := engine.Evaluate(ctx); d.Effect != EffectDeny {
+		t.Errorf("expected device 2 to be denied, got %s", d.Effect)
+	}
+
+	// Relabelling invalidates cached selector results
+	registry.SetLabels(1, map[string]string{"site": "bldg-7"})
+	ctx.DeviceID = 1
+	if d := engine.Evaluate(ctx); d.Effect != EffectDeny {
+		t.Errorf("expected relabelled device 1 to be denied, got %s", d.Effect)
+	}
+}
+
+// TestEvaluateWhileRelabelling is meant for -race: registry updates must not
+// change devices that policy evaluation is reading
+func TestEvaluateWhileRelabelling(t *testing.T) {
+	registry := models.NewDeviceRegistry()
+	registry.Register(&models.Device{ID: 1, Class: models.DeviceClassSensor, Layer: models.LayerData, Clearance: models.ClearanceLevel5, Labels: map[string]string{"site": "bldg-42"}})
+
+	engine := NewEngine(registry)
+	err := engine.LoadFromJSON(mustMarshal(&Policy{
+		Version: "1.0",
+		Rules: []*Rule{
+			{ID: "allow-site", Effect: EffectAllow, Routes: []string{"/telemetry"}, Methods: []string{"*"}, DeviceSelector: "site=bldg-42", Priority: 10},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			site := "bldg-42"
+			if i%2 == 1 {
+				site = "bldg-7"
+			}
+			registry.SetLabels(1, map[string]string{"site": site})
+			registry.SetClearance(1, models.ClearanceLevel5+models.Clearance(i%2)*0x01010101)
+		}
+	}()
+
+	for deadline := time.Now().Add(100 * time.Millisecond); time.Now().Before(deadline); {
+		if d := engine.Evaluate(&Context{Route: "/telemetry", Method: "GET", DeviceID: 1}); d.Effect != EffectAllow && d.Effect != EffectDeny {
+			t.Fatalf("unexpected effect %s", d.Effect)
+		}
+		device, err := registry.GetDevice(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = device.Clearance.Level()
+		_ = device.SelectorLabels()
+	}
+	close(stop)
+	<-done
+}
+
+func TestValidateInvalidSelector(t *testing.T) {
+	engine := NewEngine(nil)
+	policy := &Policy{
+		Version: "1.0",
+		Rules: []*Rule{
+			{ID: "bad", Effect: EffectAllow, DeviceSelector: "class in sensor"},
+		},
+	}
+
+	if err := engine.Validate(policy); err == nil {
+		t.Error("expected error for invalid device selector")
+	}
+}
+
+func FuzzParseSelector(f *testing.F) {
+	for _, expr := range []string{"site=bldg-42", "site==x,class!=y", "class in (sensor, gateway)", "class notin (actuator)", "!decommissioned", "a in (", "in ()", ","} {
+		f.Add(expr)
+	}
+
+	labels := map[string]string{"site": "bldg-42", "class": "sensor"}
+	f.Fuzz(func(t *testing.T, expr string) {
+		selector, err := ParseSelector(expr)
+		if err != nil {
+			return
+		}
+		if len(selector) == 0 {
+			t.Fatalf("ParseSelector(%q) returned an empty selector", expr)
+		}
+		selector.Matches(labels)
+		selector.Matches(nil)
+	})
+}
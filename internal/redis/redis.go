@@ -0,0 +1,231 @@
+// Package redis tracks Redis availability so features backed by Redis can fall
+// back to local state instead of failing individually while it is down.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultProbeInterval is how often the monitor pings Redis
+const DefaultProbeInterval = 5 * time.Second
+
+// ErrUnavailable is returned by Redis-backed features while their Monitor reports Redis down
+var ErrUnavailable = errors.New("redis unavailable")
+
+// Ping opens a connection to Redis, authenticates if a password is set, and sends PING
+func Ping(ctx context.Context, endpoint, password string) error {
+	_, err := Do(ctx, endpoint, password, "PING")
+	return err
+}
+
+// Do opens a connection to Redis, authenticates if a password is set, and sends one
+// command. Simple-string, integer and bulk-string replies are returned as strings;
+// a nil bulk reply is returned as "".
+func Do(ctx context.Context, endpoint, password string, args ...string) (string, error) {
+	reply, err := DoReply(ctx, endpoint, password, args...)
+	if err != nil {
+		return "", err
+	}
+	switch v := reply.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("%s: unexpected array reply", args[0])
+}
+
+// DoReply is Do for commands that may answer with arrays, such as XRANGE. Strings
+// and integers are returned as string, nil bulk strings and arrays as nil, and
+// arrays as []interface{} of the same types.
+func DoReply(ctx context.Context, endpoint, password string, args ...string) (interface{}, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(2 * time.Second)
+	}
+	conn.SetDeadline(deadline)
+
+	r := bufio.NewReader(conn)
+	if password != "" {
+		if _, err := command(conn, r, "AUTH", password); err != nil {
+			return nil, fmt.Errorf("AUTH: %w", err)
+		}
+	}
+	return command(conn, r, args...)
+}
+
+// command sends a RESP command and reads its reply
+func command(conn net.Conn, r *bufio.Reader, args ...string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		return nil, err
+	}
+	return readReply(r)
+}
+
+// readReply reads one RESP reply, recursing into arrays
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	switch {
+	case strings.HasPrefix(line, "-"):
+		return nil, fmt.Errorf("%s", strings.TrimPrefix(line, "-"))
+	case strings.HasPrefix(line, "+"), strings.HasPrefix(line, ":"):
+		return line[1:], nil
+	case strings.HasPrefix(line, "$"):
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2) // The bulk string and its CRLF
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case strings.HasPrefix(line, "*"):
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply %q", line)
+}
+
+// Monitor probes Redis in the background and reports whether it is available.
+// Redis-backed features consult Available and use their local fallback when it is false.
+type Monitor struct {
+	endpoint string
+	password string
+	interval time.Duration
+
+	mu        sync.RWMutex
+	probed    bool
+	available bool
+	lastErr   error
+	since     time.Time
+	onChange  []func(available bool, err error)
+}
+
+// NewMonitor creates a monitor for a Redis endpoint. Redis is assumed unavailable
+// until the first successful probe.
+func NewMonitor(endpoint, password string, interval time.Duration) *Monitor {
+	if interval <= 0 {
+		interval = DefaultProbeInterval
+	}
+	return &Monitor{
+		endpoint: endpoint,
+		password: password,
+		interval: interval,
+		lastErr:  fmt.Errorf("not probed yet"),
+		since:    time.Now().UTC(),
+	}
+}
+
+// OnChange registers a callback invoked after the first probe and whenever availability flips
+func (m *Monitor) OnChange(fn func(available bool, err error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = append(m.onChange, fn)
+}
+
+// Available reports whether the last probe succeeded
+func (m *Monitor) Available() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.available
+}
+
+// Probe pings Redis once and updates availability
+func (m *Monitor) Probe(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	err := Ping(ctx, m.endpoint, m.password)
+
+	m.mu.Lock()
+	changed := !m.probed || m.available != (err == nil)
+	m.probed = true
+	m.available = err == nil
+	m.lastErr = err
+	if changed {
+		m.since = time.Now().UTC()
+	}
+	callbacks := m.onChange
+	m.mu.Unlock()
+
+	if changed {
+		for _, fn := range callbacks {
+			fn(err == nil, err)
+		}
+	}
+	return err
+}
+
+// Run probes Redis every interval until ctx is cancelled
+func (m *Monitor) Run(ctx context.Context) {
+	m.Probe(ctx)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Probe(ctx)
+		}
+	}
+}
+
+// HealthCheck reports the monitor's last result without dialing Redis, so
+// readiness probes stay fast while Redis is unreachable. Register it as a
+// non-critical check so an outage shows as degraded rather than unhealthy.
+func (m *Monitor) HealthCheck() func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+
+		if m.available {
+			return nil
+		}
+		return fmt.Errorf("degraded: redis unavailable since %s (%v); using local fallbacks",
+			m.since.Format(time.RFC3339), m.lastErr)
+	}
+}
@@ -0,0 +1,145 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRedis answers AUTH, PING, XADD and XREVRANGE with canned replies
+func fakeRedis(t *testing.T, password string) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					var args []string
+					header, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					var n int
+					if _, err := fmt.Sscanf(header, "*%d\r\n", &n); err != nil {
+						return
+					}
+					for i := 0; i < n; i++ {
+						r.ReadString('\n')
+						arg, _ := r.ReadString('\n')
+						args = append(args, strings.TrimRight(arg, "\r\n"))
+					}
+					switch {
+					case args[0] == "AUTH" && args[1] == password:
+						conn.Write([]byte("+OK\r\n"))
+					case args[0] == "AUTH":
+						conn.Write([]byte("-WRONGPASS invalid password\r\n"))
+					case args[0] == "PING":
+						conn.Write([]byte("+PONG\r\n"))
+					case args[0] == "XADD":
+						conn.Write([]byte("$15\r\n1700000000000-0\r\n"))
+					case args[0] == "XREVRANGE":
+						conn.Write([]byte("*1\r\n*2\r\n$15\r\n1700000000000-0\r\n*2\r\n$5\r\nfield\r\n$-1\r\n"))
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestPing(t *testing.T) {
+	addr := fakeRedis(t, "secret")
+
+	if err := Ping(context.Background(), addr, "secret"); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if err := Ping(context.Background(), addr, "wrong"); err == nil {
+		t.Fatal("expected AUTH failure")
+	}
+}
+
+func TestDoBulkReply(t *testing.T) {
+	addr := fakeRedis(t, "")
+
+	id, err := Do(context.Background(), addr, "", "XADD", "stream", "*", "field", "value")
+	if err != nil || id != "1700000000000-0" {
+		t.Fatalf("Do = %q, %v", id, err)
+	}
+}
+
+func TestDoReplyArray(t *testing.T) {
+	addr := fakeRedis(t, "")
+
+	reply, err := DoReply(context.Background(), addr, "", "XREVRANGE", "stream", "+", "-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, ok := reply.([]interface{})
+	if !ok || len(entries) != 1 {
+		t.Fatalf("expected one entry, got %#v", reply)
+	}
+	entry := entries[0].([]interface{})
+	fields := entry[1].([]interface{})
+	if entry[0] != "1700000000000-0" || fields[0] != "field" || fields[1] != nil {
+		t.Errorf("unexpected entry %#v", entry)
+	}
+
+	if _, err := Do(context.Background(), addr, "", "XREVRANGE", "stream", "+", "-"); err == nil {
+		t.Error("expected Do to reject an array reply")
+	}
+}
+
+func TestMonitorTransitions(t *testing.T) {
+	addr := fakeRedis(t, "")
+	m := NewMonitor(addr, "", time.Minute)
+
+	var changes []bool
+	m.OnChange(func(available bool, err error) {
+		changes = append(changes, available)
+	})
+
+	if err := m.HealthCheck()(context.Background()); err == nil {
+		t.Error("expected degraded health before the first probe")
+	}
+
+	m.Probe(context.Background())
+	m.Probe(context.Background())
+	if !m.Available() {
+		t.Fatal("expected Redis to be available")
+	}
+	if err := m.HealthCheck()(context.Background()); err != nil {
+		t.Errorf("unexpected health error: %v", err)
+	}
+
+	m.endpoint = "127.0.0.1:1"
+	m.Probe(context.Background())
+	m.Probe(context.Background())
+	if m.Available() {
+		t.Fatal("expected Redis to be unavailable")
+	}
+	err := m.HealthCheck()(context.Background())
+	if err == nil || !strings.HasPrefix(err.Error(), "degraded: redis") {
+		t.Errorf("unexpected health error: %v", err)
+	}
+
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Errorf("expected transitions [true false], got %v", changes)
+	}
+}
@@ -3,16 +3,21 @@ package server
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/NSACodeGov/CodeGov/api/middleware"
 	"github.com/NSACodeGov/CodeGov/config"
 	"github.com/NSACodeGov/CodeGov/internal/health"
 	"github.com/NSACodeGov/CodeGov/internal/logging"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
 )
 
 // Server represents the HTTP server
@@ -22,6 +27,9 @@ type Server struct {
 	health  *health.Checker
 	handler http.Handler
 	server  *http.Server
+
+	started  time.Time
+	requests atomic.Uint64 // Requests that have completed
 }
 
 // New creates a new server instance
@@ -41,9 +49,10 @@ func (s *Server) SetHandler(h http.Handler) {
 // Start starts the HTTP server with graceful shutdown
 func (s *Server) Start(ctx context.Context) error {
 	// Create HTTP server
+	s.started = time.Now()
 	s.server = &http.Server{
 		Addr:         s.config.Addr(),
-		Handler:      s.handler,
+		Handler:      s.countRequests(s.handler),
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -66,25 +75,45 @@ func (s *Server) Start(ctx context.Context) error {
 				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
 			},
 		}
+
+		// Client certificates are optional but must chain to the configured CAs when presented
+		if s.config.TLS.ClientCAFile != "" {
+			pem, err := os.ReadFile(s.config.TLS.ClientCAFile)
+			if err != nil {
+				return fmt.Errorf("failed to read client CA file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("no certificates found in client CA file %s", s.config.TLS.ClientCAFile)
+			}
+			s.server.TLSConfig.ClientCAs = pool
+			s.server.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
 	}
 
 	// Channel to listen for errors from the server
-	serverErrors := make(chan error, 1)
-
-	// Start server in a goroutine
-	go func() {
-		s.logger.Info("starting server", map[string]interface{}{
-			"addr":       s.config.Addr(),
-			"tls":        s.config.TLS.Enabled,
-			"profile":    s.config.Profile,
-		})
+	serverErrors := make(chan error, 1+len(s.config.Server.LayerListeners))
 
-		if s.config.TLS.Enabled {
-			serverErrors <- s.server.ListenAndServeTLS("", "")
-		} else {
-			serverErrors <- s.server.ListenAndServe()
+	if len(s.config.Server.LayerListeners) > 0 {
+		if err := s.serveLayerListeners(serverErrors); err != nil {
+			return err
 		}
-	}()
+	} else {
+		// Start server in a goroutine
+		go func() {
+			s.logger.Info("starting server", map[string]interface{}{
+				"addr":       s.config.Addr(),
+				"tls":        s.config.TLS.Enabled,
+				"profile":    s.config.Profile,
+			})
+
+			if s.config.TLS.Enabled {
+				serverErrors <- s.server.ListenAndServeTLS("", "")
+			} else {
+				serverErrors <- s.server.ListenAndServe()
+			}
+		}()
+	}
 
 	// Create channel to listen for interrupt signals
 	shutdown := make(chan os.Signal, 1)
@@ -122,6 +151,95 @@ func (s *Server) Start(ctx context.Context) error {
 	return nil
 }
 
+// layerConn is a connection accepted on a layer listener
+type layerConn struct {
+	net.Conn
+	layer models.Layer
+}
+
+// layerListener tags the connections it accepts with its layer
+type layerListener struct {
+	net.Listener
+	layer models.Layer
+}
+
+func (l *layerListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &layerConn{Conn: c, layer: l.layer}, nil
+}
+
+// serveLayerListeners serves the main address and one listener per configured layer,
+// marking each request with its listener's layer. All listeners are bound before any
+// is served, so a taken port fails the start.
+func (s *Server) serveLayerListeners(serverErrors chan<- error) error {
+	s.server.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+		if tc, ok := c.(*tls.Conn); ok {
+			c = tc.NetConn()
+		}
+		if lc, ok := c.(*layerConn); ok {
+			return middleware.WithListenerLayer(ctx, lc.layer)
+		}
+		return ctx
+	}
+
+	main, err := net.Listen("tcp", s.config.Addr())
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.config.Addr(), err)
+	}
+	listeners := []net.Listener{main}
+	for layer, addr := range s.config.Server.LayerListeners {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("failed to listen on %s for the %s layer: %w", addr, layer, err)
+		}
+		listeners = append(listeners, &layerListener{Listener: l, layer: models.Layer(layer)})
+	}
+
+	s.logger.Info("starting server", map[string]interface{}{
+		"addr":            s.config.Addr(),
+		"layer_listeners": s.config.Server.LayerListeners,
+		"tls":             s.config.TLS.Enabled,
+		"profile":         s.config.Profile,
+	})
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			if s.config.TLS.Enabled {
+				serverErrors <- s.server.ServeTLS(l, "", "")
+			} else {
+				serverErrors <- s.server.Serve(l)
+			}
+		}(l)
+	}
+	return nil
+}
+
+// countRequests counts each request once it has been served
+func (s *Server) countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer s.requests.Add(1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Uptime returns how long the server has been started, or zero before Start
+func (s *Server) Uptime() time.Duration {
+	if s.started.IsZero() {
+		return 0
+	}
+	return time.Since(s.started)
+}
+
+// Requests returns the number of requests served
+func (s *Server) Requests() uint64 {
+	return s.requests.Load()
+}
+
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
 	if s.server == nil {
@@ -0,0 +1,198 @@
+// Package watchdog samples the process's heap, goroutine count and audit backlog
+// in the background, so slow leaks in long-running deployments show up in logs,
+// metrics and readiness before they take the service down.
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for the sampling interval and thresholds
+const (
+	DefaultInterval      = 30 * time.Second
+	DefaultMaxHeapBytes  = 1 << 30 // 1 GiB
+	DefaultMaxGoroutines = 10000
+	DefaultMaxAuditQueue = 1000
+)
+
+// Thresholds are the limits past which a sample is reported; zero values use the defaults
+type Thresholds struct {
+	HeapBytes  uint64
+	Goroutines int
+	AuditQueue int64
+}
+
+// Sample is one reading of the process
+type Sample struct {
+	Time       time.Time
+	HeapBytes  uint64 // Bytes of allocated heap objects
+	HeapSys    uint64 // Bytes of heap memory obtained from the OS
+	Goroutines int
+	AuditQueue int64  // Audit events waiting to be written
+	GCRuns     uint32 // Completed GC cycles since the process started
+}
+
+// Watchdog samples the process every interval and reports thresholds being crossed
+type Watchdog struct {
+	thresholds Thresholds
+	interval   time.Duration
+	auditQueue func() int64
+
+	mu       sync.RWMutex
+	last     Sample
+	exceeded []string // Descriptions of the limits the last sample exceeded
+	limits   string   // Names of those limits, to report a growing reading only once
+	onChange []func(sample Sample, exceeded []string)
+}
+
+// New creates a watchdog. auditQueue reports the audit backlog and may be nil.
+func New(thresholds Thresholds, interval time.Duration, auditQueue func() int64) *Watchdog {
+	if thresholds.HeapBytes == 0 {
+		thresholds.HeapBytes = DefaultMaxHeapBytes
+	}
+	if thresholds.Goroutines <= 0 {
+		thresholds.Goroutines = DefaultMaxGoroutines
+	}
+	if thresholds.AuditQueue <= 0 {
+		thresholds.AuditQueue = DefaultMaxAuditQueue
+	}
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Watchdog{thresholds: thresholds, interval: interval, auditQueue: auditQueue}
+}
+
+// OnChange registers a callback invoked whenever the set of exceeded thresholds
+// changes; exceeded is empty once every reading is back under its limit
+func (w *Watchdog) OnChange(fn func(sample Sample, exceeded []string)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onChange = append(w.onChange, fn)
+}
+
+// Sample reads the process once and records the result
+func (w *Watchdog) Sample() Sample {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	s := Sample{
+		Time:       time.Now().UTC(),
+		HeapBytes:  mem.HeapAlloc,
+		HeapSys:    mem.HeapSys,
+		Goroutines: runtime.NumGoroutine(),
+		GCRuns:     mem.NumGC,
+	}
+	if w.auditQueue != nil {
+		s.AuditQueue = w.auditQueue()
+	}
+
+	var exceeded, limits []string
+	if s.HeapBytes > w.thresholds.HeapBytes {
+		exceeded = append(exceeded, fmt.Sprintf("heap %d MiB above %d MiB", s.HeapBytes>>20, w.thresholds.HeapBytes>>20))
+		limits = append(limits, "heap")
+	}
+	if s.Goroutines > w.thresholds.Goroutines {
+		exceeded = append(exceeded, fmt.Sprintf("%d goroutines above %d", s.Goroutines, w.thresholds.Goroutines))
+		limits = append(limits, "goroutines")
+	}
+	if s.AuditQueue > w.thresholds.AuditQueue {
+		exceeded = append(exceeded, fmt.Sprintf("audit queue %d above %d", s.AuditQueue, w.thresholds.AuditQueue))
+		limits = append(limits, "audit_queue")
+	}
+
+	w.mu.Lock()
+	changed := strings.Join(limits, ",") != w.limits
+	w.last = s
+	w.exceeded = exceeded
+	w.limits = strings.Join(limits, ",")
+	callbacks := w.onChange
+	w.mu.Unlock()
+
+	if changed {
+		for _, fn := range callbacks {
+			fn(s, exceeded)
+		}
+	}
+	return s
+}
+
+// Run samples every interval until ctx is cancelled
+func (w *Watchdog) Run(ctx context.Context) {
+	w.Sample()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Sample()
+		}
+	}
+}
+
+// Last returns the most recent sample
+func (w *Watchdog) Last() Sample {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.last
+}
+
+// HealthCheck reports the last sample's exceeded thresholds without sampling again.
+// Register it as a non-critical check so a leak shows as degraded rather than unhealthy.
+func (w *Watchdog) HealthCheck() func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		w.mu.RLock()
+		defer w.mu.RUnlock()
+
+		if len(w.exceeded) == 0 {
+			return nil
+		}
+		return fmt.Errorf("degraded: %s", strings.Join(w.exceeded, "; "))
+	}
+}
+
+// WritePrometheus writes the last sample and the thresholds in the Prometheus text exposition format
+func (w *Watchdog) WritePrometheus(out io.Writer) error {
+	w.mu.RLock()
+	s, t := w.last, w.thresholds
+	w.mu.RUnlock()
+
+	writeValue := func(name, help, kind string, value interface{}) {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+	}
+
+	writeValue("gogovcode_heap_alloc_bytes", "Bytes of allocated heap objects.", "gauge", s.HeapBytes)
+	writeValue("gogovcode_heap_sys_bytes", "Bytes of heap memory obtained from the OS.", "gauge", s.HeapSys)
+	writeValue("gogovcode_goroutines", "Number of goroutines.", "gauge", s.Goroutines)
+	writeValue("gogovcode_audit_queue_depth", "Audit events waiting to be written.", "gauge", s.AuditQueue)
+	writeValue("gogovcode_gc_runs_total", "Completed garbage collection cycles.", "counter", s.GCRuns)
+	writeValue("gogovcode_heap_alloc_threshold_bytes", "Heap size past which the watchdog reports degraded.", "gauge", t.HeapBytes)
+	writeValue("gogovcode_goroutines_threshold", "Goroutine count past which the watchdog reports degraded.", "gauge", t.Goroutines)
+	writeValue("gogovcode_audit_queue_threshold", "Audit backlog past which the watchdog reports degraded.", "gauge", t.AuditQueue)
+
+	var sampled int64
+	if !s.Time.IsZero() {
+		sampled = s.Time.Unix()
+	}
+	writeValue("gogovcode_watchdog_last_sample_timestamp_seconds", "Unix time of the most recent sample.", "gauge", sampled)
+
+	return nil
+}
+
+// Handler serves the last sample in Prometheus text format
+func (w *Watchdog) Handler() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WritePrometheus(rw)
+	}
+}
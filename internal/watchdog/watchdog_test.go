@@ -0,0 +1,62 @@
+package watchdog
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchdogThresholds(t *testing.T) {
+	queue := int64(5)
+	w := New(Thresholds{HeapBytes: 1 << 40, Goroutines: 1 << 20, AuditQueue: 2}, time.Minute, func() int64 {
+		return queue
+	})
+
+	var reports [][]string
+	w.OnChange(func(sample Sample, exceeded []string) {
+		reports = append(reports, exceeded)
+	})
+
+	// A backlog that keeps growing past the limit is reported once
+	w.Sample()
+	queue = 8
+	if s := w.Sample(); s.AuditQueue != 8 || s.Goroutines == 0 || s.HeapBytes == 0 {
+		t.Fatalf("unexpected sample %+v", s)
+	}
+	if len(reports) != 1 || len(reports[0]) != 1 || !strings.Contains(reports[0][0], "audit queue 5 above 2") {
+		t.Fatalf("unexpected reports %q", reports)
+	}
+	err := w.HealthCheck()(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "audit queue 8 above 2") {
+		t.Fatalf("expected a degraded health check, got %v", err)
+	}
+
+	queue = 0
+	w.Sample()
+	if len(reports) != 2 || len(reports[1]) != 0 {
+		t.Fatalf("expected a recovery report, got %q", reports)
+	}
+	if err := w.HealthCheck()(context.Background()); err != nil {
+		t.Errorf("unexpected health check error: %v", err)
+	}
+
+	var out bytes.Buffer
+	w.WritePrometheus(&out)
+	for _, want := range []string{"gogovcode_audit_queue_depth 0", "gogovcode_audit_queue_threshold 2", "# TYPE gogovcode_goroutines gauge"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestWatchdogHeapAndGoroutines(t *testing.T) {
+	w := New(Thresholds{HeapBytes: 1, Goroutines: 1}, 0, nil)
+	w.Sample()
+
+	err := w.HealthCheck()(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "heap") || !strings.Contains(err.Error(), "goroutines above 1") {
+		t.Fatalf("expected heap and goroutine thresholds exceeded, got %v", err)
+	}
+}
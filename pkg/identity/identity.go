@@ -0,0 +1,277 @@
+// Package identity forwards a request's verified device identity from gogovcode to
+// the upstream services it proxies, as headers signed with ed25519.
+//
+// gogovcode signs with the private key; upstream services verify with the public
+// key, so an upstream can trust the headers without sharing a secret that would
+// let it mint identities itself:
+//
+//	verifier := &identity.Verifier{Keys: []ed25519.PublicKey{key}}
+//	http.Handle("/", verifier.Middleware(app))
+//
+//	func app(w http.ResponseWriter, r *http.Request) {
+//		id, _ := identity.FromContext(r.Context())
+//		...
+//	}
+package identity
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/internal/keys"
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+// Headers carrying the forwarded identity
+const (
+	HeaderDeviceID  = "X-GoGovCode-Device-ID"
+	HeaderClearance = "X-GoGovCode-Clearance" // Hex, as in X-Clearance
+	HeaderLayer     = "X-GoGovCode-Layer"
+	HeaderTimestamp = "X-GoGovCode-Timestamp" // Unix seconds when the headers were signed
+	HeaderSignature = "X-GoGovCode-Signature" // Base64 ed25519 signature
+
+	// HeaderContentDigest carries the SHA-256 of the request body (RFC 9530), which the signature covers
+	HeaderContentDigest = "Content-Digest"
+)
+
+// headers lists every identity header, for stripping client-supplied copies
+var headers = []string{HeaderDeviceID, HeaderClearance, HeaderLayer, HeaderTimestamp, HeaderSignature}
+
+// signatureVersion prefixes the signed payload so the format can change later
+const signatureVersion = "gogovcode-identity-v2"
+
+// DefaultMaxAge is how old signed headers may be before Verify rejects them
+const DefaultMaxAge = time.Minute
+
+// Verification errors
+var (
+	ErrMissing   = errors.New("identity headers missing")
+	ErrMalformed = errors.New("identity headers malformed")
+	ErrSignature = errors.New("identity signature invalid")
+	ErrExpired   = errors.New("identity headers expired")
+	ErrDigest    = errors.New("identity content digest does not match the body")
+)
+
+// Identity is the device identity gogovcode verified for a request
+type Identity struct {
+	DeviceID  uint16           `json:"device_id"`
+	Clearance models.Clearance `json:"clearance"`
+	Layer     models.Layer     `json:"layer"`
+	IssuedAt  time.Time        `json:"issued_at"`
+}
+
+// Strip removes identity headers from a request, so clients cannot pass their own
+// to an upstream
+func Strip(header http.Header) {
+	for _, name := range headers {
+		header.Del(name)
+	}
+}
+
+// payload is the signed form of an identity bound to a request's method, host,
+// URI and body digest
+func payload(method, host, requestURI, digest string, id Identity, timestamp string) []byte {
+	return []byte(strings.Join([]string{
+		signatureVersion,
+		method,
+		strings.ToLower(host),
+		requestURI,
+		digest,
+		strconv.FormatUint(uint64(id.DeviceID), 10),
+		fmt.Sprintf("%08X", uint32(id.Clearance)),
+		string(id.Layer),
+		timestamp,
+	}, "\n"))
+}
+
+// contentDigest returns the Content-Digest value for a request's body. The body is
+// read in full and replaced, so the request can still be sent or handled.
+func contentDigest(req *http.Request) (string, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to read body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		req.ContentLength = int64(len(body))
+	}
+	sum := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":", nil
+}
+
+// requestHost returns the host a request is addressed to
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+// Signer adds signed identity headers to outgoing requests
+type Signer struct {
+	key ed25519.PrivateKey
+	now func() time.Time
+}
+
+// NewSigner creates a signer for the given private key
+func NewSigner(key ed25519.PrivateKey) *Signer {
+	return &Signer{key: key, now: time.Now}
+}
+
+// Sign replaces any identity headers on req with id, signed over the request's
+// method, host, URI and a Content-Digest of its body. Call it after the request's
+// final URL and host are set; the body is buffered to compute the digest.
+func (s *Signer) Sign(req *http.Request, id Identity) error {
+	Strip(req.Header)
+
+	digest, err := contentDigest(req)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	sig := ed25519.Sign(s.key, payload(req.Method, requestHost(req), req.URL.RequestURI(), digest, id, timestamp))
+
+	req.Header.Set(HeaderDeviceID, strconv.FormatUint(uint64(id.DeviceID), 10))
+	req.Header.Set(HeaderClearance, fmt.Sprintf("%08X", uint32(id.Clearance)))
+	req.Header.Set(HeaderLayer, string(id.Layer))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, base64.StdEncoding.EncodeToString(sig))
+	req.Header.Set(HeaderContentDigest, digest)
+	return nil
+}
+
+// Verifier checks identity headers on incoming requests
+type Verifier struct {
+	// Keys are the accepted public keys; list the old and new key while rotating
+	Keys []ed25519.PublicKey
+
+	// MaxAge bounds how old signed headers may be, limiting replay; zero uses DefaultMaxAge
+	MaxAge time.Duration
+
+	// Audience is the host the headers must have been signed for, i.e. the host
+	// gogovcode forwards to; empty uses the request's Host
+	Audience string
+
+	now func() time.Time
+}
+
+// Verify returns the identity carried by req's headers, checking the signature,
+// that it was made for this method, host, URI and body, and its age. The body is
+// buffered to check its digest, so bound it (e.g. with http.MaxBytesReader) first.
+func (v *Verifier) Verify(req *http.Request) (*Identity, error) {
+	sigHeader := req.Header.Get(HeaderSignature)
+	timestamp := req.Header.Get(HeaderTimestamp)
+	if sigHeader == "" || timestamp == "" {
+		return nil, ErrMissing
+	}
+
+	deviceID, err := strconv.ParseUint(req.Header.Get(HeaderDeviceID), 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: device ID", ErrMalformed)
+	}
+	clearance, err := strconv.ParseUint(req.Header.Get(HeaderClearance), 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: clearance", ErrMalformed)
+	}
+	issued, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: timestamp", ErrMalformed)
+	}
+	sig, err := base64.StdEncoding.DecodeString(sigHeader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature", ErrMalformed)
+	}
+	digest, err := contentDigest(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Header.Get(HeaderContentDigest) != digest {
+		return nil, ErrDigest
+	}
+	host := v.Audience
+	if host == "" {
+		host = requestHost(req)
+	}
+
+	id := Identity{
+		DeviceID:  uint16(deviceID),
+		Clearance: models.Clearance(clearance),
+		Layer:     models.Layer(req.Header.Get(HeaderLayer)),
+		IssuedAt:  time.Unix(issued, 0).UTC(),
+	}
+
+	signed := payload(req.Method, host, req.URL.RequestURI(), digest, id, timestamp)
+	valid := false
+	for _, key := range v.Keys {
+		if len(key) == ed25519.PublicKeySize && ed25519.Verify(key, signed, sig) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, ErrSignature
+	}
+
+	maxAge := v.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	now := time.Now
+	if v.now != nil {
+		now = v.now
+	}
+	// Allow the same skew into the future for clocks that run ahead of gogovcode's
+	if age := now().Sub(id.IssuedAt); age > maxAge || age < -maxAge {
+		return nil, ErrExpired
+	}
+
+	return &id, nil
+}
+
+type contextKey struct{}
+
+// FromContext returns the identity Middleware verified for a request
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(contextKey{}).(*Identity)
+	return id, ok
+}
+
+// Middleware rejects requests without valid identity headers with 401 and makes
+// the identity available to next through FromContext
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := v.Verify(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, id)))
+	})
+}
+
+// LoadPrivateKey reads an ed25519 private key from a PEM (PKCS #8) file, as written
+// by "openssl genpkey -algorithm ed25519"
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	return keys.LoadPrivateKey(path)
+}
+
+// LoadPublicKey reads an ed25519 public key from a PEM (PKIX) file or a base64 encoded raw key
+func LoadPublicKey(pathOrKey string) (ed25519.PublicKey, error) {
+	return keys.LoadPublicKey(pathOrKey)
+}
@@ -0,0 +1,221 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NSACodeGov/CodeGov/pkg/models"
+)
+
+func newKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, priv
+}
+
+func signedRequest(signer *Signer, method, target string) *http.Request {
+	return signedBody(signer, method, target, "")
+}
+
+func signedBody(signer *Signer, method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if err := signer.Sign(req, Identity{DeviceID: 7, Clearance: models.ClearanceLevel5, Layer: models.LayerControl}); err != nil {
+		panic(err)
+	}
+	return req
+}
+
+func TestSignVerify(t *testing.T) {
+	pub, priv := newKey(t)
+	now := time.Unix(1_700_000_000, 0)
+	signer := &Signer{key: priv, now: func() time.Time { return now }}
+	verifier := &Verifier{Keys: []ed25519.PublicKey{pub}, now: func() time.Time { return now.Add(30 * time.Second) }}
+
+	req := signedBody(signer, "POST", "/orders?page=2", `{"item": 1}`)
+	if req.Header.Get(HeaderClearance) != "05050505" {
+		t.Errorf("clearance header = %q, want 05050505", req.Header.Get(HeaderClearance))
+	}
+	// SHA-256 of {"item": 1}
+	if got := req.Header.Get(HeaderContentDigest); got != "sha-256=:fI8Z9nyApVSp1YhzXTruo5ULxjbXXwp8Uh6QLSDsBn0=:" {
+		t.Errorf("content digest = %q", got)
+	}
+
+	id, err := verifier.Verify(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Identity{DeviceID: 7, Clearance: models.ClearanceLevel5, Layer: models.LayerControl, IssuedAt: now.UTC()}
+	if *id != want {
+		t.Errorf("Verify() = %+v, want %+v", *id, want)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != `{"item": 1}` {
+		t.Errorf("body after Verify = %q", body)
+	}
+
+	// Behind a load balancer that rewrites Host, the audience names the signed host
+	req = signedRequest(signer, "GET", "http://inventory.internal:8080/orders")
+	req.Host = "lb.example"
+	if _, err := verifier.Verify(req); !errors.Is(err, ErrSignature) {
+		t.Errorf("expected a rewritten host to be rejected, got %v", err)
+	}
+	verifier.Audience = "inventory.internal:8080"
+	if _, err := verifier.Verify(req); err != nil {
+		t.Errorf("expected the audience to verify: %v", err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	pub, priv := newKey(t)
+	otherPub, otherPriv := newKey(t)
+	now := time.Now()
+	signer := &Signer{key: priv, now: func() time.Time { return now }}
+
+	tests := []struct {
+		name   string
+		req    func() *http.Request
+		keys   []ed25519.PublicKey
+		offset time.Duration
+		want   error
+	}{
+		{"unsigned", func() *http.Request { return httptest.NewRequest("GET", "/orders", nil) }, []ed25519.PublicKey{pub}, 0, ErrMissing},
+		{"raised clearance", func() *http.Request {
+			req := signedRequest(signer, "GET", "/orders")
+			req.Header.Set(HeaderClearance, "09090909")
+			return req
+		}, []ed25519.PublicKey{pub}, 0, ErrSignature},
+		{"other path", func() *http.Request {
+			req := signedRequest(signer, "GET", "/orders")
+			req.URL.Path = "/admin"
+			return req
+		}, []ed25519.PublicKey{pub}, 0, ErrSignature},
+		{"other method", func() *http.Request {
+			req := signedRequest(signer, "GET", "/orders")
+			req.Method = "DELETE"
+			return req
+		}, []ed25519.PublicKey{pub}, 0, ErrSignature},
+		{"other host", func() *http.Request {
+			req := signedRequest(signer, "GET", "/orders")
+			req.Host = "billing.internal"
+			return req
+		}, []ed25519.PublicKey{pub}, 0, ErrSignature},
+		{"other body", func() *http.Request {
+			req := signedBody(signer, "POST", "/orders", `{"item": 1}`)
+			req.Body = io.NopCloser(strings.NewReader(`{"item": 2}`))
+			return req
+		}, []ed25519.PublicKey{pub}, 0, ErrDigest},
+		{"other body with its digest", func() *http.Request {
+			req := signedBody(signer, "POST", "/orders", `{"item": 1}`)
+			forged := signedBody(signer, "POST", "/orders", `{"item": 2}`)
+			req.Body = forged.Body
+			req.Header.Set(HeaderContentDigest, forged.Header.Get(HeaderContentDigest))
+			return req
+		}, []ed25519.PublicKey{pub}, 0, ErrSignature},
+		{"untrusted key", func() *http.Request { return signedRequest(signer, "GET", "/orders") }, []ed25519.PublicKey{otherPub}, 0, ErrSignature},
+		{"expired", func() *http.Request { return signedRequest(signer, "GET", "/orders") }, []ed25519.PublicKey{pub}, 2 * time.Minute, ErrExpired},
+		{"malformed device ID", func() *http.Request {
+			req := signedRequest(signer, "GET", "/orders")
+			req.Header.Set(HeaderDeviceID, "seven")
+			return req
+		}, []ed25519.PublicKey{pub}, 0, ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := &Verifier{Keys: tt.keys, now: func() time.Time { return now.Add(tt.offset) }}
+			if _, err := verifier.Verify(tt.req()); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// Rotation: a verifier trusting both keys accepts either signer
+	rotating := &Verifier{Keys: []ed25519.PublicKey{otherPub, pub}}
+	if _, err := rotating.Verify(signedRequest(NewSigner(otherPriv), "GET", "/orders")); err != nil {
+		t.Errorf("expected the new key to verify: %v", err)
+	}
+	if _, err := rotating.Verify(signedRequest(NewSigner(priv), "GET", "/orders")); err != nil {
+		t.Errorf("expected the old key to verify: %v", err)
+	}
+}
+
+func TestSignStripsClientHeaders(t *testing.T) {
+	_, priv := newKey(t)
+	req := httptest.NewRequest("GET", "/orders", nil)
+	req.Header.Set(HeaderDeviceID, "1")
+	req.Header.Add(HeaderDeviceID, "2")
+	NewSigner(priv).Sign(req, Identity{DeviceID: 7})
+	if got := req.Header.Values(HeaderDeviceID); len(got) != 1 || got[0] != "7" {
+		t.Errorf("device ID headers = %v, want only the signed one", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	pub, priv := newKey(t)
+	verifier := &Verifier{Keys: []ed25519.PublicKey{pub}}
+	handler := verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := FromContext(r.Context())
+		if !ok || id.DeviceID != 7 {
+			t.Errorf("FromContext() = %+v, %v", id, ok)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, signedRequest(NewSigner(priv), "GET", "/orders"))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("signed request: status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/orders", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned request: status %d, want 401", w.Code)
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	pub, priv := newKey(t)
+	dir := t.TempDir()
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privPath := filepath.Join(dir, "identity.pem")
+	os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+
+	der, err = x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPath := filepath.Join(dir, "identity.pub")
+	os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600)
+
+	loadedPriv, err := LoadPrivateKey(privPath)
+	if err != nil || !loadedPriv.Equal(priv) {
+		t.Fatalf("LoadPrivateKey() = %v", err)
+	}
+	for _, source := range []string{pubPath, base64.StdEncoding.EncodeToString(pub)} {
+		loaded, err := LoadPublicKey(source)
+		if err != nil || !loaded.Equal(pub) {
+			t.Errorf("LoadPublicKey(%q) = %v", source, err)
+		}
+	}
+	if _, err := LoadPrivateKey(pubPath); err == nil {
+		t.Error("expected a public key to be rejected as a private key")
+	}
+}
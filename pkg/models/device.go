@@ -2,6 +2,9 @@ package models
 
 import (
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 )
 
 // Clearance represents a DSMIL clearance level
@@ -33,9 +36,9 @@ const (
 type DeviceClass string
 
 const (
-	DeviceClassSensor    DeviceClass = "sensor"
-	DeviceClassActuator  DeviceClass = "actuator"
-	DeviceClassGateway   DeviceClass = "gateway"
+	DeviceClassSensor     DeviceClass = "sensor"
+	DeviceClassActuator   DeviceClass = "actuator"
+	DeviceClassGateway    DeviceClass = "gateway"
 	DeviceClassController DeviceClass = "controller"
 )
 
@@ -50,12 +53,13 @@ const (
 
 // Device represents a DSMIL device
 type Device struct {
-	ID        uint16      `json:"device_id"`
-	Layer     Layer       `json:"layer"`
-	Class     DeviceClass `json:"class"`
-	Clearance Clearance   `json:"clearance"`
-	Name      string      `json:"name"`
-	TokenBase uint16      `json:"token_base"`
+	ID        uint16            `json:"device_id"`
+	Layer     Layer             `json:"layer"`
+	Class     DeviceClass       `json:"class"`
+	Clearance Clearance         `json:"clearance"`
+	Name      string            `json:"name"`
+	TokenBase uint16            `json:"token_base"`
+	Labels    map[string]string `json:"labels,omitempty"`
 }
 
 // ComputeToken calculates the token ID for a device
@@ -79,25 +83,103 @@ func (d *Device) GetDataToken() uint16 {
 	return d.ComputeToken(TokenOffsetData)
 }
 
+// SelectorLabels returns the labels used for policy selectors.
+// The device class and layer are exposed as "class" and "layer" unless
+// an explicit label of the same name overrides them.
+func (d *Device) SelectorLabels() map[string]string {
+	labels := make(map[string]string, len(d.Labels)+2)
+	if d.Class != "" {
+		labels["class"] = string(d.Class)
+	}
+	if d.Layer != "" {
+		labels["layer"] = string(d.Layer)
+	}
+	for k, v := range d.Labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// Registry change actions
+const (
+	RegistryActionRegister  = "register"
+	RegistryActionLabels    = "labels"
+	RegistryActionClearance = "clearance"
+	RegistryActionDelete    = "delete"
+	RegistryActionRestore   = "restore"
+	RegistryActionPurge     = "purge"
+)
+
+// RegistryChange describes a mutation of the device registry
+type RegistryChange struct {
+	Action   string
+	DeviceID uint16
+	Before   *Device // Snapshot before the change (nil on registration and restore)
+	After    *Device // Snapshot after the change (nil on deletion and purge)
+	Actor    string  // Who requested the change; empty for the server itself
+}
+
 // DeviceRegistry manages device information
 type DeviceRegistry struct {
-	devices map[uint16]*Device
-	tokens  map[uint16]*Device // Maps token ID to device
+	mu         sync.RWMutex
+	devices    map[uint16]*Device
+	tokens     map[uint16]*Device // Maps token ID to device
+	generation uint64             // Incremented on every change
+	modified   time.Time          // Time of the last change
+	observer   func(RegistryChange)
+	idRanges   []IDRange             // Device ID reservations enforced at registration
+	deleted    map[uint16]*Tombstone // Soft-deleted devices awaiting restore or purge
+	retention  time.Duration         // How long deleted devices can be restored
+	now        func() time.Time
 }
 
 // NewDeviceRegistry creates a new device registry
 func NewDeviceRegistry() *DeviceRegistry {
 	return &DeviceRegistry{
-		devices: make(map[uint16]*Device),
-		tokens:  make(map[uint16]*Device),
+		devices:   make(map[uint16]*Device),
+		tokens:    make(map[uint16]*Device),
+		deleted:   make(map[uint16]*Tombstone),
+		retention: DefaultDeviceRetention,
+		now:       time.Now,
 	}
 }
 
+// SetObserver registers a function called after every registry mutation
+func (r *DeviceRegistry) SetObserver(fn func(RegistryChange)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observer = fn
+}
+
 // Register adds a device to the registry
 func (r *DeviceRegistry) Register(device *Device) error {
+	return r.RegisterBy(device, "")
+}
+
+// RegisterBy adds a device to the registry on behalf of actor
+func (r *DeviceRegistry) RegisterBy(device *Device, actor string) error {
+	r.mu.Lock()
+	return r.register(device, r.purgeExpired(), actor)
+}
+
+// register adds a device; it must be called with r.mu held and releases it.
+// Pending changes are passed to the observer ahead of the registration.
+func (r *DeviceRegistry) register(device *Device, changes []RegistryChange, actor string) error {
 	if _, exists := r.devices[device.ID]; exists {
+		r.mu.Unlock()
+		r.notify(changes)
 		return fmt.Errorf("device %d already registered", device.ID)
 	}
+	if _, deleted := r.deleted[device.ID]; deleted {
+		r.mu.Unlock()
+		r.notify(changes)
+		return fmt.Errorf("device %d is deleted; restore it or wait for it to be purged", device.ID)
+	}
+	if err := checkDeviceID(r.idRanges, device); err != nil {
+		r.mu.Unlock()
+		r.notify(changes)
+		return err
+	}
 
 	device.TokenBase = 0x8000 + (device.ID * 3)
 	r.devices[device.ID] = device
@@ -106,12 +188,108 @@ func (r *DeviceRegistry) Register(device *Device) error {
 	r.tokens[device.GetStatusToken()] = device
 	r.tokens[device.GetConfigToken()] = device
 	r.tokens[device.GetDataToken()] = device
+	r.generation++
+	r.modified = time.Now().UTC()
+
+	changes = append(changes, RegistryChange{Action: RegistryActionRegister, DeviceID: device.ID, After: device.snapshot(), Actor: actor})
+	r.mu.Unlock()
 
+	r.notify(changes)
 	return nil
 }
 
-// GetDevice retrieves a device by ID
+// SetLabels replaces the labels of a registered device
+func (r *DeviceRegistry) SetLabels(deviceID uint16, labels map[string]string) error {
+	copied := make(map[string]string, len(labels))
+	for k, v := range labels {
+		copied[k] = v
+	}
+
+	return r.update(deviceID, RegistryActionLabels, func(device *Device) error {
+		device.Labels = copied
+		return nil
+	})
+}
+
+// SetClearance changes the clearance of a registered device
+func (r *DeviceRegistry) SetClearance(deviceID uint16, clearance Clearance) error {
+	if !ValidateClearance(clearance) {
+		return fmt.Errorf("invalid clearance %s", clearance)
+	}
+
+	return r.update(deviceID, RegistryActionClearance, func(device *Device) error {
+		device.Clearance = clearance
+		return nil
+	})
+}
+
+// update applies a mutation to a copy of a registered device, swaps the copy in and
+// notifies the observer. Devices handed out by GetDevice are never changed in place,
+// so callers may read them without holding the registry lock.
+func (r *DeviceRegistry) update(deviceID uint16, action string, mutate func(*Device) error) error {
+	r.mu.Lock()
+
+	current, ok := r.devices[deviceID]
+	if !ok {
+		r.mu.Unlock()
+		return fmt.Errorf("device %d not found", deviceID)
+	}
+
+	device := current.snapshot()
+	if err := mutate(device); err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	r.devices[deviceID] = device
+	r.tokens[device.GetStatusToken()] = device
+	r.tokens[device.GetConfigToken()] = device
+	r.tokens[device.GetDataToken()] = device
+	r.generation++
+	r.modified = time.Now().UTC()
+
+	change := RegistryChange{Action: action, DeviceID: deviceID, Before: current.snapshot(), After: device.snapshot()}
+	observer := r.observer
+	r.mu.Unlock()
+
+	if observer != nil {
+		observer(change)
+	}
+	return nil
+}
+
+// snapshot returns a deep copy of the device
+func (d *Device) snapshot() *Device {
+	c := *d
+	if d.Labels != nil {
+		c.Labels = make(map[string]string, len(d.Labels))
+		for k, v := range d.Labels {
+			c.Labels[k] = v
+		}
+	}
+	return &c
+}
+
+// Generation returns a counter that changes whenever the registry is modified.
+// Callers can use it to invalidate caches derived from registry contents.
+func (r *DeviceRegistry) Generation() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.generation
+}
+
+// LastModified returns the time of the last registry change
+func (r *DeviceRegistry) LastModified() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.modified
+}
+
+// GetDevice retrieves a device by ID. The device is shared and must not be modified;
+// updates replace it in the registry, so a retrieved device is a consistent snapshot.
 func (r *DeviceRegistry) GetDevice(deviceID uint16) (*Device, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	device, ok := r.devices[deviceID]
 	if !ok {
 		return nil, fmt.Errorf("device %d not found", deviceID)
@@ -121,6 +299,9 @@ func (r *DeviceRegistry) GetDevice(deviceID uint16) (*Device, error) {
 
 // GetDeviceByToken retrieves a device by token ID
 func (r *DeviceRegistry) GetDeviceByToken(tokenID uint16) (*Device, TokenOffset, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	device, ok := r.tokens[tokenID]
 	if !ok {
 		return nil, 0, fmt.Errorf("token %d not found", tokenID)
@@ -131,8 +312,11 @@ func (r *DeviceRegistry) GetDeviceByToken(tokenID uint16) (*Device, TokenOffset,
 	return device, offset, nil
 }
 
-// ListDevices returns all registered devices
+// ListDevices returns all registered devices, shared as with GetDevice
 func (r *DeviceRegistry) ListDevices() []*Device {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	devices := make([]*Device, 0, len(r.devices))
 	for _, device := range r.devices {
 		devices = append(devices, device)
@@ -140,6 +324,20 @@ func (r *DeviceRegistry) ListDevices() []*Device {
 	return devices
 }
 
+// Snapshot returns copies of all registered devices ordered by ID, together with
+// the registry generation they were taken at
+func (r *DeviceRegistry) Snapshot() ([]*Device, uint64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	devices := make([]*Device, 0, len(r.devices))
+	for _, device := range r.devices {
+		devices = append(devices, device.snapshot())
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID < devices[j].ID })
+	return devices, r.generation
+}
+
 // ClearanceLevel returns the numeric level from a clearance value
 func (c Clearance) Level() int {
 	// Extract the level from the repeating byte pattern
@@ -195,3 +195,93 @@ func TestDeviceRegistry(t *testing.T) {
 		t.Errorf("expected 2 devices, got %d", len(devices))
 	}
 }
+
+func TestDeviceLabels(t *testing.T) {
+	registry := NewDeviceRegistry()
+	device := &Device{ID: 1, Layer: LayerData, Class: DeviceClassSensor}
+	registry.Register(device)
+
+	gen := registry.Generation()
+	if err := registry.SetLabels(1, map[string]string{"site": "bldg-42", "class": "probe"}); err != nil {
+		t.Fatalf("failed to set labels: %v", err)
+	}
+	if registry.Generation() == gen {
+		t.Error("expected generation to change after SetLabels")
+	}
+
+	// Updates replace the device rather than changing it under concurrent readers
+	if len(device.Labels) != 0 {
+		t.Errorf("expected the previously retrieved device to keep its labels, got %v", device.Labels)
+	}
+	device, _ = registry.GetDevice(1)
+	labels := device.SelectorLabels()
+	if labels["site"] != "bldg-42" {
+		t.Errorf("expected site label, got %q", labels["site"])
+	}
+	if labels["class"] != "probe" {
+		t.Errorf("expected explicit class label to override device class, got %q", labels["class"])
+	}
+	if labels["layer"] != string(LayerData) {
+		t.Errorf("expected layer label %q, got %q", LayerData, labels["layer"])
+	}
+
+	if err := registry.SetLabels(99, nil); err == nil {
+		t.Error("expected error when labelling unknown device")
+	}
+}
+
+func TestRegistryObserver(t *testing.T) {
+	registry := NewDeviceRegistry()
+
+	var changes []RegistryChange
+	registry.SetObserver(func(c RegistryChange) {
+		changes = append(changes, c)
+	})
+
+	registry.Register(&Device{ID: 1, Clearance: ClearanceLevel3})
+	if err := registry.SetClearance(1, ClearanceLevel5); err != nil {
+		t.Fatalf("failed to set clearance: %v", err)
+	}
+	if err := registry.SetClearance(1, Clearance(0x01010101)); err == nil {
+		t.Error("expected error for invalid clearance")
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %d", len(changes))
+	}
+	if changes[0].Action != RegistryActionRegister || changes[0].Before != nil {
+		t.Errorf("unexpected registration change: %+v", changes[0])
+	}
+	if changes[1].Before.Clearance != ClearanceLevel3 || changes[1].After.Clearance != ClearanceLevel5 {
+		t.Errorf("unexpected clearance change: before %v after %v", changes[1].Before.Clearance, changes[1].After.Clearance)
+	}
+}
+
+func TestRegistrySnapshot(t *testing.T) {
+	registry := NewDeviceRegistry()
+	registry.Register(&Device{ID: 9, Clearance: ClearanceLevel3})
+	registry.Register(&Device{ID: 2, Clearance: ClearanceLevel3, Labels: map[string]string{"site": "a"}})
+
+	devices, generation := registry.Snapshot()
+	if len(devices) != 2 || devices[0].ID != 2 || devices[1].ID != 9 {
+		t.Fatalf("expected devices ordered by ID, got %+v", devices)
+	}
+	if generation != registry.Generation() {
+		t.Errorf("expected snapshot generation %d, got %d", registry.Generation(), generation)
+	}
+
+	devices[0].Labels["site"] = "b"
+	if err := registry.SetLabels(2, map[string]string{"site": "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if devices[0].Labels["site"] != "b" {
+		t.Error("snapshot shares labels with the registry")
+	}
+
+	if _, next := registry.Snapshot(); next == generation {
+		t.Error("expected generation to change after an update")
+	}
+	if registry.LastModified().IsZero() {
+		t.Error("expected last modified time to be set")
+	}
+}
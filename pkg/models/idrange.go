@@ -0,0 +1,170 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MaxDeviceID is the highest device ID whose tokens fit in 16 bits
+const MaxDeviceID = (0xFFFF - 0x8000 - int(TokenOffsetData)) / 3
+
+// IDRange reserves a block of device IDs for devices of a layer and/or class,
+// e.g. sensors 1-999 and gateways 1000-1999
+type IDRange struct {
+	Layer Layer       `json:"layer,omitempty"` // Empty matches every layer
+	Class DeviceClass `json:"class,omitempty"` // Empty matches every class
+	Min   uint16      `json:"min"`
+	Max   uint16      `json:"max"`
+}
+
+func (r IDRange) String() string {
+	target := "any device"
+	switch {
+	case r.Layer != "" && r.Class != "":
+		target = fmt.Sprintf("%s %ss", r.Layer, r.Class)
+	case r.Layer != "":
+		target = fmt.Sprintf("%s layer", r.Layer)
+	case r.Class != "":
+		target = fmt.Sprintf("%ss", r.Class)
+	}
+	return fmt.Sprintf("%d-%d (%s)", r.Min, r.Max, target)
+}
+
+// Matches reports whether the range is reserved for devices of the layer and class
+func (r IDRange) Matches(layer Layer, class DeviceClass) bool {
+	return (r.Layer == "" || r.Layer == layer) && (r.Class == "" || r.Class == class)
+}
+
+// Contains reports whether the ID falls in the range
+func (r IDRange) Contains(id uint16) bool {
+	return id >= r.Min && id <= r.Max
+}
+
+// ValidateIDRanges checks that ranges are well-formed and that no device could
+// match two ranges sharing an ID
+func ValidateIDRanges(ranges []IDRange) error {
+	for i, r := range ranges {
+		if r.Min == 0 || r.Min > r.Max {
+			return fmt.Errorf("invalid device ID range %s", r)
+		}
+		if int(r.Max) > MaxDeviceID {
+			return fmt.Errorf("device ID range %s exceeds the maximum device ID %d", r, MaxDeviceID)
+		}
+		for _, other := range ranges[:i] {
+			overlapping := r.Min <= other.Max && other.Min <= r.Max
+			sameLayer := r.Layer == "" || other.Layer == "" || r.Layer == other.Layer
+			sameClass := r.Class == "" || other.Class == "" || r.Class == other.Class
+			if overlapping && sameLayer && sameClass {
+				return fmt.Errorf("device ID range %s overlaps %s", r, other)
+			}
+		}
+	}
+	return nil
+}
+
+// SetIDRanges installs the device ID reservations enforced by Register. Every
+// registered device must already satisfy them.
+func (r *DeviceRegistry) SetIDRanges(ranges []IDRange) error {
+	if err := ValidateIDRanges(ranges); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	installed := append([]IDRange(nil), ranges...)
+	for _, device := range r.devices {
+		if err := checkDeviceID(installed, device); err != nil {
+			return err
+		}
+	}
+	r.idRanges = installed
+	return nil
+}
+
+// IDRanges returns the installed device ID reservations
+func (r *DeviceRegistry) IDRanges() []IDRange {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]IDRange(nil), r.idRanges...)
+}
+
+// NextID returns the lowest free ID reserved for devices of the layer and class.
+// The ID is not held; use RegisterNext to allocate and register atomically.
+func (r *DeviceRegistry) NextID(layer Layer, class DeviceClass) (uint16, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.nextID(layer, class)
+}
+
+// RegisterNext assigns the device the lowest free ID reserved for its layer and
+// class, registers it and returns the ID
+func (r *DeviceRegistry) RegisterNext(device *Device) (uint16, error) {
+	return r.RegisterNextBy(device, "")
+}
+
+// RegisterNextBy is RegisterNext on behalf of actor
+func (r *DeviceRegistry) RegisterNextBy(device *Device, actor string) (uint16, error) {
+	r.mu.Lock()
+	changes := r.purgeExpired()
+	id, err := r.nextID(device.Layer, device.Class)
+	if err != nil {
+		r.mu.Unlock()
+		r.notify(changes)
+		return 0, err
+	}
+	device.ID = id
+
+	// register releases the lock
+	return id, r.register(device, changes, actor)
+}
+
+// nextID finds a free ID; callers must hold r.mu
+func (r *DeviceRegistry) nextID(layer Layer, class DeviceClass) (uint16, error) {
+	var matching []IDRange
+	for _, rng := range r.idRanges {
+		if rng.Matches(layer, class) {
+			matching = append(matching, rng)
+		}
+	}
+	if len(matching) == 0 {
+		return 0, fmt.Errorf("no device ID range reserved for %s %s devices", layer, class)
+	}
+
+	sort.Slice(matching, func(i, j int) bool { return matching[i].Min < matching[j].Min })
+	for _, rng := range matching {
+		for id := int(rng.Min); id <= int(rng.Max); id++ {
+			_, taken := r.devices[uint16(id)]
+			_, deleted := r.deleted[uint16(id)]
+			if !taken && !deleted {
+				return uint16(id), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("device ID ranges for %s %s devices are exhausted", layer, class)
+}
+
+// checkDeviceID enforces the reservations: a device matching any range must use
+// an ID from one of them, and no device may take an ID reserved for others
+func checkDeviceID(ranges []IDRange, device *Device) error {
+	matched := false
+	for _, rng := range ranges {
+		if !rng.Matches(device.Layer, device.Class) {
+			continue
+		}
+		matched = true
+		if rng.Contains(device.ID) {
+			return nil
+		}
+	}
+
+	for _, rng := range ranges {
+		if rng.Contains(device.ID) {
+			return fmt.Errorf("device ID %d is reserved for %s", device.ID, rng)
+		}
+	}
+	if matched {
+		return fmt.Errorf("device ID %d is outside the ranges reserved for %s %s devices", device.ID, device.Layer, device.Class)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"testing"
+)
+
+func rangedRegistry(t *testing.T) *DeviceRegistry {
+	t.Helper()
+	registry := NewDeviceRegistry()
+	err := registry.SetIDRanges([]IDRange{
+		{Class: DeviceClassSensor, Min: 1, Max: 3},
+		{Class: DeviceClassGateway, Min: 1000, Max: 1999},
+	})
+	if err != nil {
+		t.Fatalf("SetIDRanges: %v", err)
+	}
+	return registry
+}
+
+func TestValidateIDRanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		ranges  []IDRange
+		wantErr bool
+	}{
+		{"disjoint", []IDRange{{Class: DeviceClassSensor, Min: 1, Max: 999}, {Class: DeviceClassGateway, Min: 1000, Max: 1999}}, false},
+		{"same IDs for different classes", []IDRange{{Class: DeviceClassSensor, Min: 1, Max: 999}, {Class: DeviceClassGateway, Min: 1, Max: 999}}, false},
+		{"overlapping same class", []IDRange{{Class: DeviceClassSensor, Min: 1, Max: 999}, {Class: DeviceClassSensor, Min: 500, Max: 1500}}, true},
+		{"overlapping wildcard layer", []IDRange{{Class: DeviceClassSensor, Min: 1, Max: 999}, {Layer: LayerData, Min: 999, Max: 1999}}, true},
+		{"min above max", []IDRange{{Min: 10, Max: 1}}, true},
+		{"zero ID", []IDRange{{Min: 0, Max: 10}}, true},
+		{"beyond token space", []IDRange{{Min: 1, Max: uint16(MaxDeviceID + 1)}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateIDRanges(tt.ranges)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateIDRanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegisterEnforcesIDRanges(t *testing.T) {
+	registry := rangedRegistry(t)
+
+	tests := []struct {
+		name    string
+		device  *Device
+		wantErr bool
+	}{
+		{"sensor in range", &Device{ID: 2, Class: DeviceClassSensor}, false},
+		{"sensor outside range", &Device{ID: 1500, Class: DeviceClassSensor}, true},
+		{"gateway in sensor range", &Device{ID: 3, Class: DeviceClassGateway}, true},
+		{"unreserved class outside ranges", &Device{ID: 5000, Class: DeviceClassActuator}, false},
+		{"unreserved class inside a range", &Device{ID: 1001, Class: DeviceClassActuator}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := registry.Register(tt.device)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetIDRangesRejectsExistingDevices(t *testing.T) {
+	registry := NewDeviceRegistry()
+	if err := registry.Register(&Device{ID: 1500, Class: DeviceClassSensor}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	if err := registry.SetIDRanges([]IDRange{{Class: DeviceClassSensor, Min: 1, Max: 999}}); err == nil {
+		t.Fatal("expected error for a registered device outside its range")
+	}
+	if len(registry.IDRanges()) != 0 {
+		t.Error("expected no ranges installed after a rejected update")
+	}
+}
+
+func TestRegisterNext(t *testing.T) {
+	registry := rangedRegistry(t)
+	if err := registry.Register(&Device{ID: 1, Class: DeviceClassSensor}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	if id, err := registry.NextID(LayerData, DeviceClassSensor); err != nil || id != 2 {
+		t.Fatalf("NextID() = %d, %v; want 2", id, err)
+	}
+
+	for _, want := range []uint16{2, 3} {
+		device := &Device{Class: DeviceClassSensor}
+		id, err := registry.RegisterNext(device)
+		if err != nil {
+			t.Fatalf("RegisterNext: %v", err)
+		}
+		if id != want || device.ID != want {
+			t.Errorf("RegisterNext() = %d (device %d), want %d", id, device.ID, want)
+		}
+		if _, err := registry.GetDevice(want); err != nil {
+			t.Errorf("device %d not registered", want)
+		}
+	}
+
+	if _, err := registry.RegisterNext(&Device{Class: DeviceClassSensor}); err == nil {
+		t.Error("expected error once the sensor range is exhausted")
+	}
+	if _, err := registry.NextID(LayerData, DeviceClassActuator); err == nil {
+		t.Error("expected error for a class with no reserved range")
+	}
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultDeviceRetention is how long a deleted device can be restored before it is purged
+const DefaultDeviceRetention = 30 * 24 * time.Hour
+
+// Tombstone records a soft-deleted device. Its ID stays reserved until the tombstone
+// is purged, so audit events referencing the device remain resolvable.
+type Tombstone struct {
+	Device     *Device   `json:"device"`
+	DeletedAt  time.Time `json:"deleted_at"`
+	PurgeAfter time.Time `json:"purge_after"`
+}
+
+// SetRetention sets how long deleted devices can be restored; zero or negative
+// values restore the default
+func (r *DeviceRegistry) SetRetention(retention time.Duration) {
+	if retention <= 0 {
+		retention = DefaultDeviceRetention
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retention = retention
+}
+
+// Delete soft-deletes a device: it stops resolving by ID or token but can be
+// restored until its retention expires
+func (r *DeviceRegistry) Delete(deviceID uint16) error {
+	return r.DeleteBy(deviceID, "")
+}
+
+// DeleteBy soft-deletes a device on behalf of actor
+func (r *DeviceRegistry) DeleteBy(deviceID uint16, actor string) error {
+	r.mu.Lock()
+	changes := r.purgeExpired()
+
+	device, ok := r.devices[deviceID]
+	if !ok {
+		r.mu.Unlock()
+		r.notify(changes)
+		return fmt.Errorf("device %d not found", deviceID)
+	}
+
+	now := r.now()
+	delete(r.devices, deviceID)
+	delete(r.tokens, device.GetStatusToken())
+	delete(r.tokens, device.GetConfigToken())
+	delete(r.tokens, device.GetDataToken())
+	r.deleted[deviceID] = &Tombstone{Device: device, DeletedAt: now, PurgeAfter: now.Add(r.retention)}
+	r.generation++
+	r.modified = time.Now().UTC()
+
+	changes = append(changes, RegistryChange{Action: RegistryActionDelete, DeviceID: deviceID, Before: device.snapshot(), Actor: actor})
+	r.mu.Unlock()
+
+	r.notify(changes)
+	return nil
+}
+
+// Restore returns a soft-deleted device to the registry
+func (r *DeviceRegistry) Restore(deviceID uint16) error {
+	return r.RestoreBy(deviceID, "")
+}
+
+// RestoreBy returns a soft-deleted device to the registry on behalf of actor
+func (r *DeviceRegistry) RestoreBy(deviceID uint16, actor string) error {
+	r.mu.Lock()
+	changes := r.purgeExpired()
+
+	tombstone, ok := r.deleted[deviceID]
+	if !ok {
+		r.mu.Unlock()
+		r.notify(changes)
+		return fmt.Errorf("deleted device %d not found", deviceID)
+	}
+	device := tombstone.Device
+	if err := checkDeviceID(r.idRanges, device); err != nil {
+		r.mu.Unlock()
+		r.notify(changes)
+		return err
+	}
+
+	delete(r.deleted, deviceID)
+	r.devices[deviceID] = device
+	r.tokens[device.GetStatusToken()] = device
+	r.tokens[device.GetConfigToken()] = device
+	r.tokens[device.GetDataToken()] = device
+	r.generation++
+	r.modified = time.Now().UTC()
+
+	changes = append(changes, RegistryChange{Action: RegistryActionRestore, DeviceID: deviceID, After: device.snapshot(), Actor: actor})
+	r.mu.Unlock()
+
+	r.notify(changes)
+	return nil
+}
+
+// PurgeExpired permanently removes deleted devices whose retention has expired
+// and returns how many were purged
+func (r *DeviceRegistry) PurgeExpired() int {
+	r.mu.Lock()
+	changes := r.purgeExpired()
+	r.mu.Unlock()
+
+	r.notify(changes)
+	return len(changes)
+}
+
+// DeletedDevices returns copies of the restorable tombstones ordered by device ID
+func (r *DeviceRegistry) DeletedDevices() []Tombstone {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := r.now()
+	tombstones := make([]Tombstone, 0, len(r.deleted))
+	for _, tombstone := range r.deleted {
+		if now.After(tombstone.PurgeAfter) {
+			continue
+		}
+		t := *tombstone
+		t.Device = tombstone.Device.snapshot()
+		tombstones = append(tombstones, t)
+	}
+	sort.Slice(tombstones, func(i, j int) bool { return tombstones[i].Device.ID < tombstones[j].Device.ID })
+	return tombstones
+}
+
+// GetDeletedDevice retrieves a soft-deleted device by ID, e.g. to resolve an audit
+// event recorded before the device was deleted
+func (r *DeviceRegistry) GetDeletedDevice(deviceID uint16) (Tombstone, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tombstone, ok := r.deleted[deviceID]
+	if !ok || r.now().After(tombstone.PurgeAfter) {
+		return Tombstone{}, fmt.Errorf("deleted device %d not found", deviceID)
+	}
+	t := *tombstone
+	t.Device = tombstone.Device.snapshot()
+	return t, nil
+}
+
+// purgeExpired removes expired tombstones; callers must hold r.mu and pass the
+// returned changes to notify after releasing it
+func (r *DeviceRegistry) purgeExpired() []RegistryChange {
+	now := r.now()
+	var changes []RegistryChange
+	for id, tombstone := range r.deleted {
+		if !now.After(tombstone.PurgeAfter) {
+			continue
+		}
+		delete(r.deleted, id)
+		changes = append(changes, RegistryChange{Action: RegistryActionPurge, DeviceID: id, Before: tombstone.Device.snapshot()})
+	}
+	if len(changes) > 0 {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].DeviceID < changes[j].DeviceID })
+		r.generation++
+		r.modified = time.Now().UTC()
+	}
+	return changes
+}
+
+// notify passes changes to the observer; it must be called without r.mu held
+func (r *DeviceRegistry) notify(changes []RegistryChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	r.mu.RLock()
+	observer := r.observer
+	r.mu.RUnlock()
+
+	if observer == nil {
+		return
+	}
+	for _, change := range changes {
+		observer(change)
+	}
+}
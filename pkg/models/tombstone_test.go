@@ -0,0 +1,128 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeleteAndRestore(t *testing.T) {
+	registry := NewDeviceRegistry()
+	var actions []string
+	registry.SetObserver(func(change RegistryChange) {
+		actions = append(actions, change.Action)
+	})
+
+	device := &Device{ID: 7, Name: "sensor-007", Layer: LayerData, Class: DeviceClassSensor, Clearance: ClearanceLevel3}
+	if err := registry.Register(device); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	token := device.GetDataToken()
+
+	if err := registry.Delete(7); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := registry.GetDevice(7); err == nil {
+		t.Error("deleted device still resolves by ID")
+	}
+	if _, _, err := registry.GetDeviceByToken(token); err == nil {
+		t.Error("deleted device still resolves by token")
+	}
+	if err := registry.Register(&Device{ID: 7}); err == nil {
+		t.Error("expected error registering the ID of a deleted device")
+	}
+
+	tombstone, err := registry.GetDeletedDevice(7)
+	if err != nil {
+		t.Fatalf("GetDeletedDevice: %v", err)
+	}
+	if tombstone.Device.Name != "sensor-007" {
+		t.Errorf("tombstone device = %q, want sensor-007", tombstone.Device.Name)
+	}
+	if got := tombstone.PurgeAfter.Sub(tombstone.DeletedAt); got != DefaultDeviceRetention {
+		t.Errorf("retention = %v, want %v", got, DefaultDeviceRetention)
+	}
+	if deleted := registry.DeletedDevices(); len(deleted) != 1 {
+		t.Errorf("DeletedDevices() returned %d tombstones, want 1", len(deleted))
+	}
+
+	if err := registry.Restore(7); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if _, _, err := registry.GetDeviceByToken(token); err != nil {
+		t.Errorf("restored device does not resolve by token: %v", err)
+	}
+	if err := registry.Restore(7); err == nil {
+		t.Error("expected error restoring a device that is not deleted")
+	}
+
+	want := []string{RegistryActionRegister, RegistryActionDelete, RegistryActionRestore}
+	if len(actions) != len(want) {
+		t.Fatalf("observed actions %v, want %v", actions, want)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Errorf("observed actions %v, want %v", actions, want)
+			break
+		}
+	}
+}
+
+func TestDeletedDevicesArePurgedAfterRetention(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	registry := NewDeviceRegistry()
+	registry.now = func() time.Time { return now }
+	registry.SetRetention(time.Hour)
+
+	var purged []uint16
+	registry.SetObserver(func(change RegistryChange) {
+		if change.Action == RegistryActionPurge {
+			purged = append(purged, change.DeviceID)
+		}
+	})
+
+	for _, id := range []uint16{1, 2} {
+		if err := registry.Register(&Device{ID: id}); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		if err := registry.Delete(id); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+	}
+
+	now = now.Add(2 * time.Hour)
+	if deleted := registry.DeletedDevices(); len(deleted) != 0 {
+		t.Errorf("DeletedDevices() returned %d expired tombstones", len(deleted))
+	}
+	if err := registry.Restore(1); err == nil {
+		t.Error("expected error restoring a device past its retention")
+	}
+	if len(purged) != 2 {
+		t.Errorf("purged %v, want devices 1 and 2", purged)
+	}
+
+	// Purged IDs are free again
+	if err := registry.Register(&Device{ID: 2}); err != nil {
+		t.Errorf("Register after purge: %v", err)
+	}
+	if n := registry.PurgeExpired(); n != 0 {
+		t.Errorf("PurgeExpired() = %d, want 0", n)
+	}
+}
+
+func TestRegisterNextSkipsDeletedIDs(t *testing.T) {
+	registry := rangedRegistry(t)
+	if err := registry.Register(&Device{ID: 1, Class: DeviceClassSensor}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := registry.Delete(1); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	id, err := registry.RegisterNext(&Device{Class: DeviceClassSensor})
+	if err != nil {
+		t.Fatalf("RegisterNext: %v", err)
+	}
+	if id != 2 {
+		t.Errorf("RegisterNext() = %d, want 2 while device 1 is restorable", id)
+	}
+}
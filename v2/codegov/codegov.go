@@ -0,0 +1,118 @@
+// Package codegov is version 2 of the code.gov inventory API. Every function that
+// does I/O takes a context first and its settings in an options struct, so options
+// can be added later without breaking callers.
+//
+// Types are aliases of their version 1 counterparts, so inventories, options and
+// reports pass freely between the two versions while importers migrate one call at
+// a time. Functions that do no I/O, such as Diff, Merge and Summarize, have not
+// changed and are used from version 1.
+package codegov
+
+import (
+	"context"
+
+	v1 "github.com/NSACodeGov/CodeGov/codegov"
+)
+
+// Inventory types
+type (
+	CodeGovJSON     = v1.CodeGovJSON
+	Release         = v1.Release
+	Contact         = v1.Contact
+	Permissions     = v1.Permissions
+	License         = v1.License
+	DateInfo        = v1.DateInfo
+	Partner         = v1.Partner
+	MeasurementType = v1.MeasurementType
+)
+
+// Generation, validation and publishing types
+type (
+	GenerateOptions  = v1.GenerateOptions
+	GenerationReport = v1.GenerationReport
+	Credentials      = v1.Credentials
+	Progress         = v1.Progress
+	ProgressFunc     = v1.ProgressFunc
+	Schema           = v1.Schema
+	ValidationResult = v1.ValidationResult
+	IssueSeverity    = v1.IssueSeverity
+	PublishOptions   = v1.PublishOptions
+	PublishedFile    = v1.PublishedFile
+)
+
+// Generate builds an inventory and reports the organizations and repositories that
+// were skipped or published with incomplete data. ctx cancels the run and replaces
+// GenerateOptions.Context, which is ignored. The report is returned with the error
+// when no organization can be listed.
+func Generate(ctx context.Context, opts GenerateOptions) (*CodeGovJSON, *GenerationReport, error) {
+	opts.Context = ctx
+	return v1.GenerateWithReport(opts)
+}
+
+// GenerateFile is Generate, writing the inventory to path; a .yaml or .yml path
+// writes YAML
+func GenerateFile(ctx context.Context, opts GenerateOptions, path string) (*GenerationReport, error) {
+	opts.Context = ctx
+	return v1.GenerateFileWithReport(opts, path)
+}
+
+// ValidateOptions configures Validate and ValidateFile
+type ValidateOptions struct {
+	Schema *Schema // Defaults to the embedded code.gov 2.0.0 schema
+}
+
+func (o ValidateOptions) schema() *Schema {
+	if o.Schema == nil {
+		return v1.DefaultSchema()
+	}
+	return o.Schema
+}
+
+// Validate checks an inventory document against the schema and the semantic checks
+// of version 1's ValidateSemantics. The document is valid when no result has
+// severity error.
+func Validate(ctx context.Context, data []byte, opts ValidateOptions) ([]ValidationResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return v1.ValidateCodeGovJSON(data, opts.schema())
+}
+
+// ValidateFile is Validate for a JSON or YAML file
+func ValidateFile(ctx context.Context, path string, opts ValidateOptions) ([]ValidationResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return v1.ValidateCodeGovJSONFile(path, opts.schema())
+}
+
+// ReadFile loads a JSON or YAML inventory
+func ReadFile(ctx context.Context, path string) (*CodeGovJSON, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return v1.ReadCodeGovJSONFile(path)
+}
+
+// OverrideOptions configures Override
+type OverrideOptions struct {
+	Input     string // Inventory to apply the overrides to
+	Output    string // Where the result is written; may equal Input
+	Overrides string // {"overrides": [...]} document or RFC 6902 JSON Patch
+}
+
+// Override applies an overrides file or JSON Patch to an inventory file
+func Override(ctx context.Context, opts OverrideOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return v1.InvokeCodeGovJsonOverride(opts.Input, opts.Output, opts.Overrides)
+}
+
+// Publish uploads files to opts.Destination and verifies each upload
+func Publish(ctx context.Context, opts PublishOptions, files ...string) ([]PublishedFile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return v1.Publish(opts, files...)
+}
@@ -0,0 +1,48 @@
+package codegov
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := Generate(ctx, GenerateOptions{
+		Organizations: []string{"example"},
+		Agency:        "TEST",
+		Contact:       Contact{Email: "code@agency.gov"},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "code.json")
+	os.WriteFile(path, []byte(`{"version":"2.0.0","agency":"TEST","releases":[{"name":"alpha","status":"production"}]}`), 0644)
+
+	results, err := ValidateFile(context.Background(), path, ValidateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status bool
+	for _, r := range results {
+		if r.Path == "releases/0/status" {
+			status = true
+		}
+	}
+	if !status {
+		t.Errorf("expected a status error, got %v", results)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ValidateFile(ctx, path, ValidateOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/NSACodeGov/CodeGov/v2
+
+go 1.21
+
+require github.com/NSACodeGov/CodeGov v1.0.0
+
+// Version 2 wraps the version 1 implementation in this repository
+replace github.com/NSACodeGov/CodeGov => ../